@@ -0,0 +1,130 @@
+// cmd/export-shop/main.go regenerates gameConfig/seed-shop-merged-export.json
+// from the live seed shop of a logged-in account.
+//
+// Usage:
+//
+//	go run ./cmd/export-shop -platform qq -code <login code>
+//	go run ./cmd/export-shop -account 3            # platform/code from the database
+//	go run ./cmd/export-shop -account 3 -dry-run   # print the rows, write nothing
+//
+// The game server and client version come from config.json (game_servers per
+// platform, then the account's server_url_override, then -server).
+//
+// Exit codes:
+//
+//	0  export written (or printed with -dry-run)
+//	1  bad flags, config or account
+//	2  connect or login failed
+//	3  seed shop request failed or returned no goods
+//	4  writing the export failed
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/store"
+)
+
+const (
+	exitUsage   = 1
+	exitConnect = 2
+	exitShop    = 3
+	exitWrite   = 4
+)
+
+func fail(code int, format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(code)
+}
+
+func main() {
+	configPath := flag.String("config", "config.json", "server config file (game server URL and client version)")
+	configDir := flag.String("config-dir", "gameConfig", "game config directory to read Plant.json from and write the export to")
+	platform := flag.String("platform", "qq", "account platform: qq or wx")
+	code := flag.String("code", "", "login code")
+	accountID := flag.Int64("account", 0, "read platform and code from this account in the database")
+	dbPath := flag.String("db", "", "SQLite database path (default: the database from config)")
+	serverURL := flag.String("server", "", "game server URL, overrides config and account")
+	dryRun := flag.Bool("dry-run", false, "print the rows without writing the export")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fail(exitUsage, "加载配置失败: %v", err)
+	}
+
+	server, clientVersion := cfg.GameServerFor(*platform)
+	if *accountID > 0 {
+		driver, dsn := cfg.DBSource()
+		if *dbPath != "" {
+			driver, dsn = store.DriverSQLite, *dbPath
+		}
+		s, err := store.Open(driver, dsn)
+		if err != nil {
+			fail(exitUsage, "打开数据库失败: %v", err)
+		}
+		account, err := s.GetAccount(context.Background(), *accountID)
+		s.Close()
+		if err != nil {
+			fail(exitUsage, "读取账号 #%d 失败: %v", *accountID, err)
+		}
+		*platform, *code = account.Platform, account.Code
+		server, clientVersion = cfg.GameServerFor(account.Platform)
+		if account.ServerURLOverride != "" {
+			server = account.ServerURLOverride
+		}
+	}
+	if *serverURL != "" {
+		server = *serverURL
+	}
+	if *code == "" {
+		fail(exitUsage, "缺少登录 code: 请使用 -code 或 -account")
+	}
+
+	gc := bot.LoadGameConfig(*configDir, bot.NewLogger(bot.SystemAccountID, nil, nil))
+	if gc.PlantCount() == 0 {
+		fail(exitUsage, "%s 中没有可用的 Plant.json", *configDir)
+	}
+
+	session, err := bot.Dial(server, *platform, clientVersion, *code, nil)
+	if err != nil {
+		fail(exitConnect, "登录失败: %v", err)
+	}
+	goods, err := session.SeedShopGoods()
+	session.Close()
+	if err != nil {
+		fail(exitShop, "获取种子商店失败: %v", err)
+	}
+	if len(goods) == 0 {
+		fail(exitShop, "种子商店无商品")
+	}
+
+	export, skipped := gc.BuildLiveShopExport(goods)
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Plant.json 中缺少以下种子, 已跳过: %v\n", skipped)
+	}
+
+	if *dryRun {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "seedId\tgoodsId\tplantId\tname\tlevel\tprice\texp\tgrowTimeSec\tfruitId\tfruitCount")
+		for _, r := range export.Rows {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+				r.SeedID, r.GoodsID, r.PlantID, r.Name, r.RequiredLevel, r.Price, r.Exp, r.GrowTimeSec, r.FruitID, r.FruitCount)
+		}
+		w.Flush()
+		fmt.Printf("%d 种种子 (dry-run, 未写入)\n", export.Count)
+		return
+	}
+
+	if err := bot.WriteSeedShopExport(*configDir, export); err != nil {
+		fail(exitWrite, "写入失败: %v", err)
+	}
+	fmt.Printf("已写入 %s (%d 种种子)\n", filepath.Join(*configDir, "seed-shop-merged-export.json"), export.Count)
+}
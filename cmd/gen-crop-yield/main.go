@@ -1,149 +1,36 @@
 // cmd/gen-crop-yield/main.go generates web/src/data/cropYield.ts from game config data.
 // Usage: go run ./cmd/gen-crop-yield > web/src/data/cropYield.ts
+//
+// Flags:
+//
+//	-config-dir dir   game config directory (default gameConfig)
+//	-lands N          multiply the per-minute rates by N lands (default 1, per land)
+//	-fert type        fertilizer for the *Fert columns: none, normal, organic (default normal)
+//	-format f         output format: ts, json, csv (default ts)
+//	-o path           write to path instead of stdout
+//	-strict           fail on validation warnings too
+//
+// The config files are cross-checked before generating; inconsistencies are
+// reported on stderr and errors (e.g. a shop seed without a plant) exit 1
+// without writing any output.
 package main
 
 import (
+	"bufio"
+	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
-	"strings"
-)
-
-type PlantConfig struct {
-	ID            int    `json:"id"`
-	Name          string `json:"name"`
-	SeedID        int    `json:"seed_id"`
-	LandLevelNeed int    `json:"land_level_need"`
-	Exp           int    `json:"exp"`
-	GrowPhases    string `json:"grow_phases"`
-	Seasons       int    `json:"seasons"`
-	Fruit         struct {
-		ID    int `json:"id"`
-		Count int `json:"count"`
-	} `json:"fruit"`
-}
-
-type SeedShopExport struct {
-	Rows []SeedShopEntry `json:"rows"`
-}
-
-type SeedShopEntry struct {
-	SeedID        int    `json:"seedId"`
-	PlantID       int    `json:"plantId"`
-	Name          string `json:"name"`
-	RequiredLevel int    `json:"requiredLevel"`
-	GrowTimeSec   int    `json:"growTimeSec"`
-	Exp           int    `json:"exp"`
-	Price         int    `json:"price"`
-	FruitID       int    `json:"fruitId"`
-	FruitCount    int    `json:"fruitCount"`
-}
-
-type ItemInfo struct {
-	ID    int `json:"id"`
-	Price int `json:"price"`
-}
-
-type phaseData struct {
-	durations       []int
-	maxPhaseDur     int
-	maxPhaseIdx     int
-	totalGrow       int
-	allEqual        bool
-	season2Phases   []int
-	season2Grow     int
-	season2MaxPhase int
-	season2MaxIdx   int
-	season2AllEqual bool
-}
-
-
-func parseGrowPhases(gp string) []int {
-	var durations []int
-	for _, phase := range strings.Split(gp, ";") {
-		phase = strings.TrimSpace(phase)
-		if phase == "" {
-			continue
-		}
-		parts := strings.Split(phase, ":")
-		if len(parts) == 2 {
-			if v, err := strconv.Atoi(parts[1]); err == nil && v > 0 {
-				durations = append(durations, v)
-			}
-		}
-	}
-	return durations
-}
-
-// parseAllPhaseDurations returns ALL phase durations including zero (mature).
-func parseAllPhaseDurations(gp string) []int {
-	var durations []int
-	for _, phase := range strings.Split(gp, ";") {
-		phase = strings.TrimSpace(phase)
-		if phase == "" {
-			continue
-		}
-		parts := strings.Split(phase, ":")
-		if len(parts) == 2 {
-			if v, err := strconv.Atoi(parts[1]); err == nil {
-				durations = append(durations, v)
-			}
-		}
-	}
-	return durations
-}
 
-func buildPhaseData(durations []int, seasons int, growPhases string) *phaseData {
-	pd := &phaseData{durations: durations}
-	for i, d := range durations {
-		pd.totalGrow += d
-		if d > pd.maxPhaseDur {
-			pd.maxPhaseDur = d
-			pd.maxPhaseIdx = i
-		}
-	}
-	pd.allEqual = true
-	for _, d := range durations {
-		if d != durations[0] {
-			pd.allEqual = false
-			break
-		}
-	}
-	if seasons >= 2 {
-		allPhases := parseAllPhaseDurations(growPhases)
-		if len(allPhases) >= 3 {
-			last3 := allPhases[len(allPhases)-3:]
-			var s2Phases []int
-			for _, d := range last3 {
-				if d > 0 {
-					s2Phases = append(s2Phases, d)
-				}
-			}
-			if len(s2Phases) > 0 {
-				pd.season2Phases = s2Phases
-				for i, d := range s2Phases {
-					pd.season2Grow += d
-					if d > pd.season2MaxPhase {
-						pd.season2MaxPhase = d
-						pd.season2MaxIdx = i
-					}
-				}
-				pd.season2AllEqual = true
-				for _, d := range s2Phases {
-					if d != s2Phases[0] {
-						pd.season2AllEqual = false
-						break
-					}
-				}
-			}
-		}
-	}
-	return pd
-}
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/yield"
+)
 
 func formatTime(seconds int) string {
 	if seconds < 60 {
@@ -171,140 +58,125 @@ func formatTime(seconds int) string {
 }
 
 type cropRow struct {
-	rank             int
-	cropID           int
-	seedID           int
-	name             string
-	requiredLevel    int
-	seasons          int
-	growTime         string // display string
-	growTimeFert     string // display string with fert
-	harvestExp       int    // total exp per full cycle (all seasons)
-	fruitCount       int
-	fruitPrice       int
-	expPerMinNoFert  float64
-	expPerMinFert    float64
-	goldPerMinNoFert float64
-	goldPerMinFert   float64
+	Rank             int     `json:"rank"`
+	CropID           int     `json:"cropId"`
+	SeedID           int     `json:"seedId"`
+	Name             string  `json:"name"`
+	RequiredLevel    int     `json:"requiredLevel"`
+	Seasons          int     `json:"seasons"`
+	GrowTime         string  `json:"growTime"`     // display string
+	GrowTimeFert     string  `json:"growTimeFert"` // display string with fert
+	HarvestExp       int     `json:"harvestExp"`   // total exp per full cycle (all seasons)
+	FruitCount       int     `json:"fruitCount"`
+	FruitPrice       int     `json:"fruitPrice"`
+	ExpPerMinNoFert  float64 `json:"expPerMinNoFert"`
+	ExpPerMinFert    float64 `json:"expPerMinFert"`
+	GoldPerMinNoFert float64 `json:"goldPerMinNoFert"`
+	GoldPerMinFert   float64 `json:"goldPerMinFert"`
 }
 
-// calcCropRow computes yield metrics for a single crop.
-func calcCropRow(cropID, seedID int, name string, requiredLevel, seasons, growTimeSec, exp, fruitCount, fruitPrice int, pd *phaseData) cropRow {
-	var s1FertReduce, s2FertReduce, s2GrowTime int
-	if pd != nil {
-		s1FertReduce = pd.maxPhaseDur
-		if seasons >= 2 {
-			s2GrowTime = pd.season2Grow
-			s2FertReduce = pd.season2MaxPhase
-		}
-	}
-
-	// Season 1 with fert
-	s1GrowFert := growTimeSec - s1FertReduce
-	if s1GrowFert < 1 {
-		s1GrowFert = 1
-	}
-
-	// Total effective grow time (all seasons with fert)
-	totalGrowFert := s1GrowFert
-	totalExp := exp
-	if seasons >= 2 && s2GrowTime > 0 {
-		s2GrowFert := s2GrowTime - s2FertReduce
-		if s2GrowFert < 1 {
-			s2GrowFert = 1
-		}
-		totalGrowFert += s2GrowFert
-		totalExp += exp
-	}
+type options struct {
+	lands int
+	fert  yield.Fertilizer
+}
 
-	// Total without fert
-	totalGrowNoFert := growTimeSec
-	totalExpNoFert := exp
-	if seasons >= 2 && s2GrowTime > 0 {
-		totalGrowNoFert += s2GrowTime
-		totalExpNoFert += exp
-	}
+// calcCropRow computes yield metrics for a single crop.
+func calcCropRow(cropID, seedID int, name string, requiredLevel, seasons, growTimeSec, exp, fruitCount, fruitPrice int, pd *yield.PhaseData, opt options) cropRow {
+	cycle := yield.CalcCycle(growTimeSec, seasons, pd, opt.fert)
+	totalExp := exp * cycle.Harvests
 
 	// Fruit value per cycle
 	totalFruitValue := float64(fruitCount) * float64(fruitPrice) * float64(seasons)
 
-	// Rates: per land per minute (pure growth time, no operation overhead)
-	cycleSecNoFert := float64(totalGrowNoFert)
-	cycleSecFert := float64(totalGrowFert)
+	// Rates: per minute for the given lands (pure growth time, no operation overhead)
+	lands := float64(opt.lands)
+	cycleSecNoFert := float64(cycle.GrowSec)
+	cycleSecFert := float64(cycle.GrowSecFert)
 
-	expPerMinNoFert := float64(totalExpNoFert) / (cycleSecNoFert / 60.0)
-	expPerMinFert := float64(totalExp) / (cycleSecFert / 60.0)
+	expPerMinNoFert := lands * float64(totalExp) / (cycleSecNoFert / 60.0)
+	expPerMinFert := lands * float64(totalExp) / (cycleSecFert / 60.0)
 
-	goldPerMinNoFert := totalFruitValue / (cycleSecNoFert / 60.0)
-	goldPerMinFert := totalFruitValue / (cycleSecFert / 60.0)
+	goldPerMinNoFert := lands * totalFruitValue / (cycleSecNoFert / 60.0)
+	goldPerMinFert := lands * totalFruitValue / (cycleSecFert / 60.0)
 
 	return cropRow{
-		cropID:           cropID,
-		seedID:           seedID,
-		name:             name,
-		requiredLevel:    requiredLevel,
-		seasons:          seasons,
-		growTime:         formatTime(totalGrowNoFert),
-		growTimeFert:     formatTime(totalGrowFert),
-		harvestExp:       totalExp,
-		fruitCount:       fruitCount,
-		fruitPrice:       fruitPrice,
-		expPerMinNoFert:  math.Round(expPerMinNoFert*100) / 100,
-		expPerMinFert:    math.Round(expPerMinFert*100) / 100,
-		goldPerMinNoFert: math.Round(goldPerMinNoFert*100) / 100,
-		goldPerMinFert:   math.Round(goldPerMinFert*100) / 100,
+		CropID:           cropID,
+		SeedID:           seedID,
+		Name:             name,
+		RequiredLevel:    requiredLevel,
+		Seasons:          seasons,
+		GrowTime:         formatTime(cycle.GrowSec),
+		GrowTimeFert:     formatTime(cycle.GrowSecFert),
+		HarvestExp:       totalExp,
+		FruitCount:       fruitCount,
+		FruitPrice:       fruitPrice,
+		ExpPerMinNoFert:  math.Round(expPerMinNoFert*100) / 100,
+		ExpPerMinFert:    math.Round(expPerMinFert*100) / 100,
+		GoldPerMinNoFert: math.Round(goldPerMinNoFert*100) / 100,
+		GoldPerMinFert:   math.Round(goldPerMinFert*100) / 100,
 	}
 }
 
-func main() {
-	configDir := filepath.Join("gameConfig")
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
 
-	// Load Plant.json
-	var plants []PlantConfig
-	data, err := os.ReadFile(filepath.Join(configDir, "Plant.json"))
+func readJSON(path string, v any) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading Plant.json: %v\n", err)
-		os.Exit(1)
+		fatalf("Error reading %s: %v", filepath.Base(path), err)
 	}
-	if err := json.Unmarshal(data, &plants); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing Plant.json: %v\n", err)
-		os.Exit(1)
+	if err := json.Unmarshal(data, v); err != nil {
+		fatalf("Error parsing %s: %v", filepath.Base(path), err)
 	}
+}
+
+func main() {
+	configDir := flag.String("config-dir", "gameConfig", "game config directory")
+	lands := flag.Int("lands", 1, "multiply the per-minute rates by this many lands")
+	fertName := flag.String("fert", string(yield.FertNormal), "fertilizer for the *Fert columns: none, normal, organic")
+	format := flag.String("format", "ts", "output format: ts, json, csv")
+	outPath := flag.String("o", "", "output file (default stdout)")
+	strict := flag.Bool("strict", false, "fail on validation warnings too")
+	flag.Parse()
+
+	fert, err := yield.ParseFertilizer(*fertName)
+	if err != nil {
+		fatalf("Error: %v", err)
+	}
+	if *lands < 1 {
+		fatalf("Error: -lands must be at least 1")
+	}
+	switch *format {
+	case "ts", "json", "csv":
+	default:
+		fatalf("Error: unknown format %q (ts, json, csv)", *format)
+	}
+	opt := options{lands: *lands, fert: fert}
+
+	var plants []bot.PlantConfig
+	readJSON(filepath.Join(*configDir, "Plant.json"), &plants)
 
 	// Build plantMap
-	plantMap := make(map[int]*PlantConfig)
+	plantMap := make(map[int]*bot.PlantConfig)
 	for i := range plants {
 		plantMap[plants[i].ID] = &plants[i]
 	}
 
-	// Load seed-shop-merged-export.json
-	var shopExport SeedShopExport
-	data, err = os.ReadFile(filepath.Join(configDir, "seed-shop-merged-export.json"))
+	var shopExport bot.SeedShopExport
+	readJSON(filepath.Join(*configDir, "seed-shop-merged-export.json"), &shopExport)
+
+	// Load ItemInfo.json for fruit sell prices (same loader as the runtime GameConfig)
+	fruitPriceMap, err := bot.LoadItemPrices(*configDir)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading seed-shop-merged-export.json: %v\n", err)
-		os.Exit(1)
-	}
-	if err := json.Unmarshal(data, &shopExport); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing seed-shop-merged-export.json: %v\n", err)
-		os.Exit(1)
+		fatalf("Error loading ItemInfo.json: %v", err)
 	}
 
-	// Load ItemInfo.json for fruit sell prices
-	fruitPriceMap := make(map[int]int)
-	{
-		var items []ItemInfo
-		data, err := os.ReadFile(filepath.Join(configDir, "ItemInfo.json"))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading ItemInfo.json: %v\n", err)
-			os.Exit(1)
-		}
-		if err := json.Unmarshal(data, &items); err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing ItemInfo.json: %v\n", err)
-			os.Exit(1)
-		}
-		for _, item := range items {
-			fruitPriceMap[item.ID] = item.Price
-		}
+	rep := bot.CheckConsistency(plants, &shopExport, fruitPriceMap)
+	rep.Write(os.Stderr)
+	if len(rep.Errors) > 0 || (*strict && len(rep.Warnings) > 0) {
+		os.Exit(1)
 	}
 
 	// Track processed plant IDs to avoid duplicates
@@ -321,29 +193,26 @@ func main() {
 		plant := plantMap[s.PlantID]
 		if plant == nil {
 			// Try via seedToPlant
-			for _, p := range plants {
-				if p.SeedID == s.SeedID {
-					plant = &p
+			for i := range plants {
+				if plants[i].SeedID == s.SeedID {
+					plant = &plants[i]
 					break
 				}
 			}
 		}
 
 		seasons := 1
-		var pd *phaseData
+		var pd *yield.PhaseData
 
 		if plant != nil {
 			if plant.Seasons >= 2 {
 				seasons = plant.Seasons
 			}
-			durations := parseGrowPhases(plant.GrowPhases)
-			if len(durations) > 0 {
-				pd = buildPhaseData(durations, seasons, plant.GrowPhases)
-			}
+			pd, _ = yield.BuildPhaseData(plant.GrowPhases, seasons)
 		}
 
 		row := calcCropRow(s.PlantID, s.SeedID, s.Name, s.RequiredLevel, seasons,
-			s.GrowTimeSec, s.Exp, s.FruitCount, fruitPriceMap[s.FruitID], pd)
+			s.GrowTimeSec, s.Exp, s.FruitCount, fruitPriceMap[s.FruitID], pd, opt)
 		rows = append(rows, row)
 		processedPlants[s.PlantID] = true
 	}
@@ -354,62 +223,110 @@ func main() {
 		if p.SeedID <= 0 || processedPlants[p.ID] {
 			continue
 		}
-		durations := parseGrowPhases(p.GrowPhases)
-		if len(durations) == 0 {
-			continue
-		}
 		seasons := p.Seasons
 		if seasons < 1 {
 			seasons = 1
 		}
-		pd := buildPhaseData(durations, seasons, p.GrowPhases)
-		// Calculate total grow time from phases
-		growTimeSec := 0
-		for _, d := range durations {
-			growTimeSec += d
+		pd, _ := yield.BuildPhaseData(p.GrowPhases, seasons)
+		if pd == nil {
+			continue
 		}
 		row := calcCropRow(p.ID, p.SeedID, p.Name, p.LandLevelNeed, seasons,
-			growTimeSec, p.Exp, p.Fruit.Count, fruitPriceMap[p.Fruit.ID], pd)
+			pd.TotalGrowTime, p.Exp, p.Fruit.Count, fruitPriceMap[p.Fruit.ID], pd, opt)
 		rows = append(rows, row)
 	}
 
 	// Sort by expPerMinFert descending
 	sort.Slice(rows, func(i, j int) bool {
-		return rows[i].expPerMinFert > rows[j].expPerMinFert
+		return rows[i].ExpPerMinFert > rows[j].ExpPerMinFert
 	})
 
 	// Assign ranks
 	for i := range rows {
-		rows[i].rank = i + 1
+		rows[i].Rank = i + 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fatalf("Error creating %s: %v", *outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	switch *format {
+	case "ts":
+		writeTS(w, rows, opt)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(rows)
+	case "csv":
+		err = writeCSV(w, rows)
 	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		fatalf("Error writing output: %v", err)
+	}
+}
 
-	// Output TypeScript
-	fmt.Println("export interface CropYield {")
-	fmt.Println("  rank: number")
-	fmt.Println("  cropId: number")
-	fmt.Println("  seedId: number")
-	fmt.Println("  name: string")
-	fmt.Println("  requiredLevel: number")
-	fmt.Println("  seasons: number")
-	fmt.Println("  growTime: string")
-	fmt.Println("  growTimeFert: string")
-	fmt.Println("  harvestExp: number")
-	fmt.Println("  fruitCount: number")
-	fmt.Println("  fruitPrice: number")
-	fmt.Println("  expPerMinNoFert: number")
-	fmt.Println("  expPerMinFert: number")
-	fmt.Println("  goldPerMinNoFert: number")
-	fmt.Println("  goldPerMinFert: number")
-	fmt.Println("}")
-	fmt.Println("")
-	fmt.Println("// Auto-generated from gameConfig data (pure growth time, normal fertilizer, optimal phase)")
-	fmt.Println("// Multi-season crops show combined exp/time across all seasons.")
-	fmt.Println("export const cropYieldData: CropYield[] = [")
+var fertDescriptions = map[yield.Fertilizer]string{
+	yield.FertNone:    "no fertilizer",
+	yield.FertNormal:  "normal fertilizer, optimal phase",
+	yield.FertOrganic: "organic fertilizer, two longest phases",
+}
+
+func writeTS(w io.Writer, rows []cropRow, opt options) {
+	fmt.Fprintln(w, "export interface CropYield {")
+	fmt.Fprintln(w, "  rank: number")
+	fmt.Fprintln(w, "  cropId: number")
+	fmt.Fprintln(w, "  seedId: number")
+	fmt.Fprintln(w, "  name: string")
+	fmt.Fprintln(w, "  requiredLevel: number")
+	fmt.Fprintln(w, "  seasons: number")
+	fmt.Fprintln(w, "  growTime: string")
+	fmt.Fprintln(w, "  growTimeFert: string")
+	fmt.Fprintln(w, "  harvestExp: number")
+	fmt.Fprintln(w, "  fruitCount: number")
+	fmt.Fprintln(w, "  fruitPrice: number")
+	fmt.Fprintln(w, "  expPerMinNoFert: number")
+	fmt.Fprintln(w, "  expPerMinFert: number")
+	fmt.Fprintln(w, "  goldPerMinNoFert: number")
+	fmt.Fprintln(w, "  goldPerMinFert: number")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "// Auto-generated from gameConfig data (pure growth time, %s)\n", fertDescriptions[opt.fert])
+	if opt.lands > 1 {
+		fmt.Fprintf(w, "// Rates are per minute across %d lands.\n", opt.lands)
+	}
+	fmt.Fprintln(w, "// Multi-season crops show combined exp/time across all seasons.")
+	fmt.Fprintln(w, "export const cropYieldData: CropYield[] = [")
 
 	for _, r := range rows {
-		fmt.Printf("  { rank: %d, cropId: %d, seedId: %d, name: '%s', requiredLevel: %d, seasons: %d, growTime: '%s', growTimeFert: '%s', harvestExp: %d, fruitCount: %d, fruitPrice: %d, expPerMinNoFert: %.2f, expPerMinFert: %.2f, goldPerMinNoFert: %.2f, goldPerMinFert: %.2f },\n",
-			r.rank, r.cropID, r.seedID, r.name, r.requiredLevel, r.seasons, r.growTime, r.growTimeFert, r.harvestExp, r.fruitCount, r.fruitPrice, r.expPerMinNoFert, r.expPerMinFert, r.goldPerMinNoFert, r.goldPerMinFert)
+		fmt.Fprintf(w, "  { rank: %d, cropId: %d, seedId: %d, name: '%s', requiredLevel: %d, seasons: %d, growTime: '%s', growTimeFert: '%s', harvestExp: %d, fruitCount: %d, fruitPrice: %d, expPerMinNoFert: %.2f, expPerMinFert: %.2f, goldPerMinNoFert: %.2f, goldPerMinFert: %.2f },\n",
+			r.Rank, r.CropID, r.SeedID, r.Name, r.RequiredLevel, r.Seasons, r.GrowTime, r.GrowTimeFert, r.HarvestExp, r.FruitCount, r.FruitPrice, r.ExpPerMinNoFert, r.ExpPerMinFert, r.GoldPerMinNoFert, r.GoldPerMinFert)
 	}
 
-	fmt.Println("]")
+	fmt.Fprintln(w, "]")
+}
+
+func writeCSV(w io.Writer, rows []cropRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "cropId", "seedId", "name", "requiredLevel", "seasons", "growTime", "growTimeFert",
+		"harvestExp", "fruitCount", "fruitPrice", "expPerMinNoFert", "expPerMinFert", "goldPerMinNoFert", "goldPerMinFert"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	for _, r := range rows {
+		cw.Write([]string{strconv.Itoa(r.Rank), strconv.Itoa(r.CropID), strconv.Itoa(r.SeedID), r.Name,
+			strconv.Itoa(r.RequiredLevel), strconv.Itoa(r.Seasons), r.GrowTime, r.GrowTimeFert,
+			strconv.Itoa(r.HarvestExp), strconv.Itoa(r.FruitCount), strconv.Itoa(r.FruitPrice),
+			f(r.ExpPerMinNoFert), f(r.ExpPerMinFert), f(r.GoldPerMinNoFert), f(r.GoldPerMinFert)})
+	}
+	cw.Flush()
+	return cw.Error()
 }
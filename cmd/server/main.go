@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
 	"fmt"
 	"io/fs"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"qq-farm-bot/internal/api"
 	"qq-farm-bot/internal/bot"
@@ -18,78 +24,357 @@ import (
 //go:embed all:dist
 var embeddedFrontend embed.FS
 
+// version is injected at build time:
+// go build -ldflags "-X main.version=v1.2.3" ./cmd/server/
+var version = "dev"
+
 func main() {
-	// Determine base directory
-	exe, _ := os.Executable()
-	baseDir := filepath.Dir(exe)
-	if wd, err := os.Getwd(); err == nil {
-		baseDir = wd
+	baseDirFlag := flag.String("base-dir", "", "base directory holding config.json, data/ and gameConfig/ (default: working directory, else the executable's directory)")
+	checkConfig := flag.Bool("check-config", false, "validate config.json and the game config, print a summary and exit")
+	migrateOnly := flag.Bool("migrate-only", false, "open the database, apply migrations and exit")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version)
+		return
 	}
 
-	// Load config
+	baseDir, err := resolveBaseDir(*baseDirFlag)
+	if err != nil {
+		fmt.Printf("基础目录无效: %v\n", err)
+		os.Exit(1)
+	}
 	configPath := filepath.Join(baseDir, "config.json")
-	cfg, err := config.Load(configPath)
+	cfg, err := loadConfig(configPath, baseDir)
 	if err != nil {
-		fmt.Printf("加载配置失败: %v\n", err)
+		fmt.Println(err)
 		os.Exit(1)
 	}
+
+	switch {
+	case *checkConfig:
+		os.Exit(runCheckConfig(cfg, configPath))
+	case *migrateOnly:
+		os.Exit(runMigrateOnly(cfg))
+	}
+	os.Exit(runServer(cfg, configPath))
+}
+
+// resolveBaseDir returns the explicit base dir, or the working directory,
+// falling back to the executable's directory.
+func resolveBaseDir(explicit string) (string, error) {
+	if explicit != "" {
+		dir, err := filepath.Abs(explicit)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return "", err
+		} else if !info.IsDir() {
+			return "", fmt.Errorf("%s 不是目录", dir)
+		}
+		return dir, nil
+	}
+	if wd, err := os.Getwd(); err == nil {
+		return wd, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exe), nil
+}
+
+// loadConfig loads, resolves and validates the config. Warnings are printed;
+// validation errors are printed and returned as a single error.
+func loadConfig(configPath, baseDir string) (*config.Config, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %v", err)
+	}
 	cfg.ResolvePaths(baseDir)
 
-	// Save default config if not exists
+	errs, warnings := cfg.Validate()
+	for _, w := range warnings {
+		fmt.Printf("[配置警告] %s\n", w)
+	}
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Printf("[配置错误] %s\n", e)
+		}
+		return nil, fmt.Errorf("配置文件 %s 存在 %d 个错误, 请修正后重新启动", configPath, len(errs))
+	}
+
+	if len(cfg.EnvOverrides) > 0 {
+		// Only names are printed: values may be secrets
+		fmt.Printf("环境变量覆盖配置项: %s\n", strings.Join(cfg.EnvOverrides, ", "))
+	}
+	return cfg, nil
+}
+
+// runCheckConfig validates TLS and the game config on top of loadConfig and
+// prints a summary. Nothing is written and no port is bound.
+func runCheckConfig(cfg *config.Config, configPath string) int {
+	code := 0
+	tls := "未启用"
+	switch {
+	case cfg.TLSAutoSelfSigned && cfg.TLSCert == "" && cfg.TLSKey == "":
+		tls = "自签名证书 (启动时生成)"
+	case cfg.TLSCert != "" || cfg.TLSKey != "":
+		if _, err := cfg.PrepareTLS(); err != nil {
+			fmt.Printf("[配置错误] %v\n", err)
+			code = 1
+		}
+		tls = cfg.TLSCert
+	}
+
+	gc := bot.LoadGameConfig(cfg.GameConfigDir, bot.NewLogger(bot.SystemAccountID, nil, nil))
+	if gc.Degraded() {
+		fmt.Printf("[游戏配置错误] 未加载到植物数据 (%s), 可通过 game_config_dir 指定目录\n", bot.DescribeGameConfigDir(cfg.GameConfigDir))
+		code = 1
+	}
+	gameWarnings := gc.Validate()
+	for _, w := range gameWarnings {
+		fmt.Printf("[游戏配置警告] %s\n", w)
+	}
+
+	fmt.Printf("配置文件:   %s\n", configPath)
+	fmt.Printf("监听地址:   %s\n", cfg.Listen)
+	fmt.Printf("HTTPS:      %s\n", tls)
+	fmt.Printf("数据库:     %s\n", cfg.DBDescription())
+	fmt.Printf("游戏配置:   %s (%d 种植物, %d 个警告)\n", cfg.GameConfigDir, gc.PlantCount(), len(gameWarnings))
+	for _, platform := range []string{"qq", "wx"} {
+		url, clientVersion := cfg.GameServerFor(platform)
+		fmt.Printf("游戏服务器: %s → %s (%s)\n", platform, url, clientVersion)
+	}
+	if code == 0 {
+		fmt.Println("配置检查通过")
+	}
+	return code
+}
+
+// runMigrateOnly opens the database, which applies pending migrations.
+func runMigrateOnly(cfg *config.Config) int {
+	s, err := store.Open(cfg.DBSource())
+	if err != nil {
+		fmt.Printf("数据库迁移失败: %v\n", err)
+		return 1
+	}
+	version, _ := s.SchemaVersion(context.Background())
+	s.Close()
+	fmt.Printf("数据库迁移完成: %s (schema v%d)\n", cfg.DBDescription(), version)
+	return 0
+}
+
+// runServer starts the bots and serves HTTP until a signal stops it.
+func runServer(cfg *config.Config, configPath string) int {
+	// Save default config if not exists (defaults only, so env values such
+	// as secrets are never written to disk)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		cfg.Save(configPath)
+		config.DefaultConfig().Save(configPath)
 		fmt.Printf("已生成默认配置文件: %s\n", configPath)
 	}
 
-	// Init game config
-	bot.LoadGameConfig(cfg.GameConfigDir)
+	// Validate TLS settings (may generate a self-signed certificate)
+	generated, err := cfg.PrepareTLS()
+	if err != nil {
+		fmt.Printf("TLS 配置错误: %v\n", err)
+		return 1
+	}
+	if generated {
+		fmt.Printf("已生成自签名证书: %s\n", cfg.TLSCert)
+	}
 
 	// Init database
-	s, err := store.New(cfg.DBPath)
+	s, err := store.Open(cfg.DBSource())
 	if err != nil {
 		fmt.Printf("初始化数据库失败: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 	defer s.Close()
+	s.SetQueryTimeout(cfg.DBQueryTimeoutDuration())
 
-	// Clean old logs (keep 7 days)
-	s.CleanOldLogs(7)
+	// Clean old logs; the daily maintenance repeats this while running
+	if cfg.LogRetentionDays > 0 {
+		s.CleanOldLogs(context.Background(), cfg.LogRetentionDays)
+	}
+	s.CleanExpiredSessions(context.Background())
+	if cfg.AuditRetentionDays > 0 {
+		s.CleanOldAudit(context.Background(), cfg.AuditRetentionDays)
+	}
 
 	// Init bot manager
 	mgr := bot.NewManager(s, cfg)
+	sysLog := mgr.SystemLogger()
+
+	// Init game config, reporting problems on the system log channel
+	gc := bot.LoadGameConfig(cfg.GameConfigDir, sysLog)
+	if gc.Degraded() {
+		sysLog.Errorf("配置", "游戏配置不可用, 作物名称/推荐/策略均失效, 种植将按等级选种 (%s), 请检查 game_config_dir",
+			bot.DescribeGameConfigDir(cfg.GameConfigDir))
+	}
+	for _, w := range gc.Validate() {
+		sysLog.Warnf("配置", "%s", w)
+	}
+	if interval := cfg.GameConfigReloadEvery(); interval > 0 {
+		go gc.WatchReload(cfg.GameConfigDir, interval, sysLog)
+	}
 
 	// Auto start bots
 	mgr.AutoStart()
 
+	if cfg.AccountPurgeDays > 0 {
+		go purgeDeletedAccounts(s, cfg.AccountPurgeDays, sysLog)
+	}
+	if cfg.MaintenanceHour >= 0 {
+		go runMaintenance(s, cfg, sysLog)
+	}
+
 	// Prepare embedded frontend FS (strip "dist" prefix)
 	frontendFS, err := fs.Sub(embeddedFrontend, "dist")
 	if err != nil {
 		fmt.Printf("加载前端资源失败: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Setup HTTP server
 	router := api.SetupRouter(cfg, s, mgr, frontendFS)
 
 	fmt.Printf("========================================\n")
-	fmt.Printf("  QQ农场管理后台\n")
+	fmt.Printf("  QQ农场管理后台 %s\n", version)
 	fmt.Printf("  监听地址: %s\n", cfg.Listen)
+	if cfg.TLSEnabled() {
+		fmt.Printf("  HTTPS 证书: %s\n", cfg.TLSCert)
+	}
 	fmt.Printf("  管理账号: %s\n", cfg.AdminUser)
 	fmt.Printf("  数据目录: %s\n", cfg.DataDir)
 	fmt.Printf("========================================\n")
 
-	// Graceful shutdown
+	srv := &http.Server{Addr: cfg.Listen, Handler: router.Handler()}
+	var redirect *http.Server
+	certFile, keyFile := "", ""
+	if cfg.TLSEnabled() {
+		certFile, keyFile = cfg.TLSCert, cfg.TLSKey
+		if cfg.HTTPRedirectListen != "" {
+			redirect = &http.Server{Addr: cfg.HTTPRedirectListen, Handler: httpsRedirect(cfg.Listen)}
+			go func() {
+				if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					sysLog.Warnf("HTTP", "HTTPS 跳转监听启动失败: %v", err)
+				}
+			}()
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	if err := serve(srv, redirect, certFile, keyFile, stop); err != nil {
+		fmt.Printf("HTTP 服务启动失败: %v\n", err)
+		return 1
+	}
+	// No request is in flight any more, so none can start a bot again
+	fmt.Println("正在停止所有 Bot...")
+	mgr.StopAll()
+	return 0
+}
+
+// shutdownTimeout bounds how long a stop signal waits for in-flight HTTP
+// requests before the bots are stopped regardless.
+const shutdownTimeout = 10 * time.Second
+
+// serve runs srv, over TLS when certFile is set, until a value arrives on
+// stop. It then shuts srv and redirect (if any) down, waiting up to
+// shutdownTimeout for in-flight requests, and returns nil. It returns the
+// listen error if srv fails to start.
+func serve(srv, redirect *http.Server, certFile, keyFile string, stop <-chan os.Signal) error {
+	serveErr := make(chan error, 1)
 	go func() {
-		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-		<-ch
-		fmt.Println("\n正在停止所有 Bot...")
-		mgr.StopAll()
-		os.Exit(0)
+		if certFile == "" {
+			serveErr <- srv.ListenAndServe()
+		} else {
+			serveErr <- srv.ListenAndServeTLS(certFile, keyFile)
+		}
 	}()
 
-	if err := router.Run(cfg.Listen); err != nil {
-		fmt.Printf("HTTP 服务启动失败: %v\n", err)
-		os.Exit(1)
+	select {
+	case err := <-serveErr:
+		return err
+	case <-stop:
+	}
+	fmt.Println("\n正在停止 HTTP 服务...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Printf("HTTP 服务未能在 %v 内停止: %v\n", shutdownTimeout, err)
 	}
+	if redirect != nil {
+		redirect.Shutdown(ctx)
+	}
+	return nil
+}
+
+// purgeDeletedAccounts hard-deletes accounts that have been in the recycle
+// bin for more than days, checking hourly.
+func purgeDeletedAccounts(s store.Store, days int, logger *bot.Logger) {
+	for {
+		cutoff := time.Now().AddDate(0, 0, -days)
+		if n, err := s.PurgeDeletedAccounts(context.Background(), cutoff); err != nil {
+			logger.Warnf("回收站", "清理已删除账号失败: %v", err)
+		} else if n > 0 {
+			logger.Infof("回收站", "已永久删除 %d 个超过 %d 天的账号", n, days)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// maintenanceTimeout bounds one maintenance run (VACUUM may take a while).
+const maintenanceTimeout = 30 * time.Minute
+
+// runMaintenance prunes old logs, audit rows and sessions and maintains the
+// database every day at cfg.MaintenanceHour local time.
+func runMaintenance(s store.Store, cfg *config.Config, logger *bot.Logger) {
+	for {
+		time.Sleep(time.Until(nextDailyRun(time.Now(), cfg.MaintenanceHour)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+		s.CleanExpiredSessions(ctx)
+		if cfg.AuditRetentionDays > 0 {
+			if err := s.CleanOldAudit(ctx, cfg.AuditRetentionDays); err != nil {
+				logger.Warnf("维护", "清理审计日志失败: %v", err)
+			}
+		}
+		r, err := s.Maintain(ctx, cfg.LogRetentionDays)
+		cancel()
+		if err != nil {
+			logger.Warnf("维护", "数据库维护失败: %v", err)
+			continue
+		}
+		logger.Infof("维护", "数据库维护完成: 删除日志 %d 条, 大小 %.1fMB → %.1fMB, VACUUM=%v, 耗时 %dms",
+			r.LogsDeleted, float64(r.SizeBefore)/(1<<20), float64(r.SizeAfter)/(1<<20), r.Vacuumed, r.DurationMS)
+	}
+}
+
+// nextDailyRun returns the next time after now at hour:00 local time.
+func nextDailyRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// httpsRedirect redirects plain HTTP requests to the HTTPS listener on the
+// same host.
+func httpsRedirect(tlsListen string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsListen)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
 }
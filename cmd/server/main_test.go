@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/store"
+)
+
+// mainArgsEnv, when set in a re-executed test binary, holds the command
+// line (one argument per line) main runs with instead of the tests. The game config
+// is loaded once per process, so each run needs a process of its own.
+const mainArgsEnv = "QQFARM_TEST_MAIN_ARGS"
+
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(mainArgsEnv); ok {
+		os.Args = append([]string{"server"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the server command with args in a child process and returns
+// its exit code and combined output.
+func runMain(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), mainArgsEnv+"="+strings.Join(args, "\n"))
+	out, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) {
+		t.Fatalf("run %v: %v", args, err)
+	}
+	return cmd.ProcessState.ExitCode(), string(out)
+}
+
+// writeConfig writes body as config.json in a new base dir and returns the
+// base dir.
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(baseDir, "config.json"), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return baseDir
+}
+
+// gameConfigDir is the game config shipped with the repo.
+func gameConfigDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.Abs("../../gameConfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCheckConfigValid(t *testing.T) {
+	baseDir := writeConfig(t, `{"listen": "127.0.0.1:8080", "jwt_secret": "s3cret", "game_config_dir": "`+gameConfigDir(t)+`"}`)
+	code, out := runMain(t, "--base-dir", baseDir, "--check-config")
+	if code != 0 || !strings.Contains(out, "配置检查通过") {
+		t.Fatalf("exit %d:\n%s", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "data", "farm.db")); !os.IsNotExist(err) {
+		t.Fatalf("check-config created the database: %v", err)
+	}
+}
+
+func TestCheckConfigInvalid(t *testing.T) {
+	gameDir := gameConfigDir(t)
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"listen", `{"listen": "no-port", "jwt_secret": "s3cret"}`, "listen"},
+		{"db driver", `{"jwt_secret": "s3cret", "db_driver": "mysql"}`, "db_driver"},
+		{"json", `{"listen": `, "加载配置失败"},
+		{"no game config", `{"jwt_secret": "s3cret", "game_config_dir": "empty"}`, "未加载到植物数据"},
+		{"cert without key", `{"jwt_secret": "s3cret", "game_config_dir": "` + gameDir + `", "tls_cert": "missing.pem"}`, "tls_key"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			baseDir := writeConfig(t, tc.body)
+			os.Mkdir(filepath.Join(baseDir, "empty"), 0755)
+			code, out := runMain(t, "--base-dir", baseDir, "--check-config")
+			if code != 1 || !strings.Contains(out, tc.want) || strings.Contains(out, "配置检查通过") {
+				t.Fatalf("exit %d, want 1 reporting %q:\n%s", code, tc.want, out)
+			}
+		})
+	}
+}
+
+func TestMigrateOnly(t *testing.T) {
+	baseDir := writeConfig(t, `{"jwt_secret": "s3cret", "db_path": "data/farm.db"}`)
+	dbPath := filepath.Join(baseDir, "data", "farm.db")
+	for i := 0; i < 2; i++ {
+		if code, out := runMain(t, "--base-dir", baseDir, "--migrate-only"); code != 0 {
+			t.Fatalf("run %d: exit %d:\n%s", i+1, code, out)
+		}
+	}
+
+	s, err := store.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, err := s.SchemaVersion(context.Background()); err != nil || v != store.LatestSchemaVersion {
+		t.Fatalf("SchemaVersion = %d, %v; want %d", v, err, store.LatestSchemaVersion)
+	}
+}
+
+func TestMigrateOnlyNotADatabase(t *testing.T) {
+	baseDir := writeConfig(t, `{"jwt_secret": "s3cret", "db_path": "data/farm.db"}`)
+	os.Mkdir(filepath.Join(baseDir, "data"), 0755)
+	if err := os.WriteFile(filepath.Join(baseDir, "data", "farm.db"), []byte("not a database, just some text padding it out"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, out := runMain(t, "--base-dir", baseDir, "--migrate-only"); code != 1 || !strings.Contains(out, "数据库迁移失败") {
+		t.Fatalf("exit %d, want 1:\n%s", code, out)
+	}
+}
+
+// freeAddr returns a loopback address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServeWaitsForInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Addr: freeAddr(t), Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() { served <- serve(srv, nil, "", "", stop) }()
+
+	body := make(chan string, 1)
+	go func() {
+		var resp *http.Response
+		var err error
+		for i := 0; i < 100; i++ {
+			if resp, err = http.Get("http://" + srv.Addr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	stop <- os.Interrupt
+	select {
+	case err := <-served:
+		t.Fatalf("serve returned with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if got := <-body; got != "done" {
+		t.Fatalf("in-flight request got %q", got)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("serve = %v after a stop signal", err)
+	}
+	if _, err := http.Get("http://" + srv.Addr); err == nil {
+		t.Fatal("server still accepting after shutdown")
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := &http.Server{Addr: l.Addr().String(), Handler: http.NotFoundHandler()}
+	if err := serve(srv, nil, "", "", make(chan os.Signal)); err == nil {
+		t.Fatal("serve on a taken address returned nil")
+	}
+}
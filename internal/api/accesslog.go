@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/config"
+)
+
+// accessLog prints one line per request to stdout in gin's format. At the
+// "warn" level only 4xx/5xx responses are printed.
+func accessLog(level string) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Skip: func(c *gin.Context) bool {
+			return level == config.ConsoleLogWarn && c.Writer.Status() < 400
+		},
+	})
+}
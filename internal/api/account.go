@@ -1,63 +1,116 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/auth"
 	"qq-farm-bot/internal/bot"
 	"qq-farm-bot/internal/config"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
-func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager, cfg *config.Config) {
+func RegisterAccountRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager, cfg *config.Config) {
+	owned := accountOwnership(s)
+
+	// GET /accounts?q=&platform=&tag=&status=running|stopped|error&limit=&offset=
+	// The body stays a plain array; X-Total-Count carries the number of
+	// matching accounts. Without limit every match is returned.
 	r.GET("/accounts", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
+		filter := store.AccountFilter{
+			Query:    strings.TrimSpace(c.Query("q")),
+			Platform: c.Query("platform"),
+			Tag:      c.Query("tag"),
+		}
+		if !c.GetBool("isAdmin") {
+			filter.UserID = c.GetInt64("userID")
+		}
+		filter.ID = auth.TokenScope(c)
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		offset, _ := strconv.Atoi(c.Query("offset"))
+		if limit < 0 || limit > maxAccountPage {
+			limit = maxAccountPage
+		}
+		if offset < 0 {
+			offset = 0
+		}
+		status := c.Query("status")
+		if status != "" && status != "running" && status != "stopped" && status != "error" {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "status must be running, stopped or error")
+			return
+		}
 
 		var accounts []model.Account
+		var total int64
 		var err error
-
-		if isAdmin {
-			accounts, err = s.ListAccounts()
+		if status == "" {
+			filter.Limit, filter.Offset = limit, offset
+			accounts, err = s.ListAccountsFiltered(c.Request.Context(), filter)
+			if err == nil {
+				total, err = s.CountAccounts(c.Request.Context(), filter)
+			}
 		} else {
-			accounts, err = s.ListAccountsByUserID(userID)
+			// Status lives in the Manager, not the database: filter every
+			// match first, then cut the page so total and pages stay exact.
+			accounts, err = s.ListAccountsFiltered(c.Request.Context(), filter)
+			if err == nil {
+				accounts = filterAccountsByStatus(accounts, mgr, status)
+				total = int64(len(accounts))
+				accounts = pageAccounts(accounts, limit, offset)
+			}
+		}
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
 		}
 
+		// Today's counters for the whole page in one query. op_stats rows are
+		// written as operations happen, so running bots are already current.
+		ids := make([]int64, len(accounts))
+		for i, a := range accounts {
+			ids[i] = a.ID
+		}
+		today, err := s.GetTodayCounters(c.Request.Context(), ids, bot.GameDayStart(time.Now()))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
 		type accountResponse struct {
 			model.Account
-			Status string `json:"status"`
-			Level  int64  `json:"level"`
-			Gold   int64  `json:"gold"`
-			Exp    int64  `json:"exp"`
+			HasCode bool                `json:"has_code"`
+			Status  string              `json:"status"`
+			Level   int64               `json:"level"`
+			Gold    int64               `json:"gold"`
+			Exp     int64               `json:"exp"`
+			Today   model.TodayCounters `json:"today"`
 		}
 		var result []accountResponse
 		for _, a := range accounts {
-			ar := accountResponse{Account: a}
+			ar := accountResponse{Account: a, HasCode: a.Code != ""}
+			if t := today[a.ID]; t != nil {
+				ar.Today = *t
+			}
 			bs := mgr.GetStatus(a.ID)
 			// Always populate level/gold/exp from bot status (persisted even when stopped)
 			ar.Level = bs.Level
 			ar.Gold = bs.Gold
 			ar.Exp = bs.Exp
-			if bs.Running {
-				ar.Status = "running"
-			} else if bs.Error != "" {
-				ar.Status = "error"
-			} else {
-				ar.Status = "stopped"
-			}
-			if len(ar.Code) > 8 {
-				ar.Code = ar.Code[:8] + "..."
-			}
+			ar.Status = botStatusName(bs)
+			ar.Code = maskCode(ar.Code)
 			result = append(result, ar)
 		}
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 		c.JSON(http.StatusOK, result)
 	})
 
@@ -71,8 +124,25 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 			AutoStart      bool   `json:"auto_start"`
 			FarmInterval   int    `json:"farm_interval"`
 			FriendInterval int    `json:"friend_interval"`
-			EnableSteal    *bool  `json:"enable_steal"`
-			ForceLowest    bool   `json:"force_lowest"`
+			// Full friend list fetch interval (omitted = 600 s)
+			FriendListRefreshInterval *int `json:"friend_list_refresh_interval"`
+			// Bag sweep interval without harvests (omitted = 600 s)
+			WarehouseInterval *int  `json:"warehouse_interval"`
+			EnableSteal       *bool `json:"enable_steal"`
+			FamilyID          int64 `json:"family_id"`
+			ForceLowest       bool  `json:"force_lowest"`
+			// ForceLowest floors (0 = no limit)
+			ForceLowestMinExp   int `json:"force_lowest_min_exp"`
+			ForceLowestMaxPrice int `json:"force_lowest_max_price"`
+			// Level-up replant (margin omitted = 20%)
+			ReplantOnLevelUp bool `json:"replant_on_level_up"`
+			ReplantMarginPct *int `json:"replant_margin_pct"`
+			// Beginner profile below this level (0 = off)
+			WarmUpLevel int `json:"warm_up_level"`
+			// Conserve mode around this gold balance (0 = off)
+			GoldReserve int64 `json:"gold_reserve"`
+			// Maturity heads-up (0 = off)
+			NotifyBeforeMatureMinutes int `json:"notify_before_mature_minutes"`
 			// Farm automation toggles
 			EnableHarvest     *bool `json:"enable_harvest"`
 			EnablePlant       *bool `json:"enable_plant"`
@@ -85,9 +155,10 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 			EnableHelpFriend  *bool `json:"enable_help_friend"`
 			EnableClaimTask   *bool `json:"enable_claim_task"`
 			// Crop selection
-			PlantCropID  int    `json:"plant_crop_id"`
-			SellCropIDs  string `json:"sell_crop_ids"`
-			StealCropIDs string `json:"steal_crop_ids"`
+			PlantCropID      int    `json:"plant_crop_id"`
+			SellCropIDs      string `json:"sell_crop_ids"`
+			StealCropIDs     string `json:"steal_crop_ids"`
+			NoHarvestCropIDs string `json:"no_harvest_crop_ids"`
 			// Fertilizer
 			AutoUseFertilizer       bool `json:"auto_use_fertilizer"`
 			AutoBuyFertilizer       bool `json:"auto_buy_fertilizer"`
@@ -95,19 +166,54 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 			FertilizerBuyDailyLimit int  `json:"fertilizer_buy_daily_limit"`
 			// Anti-detection
 			EnableAntiDetection bool `json:"enable_anti_detection"`
+			IntervalJitterPct   *int `json:"interval_jitter_pct"`
 			// Planting preference
 			PreferBagSeeds bool `json:"prefer_bag_seeds"`
 			EnableDebugLog bool `json:"enable_debug_log"`
+			// Log storage
+			LogLevel        string `json:"log_level"`
+			LogTagBlacklist string `json:"log_tag_blacklist"`
+			// Safety: extra denied RPC patterns
+			RPCDeny string `json:"rpc_deny"`
+			// Grouping
+			Tags      string `json:"tags"`
+			Notes     string `json:"notes"`
+			SortOrder int    `json:"sort_order"`
+			// Gateway override (testing against staging gates), admins only
+			ServerURLOverride string `json:"server_url_override"`
+			// Notification webhook override
+			NotifyWebhookURL string `json:"notify_webhook_url"`
 			// External API
 			APIKey string `json:"api_key"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
 			return
 		}
 		if req.Platform == "" {
 			req.Platform = "qq"
 		}
+		tags, err := model.NormalizeTags(req.Tags)
+		if err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return
+		}
+		// The bot would send its login handshake to any host, so only admins
+		// may point it elsewhere
+		if !c.GetBool("isAdmin") {
+			req.ServerURLOverride = ""
+		}
+		if !validServerURL(req.ServerURLOverride) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "server_url_override must start with ws:// or wss://")
+			return
+		}
+		if !validWebhookURL(req.NotifyWebhookURL) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "notify_webhook_url must start with http:// or https://")
+			return
+		}
+		if !c.GetBool("isAdmin") && !checkAccountQuota(c, s, cfg, userID) {
+			return
+		}
 		if req.FarmInterval == 0 {
 			req.FarmInterval = 10
 		}
@@ -116,15 +222,27 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 		}
 
 		account := &model.Account{
-			UserID:         userID,
-			Name:           req.Name,
-			Platform:       req.Platform,
-			Code:           req.Code,
-			AutoStart:      req.AutoStart,
-			FarmInterval:   req.FarmInterval,
-			FriendInterval: req.FriendInterval,
-			EnableSteal:    ptrBoolDefault(req.EnableSteal, true),
-			ForceLowest:    req.ForceLowest,
+			UserID:                    userID,
+			Name:                      req.Name,
+			Platform:                  req.Platform,
+			Code:                      req.Code,
+			AutoStart:                 req.AutoStart,
+			FarmInterval:              req.FarmInterval,
+			FriendInterval:            req.FriendInterval,
+			FriendListRefreshInterval: ptrIntDefault(req.FriendListRefreshInterval, model.DefaultFriendListRefreshSec),
+			WarehouseInterval:         ptrIntDefault(req.WarehouseInterval, model.DefaultWarehouseIntervalSec),
+			EnableSteal:               ptrBoolDefault(req.EnableSteal, true),
+			FamilyID:                  req.FamilyID,
+			ForceLowest:               req.ForceLowest,
+			// ForceLowest floors
+			ForceLowestMinExp:   req.ForceLowestMinExp,
+			ForceLowestMaxPrice: req.ForceLowestMaxPrice,
+			ReplantOnLevelUp:    req.ReplantOnLevelUp,
+			ReplantMarginPct:    ptrIntDefault(req.ReplantMarginPct, model.DefaultReplantMarginPct),
+			WarmUpLevel:         req.WarmUpLevel,
+			GoldReserve:         req.GoldReserve,
+			// Maturity heads-up
+			NotifyBeforeMatureMinutes: req.NotifyBeforeMatureMinutes,
 			// Default all automation toggles to true
 			EnableHarvest:           ptrBoolDefault(req.EnableHarvest, true),
 			EnablePlant:             ptrBoolDefault(req.EnablePlant, true),
@@ -139,47 +257,65 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 			PlantCropID:             req.PlantCropID,
 			SellCropIDs:             req.SellCropIDs,
 			StealCropIDs:            req.StealCropIDs,
+			NoHarvestCropIDs:        req.NoHarvestCropIDs,
 			AutoUseFertilizer:       req.AutoUseFertilizer,
 			AutoBuyFertilizer:       req.AutoBuyFertilizer,
 			FertilizerTargetCount:   req.FertilizerTargetCount,
 			FertilizerBuyDailyLimit: req.FertilizerBuyDailyLimit,
 			EnableAntiDetection:     req.EnableAntiDetection,
+			IntervalJitterPct:       ptrIntDefault(req.IntervalJitterPct, model.DefaultIntervalJitterPct),
 			PreferBagSeeds:          req.PreferBagSeeds,
 			EnableDebugLog:          req.EnableDebugLog,
+			LogLevel:                req.LogLevel,
+			LogTagBlacklist:         strings.Join(model.ParseTags(req.LogTagBlacklist), ","),
+			RPCDeny:                 strings.Join(model.ParseRPCPatterns(req.RPCDeny), ","),
+			Tags:                    tags,
+			ServerURLOverride:       req.ServerURLOverride,
+			NotifyWebhookURL:        req.NotifyWebhookURL,
 			APIKey:                  req.APIKey,
+			Notes:                   req.Notes,
+			SortOrder:               req.SortOrder,
 		}
-		if err := s.CreateAccount(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !validateAccount(c, account) {
 			return
 		}
-		c.JSON(http.StatusCreated, account)
-	})
-
-	r.PUT("/accounts/:id", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
-
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
-		account, err := s.GetAccount(id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		if err := s.CreateAccount(c.Request.Context(), account); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
+		auth.RecordAudit(c, s, model.AuditAccountCreate, account.ID, "name="+account.Name)
+		c.JSON(http.StatusCreated, struct {
+			*model.Account
+			Warnings []string `json:"warnings,omitempty"`
+		}{account, duplicateCodeWarnings(c, s, account)})
+	})
 
-		if !isAdmin && account.UserID != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-			return
-		}
+	r.PUT("/accounts/:id", owned, func(c *gin.Context) {
+		account := contextAccount(c)
+		id := account.ID
 
 		var req struct {
-			Name           *string `json:"name"`
-			Platform       *string `json:"platform"`
-			Code           *string `json:"code"`
-			AutoStart      *bool   `json:"auto_start"`
-			FarmInterval   *int    `json:"farm_interval"`
-			FriendInterval *int    `json:"friend_interval"`
-			EnableSteal    *bool   `json:"enable_steal"`
-			ForceLowest    *bool   `json:"force_lowest"`
+			Name                      *string `json:"name"`
+			Platform                  *string `json:"platform"`
+			Code                      *string `json:"code"`
+			AutoStart                 *bool   `json:"auto_start"`
+			FarmInterval              *int    `json:"farm_interval"`
+			FriendInterval            *int    `json:"friend_interval"`
+			FriendListRefreshInterval *int    `json:"friend_list_refresh_interval"`
+			WarehouseInterval         *int    `json:"warehouse_interval"`
+			EnableSteal               *bool   `json:"enable_steal"`
+			FamilyID                  *int64  `json:"family_id"`
+			ForceLowest               *bool   `json:"force_lowest"`
+			// ForceLowest floors (0 = no limit)
+			ForceLowestMinExp   *int `json:"force_lowest_min_exp"`
+			ForceLowestMaxPrice *int `json:"force_lowest_max_price"`
+			// Level-up replant
+			ReplantOnLevelUp *bool  `json:"replant_on_level_up"`
+			ReplantMarginPct *int   `json:"replant_margin_pct"`
+			WarmUpLevel      *int   `json:"warm_up_level"`
+			GoldReserve      *int64 `json:"gold_reserve"`
+			// Maturity heads-up
+			NotifyBeforeMatureMinutes *int `json:"notify_before_mature_minutes"`
 			// Farm automation toggles
 			EnableHarvest     *bool `json:"enable_harvest"`
 			EnablePlant       *bool `json:"enable_plant"`
@@ -192,9 +328,10 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 			EnableHelpFriend  *bool `json:"enable_help_friend"`
 			EnableClaimTask   *bool `json:"enable_claim_task"`
 			// Crop selection
-			PlantCropID  *int    `json:"plant_crop_id"`
-			SellCropIDs  *string `json:"sell_crop_ids"`
-			StealCropIDs *string `json:"steal_crop_ids"`
+			PlantCropID      *int    `json:"plant_crop_id"`
+			SellCropIDs      *string `json:"sell_crop_ids"`
+			StealCropIDs     *string `json:"steal_crop_ids"`
+			NoHarvestCropIDs *string `json:"no_harvest_crop_ids"`
 			// Fertilizer
 			AutoUseFertilizer       *bool `json:"auto_use_fertilizer"`
 			AutoBuyFertilizer       *bool `json:"auto_buy_fertilizer"`
@@ -202,16 +339,30 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 			FertilizerBuyDailyLimit *int  `json:"fertilizer_buy_daily_limit"`
 			// Anti-detection
 			EnableAntiDetection *bool `json:"enable_anti_detection"`
+			IntervalJitterPct   *int  `json:"interval_jitter_pct"`
 			// Planting preference
 			PreferBagSeeds *bool `json:"prefer_bag_seeds"`
 			EnableDebugLog *bool `json:"enable_debug_log"`
+			// Log storage
+			LogLevel        *string `json:"log_level"`
+			LogTagBlacklist *string `json:"log_tag_blacklist"`
+			// Safety: extra denied RPC patterns
+			RPCDeny *string `json:"rpc_deny"`
 			// Planting strategy (JSON-encoded composable rules)
 			PlantingStrategy *string `json:"planting_strategy"`
+			// Grouping
+			Tags      *string `json:"tags"`
+			Notes     *string `json:"notes"`
+			SortOrder *int    `json:"sort_order"`
+			// Gateway override (testing against staging gates)
+			ServerURLOverride *string `json:"server_url_override"`
+			// Notification webhook override
+			NotifyWebhookURL *string `json:"notify_webhook_url"`
 			// External API
 			APIKey *string `json:"api_key"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
 			return
 		}
 
@@ -221,9 +372,14 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 		if req.Platform != nil {
 			account.Platform = *req.Platform
 		}
-		if req.Code != nil {
-			account.Code = *req.Code
+		// The login code changes only through POST /accounts/:id/code. Clients
+		// that PUT back a listed account send the masked or unchanged code,
+		// which is ignored; anything else is rejected rather than saved.
+		if req.Code != nil && *req.Code != account.Code && *req.Code != maskCode(account.Code) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "use POST /accounts/:id/code to change the login code")
+			return
 		}
+		req.Code = nil
 		if req.AutoStart != nil {
 			account.AutoStart = *req.AutoStart
 		}
@@ -233,12 +389,42 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 		if req.FriendInterval != nil {
 			account.FriendInterval = *req.FriendInterval
 		}
+		if req.FriendListRefreshInterval != nil {
+			account.FriendListRefreshInterval = *req.FriendListRefreshInterval
+		}
+		if req.WarehouseInterval != nil {
+			account.WarehouseInterval = *req.WarehouseInterval
+		}
 		if req.EnableSteal != nil {
 			account.EnableSteal = *req.EnableSteal
 		}
+		if req.FamilyID != nil {
+			account.FamilyID = *req.FamilyID
+		}
 		if req.ForceLowest != nil {
 			account.ForceLowest = *req.ForceLowest
 		}
+		if req.ForceLowestMinExp != nil {
+			account.ForceLowestMinExp = *req.ForceLowestMinExp
+		}
+		if req.ForceLowestMaxPrice != nil {
+			account.ForceLowestMaxPrice = *req.ForceLowestMaxPrice
+		}
+		if req.ReplantOnLevelUp != nil {
+			account.ReplantOnLevelUp = *req.ReplantOnLevelUp
+		}
+		if req.ReplantMarginPct != nil {
+			account.ReplantMarginPct = *req.ReplantMarginPct
+		}
+		if req.WarmUpLevel != nil {
+			account.WarmUpLevel = *req.WarmUpLevel
+		}
+		if req.GoldReserve != nil {
+			account.GoldReserve = *req.GoldReserve
+		}
+		if req.NotifyBeforeMatureMinutes != nil {
+			account.NotifyBeforeMatureMinutes = *req.NotifyBeforeMatureMinutes
+		}
 		if req.EnableHarvest != nil {
 			account.EnableHarvest = *req.EnableHarvest
 		}
@@ -278,6 +464,9 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 		if req.StealCropIDs != nil {
 			account.StealCropIDs = *req.StealCropIDs
 		}
+		if req.NoHarvestCropIDs != nil {
+			account.NoHarvestCropIDs = *req.NoHarvestCropIDs
+		}
 		if req.AutoUseFertilizer != nil {
 			account.AutoUseFertilizer = *req.AutoUseFertilizer
 		}
@@ -293,68 +482,495 @@ func RegisterAccountRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager,
 		if req.EnableAntiDetection != nil {
 			account.EnableAntiDetection = *req.EnableAntiDetection
 		}
+		if req.IntervalJitterPct != nil {
+			account.IntervalJitterPct = *req.IntervalJitterPct
+		}
 		if req.PreferBagSeeds != nil {
 			account.PreferBagSeeds = *req.PreferBagSeeds
 		}
 		if req.EnableDebugLog != nil {
 			account.EnableDebugLog = *req.EnableDebugLog
 		}
+		if req.LogLevel != nil {
+			account.LogLevel = *req.LogLevel
+		}
+		if req.LogTagBlacklist != nil {
+			account.LogTagBlacklist = strings.Join(model.ParseTags(*req.LogTagBlacklist), ",")
+		}
+		if req.RPCDeny != nil {
+			account.RPCDeny = strings.Join(model.ParseRPCPatterns(*req.RPCDeny), ",")
+		}
 		if req.PlantingStrategy != nil {
 			account.PlantingStrategy = *req.PlantingStrategy
 		}
+		if req.Tags != nil {
+			tags, err := model.NormalizeTags(*req.Tags)
+			if err != nil {
+				apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+				return
+			}
+			account.Tags = tags
+		}
+		if req.ServerURLOverride != nil && c.GetBool("isAdmin") {
+			if !validServerURL(*req.ServerURLOverride) {
+				apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "server_url_override must start with ws:// or wss://")
+				return
+			}
+			account.ServerURLOverride = *req.ServerURLOverride
+		}
+		if req.NotifyWebhookURL != nil {
+			if !validWebhookURL(*req.NotifyWebhookURL) {
+				apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "notify_webhook_url must start with http:// or https://")
+				return
+			}
+			account.NotifyWebhookURL = *req.NotifyWebhookURL
+		}
 		if req.APIKey != nil {
 			account.APIKey = *req.APIKey
 		}
+		if req.Notes != nil {
+			account.Notes = *req.Notes
+		}
+		if req.SortOrder != nil {
+			account.SortOrder = *req.SortOrder
+		}
+		if !validateAccount(c, account) {
+			return
+		}
 
-		if err := s.UpdateAccount(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := s.UpdateAccount(c.Request.Context(), account); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
 		// Hot-reload: apply config to running bot instance (if any)
 		mgr.UpdateBotConfig(id, account)
-		c.JSON(http.StatusOK, account)
+		auth.RecordAudit(c, s, model.AuditAccountUpdate, id, "fields="+changedFields(&req))
+		c.JSON(http.StatusOK, struct {
+			*model.Account
+			Warnings []string `json:"warnings,omitempty"`
+		}{account, cropIDWarnings(account)})
+	})
+
+	// PUT /accounts/reorder {"ids":[3,1,2]} - the caller's own accounts in
+	// display order; unlisted accounts follow in their previous order
+	r.PUT("/accounts/reorder", func(c *gin.Context) {
+		var req struct {
+			IDs []int64 `json:"ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return
+		}
+		err := s.ReorderAccounts(c.Request.Context(), c.GetInt64("userID"), req.IDs)
+		if errors.Is(err, store.ErrForeignAccount) {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, err.Error())
+			return
+		}
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "reordered"})
+	})
+
+	r.DELETE("/accounts/:id", owned, func(c *gin.Context) {
+		id := contextAccount(c).ID
+
+		mgr.StopBot(id, apierr.RequestID(c))
+		if err := s.DeleteAccount(c.Request.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
+				return
+			}
+			apierr.AbortInternal(c, err)
+			return
+		}
+		auth.RecordAudit(c, s, model.AuditAccountDelete, id, "")
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	// Login code: set by manual paste (the QR flow saves it server side)
+	r.POST("/accounts/:id/code", owned, func(c *gin.Context) {
+		account := contextAccount(c)
+		id := account.ID
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return
+		}
+		req.Code = strings.TrimSpace(req.Code)
+		if strings.HasSuffix(req.Code, maskSuffix) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "code looks masked, paste the full login code")
+			return
+		}
+
+		account.Code = req.Code
+		if err := s.UpdateAccount(c.Request.Context(), account); err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		mgr.UpdateBotConfig(id, account)
+		auth.RecordAudit(c, s, model.AuditAccountCode, id, "")
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "code updated",
+			"has_code": account.Code != "",
+			"warnings": duplicateCodeWarnings(c, s, account),
+		})
+	})
+
+	// Clone: copy every setting of an account into a new one without a code
+	r.POST("/accounts/:id/clone", owned, func(c *gin.Context) {
+		source := contextAccount(c)
+		id := source.ID
+		if !c.GetBool("isAdmin") && !checkAccountQuota(c, s, cfg, source.UserID) {
+			return
+		}
+
+		account := source.CloneSettings()
+		if err := s.CreateAccount(c.Request.Context(), account); err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		auth.RecordAudit(c, s, model.AuditAccountCreate, account.ID, fmt.Sprintf("name=%s clone_of=%d", account.Name, id))
+		c.JSON(http.StatusCreated, account)
 	})
 
-	r.DELETE("/accounts/:id", func(c *gin.Context) {
+	// Recycle bin: deleted accounts of the user (admins see all)
+	r.GET("/accounts/trash", func(c *gin.Context) {
+		userID := c.GetInt64("userID")
+		if c.GetBool("isAdmin") {
+			userID = 0
+		}
+		accounts, err := s.ListDeletedAccounts(c.Request.Context(), userID)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, accounts)
+	})
+
+	r.POST("/accounts/:id/restore", func(c *gin.Context) {
 		userID := c.GetInt64("userID")
 		isAdmin := c.GetBool("isAdmin")
 
 		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+		if !inTokenScope(c, id) {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "token is limited to another account")
+			return
+		}
+		account, err := s.GetDeletedAccount(c.Request.Context(), id)
+		if err != nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found in trash")
+			return
+		}
+		if !isAdmin && account.UserID != userID {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+			return
+		}
 
-		if !isAdmin {
-			account, err := s.GetAccount(id)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
-				return
+		if err := s.RestoreAccount(c.Request.Context(), id); err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		auth.RecordAudit(c, s, model.AuditAccountRestore, id, "")
+		account.DeletedAt = nil
+		c.JSON(http.StatusOK, account)
+	})
+
+	// Crops list endpoint for frontend dropdown
+	r.GET("/crops", func(c *gin.Context) {
+		gc := bot.GetGameConfig()
+		if gc == nil {
+			c.JSON(http.StatusOK, []interface{}{})
+			return
+		}
+		crops := gc.GetCropList()
+		// Optional ?level= keeps only crops plantable at that level
+		if level, _ := strconv.Atoi(c.Query("level")); level > 0 {
+			filtered := crops[:0]
+			for _, crop := range crops {
+				if crop.RequiredLevel <= level {
+					filtered = append(filtered, crop)
+				}
 			}
-			if account.UserID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
+			crops = filtered
+		}
+		c.JSON(http.StatusOK, crops)
+	})
+
+	// GET /crops/resolve?ids=1020002,40002 - how each entry of a crop ID list
+	// is interpreted (plant, fruit or unknown)
+	r.GET("/crops/resolve", func(c *gin.Context) {
+		ids := c.Query("ids")
+		if err := model.CheckCropIDs(ids); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return
+		}
+		gc := bot.GetGameConfig()
+		resolved := []bot.CropIDInfo{}
+		for _, part := range strings.Split(ids, ",") {
+			if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+				resolved = append(resolved, gc.ResolveCropID(id))
 			}
 		}
+		c.JSON(http.StatusOK, resolved)
+	})
 
-		mgr.StopBot(id)
-		if err := s.DeleteAccount(id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Growth phases of a crop for the fertilizer planner
+	r.GET("/crops/:plantId/phases", func(c *gin.Context) {
+		plantID, _ := strconv.Atoi(c.Param("plantId"))
+		gc := bot.GetGameConfig()
+		pd := gc.GetPlantPhaseData(plantID)
+		if pd == nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "crop not found")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+
+		resp := gin.H{
+			"plant_id": plantID,
+			"name":     gc.GetPlantName(plantID),
+			"seasons":  gc.GetPlantSeasons(plantID),
+			"season1": newCropPhases(pd.PhaseNames, pd.PhaseDurations, pd.MaxPhaseIndex,
+				pd.TotalGrowTime, pd.MaxPhaseDuration, pd.AllPhasesEqual),
+		}
+		if len(pd.Season2Phases) > 0 {
+			resp["season2"] = newCropPhases(pd.Season2PhaseNames, pd.Season2Phases, pd.Season2MaxPhaseIndex,
+				pd.Season2GrowTime, pd.Season2MaxPhase, pd.Season2AllEqual)
+		}
+		c.JSON(http.StatusOK, resp)
 	})
 
-	// Crops list endpoint for frontend dropdown
-	r.GET("/crops", func(c *gin.Context) {
+	// Crop yield table computed from game config (replaces the generated cropYield.ts)
+	r.GET("/crops/yield", func(c *gin.Context) {
+		lands, _ := strconv.Atoi(c.DefaultQuery("lands", "18"))
+		level, _ := strconv.Atoi(c.DefaultQuery("level", "0"))
+		strategy := c.DefaultQuery("strategy", "exp")
+		if lands < 1 || lands > 24 {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "lands must be between 1 and 24")
+			return
+		}
+		if strategy != "exp" && strategy != "time" {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "strategy must be exp or time")
+			return
+		}
+
 		gc := bot.GetGameConfig()
 		if gc == nil {
 			c.JSON(http.StatusOK, []interface{}{})
 			return
 		}
-		c.JSON(http.StatusOK, gc.GetCropList())
+		rows := gc.GetSeedYieldTable(lands)
+		if level > 0 {
+			filtered := rows[:0]
+			for _, r := range rows {
+				if r.RequiredLevel <= level {
+					filtered = append(filtered, r)
+				}
+			}
+			rows = filtered
+		}
+		if strategy == "time" {
+			sort.SliceStable(rows, func(i, j int) bool {
+				return rows[i].GrowTimeNormalFert < rows[j].GrowTimeNormalFert
+			})
+		}
+
+		result := make([]cropYieldRow, 0, len(rows))
+		for i, r := range rows {
+			result = append(result, cropYieldRow{
+				Rank:         i + 1,
+				SeedYieldRow: r,
+				GrowTime:     gc.FormatGrowTime(r.GrowTimeSec + r.Season2GrowTimeSec),
+				GrowTimeFert: gc.FormatGrowTime(r.GrowTimeNormalFert),
+			})
+		}
+		c.JSON(http.StatusOK, result)
 	})
 }
 
+// cropPhases is the fertilizer planner view of one season's growth phases.
+type cropPhases struct {
+	Phases        []bot.GrowPhase `json:"phases"`
+	MaxPhaseIndex int             `json:"max_phase_index"` // phase to fertilize for the biggest saving
+	TotalGrowSec  int             `json:"total_grow_sec"`
+	FertGrowSec   int             `json:"fert_grow_sec"` // total after fertilizing the longest phase
+	AllEqual      bool            `json:"all_equal"`
+}
+
+func newCropPhases(names []string, durations []int, maxIndex, total, maxPhase int, allEqual bool) *cropPhases {
+	cp := &cropPhases{
+		Phases:        make([]bot.GrowPhase, len(durations)),
+		MaxPhaseIndex: maxIndex,
+		TotalGrowSec:  total,
+		FertGrowSec:   max(total-maxPhase, 1),
+		AllEqual:      allEqual,
+	}
+	for i, d := range durations {
+		cp.Phases[i].Seconds = d
+		if i < len(names) {
+			cp.Phases[i].Name = names[i]
+		}
+	}
+	return cp
+}
+
+// cropYieldRow is a ranked yield row with human-readable grow times.
+type cropYieldRow struct {
+	Rank int `json:"rank"`
+	bot.SeedYieldRow
+	GrowTime     string `json:"grow_time"`
+	GrowTimeFert string `json:"grow_time_fert"`
+}
+
+// validateAccount responds 422 with the invalid fields when a fails
+// model.Account.Validate, and reports whether the handler may continue.
+func validateAccount(c *gin.Context, a *model.Account) bool {
+	errs := a.Validate()
+	if len(errs) == 0 {
+		return true
+	}
+	apierr.AbortDetails(c, http.StatusUnprocessableEntity, apierr.ValidationFailed, errs.Error(), errs)
+	return false
+}
+
+// cropIDWarnings lists crop ID list entries that match no plant or fruit,
+// which would make the filter silently match nothing. Fruit IDs are accepted
+// since the workers map them to their plant.
+func cropIDWarnings(a *model.Account) []string {
+	gc := bot.GetGameConfig()
+	var warnings []string
+	for _, list := range []struct{ field, ids string }{
+		{"sell_crop_ids", a.SellCropIDs},
+		{"steal_crop_ids", a.StealCropIDs},
+		{"no_harvest_crop_ids", a.NoHarvestCropIDs},
+	} {
+		for _, id := range gc.UnknownCropIDs(list.ids) {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown crop id %d", list.field, id))
+		}
+	}
+	return warnings
+}
+
+// duplicateCodeWarnings lists other accounts saved with the same login code.
+// Two bots can't share a session, so only one of them could be started.
+func duplicateCodeWarnings(c *gin.Context, s store.Store, a *model.Account) []string {
+	if a.Code == "" {
+		return nil
+	}
+	others, err := s.ListAccountsByCode(c.Request.Context(), a.Code)
+	if err != nil {
+		return nil
+	}
+	var warnings []string
+	for _, o := range others {
+		if o.ID != a.ID {
+			warnings = append(warnings, fmt.Sprintf("code: also used by account #%d (%s)", o.ID, o.Name))
+		}
+	}
+	return warnings
+}
+
+// validServerURL accepts an empty override or a WebSocket URL.
+func validServerURL(u string) bool {
+	return u == "" || strings.HasPrefix(u, "ws://") || strings.HasPrefix(u, "wss://")
+}
+
+// validWebhookURL accepts an empty override or an HTTP(S) URL.
+func validWebhookURL(u string) bool {
+	return u == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
 func ptrBoolDefault(p *bool, defaultVal bool) bool {
 	if p == nil {
 		return defaultVal
 	}
 	return *p
 }
+
+func ptrIntDefault(p *int, defaultVal int) int {
+	if p == nil {
+		return defaultVal
+	}
+	return *p
+}
+
+// maxAccountPage caps the limit of GET /accounts.
+const maxAccountPage = 500
+
+// botStatusName is the account list status of a bot: running, error or stopped.
+func botStatusName(bs *model.BotStatus) string {
+	switch {
+	case bs.Running:
+		return "running"
+	case bs.Error != "":
+		return "error"
+	default:
+		return "stopped"
+	}
+}
+
+// filterAccountsByStatus keeps only accounts whose bot is in the given status.
+func filterAccountsByStatus(accounts []model.Account, mgr *bot.Manager, status string) []model.Account {
+	var out []model.Account
+	for _, a := range accounts {
+		if botStatusName(mgr.GetStatus(a.ID)) == status {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// pageAccounts returns accounts[offset:offset+limit]; limit 0 means no limit.
+func pageAccounts(accounts []model.Account, limit, offset int) []model.Account {
+	if offset >= len(accounts) {
+		return nil
+	}
+	accounts = accounts[offset:]
+	if limit > 0 && limit < len(accounts) {
+		accounts = accounts[:limit]
+	}
+	return accounts
+}
+
+// filterAccountsByTag keeps only accounts carrying the given tag.
+func filterAccountsByTag(accounts []model.Account, tag string) []model.Account {
+	var result []model.Account
+	for _, a := range accounts {
+		if model.HasTag(a.Tags, tag) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// checkAccountQuota responds 403 and returns false when the user already owns
+// max_accounts_per_user accounts.
+func checkAccountQuota(c *gin.Context, s store.Store, cfg *config.Config, userID int64) bool {
+	if cfg.MaxAccountsPerUser <= 0 {
+		return true
+	}
+	owned, err := s.ListAccountsByUserID(c.Request.Context(), userID)
+	if err != nil {
+		apierr.AbortInternal(c, err)
+		return false
+	}
+	if len(owned) >= cfg.MaxAccountsPerUser {
+		apierr.Abort(c, http.StatusForbidden, apierr.QuotaExceeded, fmt.Sprintf("account quota reached (%d)", cfg.MaxAccountsPerUser))
+		return false
+	}
+	return true
+}
+
+// maskSuffix marks a login code shortened for display.
+const maskSuffix = "..."
+
+// maskCode shortens a login code for listings.
+func maskCode(code string) string {
+	if len(code) > 8 {
+		return code[:8] + maskSuffix
+	}
+	return code
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"qq-farm-bot/internal/model"
+)
+
+func TestAccountCodeRoundTrip(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.user("alice", false)
+	// Created through the API so the settings pass PUT validation
+	w := ts.do(http.MethodPost, "/api/accounts", token, `{"name":"farm","platform":"qq"}`)
+	var a model.Account
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create account = %d: %s", w.Code, w.Body)
+	}
+	const code = "0123456789abcdef"
+
+	storedCode := func() string {
+		t.Helper()
+		got, err := ts.s.GetAccount(context.Background(), a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Code
+	}
+
+	w = ts.do(http.MethodPost, accountPath(a.ID, "/code"), token, `{"code":" `+code+` "}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set code = %d: %s", w.Code, w.Body)
+	}
+	var set struct {
+		HasCode bool `json:"has_code"`
+	}
+	if json.Unmarshal(w.Body.Bytes(), &set); !set.HasCode || storedCode() != code {
+		t.Fatalf("after POST /code: has_code %v, stored %q", set.HasCode, storedCode())
+	}
+
+	// The list masks the code
+	w = ts.do(http.MethodGet, "/api/accounts", token, "")
+	var list []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("list = %d: %s", w.Code, w.Body)
+	}
+	listed := list[0]
+	if listed["code"] != maskCode(code) || listed["has_code"] != true {
+		t.Fatalf("listed code %v, has_code %v", listed["code"], listed["has_code"])
+	}
+
+	// A form that PUTs the listed object back keeps the real code
+	listed["name"] = "renamed"
+	body, _ := json.Marshal(listed)
+	if w := ts.do(http.MethodPut, accountPath(a.ID, ""), token, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("PUT listed account = %d: %s", w.Code, w.Body)
+	}
+	got, err := ts.s.GetAccount(context.Background(), a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != code || got.Name != "renamed" {
+		t.Fatalf("after PUT: code %q, name %q; want the code unchanged", got.Code, got.Name)
+	}
+
+	// Sending the real code back is fine too; a new one needs POST /code
+	if w := ts.do(http.MethodPut, accountPath(a.ID, ""), token, `{"code":"`+code+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT unchanged code = %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodPut, accountPath(a.ID, ""), token, `{"code":"fedcba9876543210"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT new code = %d, want 400", w.Code)
+	}
+	if w := ts.do(http.MethodPost, accountPath(a.ID, "/code"), token, `{"code":"`+maskCode(code)+`"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("POST masked code = %d, want 400", w.Code)
+	}
+	if storedCode() != code {
+		t.Fatalf("stored code = %q, want %q", storedCode(), code)
+	}
+
+	// An empty code clears it
+	w = ts.do(http.MethodPost, accountPath(a.ID, "/code"), token, `{"code":""}`)
+	if json.Unmarshal(w.Body.Bytes(), &set); w.Code != http.StatusOK || set.HasCode || storedCode() != "" {
+		t.Fatalf("clear code = %d %s, stored %q", w.Code, w.Body, storedCode())
+	}
+}
+
+func TestAccountsListFilters(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.user("alice", false)
+	bob, bobToken := ts.user("bob", false)
+	_, adminToken := ts.user("root", true)
+	for _, a := range []model.Account{
+		{UserID: alice.ID, Name: "Farm-A1", Platform: "qq", Tags: "main"},
+		{UserID: alice.ID, Name: "farm-a2", Platform: "wx"},
+		{UserID: alice.ID, Name: "alt-a3", Platform: "qq", Tags: "main,alt"},
+		{UserID: bob.ID, Name: "farm-b1", Platform: "qq", Tags: "main"},
+		{UserID: bob.ID, Name: "farm-b2", Platform: "wx"},
+	} {
+		if err := ts.s.CreateAccount(context.Background(), &a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owners := map[string]int64{aliceToken: alice.ID, bobToken: bob.ID}
+	cases := []struct {
+		name  string
+		token string
+		query string
+		want  string // listed names in order
+		total string // X-Total-Count
+	}{
+		{"own accounts", aliceToken, "", "Farm-A1 farm-a2 alt-a3", "3"},
+		{"search is case-insensitive", aliceToken, "q=FARM", "Farm-A1 farm-a2", "2"},
+		{"search misses other users", aliceToken, "q=b1", "", "0"},
+		{"platform", aliceToken, "platform=wx", "farm-a2", "1"},
+		{"tag", aliceToken, "tag=main", "Farm-A1 alt-a3", "2"},
+		{"first page", aliceToken, "limit=2", "Farm-A1 farm-a2", "3"},
+		{"second page", aliceToken, "limit=2&offset=2", "alt-a3", "3"},
+		{"past the end", aliceToken, "limit=2&offset=9", "", "3"},
+		{"negative paging is ignored", aliceToken, "limit=-1&offset=-1", "Farm-A1 farm-a2 alt-a3", "3"},
+		{"status pages after filtering", aliceToken, "status=stopped&limit=1&offset=1", "farm-a2", "3"},
+		{"status with search", aliceToken, "status=stopped&q=farm-b", "", "0"},
+		{"nothing running", aliceToken, "status=running", "", "0"},
+		{"other user", bobToken, "q=a", "farm-b1 farm-b2", "2"},
+		{"admin sees everyone", adminToken, "", "Farm-A1 farm-a2 alt-a3 farm-b1 farm-b2", "5"},
+		{"admin search and platform", adminToken, "q=farm&platform=wx", "farm-a2 farm-b2", "2"},
+		{"admin page", adminToken, "limit=2&offset=3", "farm-b1 farm-b2", "5"},
+		{"admin status and tag", adminToken, "status=stopped&tag=main", "Farm-A1 alt-a3 farm-b1", "3"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, "/api/accounts?"+tc.query, tc.token, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var list []model.Account
+			if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, a := range list {
+				names = append(names, a.Name)
+				if owner, ok := owners[tc.token]; ok && a.UserID != owner {
+					t.Errorf("non-admin listed %s of user %d", a.Name, a.UserID)
+				}
+			}
+			if got := strings.Join(names, " "); got != tc.want {
+				t.Errorf("listed %q, want %q", got, tc.want)
+			}
+			if got := w.Header().Get("X-Total-Count"); got != tc.total {
+				t.Errorf("X-Total-Count = %s, want %s", got, tc.total)
+			}
+		})
+	}
+
+	if w := ts.do(http.MethodGet, "/api/accounts?status=paused", aliceToken, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown status = %d, want 400", w.Code)
+	}
+}
+
+func TestServerURLOverrideAdminOnly(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.user("alice", false)
+	_, adminToken := ts.user("root", true)
+	const staging = "wss://staging.example/ws"
+
+	w := ts.do(http.MethodPost, "/api/accounts", token, `{"name":"farm","server_url_override":"`+staging+`"}`)
+	var a model.Account
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create account = %d: %s", w.Code, w.Body)
+	}
+	override := func() string {
+		t.Helper()
+		got, err := ts.s.GetAccount(context.Background(), a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.ServerURLOverride
+	}
+	if got := override(); got != "" {
+		t.Fatalf("user created an account with override %q", got)
+	}
+
+	if w := ts.do(http.MethodPut, accountPath(a.ID, ""), adminToken, `{"server_url_override":"`+staging+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("admin PUT = %d: %s", w.Code, w.Body)
+	}
+	if got := override(); got != staging {
+		t.Fatalf("override after admin PUT = %q, want %q", got, staging)
+	}
+
+	// The owner's own PUT leaves the admin's override alone
+	if w := ts.do(http.MethodPut, accountPath(a.ID, ""), token, `{"server_url_override":"ws://127.0.0.1:1/ws"}`); w.Code != http.StatusOK {
+		t.Fatalf("user PUT = %d: %s", w.Code, w.Body)
+	}
+	if got := override(); got != staging {
+		t.Fatalf("override after user PUT = %q, want %q", got, staging)
+	}
+}
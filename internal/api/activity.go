@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+func RegisterActivityRoutes(r *gin.RouterGroup, s store.Store) {
+	// GET /api/accounts/:id/activity — notable account events, newest first.
+	// ?type=a,b filters by event type, ?since=/?until= (RFC3339) by time and
+	// ?before_id= pages past the last id of the previous page.
+	r.GET("/accounts/:id/activity", accountOwnership(s), func(c *gin.Context) {
+		f := store.ActivityFilter{AccountID: contextAccount(c).ID}
+		f.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+		f.BeforeID, _ = strconv.ParseInt(c.DefaultQuery("before_id", "0"), 10, 64)
+
+		if types := c.Query("type"); types != "" {
+			for _, t := range strings.Split(types, ",") {
+				t = strings.TrimSpace(t)
+				if !slices.Contains(model.ActivityTypes, t) {
+					apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "unknown activity type: "+t)
+					return
+				}
+				f.Types = append(f.Types, t)
+			}
+		}
+		for _, p := range []struct {
+			name string
+			dst  *time.Time
+		}{{"since", &f.Since}, {"until", &f.Until}} {
+			v := c.Query(p.name)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, p.name+" must be an RFC3339 time")
+				return
+			}
+			*p.dst = t.Local() // stored times are local
+		}
+
+		events, err := s.ListActivity(c.Request.Context(), f)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	})
+}
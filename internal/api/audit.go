@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+func RegisterAuditRoutes(r *gin.RouterGroup, s store.Store) {
+	// GET /audit?user_id=&action=&limit=&offset= - Admin only
+	r.GET("/audit", func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+			return
+		}
+
+		userID, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+		entries, total, err := s.ListAudit(c.Request.Context(), userID, c.Query("action"), limit, offset)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if entries == nil {
+			entries = make([]model.AuditEntry, 0)
+		}
+		c.JSON(http.StatusOK, gin.H{"total": total, "items": entries})
+	})
+}
+
+// changedFields lists the JSON names of the non-nil pointer fields of a
+// partial-update request struct, for audit summaries. Values are omitted
+// so secrets such as login codes never end up in the audit table.
+func changedFields(req interface{}) string {
+	v := reflect.ValueOf(req)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	t := v.Type()
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() != reflect.Ptr || f.IsNil() {
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
@@ -1,154 +1,330 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/auth"
 	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/qrcode"
 	"qq-farm-bot/internal/store"
 )
 
-func RegisterBotRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
-	r.POST("/accounts/:id/start", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
+// RPC trace duration when none is given, and the longest allowed.
+const (
+	defaultTraceMinutes = 10
+	maxTraceMinutes     = 24 * 60
+)
 
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
-		account, err := s.GetAccount(id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
-			return
-		}
+func RegisterBotRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
+	owned := accountOwnership(s)
 
-		// Check ownership (admin can start any)
-		if !isAdmin && account.UserID != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-			return
-		}
+	r.POST("/accounts/:id/start", owned, func(c *gin.Context) {
+		account := contextAccount(c)
+		id := account.ID
 
 		if account.Code == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "account has no login code"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.NoLoginCode, "account has no login code")
 			return
 		}
-		if err := mgr.StartBot(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// A manual start overrides the persisted login backoff
+		var warning string
+		if account.LoginRetryPending(time.Now()) {
+			warning = fmt.Sprintf("account failed to log in %d times (%s), automatic retries wait until %s",
+				account.LoginFailCount, account.LoginFailReason, account.LoginRetryAt.Format(time.RFC3339))
+		}
+		if err := mgr.StartBot(account, apierr.RequestID(c)); err != nil {
+			abortStartError(c, err)
+			return
+		}
+		auth.RecordAudit(c, s, model.AuditBotStart, id, "")
+		if warning != "" {
+			c.JSON(http.StatusOK, gin.H{"message": "started", "warning": warning})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "started"})
 	})
 
-	r.POST("/accounts/:id/stop", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
-
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
-
-		// Check ownership (admin can stop any)
-		if !isAdmin {
-			account, err := s.GetAccount(id)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
-				return
-			}
-			if account.UserID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
-			}
-		}
+	r.POST("/accounts/:id/stop", owned, func(c *gin.Context) {
+		id := contextAccount(c).ID
 
-		if err := mgr.StopBot(id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := mgr.StopBot(id, apierr.RequestID(c)); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
+		auth.RecordAudit(c, s, model.AuditBotStop, id, "")
 		c.JSON(http.StatusOK, gin.H{"message": "stopped"})
 	})
 
-	r.GET("/accounts/:id/status", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
-
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
-
-		// Check ownership (admin can view any)
-		if !isAdmin {
-			account, err := s.GetAccount(id)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
-				return
-			}
-			if account.UserID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
-			}
-		}
-
-		status := mgr.GetStatus(id)
+	r.GET("/accounts/:id/status", owned, func(c *gin.Context) {
+		status := mgr.GetStatus(contextAccount(c).ID)
 		c.JSON(http.StatusOK, status)
 	})
 
-	// QR code login
-	r.POST("/accounts/:id/qrcode", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
+	// Bag contents as of the last poll (at most once a minute while running)
+	r.GET("/accounts/:id/bag", owned, func(c *gin.Context) {
+		inst := mgr.GetInstance(contextAccount(c).ID)
+		if inst == nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "bot has not run since server start")
+			return
+		}
+		bag := inst.Bag()
+		if bag.FetchedAt.IsZero() {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "bag not fetched yet")
+			return
+		}
+		c.JSON(http.StatusOK, bag)
+	})
 
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	// Seed the bot would buy now, the ranked candidates and what ruled out
+	// the rest; falls back to game config data for bots without a shop reply
+	r.GET("/accounts/:id/plant-plan", owned, func(c *gin.Context) {
+		c.JSON(http.StatusOK, mgr.PlantPlan(c.Request.Context(), contextAccount(c)))
+	})
 
-		// Check ownership (admin can access any)
-		if !isAdmin {
-			account, err := s.GetAccount(id)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
-				return
-			}
-			if account.UserID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
-			}
+	// Manual action on one land, run between farm cycles.
+	// Body: {"action":"harvest|water|weed|bug|remove|fertilize"}
+	r.POST("/accounts/:id/lands/:landId/action", owned, func(c *gin.Context) {
+		landID, err := strconv.ParseInt(c.Param("landId"), 10, 64)
+		if err != nil || landID <= 0 {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid land id")
+			return
 		}
-
-		result, err := bot.RequestQRCode()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var req struct {
+			Action string `json:"action"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return
+		}
+		if !bot.IsLandAction(req.Action) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "unknown action: "+req.Action)
+			return
+		}
+		inst := mgr.GetInstance(contextAccount(c).ID)
+		if inst == nil {
+			apierr.Abort(c, http.StatusConflict, apierr.BotNotRunning, bot.ErrNotRunning.Error())
+			return
+		}
+		if err := inst.LandAction(c.Request.Context(), landID, req.Action); err != nil {
+			abortLandActionError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
 	})
 
-	r.GET("/accounts/:id/qrcode/poll", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
+	// RPC trace: logs every request's metadata (never bodies) under the
+	// "RPC" tag for a while, then switches itself off
+	r.POST("/accounts/:id/trace", owned, func(c *gin.Context) {
+		var req struct {
+			Enabled bool `json:"enabled"`
+			Minutes *int `json:"minutes"` // omitted = 10
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return
+		}
+		minutes := ptrIntDefault(req.Minutes, defaultTraceMinutes)
+		if req.Enabled && (minutes < 1 || minutes > maxTraceMinutes) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, fmt.Sprintf("minutes must be between 1 and %d", maxTraceMinutes))
+			return
+		}
+		inst := mgr.GetInstance(contextAccount(c).ID)
+		if inst == nil {
+			apierr.Abort(c, http.StatusConflict, apierr.BotNotRunning, bot.ErrNotRunning.Error())
+			return
+		}
+		var d time.Duration
+		if req.Enabled {
+			d = time.Duration(minutes) * time.Minute
+		}
+		resp := gin.H{"enabled": req.Enabled}
+		if until := inst.SetTrace(d); !until.IsZero() {
+			resp["until"] = until
+		}
+		c.JSON(http.StatusOK, resp)
+	})
 
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	// QR code login: the server polls the scan in the background and saves
+	// the resulting code to the account, so closing the page doesn't lose it
+	r.POST("/accounts/:id/qrcode", owned, func(c *gin.Context) {
+		account := contextAccount(c)
 
-		account, err := s.GetAccount(id)
+		// The scan flow follows the account's platform (QQ or WeChat)
+		session, err := mgr.QRSessions().Start(account, auth.AuditActor(c))
+		if errors.Is(err, bot.ErrTooManyQRSessions) {
+			apierr.Abort(c, http.StatusTooManyRequests, apierr.RateLimited, err.Error())
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			apierr.AbortInternal(c, err)
 			return
 		}
+		c.JSON(http.StatusOK, session)
+	})
 
-		// Check ownership (admin can access any)
-		if !isAdmin && account.UserID != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	r.GET("/qrcode/sessions/:sid", func(c *gin.Context) {
+		session := mgr.QRSessions().Get(c.Param("sid"))
+		if session == nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "session not found")
+			return
+		}
+		if !c.GetBool("isAdmin") && session.UserID() != c.GetInt64("userID") {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
 			return
 		}
+		c.JSON(http.StatusOK, session)
+	})
+
+	// QR code rendered server-side as PNG, for webviews that can't load the
+	// QR page. Only the code of the account's pending scan login is rendered
+	qrImages := newQRImageCache()
+	r.GET("/accounts/:id/qrcode/image", owned, func(c *gin.Context) {
+		account := contextAccount(c)
 
 		loginCode := c.Query("login_code")
 		if loginCode == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing login_code"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "missing login_code")
 			return
 		}
-		status, err := bot.PollQRStatus(loginCode)
-		if err != nil {
-			c.JSON(http.StatusOK, &bot.QRLoginStatus{Status: "error", Message: err.Error()})
+		if session := mgr.QRSessions().Pending(account.ID); session == nil || session.LoginCode != loginCode {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "no pending scan login with this login_code")
 			return
 		}
-		// If login succeeded, save the code to the account
-		if status.Status == "ok" && status.Code != "" {
-			account.Code = status.Code
-			s.UpdateAccount(account)
+		key := account.Platform + ":" + loginCode
+		img, ok := qrImages.get(key)
+		if !ok {
+			var err error
+			img, err = qrcode.PNG(bot.QRCodeURL(account.Platform, loginCode), qrImageScale)
+			if err != nil {
+				apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+				return
+			}
+			qrImages.put(key, img)
 		}
-		c.JSON(http.StatusOK, status)
+		c.Header("Cache-Control", "private, max-age=300")
+		c.Data(http.StatusOK, "image/png", img)
 	})
+
+	// Bulk start/stop. Body is optional: {"ids":[1,2,3]} and/or {"tag":"x"}
+	// (or ?tag=x) limit the operation; admins may pass ?user_id= to target one
+	// user's accounts.
+	r.POST("/accounts/start-all", func(c *gin.Context) {
+		accounts, ok := selectBulkAccounts(c, s)
+		if !ok {
+			return
+		}
+		results := mgr.StartBots(accounts, apierr.RequestID(c))
+		for _, res := range results {
+			if res.Result == "started" {
+				auth.RecordAudit(c, s, model.AuditBotStart, res.AccountID, "bulk")
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	})
+
+	r.POST("/accounts/stop-all", func(c *gin.Context) {
+		accounts, ok := selectBulkAccounts(c, s)
+		if !ok {
+			return
+		}
+		results := mgr.StopBots(accounts, apierr.RequestID(c))
+		for _, res := range results {
+			if res.Result == "stopped" {
+				auth.RecordAudit(c, s, model.AuditBotStop, res.AccountID, "bulk")
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	})
+}
+
+// selectBulkAccounts resolves the accounts targeted by a bulk operation,
+// scoped to the caller. Writes the error response and returns false on failure.
+func selectBulkAccounts(c *gin.Context, s store.Store) ([]model.Account, bool) {
+	userID := c.GetInt64("userID")
+	isAdmin := c.GetBool("isAdmin")
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+		Tag string  `json:"tag"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, err.Error())
+			return nil, false
+		}
+	}
+
+	var accounts []model.Account
+	var err error
+	if isAdmin && c.Query("user_id") != "" {
+		target, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+		accounts, err = s.ListAccountsByUserID(c.Request.Context(), target)
+	} else {
+		accounts, err = listVisibleAccounts(c.Request.Context(), s, userID, isAdmin)
+	}
+	if err != nil {
+		apierr.AbortInternal(c, err)
+		return nil, false
+	}
+
+	if req.Tag == "" {
+		req.Tag = c.Query("tag")
+	}
+	if req.Tag != "" {
+		accounts = filterAccountsByTag(accounts, req.Tag)
+	}
+	if len(req.IDs) > 0 {
+		wanted := make(map[int64]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			wanted[id] = true
+		}
+		var filtered []model.Account
+		for _, a := range accounts {
+			if wanted[a.ID] {
+				filtered = append(filtered, a)
+			}
+		}
+		accounts = filtered
+	}
+	return accounts, true
+}
+
+// abortStartError maps a StartBot failure to an API error. Login failures
+// come from the game server, not from internals, so their text is kept.
+func abortStartError(c *gin.Context, err error) {
+	if errors.Is(err, bot.ErrAlreadyRunning) {
+		apierr.Abort(c, http.StatusConflict, apierr.BotAlreadyRunning, err.Error())
+		return
+	}
+	if errors.Is(err, bot.ErrDuplicateCode) {
+		apierr.Abort(c, http.StatusConflict, apierr.DuplicateCode, err.Error())
+		return
+	}
+	apierr.Abort(c, http.StatusBadGateway, apierr.BotStartFailed, err.Error())
+}
+
+// abortLandActionError maps a manual land action failure to an API error.
+// Game server rejections are passed through verbatim.
+func abortLandActionError(c *gin.Context, err error) {
+	var se *bot.ServerError
+	switch {
+	case errors.Is(err, bot.ErrNotRunning):
+		apierr.Abort(c, http.StatusConflict, apierr.BotNotRunning, err.Error())
+	case errors.Is(err, bot.ErrUnknownLand):
+		apierr.Abort(c, http.StatusNotFound, apierr.NotFound, err.Error())
+	case errors.Is(err, bot.ErrThrottled):
+		apierr.Abort(c, http.StatusTooManyRequests, apierr.RateLimited, err.Error())
+	case errors.As(err, &se):
+		apierr.Abort(c, http.StatusBadGateway, apierr.BotActionFailed, se.Message)
+	default:
+		apierr.Abort(c, http.StatusBadGateway, apierr.BotActionFailed, err.Error())
+	}
 }
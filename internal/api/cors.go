@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// originPolicy decides which browser origins may call the API and open
+// WebSockets, based on the allowed_origins config list.
+type originPolicy struct {
+	any     bool            // "*" in the list: every origin is allowed
+	origins map[string]bool // normalized scheme://host[:port]
+}
+
+func newOriginPolicy(allowed []string) *originPolicy {
+	p := &originPolicy{origins: make(map[string]bool)}
+	for _, o := range allowed {
+		o = strings.TrimSpace(o)
+		if o == "*" {
+			p.any = true
+			continue
+		}
+		if o != "" {
+			p.origins[normalizeOrigin(o)] = true
+		}
+	}
+	return p
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
+
+// allowed reports whether a non-empty Origin header value is permitted.
+func (p *originPolicy) allowed(origin string) bool {
+	return p.any || p.origins[normalizeOrigin(origin)]
+}
+
+// middleware sets CORS headers. With the wildcard the previous behavior is
+// kept (Allow-Origin: *, no credentials); with an explicit list the matching
+// origin is reflected and credentials are allowed. Preflights from origins
+// outside the list are rejected.
+func (p *originPolicy) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if p.any {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin != "" && p.allowed(origin) {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			} else if origin != "" && c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, X-Act-As-User")
+		c.Header("Access-Control-Expose-Headers", "X-Total-Count, X-Request-ID, Retry-After")
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkWebSocket is the WebSocket upgrader's CheckOrigin. Requests without an
+// Origin header (non-browser clients) and same-host pages such as the embedded
+// frontend are always accepted.
+func (p *originPolicy) checkWebSocket(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.allowed(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
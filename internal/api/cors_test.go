@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsRouter serves GET and POST /ping behind the origin policy of allowed.
+func corsRouter(allowed ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(newOriginPolicy(allowed).middleware())
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	r.POST("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return r
+}
+
+func corsRequest(r http.Handler, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/ping", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	wildcard := corsRouter("*")
+	listed := corsRouter("https://farm.example.com/", " https://admin.example.com")
+
+	cases := []struct {
+		name        string
+		r           http.Handler
+		method      string
+		origin      string
+		code        int
+		allowOrigin string
+		credentials bool
+	}{
+		{"wildcard", wildcard, http.MethodGet, "https://anywhere.example", http.StatusOK, "*", false},
+		{"wildcard preflight", wildcard, http.MethodOptions, "https://anywhere.example", http.StatusNoContent, "*", false},
+		{"allowed origin", listed, http.MethodGet, "https://farm.example.com", http.StatusOK, "https://farm.example.com", true},
+		{"allowed origin, other case", listed, http.MethodPost, "HTTPS://Admin.Example.com", http.StatusOK, "HTTPS://Admin.Example.com", true},
+		{"allowed preflight", listed, http.MethodOptions, "https://farm.example.com", http.StatusNoContent, "https://farm.example.com", true},
+		// The browser enforces the missing header; the request itself is served
+		{"disallowed origin", listed, http.MethodGet, "https://evil.example", http.StatusOK, "", false},
+		{"disallowed preflight", listed, http.MethodOptions, "https://evil.example", http.StatusForbidden, "", false},
+		{"no origin", listed, http.MethodGet, "", http.StatusOK, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := corsRequest(tc.r, tc.method, tc.origin)
+			h := w.Header()
+			if w.Code != tc.code {
+				t.Fatalf("status = %d, want %d", w.Code, tc.code)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tc.allowOrigin)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials") == "true"; got != tc.credentials {
+				t.Errorf("Allow-Credentials = %v, want %v", got, tc.credentials)
+			}
+			if tc.r == listed && h.Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin", h.Get("Vary"))
+			}
+			if tc.code == http.StatusForbidden {
+				return
+			}
+			for _, name := range []string{"X-Total-Count", "X-Request-ID", "Retry-After"} {
+				if !strings.Contains(h.Get("Access-Control-Expose-Headers"), name) {
+					t.Errorf("Expose-Headers %q lacks %s", h.Get("Access-Control-Expose-Headers"), name)
+				}
+			}
+			if !strings.Contains(h.Get("Access-Control-Allow-Headers"), "Authorization") {
+				t.Errorf("Allow-Headers = %q", h.Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	listed := newOriginPolicy([]string{"https://farm.example.com"})
+	wildcard := newOriginPolicy([]string{"*"})
+
+	cases := []struct {
+		name   string
+		p      *originPolicy
+		origin string
+		want   bool
+	}{
+		{"no origin", listed, "", true},
+		{"allowed origin", listed, "https://farm.example.com", true},
+		{"same host", listed, "https://bot.local:8080", true},
+		{"foreign origin", listed, "https://evil.example", false},
+		{"same host name, other port", listed, "https://bot.local:9090", false},
+		{"unparseable origin", listed, "://", false},
+		{"wildcard", wildcard, "https://evil.example", true},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/ws/logs", nil)
+		req.Host = "bot.local:8080"
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if got := tc.p.checkWebSocket(req); got != tc.want {
+			t.Errorf("%s: checkWebSocket(%q) = %v, want %v", tc.name, tc.origin, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"qq-farm-bot/internal/bot"
+)
+
+// gameConfigDirEnv overrides the game config the test process loads. The
+// config is loaded once per process, so a test needing another one re-runs
+// itself in a child process with this set.
+const gameConfigDirEnv = "QQFARM_TEST_GAME_CONFIG_DIR"
+
+func TestMain(m *testing.M) {
+	dir := "../../gameConfig"
+	if d, ok := os.LookupEnv(gameConfigDirEnv); ok {
+		dir = d
+	}
+	bot.LoadGameConfig(dir, nil)
+	os.Exit(m.Run())
+}
+
+// cropsGet returns the decoded body of a crops endpoint, failing unless it
+// answers 200.
+func cropsGet[T any](ts *testServer, path, token string) []T {
+	ts.t.Helper()
+	w := ts.do(http.MethodGet, path, token, "")
+	if w.Code != http.StatusOK {
+		ts.t.Fatalf("GET %s = %d: %s", path, w.Code, w.Body)
+	}
+	var rows []T
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		ts.t.Fatalf("GET %s: %v", path, err)
+	}
+	return rows
+}
+
+func TestCropsList(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.user("alice", false)
+
+	all := cropsGet[bot.CropInfo](ts, "/api/crops", token)
+	if len(all) == 0 {
+		t.Fatal("no crops listed")
+	}
+	if !sort.SliceIsSorted(all, func(i, j int) bool { return all[i].RequiredLevel < all[j].RequiredLevel }) {
+		t.Error("crops not sorted by required level")
+	}
+
+	const level = 10
+	want := 0
+	for _, c := range all {
+		if c.RequiredLevel <= level {
+			want++
+		}
+	}
+	if want == 0 || want == len(all) {
+		t.Fatalf("level %d doesn't split the shipped crops (%d of %d)", level, want, len(all))
+	}
+	filtered := cropsGet[bot.CropInfo](ts, "/api/crops?level=10", token)
+	if len(filtered) != want {
+		t.Fatalf("level 10 kept %d crops, want %d", len(filtered), want)
+	}
+	for _, c := range filtered {
+		if c.RequiredLevel > level {
+			t.Errorf("level 10 kept %s (level %d)", c.Name, c.RequiredLevel)
+		}
+	}
+
+	// An unusable level is no filter, and filtering leaves the cached list alone
+	for _, path := range []string{"/api/crops?level=0", "/api/crops?level=abc", "/api/crops"} {
+		if got := cropsGet[bot.CropInfo](ts, path, token); len(got) != len(all) {
+			t.Errorf("%s listed %d crops, want %d", path, len(got), len(all))
+		}
+	}
+}
+
+func TestCropsYield(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.user("alice", false)
+
+	rows := cropsGet[cropYieldRow](ts, "/api/crops/yield", token)
+	if len(rows) == 0 {
+		t.Fatal("empty yield table")
+	}
+	for i, r := range rows {
+		if r.Rank != i+1 || r.GrowTime == "" {
+			t.Fatalf("row %d = rank %d, grow time %q", i, r.Rank, r.GrowTime)
+		}
+	}
+
+	filtered := cropsGet[cropYieldRow](ts, "/api/crops/yield?level=10", token)
+	if len(filtered) == 0 || len(filtered) >= len(rows) {
+		t.Fatalf("level 10 kept %d of %d rows", len(filtered), len(rows))
+	}
+	for i, r := range filtered {
+		if r.RequiredLevel > 10 || r.Rank != i+1 {
+			t.Errorf("level 10 row %d: %s level %d rank %d", i, r.Name, r.RequiredLevel, r.Rank)
+		}
+	}
+	if again := cropsGet[cropYieldRow](ts, "/api/crops/yield", token); len(again) != len(rows) {
+		t.Fatalf("filtering changed the cached table: %d rows, want %d", len(again), len(rows))
+	}
+
+	byTime := cropsGet[cropYieldRow](ts, "/api/crops/yield?strategy=time&lands=6", token)
+	if !sort.SliceIsSorted(byTime, func(i, j int) bool { return byTime[i].GrowTimeNormalFert < byTime[j].GrowTimeNormalFert }) {
+		t.Error("strategy=time not sorted by grow time")
+	}
+
+	for _, q := range []string{"lands=0", "lands=25", "strategy=gold"} {
+		if w := ts.do(http.MethodGet, "/api/crops/yield?"+q, token, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestCropsEmptyGameConfig(t *testing.T) {
+	if _, ok := os.LookupEnv(gameConfigDirEnv); !ok {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCropsEmptyGameConfig$")
+		cmd.Env = append(os.Environ(), gameConfigDirEnv+"="+t.TempDir())
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("with an empty game config: %v\n%s", err, out)
+		}
+		return
+	}
+
+	ts := newTestServer(t)
+	_, token := ts.user("alice", false)
+	for _, path := range []string{"/api/crops", "/api/crops?level=10", "/api/crops/yield", "/api/crops/yield?level=10"} {
+		w := ts.do(http.MethodGet, path, token, "")
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+			t.Errorf("GET %s = %d %s, want 200 []", path, w.Code, w.Body)
+		}
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+// checkGolden compares got with testdata/name, or rewrites the file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n got: %s\nwant: %s", name, got, want)
+	}
+}
+
+func TestCropPhasesGolden(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.user("alice", false)
+
+	for _, tc := range []struct {
+		name    string
+		plantID int
+	}{
+		{"crop_phases_single_season.golden", 1020002}, // 白萝卜
+		{"crop_phases_two_seasons.golden", 1020050},   // 蘑菇
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ts.do(http.MethodGet, fmt.Sprintf("/api/crops/%d/phases", tc.plantID), token, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var out bytes.Buffer
+			if err := json.Indent(&out, w.Body.Bytes(), "", "  "); err != nil {
+				t.Fatal(err)
+			}
+			out.WriteByte('\n')
+			checkGolden(t, tc.name, out.Bytes())
+		})
+	}
+
+	if w := ts.do(http.MethodGet, "/api/crops/1/phases", token, ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown crop = %d, want 404", w.Code)
+	}
+}
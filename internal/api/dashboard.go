@@ -1,116 +1,291 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/bot"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
-func RegisterDashboardRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
-	r.GET("/dashboard", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
+// dashboardCard is a flat account card matching frontend DashboardStats.accounts
+type dashboardCard struct {
+	ID            int64              `json:"id"`
+	Name          string             `json:"name"`
+	Level         int64              `json:"level"`
+	Gold          int64              `json:"gold"`
+	Coupons       int64              `json:"coupons"`
+	Exp           int64              `json:"exp"`
+	Status        string             `json:"status"`
+	Platform      string             `json:"platform"`
+	Tags          []string           `json:"tags"`
+	TotalSteal    int64              `json:"total_steal"`
+	TotalHelp     int64              `json:"total_help"`
+	FriendsCount  int                `json:"friends_count"`
+	TotalLands    int                `json:"total_lands"`
+	UnlockedLands int                `json:"unlocked_lands"`
+	Lands         []model.LandStatus `json:"lands"`
+	// Level up estimation
+	ExpRatePerHour   float64 `json:"exp_rate_per_hour"`
+	NextLevelExp     int64   `json:"next_level_exp"`
+	ExpToNextLevel   int64   `json:"exp_to_next_level"`
+	HoursToNextLevel float64 `json:"hours_to_next_level"`
+	CropExpPerHour   float64 `json:"crop_exp_per_hour"`
+	StealExpPerHour  float64 `json:"steal_exp_per_hour"`
+	PendingTaskExp   int64   `json:"pending_task_exp"`
+	ConfigHealth     string  `json:"config_health,omitempty"`
+	// Today's gold spent vs sell proceeds plus stolen fruit value
+	SpendToday  int64 `json:"spend_today"`
+	IncomeToday int64 `json:"income_today"`
+	// When the account next needs attention
+	NextEventAt     *time.Time `json:"next_event_at,omitempty"`
+	NextEventType   string     `json:"next_event_type,omitempty"`
+	NextFarmCheckAt *time.Time `json:"next_farm_check_at,omitempty"`
+	// Uptime
+	UptimeSeconds int64      `json:"uptime_seconds"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+}
+
+// listVisibleAccounts returns all accounts for admins, otherwise only the user's own.
+func listVisibleAccounts(ctx context.Context, s store.Store, userID int64, isAdmin bool) ([]model.Account, error) {
+	if isAdmin {
+		return s.ListAccounts(ctx)
+	}
+	return s.ListAccountsByUserID(ctx, userID)
+}
 
-		var accounts []model.Account
-		var err error
+// buildDashboard assembles the dashboard payload shared by GET /dashboard and /ws/status.
+func buildDashboard(ctx context.Context, s store.Store, accounts []model.Account, mgr *bot.Manager) (gin.H, error) {
+	totalAccounts := len(accounts)
+	runningCount := 0
+	var totalGold int64
 
-		if isAdmin {
-			accounts, err = s.ListAccounts()
+	ids := make([]int64, len(accounts))
+	for i, a := range accounts {
+		ids[i] = a.ID
+	}
+	dayStart := bot.GameDayStart(time.Now())
+	today, err := s.GetTodayCounters(ctx, ids, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	spendToday, err := s.GetSpendTotals(ctx, ids, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[int64]*model.BotStatus, len(accounts))
+
+	var cards []dashboardCard
+	for _, a := range accounts {
+		card := dashboardCard{
+			ID:       a.ID,
+			Name:     a.Name,
+			Platform: a.Platform,
+			Tags:     model.ParseTags(a.Tags),
+			Status:   "stopped",
+		}
+		if card.Tags == nil {
+			card.Tags = []string{}
+		}
+		if t := today[a.ID]; t != nil {
+			card.IncomeToday = t.SellGold + t.StealValue
+		}
+		for _, amount := range spendToday[a.ID] {
+			card.SpendToday += amount
+		}
+		bs := mgr.GetStatus(a.ID)
+		statuses[a.ID] = bs
+		// Always populate fields from bot status (persisted even when stopped)
+		card.Level = bs.Level
+		card.Gold = bs.Gold
+		card.Coupons = bs.Coupons
+		card.Exp = bs.Exp
+		card.TotalSteal = bs.TotalSteal
+		card.TotalHelp = bs.TotalHelp
+		card.FriendsCount = bs.FriendsCount
+		card.TotalLands = bs.TotalLands
+		card.UnlockedLands = bs.UnlockedLands
+		if bs.Lands != nil {
+			card.Lands = bs.Lands
 		} else {
-			accounts, err = s.ListAccountsByUserID(userID)
+			card.Lands = []model.LandStatus{}
 		}
+		if bs.Running {
+			runningCount++
+			totalGold += bs.Gold
+			card.Status = "running"
+			// Level up estimation only for running bots
+			card.ExpRatePerHour = bs.ExpRatePerHour
+			card.NextLevelExp = bs.NextLevelExp
+			card.ExpToNextLevel = bs.ExpToNextLevel
+			card.HoursToNextLevel = bs.HoursToNextLevel
+			card.CropExpPerHour = bs.CropExpPerHour
+			card.StealExpPerHour = bs.StealExpPerHour
+			card.PendingTaskExp = bs.PendingTaskExp
+			card.ConfigHealth = bs.ConfigHealth
+			card.NextEventAt, card.NextEventType = bs.NextEventAt, bs.NextEventType
+			card.NextFarmCheckAt = bs.NextFarmCheckAt
+			if bs.StartedAt != nil {
+				card.StartedAt = bs.StartedAt
+				card.UptimeSeconds = int64(time.Since(*bs.StartedAt).Seconds())
+			}
+		} else if bs.Error != "" {
+			card.Status = "error"
+		}
+		cards = append(cards, card)
+	}
+	if cards == nil {
+		cards = make([]dashboardCard, 0)
+	}
+
+	fleet := summarizeFleet(accounts, statuses, today, time.Now())
+	return gin.H{
+		"total_accounts": totalAccounts,
+		"running_bots":   runningCount,
+		"total_gold":     totalGold,
+		"exp_per_hour":   fleet.ExpPerHour,
+		"needs_relogin":  fleet.NeedsRelogin,
+		"error_accounts": fleet.ErrorAccounts,
+		"harvest_today":  fleet.HarvestToday,
+		"steal_today":    fleet.StealToday,
+		"next_level_up":  fleet.NextLevelUp,
+		"alerts":         fleet.Alerts,
+		"accounts":       cards,
+	}, nil
+}
 
+const (
+	statusPushInterval = time.Second      // max one snapshot per second per client
+	statusPingPeriod   = 30 * time.Second // heartbeat ping interval
+	statusPongWait     = 70 * time.Second // client must answer pings within this window
+)
+
+func RegisterDashboardRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
+	r.GET("/dashboard", func(c *gin.Context) {
+		accounts, err := listVisibleAccounts(c.Request.Context(), s, c.GetInt64("userID"), c.GetBool("isAdmin"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
+		payload, err := buildDashboard(c.Request.Context(), s, accounts, mgr)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, payload)
+	})
+
+	// Live dashboard WebSocket: pushes the same payload as GET /dashboard
+	// whenever a visible account's status changes, throttled per client.
+	r.GET("/ws/status", func(c *gin.Context) {
+		userID := c.GetInt64("userID")
+		isAdmin := c.GetBool("isAdmin")
 
-		totalAccounts := len(accounts)
-		runningCount := 0
-		var totalGold int64
-
-		// Build flat account cards matching frontend DashboardStats.accounts
-		type accountCard struct {
-			ID              int64              `json:"id"`
-			Name            string             `json:"name"`
-			Level           int64              `json:"level"`
-			Gold            int64              `json:"gold"`
-			Exp             int64              `json:"exp"`
-			Status          string             `json:"status"`
-			Platform        string             `json:"platform"`
-			TotalSteal      int64              `json:"total_steal"`
-			TotalHelp       int64              `json:"total_help"`
-			FriendsCount    int                `json:"friends_count"`
-			TotalLands      int                `json:"total_lands"`
-			UnlockedLands   int                `json:"unlocked_lands"`
-			Lands           []model.LandStatus `json:"lands"`
-			// Level up estimation
-			ExpRatePerHour   float64 `json:"exp_rate_per_hour"`
-			NextLevelExp     int64   `json:"next_level_exp"`
-			ExpToNextLevel   int64   `json:"exp_to_next_level"`
-			HoursToNextLevel float64 `json:"hours_to_next_level"`
-			// Uptime
-			UptimeSeconds    int64      `json:"uptime_seconds"`
-			StartedAt        *time.Time `json:"started_at,omitempty"`
+		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
 		}
-		var cards []accountCard
-		for _, a := range accounts {
-			card := accountCard{
-				ID:       a.ID,
-				Name:     a.Name,
-				Platform: a.Platform,
-				Status:   "stopped",
+		defer conn.Close()
+
+		events := mgr.Events().Subscribe()
+		defer mgr.Events().Unsubscribe(events)
+
+		// owned caches the visible account IDs, refreshed with every snapshot;
+		// foreign caches accounts of other users to avoid repeated lookups
+		owned := make(map[int64]bool)
+		foreign := make(map[int64]bool)
+		push := func() error {
+			accounts, err := listVisibleAccounts(c.Request.Context(), s, userID, isAdmin)
+			if err != nil {
+				return err
 			}
-			bs := mgr.GetStatus(a.ID)
-			// Always populate fields from bot status (persisted even when stopped)
-			card.Level = bs.Level
-			card.Gold = bs.Gold
-			card.Exp = bs.Exp
-			card.TotalSteal = bs.TotalSteal
-			card.TotalHelp = bs.TotalHelp
-			card.FriendsCount = bs.FriendsCount
-			card.TotalLands = bs.TotalLands
-			card.UnlockedLands = bs.UnlockedLands
-			if bs.Lands != nil {
-				card.Lands = bs.Lands
-			} else {
-				card.Lands = []model.LandStatus{}
+			owned = make(map[int64]bool, len(accounts))
+			for _, a := range accounts {
+				owned[a.ID] = true
 			}
-			if bs.Running {
-				runningCount++
-				totalGold += bs.Gold
-				card.Status = "running"
-				// Level up estimation only for running bots
-				card.ExpRatePerHour = bs.ExpRatePerHour
-				card.NextLevelExp = bs.NextLevelExp
-				card.ExpToNextLevel = bs.ExpToNextLevel
-				card.HoursToNextLevel = bs.HoursToNextLevel
-				if bs.StartedAt != nil {
-					card.StartedAt = bs.StartedAt
-					card.UptimeSeconds = int64(time.Since(*bs.StartedAt).Seconds())
-				}
-			} else if bs.Error != "" {
-				card.Status = "error"
+			payload, err := buildDashboard(c.Request.Context(), s, accounts, mgr)
+			if err != nil {
+				return err
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			return conn.WriteJSON(payload)
+		}
+		visible := func(accountID int64) bool {
+			if isAdmin || owned[accountID] {
+				return true
 			}
-			if card.Lands == nil {
-				card.Lands = []model.LandStatus{}
+			if foreign[accountID] {
+				return false
 			}
-			cards = append(cards, card)
+			// Account created after the last snapshot
+			a, err := s.GetAccount(c.Request.Context(), accountID)
+			if err == nil && a.UserID == userID {
+				return true
+			}
+			foreign[accountID] = true
+			return false
 		}
-		if cards == nil {
-			cards = make([]accountCard, 0)
+
+		if err := push(); err != nil {
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"total_accounts": totalAccounts,
-			"running_bots":   runningCount,
-			"total_gold":     totalGold,
-			"accounts":       cards,
+		conn.SetReadDeadline(time.Now().Add(statusPongWait))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(statusPongWait))
+			return nil
 		})
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(statusPushInterval)
+		defer ticker.Stop()
+		ping := time.NewTicker(statusPingPeriod)
+		defer ping.Stop()
+		expiry := time.NewTimer(time.Until(c.GetTime("tokenExpiresAt")))
+		defer expiry.Stop()
+
+		dirty := false
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if !dirty && visible(ev.AccountID) {
+					dirty = true
+				}
+			case <-ticker.C:
+				if !dirty {
+					continue
+				}
+				dirty = false
+				if err := push(); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+					return
+				}
+			case <-expiry.C:
+				closeWithReason(conn, websocket.ClosePolicyViolation, "token expired")
+				return
+			case <-closed:
+				return
+			}
+		}
 	})
 }
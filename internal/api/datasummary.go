@@ -7,19 +7,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/bot"
 	"qq-farm-bot/internal/store"
 )
 
-func RegisterDataSummaryRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
+func RegisterDataSummaryRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
 	// GET /api/accounts/:id/data-summary?hours=24&days=7
-	r.GET("/accounts/:id/data-summary", func(c *gin.Context) {
-		idStr := c.Param("id")
-		accountID, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
-			return
-		}
+	r.GET("/accounts/:id/data-summary", accountOwnership(s), func(c *gin.Context) {
+		accountID := contextAccount(c).ID
 
 		hoursStr := c.DefaultQuery("hours", "24")
 		hours, _ := strconv.Atoi(hoursStr)
@@ -38,16 +34,16 @@ func RegisterDataSummaryRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Mana
 		daysSince := now.AddDate(0, 0, -days)
 
 		// Summary totals (use the hourly time range)
-		totals, err := s.GetDataSummaryTotals(accountID, hoursSince)
+		totals, err := s.GetDataSummaryTotals(c.Request.Context(), accountID, hoursSince)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
 		// Hourly trend
-		hourlyTrend, err := s.GetHourlyTrend(accountID, hoursSince)
+		hourlyTrend, err := s.GetHourlyTrend(c.Request.Context(), accountID, hoursSince)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if hourlyTrend == nil {
@@ -55,9 +51,9 @@ func RegisterDataSummaryRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Mana
 		}
 
 		// Crop breakdown (from hourly range)
-		cropBreakdown, err := s.GetCropBreakdown(accountID, hoursSince)
+		cropBreakdown, err := s.GetCropBreakdown(c.Request.Context(), accountID, hoursSince)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if cropBreakdown == nil {
@@ -65,9 +61,9 @@ func RegisterDataSummaryRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Mana
 		}
 
 		// Steal ranking (from hourly range)
-		stealRanking, err := s.GetStealRanking(accountID, hoursSince)
+		stealRanking, err := s.GetStealRanking(c.Request.Context(), accountID, hoursSince)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if stealRanking == nil {
@@ -75,9 +71,9 @@ func RegisterDataSummaryRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Mana
 		}
 
 		// Daily summary (from days range)
-		dailySummary, err := s.GetDailySummary(accountID, daysSince)
+		dailySummary, err := s.GetDailySummary(c.Request.Context(), accountID, daysSince)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if dailySummary == nil {
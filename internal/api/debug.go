@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/store"
+)
+
+// RegisterDebugRoutes exposes raw game data for troubleshooting. Only
+// registered when debug_endpoints_enabled is set.
+func RegisterDebugRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
+	// GET /api/accounts/:id/debug/lands — last AllLandsReply as seen by the
+	// farm analyzer, with the categories it put each land in
+	r.GET("/accounts/:id/debug/lands", accountOwnership(s), func(c *gin.Context) {
+		inst := mgr.GetInstance(contextAccount(c).ID)
+		if inst == nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "bot has not run since server start")
+			return
+		}
+		snap := inst.LandsSnapshot()
+		if snap.Reply == nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "no lands reply recorded yet")
+			return
+		}
+		raw, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(snap.Reply)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"captured_at":    snap.At,
+			"reply":          json.RawMessage(raw),
+			"classification": snap.Classes,
+		})
+	})
+}
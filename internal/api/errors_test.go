@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/bot"
+)
+
+func TestErrorCodes(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceJWT := ts.user("alice", false)
+	_, bobJWT := ts.user("bob", false)
+	_, adminJWT := ts.user("root", true)
+	mine := ts.account(alice, "mine")
+	landAction := `{"action":"` + bot.LandActions[0] + `"}`
+
+	cases := []struct {
+		name               string
+		method, path, body string
+		token              string
+		status             int
+		code               apierr.Code
+	}{
+		{"malformed json", http.MethodPost, "/api/accounts", `{"name":`, aliceJWT, http.StatusBadRequest, apierr.BadRequest},
+		{"malformed account id", http.MethodGet, "/api/accounts/abc/status", "", aliceJWT, http.StatusBadRequest, apierr.BadRequest},
+		{"no login code", http.MethodPost, accountPath(mine.ID, "/start"), "", aliceJWT, http.StatusBadRequest, apierr.NoLoginCode},
+		{"unknown land action", http.MethodPost, accountPath(mine.ID, "/lands/1/action"), `{"action":"dance"}`, aliceJWT, http.StatusBadRequest, apierr.BadRequest},
+		{"short password", http.MethodPost, "/api/auth/register", `{"username":"carol","password":"x"}`, "", http.StatusBadRequest, apierr.BadRequest},
+
+		{"no token", http.MethodGet, "/api/accounts", "", "", http.StatusUnauthorized, apierr.Unauthorized},
+		{"bad token", http.MethodGet, "/api/accounts", "", "not-a-jwt", http.StatusUnauthorized, apierr.Unauthorized},
+		{"wrong password", http.MethodPost, "/api/auth/login", `{"username":"alice","password":"wrong"}`, "", http.StatusUnauthorized, apierr.InvalidCreds},
+
+		{"another user's account", http.MethodGet, accountPath(mine.ID, "/status"), "", bobJWT, http.StatusForbidden, apierr.AccessDenied},
+		{"admin-only route", http.MethodPost, "/api/users/1/reset-password", "", aliceJWT, http.StatusForbidden, apierr.AccessDenied},
+
+		{"missing account", http.MethodGet, accountPath(mine.ID+100, "/status"), "", aliceJWT, http.StatusNotFound, apierr.AccountNotFound},
+		{"missing user", http.MethodPost, "/api/users/999/reset-password", "", adminJWT, http.StatusNotFound, apierr.UserNotFound},
+		{"unknown api route", http.MethodGet, "/api/no-such-route", "", aliceJWT, http.StatusNotFound, apierr.NotFound},
+
+		{"username taken", http.MethodPost, "/api/auth/register", `{"username":"alice","password":"secret1"}`, "", http.StatusConflict, apierr.UsernameTaken},
+		{"bot not running", http.MethodPost, accountPath(mine.ID, "/lands/1/action"), landAction, aliceJWT, http.StatusConflict, apierr.BotNotRunning},
+		{"trace while stopped", http.MethodPost, accountPath(mine.ID, "/trace"), `{"enabled":true}`, aliceJWT, http.StatusConflict, apierr.BotNotRunning},
+
+		{"invalid account", http.MethodPost, "/api/accounts", `{"name":"x","platform":"nope"}`, aliceJWT, http.StatusUnprocessableEntity, apierr.ValidationFailed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ts.do(tc.method, tc.path, tc.token, tc.body)
+			if w.Code != tc.status {
+				t.Fatalf("%s %s = %d, want %d: %s", tc.method, tc.path, w.Code, tc.status, w.Body)
+			}
+			var body apierr.Body
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q is not an error envelope: %v", w.Body, err)
+			}
+			if body.Code != tc.code || body.Message == "" {
+				t.Errorf("code = %q, message %q; want %q", body.Code, body.Message, tc.code)
+			}
+			if body.RequestID == "" || body.RequestID != w.Header().Get("X-Request-ID") {
+				t.Errorf("request_id = %q, X-Request-ID header %q", body.RequestID, w.Header().Get("X-Request-ID"))
+			}
+		})
+	}
+}
+
+func TestInternalErrorHidesCause(t *testing.T) {
+	ts := newTestServer(t)
+	var logged string
+	saved := apierr.Logf
+	apierr.Logf = func(format string, args ...any) { logged = format }
+	t.Cleanup(func() { apierr.Logf = saved })
+
+	ts.s.Close()
+	w := ts.do(http.MethodPost, "/api/auth/register", "", `{"username":"carol","password":"secret1"}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("register on a closed store = %d: %s", w.Code, w.Body)
+	}
+	var body apierr.Body
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Code != apierr.Internal || body.Message != "internal error" || body.RequestID == "" {
+		t.Fatalf("body = %+v", body)
+	}
+	if strings.Contains(w.Body.String(), "sql") || strings.Contains(w.Body.String(), "closed") {
+		t.Fatalf("internal error text leaked: %s", w.Body)
+	}
+	if logged == "" {
+		t.Fatal("internal error not logged")
+	}
+}
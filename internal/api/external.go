@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/bot"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
@@ -19,14 +20,14 @@ import (
 //   - Per-account API key (from database): restricted to that account only
 //
 // When a per-account key matches, "externalAccountID" is set in the gin context.
-func APIKeyMiddleware(globalAPIKey string, s *store.Store) gin.HandlerFunc {
+func APIKeyMiddleware(globalAPIKey string, s store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		key := c.GetHeader("X-API-Key")
 		if key == "" {
 			key = c.Query("api_key")
 		}
 		if key == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API key"})
+			apierr.Abort(c, http.StatusUnauthorized, apierr.InvalidAPIKey, "invalid or missing API key")
 			return
 		}
 
@@ -37,9 +38,9 @@ func APIKeyMiddleware(globalAPIKey string, s *store.Store) gin.HandlerFunc {
 		}
 
 		// 2. Check per-account API key — restricted to that account
-		account, err := s.GetAccountByAPIKey(key)
+		account, err := s.GetAccountByAPIKey(c.Request.Context(), key)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API key"})
+			apierr.Abort(c, http.StatusUnauthorized, apierr.InvalidAPIKey, "invalid or missing API key")
 			return
 		}
 
@@ -64,21 +65,21 @@ func getRestrictedAccountID(c *gin.Context) (int64, bool) {
 func checkAccountAccess(c *gin.Context, requestedID int64) bool {
 	restrictedID, restricted := getRestrictedAccountID(c)
 	if restricted && restrictedID != requestedID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied: API key can only access its own account"})
+		apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied: API key can only access its own account")
 		return false
 	}
 	return true
 }
 
-func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
+func RegisterExternalRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
 
 	// ==================== Account Endpoints ====================
 
 	// GET /api/external/accounts — List accounts (filtered by API key scope)
 	r.GET("/accounts", func(c *gin.Context) {
-		accounts, err := s.ListAccounts()
+		accounts, err := s.ListAccounts(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
@@ -123,7 +124,7 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 	r.PUT("/accounts/:id/code", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account ID")
 			return
 		}
 
@@ -136,13 +137,13 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 			Platform string `json:"platform"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "code is required")
 			return
 		}
 
-		account, err := s.GetAccount(id)
+		account, err := s.GetAccount(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
 			return
 		}
 
@@ -150,8 +151,8 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 		if req.Platform != "" {
 			account.Platform = req.Platform
 		}
-		if err := s.UpdateAccount(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := s.UpdateAccount(c.Request.Context(), account); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
@@ -169,28 +170,32 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 			AutoStart bool   `json:"auto_start"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "name and code are required"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "name and code are required")
 			return
 		}
 		if req.Platform == "" {
 			req.Platform = "qq"
 		}
+		if !model.ValidPlatform(req.Platform) {
+			apierr.Abort(c, http.StatusUnprocessableEntity, apierr.ValidationFailed, "platform: must be qq or wx")
+			return
+		}
 
 		restrictedID, restricted := getRestrictedAccountID(c)
 
 		// Try to find existing account by name
-		account, err := s.GetAccountByName(req.Name)
+		account, err := s.GetAccountByName(c.Request.Context(), req.Name)
 		if err == nil {
 			// Per-account key: verify this is the same account
 			if restricted && account.ID != restrictedID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied: API key can only access its own account"})
+				apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied: API key can only access its own account")
 				return
 			}
 			// Account exists — update code
 			account.Code = req.Code
 			account.Platform = req.Platform
-			if err := s.UpdateAccount(account); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			if err := s.UpdateAccount(c.Request.Context(), account); err != nil {
+				apierr.AbortInternal(c, err)
 				return
 			}
 			c.JSON(http.StatusOK, gin.H{
@@ -203,7 +208,7 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 
 		// Per-account key: cannot create new accounts
 		if restricted {
-			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: per-account API key cannot create new accounts"})
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied: per-account API key cannot create new accounts")
 			return
 		}
 
@@ -228,8 +233,8 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 			EnableHelpFriend:  true,
 			EnableClaimTask:   true,
 		}
-		if err := s.CreateAccount(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := s.CreateAccount(c.Request.Context(), account); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
 		c.JSON(http.StatusCreated, gin.H{
@@ -243,9 +248,9 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 
 	// POST /api/external/bot/start-all — Start bots (filtered by API key scope)
 	r.POST("/bot/start-all", func(c *gin.Context) {
-		accounts, err := s.ListAccounts()
+		accounts, err := s.ListAccounts(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
@@ -263,7 +268,7 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 				continue
 			}
 			acct := a
-			if err := mgr.StartBot(&acct); err != nil {
+			if err := mgr.StartBot(&acct, apierr.RequestID(c)); err != nil {
 				failed++
 				errors = append(errors, fmt.Sprintf("#%d(%s): %s", a.ID, a.Name, err.Error()))
 			} else {
@@ -281,9 +286,9 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 
 	// POST /api/external/bot/stop-all — Stop bots (filtered by API key scope)
 	r.POST("/bot/stop-all", func(c *gin.Context) {
-		accounts, err := s.ListAccounts()
+		accounts, err := s.ListAccounts(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
@@ -294,7 +299,7 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 			if restricted && a.ID != restrictedID {
 				continue
 			}
-			if err := mgr.StopBot(a.ID); err == nil {
+			if err := mgr.StopBot(a.ID, apierr.RequestID(c)); err == nil {
 				stopped++
 			}
 		}
@@ -308,23 +313,23 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 	r.POST("/bot/:id/start", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account ID")
 			return
 		}
 		if !checkAccountAccess(c, id) {
 			return
 		}
-		account, err := s.GetAccount(id)
+		account, err := s.GetAccount(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
 			return
 		}
 		if account.Code == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "account has no login code"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.NoLoginCode, "account has no login code")
 			return
 		}
-		if err := mgr.StartBot(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := mgr.StartBot(account, apierr.RequestID(c)); err != nil {
+			abortStartError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "started", "account_id": id})
@@ -334,14 +339,14 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 	r.POST("/bot/:id/stop", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account ID")
 			return
 		}
 		if !checkAccountAccess(c, id) {
 			return
 		}
-		if err := mgr.StopBot(id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := mgr.StopBot(id, apierr.RequestID(c)); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "stopped", "account_id": id})
@@ -351,28 +356,28 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 	r.POST("/bot/:id/restart", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account ID")
 			return
 		}
 		if !checkAccountAccess(c, id) {
 			return
 		}
-		account, err := s.GetAccount(id)
+		account, err := s.GetAccount(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
 			return
 		}
 		if account.Code == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "account has no login code"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.NoLoginCode, "account has no login code")
 			return
 		}
 		// Stop first (ignore error — bot may not be running)
-		mgr.StopBot(id)
+		mgr.StopBot(id, apierr.RequestID(c))
 		// Brief pause to allow goroutine cleanup
 		time.Sleep(500 * time.Millisecond)
 		// Start
-		if err := mgr.StartBot(account); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := mgr.StartBot(account, apierr.RequestID(c)); err != nil {
+			abortStartError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "restarted", "account_id": id})
@@ -384,14 +389,14 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 	r.GET("/bot/:id/status", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account ID")
 			return
 		}
 		if !checkAccountAccess(c, id) {
 			return
 		}
-		if _, err := s.GetAccount(id); err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		if _, err := s.GetAccount(c.Request.Context(), id); err != nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
 			return
 		}
 		status := mgr.GetStatus(id)
@@ -400,9 +405,9 @@ func RegisterExternalRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager
 
 	// GET /api/external/status — Get bots status overview (filtered by API key scope)
 	r.GET("/status", func(c *gin.Context) {
-		accounts, err := s.ListAccounts()
+		accounts, err := s.ListAccounts(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// reconnectAlertThreshold is how many reconnects in a row must fail before
+// the dashboard raises an alert for the account.
+const reconnectAlertThreshold = 5
+
+// Dashboard alert severities.
+const (
+	alertError   = "error"
+	alertWarning = "warning"
+)
+
+// fleetAlert is one banner line on the dashboard.
+type fleetAlert struct {
+	Severity  string `json:"severity"`
+	AccountID int64  `json:"account_id"`
+	Message   string `json:"message"`
+}
+
+// fleetLevelUp is the soonest expected level-up across running bots.
+type fleetLevelUp struct {
+	AccountID int64     `json:"account_id"`
+	Name      string    `json:"name"`
+	Level     int64     `json:"level"` // the level about to be reached
+	Hours     float64   `json:"hours"`
+	At        time.Time `json:"at"`
+}
+
+// fleetSummary rolls the visible accounts up for the dashboard header.
+type fleetSummary struct {
+	ExpPerHour    float64       `json:"exp_per_hour"` // summed over running bots
+	NeedsRelogin  int           `json:"needs_relogin"`
+	ErrorAccounts int           `json:"error_accounts"`
+	HarvestToday  int64         `json:"harvest_today"`
+	StealToday    int64         `json:"steal_today"`
+	NextLevelUp   *fleetLevelUp `json:"next_level_up,omitempty"`
+	Alerts        []fleetAlert  `json:"alerts"`
+}
+
+// summarizeFleet computes the fleet rollup from each account's bot status
+// and today's counters. It does no I/O so it can be checked on fixtures.
+func summarizeFleet(accounts []model.Account, statuses map[int64]*model.BotStatus, today map[int64]*model.TodayCounters, now time.Time) fleetSummary {
+	sum := fleetSummary{Alerts: []fleetAlert{}}
+	alert := func(severity string, a model.Account, format string, args ...any) {
+		sum.Alerts = append(sum.Alerts, fleetAlert{
+			Severity:  severity,
+			AccountID: a.ID,
+			Message:   fmt.Sprintf("账号 %s ", a.Name) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, a := range accounts {
+		if t := today[a.ID]; t != nil {
+			sum.HarvestToday += t.Harvest
+			sum.StealToday += t.Steal
+		}
+		bs := statuses[a.ID]
+		if bs == nil {
+			continue
+		}
+		switch {
+		case bs.NeedsRelogin:
+			sum.NeedsRelogin++
+			alert(alertError, a, "需要重新扫码登录")
+		case !bs.Running && bs.Error != "":
+			sum.ErrorAccounts++
+			alert(alertError, a, "异常: %s", bs.Error)
+		}
+		if bs.ReconnectFailures >= reconnectAlertThreshold {
+			alert(alertWarning, a, "连续重连 %d 次", bs.ReconnectFailures)
+		}
+		if !bs.Running {
+			continue
+		}
+		sum.ExpPerHour += bs.ExpRatePerHour
+		for _, w := range bs.Warnings {
+			if w == model.WarningBagFull {
+				alert(alertWarning, a, "背包已满")
+			}
+		}
+		if bs.ConfigHealth != "" {
+			alert(alertWarning, a, "游戏配置异常: %s", bs.ConfigHealth)
+		}
+		if bs.HoursToNextLevel > 0 && (sum.NextLevelUp == nil || bs.HoursToNextLevel < sum.NextLevelUp.Hours) {
+			sum.NextLevelUp = &fleetLevelUp{
+				AccountID: a.ID,
+				Name:      a.Name,
+				Level:     bs.Level + 1,
+				Hours:     bs.HoursToNextLevel,
+				At:        now.Add(time.Duration(bs.HoursToNextLevel * float64(time.Hour))),
+			}
+		}
+	}
+	sum.ExpPerHour = math.Round(sum.ExpPerHour*10) / 10
+	return sum
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+func TestSummarizeFleet(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	accounts := []model.Account{
+		{ID: 1, Name: "fast"},
+		{ID: 2, Name: "slow"},
+		{ID: 3, Name: "stopped"},
+		{ID: 4, Name: "crashed"},
+		{ID: 5, Name: "expired"},
+		{ID: 6, Name: "never"},
+	}
+	statuses := map[int64]*model.BotStatus{
+		1: {Running: true, Level: 20, ExpRatePerHour: 120.25, HoursToNextLevel: 1.5, Warnings: []string{model.WarningBagFull}},
+		2: {Running: true, Level: 30, ExpRatePerHour: 80.1, HoursToNextLevel: 6, ReconnectFailures: reconnectAlertThreshold},
+		3: {Running: false, Level: 15, ExpRatePerHour: 500, HoursToNextLevel: 0.5},
+		4: {Running: false, Error: "connect: refused"},
+		5: {Running: false, NeedsRelogin: true, Error: "login expired"},
+	}
+	today := map[int64]*model.TodayCounters{
+		1: {Harvest: 10, Steal: 3},
+		3: {Harvest: 5, Steal: 1},
+		6: {Harvest: 2},
+	}
+
+	sum := summarizeFleet(accounts, statuses, today, now)
+
+	// Only running bots count towards the exp rate and the next level-up
+	if sum.ExpPerHour != 200.4 {
+		t.Errorf("exp/hour = %v, want 200.4", sum.ExpPerHour)
+	}
+	if sum.HarvestToday != 17 || sum.StealToday != 4 {
+		t.Errorf("today = %d harvests, %d steals; want 17, 4", sum.HarvestToday, sum.StealToday)
+	}
+	if sum.ErrorAccounts != 1 || sum.NeedsRelogin != 1 {
+		t.Errorf("errors = %d, needs relogin = %d; want 1, 1", sum.ErrorAccounts, sum.NeedsRelogin)
+	}
+	want := &fleetLevelUp{AccountID: 1, Name: "fast", Level: 21, Hours: 1.5, At: now.Add(90 * time.Minute)}
+	if got := sum.NextLevelUp; got == nil || *got != *want {
+		t.Errorf("next level-up = %+v, want %+v", got, want)
+	}
+
+	alerts := make(map[int64][]string)
+	for _, a := range sum.Alerts {
+		alerts[a.AccountID] = append(alerts[a.AccountID], a.Severity)
+	}
+	for id, severities := range map[int64][]string{
+		1: {alertWarning}, // bag full
+		2: {alertWarning}, // reconnect failures
+		4: {alertError},
+		5: {alertError}, // relogin, not also an error
+	} {
+		if got := alerts[id]; len(got) != len(severities) || got[0] != severities[0] {
+			t.Errorf("account %d alerts = %v, want %v", id, got, severities)
+		}
+	}
+	if len(sum.Alerts) != 4 {
+		t.Errorf("alerts = %+v, want 4", sum.Alerts)
+	}
+}
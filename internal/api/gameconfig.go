@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/auth"
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+func RegisterGameConfigRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager, cfg *config.Config) {
+	// POST /gameconfig/reload - Admin only. Re-reads gameConfig/ without
+	// restarting the server; running bots pick up the new data immediately.
+	r.POST("/gameconfig/reload", func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+			return
+		}
+
+		gc := bot.GetGameConfig()
+		if err := gc.Reload(cfg.GameConfigDir); err != nil {
+			mgr.SystemLogger().Errorf("配置", "手动重载失败: %v", err)
+			apierr.AbortInternal(c, err)
+			return
+		}
+		auth.RecordAudit(c, s, model.AuditConfigReload, 0, "")
+		mgr.SystemLogger().Infof("配置", "%s 手动重新加载了游戏配置", c.GetString("username"))
+		warnings := gc.Validate()
+		if warnings == nil {
+			warnings = []string{}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "reloaded", "crops": len(gc.GetCropList()), "warnings": warnings})
+	})
+}
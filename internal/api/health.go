@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/store"
+)
+
+// managerLockTimeout bounds how long /readyz waits for the manager lock.
+const managerLockTimeout = 100 * time.Millisecond
+
+// healthCheck is the result of one readiness check.
+type healthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RegisterHealthRoutes adds unauthenticated liveness and readiness probes.
+func RegisterHealthRoutes(r *gin.Engine, s store.Store, mgr *bot.Manager) {
+	// Liveness: the process is serving HTTP
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Readiness: dependencies needed to serve requests are usable
+	r.GET("/readyz", func(c *gin.Context) {
+		checks := []healthCheck{
+			checkDatabase(c.Request.Context(), s),
+			checkGameConfig(),
+			checkManager(mgr),
+		}
+		status, code := "ok", http.StatusOK
+		for _, chk := range checks {
+			if !chk.OK {
+				status, code = "unavailable", http.StatusServiceUnavailable
+				break
+			}
+		}
+		c.JSON(code, gin.H{"status": status, "checks": checks, "maintenance": s.LastMaintenance()})
+	})
+}
+
+func checkDatabase(ctx context.Context, s store.Store) healthCheck {
+	chk := healthCheck{Name: "database"}
+	if err := s.CheckWritable(ctx); err != nil {
+		chk.Detail = err.Error()
+		return chk
+	}
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		chk.Detail = err.Error()
+		return chk
+	}
+	chk.Detail = fmt.Sprintf("schema v%d", version)
+	if version != store.LatestSchemaVersion {
+		chk.Detail += fmt.Sprintf(", expected v%d", store.LatestSchemaVersion)
+		return chk
+	}
+	chk.OK = true
+	return chk
+}
+
+func checkGameConfig() healthCheck {
+	chk := healthCheck{Name: "game_config"}
+	gc := bot.GetGameConfig()
+	n := gc.PlantCount()
+	if n == 0 {
+		chk.Detail = "Plant.json not loaded (degraded)"
+		if dir := gc.ConfigDir(); dir != "" {
+			chk.Detail += ": " + bot.DescribeGameConfigDir(dir)
+		}
+		return chk
+	}
+	chk.OK = true
+	chk.Detail = fmt.Sprintf("%d plants", n)
+	return chk
+}
+
+func checkManager(mgr *bot.Manager) healthCheck {
+	chk := healthCheck{Name: "manager"}
+	if !mgr.Responsive(managerLockTimeout) {
+		chk.Detail = fmt.Sprintf("lock not acquired within %s", managerLockTimeout)
+		return chk
+	}
+	chk.OK = true
+	return chk
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/store"
+)
+
+// staleStore reports an older schema version, as a database left behind by
+// a failed upgrade would.
+type staleStore struct{ store.Store }
+
+func (staleStore) SchemaVersion(context.Context) (int, error) {
+	return store.LatestSchemaVersion - 1, nil
+}
+
+// unwritableStore fails the write probe.
+type unwritableStore struct{ store.Store }
+
+func (unwritableStore) CheckWritable(context.Context) error {
+	return errors.New("attempt to write a readonly database")
+}
+
+type readyzBody struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+// probe serves the health routes over s and returns the status and decoded
+// body of path.
+func probe(t *testing.T, s store.Store, path string) (int, readyzBody) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.ConsoleLog = config.ConsoleLogOff
+	mgr := bot.NewManager(s, cfg)
+	t.Cleanup(mgr.StopAll)
+	r := gin.New()
+	RegisterHealthRoutes(r, s, mgr)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var body readyzBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET %s: %v: %s", path, err, w.Body)
+	}
+	return w.Code, body
+}
+
+func openHealthStore(t *testing.T) *store.SQLStore {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "farm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestReadyz(t *testing.T) {
+	closed := openHealthStore(t)
+	closed.Close()
+
+	cases := []struct {
+		name   string
+		s      store.Store
+		status int
+		detail string // of the database check
+	}{
+		{"healthy", openHealthStore(t), http.StatusOK, "schema v"},
+		{"closed database", closed, http.StatusServiceUnavailable, "closed"},
+		{"unwritable database", unwritableStore{openHealthStore(t)}, http.StatusServiceUnavailable, "readonly"},
+		{"stale schema", staleStore{openHealthStore(t)}, http.StatusServiceUnavailable, "expected v"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := probe(t, tc.s, "/readyz")
+			if code != tc.status {
+				t.Fatalf("status = %d, want %d: %+v", code, tc.status, body)
+			}
+			wantStatus := "ok"
+			if tc.status != http.StatusOK {
+				wantStatus = "unavailable"
+			}
+			if body.Status != wantStatus {
+				t.Errorf("status field = %q, want %q", body.Status, wantStatus)
+			}
+			if len(body.Checks) == 0 || body.Checks[0].Name != "database" {
+				t.Fatalf("checks = %+v", body.Checks)
+			}
+			db := body.Checks[0]
+			if db.OK != (tc.status == http.StatusOK) || !strings.Contains(db.Detail, tc.detail) {
+				t.Errorf("database check = %+v, want detail containing %q", db, tc.detail)
+			}
+			// Only the database is broken
+			for _, chk := range body.Checks[1:] {
+				if !chk.OK {
+					t.Errorf("%s check failed: %s", chk.Name, chk.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthzIgnoresDatabase(t *testing.T) {
+	closed := openHealthStore(t)
+	closed.Close()
+	if code, body := probe(t, closed, "/healthz"); code != http.StatusOK || body.Status != "ok" {
+		t.Fatalf("healthz with a closed database = %d %+v", code, body)
+	}
+}
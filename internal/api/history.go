@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// historyTotal is one game day of the aggregate history across accounts.
+type historyTotal struct {
+	Date         string `json:"date"`
+	Accounts     int    `json:"accounts"`
+	Gold         int64  `json:"gold"`
+	Exp          int64  `json:"exp"`
+	TotalHarvest int64  `json:"total_harvest"`
+	TotalSteal   int64  `json:"total_steal"`
+	TotalHelp    int64  `json:"total_help"`
+}
+
+func RegisterHistoryRoutes(r *gin.RouterGroup, s store.Store) {
+	// GET /api/accounts/:id/history?days=30
+	r.GET("/accounts/:id/history", accountOwnership(s), func(c *gin.Context) {
+		rows, err := s.GetDailySummaries(c.Request.Context(), []int64{contextAccount(c).ID}, historySince(c))
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rows)
+	})
+
+	// GET /api/accounts/:id/grief?days=30 — weeds/insects each friend left
+	// on the account's lands, summed over the days, worst first
+	r.GET("/accounts/:id/grief", accountOwnership(s), func(c *gin.Context) {
+		rows, err := s.GetFriendGrief(c.Request.Context(), contextAccount(c).ID, historySince(c))
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rows)
+	})
+
+	// GET /api/history?days=30 — per-day totals over all of the user's accounts
+	r.GET("/history", func(c *gin.Context) {
+		var accounts []model.Account
+		var err error
+		if c.GetBool("isAdmin") {
+			accounts, err = s.ListAccounts(c.Request.Context())
+		} else {
+			accounts, err = s.ListAccountsByUserID(c.Request.Context(), c.GetInt64("userID"))
+		}
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		ids := make([]int64, 0, len(accounts))
+		for _, a := range accounts {
+			ids = append(ids, a.ID)
+		}
+
+		rows, err := s.GetDailySummaries(c.Request.Context(), ids, historySince(c))
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		// Rows are ordered by date, so consecutive rows share a bucket
+		totals := []historyTotal{}
+		for _, row := range rows {
+			if len(totals) == 0 || totals[len(totals)-1].Date != row.Date {
+				totals = append(totals, historyTotal{Date: row.Date})
+			}
+			t := &totals[len(totals)-1]
+			t.Accounts++
+			t.Gold += row.Gold
+			t.Exp += row.Exp
+			t.TotalHarvest += row.TotalHarvest
+			t.TotalSteal += row.TotalSteal
+			t.TotalHelp += row.TotalHelp
+		}
+		c.JSON(http.StatusOK, gin.H{"totals": totals, "accounts": rows})
+	})
+}
+
+// historySince returns the first game day covered by ?days= (default 30, max 365).
+func historySince(c *gin.Context) string {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+	return bot.GameDate(time.Now().AddDate(0, 0, -(days - 1)))
+}
@@ -8,47 +8,55 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/i18n"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
-var wsUpgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
-}
+// wsUpgrader's CheckOrigin is set from allowed_origins in SetupRouter.
+var wsUpgrader = websocket.Upgrader{}
 
-func RegisterLogRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
-	// Get historical logs
-	r.GET("/accounts/:id/logs", func(c *gin.Context) {
-		userID := c.GetInt64("userID")
-		isAdmin := c.GetBool("isAdmin")
+func RegisterLogRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
+	// Get historical logs; ?correlation_id= returns only the lines of one API action
+	r.GET("/accounts/:id/logs", accountOwnership(s), func(c *gin.Context) {
+		id := contextAccount(c).ID
 
-		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		beforeID, _ := strconv.ParseInt(c.DefaultQuery("before_id", "0"), 10, 64)
 
-		// Check ownership (admin can view any)
-		if !isAdmin {
-			account, err := s.GetAccount(id)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
-				return
-			}
-			if account.UserID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
-			}
+		logs, err := s.GetLogs(c.Request.Context(), id, limit, beforeID, c.Query("correlation_id"))
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if logs == nil {
+			logs = make([]model.LogEntry, 0)
 		}
+		translateLogs(userLocale(c, s), logs)
+		c.JSON(http.StatusOK, logs)
+	})
 
+	// GET /system/logs - Admin only. Server events not tied to an account;
+	// live entries stream via /ws/logs?account_id=0.
+	r.GET("/system/logs", func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+			return
+		}
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
 		beforeID, _ := strconv.ParseInt(c.DefaultQuery("before_id", "0"), 10, 64)
 
-		logs, err := s.GetLogs(id, limit, beforeID)
+		logs, err := s.GetLogs(c.Request.Context(), bot.SystemAccountID, limit, beforeID, c.Query("correlation_id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if logs == nil {
 			logs = make([]model.LogEntry, 0)
 		}
+		translateLogs(userLocale(c, s), logs)
 		c.JSON(http.StatusOK, logs)
 	})
 
@@ -59,41 +67,55 @@ func RegisterLogRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
 
 		idStr := c.Query("account_id")
 		if idStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing account_id"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "missing account_id")
+			return
+		}
+		accountID, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil || accountID < 0 {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account_id")
+			return
+		}
+		if !inTokenScope(c, accountID) {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "token is limited to another account")
 			return
 		}
-		accountID, _ := strconv.ParseInt(idStr, 10, 64)
 
-		// Check ownership (admin can view any)
-		if !isAdmin {
-			account, err := s.GetAccount(accountID)
+		// The system channel has no account row and is admin only. For real
+		// accounts check ownership (admin can view any); deleted accounts are
+		// gone for everyone
+		if accountID == bot.SystemAccountID {
+			if !isAdmin {
+				apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+				return
+			}
+		} else {
+			account, err := s.GetAccount(c.Request.Context(), accountID)
 			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+				apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
 				return
 			}
-			if account.UserID != userID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			if !isAdmin && account.UserID != userID {
+				apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
 				return
 			}
 		}
 
+		locale := userLocale(c, s)
+
 		conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			return
 		}
 		defer conn.Close()
 
-		inst := mgr.GetInstance(accountID)
-		if inst == nil {
-			conn.WriteJSON(map[string]string{"error": "bot not running"})
-			return
-		}
+		// Subscribe at the manager level so the stream survives bot restarts
+		logCh := mgr.Logs().Subscribe(accountID)
+		defer mgr.Logs().Unsubscribe(accountID, logCh)
 
-		logCh := inst.Logger().Subscribe()
-		defer inst.Logger().Unsubscribe(logCh)
-
-		// Keep alive
+		// Drain client frames; a read error means the client went away
+		closed := make(chan struct{})
 		go func() {
+			defer close(closed)
 			for {
 				if _, _, err := conn.ReadMessage(); err != nil {
 					return
@@ -101,18 +123,59 @@ func RegisterLogRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
 			}
 		}()
 
-		for entry := range logCh {
-			data := map[string]interface{}{
-				"id":         entry.ID,
-				"account_id": entry.AccountID,
-				"tag":        entry.Tag,
-				"message":    entry.Message,
-				"level":      entry.Level,
-				"created_at": entry.CreatedAt.Format(time.RFC3339),
-			}
-			if err := conn.WriteJSON(data); err != nil {
+		expiry := time.NewTimer(time.Until(c.GetTime("tokenExpiresAt")))
+		defer expiry.Stop()
+
+		for {
+			select {
+			case entry, ok := <-logCh:
+				if !ok {
+					return
+				}
+				tag, message := i18n.Translate(locale, entry.Tag, entry.Message)
+				data := map[string]interface{}{
+					"id":         entry.ID,
+					"account_id": entry.AccountID,
+					"tag":        tag,
+					"message":    message,
+					"level":      entry.Level,
+					"created_at": entry.CreatedAt.Format(time.RFC3339),
+				}
+				if err := conn.WriteJSON(data); err != nil {
+					return
+				}
+			case <-expiry.C:
+				closeWithReason(conn, websocket.ClosePolicyViolation, "token expired")
+				return
+			case <-closed:
 				return
 			}
 		}
 	})
 }
+
+// userLocale returns the log language of the requesting user, falling back
+// to the stored language when it can't be read.
+func userLocale(c *gin.Context, s store.Store) string {
+	user, err := s.GetUserByID(c.Request.Context(), c.GetInt64("userID"))
+	if err != nil {
+		return i18n.Zh
+	}
+	return user.Locale
+}
+
+// translateLogs rewrites the known lines of logs into locale in place.
+func translateLogs(locale string, logs []model.LogEntry) {
+	if locale == i18n.Zh {
+		return
+	}
+	for i := range logs {
+		logs[i].Tag, logs[i].Message = i18n.Translate(locale, logs[i].Tag, logs[i].Message)
+	}
+}
+
+// closeWithReason sends a close frame so clients can tell why the stream ended.
+func closeWithReason(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
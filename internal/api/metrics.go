@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/bot"
+)
+
+// RegisterMetricsRoutes exposes Prometheus text-format metrics. It is mounted
+// under the external API group and requires the global API key.
+func RegisterMetricsRoutes(r *gin.RouterGroup, mgr *bot.Manager) {
+	r.GET("/metrics", func(c *gin.Context) {
+		if _, restricted := getRestrictedAccountID(c); restricted {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "global API key required")
+			return
+		}
+
+		running := 0
+		for _, st := range mgr.GetAllStatus() {
+			if st.Running {
+				running++
+			}
+		}
+
+		var b strings.Builder
+		writeMetric(&b, "qqfarm_bots_running", "gauge", "Number of running bots.", int64(running))
+		writeMetric(&b, "qqfarm_log_subscribers", "gauge", "Active live log subscriptions.", int64(mgr.Logs().Subscribers()))
+		writeMetric(&b, "qqfarm_log_subscriber_dropped_total", "counter", "Log entries dropped for slow live log subscribers.", mgr.Logs().Dropped())
+		writeMetric(&b, "qqfarm_log_file_dropped_total", "counter", "Log entries dropped by the log file sink.", mgr.LogFileDropped())
+		fl := bot.GetFriendListTraffic()
+		writeMetric(&b, "qqfarm_friend_list_full_fetches_total", "counter", "Full friend list (GetAll) fetches.", fl.FullFetches)
+		writeMetric(&b, "qqfarm_friend_list_full_bytes_total", "counter", "Bytes received in full friend list replies.", fl.FullBytes)
+		writeMetric(&b, "qqfarm_friend_list_partial_fetches_total", "counter", "Partial friend refreshes (GetGameFriends) between full fetches.", fl.PartialFetches)
+		writeMetric(&b, "qqfarm_friend_list_partial_bytes_total", "counter", "Bytes received in partial friend refreshes.", fl.PartialBytes)
+		writeMetric(&b, "qqfarm_friend_list_bytes_saved_total", "counter", "Estimated friend list bytes saved by the cached friend list.", fl.BytesSaved)
+		c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	})
+}
+
+// writeMetric appends one metric with its HELP and TYPE lines.
+func writeMetric(b *strings.Builder, name, kind, help string, value int64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/auth"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// accountKey is the gin context key of the account resolved by accountOwnership.
+const accountKey = "account"
+
+// accountOwnership resolves the :id route parameter to an account and
+// enforces access: 400 for a malformed id, 404 for a missing (or deleted)
+// account, 403 when a non-admin does not own it or the request's token is
+// scoped to another account. Handlers behind it read the account with
+// contextAccount instead of loading it again.
+func accountOwnership(s store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid account id")
+			return
+		}
+		if !inTokenScope(c, id) {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "token is limited to another account")
+			return
+		}
+		account, err := s.GetAccount(c.Request.Context(), id)
+		if err != nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
+			return
+		}
+		if !c.GetBool("isAdmin") && account.UserID != c.GetInt64("userID") {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+			return
+		}
+		c.Set(accountKey, account)
+		c.Next()
+	}
+}
+
+// contextAccount returns the account stored by accountOwnership.
+func contextAccount(c *gin.Context) *model.Account {
+	return c.MustGet(accountKey).(*model.Account)
+}
+
+// inTokenScope reports whether the request may act on account id: always,
+// unless it uses a token scoped to another account.
+func inTokenScope(c *gin.Context, id int64) bool {
+	scope := auth.TokenScope(c)
+	return scope == 0 || scope == id
+}
+
+// scopedRoutes are the routes outside /api/accounts/:id a token scoped to one
+// account may use. Both check the scope themselves.
+var scopedRoutes = map[string]bool{
+	"GET /api/accounts": true, // lists only the scoped account
+	"GET /api/ws/logs":  true,
+}
+
+// tokenScope confines tokens scoped to one account to the routes acting on a
+// single account. Routes under /api/accounts/:id enforce the scope through
+// accountOwnership; fleet-wide routes (dashboard, bulk start/stop, users,
+// settings) and creating accounts, cloning included, are refused.
+func tokenScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.TokenScope(c) == 0 {
+			c.Next()
+			return
+		}
+		path := c.FullPath()
+		if (strings.HasPrefix(path, "/api/accounts/:id") && path != "/api/accounts/:id/clone") ||
+			scopedRoutes[c.Request.Method+" "+path] {
+			c.Next()
+			return
+		}
+		apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "token is limited to one account")
+	}
+}
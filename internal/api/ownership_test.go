@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/auth"
+	"qq-farm-bot/internal/bot"
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// testServer is the full API router on a fresh SQLite store.
+type testServer struct {
+	t   *testing.T
+	cfg *config.Config
+	s   *store.SQLStore
+	r   *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWith(t, func(*config.Config) {})
+}
+
+// newTestServerWith is newTestServer with configure applied to the default
+// config before the router is built.
+func newTestServerWith(t *testing.T, configure func(*config.Config)) *testServer {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "farm.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	cfg := config.DefaultConfig()
+	cfg.ConsoleLog = config.ConsoleLogOff
+	configure(cfg)
+	mgr := bot.NewManager(s, cfg)
+	t.Cleanup(mgr.StopAll)
+	return &testServer{t: t, cfg: cfg, s: s, r: SetupRouter(cfg, s, mgr, nil)}
+}
+
+// user creates a user and returns it with a session JWT.
+func (ts *testServer) user(name string, admin bool) (*model.User, string) {
+	ts.t.Helper()
+	u := &model.User{Username: name, PasswordHash: "x", IsAdmin: admin}
+	if err := ts.s.CreateUser(context.Background(), u); err != nil {
+		ts.t.Fatalf("create user: %v", err)
+	}
+	token, err := auth.GenerateToken(ts.cfg.JWTSecret, time.Hour, u.ID, u.Username, u.IsAdmin)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return u, token
+}
+
+func (ts *testServer) account(owner *model.User, name string) *model.Account {
+	ts.t.Helper()
+	a := &model.Account{UserID: owner.ID, Name: name, Platform: "qq"}
+	if err := ts.s.CreateAccount(context.Background(), a); err != nil {
+		ts.t.Fatalf("create account: %v", err)
+	}
+	return a
+}
+
+func (ts *testServer) do(method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	ts.r.ServeHTTP(w, req)
+	return w
+}
+
+// pat creates a personal access token through the API.
+func (ts *testServer) pat(jwt string, accountID int64) (string, *httptest.ResponseRecorder) {
+	body := `{"name":"script","account_id":` + strconv.FormatInt(accountID, 10) + `}`
+	w := ts.do(http.MethodPost, "/api/tokens", jwt, body)
+	var resp struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Token, w
+}
+
+func accountPath(id int64, rest string) string {
+	return "/api/accounts/" + strconv.FormatInt(id, 10) + rest
+}
+
+func TestScopedTokenLimitedToItsAccount(t *testing.T) {
+	ts := newTestServer(t)
+	alice, jwt := ts.user("alice", false)
+	mine := ts.account(alice, "mine")
+	other := ts.account(alice, "other")
+
+	token, w := ts.pat(jwt, mine.ID)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create scoped token = %d: %s", w.Code, w.Body)
+	}
+
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, accountPath(mine.ID, "/status"), http.StatusOK},
+		{http.MethodGet, accountPath(other.ID, "/status"), http.StatusForbidden},
+		{http.MethodGet, accountPath(other.ID, "/logs"), http.StatusForbidden},
+		{http.MethodPost, accountPath(other.ID, "/restore"), http.StatusForbidden},
+		{http.MethodPost, accountPath(mine.ID, "/clone"), http.StatusForbidden},
+		{http.MethodGet, "/api/ws/logs?account_id=" + strconv.FormatInt(other.ID, 10), http.StatusForbidden},
+		{http.MethodGet, "/api/ws/logs?account_id=0", http.StatusForbidden},
+		{http.MethodGet, "/api/dashboard", http.StatusForbidden},
+		{http.MethodPost, "/api/accounts/stop-all", http.StatusForbidden},
+		{http.MethodPost, "/api/accounts", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if w := ts.do(tc.method, tc.path, token, ""); w.Code != tc.want {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, w.Code, tc.want, w.Body)
+		}
+	}
+
+	w = ts.do(http.MethodGet, "/api/accounts", token, "")
+	var list []model.Account
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list accounts = %d: %s", w.Code, w.Body)
+	}
+	if len(list) != 1 || list[0].ID != mine.ID {
+		t.Fatalf("scoped token listed %d accounts, want only account %d", len(list), mine.ID)
+	}
+}
+
+func TestUnscopedTokenSeesAllOwnAccounts(t *testing.T) {
+	ts := newTestServer(t)
+	alice, jwt := ts.user("alice", false)
+	a := ts.account(alice, "a")
+	b := ts.account(alice, "b")
+
+	token, w := ts.pat(jwt, 0)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create token = %d: %s", w.Code, w.Body)
+	}
+	for _, id := range []int64{a.ID, b.ID} {
+		if w := ts.do(http.MethodGet, accountPath(id, "/status"), token, ""); w.Code != http.StatusOK {
+			t.Errorf("status of %d = %d", id, w.Code)
+		}
+	}
+	if w := ts.do(http.MethodGet, "/api/dashboard", token, ""); w.Code != http.StatusOK {
+		t.Errorf("dashboard = %d", w.Code)
+	}
+}
+
+func TestScopedTokenNeedsOwnedAccount(t *testing.T) {
+	ts := newTestServer(t)
+	_, jwt := ts.user("alice", false)
+	bob, _ := ts.user("bob", false)
+	bobs := ts.account(bob, "bob's")
+
+	if _, w := ts.pat(jwt, bobs.ID); w.Code != http.StatusForbidden {
+		t.Fatalf("token scoped to another user's account = %d, want 403", w.Code)
+	}
+	if _, w := ts.pat(jwt, 9999); w.Code != http.StatusNotFound {
+		t.Fatalf("token scoped to a missing account = %d, want 404", w.Code)
+	}
+}
+
+// accountRoutes lists every route acting on one account by id, with :id
+// left for the caller and other parameters filled in.
+func accountRoutes(r *gin.Engine) []gin.RouteInfo {
+	var routes []gin.RouteInfo
+	for _, ri := range r.Routes() {
+		if strings.HasPrefix(ri.Path, "/api/accounts/:id") {
+			ri.Path = strings.ReplaceAll(ri.Path, ":landId", "1")
+			routes = append(routes, ri)
+		}
+	}
+	return routes
+}
+
+func TestEveryAccountRouteChecksOwnership(t *testing.T) {
+	ts := newTestServer(t)
+	alice, _ := ts.user("alice", false)
+	_, bobJWT := ts.user("bob", false)
+	live := ts.account(alice, "live")
+	trashed := ts.account(alice, "trashed")
+	if err := ts.s.DeleteAccount(context.Background(), trashed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := accountRoutes(ts.r)
+	if len(routes) < 20 {
+		t.Fatalf("found only %d /api/accounts/:id routes", len(routes))
+	}
+	for _, ri := range routes {
+		target := live
+		// Restore acts on the recycle bin: live accounts are "not found" there
+		restore := strings.HasSuffix(ri.Path, "/restore")
+		if restore {
+			target = trashed
+		}
+		path := func(id string) string { return strings.Replace(ri.Path, ":id", id, 1) }
+
+		// Another user's account is refused before the handler parses anything
+		w := ts.do(ri.Method, path(strconv.FormatInt(target.ID, 10)), bobJWT, "")
+		var body struct {
+			Code string `json:"code"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != http.StatusForbidden || body.Code != "ACCESS_DENIED" {
+			t.Errorf("%s %s as another user = %d %s, want 403 ACCESS_DENIED", ri.Method, ri.Path, w.Code, w.Body)
+		}
+
+		if w := ts.do(ri.Method, path("9999"), bobJWT, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s for a missing account = %d, want 404", ri.Method, ri.Path, w.Code)
+		}
+		if !restore {
+			if w := ts.do(ri.Method, path("x"), bobJWT, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s with a malformed id = %d, want 400", ri.Method, ri.Path, w.Code)
+			}
+		}
+		if w := ts.do(ri.Method, path(strconv.FormatInt(target.ID, 10)), "", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a token = %d, want 401", ri.Method, ri.Path, w.Code)
+		}
+	}
+
+	// None of the refused requests reached a handler
+	if _, err := ts.s.GetAccount(context.Background(), live.ID); err != nil {
+		t.Fatalf("account changed by refused requests: %v", err)
+	}
+	if _, err := ts.s.GetDeletedAccount(context.Background(), trashed.ID); err != nil {
+		t.Fatalf("trashed account restored by another user: %v", err)
+	}
+}
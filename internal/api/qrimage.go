@@ -0,0 +1,66 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	qrImageTTL        = 5 * time.Minute // scan login codes expire well before this
+	qrImageScale      = 8               // pixels per module
+	qrImageMaxEntries = 256
+)
+
+// qrImageCache keeps rendered QR PNGs per login code for the lifetime of the
+// code, so UI refreshes don't re-encode the image. It holds at most
+// qrImageMaxEntries images, dropping the oldest first.
+type qrImageCache struct {
+	mu      sync.Mutex
+	entries map[string]qrImageEntry
+}
+
+type qrImageEntry struct {
+	png       []byte
+	expiresAt time.Time
+}
+
+func newQRImageCache() *qrImageCache {
+	return &qrImageCache{entries: make(map[string]qrImageEntry)}
+}
+
+func (qc *qrImageCache) get(key string) ([]byte, bool) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	e, ok := qc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(qc.entries, key)
+		return nil, false
+	}
+	return e.png, true
+}
+
+// put stores an image, dropping expired entries and then, when full, the
+// one expiring soonest.
+func (qc *qrImageCache) put(key string, png []byte) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	now := time.Now()
+	for k, e := range qc.entries {
+		if now.After(e.expiresAt) {
+			delete(qc.entries, k)
+		}
+	}
+	if _, ok := qc.entries[key]; !ok && len(qc.entries) >= qrImageMaxEntries {
+		var oldest string
+		for k, e := range qc.entries {
+			if oldest == "" || e.expiresAt.Before(qc.entries[oldest].expiresAt) {
+				oldest = k
+			}
+		}
+		delete(qc.entries, oldest)
+	}
+	qc.entries[key] = qrImageEntry{png: png, expiresAt: now.Add(qrImageTTL)}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestQRImageCacheEviction(t *testing.T) {
+	qc := newQRImageCache()
+	qc.entries["stale"] = qrImageEntry{png: []byte("png"), expiresAt: time.Now().Add(-time.Second)}
+	if _, ok := qc.get("stale"); ok || len(qc.entries) != 0 {
+		t.Fatalf("expired entry served or kept: %d entries", len(qc.entries))
+	}
+
+	for i := range qrImageMaxEntries + 10 {
+		qc.put(fmt.Sprint(i), []byte("png"))
+	}
+	if len(qc.entries) != qrImageMaxEntries {
+		t.Fatalf("cache holds %d images, want %d", len(qc.entries), qrImageMaxEntries)
+	}
+	if _, ok := qc.get(fmt.Sprint(qrImageMaxEntries + 9)); !ok {
+		t.Fatal("newest image evicted")
+	}
+}
+
+func TestQRImageNeedsPendingSession(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.user("alice", false)
+	a := ts.account(alice, "farm")
+
+	w := ts.do(http.MethodGet, accountPath(a.ID, "/qrcode/image?login_code=anything"), token, "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("image of a code without a session = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+)
+
+// rateLimiter keeps a token bucket per key (user or client IP). Buckets
+// refill continuously; idle buckets that have refilled completely carry no
+// state and are swept away.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests per key with
+// bursts of up to burst (perMinute when burst <= 0), or nil when perMinute
+// is 0. A nil limiter allows everything.
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take consumes a token for key. It returns 0 when the request may proceed,
+// otherwise how long until the next token is available.
+func (l *rateLimiter) take(key string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	l.sweep(now)
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// sweep drops buckets that would be full again by now. Caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// middleware rejects requests over the limit with 429 and Retry-After.
+// Streaming endpoints are skipped; streamLimiter caps those instead.
+func (l *rateLimiter) middleware(key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil || isStreamRequest(c) {
+			c.Next()
+			return
+		}
+		if wait := l.take(key(c), time.Now()); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			apierr.Abort(c, http.StatusTooManyRequests, apierr.RateLimited, "too many requests, slow down")
+			return
+		}
+		c.Next()
+	}
+}
+
+// userKey keys the limiter by the authenticated user.
+func userKey(c *gin.Context) string {
+	return "user:" + strconv.FormatInt(c.GetInt64("userID"), 10)
+}
+
+// ipKey keys the limiter by client IP (X-Forwarded-For only from trusted_proxies).
+func ipKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// isStreamRequest reports whether the request opens a long-lived stream.
+func isStreamRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, "/api/ws/")
+}
+
+// streamLimiter caps the concurrent streaming connections of each user.
+type streamLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[int64]int
+}
+
+func newStreamLimiter(max int) *streamLimiter {
+	return &streamLimiter{max: max, active: make(map[int64]int)}
+}
+
+func (l *streamLimiter) acquire(userID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.active[userID] >= l.max {
+		return false
+	}
+	l.active[userID]++
+	return true
+}
+
+func (l *streamLimiter) release(userID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[userID]--; l.active[userID] <= 0 {
+		delete(l.active, userID)
+	}
+}
+
+// middleware holds a slot for the lifetime of each streaming request.
+func (l *streamLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isStreamRequest(c) {
+			c.Next()
+			return
+		}
+		userID := c.GetInt64("userID")
+		if !l.acquire(userID) {
+			apierr.Abort(c, http.StatusTooManyRequests, apierr.RateLimited, "too many open streams")
+			return
+		}
+		defer l.release(userID)
+		c.Next()
+	}
+}
+
+// maxBodySize rejects bodies larger than n with 413. Bodies of unknown
+// length (chunked) are read up front, at most n+1 bytes, so they get the
+// same 413 instead of failing to bind halfway through a handler.
+func maxBodySize(n int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if n <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > n {
+			apierr.Abort(c, http.StatusRequestEntityTooLarge, apierr.BodyTooLarge, "request body too large")
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, n+1))
+			if err != nil {
+				apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "failed to read request body")
+				return
+			}
+			if int64(len(body)) > n {
+				apierr.Abort(c, http.StatusRequestEntityTooLarge, apierr.BodyTooLarge, "request body too large")
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Next()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+		c.Next()
+	}
+}
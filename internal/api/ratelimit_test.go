@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/config"
+)
+
+// errorCode decodes the code of an API error body.
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) apierr.Code {
+	t.Helper()
+	var body struct {
+		Code apierr.Code `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q: %v", w.Body, err)
+	}
+	return body.Code
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60, 2)
+	for i := 0; i < 2; i++ {
+		if wait := l.take("a", now); wait != 0 {
+			t.Fatalf("request %d of the burst waited %v", i+1, wait)
+		}
+	}
+	if wait := l.take("a", now); wait != time.Second {
+		t.Fatalf("over the burst: wait = %v, want 1s", wait)
+	}
+	if wait := l.take("b", now); wait != 0 {
+		t.Fatalf("another key waited %v", wait)
+	}
+	if wait := l.take("a", now.Add(time.Second)); wait != 0 {
+		t.Fatalf("after refilling one token: wait = %v", wait)
+	}
+	if newRateLimiter(0, 10).take("a", now) != 0 {
+		t.Fatal("a disabled limiter limited")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	ts := newTestServerWith(t, func(cfg *config.Config) { cfg.MaxBodyBytes = 64 })
+	small := `{"username":"nobody","password":"wrong"}`
+	large := `{"username":"nobody","password":"` + strings.Repeat("x", 64) + `"}`
+
+	post := func(body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			// Hide the length, as a chunked upload does
+			req.Body = io.NopCloser(strings.NewReader(body))
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		ts.r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, chunked := range []bool{false, true} {
+		w := post(large, chunked)
+		if w.Code != http.StatusRequestEntityTooLarge || errorCode(t, w) != apierr.BodyTooLarge {
+			t.Errorf("chunked=%v: oversized body = %d %s, want 413", chunked, w.Code, w.Body)
+		}
+		// A body within the limit reaches the handler: wrong credentials
+		if w := post(small, chunked); w.Code != http.StatusUnauthorized {
+			t.Errorf("chunked=%v: small body = %d %s, want 401", chunked, w.Code, w.Body)
+		}
+	}
+}
+
+func TestUserRateLimit(t *testing.T) {
+	ts := newTestServerWith(t, func(cfg *config.Config) {
+		cfg.RateLimitPerMinute = 60
+		cfg.RateLimitBurst = 2
+	})
+	_, alice := ts.user("alice", false)
+	_, bob := ts.user("bob", false)
+
+	for i := 0; i < 2; i++ {
+		if w := ts.do(http.MethodGet, "/api/accounts", alice, ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i+1, w.Code)
+		}
+	}
+	w := ts.do(http.MethodGet, "/api/accounts", alice, "")
+	if w.Code != http.StatusTooManyRequests || errorCode(t, w) != apierr.RateLimited {
+		t.Fatalf("over the burst = %d %s, want 429", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Fatalf("Retry-After = %q, want 1", got)
+	}
+	// Buckets are per user
+	if w := ts.do(http.MethodGet, "/api/accounts", bob, ""); w.Code != http.StatusOK {
+		t.Fatalf("another user = %d, want 200", w.Code)
+	}
+}
+
+func TestIPRateLimit(t *testing.T) {
+	ts := newTestServerWith(t, func(cfg *config.Config) { cfg.AuthRateLimitPerMinute = 2 })
+	login := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"nobody","password":"wrong"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":40000"
+		w := httptest.NewRecorder()
+		ts.r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := login("198.51.100.7"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d %s, want 401", i+1, w.Code, w.Body)
+		}
+	}
+	w := login("198.51.100.7")
+	if w.Code != http.StatusTooManyRequests || errorCode(t, w) != apierr.RateLimited {
+		t.Fatalf("over the limit = %d %s, want 429", w.Code, w.Body)
+	}
+	// 2 per minute: the next token is 30s away
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After = %q, want 30", got)
+	}
+	if w := login("198.51.100.8"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("another IP = %d, want 401", w.Code)
+	}
+}
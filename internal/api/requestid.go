@@ -0,0 +1,25 @@
+package api
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+)
+
+// requestIDPattern limits client-supplied ids to something safe to log.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID assigns every request a correlation id, reusing the client's
+// X-Request-ID when it looks sane, and echoes it in the response header.
+// Error responses and the bot log lines of the resulting action carry it.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := c.GetHeader("X-Request-ID"); requestIDPattern.MatchString(id) {
+			c.Set(apierr.RequestIDKey, id)
+		}
+		c.Header("X-Request-ID", apierr.RequestID(c))
+		c.Next()
+	}
+}
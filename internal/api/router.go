@@ -7,50 +7,77 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/auth"
 	"qq-farm-bot/internal/bot"
 	"qq-farm-bot/internal/config"
 	"qq-farm-bot/internal/store"
 )
 
-func SetupRouter(cfg *config.Config, s *store.Store, mgr *bot.Manager, frontendFS fs.FS) *gin.Engine {
+func SetupRouter(cfg *config.Config, s store.Store, mgr *bot.Manager, frontendFS fs.FS) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	r.Use(gin.Recovery())
-	r.SetTrustedProxies(nil)
-
-	// CORS
-	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	if cfg.AccessLog != config.ConsoleLogOff {
+		r.Use(accessLog(cfg.AccessLog))
+	}
+	r.Use(requestID())
+	// Only honor X-Forwarded-For from configured reverse proxies
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		mgr.SystemLogger().Warnf("HTTP", "trusted_proxies 配置无效: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+
+	// Internal API errors are logged with their request id
+	apierr.Logf = func(format string, args ...any) {
+		mgr.SystemLogger().Errorf("HTTP", format, args...)
+	}
+
+	// CORS and WebSocket origin checks share the allowed_origins list
+	origins := newOriginPolicy(cfg.AllowedOrigins)
+	r.Use(origins.middleware())
+	wsUpgrader.CheckOrigin = origins.checkWebSocket
+
+	// Health probes (no auth, for systemd/Kubernetes)
+	RegisterHealthRoutes(r, s, mgr)
 
 	// Public routes
 	api := r.Group("/api")
-	auth.RegisterRoutes(api.Group("/auth"), cfg, s)
+	api.Use(maxBodySize(int64(cfg.MaxBodyBytes)))
+	authGroup := api.Group("/auth")
+	authGroup.Use(newRateLimiter(cfg.AuthRateLimitPerMinute, 0).middleware(ipKey))
+	auth.RegisterRoutes(authGroup, cfg, s)
 
-	// Protected routes
+	// Protected routes: rate limited per user, streams capped per user
 	protected := api.Group("")
-	protected.Use(auth.AuthMiddleware(cfg.JWTSecret))
+	protected.Use(auth.AuthMiddleware(cfg.JWTSecret, s))
+	protected.Use(tokenScope())
+	protected.Use(newRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst).middleware(userKey))
+	protected.Use(newStreamLimiter(cfg.MaxStreamsPerUser).middleware())
 	{
 		RegisterAccountRoutes(protected, s, mgr, cfg)
 		RegisterBotRoutes(protected, s, mgr)
 		RegisterLogRoutes(protected, s, mgr)
+		RegisterActivityRoutes(protected, s)
 		RegisterDashboardRoutes(protected, s, mgr)
 		RegisterStatsRoutes(protected, s, mgr)
 		RegisterDataSummaryRoutes(protected, s, mgr)
+		RegisterHistoryRoutes(protected, s)
+		RegisterUserRoutes(protected, s)
+		RegisterAuditRoutes(protected, s)
+		auth.RegisterTokenRoutes(protected, s)
+		RegisterGameConfigRoutes(protected, s, mgr, cfg)
+		if cfg.DebugEndpointsEnabled {
+			RegisterDebugRoutes(protected, s, mgr)
+		}
 	}
 
 	// External API routes (API key auth: global key or per-account key)
 	external := api.Group("/external")
+	external.Use(newRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst).middleware(ipKey))
 	external.Use(APIKeyMiddleware(cfg.APIKey, s))
 	RegisterExternalRoutes(external, s, mgr)
+	RegisterMetricsRoutes(external, mgr)
 
 	// Serve frontend static files from embedded FS
 	if frontendFS != nil {
@@ -64,7 +91,7 @@ func SetupRouter(cfg *config.Config, s *store.Store, mgr *bot.Manager, frontendF
 		r.NoRoute(func(c *gin.Context) {
 			// API routes return 404 JSON
 			if strings.HasPrefix(c.Request.URL.Path, "/api") {
-				c.JSON(404, gin.H{"error": "not found"})
+				apiNotFound(c)
 				return
 			}
 			// Try to serve static file first (skip "/" to avoid redirect loop)
@@ -80,11 +107,18 @@ func SetupRouter(cfg *config.Config, s *store.Store, mgr *bot.Manager, frontendF
 			// SPA fallback: serve index.html directly (bypasses http.FileServer redirect)
 			c.Data(http.StatusOK, "text/html; charset=utf-8", indexHTML)
 		})
+	} else {
+		r.NoRoute(apiNotFound)
 	}
 
 	return r
 }
 
+// apiNotFound answers unknown routes with the API error envelope.
+func apiNotFound(c *gin.Context) {
+	apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "not found")
+}
+
 // onlyFilesFS wraps http.FileSystem to disable directory listings
 type onlyFilesFS struct {
 	fs http.FileSystem
@@ -1,26 +1,22 @@
 package api
 
 import (
+	"context"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/bot"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
-func RegisterStatsRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
+func RegisterStatsRoutes(r *gin.RouterGroup, s store.Store, mgr *bot.Manager) {
 	// GET /api/accounts/:id/stats?granularity=hour|day|week|all&from=...&to=...
-	r.GET("/accounts/:id/stats", func(c *gin.Context) {
-		idStr := c.Param("id")
-		accountID, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
-			return
-		}
+	r.GET("/accounts/:id/stats", accountOwnership(s), func(c *gin.Context) {
+		accountID := contextAccount(c).ID
 
 		granularity := c.DefaultQuery("granularity", "day")
 		fromStr := c.Query("from")
@@ -45,9 +41,9 @@ func RegisterStatsRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
 		}
 
 		// Get aggregated timeline data
-		timeline, err := s.GetOpStats(accountID, granularity, from, to)
+		timeline, err := s.GetOpStats(c.Request.Context(), accountID, granularity, from, to)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if timeline == nil {
@@ -55,9 +51,9 @@ func RegisterStatsRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
 		}
 
 		// Get overall summary
-		opCounts, totalGoldIn, totalGoldOut, totalExp, err := s.GetOpStatsSummary(accountID)
+		opCounts, totalGoldIn, totalGoldOut, totalExp, err := s.GetOpStatsSummary(c.Request.Context(), accountID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if opCounts == nil {
@@ -109,4 +105,54 @@ func RegisterStatsRoutes(r *gin.RouterGroup, s *store.Store, mgr *bot.Manager) {
 			"started_at":     startedAt,
 		})
 	})
+
+	// GET /api/accounts/:id/spend — gold spent per category against sell
+	// and steal income, for the current game day and the last 7 game days.
+	r.GET("/accounts/:id/spend", accountOwnership(s), func(c *gin.Context) {
+		accountID := contextAccount(c).ID
+		today := bot.GameDayStart(time.Now())
+		result := gin.H{}
+		for period, since := range map[string]time.Time{"today": today, "week": today.AddDate(0, 0, -6)} {
+			p, err := spendPeriod(c.Request.Context(), s, accountID, since)
+			if err != nil {
+				apierr.AbortInternal(c, err)
+				return
+			}
+			result[period] = p
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
+
+// spendSummary is the spend/income breakdown of one period.
+type spendSummary struct {
+	Since       time.Time        `json:"since"`
+	Spend       map[string]int64 `json:"spend"` // category -> gold
+	TotalSpend  int64            `json:"total_spend"`
+	SellIncome  int64            `json:"sell_income"`
+	StealValue  int64            `json:"steal_value"`
+	TotalIncome int64            `json:"total_income"`
+}
+
+func spendPeriod(ctx context.Context, s store.Store, accountID int64, since time.Time) (*spendSummary, error) {
+	spend, err := s.GetSpendTotals(ctx, []int64{accountID}, since)
+	if err != nil {
+		return nil, err
+	}
+	counters, err := s.GetTodayCounters(ctx, []int64{accountID}, since)
+	if err != nil {
+		return nil, err
+	}
+	p := &spendSummary{Since: since, Spend: spend[accountID]}
+	if p.Spend == nil {
+		p.Spend = map[string]int64{}
+	}
+	for _, amount := range p.Spend {
+		p.TotalSpend += amount
+	}
+	if t := counters[accountID]; t != nil {
+		p.SellIncome, p.StealValue = t.SellGold, t.StealValue
+	}
+	p.TotalIncome = p.SellIncome + p.StealValue
+	return p, nil
 }
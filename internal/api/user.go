@@ -0,0 +1,75 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/auth"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+func RegisterUserRoutes(r *gin.RouterGroup, s store.Store) {
+	// POST /users/:id/reset-password - Admin only. Generates a temporary
+	// password which is returned once and never stored in plain text.
+	r.POST("/users/:id/reset-password", func(c *gin.Context) {
+		if !c.GetBool("isAdmin") {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+			return
+		}
+
+		id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+		user, err := s.GetUserByID(c.Request.Context(), id)
+		if err != nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.UserNotFound, "user not found")
+			return
+		}
+
+		tempPass, err := generateTempPassword()
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(tempPass), bcrypt.DefaultCost)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if err := s.UpdateUserPassword(c.Request.Context(), user.ID, string(hash)); err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if err := s.RevokeUserSessions(c.Request.Context(), user.ID); err != nil {
+			apierr.AbortInternal(c, fmt.Errorf("revoke sessions: %w", err))
+			return
+		}
+		// Tokens an attacker minted on a compromised account go too
+		if err := s.DeleteUserAPITokens(c.Request.Context(), user.ID); err != nil {
+			apierr.AbortInternal(c, fmt.Errorf("delete access tokens: %w", err))
+			return
+		}
+		auth.RecordAudit(c, s, model.AuditPasswordReset, 0, "target="+user.Username)
+
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":            user.ID,
+			"username":           user.Username,
+			"temporary_password": tempPass,
+		})
+	})
+}
+
+// generateTempPassword returns a random 12-character hex password.
+func generateTempPassword() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
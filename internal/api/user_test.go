@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestResetPasswordDeletesAccessTokens(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.user("alice", false)
+	_, adminToken := ts.user("root", true)
+
+	w := ts.do(http.MethodPost, "/api/tokens", aliceToken, `{"name":"ci"}`)
+	var created struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create token = %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodGet, "/api/accounts", created.Token, ""); w.Code != http.StatusOK {
+		t.Fatalf("list with the token = %d: %s", w.Code, w.Body)
+	}
+
+	if w := ts.do(http.MethodPost, fmt.Sprintf("/api/users/%d/reset-password", alice.ID), adminToken, ""); w.Code != http.StatusOK {
+		t.Fatalf("reset password = %d: %s", w.Code, w.Body)
+	}
+	if w := ts.do(http.MethodGet, "/api/accounts", created.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("list with the token after a reset = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,90 @@
+// Package apierr defines the JSON error envelope of the HTTP API:
+//
+//	{"code": "ACCOUNT_NOT_FOUND", "message": "account not found", "details": ..., "request_id": "..."}
+//
+// Clients branch on code, which is stable; message is for humans and may
+// change. details is optional (field errors, ...). request_id matches the
+// X-Request-ID response header and the correlation_id of related bot logs.
+package apierr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code is a stable, machine-readable error code.
+type Code string
+
+const (
+	BadRequest        Code = "BAD_REQUEST"
+	ValidationFailed  Code = "VALIDATION_FAILED"
+	Unauthorized      Code = "UNAUTHORIZED"
+	InvalidAPIKey     Code = "INVALID_API_KEY"
+	InvalidCreds      Code = "INVALID_CREDENTIALS"
+	AccessDenied      Code = "ACCESS_DENIED"
+	NotFound          Code = "NOT_FOUND"
+	AccountNotFound   Code = "ACCOUNT_NOT_FOUND"
+	UserNotFound      Code = "USER_NOT_FOUND"
+	UsernameTaken     Code = "USERNAME_TAKEN"
+	QuotaExceeded     Code = "QUOTA_EXCEEDED"
+	NoLoginCode       Code = "NO_LOGIN_CODE"
+	BotAlreadyRunning Code = "BOT_ALREADY_RUNNING"
+	DuplicateCode     Code = "DUPLICATE_LOGIN_CODE"
+	BotStartFailed    Code = "BOT_START_FAILED"
+	BotNotRunning     Code = "BOT_NOT_RUNNING"
+	BotActionFailed   Code = "BOT_ACTION_FAILED"
+	RateLimited       Code = "RATE_LIMITED"
+	BodyTooLarge      Code = "BODY_TOO_LARGE"
+	Internal          Code = "INTERNAL"
+)
+
+// Body is the error envelope of every API error response.
+type Body struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// RequestIDKey is the gin context key holding the request's correlation id.
+const RequestIDKey = "requestID"
+
+// Logf logs internal errors; the router points it at the system logger.
+var Logf = log.Printf
+
+// Abort writes an error response and stops the handler chain.
+func Abort(c *gin.Context, status int, code Code, message string) {
+	AbortDetails(c, status, code, message, nil)
+}
+
+// AbortDetails is Abort with a details payload.
+func AbortDetails(c *gin.Context, status int, code Code, message string, details any) {
+	c.AbortWithStatusJSON(status, Body{Code: code, Message: message, Details: details, RequestID: RequestID(c)})
+}
+
+// AbortInternal logs err server side with the request id and responds 500
+// without it, so database and other internal error text never reaches the
+// client.
+func AbortInternal(c *gin.Context, err error) {
+	id := RequestID(c)
+	Logf("%s %s 内部错误 [%s]: %v", c.Request.Method, c.Request.URL.Path, id, err)
+	Abort(c, http.StatusInternalServerError, Internal, "internal error")
+}
+
+// RequestID returns the request's correlation id, assigning a new one if
+// none was set yet.
+func RequestID(c *gin.Context) string {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return id
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	c.Set(RequestIDKey, id)
+	return id
+}
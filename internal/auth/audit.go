@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// RecordAudit writes an audit row for the authenticated caller. Failures are
+// ignored so auditing never blocks the action itself.
+func RecordAudit(c *gin.Context, s store.Store, action string, accountID int64, summary string) {
+	e := AuditActor(c)
+	e.Action = action
+	e.AccountID = accountID
+	e.Summary = summary
+	s.AddAudit(c.Request.Context(), &e)
+}
+
+// AuditActor returns an audit entry describing the caller, for actions that
+// are recorded later (e.g. a background scan login completing). An admin
+// acting as another user is recorded as the admin; requests made with a
+// personal access token carry its name.
+func AuditActor(c *gin.Context) model.AuditEntry {
+	e := model.AuditEntry{
+		UserID:   c.GetInt64("userID"),
+		Username: c.GetString("username"),
+		IP:       c.ClientIP(),
+	}
+	if adminID := c.GetInt64("impersonatorID"); adminID != 0 {
+		e.UserID = adminID
+		e.Username = c.GetString("impersonator") + " (as " + e.Username + ")"
+	}
+	if name := c.GetString("apiToken"); name != "" {
+		e.Username += " [token: " + name + "]"
+	}
+	return e
+}
@@ -1,11 +1,20 @@
 package auth
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"qq-farm-bot/internal/apierr"
 	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/i18n"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
@@ -15,42 +24,55 @@ type loginReq struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type changePasswordReq struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type localeReq struct {
+	Locale string `json:"locale" binding:"required"`
+}
+
 type registerReq struct {
 	Username string `json:"username" binding:"required,min=3,max=32"`
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-func RegisterRoutes(r *gin.RouterGroup, cfg *config.Config, s *store.Store) {
+func RegisterRoutes(r *gin.RouterGroup, cfg *config.Config, s store.Store) {
 	// POST /auth/register - Open registration
 	r.POST("/register", func(c *gin.Context) {
 		var req registerReq
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: username (3-32 chars) and password (6+ chars) required"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid request: username (3-32 chars) and password (6+ chars) required")
 			return
 		}
 
 		// Check if username already exists
-		exists, err := s.UserExists(req.Username)
+		exists, err := s.UserExists(c.Request.Context(), req.Username)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			apierr.AbortInternal(c, err)
 			return
 		}
 		if exists {
-			c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+			apierr.Abort(c, http.StatusConflict, apierr.UsernameTaken, "username already exists")
 			return
 		}
 
 		// Hash password
 		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "password hashing failed"})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
 		// Check if this is the first user (make admin)
-		hasUsers, err := s.HasAnyUser()
+		hasUsers, err := s.HasAnyUser(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			apierr.AbortInternal(c, err)
 			return
 		}
 
@@ -60,101 +82,196 @@ func RegisterRoutes(r *gin.RouterGroup, cfg *config.Config, s *store.Store) {
 			IsAdmin:      !hasUsers, // First user becomes admin
 		}
 
-		if err := s.CreateUser(user); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		if err := s.CreateUser(c.Request.Context(), user); err != nil {
+			apierr.AbortInternal(c, err)
 			return
 		}
 
-		// Generate token for auto-login
-		token, err := GenerateToken(cfg.JWTSecret, user.ID, user.Username, user.IsAdmin)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
-			return
-		}
-
-		c.JSON(http.StatusCreated, gin.H{
-			"token": token,
-			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"is_admin": user.IsAdmin,
-			},
-		})
+		// Issue tokens for auto-login
+		issueTokens(c, cfg, s, user, http.StatusCreated)
 	})
 
+	throttle := newLoginThrottle(cfg.LoginMaxAttempts, cfg.LoginWindowDuration())
+
 	// POST /auth/login
 	r.POST("/login", func(c *gin.Context) {
 		var req loginReq
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid request")
 			return
 		}
 
+		// ClientIP only honors X-Forwarded-For from trusted_proxies
+		keys := []string{"user:" + req.Username, "ip:" + c.ClientIP()}
+		if wait := throttle.retryAfter(time.Now(), keys...); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			apierr.Abort(c, http.StatusTooManyRequests, apierr.RateLimited, "too many failed login attempts, try again later")
+			return
+		}
+		invalidCredentials := func() {
+			throttle.fail(time.Now(), keys...)
+			apierr.Abort(c, http.StatusUnauthorized, apierr.InvalidCreds, "invalid credentials")
+		}
+
 		// Try database user first
-		user, err := s.GetUserByUsername(req.Username)
+		user, err := s.GetUserByUsername(c.Request.Context(), req.Username)
 		if err == nil {
 			// Verify password
 			if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
-				return
-			}
-
-			token, err := GenerateToken(cfg.JWTSecret, user.ID, user.Username, user.IsAdmin)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+				invalidCredentials()
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{
-				"token": token,
-				"user": gin.H{
-					"id":       user.ID,
-					"username": user.Username,
-					"is_admin": user.IsAdmin,
-				},
-			})
+			throttle.reset(keys...)
+			issueTokens(c, cfg, s, user, http.StatusOK)
 			return
 		}
 
-		// Fallback to config admin (for backwards compatibility)
+		// Fallback to config admin (for backwards compatibility).
+		// Only used to bootstrap the first admin; once any admin exists in the
+		// database the config credentials are no longer accepted.
 		if req.Username == cfg.AdminUser && req.Password == cfg.AdminPass {
-			// Create admin user in database if not exists
-			hash, _ := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-			adminUser := &model.User{
+			hasAdmin, err := s.HasAdminUser(c.Request.Context())
+			if err != nil {
+				apierr.AbortInternal(c, err)
+				return
+			}
+			if hasAdmin {
+				invalidCredentials()
+				return
+			}
+
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				apierr.AbortInternal(c, err)
+				return
+			}
+			user = &model.User{
 				Username:     cfg.AdminUser,
 				PasswordHash: string(hash),
 				IsAdmin:      true,
 			}
-			// Try to create, ignore if exists
-			if err := s.CreateUser(adminUser); err == nil {
-				user = adminUser
-			} else {
-				// User might already exist, fetch it
-				user, _ = s.GetUserByUsername(cfg.AdminUser)
-			}
-
-			if user == nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get/create admin user"})
+			if err := s.CreateUser(c.Request.Context(), user); err != nil {
+				apierr.AbortInternal(c, err)
 				return
 			}
 
-			token, err := GenerateToken(cfg.JWTSecret, user.ID, user.Username, user.IsAdmin)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+			throttle.reset(keys...)
+			issueTokens(c, cfg, s, user, http.StatusOK)
+			return
+		}
+
+		invalidCredentials()
+	})
+
+	// POST /auth/refresh - Exchange a refresh token for a new token pair.
+	// The presented refresh token is rotated (revoked) on success.
+	r.POST("/refresh", func(c *gin.Context) {
+		var req refreshReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid request")
+			return
+		}
+
+		sess, err := s.GetSessionByTokenHash(c.Request.Context(), hashRefreshToken(req.RefreshToken))
+		if err != nil || sess.Revoked || time.Now().After(sess.ExpiresAt) {
+			apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "invalid refresh token")
+			return
+		}
+		user, err := s.GetUserByID(c.Request.Context(), sess.UserID)
+		if err != nil {
+			apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "invalid refresh token")
+			return
+		}
+		// The revoke is conditional: a concurrent refresh with the same
+		// token that revoked it first wins, this one is rejected
+		if err := s.RevokeSession(c.Request.Context(), sess.ID); errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "invalid refresh token")
+			return
+		} else if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+
+		issueTokens(c, cfg, s, user, http.StatusOK)
+	})
+
+	// POST /auth/logout - Revoke a refresh token. Access tokens are stateless
+	// and simply expire after token_ttl.
+	r.POST("/logout", func(c *gin.Context) {
+		var req refreshReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid request")
+			return
+		}
+
+		if sess, err := s.GetSessionByTokenHash(c.Request.Context(), hashRefreshToken(req.RefreshToken)); err == nil {
+			if err := s.RevokeSession(c.Request.Context(), sess.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				apierr.AbortInternal(c, err)
 				return
 			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	})
+
+	// POST /auth/change-password - Change own password (requires a valid token).
+	// All refresh sessions of the user are revoked; access tokens already
+	// issued stay valid until they expire (at most token_ttl).
+	r.POST("/change-password", AuthMiddleware(cfg.JWTSecret, nil), func(c *gin.Context) {
+		var req changePasswordReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid request: current_password and new_password (6+ chars) required")
+			return
+		}
 
-			c.JSON(http.StatusOK, gin.H{
-				"token": token,
-				"user": gin.H{
-					"id":       user.ID,
-					"username": user.Username,
-					"is_admin": user.IsAdmin,
-				},
-			})
+		user, err := s.GetUserByID(c.Request.Context(), c.GetInt64("userID"))
+		if err != nil {
+			apierr.Abort(c, http.StatusNotFound, apierr.UserNotFound, "user not found")
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+			apierr.Abort(c, http.StatusUnauthorized, apierr.InvalidCreds, "current password is incorrect")
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if err := s.UpdateUserPassword(c.Request.Context(), user.ID, string(hash)); err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		// Old sessions and access tokens must not outlive the password; the
+		// client can retry
+		if err := s.RevokeUserSessions(c.Request.Context(), user.ID); err != nil {
+			apierr.AbortInternal(c, fmt.Errorf("revoke sessions: %w", err))
+			return
+		}
+		if err := s.DeleteUserAPITokens(c.Request.Context(), user.ID); err != nil {
+			apierr.AbortInternal(c, fmt.Errorf("delete access tokens: %w", err))
 			return
 		}
+		RecordAudit(c, s, model.AuditPasswordChange, 0, "")
+
+		c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+	})
 
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	// PUT /auth/locale - Language the log endpoints translate known lines to
+	r.PUT("/locale", AuthMiddleware(cfg.JWTSecret, nil), func(c *gin.Context) {
+		var req localeReq
+		if err := c.ShouldBindJSON(&req); err != nil || !i18n.Valid(req.Locale) {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, fmt.Sprintf("locale must be one of %s", strings.Join(i18n.Locales, ", ")))
+			return
+		}
+		if err := s.UpdateUserLocale(c.Request.Context(), c.GetInt64("userID"), req.Locale); errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, http.StatusNotFound, apierr.UserNotFound, "user not found")
+			return
+		} else if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"locale": req.Locale})
 	})
 }
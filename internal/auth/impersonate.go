@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// ActAsHeader lets an admin view the API as another user. The query
+// parameter act_as does the same for WebSocket connections.
+const ActAsHeader = "X-Act-As-User"
+
+// impersonationAuditGap is how long an admin may keep viewing as the same
+// user before another audit row is written, so polling doesn't flood the log.
+const impersonationAuditGap = 30 * time.Minute
+
+// impersonationAudit remembers when each (admin, target) pair was last audited.
+type impersonationAudit struct {
+	mu   sync.Mutex
+	last map[[2]int64]time.Time
+}
+
+func (a *impersonationAudit) due(adminID, targetID int64, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := [2]int64{adminID, targetID}
+	if now.Sub(a.last[key]) < impersonationAuditGap {
+		return false
+	}
+	for k, t := range a.last {
+		if now.Sub(t) >= impersonationAuditGap {
+			delete(a.last, k)
+		}
+	}
+	a.last[key] = now
+	return true
+}
+
+// actAs switches an admin request to the identity of the user named by
+// X-Act-As-User (or ?act_as=). Impersonation is read-only: only GET and
+// HEAD requests are allowed, so an admin can see exactly what the user
+// sees without acting in their name. It reports whether the request may
+// continue.
+func actAs(c *gin.Context, s store.Store, audit *impersonationAudit) bool {
+	raw := c.GetHeader(ActAsHeader)
+	if raw == "" {
+		raw = c.Query("act_as")
+	}
+	if raw == "" {
+		return true
+	}
+	if !c.GetBool("isAdmin") {
+		apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "only admins may act as another user")
+		return false
+	}
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "acting as another user is read-only")
+		return false
+	}
+	targetID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || targetID <= 0 {
+		apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid "+ActAsHeader)
+		return false
+	}
+	adminID := c.GetInt64("userID")
+	if targetID == adminID {
+		return true
+	}
+	target, err := s.GetUserByID(c.Request.Context(), targetID)
+	if err != nil {
+		apierr.Abort(c, http.StatusNotFound, apierr.UserNotFound, "user not found")
+		return false
+	}
+
+	if audit.due(adminID, targetID, time.Now()) {
+		RecordAudit(c, s, model.AuditImpersonate, 0, "target="+target.Username)
+	}
+	c.Set("impersonatorID", adminID)
+	c.Set("impersonator", c.GetString("username"))
+	c.Set("userID", target.ID)
+	c.Set("username", target.Username)
+	c.Set("isAdmin", false)
+	c.Header(ActAsHeader, strconv.FormatInt(target.ID, 10))
+	return true
+}
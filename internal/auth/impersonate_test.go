@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+const testSecret = "test-secret"
+
+// whoami is the identity a handler behind AuthMiddleware sees.
+type whoami struct {
+	UserID       int64 `json:"user_id"`
+	IsAdmin      bool  `json:"is_admin"`
+	Impersonator int64 `json:"impersonator"`
+}
+
+// impersonationRouter serves GET and POST /whoami behind AuthMiddleware.
+func impersonationRouter(s store.Store) *gin.Engine {
+	r := gin.New()
+	g := r.Group("", AuthMiddleware(testSecret, s))
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, whoami{c.GetInt64("userID"), c.GetBool("isAdmin"), c.GetInt64("impersonatorID")})
+	}
+	g.GET("/whoami", handler)
+	g.POST("/whoami", handler)
+	return r
+}
+
+// bearer creates a user and returns it with an access token.
+func bearer(t *testing.T, s store.Store, name string, admin bool) (*model.User, string) {
+	t.Helper()
+	u := &model.User{Username: name, PasswordHash: "x", IsAdmin: admin}
+	if err := s.CreateUser(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	token, err := GenerateToken(testSecret, time.Hour, u.ID, u.Username, u.IsAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, token
+}
+
+func TestActAs(t *testing.T) {
+	s := newTestStore(t)
+	r := impersonationRouter(s)
+	admin, adminToken := bearer(t, s, "root", true)
+	bob, bobToken := bearer(t, s, "bob", false)
+	carol, _ := bearer(t, s, "carol", false)
+
+	id := func(u *model.User) string { return strconv.FormatInt(u.ID, 10) }
+	cases := []struct {
+		name          string
+		method, token string
+		header, query string // X-Act-As-User, ?act_as=
+		status        int
+		code          apierr.Code
+		want          whoami
+	}{
+		{name: "no header", method: http.MethodGet, token: adminToken,
+			status: http.StatusOK, want: whoami{UserID: admin.ID, IsAdmin: true}},
+		{name: "admin acts as user", method: http.MethodGet, token: adminToken, header: id(bob),
+			status: http.StatusOK, want: whoami{UserID: bob.ID, Impersonator: admin.ID}},
+		{name: "admin acts as user by query", method: http.MethodGet, token: adminToken, query: id(bob),
+			status: http.StatusOK, want: whoami{UserID: bob.ID, Impersonator: admin.ID}},
+		{name: "admin acts as self", method: http.MethodGet, token: adminToken, header: id(admin),
+			status: http.StatusOK, want: whoami{UserID: admin.ID, IsAdmin: true}},
+		{name: "non-admin header", method: http.MethodGet, token: bobToken, header: id(carol),
+			status: http.StatusForbidden, code: apierr.AccessDenied},
+		{name: "non-admin query", method: http.MethodGet, token: bobToken, query: id(admin),
+			status: http.StatusForbidden, code: apierr.AccessDenied},
+		{name: "non-admin as self", method: http.MethodGet, token: bobToken, header: id(bob),
+			status: http.StatusForbidden, code: apierr.AccessDenied},
+		{name: "admin write", method: http.MethodPost, token: adminToken, header: id(bob),
+			status: http.StatusForbidden, code: apierr.AccessDenied},
+		{name: "admin write by query", method: http.MethodPost, token: adminToken, query: id(bob),
+			status: http.StatusForbidden, code: apierr.AccessDenied},
+		{name: "malformed id", method: http.MethodGet, token: adminToken, header: "bob",
+			status: http.StatusBadRequest, code: apierr.BadRequest},
+		{name: "missing user", method: http.MethodGet, token: adminToken, header: "999",
+			status: http.StatusNotFound, code: apierr.UserNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := "/whoami"
+			if tc.query != "" {
+				path += "?act_as=" + tc.query
+			}
+			req := httptest.NewRequest(tc.method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			if tc.header != "" {
+				req.Header.Set(ActAsHeader, tc.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body)
+			}
+			if tc.status != http.StatusOK {
+				var body apierr.Body
+				if json.Unmarshal(w.Body.Bytes(), &body); body.Code != tc.code {
+					t.Fatalf("code = %q, want %q", body.Code, tc.code)
+				}
+				return
+			}
+			var got whoami
+			json.Unmarshal(w.Body.Bytes(), &got)
+			if got != tc.want {
+				t.Fatalf("handler saw %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	// Viewing as bob twice within the gap is audited once, as the admin
+	entries, _, err := s.ListAudit(context.Background(), 0, model.AuditImpersonate, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UserID != admin.ID || entries[0].Summary != "target=bob" {
+		t.Fatalf("impersonation audit = %+v, want one row by the admin", entries)
+	}
+}
@@ -3,11 +3,19 @@ package auth
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/store"
 )
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// AuthMiddleware validates the access token. With a store, personal access
+// tokens (pat_...) are accepted too and admins may view the API as another
+// user via X-Act-As-User (see actAs); pass nil to allow neither.
+func AuthMiddleware(secret string, s store.Store) gin.HandlerFunc {
+	audit := &impersonationAudit{last: make(map[[2]int64]time.Time)}
 	return func(c *gin.Context) {
 		// Try Authorization header first
 		tokenStr := ""
@@ -19,17 +27,28 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 			tokenStr = c.Query("token")
 		}
 		if tokenStr == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+			apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "missing token")
 			return
 		}
-		claims, err := ValidateToken(secret, tokenStr)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		if s != nil && strings.HasPrefix(tokenStr, TokenPrefix) {
+			if !tokenAuth(c, s, tokenStr) {
+				return
+			}
+		} else {
+			claims, err := ValidateToken(secret, tokenStr)
+			if err != nil {
+				apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "invalid token")
+				return
+			}
+			c.Set("userID", claims.UserID)
+			c.Set("username", claims.Username)
+			c.Set("isAdmin", claims.IsAdmin)
+			// Long-lived connections (WebSocket) use this to close once the token expires
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
+		if s != nil && !actAs(c, s, audit) {
 			return
 		}
-		c.Set("userID", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("isAdmin", claims.IsAdmin)
 		c.Next()
 	}
 }
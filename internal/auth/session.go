@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// newRefreshToken returns a random opaque refresh token and its SHA-256 hash.
+// Only the hash is persisted.
+func newRefreshToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens creates a new refresh-token session for the user and writes the
+// access/refresh token pair as the response.
+func issueTokens(c *gin.Context, cfg *config.Config, s store.Store, user *model.User, status int) {
+	accessTTL := cfg.AccessTokenTTL()
+	token, err := GenerateToken(cfg.JWTSecret, accessTTL, user.ID, user.Username, user.IsAdmin)
+	if err != nil {
+		apierr.AbortInternal(c, err)
+		return
+	}
+
+	refresh, hash, err := newRefreshToken()
+	if err != nil {
+		apierr.AbortInternal(c, err)
+		return
+	}
+	sess := &model.Session{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(cfg.RefreshTokenTTL()),
+	}
+	if err := s.CreateSession(c.Request.Context(), sess); err != nil {
+		apierr.AbortInternal(c, err)
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"token":         token,
+		"expires_in":    int64(accessTTL.Seconds()),
+		"refresh_token": refresh,
+		"user": gin.H{
+			"id":       user.ID,
+			"username": user.Username,
+			"is_admin": user.IsAdmin,
+			"locale":   user.Locale,
+		},
+	})
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestStore opens a fresh SQLite store in a temp dir.
+func newTestStore(t *testing.T) *store.SQLStore {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "farm.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newTestUser creates a user and a refresh session for it, returning the
+// user and the plain refresh token.
+func newTestUser(t *testing.T, s store.Store) (*model.User, string) {
+	t.Helper()
+	ctx := context.Background()
+	u := &model.User{Username: "alice", PasswordHash: "x"}
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	refresh, hash, err := newRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := &model.Session{UserID: u.ID, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return u, refresh
+}
+
+func newAuthRouter(cfg *config.Config, s store.Store) *gin.Engine {
+	r := gin.New()
+	RegisterRoutes(r.Group("/auth"), cfg, s)
+	return r
+}
+
+func postJSON(r http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s := newTestStore(t)
+	_, refresh := newTestUser(t, s)
+	r := newAuthRouter(config.DefaultConfig(), s)
+	body := `{"refresh_token":"` + refresh + `"}`
+
+	if w := postJSON(r, "/auth/refresh", body); w.Code != http.StatusOK {
+		t.Fatalf("first refresh = %d: %s", w.Code, w.Body)
+	}
+	if w := postJSON(r, "/auth/refresh", body); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh = %d, want 401", w.Code)
+	}
+	if w := postJSON(r, "/auth/logout", body); w.Code != http.StatusOK {
+		t.Fatalf("logout with a revoked token = %d, want 200", w.Code)
+	}
+}
+
+// lookupBarrier holds every session lookup until all n requests have read
+// the session, so each of them sees it unrevoked.
+type lookupBarrier struct {
+	store.Store
+	read sync.WaitGroup
+}
+
+func (b *lookupBarrier) GetSessionByTokenHash(ctx context.Context, hash string) (*model.Session, error) {
+	sess, err := b.Store.GetSessionByTokenHash(ctx, hash)
+	b.read.Done()
+	b.read.Wait()
+	return sess, err
+}
+
+func TestConcurrentRefreshOnlyOneWins(t *testing.T) {
+	s := newTestStore(t)
+	_, refresh := newTestUser(t, s)
+	const n = 4
+	b := &lookupBarrier{Store: s}
+	b.read.Add(n)
+	r := newAuthRouter(config.DefaultConfig(), b)
+	body := `{"refresh_token":"` + refresh + `"}`
+
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- postJSON(r, "/auth/refresh", body).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	ok := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusUnauthorized:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d refreshes succeeded with one token, want 1", ok)
+	}
+}
+
+// revokeFails can't revoke sessions, as a database gone read-only couldn't.
+type revokeFails struct{ store.Store }
+
+func (revokeFails) RevokeUserSessions(context.Context, int64) error {
+	return errors.New("attempt to write a readonly database")
+}
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		wrap   func(store.Store) store.Store
+		status int
+	}{
+		{"revoked", func(s store.Store) store.Store { return s }, http.StatusOK},
+		{"revoke fails", func(s store.Store) store.Store { return revokeFails{s} }, http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestStore(t)
+			u, refresh := newTestUser(t, s)
+			hash, _ := bcrypt.GenerateFromPassword([]byte("old-secret"), bcrypt.MinCost)
+			s.UpdateUserPassword(context.Background(), u.ID, string(hash))
+			pat, patHash, err := newAPIToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreateAPIToken(context.Background(), &model.APIToken{UserID: u.ID, Name: "ci", TokenHash: patHash, Prefix: pat[:12]}); err != nil {
+				t.Fatal(err)
+			}
+			cfg := config.DefaultConfig()
+			token, err := GenerateToken(cfg.JWTSecret, time.Hour, u.ID, u.Username, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := newAuthRouter(cfg, tc.wrap(s))
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/change-password",
+				strings.NewReader(`{"current_password":"old-secret","new_password":"new-secret"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("change-password = %d, want %d: %s", w.Code, tc.status, w.Body)
+			}
+			if tc.status == http.StatusOK {
+				if w := postJSON(r, "/auth/refresh", `{"refresh_token":"`+refresh+`"}`); w.Code != http.StatusUnauthorized {
+					t.Fatalf("refresh after a password change = %d, want 401", w.Code)
+				}
+				if _, err := s.GetAPITokenByHash(context.Background(), patHash); err == nil {
+					t.Fatal("access token still valid after a password change")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// loginThrottle tracks failed login attempts per key (username or client IP)
+// in a sliding window. Keys whose attempts all fall out of the window decay
+// away on their own.
+type loginThrottle struct {
+	mu        sync.Mutex
+	max       int
+	window    time.Duration
+	failures  map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newLoginThrottle(max int, window time.Duration) *loginThrottle {
+	return &loginThrottle{
+		max:      max,
+		window:   window,
+		failures: make(map[string][]time.Time),
+	}
+}
+
+// retryAfter returns how long the caller must wait before another attempt is
+// allowed for any of the keys, or 0 if none of them is locked.
+func (t *loginThrottle) retryAfter(now time.Time, keys ...string) time.Duration {
+	if t.max <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wait time.Duration
+	for _, key := range keys {
+		attempts := t.prune(key, now)
+		if len(attempts) < t.max {
+			continue
+		}
+		// Locked until enough of the oldest failures leave the window
+		oldest := attempts[len(attempts)-t.max]
+		if d := oldest.Add(t.window).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// fail records a failed attempt for each key.
+func (t *loginThrottle) fail(now time.Time, keys ...string) {
+	if t.max <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range keys {
+		t.failures[key] = append(t.prune(key, now), now)
+	}
+	if now.Sub(t.lastSweep) > t.window {
+		for key := range t.failures {
+			t.prune(key, now)
+		}
+		t.lastSweep = now
+	}
+}
+
+// reset clears the recorded failures for each key.
+func (t *loginThrottle) reset(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		delete(t.failures, key)
+	}
+}
+
+// prune drops attempts older than the window. Caller must hold t.mu.
+func (t *loginThrottle) prune(key string, now time.Time) []time.Time {
+	attempts := t.failures[key]
+	cutoff := now.Add(-t.window)
+	i := 0
+	for i < len(attempts) && !attempts[i].After(cutoff) {
+		i++
+	}
+	attempts = attempts[i:]
+	if len(attempts) == 0 {
+		delete(t.failures, key)
+		return nil
+	}
+	t.failures[key] = attempts
+	return attempts
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"qq-farm-bot/internal/config"
+)
+
+var t0 = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+func TestLoginThrottleWindow(t *testing.T) {
+	th := newLoginThrottle(3, time.Minute)
+	th.fail(t0, "user:a")
+	th.fail(t0.Add(10*time.Second), "user:a")
+	if d := th.retryAfter(t0.Add(20*time.Second), "user:a"); d != 0 {
+		t.Fatalf("locked after 2 of 3 failures: %v", d)
+	}
+	th.fail(t0.Add(20*time.Second), "user:a")
+
+	cases := []struct {
+		at   time.Duration
+		want time.Duration
+	}{
+		{20 * time.Second, 40 * time.Second}, // until the first failure leaves the window
+		{59 * time.Second, time.Second},
+		{time.Minute, 0},
+		{2 * time.Minute, 0},
+	}
+	for _, tc := range cases {
+		if got := th.retryAfter(t0.Add(tc.at), "user:a"); got != tc.want {
+			t.Errorf("retryAfter at +%v = %v, want %v", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestLoginThrottleLockoutExpiresThenRelocks(t *testing.T) {
+	th := newLoginThrottle(2, time.Minute)
+	th.fail(t0, "ip:1")
+	th.fail(t0, "ip:1")
+	if d := th.retryAfter(t0, "ip:1"); d != time.Minute {
+		t.Fatalf("retryAfter = %v, want 1m", d)
+	}
+
+	// Once expired a single new failure doesn't lock again
+	later := t0.Add(time.Minute)
+	th.fail(later, "ip:1")
+	if d := th.retryAfter(later, "ip:1"); d != 0 {
+		t.Fatalf("locked by one failure after expiry: %v", d)
+	}
+	th.fail(later.Add(time.Second), "ip:1")
+	if d := th.retryAfter(later.Add(time.Second), "ip:1"); d != time.Minute-time.Second {
+		t.Fatalf("retryAfter = %v, want 59s", d)
+	}
+}
+
+func TestLoginThrottleKeys(t *testing.T) {
+	th := newLoginThrottle(1, time.Minute)
+	th.fail(t0, "user:a", "ip:1")
+
+	if d := th.retryAfter(t0, "user:b", "ip:2"); d != 0 {
+		t.Fatalf("unrelated keys locked: %v", d)
+	}
+	// Either locked key locks the attempt
+	if d := th.retryAfter(t0, "user:b", "ip:1"); d == 0 {
+		t.Fatal("locked ip not enforced")
+	}
+	th.reset("user:a", "ip:1")
+	if d := th.retryAfter(t0, "user:a", "ip:1"); d != 0 {
+		t.Fatalf("locked after reset: %v", d)
+	}
+}
+
+func TestLoginThrottleDisabledAndSweep(t *testing.T) {
+	off := newLoginThrottle(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		off.fail(t0, "user:a")
+	}
+	if d := off.retryAfter(t0, "user:a"); d != 0 {
+		t.Fatalf("disabled throttle locked: %v", d)
+	}
+
+	th := newLoginThrottle(5, time.Minute)
+	th.fail(t0, "user:stale")
+	th.fail(t0.Add(2*time.Minute), "user:fresh")
+	th.mu.Lock()
+	_, stale := th.failures["user:stale"]
+	th.mu.Unlock()
+	if stale {
+		t.Fatal("stale key not swept")
+	}
+}
+
+// loginRouter serves /auth/login behind the given trusted proxies with a
+// lockout after two failures.
+func loginRouter(t *testing.T, proxies []string) *gin.Engine {
+	cfg := config.DefaultConfig()
+	cfg.LoginMaxAttempts = 2
+	r := gin.New()
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		t.Fatal(err)
+	}
+	RegisterRoutes(r.Group("/auth"), cfg, newTestStore(t))
+	return r
+}
+
+// badLogin posts wrong credentials for user from peer, claiming to be
+// forwarded for xff when set.
+func badLogin(r http.Handler, user, peer, xff string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/auth/login",
+		strings.NewReader(`{"username":"`+user+`","password":"wrong-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = peer + ":40000"
+	if xff != "" {
+		req.Header.Set("X-Forwarded-For", xff)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginLockoutReturns429(t *testing.T) {
+	r := loginRouter(t, nil)
+	for i := 0; i < 2; i++ {
+		if w := badLogin(r, "alice", "203.0.113.1", ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d, want 401", i+1, w.Code)
+		}
+	}
+	w := badLogin(r, "alice", "203.0.113.1", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third attempt = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("429 without Retry-After")
+	}
+}
+
+func TestLoginIgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	r := loginRouter(t, nil)
+	// A spoofed header per attempt must not dodge the per-IP lockout
+	badLogin(r, "u1", "203.0.113.1", "198.51.100.1")
+	badLogin(r, "u2", "203.0.113.1", "198.51.100.2")
+	if w := badLogin(r, "u3", "203.0.113.1", "198.51.100.3"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt from a locked peer = %d, want 429", w.Code)
+	}
+}
+
+func TestLoginHonorsForwardedForFromTrustedProxy(t *testing.T) {
+	r := loginRouter(t, []string{"10.0.0.1"})
+	// Behind the proxy each client has its own lockout
+	badLogin(r, "u1", "10.0.0.1", "198.51.100.1")
+	badLogin(r, "u2", "10.0.0.1", "198.51.100.1")
+	if w := badLogin(r, "u3", "10.0.0.1", "198.51.100.1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked client = %d, want 429", w.Code)
+	}
+	if w := badLogin(r, "u4", "10.0.0.1", "198.51.100.2"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("other client behind the proxy = %d, want 401", w.Code)
+	}
+}
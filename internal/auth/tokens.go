@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"qq-farm-bot/internal/apierr"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// TokenPrefix marks personal access tokens in the Authorization header, so
+// the middleware can tell them from JWTs without trying both.
+const TokenPrefix = "pat_"
+
+const (
+	maxTokensPerUser = 20
+	maxTokenNameLen  = 64
+	// tokenTouchGap throttles last_used_at writes for busy scripts
+	tokenTouchGap = time.Minute
+	// tokenStreamLimit caps how long a stream opened with a token stays up,
+	// since revocation is only checked when a request starts
+	tokenStreamLimit = time.Hour
+)
+
+// newAPIToken returns a random token secret and its SHA-256 hash.
+func newAPIToken() (secret, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret = TokenPrefix + hex.EncodeToString(buf)
+	return secret, hashRefreshToken(secret), nil
+}
+
+// tokenAccountKey is the gin context key of a token's account scope.
+const tokenAccountKey = "tokenAccountID"
+
+// TokenScope returns the only account the request's token may act on, or 0
+// when the request is not limited to one account.
+func TokenScope(c *gin.Context) int64 {
+	return c.GetInt64(tokenAccountKey)
+}
+
+// tokenAuth authenticates a personal access token, setting the same context
+// keys as a JWT plus "apiToken" (the token name) and, for a token scoped to
+// one account, its id. It aborts the request and returns false when the
+// token is unknown, expired or its user is gone.
+func tokenAuth(c *gin.Context, s store.Store, secret string) bool {
+	ctx := c.Request.Context()
+	t, err := s.GetAPITokenByHash(ctx, hashRefreshToken(secret))
+	if err != nil {
+		apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "invalid token")
+		return false
+	}
+	now := time.Now()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "token expired")
+		return false
+	}
+	user, err := s.GetUserByID(ctx, t.UserID)
+	if err != nil {
+		apierr.Abort(c, http.StatusUnauthorized, apierr.Unauthorized, "invalid token")
+		return false
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= tokenTouchGap {
+		s.TouchAPIToken(ctx, t.ID, now)
+	}
+
+	expires := now.Add(tokenStreamLimit)
+	if t.ExpiresAt != nil && t.ExpiresAt.Before(expires) {
+		expires = *t.ExpiresAt
+	}
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	c.Set("isAdmin", user.IsAdmin)
+	c.Set("tokenExpiresAt", expires)
+	c.Set("apiToken", t.Name)
+	if t.AccountID != 0 {
+		c.Set(tokenAccountKey, t.AccountID)
+	}
+	return true
+}
+
+type createTokenReq struct {
+	Name          string `json:"name" binding:"required"`
+	ExpiresInDays int    `json:"expires_in_days"` // 0 never expires
+	AccountID     int64  `json:"account_id"`      // 0 all of the user's accounts
+}
+
+// RegisterTokenRoutes adds personal access token management for the caller.
+// Tokens can't manage tokens, so a leaked one can't mint longer-lived ones.
+func RegisterTokenRoutes(r *gin.RouterGroup, s store.Store) {
+	sessionOnly := func(c *gin.Context) {
+		if c.GetString("apiToken") != "" {
+			apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "API tokens can't manage tokens")
+			return
+		}
+		c.Next()
+	}
+
+	// GET /api/tokens - list the caller's tokens (never the secrets)
+	r.GET("/tokens", sessionOnly, func(c *gin.Context) {
+		tokens, err := s.ListAPITokens(c.Request.Context(), c.GetInt64("userID"))
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if tokens == nil {
+			tokens = []model.APIToken{}
+		}
+		c.JSON(http.StatusOK, tokens)
+	})
+
+	// POST /api/tokens - create a token; the secret is only returned here
+	r.POST("/tokens", sessionOnly, func(c *gin.Context) {
+		var req createTokenReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid request: name required")
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len([]rune(req.Name)) > maxTokenNameLen || req.ExpiresInDays < 0 || req.AccountID < 0 {
+			apierr.Abort(c, http.StatusUnprocessableEntity, apierr.ValidationFailed,
+				"name must be 1-"+strconv.Itoa(maxTokenNameLen)+" characters, expires_in_days and account_id not negative")
+			return
+		}
+		userID := c.GetInt64("userID")
+		if req.AccountID != 0 {
+			account, err := s.GetAccount(c.Request.Context(), req.AccountID)
+			if err != nil {
+				apierr.Abort(c, http.StatusNotFound, apierr.AccountNotFound, "account not found")
+				return
+			}
+			if !c.GetBool("isAdmin") && account.UserID != userID {
+				apierr.Abort(c, http.StatusForbidden, apierr.AccessDenied, "access denied")
+				return
+			}
+		}
+		existing, err := s.ListAPITokens(c.Request.Context(), userID)
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		if len(existing) >= maxTokensPerUser {
+			apierr.Abort(c, http.StatusForbidden, apierr.QuotaExceeded, "token limit reached ("+strconv.Itoa(maxTokensPerUser)+")")
+			return
+		}
+
+		secret, hash, err := newAPIToken()
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		t := &model.APIToken{
+			UserID:    userID,
+			Name:      req.Name,
+			TokenHash: hash,
+			Prefix:    secret[:len(TokenPrefix)+8],
+			AccountID: req.AccountID,
+		}
+		if req.ExpiresInDays > 0 {
+			expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			t.ExpiresAt = &expires
+		}
+		if err := s.CreateAPIToken(c.Request.Context(), t); err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		RecordAudit(c, s, model.AuditTokenCreate, t.AccountID, t.Name)
+		c.JSON(http.StatusCreated, gin.H{"token": secret, "info": t})
+	})
+
+	// DELETE /api/tokens/:id - revoke one of the caller's tokens
+	r.DELETE("/tokens/:id", sessionOnly, func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			apierr.Abort(c, http.StatusBadRequest, apierr.BadRequest, "invalid id")
+			return
+		}
+		err = s.DeleteAPIToken(c.Request.Context(), c.GetInt64("userID"), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, http.StatusNotFound, apierr.NotFound, "token not found")
+			return
+		}
+		if err != nil {
+			apierr.AbortInternal(c, err)
+			return
+		}
+		RecordAudit(c, s, model.AuditTokenRevoke, 0, "#"+strconv.FormatInt(id, 10))
+		c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+	})
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/internal/model"
+
+	"qq-farm-bot/proto/corepb"
+	"qq-farm-bot/proto/itempb"
+)
+
+// bagRefreshInterval is the minimum gap between Bag requests made only to
+// keep BagCache fresh.
+const bagRefreshInterval = time.Minute
+
+// BagCache stores the latest bag contents for the panel.
+type BagCache struct {
+	mu        sync.RWMutex
+	items     []model.BagItem
+	fetchedAt time.Time
+}
+
+func NewBagCache() *BagCache {
+	return &BagCache{}
+}
+
+// Update replaces the cached bag with items, resolving names and categories.
+func (bc *BagCache) Update(items []*corepb.Item, gc *GameConfig) {
+	if bc == nil {
+		return
+	}
+	out := make([]model.BagItem, 0, len(items))
+	for _, item := range items {
+		if item == nil || item.Count <= 0 {
+			continue
+		}
+		id := int(item.Id)
+		out = append(out, model.BagItem{
+			ID:       item.Id,
+			Name:     gc.GetItemName(id),
+			Count:    item.Count,
+			Category: classifyBagItem(gc, item.Id),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Category != out[j].Category {
+			return out[i].Category < out[j].Category
+		}
+		return out[i].ID < out[j].ID
+	})
+	bc.mu.Lock()
+	bc.items = out
+	bc.fetchedAt = time.Now()
+	bc.mu.Unlock()
+}
+
+// Snapshot returns a copy of the cached bag; FetchedAt is zero until the
+// first fetch.
+func (bc *BagCache) Snapshot() model.BagSnapshot {
+	if bc == nil {
+		return model.BagSnapshot{}
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	items := make([]model.BagItem, len(bc.items))
+	copy(items, bc.items)
+	return model.BagSnapshot{Items: items, FetchedAt: bc.fetchedAt}
+}
+
+// fresh reports whether the cache was updated within bagRefreshInterval.
+func (bc *BagCache) fresh() bool {
+	if bc == nil {
+		return false
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return time.Since(bc.fetchedAt) < bagRefreshInterval
+}
+
+// classifyBagItem sorts an item into a bag category.
+func classifyBagItem(gc *GameConfig, id int64) string {
+	switch id {
+	case 1, 1001, couponItemID:
+		return model.BagCurrency
+	case normalContainerID, organicContainerID, fertilizerPackID1, fertilizerPackID2,
+		normalFertilizer1h, normalFertilizer4h, normalFertilizer8h, normalFertilizer12h,
+		organicFertilizer1h, organicFertilizer4h, organicFertilizer8h, organicFertilizer12h:
+		return model.BagFertilizer
+	}
+	if gc.IsFruitID(int(id)) {
+		return model.BagFruit
+	}
+	if gc.IsSeedID(int(id)) {
+		return model.BagSeed
+	}
+	return model.BagOther
+}
+
+// fetchBag requests the bag and refreshes bc with the result.
+func fetchBag(net *Network, bc *BagCache, gc *GameConfig) ([]*corepb.Item, error) {
+	body, _ := proto.Marshal(&itempb.BagRequest{})
+	replyBody, err := net.SendRequest("gamepb.itempb.ItemService", "Bag", body)
+	if err != nil {
+		return nil, err
+	}
+	reply := &itempb.BagReply{}
+	if err := proto.Unmarshal(replyBody, reply); err != nil {
+		return nil, err
+	}
+	var items []*corepb.Item
+	if reply.ItemBag != nil {
+		items = reply.ItemBag.Items
+	}
+	bc.Update(items, gc)
+	net.state.SetCoupons(getItemCount(items, couponItemID))
+	return items, nil
+}
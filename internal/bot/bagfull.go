@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// bagFullRetry is how long harvests and steals stay paused after the bag
+// was still full following a sell pass, unless a sale frees room sooner.
+const bagFullRetry = 5 * time.Minute
+
+// BagFull coordinates the reaction to a full bag. The worker whose harvest
+// or steal hits it asks the warehouse loop for an immediate sell pass and
+// retries once; if the bag is still full, harvesting pauses and BotStatus
+// shows a warning until a harvest goes through again.
+type BagFull struct {
+	sells chan chan bool // served by the warehouse loop; replies whether anything sold
+
+	mu       sync.Mutex
+	since    time.Time // zero while the bag has room
+	lastFail time.Time
+}
+
+func NewBagFull() *BagFull {
+	return &BagFull{sells: make(chan chan bool)}
+}
+
+// Since returns when the bag was found full, or the zero time.
+func (b *BagFull) Since() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.since
+}
+
+// paused reports whether harvests should wait for room.
+func (b *BagFull) paused(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.lastFail.IsZero() && now.Sub(b.lastFail) < bagFullRetry
+}
+
+// sold lifts the pause after a sale made room.
+func (b *BagFull) sold() {
+	b.mu.Lock()
+	b.lastFail = time.Time{}
+	b.mu.Unlock()
+}
+
+// set records whether the last harvest found the bag full and reports
+// whether that changed the state.
+func (b *BagFull) set(full bool, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !full {
+		changed := !b.since.IsZero()
+		b.since, b.lastFail = time.Time{}, time.Time{}
+		return changed
+	}
+	b.lastFail = now
+	if b.since.IsZero() {
+		b.since = now
+		return true
+	}
+	return false
+}
+
+// sellNow asks the warehouse loop for a sell pass and waits for it. It
+// reports whether anything was sold.
+func (b *BagFull) sellNow(ctx context.Context) bool {
+	reply := make(chan bool, 1)
+	select {
+	case b.sells <- reply:
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case sold := <-reply:
+		return sold
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendWithRoom sends a Harvest request (own farm or a steal). When the bag
+// is full it triggers a sell pass and retries once, then records the
+// outcome in full.
+func sendWithRoom(net *Network, logger *Logger, full *BagFull, body []byte) ([]byte, error) {
+	reply, err := net.SendRequest("gamepb.plantpb.PlantService", "Harvest", body)
+	if asServerError(err).IsBagFull() {
+		logger.Warnf("仓库", "背包已满, 出售果实后重试")
+		if full.sellNow(net.ctx) {
+			reply, err = net.SendRequest("gamepb.plantpb.PlantService", "Harvest", body)
+		}
+	}
+	isFull := asServerError(err).IsBagFull()
+	if !isFull && err != nil {
+		return reply, err // unrelated failure says nothing about the bag
+	}
+	if full.set(isFull, net.clock.Now()) {
+		if isFull {
+			logger.Warnf("仓库", "背包已满, 暂停收获 %v", bagFullRetry)
+		} else {
+			logger.Infof("仓库", "背包已有空间, 恢复收获")
+		}
+	}
+	return reply, err
+}
@@ -196,7 +196,7 @@ func (f *FarmWorker) plantBigSeedOnLands(seedID int64, landIDs []int64, consumed
 		Items: []*plantpb.PlantItem{{SeedId: seedID, LandIds: landIDs}},
 	}
 	plantBody, _ := proto.Marshal(plantReq)
-	replyBody, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Plant", plantBody)
+	replyBody, err := f.sendLandAction("Plant", landIDs, plantBody)
 	if err != nil {
 		return false
 	}
@@ -0,0 +1,40 @@
+package bot
+
+import "time"
+
+// Clock is the time source of the bot's scheduling: worker loops, daily
+// rollovers, cooldowns, the watchdog's backoff and the level-up ETA all read
+// it instead of the time package, so they can run on a fake clock. Short
+// pacing sleeps between RPCs and socket deadlines stay on real time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the part of *time.Timer the bot uses.
+type Timer interface {
+	Stop() bool
+}
+
+// Ticker is the part of *time.Ticker the bot uses.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
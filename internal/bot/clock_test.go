@@ -0,0 +1,281 @@
+package bot
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/config"
+)
+
+// fakeClock is a Clock that only moves when Advance is called. Timers due
+// by then fire inside Advance, in time order, so tests need no sleeps.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	at     time.Time
+	period time.Duration  // tickers only
+	ch     chan time.Time // After and tickers
+	fn     func()         // AfterFunc
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.schedule(t, d)
+	return t.ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, fn: f}
+	c.schedule(t, d)
+	return t
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	t := &fakeTimer{clock: c, period: d, ch: make(chan time.Time, 1)}
+	c.schedule(t, d)
+	return fakeTicker{t}
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.ch }
+func (t fakeTicker) Stop()               { t.t.Stop() }
+
+func (c *fakeClock) schedule(t *fakeTimer, d time.Duration) {
+	c.mu.Lock()
+	t.at = c.now.Add(d)
+	c.waiters = append(c.waiters, t)
+	c.mu.Unlock()
+	if d <= 0 {
+		c.Advance(0)
+	}
+}
+
+// Advance moves the clock forward by d and fires every timer due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			break
+		}
+		t := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+			c.waiters = append(c.waiters, t)
+		}
+		now := c.now
+		c.mu.Unlock()
+		if t.fn != nil {
+			t.fn()
+		} else {
+			select {
+			case t.ch <- now:
+			default: // like time.Ticker, drop ticks nobody read
+			}
+		}
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// blockUntil waits until n timers are pending, i.e. the goroutines under
+// test have reached their waits.
+func (c *fakeClock) blockUntil(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending timers = %d, want %d", pending, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.waiters {
+		if w == t {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// quietLogger is a Logger with no store, hub or console output.
+func quietLogger() *Logger {
+	l := NewLogger(1, nil, nil)
+	l.SetOutputs(nil, config.ConsoleLogOff)
+	return l
+}
+
+var testEpoch = time.Date(2026, 10, 16, 3, 0, 0, 0, gameDayZone)
+
+func TestFakeClockFiresInOrder(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	var fired []int
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	c.AfterFunc(time.Second, func() { fired = append(fired, 1) })
+	stopped := c.AfterFunc(time.Second, func() { fired = append(fired, 0) })
+	if !stopped.Stop() {
+		t.Fatal("Stop on a pending timer returned false")
+	}
+	after := c.After(3 * time.Second)
+
+	c.Advance(2 * time.Second)
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 2 {
+		t.Fatalf("fired = %v, want [1 2]", fired)
+	}
+	select {
+	case <-after:
+		t.Fatal("After fired early")
+	default:
+	}
+	c.Advance(time.Second)
+	if got := <-after; !got.Equal(testEpoch.Add(3 * time.Second)) {
+		t.Fatalf("After delivered %v", got)
+	}
+}
+
+func TestSleepJitterOnFakeClock(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	done := make(chan bool, 1)
+	go func() { done <- sleepJitter(context.Background(), c, time.Minute, 0) }()
+
+	c.blockUntil(t, 1)
+	c.Advance(59 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("sleep ended before its duration")
+	default:
+	}
+	c.Advance(time.Second)
+	if !<-done {
+		t.Fatal("sleepJitter = false, want true")
+	}
+}
+
+func TestGameEventsExpireOnClock(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	ge := NewGameEvents(c)
+	if started, _ := ge.observe(GameEventDoubleExp, true, 2, c.Now()); !started {
+		t.Fatal("first observation did not start the event")
+	}
+
+	c.Advance(gameEventTTL)
+	if got := ge.ExpMultiplier(); got != 2 {
+		t.Fatalf("multiplier at TTL = %v, want 2", got)
+	}
+	c.Advance(time.Second)
+	if got := ge.ExpMultiplier(); got != 1 {
+		t.Fatalf("multiplier after TTL = %v, want 1", got)
+	}
+}
+
+func TestSetTraceExpiresOnClock(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	inst := &Instance{clock: c, logger: quietLogger()}
+	inst.net = NewNetwork(inst.logger, nil, c)
+
+	until := inst.SetTrace(10 * time.Minute)
+	if want := testEpoch.Add(10 * time.Minute); !until.Equal(want) {
+		t.Fatalf("until = %v, want %v", until, want)
+	}
+	if !inst.net.tracing() {
+		t.Fatal("network not tracing after SetTrace")
+	}
+
+	c.Advance(10*time.Minute - time.Second)
+	if !inst.net.tracing() {
+		t.Fatal("tracing ended early")
+	}
+	c.Advance(time.Second)
+	if inst.net.tracing() {
+		t.Fatal("network still tracing after expiry")
+	}
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	if !inst.traceUntil.IsZero() || inst.traceTimer != nil {
+		t.Fatal("instance trace state not cleared")
+	}
+}
+
+func TestSetTraceRenewReplacesTimer(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	inst := &Instance{clock: c, logger: quietLogger()}
+
+	inst.SetTrace(time.Minute)
+	inst.SetTrace(5 * time.Minute)
+	c.Advance(time.Minute)
+	inst.mu.RLock()
+	on := !inst.traceUntil.IsZero()
+	inst.mu.RUnlock()
+	if !on {
+		t.Fatal("the first timer ended the renewed trace")
+	}
+}
+
+func TestLandSnapshotUsesClock(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	lc := NewLandCache(c)
+	c.Advance(time.Hour)
+	lc.SetSnapshot(nil, nil)
+	if got := lc.Snapshot().At; !got.Equal(testEpoch.Add(time.Hour)) {
+		t.Fatalf("snapshot at %v, want %v", got, testEpoch.Add(time.Hour))
+	}
+}
+
+func TestFertilizerCountersResetAtGameDay(t *testing.T) {
+	prev := gameResetHour.Load()
+	SetGameResetHour(4)
+	t.Cleanup(func() { gameResetHour.Store(prev) })
+
+	c := newFakeClock(testEpoch) // 03:00, before the reset
+	fw := &FertilizerWorker{net: NewNetwork(quietLogger(), nil, c)}
+	fw.resetDailyCounters()
+	fw.dailyBuyCount, fw.dailyOpenCount = 3, 2
+
+	c.Advance(59 * time.Minute)
+	fw.resetDailyCounters()
+	if fw.dailyBuyCount != 3 {
+		t.Fatalf("counters reset before the game day rolled over")
+	}
+	c.Advance(time.Minute)
+	fw.resetDailyCounters()
+	if fw.dailyBuyCount != 0 || fw.dailyOpenCount != 0 {
+		t.Fatalf("counters = %d/%d after the reset, want 0/0", fw.dailyBuyCount, fw.dailyOpenCount)
+	}
+}
@@ -0,0 +1,76 @@
+package bot
+
+import "sync"
+
+// Conserve hysteresis around the gold reserve: spending pauses once gold
+// falls below conserveEnterRatio × reserve and resumes only after it climbs
+// back above conserveExitRatio × reserve, so a balance hovering around the
+// reserve doesn't flap between the two modes.
+const (
+	conserveEnterRatio = 0.8
+	conserveExitRatio  = 1.2
+)
+
+// spendGate is the conserve state machine of one account. Spenders report
+// the gold they see through observe before buying; while it reports
+// conserving, only the cheapest seed may be bought and land unlocks,
+// upgrades and mall purchases are skipped. A reserve of 0 never conserves.
+// A nil *spendGate never conserves either.
+type spendGate struct {
+	mu         sync.Mutex
+	reserve    int64
+	conserving bool
+	logger     *Logger
+}
+
+func newSpendGate(reserve int64, logger *Logger) *spendGate {
+	return &spendGate{reserve: reserve, logger: logger}
+}
+
+// setReserve changes the reserve. Clearing it ends conserve mode at once;
+// otherwise the new thresholds apply from the next observe.
+func (g *spendGate) setReserve(reserve int64) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.reserve = reserve
+	if reserve <= 0 && g.conserving {
+		g.conserving = false
+		g.logger.Infof("金币", "已关闭金币储备, 恢复正常消费")
+	}
+}
+
+// observe feeds the current gold balance into the state machine and reports
+// whether spending is paused.
+func (g *spendGate) observe(gold int64) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.reserve <= 0 {
+		return false
+	}
+	switch {
+	case !g.conserving && float64(gold) < float64(g.reserve)*conserveEnterRatio:
+		g.conserving = true
+		g.logger.Warnf("金币", "金币 %d 低于储备 %d 的 %.0f%%, 进入节约模式: 只买最便宜的种子, 暂停解锁/升级土地和商城购买",
+			gold, g.reserve, conserveEnterRatio*100)
+	case g.conserving && float64(gold) > float64(g.reserve)*conserveExitRatio:
+		g.conserving = false
+		g.logger.Infof("金币", "金币 %d 已高于储备 %d 的 %.0f%%, 退出节约模式", gold, g.reserve, conserveExitRatio*100)
+	}
+	return g.conserving
+}
+
+// Conserving reports the current mode without feeding a new balance.
+func (g *spendGate) Conserving() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.conserving
+}
@@ -0,0 +1,70 @@
+package bot
+
+import "testing"
+
+func TestSpendGateHysteresis(t *testing.T) {
+	// Reserve 1000: enter below 800, leave above 1200
+	steps := []struct {
+		gold int64
+		want bool
+	}{
+		{5000, false},
+		{1000, false}, // at the reserve, still above the entry threshold
+		{800, false},  // the threshold itself doesn't enter
+		{799, true},
+		{1000, true}, // back at the reserve isn't enough to leave
+		{1200, true}, // nor is the exit threshold itself
+		{700, true},
+		{1201, false},
+		{900, false}, // dipping below the reserve doesn't re-enter
+		{799, true},
+	}
+	g := newSpendGate(1000, quietLogger())
+	for i, s := range steps {
+		if got := g.observe(s.gold); got != s.want {
+			t.Fatalf("step %d: observe(%d) = %v, want %v", i, s.gold, got, s.want)
+		}
+		if g.Conserving() != s.want {
+			t.Fatalf("step %d: Conserving() disagrees with observe", i)
+		}
+	}
+}
+
+func TestSpendGateSetReserve(t *testing.T) {
+	g := newSpendGate(1000, quietLogger())
+	g.observe(100)
+	if !g.Conserving() {
+		t.Fatal("not conserving at 100 of 1000")
+	}
+
+	// A lower reserve takes effect from the next balance
+	g.setReserve(50)
+	if !g.Conserving() {
+		t.Fatal("setReserve left conserve mode without a new balance")
+	}
+	if g.observe(100) {
+		t.Fatal("still conserving at 100 with reserve 50")
+	}
+
+	// Clearing the reserve ends conserve mode at once and keeps it off
+	g.setReserve(1000)
+	g.observe(100)
+	g.setReserve(0)
+	if g.Conserving() {
+		t.Fatal("still conserving after the reserve was cleared")
+	}
+	if g.observe(0) {
+		t.Fatal("reserve 0 conserved")
+	}
+}
+
+func TestSpendGateNilAndZero(t *testing.T) {
+	var g *spendGate
+	g.setReserve(1000)
+	if g.observe(0) || g.Conserving() {
+		t.Fatal("nil gate conserved")
+	}
+	if newSpendGate(0, quietLogger()).observe(0) {
+		t.Fatal("zero reserve conserved")
+	}
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"qq-farm-bot/internal/yield"
+)
+
+// ConsistencyReport collects inconsistencies between Plant.json, the seed
+// shop export and ItemInfo.json. Errors make yield rows wrong (missing plant,
+// unknown fruit, grow time drift); warnings are suspicious but usable.
+type ConsistencyReport struct {
+	Errors   []string
+	Warnings []string
+}
+
+func (r *ConsistencyReport) errorf(format string, args ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *ConsistencyReport) warnf(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Write prints one line per issue followed by a summary, or nothing.
+func (r *ConsistencyReport) Write(w io.Writer) {
+	for _, e := range r.Errors {
+		fmt.Fprintf(w, "ERROR   %s\n", e)
+	}
+	for _, e := range r.Warnings {
+		fmt.Fprintf(w, "WARNING %s\n", e)
+	}
+	if len(r.Errors)+len(r.Warnings) > 0 {
+		fmt.Fprintf(w, "validation: %d error(s), %d warning(s)\n", len(r.Errors), len(r.Warnings))
+	}
+}
+
+// CheckConsistency cross-checks the three config files the yield tables join.
+func CheckConsistency(plants []PlantConfig, shop *SeedShopExport, prices map[int]int) *ConsistencyReport {
+	r := &ConsistencyReport{}
+	plantMap := make(map[int]*PlantConfig, len(plants))
+	seedToPlant := make(map[int]*PlantConfig, len(plants))
+	for i := range plants {
+		plantMap[plants[i].ID] = &plants[i]
+		if plants[i].SeedID > 0 {
+			seedToPlant[plants[i].SeedID] = &plants[i]
+		}
+	}
+
+	checkFruit := func(what string, fruitID int) {
+		price, ok := prices[fruitID]
+		switch {
+		case !ok:
+			r.errorf("%s: fruit %d not found in ItemInfo.json", what, fruitID)
+		case price == 0:
+			r.warnf("%s: fruit %d has price 0 in ItemInfo.json", what, fruitID)
+		}
+	}
+
+	inShop := make(map[int]bool)
+	for _, s := range shop.Rows {
+		if s.SeedID <= 0 {
+			continue
+		}
+		what := fmt.Sprintf("shop seed %d (%s)", s.SeedID, s.Name)
+		plant := plantMap[s.PlantID]
+		if plant == nil {
+			plant = seedToPlant[s.SeedID]
+		}
+		if plant == nil {
+			r.errorf("%s: plant %d not found in Plant.json", what, s.PlantID)
+			continue
+		}
+		inShop[plant.ID] = true
+		if plant.SeedID != s.SeedID {
+			r.warnf("%s: Plant.json plant %d has seed_id %d", what, plant.ID, plant.SeedID)
+		}
+		if s.PlantID != plant.ID {
+			r.warnf("%s: plantId %d, but Plant.json maps the seed to plant %d", what, s.PlantID, plant.ID)
+		}
+
+		checkFruit(what, s.FruitID)
+		if s.FruitID != plant.Fruit.ID {
+			r.warnf("%s: fruitId %d differs from Plant.json fruit %d", what, s.FruitID, plant.Fruit.ID)
+		}
+		if s.FruitCount != plant.Fruit.Count {
+			r.warnf("%s: fruitCount %d differs from Plant.json %d", what, s.FruitCount, plant.Fruit.Count)
+		}
+		if s.Exp != plant.Exp {
+			r.warnf("%s: exp %d differs from Plant.json %d", what, s.Exp, plant.Exp)
+		}
+		if s.Price <= 0 {
+			r.warnf("%s: price is %d", what, s.Price)
+		}
+
+		pd, _ := yield.BuildPhaseData(plant.GrowPhases, plant.Seasons)
+		if pd != nil && s.GrowTimeSec != pd.TotalGrowTime {
+			r.errorf("%s: growTimeSec %d differs from the %ds summed from Plant.json phases", what, s.GrowTimeSec, pd.TotalGrowTime)
+		}
+	}
+
+	for i := range plants {
+		p := &plants[i]
+		if p.SeedID <= 0 {
+			continue
+		}
+		what := fmt.Sprintf("plant %d (%s)", p.ID, p.Name)
+		pd, issues := yield.BuildPhaseData(p.GrowPhases, p.Seasons)
+		if len(issues) > 0 {
+			r.warnf("%s: malformed grow_phases: %s", what, strings.Join(issues, "; "))
+		}
+		if pd == nil {
+			r.warnf("%s: no growth phases, row skipped", what)
+		}
+		if !inShop[p.ID] {
+			checkFruit(what, p.Fruit.ID)
+		}
+	}
+
+	return r
+}
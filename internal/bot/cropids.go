@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"sort"
+
+	"qq-farm-bot/internal/model"
+)
+
+// Crop ID kinds reported by ResolveCropID.
+const (
+	CropIDPlant   = "plant"
+	CropIDFruit   = "fruit"
+	CropIDUnknown = "unknown"
+)
+
+// CropIDInfo is how an entry of a crop ID list is interpreted.
+type CropIDInfo struct {
+	ID      int    `json:"id"`
+	Kind    string `json:"kind"`
+	PlantID int    `json:"plant_id,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// ResolveCropID reports whether id is a plant ID, a fruit ID (and of which
+// plant) or neither.
+func (gc *GameConfig) ResolveCropID(id int) CropIDInfo {
+	info := CropIDInfo{ID: id, Kind: CropIDUnknown}
+	if gc == nil {
+		return info
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	if p, ok := gc.plantMap[id]; ok {
+		info.Kind, info.PlantID, info.Name = CropIDPlant, p.ID, p.Name
+	} else if p, ok := gc.fruitToPlant[id]; ok {
+		info.Kind, info.PlantID, info.Name = CropIDFruit, p.ID, p.Name
+	}
+	return info
+}
+
+// CropFilter parses a crop ID list like model.ParseCropIDs and maps fruit
+// IDs to their plant, so lists match plant IDs whichever kind was entered.
+func (gc *GameConfig) CropFilter(s string) map[int]bool {
+	ids := model.ParseCropIDs(s)
+	for id := range ids {
+		if info := gc.ResolveCropID(id); info.Kind == CropIDFruit {
+			ids[info.PlantID] = true
+		}
+	}
+	return ids
+}
+
+// UnknownCropIDs returns the entries of a crop ID list that are neither
+// plant nor fruit IDs. Without loaded plant data nothing can be judged, so
+// it returns nil.
+func (gc *GameConfig) UnknownCropIDs(s string) []int {
+	if gc.Degraded() {
+		return nil
+	}
+	var unknown []int
+	for id := range model.ParseCropIDs(s) {
+		if gc.ResolveCropID(id).Kind == CropIDUnknown {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Ints(unknown)
+	return unknown
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// cycleAvgWeight is the weight of the newest pass in the moving average.
+const cycleAvgWeight = 0.2
+
+// cycleGuard runs the passes of one worker loop (farm check, friend round,
+// fertilizer task) one at a time and times them. The loop itself never
+// overlaps its passes, but a trigger from outside the loop or the worker of
+// a connection still winding down could, and two passes buying seeds at
+// once spend twice. The guard lives on the instance so it spans reconnects.
+// A nil *cycleGuard runs passes unguarded and untimed.
+type cycleGuard struct {
+	tag     string // log tag of the worker
+	logger  *Logger
+	running atomic.Bool
+
+	mu       sync.Mutex
+	last     time.Duration
+	avg      time.Duration
+	passes   int64
+	overruns int64
+	skipped  int64
+}
+
+func newCycleGuard(tag string, logger *Logger) *cycleGuard {
+	return &cycleGuard{tag: tag, logger: logger}
+}
+
+// run executes pass unless one is already in flight, in which case it is
+// skipped and run returns false. budget is the loop interval; a pass taking
+// longer logs a warning, as the next one then starts late.
+func (g *cycleGuard) run(budget time.Duration, pass func()) bool {
+	if g == nil {
+		pass()
+		return true
+	}
+	if !g.running.CompareAndSwap(false, true) {
+		g.mu.Lock()
+		g.skipped++
+		g.mu.Unlock()
+		g.logger.Warnf(g.tag, "上一轮尚未结束, 跳过本轮")
+		return false
+	}
+	defer g.running.Store(false)
+
+	start := time.Now()
+	pass()
+	elapsed := time.Since(start)
+
+	g.mu.Lock()
+	g.last = elapsed
+	if g.passes == 0 {
+		g.avg = elapsed
+	} else {
+		g.avg += time.Duration(cycleAvgWeight * float64(elapsed-g.avg))
+	}
+	g.passes++
+	over := budget > 0 && elapsed > budget
+	if over {
+		g.overruns++
+	}
+	g.mu.Unlock()
+
+	if over {
+		g.logger.Warnf(g.tag, "本轮耗时 %v, 超过间隔 %v", elapsed.Round(time.Millisecond), budget)
+	}
+	return true
+}
+
+// timing returns the pass durations so far; ok is false before the first.
+func (g *cycleGuard) timing() (t model.CycleTiming, ok bool) {
+	if g == nil {
+		return t, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.passes == 0 && g.skipped == 0 {
+		return t, false
+	}
+	return model.CycleTiming{
+		LastMs:   g.last.Milliseconds(),
+		AvgMs:    g.avg.Milliseconds(),
+		Passes:   g.passes,
+		Overruns: g.overruns,
+		Skipped:  g.skipped,
+	}, true
+}
@@ -0,0 +1,66 @@
+package bot
+
+import "sync"
+
+// StatusEventKind describes what changed on a bot instance.
+type StatusEventKind string
+
+const (
+	EventStarted      StatusEventKind = "started"       // bot connected and logged in
+	EventStopped      StatusEventKind = "stopped"       // bot stopped or disconnected
+	EventLandsUpdated StatusEventKind = "lands_updated" // land cache refreshed
+	EventStateChanged StatusEventKind = "state_changed" // level/gold/exp changed
+)
+
+// StatusEvent notifies subscribers that an account's status changed.
+// Subscribers fetch the fresh status themselves; events carry no payload.
+type StatusEvent struct {
+	AccountID int64
+	Kind      StatusEventKind
+}
+
+// EventBus fans out status events to subscribers. Publishing never blocks:
+// events are dropped for subscribers whose buffer is full.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[chan StatusEvent]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan StatusEvent]struct{})}
+}
+
+func (b *EventBus) Publish(accountID int64, kind StatusEventKind) {
+	if b == nil {
+		return
+	}
+	ev := StatusEvent{AccountID: accountID, Kind: kind}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all status events. Call Unsubscribe to stop.
+func (b *EventBus) Subscribe() chan StatusEvent {
+	ch := make(chan StatusEvent, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes and closes the channel. Closing under the lock
+// guarantees Publish never sends on a closed channel.
+func (b *EventBus) Unsubscribe(ch chan StatusEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
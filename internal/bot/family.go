@@ -0,0 +1,70 @@
+package bot
+
+import "sync"
+
+type familyKey struct{ userID, familyID int64 }
+
+// Families tracks the game GIDs of running accounts per account family, so
+// FriendWorkers can tell family members apart from other friends. A family
+// is scoped to the user owning the accounts. A nil *Families has no members.
+type Families struct {
+	mu      sync.RWMutex
+	members map[familyKey]map[int64]int64 // account ID -> GID
+}
+
+func NewFamilies() *Families {
+	return &Families{members: make(map[familyKey]map[int64]int64)}
+}
+
+// Join records gid as accountID's member entry in family familyID, leaving
+// any family it was in before. Family 0 means none, so it only leaves.
+func (fs *Families) Join(userID, familyID, accountID, gid int64) {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.leave(accountID)
+	if familyID <= 0 || gid == 0 {
+		return
+	}
+	key := familyKey{userID, familyID}
+	if fs.members[key] == nil {
+		fs.members[key] = make(map[int64]int64)
+	}
+	fs.members[key][accountID] = gid
+}
+
+// Leave removes accountID from its family.
+func (fs *Families) Leave(accountID int64) {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.leave(accountID)
+}
+
+func (fs *Families) leave(accountID int64) {
+	for key, members := range fs.members {
+		delete(members, accountID)
+		if len(members) == 0 {
+			delete(fs.members, key)
+		}
+	}
+}
+
+// GIDs returns the GIDs of the running members of a family.
+func (fs *Families) GIDs(userID, familyID int64) map[int64]bool {
+	if fs == nil || familyID <= 0 {
+		return nil
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	members := fs.members[familyKey{userID, familyID}]
+	gids := make(map[int64]bool, len(members))
+	for _, gid := range members {
+		gids[gid] = true
+	}
+	return gids
+}
@@ -2,8 +2,9 @@ package bot
 
 import (
 	"fmt"
+	"maps"
 	"math"
-	"math/rand"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -11,7 +12,9 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/yield"
 
+	"qq-farm-bot/proto/corepb"
 	"qq-farm-bot/proto/itempb"
 	"qq-farm-bot/proto/plantpb"
 	"qq-farm-bot/proto/shoppb"
@@ -23,12 +26,31 @@ const normalFertilizerID = 1011
 type FarmWorker struct {
 	net                *Network
 	logger             *Logger
-	cfg                *BotConfig
+	profile            *Profile
+	cfg                *BotConfig // resolved from profile each cycle
 	gc                 *GameConfig
+	stats              *BotStats
 	lands              *LandCache
 	sc                 *StatsCollector
-	fertilized         map[int64]bool // tracks lands we've already fertilized this grow cycle
-	reservedForBigSeed map[int64]bool // lands reserved for 2×2 seed planting
+	fertilized         map[int64]bool  // tracks lands we've already fertilized this grow cycle
+	fertilizedStarts   map[int64]int64 // plant start of each fertilized land, as checkpointed
+	restoredStarts     map[int64]int64 // checkpoint to validate against the first land fetch
+	reservedForBigSeed map[int64]bool  // lands reserved for 2×2 seed planting
+	purchases          seedPurchases   // seed buys not yet reflected in ShopInfo
+	friends            *FriendNames
+	events             *GameEvents
+	harvested          trigger // fired after every harvest
+	leveledUp          trigger // fired by the instance on level-up
+	bagFull            *BagFull
+	throttle           *landThrottle    // minimum gap between identical land mutations
+	shop               seedShopSnapshot // last seed shop result, for plant plans
+	cmds               chan landCommand // manual actions, run between cycles
+	state              *WorkerState
+	gate               *spendGate      // conserve mode: cheapest seed only, no land spending
+	matureNotified     map[int64]int64 // land → mature time already announced
+	// notify sends a webhook notification; set by the instance
+	notify func(event, message string, data map[string]any)
+	cycle  *cycleGuard // single-flight and timing of checkFarm; set by the instance
 }
 
 // shopSeedCandidate represents an available seed from the shop with its level requirement.
@@ -37,49 +59,119 @@ type shopSeedCandidate struct {
 	requiredLevel int64
 }
 
-func NewFarmWorker(net *Network, logger *Logger, cfg *BotConfig, lands *LandCache, sc *StatsCollector) *FarmWorker {
-	return &FarmWorker{
+func NewFarmWorker(net *Network, logger *Logger, profile *Profile, stats *BotStats, lands *LandCache, friends *FriendNames, events *GameEvents, harvested, leveledUp trigger, bagFull *BagFull, sc *StatsCollector, state *WorkerState, gate *spendGate) *FarmWorker {
+	f := &FarmWorker{
 		net:                net,
 		logger:             logger,
-		cfg:                cfg,
+		profile:            profile,
+		cfg:                profile.Current(),
 		gc:                 GetGameConfig(),
+		stats:              stats,
 		lands:              lands,
+		friends:            friends,
+		events:             events,
+		harvested:          harvested,
+		leveledUp:          leveledUp,
+		bagFull:            bagFull,
 		sc:                 sc,
 		fertilized:         make(map[int64]bool),
 		reservedForBigSeed: make(map[int64]bool),
+		throttle:           newLandThrottle(profile.Current().LandActionThrottle),
+		cmds:               make(chan landCommand),
+		state:              state,
+		gate:               gate,
 	}
+	state.Load(stateKeyFertilizedLands, stateVersionFertilizedLands, &f.restoredStarts)
+	return f
 }
 
 // RunLoop runs the farm check loop until context is cancelled.
 func (f *FarmWorker) RunLoop() {
-	// Initial delay: add jitter if anti-detection enabled
-	initDelay := 2 * time.Second
-	if f.cfg.EnableAntiDetection {
-		initDelay = time.Duration(1+rand.Intn(3)) * time.Second
-	}
-	select {
-	case <-time.After(initDelay):
-	case <-f.net.ctx.Done():
+	defer f.checkpointFertilized(nil)
+	if !f.wait(jitterDuration(2*time.Second, f.cfg.jitterPct())) {
 		return
 	}
 
 	for {
-		f.checkFarm()
-		waitTime := time.Duration(f.cfg.FarmInterval) * time.Second
-		if f.cfg.EnableAntiDetection {
-			// Add ±30% random jitter to the interval
-			base := float64(f.cfg.FarmInterval)
-			jitter := base * (0.7 + rand.Float64()*0.6) // 0.7x ~ 1.3x
-			waitTime = time.Duration(jitter * float64(time.Second))
+		f.resolveConfig()
+		f.cycle.run(time.Duration(f.cfg.FarmInterval)*time.Second, f.checkFarm)
+		waitTime := jitterDuration(time.Duration(f.cfg.FarmInterval)*time.Second, f.cfg.jitterPct())
+		f.lands.SetNextCheck(f.net.clock.Now().Add(waitTime))
+		if !f.wait(waitTime) {
+			return
 		}
+	}
+}
+
+// wait sleeps for d while serving manual land commands. A level-up ends
+// the wait early so the next cycle plants for the new level. It returns
+// false once the connection is gone.
+func (f *FarmWorker) wait(d time.Duration) bool {
+	done := f.net.clock.After(d)
+	for {
 		select {
-		case <-time.After(waitTime):
+		case <-done:
+			return true
+		case cmd := <-f.cmds:
+			cmd.done <- f.runLandCommand(cmd)
+		case <-f.leveledUp:
+			f.resolveConfig()
+			f.onLevelUp()
+			return true
 		case <-f.net.ctx.Done():
-			return
+			return false
 		}
 	}
 }
 
+// restoreFertilized marks lands fertilized before a reconnect or restart,
+// keeping only those still growing the same planting.
+func (f *FarmWorker) restoreFertilized(lands []*plantpb.LandInfo) {
+	if f.restoredStarts == nil {
+		return
+	}
+	for _, land := range lands {
+		start, ok := f.restoredStarts[land.Id]
+		if ok && land.Plant != nil && getPlantStartTimeSec(land.Plant.Phases) == start {
+			f.fertilized[land.Id] = true
+		}
+	}
+	f.fertilizedStarts = f.restoredStarts
+	f.restoredStarts = nil
+}
+
+// checkpointFertilized saves the fertilized lands with their plant start,
+// which tells a later restore whether the crop is still the same. Without
+// lands the last known starts are kept for lands still marked.
+func (f *FarmWorker) checkpointFertilized(lands []*plantpb.LandInfo) {
+	starts := make(map[int64]int64, len(f.fertilized))
+	if lands != nil {
+		for _, land := range lands {
+			if f.fertilized[land.Id] && land.Plant != nil {
+				starts[land.Id] = getPlantStartTimeSec(land.Plant.Phases)
+			}
+		}
+	} else {
+		for id, start := range f.fertilizedStarts {
+			if f.fertilized[id] {
+				starts[id] = start
+			}
+		}
+	}
+	if maps.Equal(starts, f.fertilizedStarts) {
+		return
+	}
+	f.fertilizedStarts = starts
+	f.state.Save(stateKeyFertilizedLands, stateVersionFertilizedLands, starts)
+}
+
+// resolveConfig picks up settings changes and the warm-up profile for the
+// current level.
+func (f *FarmWorker) resolveConfig() {
+	_, level, _, _, _ := f.net.state.Get()
+	f.cfg = f.profile.Resolve(level)
+}
+
 func (f *FarmWorker) checkFarm() {
 	landsReply, err := f.net.AllLands()
 	if err != nil {
@@ -106,8 +198,10 @@ func (f *FarmWorker) checkFarm() {
 	}
 
 	status := f.analyzeLands(lands)
+	f.lands.SetSnapshot(landsReply, status.classes())
 	landMap := buildLandMap(lands)
 
+	f.restoreFertilized(lands)
 	f.logger.Debugf("巡田", "fertilized缓存: %v", f.fertilized)
 
 	fertilized := f.checkAndFertilize(lands)
@@ -127,6 +221,7 @@ func (f *FarmWorker) checkFarm() {
 
 	// Update land cache for dashboard display
 	f.updateLandCache(lands)
+	f.checkpointFertilized(lands)
 
 	// Build status summary
 	var parts []string
@@ -159,6 +254,9 @@ func (f *FarmWorker) checkFarm() {
 		}
 	}
 	parts = append(parts, fmt.Sprintf("长:%d", len(status.growing)))
+	if len(status.kept) > 0 {
+		parts = append(parts, fmt.Sprintf("保留:%d", len(status.kept)))
+	}
 
 	hasWork := len(status.harvestable) > 0 || len(status.needWeed) > 0 || len(status.needBug) > 0 ||
 		len(status.needWater) > 0 || len(status.dead) > 0 || len(status.empty) > 0
@@ -181,6 +279,7 @@ func (f *FarmWorker) checkFarm() {
 		if err := f.weedOut(status.needWeed); err == nil {
 			actions = append(actions, fmt.Sprintf("除草%d", len(status.needWeed)))
 			f.sc.RecordSimple(model.OpWeed, int64(len(status.needWeed)))
+			f.creditGrief(status.needWeed, landMap, false)
 		}
 	}
 	if f.cfg.EnableBug && len(status.needBug) > 0 {
@@ -188,6 +287,7 @@ func (f *FarmWorker) checkFarm() {
 		if err := f.insecticide(status.needBug); err == nil {
 			actions = append(actions, fmt.Sprintf("除虫%d", len(status.needBug)))
 			f.sc.RecordSimple(model.OpBug, int64(len(status.needBug)))
+			f.creditGrief(status.needBug, landMap, true)
 		}
 	}
 	if f.cfg.EnableWater && len(status.needWater) > 0 {
@@ -198,7 +298,9 @@ func (f *FarmWorker) checkFarm() {
 		}
 	}
 
-	if f.cfg.EnableHarvest && len(status.harvestable) > 0 {
+	if f.cfg.EnableHarvest && len(status.harvestable) > 0 && f.bagFull.paused(f.net.clock.Now()) {
+		f.logger.Debugf("收获", "背包已满, 跳过收获 %d 块", len(status.harvestable))
+	} else if f.cfg.EnableHarvest && len(status.harvestable) > 0 {
 		for _, id := range status.harvestable {
 			if land, ok := landMap[id]; ok && land.Plant != nil {
 				cropName := f.gc.GetPlantName(int(land.Plant.Id))
@@ -208,9 +310,10 @@ func (f *FarmWorker) checkFarm() {
 			}
 		}
 		f.logger.Infof("收获", "成熟 %d 块: %s", len(status.harvestable), f.descLands(status.harvestable, landMap))
-		if err := f.harvest(status.harvestable); err == nil {
+		if yield, err := f.harvest(status.harvestable); err == nil {
 			actions = append(actions, fmt.Sprintf("收获%d", len(status.harvestable)))
-			f.sc.RecordSimple(model.OpHarvest, int64(len(status.harvestable)))
+			f.recordHarvest(len(status.harvestable), yield)
+			f.observeHarvestExp(status.harvestable, landMap, yield)
 			for _, id := range status.harvestable {
 				delete(f.fertilized, id)
 			}
@@ -219,7 +322,7 @@ func (f *FarmWorker) checkFarm() {
 				for _, id := range status.harvestable {
 					if land, ok := freshLandMap[id]; ok && land.Plant != nil && len(land.Plant.Phases) > 0 {
 						cropName := f.gc.GetPlantName(int(land.Plant.Id))
-						nowSec := time.Now().Unix()
+						nowSec := f.net.clock.Now().Unix()
 						cp := getCurrentPhase(land.Plant.Phases, nowSec)
 						phaseName := "?"
 						if cp != nil {
@@ -317,6 +420,41 @@ func getPhaseName(phase *plantpb.PlantPhaseInfo) string {
 	return fmt.Sprintf("阶段%d", phase.Phase)
 }
 
+// creditGrief counts the friends who left the weeds (or insects) just
+// cleared from landIDs into today's per-friend counters.
+func (f *FarmWorker) creditGrief(landIDs []int64, landMap map[int64]*plantpb.LandInfo, insects bool) {
+	myGID, _, _, _, _ := f.net.state.Get()
+	counts := make(map[int64]int64)
+	for _, id := range landIDs {
+		land := landMap[id]
+		if land == nil || land.Plant == nil {
+			continue
+		}
+		owners := land.Plant.WeedOwners
+		if insects {
+			owners = land.Plant.InsectOwners
+		}
+		for _, gid := range owners {
+			if gid > 0 && gid != myGID {
+				counts[gid]++
+			}
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+	date := f.net.GameDate()
+	for gid, n := range counts {
+		g := &model.FriendGrief{Date: date, FriendGID: gid, FriendName: f.friends.Name(gid)}
+		if insects {
+			g.Insects = n
+		} else {
+			g.Weeds = n
+		}
+		f.sc.RecordFriendGrief(g)
+	}
+}
+
 func (f *FarmWorker) descLands(landIDs []int64, landMap map[int64]*plantpb.LandInfo) string {
 	var parts []string
 	for _, id := range landIDs {
@@ -334,12 +472,22 @@ func (f *FarmWorker) updateLandCache(lands []*plantpb.LandInfo) {
 	if f.lands == nil {
 		return
 	}
-	nowSec := time.Now().Unix()
+	nowSec := f.net.clock.Now().Unix()
 	totalLands := len(lands)
 	unlockedCount := 0
 	var statuses []model.LandStatus
 	var harvestInfos []LandHarvestInfo
 	landMap := buildLandMap(lands)
+	noHarvest := f.gc.CropFilter(f.cfg.NoHarvestCropIDs)
+	// Precomputed here so Status stays cheap
+	var next LandEvent
+	var soon []matureLand
+	notifyWindow := int64(f.cfg.MatureNoticeMinutes) * 60
+	upcoming := func(atSec int64, eventType string, landID int64) {
+		if atSec > nowSec && (next.AtSec == 0 || atSec < next.AtSec) {
+			next = LandEvent{AtSec: atSec, Type: eventType, LandID: landID}
+		}
+	}
 	for _, land := range lands {
 		ls := model.LandStatus{
 			ID:           land.Id,
@@ -362,7 +510,7 @@ func (f *FarmWorker) updateLandCache(lands []*plantpb.LandInfo) {
 		if land.Plant != nil && len(land.Plant.Phases) > 0 && !isOccupiedSlaveLand(land, landMap) {
 			ls.CropID = land.Plant.Id
 			ls.CropName = f.gc.GetPlantName(int(land.Plant.Id))
-			ls.Season = land.Plant.GetSeason()
+			ls.Season = f.plantSeason(land.Plant, ls.TimeReducePct)
 			ls.GrowSec = land.Plant.GrowSec
 			ls.CropExp = f.gc.GetPlantExp(int(land.Plant.Id))
 			ls.PlantSize = f.gc.GetPlantSize(int(land.Plant.Id))
@@ -374,6 +522,7 @@ func (f *FarmWorker) updateLandCache(lands []*plantpb.LandInfo) {
 			ls.FertTimesLeft = land.Plant.LeftInorcFertTimes
 			ls.HasWeeds = len(land.Plant.WeedOwners) > 0
 			ls.HasInsects = len(land.Plant.InsectOwners) > 0
+			ls.Kept = noHarvest[int(land.Plant.Id)]
 
 			currentPhase := getCurrentPhase(land.Plant.Phases, nowSec)
 			if currentPhase != nil {
@@ -395,19 +544,36 @@ func (f *FarmWorker) updateLandCache(lands []*plantpb.LandInfo) {
 				if !ls.HasInsects && currentPhase.InsectTime > 0 && toTimeSec(currentPhase.InsectTime) <= nowSec {
 					ls.HasInsects = true
 				}
+				ls.NeedsWater = ls.DryNum > 0 || (currentPhase.DryTime > 0 && toTimeSec(currentPhase.DryTime) <= nowSec)
+				ls.Fertilized = len(currentPhase.FertsUsed) > 0
+				if currentPhase.DryTime > 0 {
+					upcoming(toTimeSec(currentPhase.DryTime), model.LandEventDry, land.Id)
+				}
+				if currentPhase.WeedsTime > 0 {
+					upcoming(toTimeSec(currentPhase.WeedsTime), model.LandEventWeeds, land.Id)
+				}
+				if currentPhase.InsectTime > 0 {
+					upcoming(toTimeSec(currentPhase.InsectTime), model.LandEventInsects, land.Id)
+				}
 			}
 
 			matureTime := getMatureTimeSec(land.Plant.Phases)
 			plantTime := getPlantStartTimeSec(land.Plant.Phases)
 			if matureTime > 0 {
 				ls.MatureTimeSec = matureTime
+				if !ls.Kept {
+					upcoming(matureTime, model.LandEventMature, land.Id)
+				}
+				if notifyWindow > 0 && matureTime > nowSec && matureTime-nowSec <= notifyWindow {
+					soon = append(soon, matureLand{landID: land.Id, matureSec: matureTime, crop: ls.CropName})
+				}
 			}
 			if matureTime > 0 && plantTime > 0 && matureTime > plantTime {
 				ls.CycleTimeSec = matureTime - plantTime
 				hi := LandHarvestInfo{
 					LandID:        land.Id,
 					CropID:        land.Plant.Id,
-					Season:        land.Plant.GetSeason(),
+					Season:        ls.Season,
 					CropExp:       f.gc.GetPlantExp(int(land.Plant.Id)),
 					CycleTimeSec:  matureTime - plantTime,
 					MatureTimeSec: matureTime,
@@ -434,7 +600,10 @@ func (f *FarmWorker) updateLandCache(lands []*plantpb.LandInfo) {
 		}
 		statuses = append(statuses, ls)
 	}
-	f.lands.Update(totalLands, unlockedCount, statuses, harvestInfos)
+	f.lands.Update(totalLands, unlockedCount, statuses, harvestInfos, next)
+	if notifyWindow > 0 {
+		f.notifyMatureSoon(soon, nowSec)
+	}
 }
 
 type landStatus struct {
@@ -445,12 +614,33 @@ type landStatus struct {
 	growing     []int64
 	empty       []int64
 	dead        []int64
+	kept        []int64 // mature but in no_harvest_crop_ids; also in growing
+}
+
+// classes maps each land to the categories it was sorted into.
+func (s *landStatus) classes() map[int64][]string {
+	m := make(map[int64][]string)
+	add := func(name string, ids []int64) {
+		for _, id := range ids {
+			m[id] = append(m[id], name)
+		}
+	}
+	add("harvestable", s.harvestable)
+	add("need_water", s.needWater)
+	add("need_weed", s.needWeed)
+	add("need_bug", s.needBug)
+	add("growing", s.growing)
+	add("empty", s.empty)
+	add("dead", s.dead)
+	add("kept", s.kept)
+	return m
 }
 
 func (f *FarmWorker) analyzeLands(lands []*plantpb.LandInfo) *landStatus {
 	s := &landStatus{}
-	nowSec := time.Now().Unix()
+	nowSec := f.net.clock.Now().Unix()
 	landMap := buildLandMap(lands)
+	noHarvest := f.gc.CropFilter(f.cfg.NoHarvestCropIDs)
 
 	for _, land := range lands {
 		id := land.Id
@@ -475,15 +665,20 @@ func (f *FarmWorker) analyzeLands(lands []*plantpb.LandInfo) *landStatus {
 		cropName := f.gc.GetPlantName(int(plant.Id))
 		phaseName := getPhaseName(phase)
 
-		switch plantpb.PlantPhase(phase.Phase) {
-		case plantpb.PlantPhase_DEAD:
+		// Kept crops stay standing when mature and are tended like growing ones
+		kept := noHarvest[int(plant.Id)]
+		switch p := plantpb.PlantPhase(phase.Phase); {
+		case p == plantpb.PlantPhase_DEAD:
 			s.dead = append(s.dead, id)
 			f.logger.Debugf("分析", "地#%d %s Phase=%d(%s) → 枯萎", id, cropName, phase.Phase, phaseName)
-		case plantpb.PlantPhase_MATURE:
+		case p == plantpb.PlantPhase_MATURE && !kept:
 			s.harvestable = append(s.harvestable, id)
 			f.logger.Debugf("分析", "地#%d %s Phase=%d(%s) 季=%d → 成熟",
 				id, cropName, phase.Phase, phaseName, plant.GetSeason())
 		default:
+			if p == plantpb.PlantPhase_MATURE {
+				s.kept = append(s.kept, id)
+			}
 			if plant.DryNum > 0 || (phase.DryTime > 0 && toTimeSec(phase.DryTime) <= nowSec) {
 				s.needWater = append(s.needWater, id)
 			}
@@ -505,7 +700,7 @@ func (f *FarmWorker) analyzeLands(lands []*plantpb.LandInfo) *landStatus {
 // checkAndFertilize examines growing plants and fertilizes them when they're in their longest phase.
 // Normal fertilizer skips the current phase, so applying it during the longest phase saves the most time.
 func (f *FarmWorker) checkAndFertilize(lands []*plantpb.LandInfo) int {
-	nowSec := time.Now().Unix()
+	nowSec := f.net.clock.Now().Unix()
 	fertilizeCount := 0
 	landMap := buildLandMap(lands)
 
@@ -546,11 +741,12 @@ func (f *FarmWorker) checkAndFertilize(lands []*plantpb.LandInfo) int {
 			continue
 		}
 
-		season := plant.GetSeason()
-		totalSeasons := f.gc.GetPlantSeasons(int(plant.Id))
-		if season < 1 {
-			season = 1
+		var timeReducePct int64
+		if buff := land.GetBuff(); buff != nil {
+			timeReducePct = buff.PlantingTimeReduction
 		}
+		season := f.plantSeason(plant, timeReducePct)
+		totalSeasons := f.gc.GetPlantSeasons(int(plant.Id))
 
 		if plant.LeftInorcFertTimes <= 0 {
 			f.logger.Debugf("施肥", "地#%d %s 剩余施肥次数=%d → 跳过", landID, cropName, plant.LeftInorcFertTimes)
@@ -623,9 +819,7 @@ func (f *FarmWorker) checkAndFertilize(lands []*plantpb.LandInfo) int {
 }
 
 func (f *FarmWorker) fertilizeSingle(landID int64) bool {
-	req := &plantpb.FertilizeRequest{LandIds: []int64{landID}, FertilizerId: normalFertilizerID}
-	body, _ := proto.Marshal(req)
-	if _, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Fertilize", body); err != nil {
+	if err := f.fertilizeLand(landID); err != nil {
 		f.logger.Debugf("施肥", "地#%d 请求失败: %v", landID, err)
 		return false
 	}
@@ -633,6 +827,13 @@ func (f *FarmWorker) fertilizeSingle(landID int64) bool {
 	return true
 }
 
+func (f *FarmWorker) fertilizeLand(landID int64) error {
+	req := &plantpb.FertilizeRequest{LandIds: []int64{landID}, FertilizerId: normalFertilizerID}
+	body, _ := proto.Marshal(req)
+	_, err := f.sendLandAction("Fertilize", []int64{landID}, body)
+	return err
+}
+
 func getCurrentPhase(phases []*plantpb.PlantPhaseInfo, nowSec int64) *plantpb.PlantPhaseInfo {
 	if len(phases) == 0 {
 		return nil
@@ -652,6 +853,59 @@ func getCurrentPhase(phases []*plantpb.PlantPhaseInfo, nowSec int64) *plantpb.Pl
 	return phases[0]
 }
 
+// plantSeason returns the current season (1 or 2) of plant. The server's
+// season field is used when set; otherwise it is derived from the phase
+// block the plant is in: a season-2 cycle regrows only the last
+// len(Season2Phases) phases, so more growth phases left means season 1, and
+// failing that the block's span is matched against each season's grow time
+// (after the land's time reduction buff).
+func (f *FarmWorker) plantSeason(plant *plantpb.PlantInfo, timeReducePct int64) int64 {
+	if s := plant.GetSeason(); s > 0 {
+		return s
+	}
+	if f.gc.GetPlantSeasons(int(plant.Id)) < 2 {
+		return 1
+	}
+	pd := f.gc.GetPlantPhaseData(int(plant.Id))
+	if pd == nil || len(pd.Season2Phases) == 0 || pd.Season2GrowTime <= 0 {
+		return 1
+	}
+	return deriveSeason(plant.Phases, pd, timeReducePct)
+}
+
+// deriveSeason is the phase-layout part of plantSeason.
+func deriveSeason(phases []*plantpb.PlantPhaseInfo, pd *PlantPhaseData, timeReducePct int64) int64 {
+	growth := 0
+	for _, p := range phases {
+		switch plantpb.PlantPhase(p.Phase) {
+		case plantpb.PlantPhase_MATURE, plantpb.PlantPhase_DEAD, plantpb.PlantPhase_PHASE_UNKNOWN:
+		default:
+			growth++
+		}
+	}
+	if growth > len(pd.Season2Phases) {
+		return 1
+	}
+	start, mature := getPlantStartTimeSec(phases), getMatureTimeSec(phases)
+	if start <= 0 || mature <= start {
+		return 1
+	}
+	span := mature - start
+	s1 := effectiveGrowSec(pd.TotalGrowTime, 0, timeReducePct)
+	s2 := effectiveGrowSec(pd.Season2GrowTime, 0, timeReducePct)
+	if abs64(span-s2) < abs64(span-s1) {
+		return 2
+	}
+	return 1
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 func getMatureTimeSec(phases []*plantpb.PlantPhaseInfo) int64 {
 	for _, p := range phases {
 		if plantpb.PlantPhase(p.Phase) == plantpb.PlantPhase_MATURE {
@@ -722,19 +976,45 @@ func isOccupiedSlaveLand(land *plantpb.LandInfo, landMap map[int64]*plantpb.Land
 	return true
 }
 
-func (f *FarmWorker) harvest(landIDs []int64) error {
+// harvest harvests landIDs in one batch and returns what the reply credited.
+func (f *FarmWorker) harvest(landIDs []int64) (harvestYield, error) {
 	gid, _, _, _, _ := f.net.state.Get()
+	if !f.throttle.allow("Harvest", landIDs, f.net.clock.Now()) {
+		f.logger.Debugf("限流", "Harvest 地%v 在 %s 内重复, 已跳过", landIDs, f.throttle.window)
+		return harvestYield{}, ErrThrottled
+	}
 	req := &plantpb.HarvestRequest{LandIds: landIDs, HostGid: gid, IsAll: true}
 	body, _ := proto.Marshal(req)
-	_, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Harvest", body)
-	return err
+	replyBody, err := sendWithRoom(f.net, f.logger, f.bagFull, body)
+	if err != nil {
+		return harvestYield{}, err
+	}
+	yield, err := decodeHarvestReply(replyBody, f.gc)
+	if err != nil {
+		// The harvest went through; only the accounting is lost
+		f.logger.Warnf("收获", "解析收获结果失败: %v", err)
+	}
+	return yield, nil
+}
+
+// recordHarvest logs and records the yield of a harvest of n lands.
+func (f *FarmWorker) recordHarvest(n int, yield harvestYield) {
+	f.logger.Infof("收获", "收获 %d 块: %s", n, yield.describe(f.gc))
+	if yield.Lands > 0 && yield.Lands != n {
+		f.logger.Debugf("收获", "回包土地数 %d 与请求 %d 不一致", yield.Lands, n)
+	}
+	f.sc.Record(model.OpHarvest, int64(n), yield.Gold, yield.Exp)
+	if f.stats != nil {
+		f.stats.recordHarvest(n, yield.Exp, yield.cropCounts(f.gc), f.net.clock.Now())
+	}
+	f.harvested.fire()
 }
 
 func (f *FarmWorker) waterLand(landIDs []int64) error {
 	gid, _, _, _, _ := f.net.state.Get()
 	req := &plantpb.WaterLandRequest{LandIds: landIDs, HostGid: gid}
 	body, _ := proto.Marshal(req)
-	_, err := f.net.SendRequest("gamepb.plantpb.PlantService", "WaterLand", body)
+	_, err := f.sendLandAction("WaterLand", landIDs, body)
 	return err
 }
 
@@ -742,7 +1022,7 @@ func (f *FarmWorker) weedOut(landIDs []int64) error {
 	gid, _, _, _, _ := f.net.state.Get()
 	req := &plantpb.WeedOutRequest{LandIds: landIDs, HostGid: gid}
 	body, _ := proto.Marshal(req)
-	_, err := f.net.SendRequest("gamepb.plantpb.PlantService", "WeedOut", body)
+	_, err := f.sendLandAction("WeedOut", landIDs, body)
 	return err
 }
 
@@ -750,7 +1030,7 @@ func (f *FarmWorker) insecticide(landIDs []int64) error {
 	gid, _, _, _, _ := f.net.state.Get()
 	req := &plantpb.InsecticideRequest{LandIds: landIDs, HostGid: gid}
 	body, _ := proto.Marshal(req)
-	_, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Insecticide", body)
+	_, err := f.sendLandAction("Insecticide", landIDs, body)
 	return err
 }
 
@@ -759,7 +1039,7 @@ func (f *FarmWorker) insecticide(landIDs []int64) error {
 func (f *FarmWorker) removePlantAndCollectFreed(landIDs []int64) ([]int64, error) {
 	req := &plantpb.RemovePlantRequest{LandIds: landIDs}
 	body, _ := proto.Marshal(req)
-	replyBody, err := f.net.SendRequest("gamepb.plantpb.PlantService", "RemovePlant", body)
+	replyBody, err := f.sendLandAction("RemovePlant", landIDs, body)
 	if err != nil {
 		return landIDs, err
 	}
@@ -790,7 +1070,7 @@ func (f *FarmWorker) fertilize(landIDs []int64) int {
 	for _, id := range landIDs {
 		req := &plantpb.FertilizeRequest{LandIds: []int64{id}, FertilizerId: normalFertilizerID}
 		body, _ := proto.Marshal(req)
-		if _, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Fertilize", body); err != nil {
+		if _, err := f.sendLandAction("Fertilize", []int64{id}, body); err != nil {
 			break
 		}
 		success++
@@ -924,7 +1204,7 @@ func (f *FarmWorker) plantFromBag(lands []int64) int {
 				Items: []*plantpb.PlantItem{{SeedId: seed.itemID, LandIds: []int64{landID}}},
 			}
 			plantBody, _ := proto.Marshal(plantReq)
-			replyBody, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Plant", plantBody)
+			replyBody, err := f.sendLandAction("Plant", []int64{landID}, plantBody)
 			if err != nil {
 				break
 			}
@@ -958,13 +1238,34 @@ func (f *FarmWorker) plantFromBag(lands []int64) int {
 	return planted
 }
 
+// buyAndPlant buys seeds from the shop and plants toLant. When a purchase
+// limit stops the chosen seed from covering every land, the rest is planted
+// with the next best seed.
 func (f *FarmWorker) buyAndPlant(toLant []int64, unlockedCount int) {
-	// Find best seed from shop (respects PlantCropID config)
-	bestSeed, err := f.findBestSeed(unlockedCount)
-	if err != nil || bestSeed == nil {
+	available, err := f.seedShopCandidates()
+	if err != nil {
 		return
 	}
+	for round := 0; round < maxSeedTypesPerPlant && len(toLant) > 0 && len(available) > 0; round++ {
+		// Find best seed from shop (respects PlantCropID config)
+		bestSeed, err := f.findBestSeed(available, unlockedCount)
+		if err != nil || bestSeed == nil {
+			return
+		}
+		rest, limited := f.buyAndPlantSeed(bestSeed, toLant)
+		if !limited || len(rest) == 0 {
+			return
+		}
+		f.logger.Infof("商店", "%s 已达限购, 剩余 %d 块地改种其他种子", f.gc.GetPlantNameBySeedID(int(bestSeed.ItemId)), len(rest))
+		toLant = rest
+		available = slices.DeleteFunc(available, func(c shopSeedCandidate) bool { return c.goods.Id == bestSeed.Id })
+	}
+}
 
+// buyAndPlantSeed buys bestSeed for toLant (capped by gold and its purchase
+// limit) and plants it. It returns the lands left unplanted and whether the
+// purchase limit was what fell short.
+func (f *FarmWorker) buyAndPlantSeed(bestSeed *shoppb.GoodsInfo, toLant []int64) (rest []int64, limited bool) {
 	seedName := f.gc.GetPlantNameBySeedID(int(bestSeed.ItemId))
 	f.logger.Infof("商店", "最佳种子: %s 价格=%d金币", seedName, bestSeed.Price)
 
@@ -979,7 +1280,13 @@ func (f *FarmWorker) buyAndPlant(toLant []int64, unlockedCount int) {
 		needCount = int64(len(toLant) / landFootprint)
 		if needCount <= 0 {
 			f.logger.Warnf("种植", "%s 需要至少 %d 块空地才能种植，当前仅 %d 块", seedName, landFootprint, len(toLant))
-			return
+			return toLant, false
+		}
+	}
+	if remaining := f.purchases.remaining(bestSeed, f.net.GameDate()); remaining >= 0 && needCount > remaining {
+		needCount, limited = remaining, true
+		if needCount <= 0 {
+			return toLant, true
 		}
 	}
 	totalCost := bestSeed.Price * needCount
@@ -987,28 +1294,50 @@ func (f *FarmWorker) buyAndPlant(toLant []int64, unlockedCount int) {
 		canBuy := gold / bestSeed.Price
 		if canBuy <= 0 {
 			f.logger.Warnf("商店", "金币不足")
-			return
+			return toLant, false
 		}
-		needCount = canBuy
+		needCount, limited = canBuy, false
 	}
 
-	buyReq := &shoppb.BuyGoodsRequest{GoodsId: bestSeed.Id, Num: needCount, Price: bestSeed.Price}
-	buyBody, _ := proto.Marshal(buyReq)
-	buyReplyBody, err := f.net.SendRequest("gamepb.shoppb.ShopService", "BuyGoods", buyBody)
+	buyReplyBody, err := f.buyGoods(bestSeed, needCount)
+	if asServerError(err).IsPriceChanged() {
+		// ShopInfo was stale; retry once at the current price
+		if fresh := f.currentGoods(bestSeed.Id); fresh != nil && fresh.Price*needCount <= gold {
+			f.logger.Infof("购买", "%s 价格变为 %d金币, 重试", seedName, fresh.Price)
+			bestSeed = fresh
+			buyReplyBody, err = f.buyGoods(bestSeed, needCount)
+		}
+	}
 	if err != nil {
 		f.logger.Warnf("购买", "%v", err)
-		return
+		if asServerError(err).IsLimitReached() {
+			f.purchases.exhaust(bestSeed, f.net.GameDate())
+			return toLant, true
+		}
+		return toLant, false
 	}
+	f.purchases.record(bestSeed, needCount, f.net.GameDate())
 	buyReply := &shoppb.BuyGoodsReply{}
 	proto.Unmarshal(buyReplyBody, buyReply)
 
-	actualSeedID := bestSeed.ItemId
-	if len(buyReply.GetItems) > 0 && buyReply.GetItems[0].Id > 0 {
-		actualSeedID = buyReply.GetItems[0].Id
+	actualSeedID, extras := boughtSeed(f.gc, buyReply.GetItems, bestSeed.ItemId)
+	if actualSeedID != bestSeed.ItemId {
+		f.logger.Warnf("购买", "购买结果中的种子(%d)与商品种子(%d)不一致, 按 %d 种植", actualSeedID, bestSeed.ItemId, actualSeedID)
+	}
+	if len(extras) > 0 {
+		var names []string
+		for _, item := range extras {
+			names = append(names, fmt.Sprintf("%sx%d", f.gc.GetItemName(int(item.Id)), item.Count))
+		}
+		f.logger.Infof("购买", "附赠物品: %s", strings.Join(names, ", "))
 	}
 	f.logger.Infof("购买", "已购买 %s种子 x%d", f.gc.GetPlantNameBySeedID(int(actualSeedID)), needCount)
-	seedCost := bestSeed.Price * needCount
+	seedCost := goldCost(buyReply.CostItems)
+	if seedCost == 0 {
+		seedCost = bestSeed.Price * needCount
+	}
 	f.sc.Record(model.OpBuySeed, needCount, -seedCost, 0)
+	f.sc.RecordSpend(model.SpendSeed, seedCost, fmt.Sprintf("%s种子×%d", f.gc.GetPlantNameBySeedID(int(actualSeedID)), needCount))
 
 	planted := 0
 	var plantedOnLands []string
@@ -1028,7 +1357,7 @@ func (f *FarmWorker) buyAndPlant(toLant []int64, unlockedCount int) {
 			Items: []*plantpb.PlantItem{{SeedId: actualSeedID, LandIds: []int64{landID}}},
 		}
 		body, _ := proto.Marshal(req)
-		replyBody, err := f.net.SendRequest("gamepb.plantpb.PlantService", "Plant", body)
+		replyBody, err := f.sendLandAction("Plant", []int64{landID}, body)
 		if err != nil {
 			break
 		}
@@ -1053,10 +1382,85 @@ func (f *FarmWorker) buyAndPlant(toLant []int64, unlockedCount int) {
 		f.logger.Infof("种植", "商店种子 %s x%d → 地%s", actualSeedName, planted, strings.Join(plantedOnLands, " "))
 		f.sc.RecordSimple(model.OpPlant, int64(planted))
 	}
+	for _, id := range toLant {
+		if pendingLands[id] {
+			rest = append(rest, id)
+		}
+	}
+	return rest, limited
+}
+
+// feedLiveShop passes the live seed shop listing to GameConfig so yield
+// ranking sees new crops and current prices without a manual re-export.
+func (f *FarmWorker) feedLiveShop(goodsList []*shoppb.GoodsInfo) {
+	if f.gc == nil {
+		return
+	}
+	goods := liveSeedGoods(goodsList)
+	changed, err := f.gc.SetLiveShopData(goods)
+	if err != nil {
+		f.logger.Warnf("商店", "保存商店数据失败: %v", err)
+	}
+	if changed {
+		f.logger.Infof("商店", "已用实时商店数据更新种子配置 (%d 种)", len(goods))
+	}
 }
 
-func (f *FarmWorker) findBestSeed(landsCount int) (*shoppb.GoodsInfo, error) {
-	req := &shoppb.ShopInfoRequest{ShopId: 2} // Seed shop
+// boughtSeed picks the purchased seed out of a BuyGoodsReply's items: the
+// expected seed if present, else the first known seed, else expected. The
+// other items (bonus rewards bundled with the purchase) are returned as extras.
+func boughtSeed(gc *GameConfig, items []*corepb.Item, expected int64) (seedID int64, extras []*corepb.Item) {
+	pick := -1
+	for i, item := range items {
+		if item.GetId() == expected {
+			pick = i
+			break
+		}
+	}
+	if pick < 0 {
+		for i, item := range items {
+			if gc.IsSeedID(int(item.GetId())) {
+				pick = i
+				break
+			}
+		}
+	}
+	seedID = expected
+	if pick >= 0 {
+		seedID = items[pick].Id
+	}
+	for i, item := range items {
+		if i != pick && item.GetId() > 0 {
+			extras = append(extras, item)
+		}
+	}
+	return seedID, extras
+}
+
+func (f *FarmWorker) buyGoods(goods *shoppb.GoodsInfo, n int64) ([]byte, error) {
+	body, _ := proto.Marshal(&shoppb.BuyGoodsRequest{GoodsId: goods.Id, Num: n, Price: goods.Price})
+	return f.net.SendRequest("gamepb.shoppb.ShopService", "BuyGoods", body)
+}
+
+// currentGoods re-reads the seed shop for goodsID, nil when it is no
+// longer buyable.
+func (f *FarmWorker) currentGoods(goodsID int64) *shoppb.GoodsInfo {
+	available, err := f.seedShopCandidates()
+	if err != nil {
+		return nil
+	}
+	for _, c := range available {
+		if c.goods.Id == goodsID {
+			return c.goods
+		}
+	}
+	return nil
+}
+
+// seedShopCandidates fetches the seed shop and returns the goods this
+// account can buy now: unlocked, level met and purchase limit not reached.
+func (f *FarmWorker) seedShopCandidates() ([]shopSeedCandidate, error) {
+	req := &shoppb.ShopInfoRequest{ShopId: seedShopID}
 	body, _ := proto.Marshal(req)
 	replyBody, err := f.net.SendRequest("gamepb.shoppb.ShopService", "ShopInfo", body)
 	if err != nil {
@@ -1067,13 +1471,16 @@ func (f *FarmWorker) findBestSeed(landsCount int) (*shoppb.GoodsInfo, error) {
 	if len(reply.GoodsList) == 0 {
 		return nil, fmt.Errorf("种子商店无商品")
 	}
+	f.feedLiveShop(reply.GoodsList)
 
 	_, level, _, _, _ := f.net.state.Get()
 
 	var available []shopSeedCandidate
+	var eliminated []SeedElimination
 
 	for _, goods := range reply.GoodsList {
 		if !goods.Unlocked {
+			eliminated = append(eliminated, f.gc.seedOut(goods, SeedOutLocked))
 			continue
 		}
 		meetsConditions := true
@@ -1088,63 +1495,79 @@ func (f *FarmWorker) findBestSeed(landsCount int) (*shoppb.GoodsInfo, error) {
 			}
 		}
 		if !meetsConditions {
+			eliminated = append(eliminated, f.gc.seedOut(goods, SeedOutLevel))
 			continue
 		}
-		if goods.LimitCount > 0 && goods.BoughtNum >= goods.LimitCount {
+		if f.purchases.remaining(goods, f.net.GameDate()) == 0 {
+			eliminated = append(eliminated, f.gc.seedOut(goods, SeedOutLimit))
 			continue
 		}
 		available = append(available, shopSeedCandidate{goods: goods, requiredLevel: reqLevel})
 	}
+	f.shop.set(available, eliminated, f.net.clock.Now())
 
 	if len(available) == 0 {
 		return nil, fmt.Errorf("没有可购买的种子")
 	}
+	return available, nil
+}
 
-	// If a specific crop is configured, try to find its seed
-	if f.cfg.PlantCropID > 0 {
-		targetSeedID := f.gc.GetSeedIDForCrop(f.cfg.PlantCropID)
-		if targetSeedID > 0 {
-			for _, c := range available {
-				if int(c.goods.ItemId) == targetSeedID {
-					return c.goods, nil
-				}
-			}
-			f.logger.Warnf("商店", "指定作物(ID:%d)的种子不可购买，使用自动选择", f.cfg.PlantCropID)
-		}
-	}
-	// Strategy-based selection: composable rules pipeline
-	strategy := ParsePlantingStrategy(f.cfg.PlantingStrategy)
-	if strategy != nil {
-		result := f.selectSeedByStrategy(strategy, available, landsCount)
-		if result != nil {
-			return result, nil
-		}
-		f.logger.Warnf("策略", "策略筛选无匹配作物，回退默认选择")
+// findBestSeed picks the seed to buy from available (respects PlantCropID,
+// strategy and ForceLowest config). The choice itself is made by planSeed.
+func (f *FarmWorker) findBestSeed(available []shopSeedCandidate, landsCount int) (*shoppb.GoodsInfo, error) {
+	plan, err := f.bestSeedPlan(available, landsCount)
+	if err != nil {
+		return nil, err
 	}
+	return plan.goods, nil
+}
 
-	if f.cfg.ForceLowest {
-		best := available[0]
-		for _, c := range available[1:] {
-			if c.requiredLevel < best.requiredLevel || (c.requiredLevel == best.requiredLevel && c.goods.Price < best.goods.Price) {
-				best = c
-			}
-		}
-		return best.goods, nil
+// bestSeedPlan is findBestSeed returning the whole plan, logging its notes.
+func (f *FarmWorker) bestSeedPlan(available []shopSeedCandidate, landsCount int) (*SeedPlan, error) {
+	_, level, _, gold, _ := f.net.state.Get()
+	plan := f.gc.planSeed(seedPlanInput{
+		cfg:            f.cfg,
+		conserve:       f.gate.observe(gold),
+		level:          level,
+		landsCount:     landsCount,
+		available:      available,
+		expMultiplier:  f.events.ExpMultiplier(),
+		fastestLevelUp: f.fastestLevelUpSeed,
+	})
+	if plan.goods == nil {
+		return nil, fmt.Errorf("没有可购买的种子")
+	}
+	for _, note := range plan.Notes {
+		f.logger.Warnf("商店", "%s", note)
 	}
+	name := f.gc.GetPlantNameBySeedID(int(plan.goods.ItemId))
+	switch {
+	case plan.Degraded:
+		f.logger.Debugf("商店", "游戏配置缺失, 按等级选择种子")
+	case plan.Rule == SeedRuleDoubleExp:
+		f.logger.Infof("活动", "多倍经验(×%.0f)期间选择经验效率最高的种子 → %s", f.events.ExpMultiplier(), name)
+	case plan.Rule == SeedRuleStrategy:
+		f.logger.Infof("策略", "%s → %s", FormatStrategyDescription(ParsePlantingStrategy(f.cfg.PlantingStrategy)), name)
+	}
+	return plan, nil
+}
 
-	// Try efficiency-based selection first
-	if f.gc != nil {
-		rec := f.gc.GetPlantingRecommendation(int(level), landsCount, 50)
-		for _, r := range rec {
-			for _, c := range available {
-				if c.goods.ItemId == int64(r.SeedID) {
-					return c.goods, nil
-				}
-			}
+// cheapestSeed picks the seed with the lowest price, the lower level one on
+// a tie.
+func cheapestSeed(available []shopSeedCandidate) *shoppb.GoodsInfo {
+	best := available[0]
+	for _, c := range available[1:] {
+		if c.goods.Price < best.goods.Price ||
+			(c.goods.Price == best.goods.Price && c.requiredLevel < best.requiredLevel) {
+			best = c
 		}
 	}
+	return best.goods
+}
 
-	// Fallback: level-based selection
+// levelBasedSeed picks the lowest-level seed early in the game and the
+// highest-level one afterwards. It needs no game config data.
+func levelBasedSeed(available []shopSeedCandidate, level int64) *shoppb.GoodsInfo {
 	if level <= 28 {
 		best := available[0]
 		for _, c := range available[1:] {
@@ -1152,7 +1575,7 @@ func (f *FarmWorker) findBestSeed(landsCount int) (*shoppb.GoodsInfo, error) {
 				best = c
 			}
 		}
-		return best.goods, nil
+		return best.goods
 	}
 
 	best := available[0]
@@ -1161,7 +1584,7 @@ func (f *FarmWorker) findBestSeed(landsCount int) (*shoppb.GoodsInfo, error) {
 			best = c
 		}
 	}
-	return best.goods, nil
+	return best.goods
 }
 
 func (f *FarmWorker) findFastestLevelUpSeed(emptyLandIDs []int64, available []shopSeedCandidate) *shoppb.GoodsInfo {
@@ -1183,7 +1606,7 @@ func (f *FarmWorker) findFastestLevelUpSeed(emptyLandIDs []int64, available []sh
 	harvestInfos := f.lands.GetHarvestInfo()
 	landBuffs := f.lands.GetLandBuffsByID(emptyLandIDs)
 
-	nowSec := time.Now().Unix()
+	nowSec := f.net.clock.Now().Unix()
 
 	type harvestEvent struct {
 		timeSec int64
@@ -1205,18 +1628,7 @@ func (f *FarmWorker) findFastestLevelUpSeed(emptyLandIDs []int64, available []sh
 			seasons = f.gc.GetPlantSeasons(int(h.CropID))
 			if seasons >= 2 {
 				if pd := f.gc.GetPlantPhaseData(int(h.CropID)); pd != nil && pd.Season2GrowTime > 0 {
-					s2Base := int64(pd.Season2GrowTime)
-					if h.TimeReducePct > 0 {
-						s2Base = s2Base * (10000 - h.TimeReducePct) / 10000
-					}
-					s2Fert := int64(pd.Season2MaxPhase)
-					if h.TimeReducePct > 0 {
-						s2Fert = s2Fert * (10000 - h.TimeReducePct) / 10000
-					}
-					season2GrowSec = s2Base - s2Fert
-					if season2GrowSec < 1 {
-						season2GrowSec = 1
-					}
+					season2GrowSec = yield.SeasonTime(pd.Season2GrowTime, pd.Season2MaxPhase, h.TimeReducePct)
 				}
 			}
 		}
@@ -1303,18 +1715,7 @@ func (f *FarmWorker) findFastestLevelUpSeed(emptyLandIDs []int64, available []sh
 
 			buff := landBuffs[landID]
 
-			s1Base := int64(yr.GrowTimeSec)
-			if buff.TimeReducePct > 0 {
-				s1Base = s1Base * (10000 - buff.TimeReducePct) / 10000
-			}
-			s1Fert := int64(yr.NormalFertReduceSec)
-			if buff.TimeReducePct > 0 {
-				s1Fert = s1Fert * (10000 - buff.TimeReducePct) / 10000
-			}
-			s1Effective := s1Base - s1Fert
-			if s1Effective < 1 {
-				s1Effective = 1
-			}
+			s1Effective := yield.SeasonTime(yr.GrowTimeSec, yr.NormalFertReduceSec, buff.TimeReducePct)
 
 			adjustedExp := int64(yr.ExpHarvest) * (10000 + buff.ExpBonusPct) / 10000
 			if adjustedExp <= 0 {
@@ -1326,18 +1727,7 @@ func (f *FarmWorker) findFastestLevelUpSeed(emptyLandIDs []int64, available []sh
 
 			var s2Effective int64
 			if yr.Seasons >= 2 && yr.Season2GrowTimeSec > 0 {
-				s2Base := int64(yr.Season2GrowTimeSec)
-				if buff.TimeReducePct > 0 {
-					s2Base = s2Base * (10000 - buff.TimeReducePct) / 10000
-				}
-				s2Fert := int64(yr.Season2FertReduceSec)
-				if buff.TimeReducePct > 0 {
-					s2Fert = s2Fert * (10000 - buff.TimeReducePct) / 10000
-				}
-				s2Effective = s2Base - s2Fert
-				if s2Effective < 1 {
-					s2Effective = 1
-				}
+				s2Effective = yield.SeasonTime(yr.Season2GrowTimeSec, yr.Season2FertReduceSec, buff.TimeReducePct)
 				newEvents = append(newEvents, harvestEvent{timeSec: harvestTime1 + s2Effective, exp: adjustedExp})
 			}
 
@@ -1413,6 +1803,9 @@ func (f *FarmWorker) findFastestLevelUpSeed(emptyLandIDs []int64, available []sh
 // autoUnlockAndUpgrade checks all lands and attempts to unlock/upgrade eligible ones.
 func (f *FarmWorker) autoUnlockAndUpgrade(lands []*plantpb.LandInfo) (unlocked, upgraded int) {
 	_, level, _, gold, _ := f.net.state.Get()
+	if f.gate.observe(gold) {
+		return 0, 0
+	}
 
 	for _, land := range lands {
 		if !land.Unlocked && land.CouldUnlock {
@@ -1423,6 +1816,8 @@ func (f *FarmWorker) autoUnlockAndUpgrade(lands []*plantpb.LandInfo) (unlocked,
 				} else {
 					f.logger.Infof("解锁", "土地#%d 成功 (花费%d金币)", land.Id, cond.NeedGold)
 					f.sc.Record(model.OpUnlockLand, 1, -cond.NeedGold, 0)
+					f.sc.RecordSpend(model.SpendLandUnlock, cond.NeedGold, fmt.Sprintf("土地#%d", land.Id))
+					f.sc.RecordActivity(model.ActivityLandUnlock, map[string]any{"land_id": land.Id, "cost": cond.NeedGold})
 					unlocked++
 					gold -= cond.NeedGold
 				}
@@ -1438,6 +1833,10 @@ func (f *FarmWorker) autoUnlockAndUpgrade(lands []*plantpb.LandInfo) (unlocked,
 				} else {
 					f.logger.Infof("升级", "土地#%d Lv%d→Lv%d (花费%d金币)", land.Id, land.Level, land.Level+1, cond.NeedGold)
 					f.sc.Record(model.OpUpgradeLand, 1, -cond.NeedGold, 0)
+					f.sc.RecordSpend(model.SpendLandUpgrade, cond.NeedGold, fmt.Sprintf("土地#%d Lv%d→Lv%d", land.Id, land.Level, land.Level+1))
+					f.sc.RecordActivity(model.ActivityLandUpgrade, map[string]any{
+						"land_id": land.Id, "from": land.Level, "to": land.Level + 1, "cost": cond.NeedGold,
+					})
 					upgraded++
 					gold -= cond.NeedGold
 				}
@@ -1448,35 +1847,42 @@ func (f *FarmWorker) autoUnlockAndUpgrade(lands []*plantpb.LandInfo) (unlocked,
 	return
 }
 
-// selectSeedByStrategy builds SeedCandidates from shop data + yield cache,
-// applies the composable strategy rules, and returns the best matching shop goods.
-func (f *FarmWorker) selectSeedByStrategy(strategy *PlantingStrategyConfig, available []shopSeedCandidate, landsCount int) *shoppb.GoodsInfo {
-	if f.gc == nil {
-		return nil
-	}
-	if strategy != nil && strategy.Mode == StrategyModeFastestLevelUp {
-		_, _, statuses := f.lands.Get()
-		cropByLandID := make(map[int64]int64, len(statuses))
-		for _, ls := range statuses {
-			cropByLandID[ls.ID] = ls.CropID
-		}
-		emptyLandIDs := make([]int64, 0, len(statuses))
-		for _, ls := range statuses {
-			if !ls.Unlocked || ls.CropID > 0 {
+// fastestLevelUpSeed resolves the fastest_levelup strategy for the empty
+// lands in the land cache.
+func (f *FarmWorker) fastestLevelUpSeed(available []shopSeedCandidate) *shoppb.GoodsInfo {
+	_, _, statuses := f.lands.Get()
+	cropByLandID := make(map[int64]int64, len(statuses))
+	for _, ls := range statuses {
+		cropByLandID[ls.ID] = ls.CropID
+	}
+	emptyLandIDs := make([]int64, 0, len(statuses))
+	for _, ls := range statuses {
+		if !ls.Unlocked || ls.CropID > 0 {
+			continue
+		}
+		if ls.MasterLandID > 0 && ls.MasterLandID != ls.ID {
+			if cropByLandID[ls.MasterLandID] > 0 {
 				continue
 			}
-			if ls.MasterLandID > 0 && ls.MasterLandID != ls.ID {
-				if cropByLandID[ls.MasterLandID] > 0 {
-					continue
-				}
-			}
-			emptyLandIDs = append(emptyLandIDs, ls.ID)
 		}
-		return f.findFastestLevelUpSeed(emptyLandIDs, available)
+		emptyLandIDs = append(emptyLandIDs, ls.ID)
+	}
+	return f.findFastestLevelUpSeed(emptyLandIDs, available)
+}
+
+// strategySeed builds SeedCandidates from shop data + yield cache, applies
+// the composable strategy rules, and returns the best matching shop goods.
+// The fastest_levelup mode is delegated to fastest.
+func (gc *GameConfig) strategySeed(strategy *PlantingStrategyConfig, available []shopSeedCandidate, fastest func([]shopSeedCandidate) *shoppb.GoodsInfo) *shoppb.GoodsInfo {
+	if gc == nil {
+		return nil
+	}
+	if strategy != nil && strategy.Mode == StrategyModeFastestLevelUp {
+		return fastest(available)
 	}
 
 	// Build yield lookup from game config
-	yieldRows := f.gc.GetSeedYieldRows()
+	yieldRows := gc.GetSeedYieldRows()
 	yieldMap := make(map[int]*SeedYieldRow, len(yieldRows))
 	for i := range yieldRows {
 		yieldMap[yieldRows[i].SeedID] = &yieldRows[i]
@@ -1489,7 +1895,7 @@ func (f *FarmWorker) selectSeedByStrategy(strategy *PlantingStrategyConfig, avai
 		sc := SeedCandidate{
 			SeedID:        seedID,
 			GoodsID:       c.goods.Id,
-			Name:          f.gc.GetPlantNameBySeedID(seedID),
+			Name:          gc.GetPlantNameBySeedID(seedID),
 			RequiredLevel: int(c.requiredLevel),
 			Price:         int(c.goods.Price),
 		}
@@ -1523,8 +1929,6 @@ func (f *FarmWorker) selectSeedByStrategy(strategy *PlantingStrategyConfig, avai
 	topSeedID := result[0].SeedID
 	for _, c := range available {
 		if int(c.goods.ItemId) == topSeedID {
-			desc := FormatStrategyDescription(strategy)
-			f.logger.Infof("策略", "%s → %s", desc, result[0].Name)
 			return c.goods
 		}
 	}
@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/corepb"
+	"qq-farm-bot/proto/plantpb"
+	"qq-farm-bot/proto/shoppb"
+)
+
+// newTestFarm is a FarmWorker over gc whose clock reads now. It has no
+// connection; methods that send requests need connectFakeGame first.
+func newTestFarm(gc *GameConfig, now time.Time) *FarmWorker {
+	clock := newFakeClock(now)
+	return &FarmWorker{
+		net:        NewNetwork(quietLogger(), nil, clock),
+		logger:     quietLogger(),
+		cfg:        &BotConfig{},
+		gc:         gc,
+		lands:      NewLandCache(clock),
+		fertilized: make(map[int64]bool),
+		throttle:   newLandThrottle(0),
+		bagFull:    NewBagFull(),
+	}
+}
+
+// plantedLand is a land growing plant 1 through the given phases, each
+// lasting the given seconds from start, and maturing after the last.
+func plantedLand(id, start int64, phases []plantpb.PlantPhase, secs []int64) *plantpb.LandInfo {
+	plant := &plantpb.PlantInfo{Id: 1}
+	at := start
+	for i, p := range phases {
+		plant.Phases = append(plant.Phases, &plantpb.PlantPhaseInfo{Phase: int32(p), BeginTime: at})
+		at += secs[i]
+	}
+	plant.Phases = append(plant.Phases, &plantpb.PlantPhaseInfo{Phase: int32(plantpb.PlantPhase_MATURE), BeginTime: at})
+	return &plantpb.LandInfo{Id: id, Unlocked: true, Plant: plant}
+}
+
+func TestUpdateLandCacheTwoSeasonCrop(t *testing.T) {
+	gc := twoSeasonGameConfig(t)
+	start := testNow - 5000
+	lands := []*plantpb.LandInfo{
+		// The full layout: season 1
+		plantedLand(1, start, []plantpb.PlantPhase{plantpb.PlantPhase_SEED, plantpb.PlantPhase_GERMINATION,
+			plantpb.PlantPhase_LARGE_LEAVES, plantpb.PlantPhase_BLOOMING}, []int64{600, 600, 1200, 1800}),
+		// Only the regrown phases: season 2
+		plantedLand(2, start, []plantpb.PlantPhase{plantpb.PlantPhase_LARGE_LEAVES, plantpb.PlantPhase_BLOOMING},
+			[]int64{1200, 1800}),
+		// A regrowth the land buff sped up by 10% is still season 2
+		plantedLand(3, start, []plantpb.PlantPhase{plantpb.PlantPhase_LARGE_LEAVES, plantpb.PlantPhase_BLOOMING},
+			[]int64{1080, 1620}),
+		// The server's season wins over the layout
+		plantedLand(4, start, []plantpb.PlantPhase{plantpb.PlantPhase_LARGE_LEAVES, plantpb.PlantPhase_BLOOMING},
+			[]int64{1200, 1800}),
+	}
+	lands[2].Buff = &plantpb.LandInfo_Buff{PlantingTimeReduction: 1000}
+	lands[3].Plant.Season = 1
+	want := map[int64]int64{1: 1, 2: 2, 3: 2, 4: 1}
+
+	f := newTestFarm(gc, time.Unix(testNow, 0))
+	f.updateLandCache(lands)
+
+	_, _, statuses := f.lands.Get()
+	for _, ls := range statuses {
+		if ls.Season != want[ls.ID] {
+			t.Errorf("land %d status season = %d, want %d", ls.ID, ls.Season, want[ls.ID])
+		}
+	}
+	harvests := f.lands.GetHarvestInfo()
+	if len(harvests) != len(lands) {
+		t.Fatalf("%d harvest infos, want %d", len(harvests), len(lands))
+	}
+	byLand := make(map[int64]LandHarvestInfo)
+	for _, hi := range harvests {
+		byLand[hi.LandID] = hi
+		if hi.CropID != 1 || !hi.IsMature || hi.Season != want[hi.LandID] {
+			t.Errorf("land %d harvest info = %+v, want mature crop 1 in season %d", hi.LandID, hi, want[hi.LandID])
+		}
+	}
+
+	// Harvesting the season-1 crop schedules its second season: 100 exp now
+	// and 100 more once the 1200s fertilized regrowth matures
+	estimate := func(id int64) levelUpEstimate {
+		return estimateLevelUp(levelUpInputs{NowSec: testNow, ExpToNextLevel: 200, GC: gc,
+			Harvests: []LandHarvestInfo{byLand[id]}})
+	}
+	if got := estimate(1); !approx(got.HoursToNextLevel, 1200.0/3600.0) {
+		t.Errorf("season 1 land: %+v, want the second season in 1200s", got)
+	}
+	if got := estimate(2); got.HoursToNextLevel <= 1200.0/3600.0 {
+		t.Errorf("season 2 land: %+v, counted a third season", got)
+	}
+}
+
+func TestUpdateLandCacheNeeds(t *testing.T) {
+	gc := twoSeasonGameConfig(t)
+	start := testNow - 100
+	phases, secs := []plantpb.PlantPhase{plantpb.PlantPhase_SEED, plantpb.PlantPhase_GERMINATION}, []int64{600, 600}
+	dry := plantedLand(1, start, phases, secs)
+	dry.Plant.Phases[0].DryTime = testNow - 10
+	dry.Plant.Phases[0].FertsUsed = map[int64]int64{normalFertilizerID: 1}
+	soaked := plantedLand(2, start, phases, secs)
+	soaked.Plant.DryNum = 1
+	fine := plantedLand(3, start, phases, secs)
+	fine.Plant.Phases[0].DryTime = testNow + 10
+	fine.Plant.Phases[1].FertsUsed = map[int64]int64{normalFertilizerID: 1} // not reached yet
+
+	f := newTestFarm(gc, time.Unix(testNow, 0))
+	f.updateLandCache([]*plantpb.LandInfo{dry, soaked, fine})
+	_, _, statuses := f.lands.Get()
+	want := map[int64][2]bool{1: {true, true}, 2: {true, false}, 3: {false, false}}
+	for _, ls := range statuses {
+		if got := [2]bool{ls.NeedsWater, ls.Fertilized}; got != want[ls.ID] {
+			t.Errorf("land %d needs_water, fertilized = %v, want %v", ls.ID, got, want[ls.ID])
+		}
+	}
+}
+
+func TestBoughtSeed(t *testing.T) {
+	gc := newGameConfig()
+	gc.load(writeSmallGameConfig(t)) // seeds 20001 and 20002
+	item := func(id, n int64) *corepb.Item { return &corepb.Item{Id: id, Count: n} }
+
+	cases := []struct {
+		name   string
+		items  []*corepb.Item
+		seed   int64
+		extras []int64
+	}{
+		{"seed only", []*corepb.Item{item(20001, 5)}, 20001, nil},
+		{"bonus items first", []*corepb.Item{item(1001, 20), item(80001, 1), item(20001, 5)}, 20001, []int64{1001, 80001}},
+		{"another known seed", []*corepb.Item{item(1001, 20), item(20002, 5)}, 20002, []int64{1001}},
+		{"expected seed wins over another seed", []*corepb.Item{item(20002, 1), item(20001, 5)}, 20001, []int64{20002}},
+		{"no seed falls back", []*corepb.Item{item(1001, 20), item(0, 3)}, 20001, []int64{1001}},
+		{"empty reply", nil, 20001, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := proto.Marshal(&shoppb.BuyGoodsReply{GetItems: tc.items})
+			reply := &shoppb.BuyGoodsReply{}
+			if err := proto.Unmarshal(body, reply); err != nil {
+				t.Fatal(err)
+			}
+			seed, extras := boughtSeed(gc, reply.GetItems, 20001)
+			var extraIDs []int64
+			for _, e := range extras {
+				extraIDs = append(extraIDs, e.Id)
+			}
+			if seed != tc.seed || !slices.Equal(extraIDs, tc.extras) {
+				t.Errorf("seed %d, extras %v; want %d, %v", seed, extraIDs, tc.seed, tc.extras)
+			}
+		})
+	}
+}
+
+func TestCheckFarmKeepsNoHarvestCrops(t *testing.T) {
+	gc := newGameConfig()
+	gc.load(writeSmallGameConfig(t)) // plants 1 and 2
+	mature := func(id, plant int64) *plantpb.LandInfo {
+		land := plantedLand(id, testNow-1000, []plantpb.PlantPhase{plantpb.PlantPhase_SEED}, []int64{60})
+		land.Plant.Id = plant
+		return land
+	}
+
+	cases := []struct {
+		name      string
+		noHarvest string
+		lands     []*plantpb.LandInfo
+		harvested []int64 // nil for no Harvest request
+	}{
+		{"kept crop only", "1", []*plantpb.LandInfo{mature(1, 1)}, nil},
+		{"kept next to a harvestable crop", "1", []*plantpb.LandInfo{mature(1, 1), mature(2, 2)}, []int64{2}},
+		{"nothing kept", "", []*plantpb.LandInfo{mature(1, 1), mature(2, 2)}, []int64{1, 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newTestFarm(gc, time.Unix(testNow, 0))
+			f.cfg = &BotConfig{EnableHarvest: true, EnablePlant: true, EnableRemoveDead: true,
+				EnableWater: true, EnableWeed: true, EnableBug: true, NoHarvestCropIDs: tc.noHarvest}
+			game := connectFakeGame(t, f.net, map[string]gameHandler{
+				"AllLands": func([]byte) (proto.Message, int64) {
+					return &plantpb.AllLandsReply{Lands: tc.lands}, 0
+				},
+			})
+			f.checkFarm()
+
+			requests := game.requests("Harvest")
+			if tc.harvested == nil {
+				if len(requests) != 0 {
+					t.Fatalf("%d Harvest requests for kept crops", len(requests))
+				}
+				// Nothing else was sent either: a kept land is no work
+				if got := len(game.requests("AllLands")); got != len(game.calls) {
+					t.Errorf("sent %d requests besides AllLands", len(game.calls)-got)
+				}
+			} else {
+				if len(requests) != 1 {
+					t.Fatalf("%d Harvest requests, want 1", len(requests))
+				}
+				req := &plantpb.HarvestRequest{}
+				proto.Unmarshal(requests[0], req)
+				if !slices.Equal(req.LandIds, tc.harvested) {
+					t.Errorf("harvested lands %v, want %v", req.LandIds, tc.harvested)
+				}
+			}
+			_, _, statuses := f.lands.Get()
+			for _, ls := range statuses {
+				if want := tc.noHarvest != "" && ls.ID == 1; ls.Kept != want {
+					t.Errorf("land %d kept = %v, want %v", ls.ID, ls.Kept, want)
+				}
+			}
+		})
+	}
+}
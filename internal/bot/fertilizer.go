@@ -39,10 +39,16 @@ const (
 
 // FertilizerWorker handles automatic fertilizer pack buying, opening, and usage.
 type FertilizerWorker struct {
-	net    *Network
-	logger *Logger
-	cfg    *BotConfig
-	sc     *StatsCollector
+	net     *Network
+	logger  *Logger
+	profile *Profile
+	cfg     *BotConfig // copy taken at the start of each pass
+	bag     *BagCache
+	events  *GameEvents
+	sc      *StatsCollector
+	state   *WorkerState
+	gate    *spendGate // no mall purchases in conserve mode
+	cycle   *cycleGuard
 
 	mu             sync.Mutex
 	dailyBuyCount  int
@@ -51,8 +57,30 @@ type FertilizerWorker struct {
 	lastBuyTime    time.Time
 }
 
-func NewFertilizerWorker(net *Network, logger *Logger, cfg *BotConfig, sc *StatsCollector) *FertilizerWorker {
-	return &FertilizerWorker{net: net, logger: logger, cfg: cfg, sc: sc}
+// fertilizerState is the FertilizerWorker checkpoint: the daily caps and the
+// buy cooldown.
+type fertilizerState struct {
+	Date      string    `json:"date"`
+	BuyCount  int       `json:"buy_count"`
+	OpenCount int       `json:"open_count"`
+	LastBuy   time.Time `json:"last_buy"`
+}
+
+func NewFertilizerWorker(net *Network, logger *Logger, profile *Profile, bag *BagCache, events *GameEvents, sc *StatsCollector, state *WorkerState, gate *spendGate) *FertilizerWorker {
+	fw := &FertilizerWorker{net: net, logger: logger, profile: profile, cfg: profile.Current(), bag: bag, events: events, sc: sc, state: state, gate: gate}
+	var saved fertilizerState
+	if state.Load(stateKeyFertilizer, stateVersionFertilizer, &saved) {
+		fw.dailyDate, fw.dailyBuyCount, fw.dailyOpenCount, fw.lastBuyTime = saved.Date, saved.BuyCount, saved.OpenCount, saved.LastBuy
+	}
+	return fw
+}
+
+// checkpoint saves the daily caps and buy cooldown.
+func (fw *FertilizerWorker) checkpoint() {
+	fw.mu.Lock()
+	saved := fertilizerState{Date: fw.dailyDate, BuyCount: fw.dailyBuyCount, OpenCount: fw.dailyOpenCount, LastBuy: fw.lastBuyTime}
+	fw.mu.Unlock()
+	fw.state.Save(stateKeyFertilizer, stateVersionFertilizer, saved)
 }
 
 func (fw *FertilizerWorker) RunLoop() {
@@ -60,27 +88,33 @@ func (fw *FertilizerWorker) RunLoop() {
 	if !fw.cfg.AutoUseFertilizer && !fw.cfg.AutoBuyFertilizer {
 		return
 	}
+	defer fw.checkpoint()
 
-	select {
-	case <-time.After(fertilizerInitialDelay):
-	case <-fw.net.ctx.Done():
+	if !sleepJitter(fw.net.ctx, fw.net.clock, fertilizerInitialDelay, fw.cfg.jitterPct()) {
 		return
 	}
 
-	fw.runFertilizerTask()
+	fw.cycle.run(fertilizerLoopInterval, fw.runFertilizerTask)
+	fw.checkpoint()
 
 	for {
-		select {
-		case <-time.After(fertilizerLoopInterval):
-			fw.runFertilizerTask()
-		case <-fw.net.ctx.Done():
+		// Crop time is worth more during double exp, so keep fertilizer
+		// stocked and used at the buy cooldown rather than hourly
+		interval := fertilizerLoopInterval
+		if fw.events.ExpMultiplier() > 1 {
+			interval = buyCooldown
+		}
+		if !sleepJitter(fw.net.ctx, fw.net.clock, interval, fw.cfg.jitterPct()) {
 			return
 		}
+		fw.cycle.run(interval, fw.runFertilizerTask)
+		fw.checkpoint()
 	}
 }
 
 // runFertilizerTask orchestrates: buy → open → use surplus.
 func (fw *FertilizerWorker) runFertilizerTask() {
+	fw.cfg = fw.profile.Current()
 	fw.resetDailyCounters()
 
 	items, err := fw.getBagItems()
@@ -89,12 +123,9 @@ func (fw *FertilizerWorker) runFertilizerTask() {
 		return
 	}
 
-	// Step 1: Buy fertilizer packs if enabled
+	// Step 1: Buy fertilizer packs if enabled (returns the re-fetched bag)
 	if fw.cfg.AutoBuyFertilizer {
-		fw.buyFertilizerPacks(items)
-		time.Sleep(throttleDelay)
-		// Re-fetch bag after buying
-		items, err = fw.getBagItems()
+		items, err = fw.buyFertilizerPacks(items)
 		if err != nil {
 			fw.logger.Warnf("化肥", "获取背包失败: %v", err)
 			return
@@ -121,18 +152,7 @@ func (fw *FertilizerWorker) runFertilizerTask() {
 
 // getBagItems fetches the current bag contents.
 func (fw *FertilizerWorker) getBagItems() ([]*corepb.Item, error) {
-	req := &itempb.BagRequest{}
-	body, _ := proto.Marshal(req)
-	replyBody, err := fw.net.SendRequest("gamepb.itempb.ItemService", "Bag", body)
-	if err != nil {
-		return nil, err
-	}
-	reply := &itempb.BagReply{}
-	proto.Unmarshal(replyBody, reply)
-	if reply.ItemBag == nil {
-		return nil, nil
-	}
-	return reply.ItemBag.Items, nil
+	return fetchBag(fw.net, fw.bag, GetGameConfig())
 }
 
 // findItem returns the item with the given ID from the bag, or nil.
@@ -183,7 +203,7 @@ func totalPackCount(items []*corepb.Item) int64 {
 func (fw *FertilizerWorker) resetDailyCounters() {
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
-	today := time.Now().Format("2006-01-02")
+	today := fw.net.GameDate()
 	if fw.dailyDate != today {
 		fw.dailyDate = today
 		fw.dailyBuyCount = 0
@@ -191,8 +211,9 @@ func (fw *FertilizerWorker) resetDailyCounters() {
 	}
 }
 
-// buyFertilizerPacks purchases fertilizer packs from MallService using coupons.
-func (fw *FertilizerWorker) buyFertilizerPacks(items []*corepb.Item) {
+// buyFertilizerPacks spends coupons on fertilizer packs and returns the bag
+// as it stands afterwards (items unchanged when nothing was bought).
+func (fw *FertilizerWorker) buyFertilizerPacks(items []*corepb.Item) ([]*corepb.Item, error) {
 	fw.mu.Lock()
 	dailyLimit := fw.cfg.FertilizerBuyDailyLimit
 	alreadyBought := fw.dailyBuyCount
@@ -201,36 +222,50 @@ func (fw *FertilizerWorker) buyFertilizerPacks(items []*corepb.Item) {
 
 	// Check daily limit
 	if dailyLimit > 0 && alreadyBought >= dailyLimit {
-		return
+		return items, nil
 	}
 
 	// Check buy cooldown
-	if time.Since(lastBuy) < buyCooldown {
-		return
+	if fw.net.clock.Now().Sub(lastBuy) < buyCooldown {
+		return items, nil
+	}
+
+	if _, _, _, gold, _ := fw.net.state.Get(); fw.gate.observe(gold) {
+		return items, nil
 	}
 
 	// Check container limit (don't buy if containers are near full)
 	normalHours := containerHours(items, normalContainerID)
 	if normalHours >= containerLimitHours {
 		fw.logger.Infof("化肥", "普通化肥容器已满 (%d小时), 跳过购买", normalHours)
-		return
+		return items, nil
 	}
 
 	// Check coupon balance
 	couponBalance := getItemCount(items, couponItemID)
 	if couponBalance <= 0 {
-		return
+		return items, nil
 	}
 
 	// Get mall info to check price
 	price, err := fw.getMallFertilizerPrice()
 	if err != nil || price <= 0 {
-		return
+		return items, nil
+	}
+
+	// A push since the bag fetch reported a different balance: trust
+	// neither and re-read the bag before spending
+	if pushed := fw.net.state.GetCoupons(); pushed != couponBalance {
+		fw.logger.Infof("化肥", "点券余额不一致 (背包:%d, 推送:%d), 重新获取背包", couponBalance, pushed)
+		if items, err = fw.getBagItems(); err != nil {
+			return nil, err
+		}
+		couponBalance = getItemCount(items, couponItemID)
 	}
 
 	if couponBalance < int64(price) {
 		fw.logger.Infof("化肥", "点券不足 (余额:%d, 价格:%d)", couponBalance, price)
-		return
+		return items, nil
 	}
 
 	// Calculate how many to buy
@@ -243,7 +278,7 @@ func (fw *FertilizerWorker) buyFertilizerPacks(items []*corepb.Item) {
 		}
 	}
 	if toBuy <= 0 {
-		return
+		return items, nil
 	}
 
 	// Purchase one at a time with throttle
@@ -265,13 +300,30 @@ func (fw *FertilizerWorker) buyFertilizerPacks(items []*corepb.Item) {
 
 	fw.mu.Lock()
 	fw.dailyBuyCount += bought
-	fw.lastBuyTime = time.Now()
+	fw.lastBuyTime = fw.net.clock.Now()
 	fw.mu.Unlock()
 
-	if bought > 0 {
-		fw.logger.Infof("化肥", "购买化肥礼包 x%d (今日累计:%d)", bought, fw.dailyBuyCount)
-		fw.sc.RecordSimple(model.OpFertBuy, int64(bought))
+	if bought == 0 {
+		return items, nil
+	}
+
+	time.Sleep(throttleDelay)
+	items, err = fw.getBagItems()
+	if err != nil {
+		return nil, err
 	}
+	balanceAfter := getItemCount(items, couponItemID)
+	fw.logger.Infof("化肥", "购买化肥礼包 x%d (今日累计:%d), 点券 %d→%d", bought, fw.dailyBuyCount, couponBalance, balanceAfter)
+	fw.sc.RecordSimple(model.OpFertBuy, int64(bought))
+	fw.sc.RecordActivity(model.ActivityCouponSpend, map[string]any{
+		"item":           "化肥礼包",
+		"goods_id":       mallFertilizerGoodsID,
+		"count":          bought,
+		"amount":         int64(price) * int64(bought),
+		"balance_before": couponBalance,
+		"balance_after":  balanceAfter,
+	})
+	return items, nil
 }
 
 // getMallFertilizerPrice queries the mall for the fertilizer pack price in coupons.
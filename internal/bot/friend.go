@@ -3,7 +3,9 @@ package bot
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -12,97 +14,88 @@ import (
 
 	"qq-farm-bot/proto/friendpb"
 	"qq-farm-bot/proto/plantpb"
-	"qq-farm-bot/proto/visitpb"
 )
 
 type FriendWorker struct {
-	net    *Network
-	logger *Logger
-	cfg    *BotConfig
-	gc     *GameConfig
-	stats  *BotStats
-	sc     *StatsCollector
+	net     *Network
+	logger  *Logger
+	profile *Profile
+	cfg     *BotConfig // resolved from profile each pass
+	gc      *GameConfig
+	stats   *BotStats
+	names   *FriendNames
+	bagFull *BagFull
+	sc      *StatsCollector
+	roster  friendRoster
+	// families and userID identify the running accounts of our family
+	families *Families
+	userID   int64
+	state    *WorkerState
+	visiting atomic.Int64 // host GID of the visit in progress, 0 if none
+	cycle    *cycleGuard  // set by the instance
 }
 
-type BotStats struct {
-	TotalSteal   int64
-	TotalHelp    int64
-	FriendsCount int
-}
-
-func NewFriendWorker(net *Network, logger *Logger, cfg *BotConfig, stats *BotStats, sc *StatsCollector) *FriendWorker {
-	return &FriendWorker{net: net, logger: logger, cfg: cfg, gc: GetGameConfig(), stats: stats, sc: sc}
+func NewFriendWorker(net *Network, logger *Logger, profile *Profile, stats *BotStats, names *FriendNames, bagFull *BagFull, sc *StatsCollector, families *Families, userID int64, state *WorkerState) *FriendWorker {
+	return &FriendWorker{net: net, logger: logger, profile: profile, cfg: profile.Current(), gc: GetGameConfig(), stats: stats, names: names, bagFull: bagFull, sc: sc, families: families, userID: userID, state: state}
 }
 
 func (fw *FriendWorker) RunLoop() {
-	// Initial delay: add jitter if anti-detection enabled
-	initDelay := 5 * time.Second
-	if fw.cfg.EnableAntiDetection {
-		initDelay = time.Duration(3+rand.Intn(5)) * time.Second
-	}
-	select {
-	case <-time.After(initDelay):
-	case <-fw.net.ctx.Done():
+	fw.leaveStaleVisit()
+	if !sleepJitter(fw.net.ctx, fw.net.clock, 5*time.Second, fw.cfg.jitterPct()) {
 		return
 	}
 
 	fw.checkAndAcceptApplications()
 
 	for {
-		fw.checkFriends()
-		waitTime := time.Duration(fw.cfg.FriendInterval) * time.Second
-		if fw.cfg.EnableAntiDetection {
-			// Add ±30% random jitter to the interval
-			base := float64(fw.cfg.FriendInterval)
-			jitter := base * (0.7 + rand.Float64()*0.6) // 0.7x ~ 1.3x
-			waitTime = time.Duration(jitter * float64(time.Second))
-		}
-		select {
-		case <-time.After(waitTime):
-		case <-fw.net.ctx.Done():
+		_, level, _, _, _ := fw.net.state.Get()
+		fw.cfg = fw.profile.Resolve(level)
+		fw.cycle.run(time.Duration(fw.cfg.FriendInterval)*time.Second, fw.checkFriends)
+		if !sleepJitter(fw.net.ctx, fw.net.clock, time.Duration(fw.cfg.FriendInterval)*time.Second, fw.cfg.jitterPct()) {
 			return
 		}
 	}
 }
 
 func (fw *FriendWorker) checkFriends() {
+	// Passes without steals count too, so the rate decays
+	var stealExp int64
+	defer func() { fw.stats.recordStealPass(stealExp, fw.net.clock.Now()) }()
+
 	gid, _, _, _, _ := fw.net.state.Get()
 	if gid == 0 {
 		return
 	}
 
-	friends := fw.fetchFriendList()
+	friends := fw.friendsToVisit()
 	if len(friends) == 0 {
 		return
 	}
-	fw.stats.FriendsCount = len(friends)
 
 	type friendTarget struct {
-		gid  int64
-		name string
+		gid    int64
+		name   string
+		family bool
 	}
 	var targets []friendTarget
 
+	// Our own family's accounts are never stolen from
+	family := fw.families.GIDs(fw.userID, fw.cfg.FamilyID)
 	for _, f := range friends {
 		if f.Gid == gid {
 			continue
 		}
-		name := f.Remark
-		if name == "" {
-			name = f.Name
-		}
-		if name == "" {
-			name = fmt.Sprintf("GID:%d", f.Gid)
-		}
+		name := friendDisplayName(f)
+		isFamily := family[f.Gid]
 
 		hasSteal := f.Plant != nil && f.Plant.StealPlantNum > 0
 		hasHelp := f.Plant != nil && (f.Plant.DryNum > 0 || f.Plant.WeedNum > 0 || f.Plant.InsectNum > 0)
 
-		canSteal := hasSteal && fw.cfg.EnableSteal
+		canSteal := hasSteal && fw.cfg.EnableSteal && !isFamily
 		canHelp := hasHelp && fw.cfg.EnableHelpFriend
 
 		if canSteal || canHelp {
-			targets = append(targets, friendTarget{gid: f.Gid, name: name})
+			targets = append(targets, friendTarget{gid: f.Gid, name: name, family: isFamily})
 		}
 	}
 
@@ -116,33 +109,44 @@ func (fw *FriendWorker) checkFriends() {
 			targets[i], targets[j] = targets[j], targets[i]
 		})
 	}
+	// Family members are helped first, so a per-cycle cap never drops them
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].family && !targets[j].family })
+	if n := fw.cfg.FriendsPerCycle; n > 0 && len(targets) > n {
+		targets = targets[:n]
+	}
 
 	totalActions := struct {
 		steal, water, weed, bug int
 	}{}
 
 	for _, t := range targets {
-		actions := fw.visitFriend(t.gid, t.name, gid)
+		if fw.stopping() {
+			break
+		}
+		actions := fw.visitFriend(t.gid, t.name, gid, t.family)
 		totalActions.steal += actions.steal
 		totalActions.water += actions.water
 		totalActions.weed += actions.weed
 		totalActions.bug += actions.bug
+		stealExp += actions.stealExp
 		// Record per-friend steal for data summary (friend ranking)
 		if actions.steal > 0 {
-			fw.sc.RecordWithDetail(model.OpSteal, int64(actions.steal), 0, 0, t.name)
+			fw.sc.RecordWithDetail(model.OpSteal, int64(actions.steal), actions.stealValue, actions.stealExp, t.name)
 		}
+		delay := 500 * time.Millisecond
 		if fw.cfg.EnableAntiDetection {
 			// Random delay between friend visits: 1~3 seconds
-			time.Sleep(time.Duration(1000+rand.Intn(2000)) * time.Millisecond)
-		} else {
-			time.Sleep(500 * time.Millisecond)
+			delay = time.Duration(1000+rand.Intn(2000)) * time.Millisecond
+		}
+		if !fw.pause(delay) {
+			break
 		}
 	}
 
 	var summary []string
 	if totalActions.steal > 0 {
 		summary = append(summary, fmt.Sprintf("偷%d", totalActions.steal))
-		fw.stats.TotalSteal += int64(totalActions.steal)
+		fw.stats.AddSteal(int64(totalActions.steal))
 	}
 	if totalActions.weed > 0 {
 		summary = append(summary, fmt.Sprintf("除草%d", totalActions.weed))
@@ -157,7 +161,7 @@ func (fw *FriendWorker) checkFriends() {
 		fw.sc.RecordSimple(model.OpHelpWater, int64(totalActions.water))
 	}
 	if totalActions.weed+totalActions.bug+totalActions.water > 0 {
-		fw.stats.TotalHelp += int64(totalActions.weed + totalActions.bug + totalActions.water)
+		fw.stats.AddHelp(int64(totalActions.weed + totalActions.bug + totalActions.water))
 	}
 	if len(summary) > 0 {
 		fw.logger.Infof("好友", "巡查 %d 人 → %s", len(targets), strings.Join(summary, "/"))
@@ -166,25 +170,20 @@ func (fw *FriendWorker) checkFriends() {
 
 type friendActions struct {
 	steal, water, weed, bug int
+	stealExp                int64
+	stealValue              int64 // sell value of the stolen fruit
 }
 
-func (fw *FriendWorker) visitFriend(friendGid int64, name string, myGid int64) friendActions {
+// visitFriend helps and steals on one friend's farm. Family members are
+// only helped.
+func (fw *FriendWorker) visitFriend(friendGid int64, name string, myGid int64, family bool) friendActions {
 	var actions friendActions
 
-	enterReq := &visitpb.EnterRequest{HostGid: friendGid, Reason: 2}
-	enterBody, _ := proto.Marshal(enterReq)
-	enterReplyBody, err := fw.net.SendRequest("gamepb.visitpb.VisitService", "Enter", enterBody)
+	enterReply, err := fw.enterFriend(friendGid)
 	if err != nil {
 		return actions
 	}
-	enterReply := &visitpb.EnterReply{}
-	proto.Unmarshal(enterReplyBody, enterReply)
-
-	defer func() {
-		leaveReq := &visitpb.LeaveRequest{HostGid: friendGid}
-		leaveBody, _ := proto.Marshal(leaveReq)
-		fw.net.SendRequest("gamepb.visitpb.VisitService", "Leave", leaveBody)
-	}()
+	defer fw.leaveFriend(friendGid, defaultRequestTimeout)
 
 	lands := enterReply.Lands
 	if len(lands) == 0 {
@@ -203,35 +202,44 @@ func (fw *FriendWorker) visitFriend(friendGid int64, name string, myGid int64) f
 				if _, err := fw.net.SendRequest("gamepb.plantpb.PlantService", "WeedOut", body); err == nil {
 					actions.weed++
 				}
-				fw.antiDetectionDelay(100)
+				if !fw.antiDetectionDelay(100) {
+					break
+				}
 			}
 		}
-		if len(status.needBug) > 0 {
+		if len(status.needBug) > 0 && !fw.stopping() {
 			for _, landID := range status.needBug {
 				req := &plantpb.InsecticideRequest{LandIds: []int64{landID}, HostGid: friendGid}
 				body, _ := proto.Marshal(req)
 				if _, err := fw.net.SendRequest("gamepb.plantpb.PlantService", "Insecticide", body); err == nil {
 					actions.bug++
 				}
-				fw.antiDetectionDelay(100)
+				if !fw.antiDetectionDelay(100) {
+					break
+				}
 			}
 		}
-		if len(status.needWater) > 0 {
+		if len(status.needWater) > 0 && !fw.stopping() {
 			for _, landID := range status.needWater {
 				req := &plantpb.WaterLandRequest{LandIds: []int64{landID}, HostGid: friendGid}
 				body, _ := proto.Marshal(req)
 				if _, err := fw.net.SendRequest("gamepb.plantpb.PlantService", "WaterLand", body); err == nil {
 					actions.water++
 				}
-				fw.antiDetectionDelay(100)
+				if !fw.antiDetectionDelay(100) {
+					break
+				}
 			}
 		}
 	}
 
-	if fw.cfg.EnableSteal && len(status.stealable) > 0 {
+	if family && len(status.stealable) > 0 {
+		fw.logger.Debugf("好友", "%s 是家族成员, 不偷取", name)
+	}
+	if fw.cfg.EnableSteal && !family && len(status.stealable) > 0 && !fw.bagFull.paused(fw.net.clock.Now()) && !fw.stopping() {
 		canSteal, _ := fw.checkCanSteal(friendGid)
 		if canSteal {
-			stealFilter := ParseCropIDs(fw.cfg.StealCropIDs)
+			stealFilter := fw.gc.CropFilter(fw.cfg.StealCropIDs)
 			hasStealFilter := len(stealFilter) > 0
 			stolenCrops := make(map[string]int)
 
@@ -241,7 +249,14 @@ func (fw *FriendWorker) visitFriend(friendGid int64, name string, myGid int64) f
 				}
 				req := &plantpb.HarvestRequest{LandIds: []int64{sl.landID}, HostGid: friendGid, IsAll: true}
 				body, _ := proto.Marshal(req)
-				replyBody, err := fw.net.SendRequest("gamepb.plantpb.PlantService", "Harvest", body)
+				replyBody, err := sendWithRoom(fw.net, fw.logger, fw.bagFull, body)
+				if se := asServerError(err); se.IsProtected() || se.IsNotFriend() {
+					fw.logger.Infof("好友", "停止偷取 %s: %s", name, se.Message)
+					if se.IsNotFriend() {
+						fw.net.friendsChanged.Store(true)
+					}
+					break
+				}
 				if err == nil {
 					reply := &plantpb.HarvestReply{}
 					proto.Unmarshal(replyBody, reply)
@@ -249,10 +264,15 @@ func (fw *FriendWorker) visitFriend(friendGid int64, name string, myGid int64) f
 						continue
 					}
 					actions.steal++
+					yield := yieldOf(reply.Items, fw.gc, 1)
+					actions.stealExp += yield.Exp
+					actions.stealValue += yield.Gold + yield.fruitValue(fw.gc)
 					cropName := fw.gc.GetPlantName(int(sl.cropID))
 					stolenCrops[cropName]++
 				}
-				fw.antiDetectionDelay(100)
+				if !fw.antiDetectionDelay(100) {
+					break
+				}
 			}
 
 			if actions.steal > 0 {
@@ -295,7 +315,7 @@ type friendLandStatus struct {
 
 func (fw *FriendWorker) analyzeFriendLands(lands []*plantpb.LandInfo, myGid int64) *friendLandStatus {
 	s := &friendLandStatus{}
-	nowSec := time.Now().Unix()
+	nowSec := fw.net.clock.Now().Unix()
 	landMap := buildLandMap(lands)
 
 	for _, land := range lands {
@@ -362,17 +382,20 @@ func (fw *FriendWorker) checkAndAcceptApplications() {
 	}
 }
 
-func (fw *FriendWorker) fetchFriendList() []*friendpb.GameFriend {
+// fetchFriendList fetches all friends and returns them with the reply size.
+func (fw *FriendWorker) fetchFriendList() ([]*friendpb.GameFriend, int) {
 	req := &friendpb.GetAllRequest{}
 	body, _ := proto.Marshal(req)
 	replyBody, err := fw.net.SendRequestWithRetry("gamepb.friendpb.FriendService", "GetAll", body)
 	if err != nil {
 		fw.logger.Warnf("好友", "获取好友失败: %v", err)
-		return nil
+		return nil, 0
 	}
+	friendListTraffic.fullFetches.Add(1)
+	friendListTraffic.fullBytes.Add(int64(len(replyBody)))
 	reply := &friendpb.GetAllReply{}
 	proto.Unmarshal(replyBody, reply)
-	return reply.GameFriends
+	return reply.GameFriends, len(replyBody)
 }
 
 func (fw *FriendWorker) checkCanSteal(friendGid int64) (bool, int64) {
@@ -387,10 +410,11 @@ func (fw *FriendWorker) checkCanSteal(friendGid int64) (bool, int64) {
 	return reply.CanOperate, reply.CanStealNum
 }
 
-func (fw *FriendWorker) antiDetectionDelay(baseMs int) {
+// antiDetectionDelay pauses between per-land requests. It returns false once
+// the bot is stopping.
+func (fw *FriendWorker) antiDetectionDelay(baseMs int) bool {
 	if fw.cfg.EnableAntiDetection {
-		time.Sleep(time.Duration(baseMs*2+rand.Intn(baseMs*2)) * time.Millisecond)
-	} else {
-		time.Sleep(time.Duration(baseMs) * time.Millisecond)
+		return fw.pause(time.Duration(baseMs*2+rand.Intn(baseMs*2)) * time.Millisecond)
 	}
+	return fw.pause(time.Duration(baseMs) * time.Millisecond)
 }
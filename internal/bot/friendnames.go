@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+
+	"qq-farm-bot/proto/friendpb"
+)
+
+// FriendNames maps friend GIDs to display names. FriendWorker refreshes it
+// on every friend list fetch; FarmWorker reads it to name the friends who
+// left weeds or insects on our lands.
+type FriendNames struct {
+	mu    sync.RWMutex
+	names map[int64]string
+}
+
+func NewFriendNames() *FriendNames {
+	return &FriendNames{names: make(map[int64]string)}
+}
+
+func (fn *FriendNames) update(friends []*friendpb.GameFriend) {
+	names := make(map[int64]string, len(friends))
+	for _, f := range friends {
+		names[f.Gid] = friendDisplayName(f)
+	}
+	fn.mu.Lock()
+	fn.names = names
+	fn.mu.Unlock()
+}
+
+// Name returns the display name of gid, or "GID:<gid>" if unknown.
+func (fn *FriendNames) Name(gid int64) string {
+	if fn != nil {
+		fn.mu.RLock()
+		name, ok := fn.names[gid]
+		fn.mu.RUnlock()
+		if ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("GID:%d", gid)
+}
+
+// friendDisplayName prefers the remark we gave a friend over their nickname.
+func friendDisplayName(f *friendpb.GameFriend) string {
+	name := f.Remark
+	if name == "" {
+		name = f.Name
+	}
+	if name == "" {
+		name = fmt.Sprintf("GID:%d", f.Gid)
+	}
+	return name
+}
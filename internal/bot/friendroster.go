@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/friendpb"
+)
+
+// friendListTraffic counts friend list traffic of all bots, to measure what
+// the cached friend list saves.
+var friendListTraffic struct {
+	fullFetches    atomic.Int64
+	fullBytes      atomic.Int64
+	partialFetches atomic.Int64
+	partialBytes   atomic.Int64
+	bytesSaved     atomic.Int64
+}
+
+// FriendListTraffic is a snapshot of the friend list traffic counters.
+// BytesSaved estimates the GetAll bytes avoided by serving checks from the
+// cached list: the last full reply size minus what was fetched instead.
+type FriendListTraffic struct {
+	FullFetches    int64
+	FullBytes      int64
+	PartialFetches int64
+	PartialBytes   int64
+	BytesSaved     int64
+}
+
+// GetFriendListTraffic returns the friend list traffic counters.
+func GetFriendListTraffic() FriendListTraffic {
+	return FriendListTraffic{
+		FullFetches:    friendListTraffic.fullFetches.Load(),
+		FullBytes:      friendListTraffic.fullBytes.Load(),
+		PartialFetches: friendListTraffic.partialFetches.Load(),
+		PartialBytes:   friendListTraffic.partialBytes.Load(),
+		BytesSaved:     friendListTraffic.bytesSaved.Load(),
+	}
+}
+
+// friendRoster is a FriendWorker's cached friend list.
+type friendRoster struct {
+	friends   []*friendpb.GameFriend
+	fetchedAt time.Time
+	size      int // bytes of the GetAll reply
+}
+
+// friendsToVisit returns the friends worth checking this pass. The full list
+// is fetched every FriendListRefresh seconds or after a friend was added or
+// removed; in between only friends whose cached plant summary is due are
+// refreshed, with the much smaller GetGameFriends.
+func (fw *FriendWorker) friendsToVisit() []*friendpb.GameFriend {
+	now := fw.net.clock.Now()
+	refresh := time.Duration(fw.cfg.FriendListRefresh) * time.Second
+	changed := fw.net.friendsChanged.Swap(false)
+	if fw.roster.friends == nil || refresh <= 0 || changed || now.Sub(fw.roster.fetchedAt) >= refresh {
+		return fw.refreshRoster(now)
+	}
+
+	due := fw.dueFriends(now)
+	if len(due) == 0 {
+		friendListTraffic.bytesSaved.Add(int64(fw.roster.size))
+		return nil
+	}
+	fresh, size, err := fw.fetchGameFriends(due)
+	if err != nil {
+		fw.logger.Debugf("好友", "获取部分好友失败: %v, 改为获取全部", err)
+		return fw.refreshRoster(now)
+	}
+	friendListTraffic.bytesSaved.Add(int64(max(fw.roster.size-size, 0)))
+
+	byGID := make(map[int64]*friendpb.GameFriend, len(fresh))
+	for _, f := range fresh {
+		byGID[f.Gid] = f
+	}
+	for i, f := range fw.roster.friends {
+		if g, ok := byGID[f.Gid]; ok {
+			fw.roster.friends[i] = g
+		}
+	}
+	return fresh
+}
+
+// refreshRoster fetches the full friend list into the cache.
+func (fw *FriendWorker) refreshRoster(now time.Time) []*friendpb.GameFriend {
+	friends, size := fw.fetchFriendList()
+	if friends == nil {
+		return nil
+	}
+	fw.roster = friendRoster{friends: friends, fetchedAt: now, size: size}
+	fw.stats.SetFriendsCount(len(friends))
+	fw.names.update(friends)
+	return friends
+}
+
+// dueFriends returns the gids of cached friends whose plant summary shows
+// something to steal or help with by now.
+func (fw *FriendWorker) dueFriends(now time.Time) []int64 {
+	nowSec := now.Unix()
+	reached := func(sec int64) bool { return sec > 0 && toTimeSec(sec) <= nowSec }
+	var gids []int64
+	for _, f := range fw.roster.friends {
+		p := f.Plant
+		if p == nil {
+			continue
+		}
+		steal := fw.cfg.EnableSteal && (p.StealPlantNum > 0 || reached(p.RipeTimeSec))
+		help := fw.cfg.EnableHelpFriend && (p.DryNum > 0 || p.WeedNum > 0 || p.InsectNum > 0 ||
+			reached(p.DryTimeSec) || reached(p.WeedTimeSec) || reached(p.InsectTimeSec))
+		if steal || help {
+			gids = append(gids, f.Gid)
+		}
+	}
+	return gids
+}
+
+// fetchGameFriends fetches the current entries of the given friends.
+func (fw *FriendWorker) fetchGameFriends(gids []int64) ([]*friendpb.GameFriend, int, error) {
+	body, _ := proto.Marshal(&friendpb.GetGameFriendsRequest{FriendGids: gids})
+	replyBody, err := fw.net.SendRequest("gamepb.friendpb.FriendService", "GetGameFriends", body)
+	if err != nil {
+		return nil, 0, err
+	}
+	reply := &friendpb.GetGameFriendsReply{}
+	if err := proto.Unmarshal(replyBody, reply); err != nil {
+		return nil, 0, err
+	}
+	friendListTraffic.partialFetches.Add(1)
+	friendListTraffic.partialBytes.Add(int64(len(replyBody)))
+	return reply.Friends, len(replyBody), nil
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"qq-farm-bot/internal/yield"
 )
 
 type PlantConfig struct {
@@ -52,34 +56,34 @@ type SeedShopEntry struct {
 	FruitCount    int    `json:"fruitCount"`
 }
 
-// PlantPhaseData holds parsed phase info for fertilizer optimization.
-type PlantPhaseData struct {
-	PhaseDurations       []int // all non-zero growth phase durations
-	MaxPhaseDuration     int   // longest phase in season 1
-	MaxPhaseIndex        int   // 0-based index of longest phase
-	TotalGrowTime        int   // sum of all phase durations
-	AllPhasesEqual       bool  // true if all phases have the same duration
-	Season2Phases        []int // last 3 non-zero phases (for multi-season crops)
-	Season2GrowTime      int   // sum of season 2 phases
-	Season2MaxPhase      int   // longest phase in season 2
-	Season2MaxPhaseIndex int   // index of longest phase within Season2Phases
-	Season2AllEqual      bool  // true if all season 2 phases are equal
+// ItemInfo is the subset of ItemInfo.json needed for pricing and names.
+type ItemInfo struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Price int    `json:"price"`
 }
 
+// PlantPhaseData holds parsed phase info for fertilizer optimization.
+type PlantPhaseData = yield.PhaseData
+
 // SeedYieldRow contains calculated yield info for a seed
 type SeedYieldRow struct {
-	SeedID               int
-	Name                 string
-	RequiredLevel        int
-	Price                int
-	ExpHarvest           int // base exp per season
-	Seasons              int
-	GrowTimeSec          int // season 1 total grow time
-	Season2GrowTimeSec   int // season 2 total grow time (0 if single season)
-	NormalFertReduceSec  int // time saved by fertilizer in season 1 (max phase)
-	Season2FertReduceSec int // time saved by fertilizer in season 2
-	GrowTimeNormalFert   int // effective grow time with fert (both seasons combined)
-	FarmExpPerHourNormal float64
+	SeedID               int     `json:"seed_id"`
+	Name                 string  `json:"name"`
+	RequiredLevel        int     `json:"required_level"`
+	Price                int     `json:"price"`
+	ExpHarvest           int     `json:"exp_harvest"` // base exp per season
+	Seasons              int     `json:"seasons"`
+	GrowTimeSec          int     `json:"grow_time_sec"`           // season 1 total grow time
+	Season2GrowTimeSec   int     `json:"season2_grow_time_sec"`   // season 2 total grow time (0 if single season)
+	NormalFertReduceSec  int     `json:"normal_fert_reduce_sec"`  // time saved by fertilizer in season 1 (max phase)
+	Season2FertReduceSec int     `json:"season2_fert_reduce_sec"` // time saved by fertilizer in season 2
+	GrowTimeNormalFert   int     `json:"grow_time_normal_fert"`   // effective grow time with fert (both seasons combined)
+	FarmExpPerHourNormal float64 `json:"farm_exp_per_hour_normal"`
+	FruitID              int     `json:"fruit_id"`
+	FruitCount           int     `json:"fruit_count"`             // fruits per harvest
+	FruitPrice           int     `json:"fruit_price"`             // sell price per fruit (0 if ItemInfo.json missing)
+	FarmGoldPerHourFert  float64 `json:"farm_gold_per_hour_fert"` // fruit value per hour across all lands
 }
 
 type GameConfig struct {
@@ -91,32 +95,199 @@ type GameConfig struct {
 	levelExp       []RoleLevelConfig
 	levelExpMap    map[int]int64 // level -> cumulative exp
 	seedShopData   *SeedShopExport
-	seedYieldCache []SeedYieldRow
+	seedYieldCache map[int][]SeedYieldRow  // land count -> yield table, sorted by exp/hour
 	plantPhaseData map[int]*PlantPhaseData // seed_id -> phase data
+	itemPrice      map[int]int             // item_id -> sell price
+	itemName       map[int]string          // item_id -> display name
+	cropList       []CropInfo              // built lazily by GetCropList
+	generation     uint64                  // bumped whenever the caches above are reset
+	phaseIssues    map[int][]string        // plant_id -> grow_phases parse problems
+	modTime        time.Time               // newest mtime of the loaded files
+	configDir      string                  // directory the data was loaded from
+	logger         *Logger                 // load progress and data gaps; nil is silent
 }
 
+// testHookCacheBuilt, when set by tests, runs after a lazily built cache
+// entry is computed and before it is stored.
+var testHookCacheBuilt func()
+
+// defaultYieldLands is the land count of a fully expanded farm.
+const defaultYieldLands = 18
+
 var globalGameConfig *GameConfig
 var gameConfigOnce sync.Once
 
-func LoadGameConfig(configDir string) *GameConfig {
+// gameConfigFiles are the files read by load; a newer mtime on any of them
+// triggers a reload from WatchReload.
+var gameConfigFiles = []string{"Plant.json", "RoleLevel.json", seedShopFile, "ItemInfo.json"}
+
+func newGameConfig() *GameConfig {
+	return &GameConfig{
+		plantMap:       make(map[int]*PlantConfig),
+		seedToPlant:    make(map[int]*PlantConfig),
+		fruitToPlant:   make(map[int]*PlantConfig),
+		levelExpMap:    make(map[int]int64),
+		plantPhaseData: make(map[int]*PlantPhaseData),
+		seedYieldCache: make(map[int][]SeedYieldRow),
+		itemPrice:      make(map[int]int),
+		itemName:       make(map[int]string),
+		phaseIssues:    make(map[int][]string),
+	}
+}
+
+func LoadGameConfig(configDir string, logger *Logger) *GameConfig {
 	gameConfigOnce.Do(func() {
-		globalGameConfig = &GameConfig{
-			plantMap:       make(map[int]*PlantConfig),
-			seedToPlant:    make(map[int]*PlantConfig),
-			fruitToPlant:   make(map[int]*PlantConfig),
-			levelExpMap:    make(map[int]int64),
-			plantPhaseData: make(map[int]*PlantPhaseData),
-		}
+		globalGameConfig = newGameConfig()
+		globalGameConfig.logger = logger
 		globalGameConfig.load(configDir)
 	})
 	return globalGameConfig
 }
 
+// Reload re-reads the game config files into fresh maps and swaps them in
+// under the write lock. The *GameConfig pointer held by workers stays valid;
+// lookups see either the old or the new data set, never a mix. If Plant.json
+// cannot be loaded the current data is kept.
+func (gc *GameConfig) Reload(configDir string) error {
+	if gc == nil {
+		return fmt.Errorf("游戏配置未初始化")
+	}
+	fresh := newGameConfig()
+	fresh.logger = gc.logger
+	fresh.load(configDir)
+	if len(fresh.plants) == 0 {
+		return fmt.Errorf("加载 %s 失败, 保留当前配置", filepath.Join(configDir, "Plant.json"))
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.plants = fresh.plants
+	gc.plantMap = fresh.plantMap
+	gc.seedToPlant = fresh.seedToPlant
+	gc.fruitToPlant = fresh.fruitToPlant
+	gc.levelExp = fresh.levelExp
+	gc.levelExpMap = fresh.levelExpMap
+	gc.seedShopData = fresh.seedShopData
+	gc.seedYieldCache = fresh.seedYieldCache
+	gc.plantPhaseData = fresh.plantPhaseData
+	gc.itemPrice = fresh.itemPrice
+	gc.itemName = fresh.itemName
+	gc.cropList = nil
+	gc.generation++
+	gc.phaseIssues = fresh.phaseIssues
+	gc.modTime = fresh.modTime
+	gc.configDir = fresh.configDir
+	return nil
+}
+
+// Validate reports problems with the loaded data that silently degrade
+// recommendations or level-up estimates. An empty result means healthy.
+func (gc *GameConfig) Validate() []string {
+	if gc == nil {
+		return []string{"游戏配置未加载"}
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+
+	var warnings []string
+	if len(gc.plants) == 0 {
+		warnings = append(warnings, "Plant.json 缺失或为空")
+	}
+	if len(gc.levelExpMap) == 0 {
+		warnings = append(warnings, "RoleLevel.json 缺失或为空, 无法估算升级时间")
+	} else {
+		maxLevel := 0
+		for l := range gc.levelExpMap {
+			maxLevel = max(maxLevel, l)
+		}
+		var missing []int
+		for l := 1; l <= maxLevel; l++ {
+			if _, ok := gc.levelExpMap[l]; !ok {
+				missing = append(missing, l)
+			}
+		}
+		if len(missing) > 0 {
+			warnings = append(warnings, fmt.Sprintf("等级经验表缺少等级: %v", missing))
+		}
+	}
+	if gc.seedShopData == nil {
+		warnings = append(warnings, fmt.Sprintf("%s 缺失, 收益排行仅使用 Plant.json", seedShopFile))
+	}
+	if len(gc.itemPrice) == 0 {
+		warnings = append(warnings, "ItemInfo.json 缺失, 金币收益不可用")
+	}
+
+	ids := make([]int, 0, len(gc.phaseIssues))
+	for id := range gc.phaseIssues {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		name := ""
+		if p := gc.plantMap[id]; p != nil {
+			name = p.Name
+		}
+		warnings = append(warnings, fmt.Sprintf("植物 %d(%s) grow_phases 异常: %s", id, name, strings.Join(gc.phaseIssues[id], "; ")))
+	}
+	return warnings
+}
+
+// WatchReload polls the config files every interval and reloads when any of
+// them has a newer mtime than the loaded data. It never returns.
+func (gc *GameConfig) WatchReload(configDir string, interval time.Duration, logger *Logger) {
+	if gc == nil || interval <= 0 {
+		return
+	}
+	for range time.Tick(interval) {
+		gc.mu.RLock()
+		loaded := gc.modTime
+		gc.mu.RUnlock()
+		if !latestModTime(configDir).After(loaded) {
+			continue
+		}
+		if err := gc.Reload(configDir); err != nil {
+			logger.Errorf("配置", "自动重载失败: %v", err)
+			continue
+		}
+		logger.Info("配置", "检测到文件变更, 已重新加载游戏配置")
+		for _, w := range gc.Validate() {
+			logger.Warnf("配置", "%s", w)
+		}
+	}
+}
+
+// latestModTime returns the newest mtime among the game config files.
+func latestModTime(configDir string) time.Time {
+	var latest time.Time
+	for _, name := range gameConfigFiles {
+		if fi, err := os.Stat(filepath.Join(configDir, name)); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
 func GetGameConfig() *GameConfig {
 	return globalGameConfig
 }
 
+// infof and warnf log under the config tag when gc has a logger.
+func (gc *GameConfig) infof(format string, args ...any) {
+	if gc.logger != nil {
+		gc.logger.Infof("配置", format, args...)
+	}
+}
+
+func (gc *GameConfig) warnf(format string, args ...any) {
+	if gc.logger != nil {
+		gc.logger.Warnf("配置", format, args...)
+	}
+}
+
 func (gc *GameConfig) load(configDir string) {
+	gc.modTime = latestModTime(configDir)
+	gc.configDir = configDir
+
 	// Load Plant.json
 	plantPath := filepath.Join(configDir, "Plant.json")
 	if data, err := os.ReadFile(plantPath); err == nil {
@@ -133,7 +304,7 @@ func (gc *GameConfig) load(configDir string) {
 					gc.fruitToPlant[p.Fruit.ID] = p
 				}
 			}
-			fmt.Printf("[配置] 已加载植物配置 (%d 种)\n", len(plants))
+			gc.infof("已加载植物配置 (%d 种)", len(plants))
 		}
 	}
 
@@ -144,25 +315,38 @@ func (gc *GameConfig) load(configDir string) {
 			for _, l := range gc.levelExp {
 				gc.levelExpMap[l.Level] = l.Exp
 			}
-			fmt.Printf("[配置] 已加载等级经验表 (%d 级)\n", len(gc.levelExp))
+			gc.infof("已加载等级经验表 (%d 级)", len(gc.levelExp))
 		}
 	}
 
 	// Load seed-shop-merged-export.json for yield calculation
-	seedShopPath := filepath.Join(configDir, "seed-shop-merged-export.json")
+	seedShopPath := filepath.Join(configDir, seedShopFile)
 	if data, err := os.ReadFile(seedShopPath); err == nil {
 		var export SeedShopExport
 		if err := json.Unmarshal(data, &export); err == nil {
 			gc.seedShopData = &export
-			fmt.Printf("[配置] 已加载种子商店数据 (%d 种)\n", len(export.Rows))
+			gc.infof("已加载种子商店数据 (%d 种)", len(export.Rows))
 		}
 	}
 
+	// Load ItemInfo.json for sell prices (optional)
+	if items, err := loadItemInfo(configDir); err == nil {
+		for _, item := range items {
+			gc.itemPrice[item.ID] = item.Price
+			if item.Name != "" {
+				gc.itemName[item.ID] = item.Name
+			}
+		}
+		gc.infof("已加载物品价格 (%d 种)", len(items))
+	} else if !os.IsNotExist(err) {
+		gc.warnf("加载物品价格失败: %v", err)
+	}
+
 	// Build phase data for fertilizer optimization
 	gc.buildPlantPhaseData()
 
 	// Calculate yield for all seeds
-	gc.calculateSeedYield(18) // default 18 lands
+	gc.calculateSeedYield(defaultYieldLands)
 }
 
 func (gc *GameConfig) GetPlantName(plantID int) string {
@@ -259,6 +443,19 @@ func (gc *GameConfig) GetPlantSizeBySeedID(seedID int) int {
 	return 1
 }
 
+// GetPlantExpBySeedID returns the per-season harvest exp of a seed's plant.
+func (gc *GameConfig) GetPlantExpBySeedID(seedID int) int {
+	if gc == nil {
+		return 0
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	if p, ok := gc.seedToPlant[seedID]; ok {
+		return p.Exp
+	}
+	return 0
+}
+
 func (gc *GameConfig) GetPlantGrowTime(plantID int) int {
 	if gc == nil {
 		return 0
@@ -301,123 +498,83 @@ func (gc *GameConfig) FormatGrowTime(seconds int) string {
 }
 
 
-// parseGrowPhases extracts all non-zero phase durations from a grow_phases string.
-// Format: "name:seconds;name:seconds;...;mature:0;"
-func parseGrowPhases(growPhases string) []int {
-	var durations []int
-	for _, phase := range strings.Split(growPhases, ";") {
-		phase = strings.TrimSpace(phase)
-		if phase == "" {
-			continue
-		}
-		parts := strings.Split(phase, ":")
-		if len(parts) == 2 {
-			if v, err := strconv.Atoi(parts[1]); err == nil && v > 0 {
-				durations = append(durations, v)
-			}
-		}
-	}
-	return durations
-}
-
-// parseAllPhaseDurations extracts ALL phase durations including zero (mature).
-// Used for season 2 calculation: the game takes the last 3 phases from the full list.
-func parseAllPhaseDurations(growPhases string) []int {
-	var durations []int
-	for _, phase := range strings.Split(growPhases, ";") {
-		phase = strings.TrimSpace(phase)
-		if phase == "" {
-			continue
-		}
-		parts := strings.Split(phase, ":")
-		if len(parts) == 2 {
-			if v, err := strconv.Atoi(parts[1]); err == nil {
-				durations = append(durations, v)
-			}
-		}
-	}
-	return durations
-}
+// GrowPhase is one named phase of a grow_phases string.
+type GrowPhase = yield.Phase
 
 // buildPlantPhaseData parses phase durations for each plant and computes
-// max-phase info for optimal fertilization.
+// max-phase info for optimal fertilization. Plants with malformed grow_phases
+// are recorded in phaseIssues and summarized in a single log line.
 func (gc *GameConfig) buildPlantPhaseData() {
 	for _, p := range gc.plants {
 		if p.GrowPhases == "" || p.SeedID <= 0 {
 			continue
 		}
 
-		durations := parseGrowPhases(p.GrowPhases)
-		if len(durations) == 0 {
-			continue
-		}
-
-		pd := &PlantPhaseData{
-			PhaseDurations: durations,
-		}
-
-		// Find max phase and total grow time for season 1
-		for i, d := range durations {
-			pd.TotalGrowTime += d
-			if d > pd.MaxPhaseDuration {
-				pd.MaxPhaseDuration = d
-				pd.MaxPhaseIndex = i
-			}
-		}
-
-		// Check if all phases are equal (no benefit from delayed fertilization)
-		pd.AllPhasesEqual = true
-		for _, d := range durations {
-			if d != durations[0] {
-				pd.AllPhasesEqual = false
-				break
-			}
-		}
-
-		// For multi-season crops: season 2 uses the last 3 phases from the FULL
-		// phase list (including 成熟:0), then filters to non-zero growth durations.
 		seasons := p.Seasons
 		if seasons < 1 {
 			seasons = 1
 		}
-		if seasons >= 2 {
-			allPhases := parseAllPhaseDurations(p.GrowPhases)
-			if len(allPhases) >= 3 {
-				last3 := allPhases[len(allPhases)-3:]
-				var s2Phases []int
-				for _, d := range last3 {
-					if d > 0 {
-						s2Phases = append(s2Phases, d)
-					}
-				}
-				if len(s2Phases) > 0 {
-					pd.Season2Phases = s2Phases
-					for i, d := range s2Phases {
-						pd.Season2GrowTime += d
-						if d > pd.Season2MaxPhase {
-							pd.Season2MaxPhase = d
-							pd.Season2MaxPhaseIndex = i
-						}
-					}
-					pd.Season2AllEqual = true
-					for _, d := range s2Phases {
-						if d != s2Phases[0] {
-							pd.Season2AllEqual = false
-							break
-						}
-					}
-				}
-			}
+		pd, issues := yield.BuildPhaseData(p.GrowPhases, seasons)
+		if pd == nil && len(issues) == 0 {
+			issues = []string{"没有有效的生长阶段"}
+		}
+		if len(issues) > 0 {
+			gc.phaseIssues[p.ID] = issues
+		}
+		if pd == nil {
+			continue
 		}
 
 		gc.plantPhaseData[p.SeedID] = pd
 	}
+
+	if len(gc.phaseIssues) > 0 {
+		ids := make([]int, 0, len(gc.phaseIssues))
+		for id := range gc.phaseIssues {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		gc.warnf("%d 种植物的 grow_phases 无法完整解析: ids %v", len(ids), ids)
+	}
 }
 
 // calculateSeedYield calculates experience yield for all seeds, accounting for
 // multi-season crops and optimal fertilizer usage (skip longest phase).
 // It processes both seed shop entries and Plant.json-only entries.
-func (gc *GameConfig) calculateSeedYield(lands int) {
+// The result is cached per land count; callers must not modify the returned slice.
+func (gc *GameConfig) calculateSeedYield(lands int) []SeedYieldRow {
+	if lands <= 0 {
+		lands = defaultYieldLands
+	}
+	gc.mu.RLock()
+	rows, ok := gc.seedYieldCache[lands]
+	gen := gc.generation
+	if !ok {
+		rows = gc.buildSeedYieldRows(lands)
+	}
+	gc.mu.RUnlock()
+	if ok {
+		return rows
+	}
+	if testHookCacheBuilt != nil {
+		testHookCacheBuilt()
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.generation != gen {
+		return rows // built from data a reload has replaced; don't cache it
+	}
+	if cached, ok := gc.seedYieldCache[lands]; ok {
+		return cached // another goroutine populated it first
+	}
+	gc.seedYieldCache[lands] = rows
+	return rows
+}
+
+// buildSeedYieldRows computes the yield table for the given land count without
+// touching shared state. Callers must hold at least the read lock.
+func (gc *GameConfig) buildSeedYieldRows(lands int) []SeedYieldRow {
 	var rows []SeedYieldRow
 	processedSeeds := make(map[int]bool)
 
@@ -470,40 +627,27 @@ func (gc *GameConfig) calculateSeedYield(lands int) {
 		}
 	}
 
-	gc.seedYieldCache = rows
+	return rows
 }
 
 // calcSeedYieldRow computes yield metrics for a single seed.
 func (gc *GameConfig) calcSeedYieldRow(seedID int, name string, requiredLevel, price, exp, seasons, growTimeSec int, pd *PlantPhaseData, lands int) SeedYieldRow {
-	var s1FertReduce, s2FertReduce, s2GrowTime int
-
-	if pd != nil {
-		s1FertReduce = pd.MaxPhaseDuration
-		if seasons >= 2 {
-			s2GrowTime = pd.Season2GrowTime
-			s2FertReduce = pd.Season2MaxPhase
-		}
-	}
-
-	s1GrowFert := growTimeSec - s1FertReduce
-	if s1GrowFert < 1 {
-		s1GrowFert = 1
-	}
-
-	totalGrowFert := s1GrowFert
-	totalExp := exp
-	if seasons >= 2 && s2GrowTime > 0 {
-		s2GrowFert := s2GrowTime - s2FertReduce
-		if s2GrowFert < 1 {
-			s2GrowFert = 1
-		}
-		totalGrowFert += s2GrowFert
-		totalExp += exp
-	}
+	cycle := yield.CalcCycle(growTimeSec, seasons, pd, yield.FertNormal)
+	totalGrowFert := cycle.GrowSecFert
+	totalExp := exp * cycle.Harvests
+	harvests := cycle.Harvests
 
 	cycleSecNormalFert := float64(totalGrowFert)
 	farmExpPerHourNormal := float64(lands*totalExp) / cycleSecNormalFert * 3600
 
+	// Fruit value per harvested season
+	var fruitID, fruitCount int
+	if plant := gc.seedToPlant[seedID]; plant != nil {
+		fruitID, fruitCount = plant.Fruit.ID, plant.Fruit.Count
+	}
+	fruitPrice := gc.itemPrice[fruitID]
+	farmGoldPerHourFert := float64(lands*fruitCount*fruitPrice*harvests) / cycleSecNormalFert * 3600
+
 	return SeedYieldRow{
 		SeedID:               seedID,
 		Name:                 name,
@@ -512,27 +656,26 @@ func (gc *GameConfig) calcSeedYieldRow(seedID int, name string, requiredLevel, p
 		ExpHarvest:           exp,
 		Seasons:              seasons,
 		GrowTimeSec:          growTimeSec,
-		Season2GrowTimeSec:   s2GrowTime,
-		NormalFertReduceSec:  s1FertReduce,
-		Season2FertReduceSec: s2FertReduce,
+		Season2GrowTimeSec:   cycle.Season2GrowSec,
+		NormalFertReduceSec:  cycle.FertReduceSec,
+		Season2FertReduceSec: cycle.Season2FertReduceSec,
 		GrowTimeNormalFert:   totalGrowFert,
 		FarmExpPerHourNormal: farmExpPerHourNormal,
+		FruitID:              fruitID,
+		FruitCount:           fruitCount,
+		FruitPrice:           fruitPrice,
+		FarmGoldPerHourFert:  farmGoldPerHourFert,
 	}
 }
 
 // GetPlantingRecommendation returns seed recommendations based on experience efficiency
 func (gc *GameConfig) GetPlantingRecommendation(level, lands int, topN int) []SeedYieldRow {
-	if gc == nil || len(gc.seedYieldCache) == 0 {
+	if gc == nil {
 		return nil
 	}
 
-	// Recalculate if lands count differs significantly
-	if lands > 0 && lands != 18 {
-		gc.calculateSeedYield(lands)
-	}
-
 	var result []SeedYieldRow
-	for _, r := range gc.seedYieldCache {
+	for _, r := range gc.calculateSeedYield(lands) {
 		if r.RequiredLevel <= level {
 			result = append(result, r)
 			if len(result) >= topN {
@@ -587,12 +730,62 @@ func (gc *GameConfig) GetNextLevelExp(currentLevel int) (int64, bool) {
 		return 0, false
 	}
 	gc.mu.RLock()
-	defer gc.mu.RUnlock()
 	nextLevel := currentLevel + 1
 	if exp, ok := gc.levelExpMap[nextLevel]; ok {
+		gc.mu.RUnlock()
 		return exp, true
 	}
-	return 0, false
+	exp, lowLevel, highLevel, ok := gc.interpolateLevelExp(nextLevel)
+	gc.mu.RUnlock()
+	if ok {
+		if _, warned := levelGapWarned.LoadOrStore(nextLevel, true); !warned {
+			gc.warnf("等级经验表缺少 %d 级, 已按 %d~%d 级插值估算", nextLevel, lowLevel, highLevel)
+		}
+	}
+	return exp, ok
+}
+
+// levelGapWarned records levels already reported as missing from RoleLevel.json.
+var levelGapWarned sync.Map
+
+// interpolateLevelExp fills a gap in the level table by linear interpolation
+// between the closest known levels, which it also returns. Returns false above
+// the max level or when the table is empty. Callers must hold at least the
+// read lock.
+func (gc *GameConfig) interpolateLevelExp(level int) (exp int64, lowLevel, highLevel int, ok bool) {
+	for l := range gc.levelExpMap {
+		if l < level && l > lowLevel {
+			lowLevel = l
+		}
+		if l > level && (highLevel == 0 || l < highLevel) {
+			highLevel = l
+		}
+	}
+	if highLevel == 0 {
+		return 0, 0, 0, false
+	}
+	lowExp, highExp := gc.levelExpMap[lowLevel], gc.levelExpMap[highLevel]
+	return lowExp + (highExp-lowExp)*int64(level-lowLevel)/int64(highLevel-lowLevel), lowLevel, highLevel, true
+}
+
+// PlantCount returns the number of plants loaded from Plant.json.
+func (gc *GameConfig) PlantCount() int {
+	if gc == nil {
+		return 0
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	return len(gc.plants)
+}
+
+// HasLevelTable reports whether RoleLevel.json was loaded.
+func (gc *GameConfig) HasLevelTable() bool {
+	if gc == nil {
+		return false
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	return len(gc.levelExpMap) > 0
 }
 
 type CropInfo struct {
@@ -600,20 +793,48 @@ type CropInfo struct {
 	Name          string `json:"name"`
 	SeedID        int    `json:"seed_id"`
 	FruitID       int    `json:"fruit_id"`
+	FruitCount    int    `json:"fruit_count"`
 	Exp           int    `json:"exp"`
 	Seasons       int    `json:"seasons"`
 	RequiredLevel int    `json:"required_level"`
+	GrowTimeSec   int    `json:"grow_time_sec"` // season 1 grow time
+	GrowTime      string `json:"grow_time"`
 }
 
+// GetCropList returns all plantable crops sorted by required level. The list
+// is built once per loaded config; callers receive their own copy.
 func (gc *GameConfig) GetCropList() []CropInfo {
 	if gc == nil {
 		return nil
 	}
 	gc.mu.RLock()
-	defer gc.mu.RUnlock()
+	crops := gc.cropList
+	gen := gc.generation
+	if crops == nil {
+		crops = gc.buildCropList()
+	}
+	gc.mu.RUnlock()
+	if testHookCacheBuilt != nil {
+		testHookCacheBuilt()
+	}
 
+	// A reload in between makes crops stale: return it, but don't cache it
+	gc.mu.Lock()
+	if gc.cropList == nil && gc.generation == gen {
+		gc.cropList = crops
+	}
+	gc.mu.Unlock()
+
+	result := make([]CropInfo, len(crops))
+	copy(result, crops)
+	return result
+}
+
+// buildCropList joins seed shop data with Plant.json. Callers must hold at
+// least the read lock.
+func (gc *GameConfig) buildCropList() []CropInfo {
 	seen := make(map[int]bool)
-	var crops []CropInfo
+	crops := make([]CropInfo, 0)
 
 	if gc.seedShopData != nil {
 		for _, s := range gc.seedShopData.Rows {
@@ -621,42 +842,63 @@ func (gc *GameConfig) GetCropList() []CropInfo {
 				continue
 			}
 			seen[s.PlantID] = true
-			crops = append(crops, CropInfo{
+			crop := CropInfo{
 				ID:            s.PlantID,
 				Name:          s.Name,
 				SeedID:        s.SeedID,
 				FruitID:       s.FruitID,
+				FruitCount:    s.FruitCount,
 				Exp:           s.Exp,
 				Seasons:       1,
 				RequiredLevel: s.RequiredLevel,
-			})
+				GrowTimeSec:   s.GrowTimeSec,
+			}
+			if p, ok := gc.plantMap[s.PlantID]; ok && p.Seasons >= 2 {
+				crop.Seasons = p.Seasons
+			}
+			if crop.GrowTimeSec <= 0 {
+				if pd := gc.plantPhaseData[s.SeedID]; pd != nil {
+					crop.GrowTimeSec = pd.TotalGrowTime
+				}
+			}
+			crops = append(crops, crop)
 		}
-		for i := range crops {
-			if p, ok := gc.plantMap[crops[i].ID]; ok && p.Seasons >= 2 {
-				crops[i].Seasons = p.Seasons
+	} else {
+		for _, p := range gc.plants {
+			if p.SeedID <= 0 || p.SeedID < 20000 || seen[p.ID] {
+				continue
+			}
+			seen[p.ID] = true
+			seasons := p.Seasons
+			if seasons < 1 {
+				seasons = 1
+			}
+			crop := CropInfo{
+				ID:            p.ID,
+				Name:          p.Name,
+				SeedID:        p.SeedID,
+				FruitID:       p.Fruit.ID,
+				FruitCount:    p.Fruit.Count,
+				Exp:           p.Exp,
+				Seasons:       seasons,
+				RequiredLevel: p.LandLevelNeed,
 			}
+			if pd := gc.plantPhaseData[p.SeedID]; pd != nil {
+				crop.GrowTimeSec = pd.TotalGrowTime
+			}
+			crops = append(crops, crop)
 		}
-		return crops
 	}
 
-	for _, p := range gc.plants {
-		if p.SeedID <= 0 || p.SeedID < 20000 || seen[p.ID] {
-			continue
-		}
-		seen[p.ID] = true
-		seasons := p.Seasons
-		if seasons < 1 {
-			seasons = 1
-		}
-		crops = append(crops, CropInfo{
-			ID:      p.ID,
-			Name:    p.Name,
-			SeedID:  p.SeedID,
-			FruitID: p.Fruit.ID,
-			Exp:     p.Exp,
-			Seasons: seasons,
-		})
+	for i := range crops {
+		crops[i].GrowTime = gc.FormatGrowTime(crops[i].GrowTimeSec)
 	}
+	sort.SliceStable(crops, func(i, j int) bool {
+		if crops[i].RequiredLevel != crops[j].RequiredLevel {
+			return crops[i].RequiredLevel < crops[j].RequiredLevel
+		}
+		return crops[i].ID < crops[j].ID
+	})
 	return crops
 }
 
@@ -684,29 +926,256 @@ func (gc *GameConfig) GetSeedIDForCrop(cropID int) int {
 	return 0
 }
 
-func ParseCropIDs(s string) map[int]bool {
-	result := make(map[int]bool)
-	if s == "" {
-		return result
-	}
-	for _, part := range strings.Split(s, ",") {
-		part = strings.TrimSpace(part)
-		if id, err := strconv.Atoi(part); err == nil && id > 0 {
-			result[id] = true
-		}
+// GetSeedYieldRows returns a copy of the seed yield data for strategy evaluation.
+// Filtered to seeds the player can buy (level check done by caller).
+func (gc *GameConfig) GetSeedYieldRows() []SeedYieldRow {
+	if gc == nil {
+		return nil
 	}
+	rows := gc.calculateSeedYield(defaultYieldLands)
+	result := make([]SeedYieldRow, len(rows))
+	copy(result, rows)
 	return result
 }
 
-// GetSeedYieldRows returns a copy of the seed yield data for strategy evaluation.
-// Filtered to seeds the player can buy (level check done by caller).
-func (gc *GameConfig) GetSeedYieldRows() []SeedYieldRow {
+// GetSeedYieldTable returns a copy of the yield table for the given land count,
+// sorted by exp/hour.
+func (gc *GameConfig) GetSeedYieldTable(lands int) []SeedYieldRow {
 	if gc == nil {
 		return nil
 	}
+	rows := gc.calculateSeedYield(lands)
+	result := make([]SeedYieldRow, len(rows))
+	copy(result, rows)
+	return result
+}
+
+// LoadItemPrices reads item sell prices from ItemInfo.json in configDir.
+func LoadItemPrices(configDir string) (map[int]int, error) {
+	items, err := loadItemInfo(configDir)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[int]int, len(items))
+	for _, item := range items {
+		prices[item.ID] = item.Price
+	}
+	return prices, nil
+}
+
+func loadItemInfo(configDir string) ([]ItemInfo, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, "ItemInfo.json"))
+	if err != nil {
+		return nil, err
+	}
+	var items []ItemInfo
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("parse ItemInfo.json: %w", err)
+	}
+	return items, nil
+}
+
+// GetItemName returns the display name of any item: ItemInfo.json first,
+// then fruit and seed names from Plant.json, else a placeholder.
+func (gc *GameConfig) GetItemName(itemID int) string {
+	if gc == nil {
+		return fmt.Sprintf("物品%d", itemID)
+	}
 	gc.mu.RLock()
 	defer gc.mu.RUnlock()
-	result := make([]SeedYieldRow, len(gc.seedYieldCache))
-	copy(result, gc.seedYieldCache)
-	return result
+	if name, ok := gc.itemName[itemID]; ok {
+		return name
+	}
+	if p, ok := gc.fruitToPlant[itemID]; ok {
+		return p.Name
+	}
+	if p, ok := gc.seedToPlant[itemID]; ok {
+		return p.Name + "种子"
+	}
+	return fmt.Sprintf("物品%d", itemID)
+}
+
+// GetItemPrice returns the sell price of an item, or 0 if unknown.
+func (gc *GameConfig) GetItemPrice(itemID int) int {
+	if gc == nil {
+		return 0
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	return gc.itemPrice[itemID]
+}
+
+// GetFruitSellValue returns the sell value of one harvest of a plant
+// (fruit count * fruit price), or 0 if the plant or price is unknown.
+func (gc *GameConfig) GetFruitSellValue(plantID int) int {
+	if gc == nil {
+		return 0
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	p, ok := gc.plantMap[plantID]
+	if !ok {
+		return 0
+	}
+	return p.Fruit.Count * gc.itemPrice[p.Fruit.ID]
+}
+
+// ============ Live shop data ============
+
+// seedShopFile is the seed shop export, also rewritten from live shop data.
+const seedShopFile = "seed-shop-merged-export.json"
+
+// LiveSeedGoods is a seed shop entry as reported by the game server's ShopInfo.
+type LiveSeedGoods struct {
+	GoodsID       int
+	SeedID        int
+	Price         int
+	RequiredLevel int
+}
+
+// SetLiveShopData merges live seed shop goods into the seed shop data. Live
+// prices and level conditions supersede the static export; seeds missing from
+// the export are added using Plant.json metadata. When anything changed, the
+// yield and crop caches are invalidated and the merged data is written back to
+// the config dir so restarts keep it. Returns whether the data changed.
+func (gc *GameConfig) SetLiveShopData(goods []LiveSeedGoods) (bool, error) {
+	if gc == nil || len(goods) == 0 {
+		return false, nil
+	}
+
+	// Fast path: most calls carry the same listing as last time
+	gc.mu.RLock()
+	_, changed := gc.mergeLiveShop(gc.seedShopRows(), goods)
+	gc.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	// Merge again under the write lock in case a reload or another bot
+	// updated the data in between
+	gc.mu.Lock()
+	merged, changed := gc.mergeLiveShop(gc.seedShopRows(), goods)
+	if !changed {
+		gc.mu.Unlock()
+		return false, nil
+	}
+	export := &SeedShopExport{
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Source:     "live:ShopInfo",
+		Count:      len(merged),
+		Rows:       merged,
+	}
+	gc.seedShopData = export
+	gc.seedYieldCache = make(map[int][]SeedYieldRow)
+	gc.cropList = nil
+	gc.generation++
+	configDir := gc.configDir
+	gc.mu.Unlock()
+
+	if configDir == "" {
+		return true, nil
+	}
+	if err := WriteSeedShopExport(configDir, export); err != nil {
+		return true, err
+	}
+	// Our own write must not trigger WatchReload
+	gc.mu.Lock()
+	gc.modTime = latestModTime(configDir)
+	gc.mu.Unlock()
+	return true, nil
+}
+
+// BuildLiveShopExport builds a fresh seed shop export from a live listing
+// alone, joined with Plant.json. Seeds unknown to Plant.json are left out and
+// returned in skipped.
+func (gc *GameConfig) BuildLiveShopExport(goods []LiveSeedGoods) (export *SeedShopExport, skipped []int) {
+	gc.mu.RLock()
+	rows, _ := gc.mergeLiveShop(nil, goods)
+	for _, g := range goods {
+		if gc.seedToPlant[g.SeedID] == nil {
+			skipped = append(skipped, g.SeedID)
+		}
+	}
+	gc.mu.RUnlock()
+	return &SeedShopExport{
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Source:     "live:ShopInfo",
+		Count:      len(rows),
+		Rows:       rows,
+	}, skipped
+}
+
+// seedShopRows returns a copy of the loaded seed shop rows. Callers must hold
+// at least the read lock.
+func (gc *GameConfig) seedShopRows() []SeedShopEntry {
+	if gc.seedShopData == nil {
+		return nil
+	}
+	rows := make([]SeedShopEntry, len(gc.seedShopData.Rows))
+	copy(rows, gc.seedShopData.Rows)
+	return rows
+}
+
+// mergeLiveShop merges the live goods into rows (modified in place) and
+// reports whether anything changed. Callers must hold at least the read lock.
+func (gc *GameConfig) mergeLiveShop(rows []SeedShopEntry, goods []LiveSeedGoods) ([]SeedShopEntry, bool) {
+	index := make(map[int]int) // seed_id -> index in rows
+	for i, r := range rows {
+		index[r.SeedID] = i
+	}
+
+	changed := false
+	for _, g := range goods {
+		if i, ok := index[g.SeedID]; ok {
+			r := &rows[i]
+			if r.GoodsID != g.GoodsID || r.Price != g.Price || r.RequiredLevel != g.RequiredLevel {
+				r.GoodsID, r.Price, r.RequiredLevel = g.GoodsID, g.Price, g.RequiredLevel
+				changed = true
+			}
+			continue
+		}
+
+		// New seed: fill in the rest from Plant.json, skip if unknown
+		plant := gc.seedToPlant[g.SeedID]
+		if plant == nil {
+			continue
+		}
+		growTime := 0
+		if pd := gc.plantPhaseData[g.SeedID]; pd != nil {
+			growTime = pd.TotalGrowTime
+		}
+		index[g.SeedID] = len(rows)
+		rows = append(rows, SeedShopEntry{
+			SeedID:        g.SeedID,
+			GoodsID:       g.GoodsID,
+			PlantID:       plant.ID,
+			Name:          plant.Name,
+			RequiredLevel: g.RequiredLevel,
+			Price:         g.Price,
+			Exp:           plant.Exp,
+			GrowTimeSec:   growTime,
+			FruitID:       plant.Fruit.ID,
+			FruitCount:    plant.Fruit.Count,
+		})
+		changed = true
+	}
+	return rows, changed
+}
+
+// WriteSeedShopExport atomically replaces the seed shop export file.
+func WriteSeedShopExport(configDir string, export *SeedShopExport) error {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, seedShopFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", seedShopFile, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", seedShopFile, err)
+	}
+	return nil
 }
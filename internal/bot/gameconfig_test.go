@@ -0,0 +1,392 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"qq-farm-bot/internal/config"
+)
+
+// testGameConfigDir is the game config shipped with the repo.
+const testGameConfigDir = "../../gameConfig"
+
+// loadTestGameConfig loads the shipped game config into a fresh GameConfig,
+// leaving the process-wide one alone.
+func loadTestGameConfig(t *testing.T) *GameConfig {
+	t.Helper()
+	gc := newGameConfig()
+	gc.load(testGameConfigDir)
+	if len(gc.plants) == 0 {
+		t.Fatalf("no plants loaded from %s", testGameConfigDir)
+	}
+	return gc
+}
+
+func TestValidateReportsMalformedGrowPhases(t *testing.T) {
+	dir := t.TempDir()
+	plants := `[
+		{"id": 1, "name": "good", "seed_id": 101, "grow_phases": "种子:30;发芽:30;成熟:0;", "seasons": 1, "exp": 1},
+		{"id": 2, "name": "broken", "seed_id": 102, "grow_phases": "种子30;发芽:abc", "seasons": 1, "exp": 1},
+		{"id": 3, "name": "partial", "seed_id": 103, "grow_phases": "种子:30;发芽:-5;成熟:0", "seasons": 1, "exp": 1}
+	]`
+	if err := os.WriteFile(filepath.Join(dir, "Plant.json"), []byte(plants), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gc := newGameConfig()
+	gc.load(dir)
+
+	if gc.plantPhaseData[101] == nil || gc.plantPhaseData[103] == nil {
+		t.Fatal("well-formed phases dropped")
+	}
+	if gc.plantPhaseData[102] != nil {
+		t.Fatal("plant without a parseable phase got phase data")
+	}
+
+	warnings := strings.Join(gc.Validate(), "\n")
+	for _, want := range []string{"植物 2(broken)", "植物 3(partial)"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("Validate() missing %q:\n%s", want, warnings)
+		}
+	}
+	if strings.Contains(warnings, "植物 1(") {
+		t.Errorf("Validate() reported the well-formed plant:\n%s", warnings)
+	}
+}
+
+func TestValidateShippedConfig(t *testing.T) {
+	gc := loadTestGameConfig(t)
+	for _, w := range gc.Validate() {
+		if strings.Contains(w, "grow_phases") {
+			t.Errorf("shipped config: %s", w)
+		}
+	}
+}
+
+func TestPlantingRecommendationConcurrentLandCounts(t *testing.T) {
+	// Expected tables from a config no other goroutine touches
+	ref := loadTestGameConfig(t)
+	landCounts := []int{6, 8, 12, 18, 24}
+	want := make(map[int][]SeedYieldRow, len(landCounts))
+	for _, n := range landCounts {
+		want[n] = ref.GetPlantingRecommendation(100, n, 5)
+		if len(want[n]) == 0 {
+			t.Fatalf("no recommendation for %d lands", n)
+		}
+	}
+
+	gc := loadTestGameConfig(t)
+	var wg sync.WaitGroup
+	errs := make(chan string, 64)
+	for i := 0; i < 8; i++ {
+		for _, n := range landCounts {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					got := gc.GetPlantingRecommendation(100, n, 5)
+					if !reflect.DeepEqual(got, want[n]) {
+						select {
+						case errs <- fmt.Sprintf("%d lands: got %+v, want %+v", n, got, want[n]):
+						default:
+						}
+						return
+					}
+				}
+			}(n)
+		}
+	}
+	// A reload swapping the cache out mid-flight must not disturb readers
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := gc.Reload(testGameConfigDir); err != nil {
+			errs <- err.Error()
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+}
+
+func TestSeedYieldCachedPerLandCount(t *testing.T) {
+	gc := loadTestGameConfig(t)
+	small := gc.calculateSeedYield(8)
+	gc.calculateSeedYield(18)
+	if again := gc.calculateSeedYield(8); !reflect.DeepEqual(again, small) {
+		t.Fatal("an 18-land lookup changed the 8-land table")
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	if _, ok := gc.seedYieldCache[8]; !ok {
+		t.Fatal("8-land table not cached")
+	}
+	if _, ok := gc.seedYieldCache[18]; !ok {
+		t.Fatal("18-land table not cached")
+	}
+}
+
+// writeSmallGameConfig writes a Plant.json-only config of two crops, so its
+// crop list and yield table differ from the shipped config's.
+func writeSmallGameConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	plants := `[
+		{"id": 1, "name": "small-a", "seed_id": 20001, "grow_phases": "种子:60;成熟:0", "seasons": 1, "exp": 5},
+		{"id": 2, "name": "small-b", "seed_id": 20002, "grow_phases": "种子:120;成熟:0", "seasons": 1, "exp": 8}
+	]`
+	if err := os.WriteFile(filepath.Join(dir, "Plant.json"), []byte(plants), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReloadDuringCacheBuilds(t *testing.T) {
+	small := writeSmallGameConfig(t)
+	gc := loadTestGameConfig(t)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(lands int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				gc.GetCropList()
+				gc.GetSeedYieldTable(lands)
+			}
+		}(6 + i)
+	}
+	for i := 0; i < 20; i++ {
+		dir := testGameConfigDir
+		if i%2 == 1 {
+			dir = small
+		}
+		if err := gc.Reload(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// The last reload loaded the small config; no table built from the
+	// shipped one may have been cached after it
+	want := newGameConfig()
+	want.load(small)
+	if got := gc.GetCropList(); !reflect.DeepEqual(got, want.GetCropList()) {
+		t.Fatalf("crop list after reload = %+v, want %+v", got, want.GetCropList())
+	}
+	for lands := 6; lands < 10; lands++ {
+		if got := gc.GetSeedYieldTable(lands); !reflect.DeepEqual(got, want.GetSeedYieldTable(lands)) {
+			t.Fatalf("%d-land yield table has %d rows after reload, want %d", lands, len(got), len(want.GetSeedYieldTable(lands)))
+		}
+	}
+}
+
+func TestReloadBetweenBuildAndStore(t *testing.T) {
+	small := writeSmallGameConfig(t)
+	want := newGameConfig()
+	want.load(small)
+
+	gc := loadTestGameConfig(t)
+	// A reload lands after each table is built from the shipped config.
+	// The reload builds its own default table, so the hook must not recurse.
+	reloadOnce := func() {
+		testHookCacheBuilt = nil
+		if err := gc.Reload(small); err != nil {
+			t.Error(err)
+		}
+	}
+	testHookCacheBuilt = reloadOnce
+	stale := gc.GetCropList()
+	if err := gc.Reload(testGameConfigDir); err != nil {
+		t.Fatal(err)
+	}
+	testHookCacheBuilt = reloadOnce
+	gc.GetSeedYieldTable(7)
+	testHookCacheBuilt = nil
+
+	if reflect.DeepEqual(stale, want.GetCropList()) {
+		t.Fatal("the hook ran before the crop list was built")
+	}
+	if got := gc.GetCropList(); !reflect.DeepEqual(got, want.GetCropList()) {
+		t.Fatalf("stale crop list cached after reload: %d crops, want %d", len(got), len(want.GetCropList()))
+	}
+	if got := gc.GetSeedYieldTable(7); !reflect.DeepEqual(got, want.GetSeedYieldTable(7)) {
+		t.Fatalf("stale yield table cached after reload: %d rows, want %d", len(got), len(want.GetSeedYieldTable(7)))
+	}
+}
+
+func TestLoadLogsThroughLogger(t *testing.T) {
+	dir := t.TempDir()
+	plants := `[
+		{"id": 1, "name": "good", "seed_id": 101, "grow_phases": "种子:30;成熟:0", "seasons": 1, "exp": 1},
+		{"id": 2, "name": "broken", "seed_id": 102, "grow_phases": "种子30", "seasons": 1, "exp": 1}
+	]`
+	// Level 998 is missing from the table
+	levels := `[{"level": 1, "exp": 0}, {"level": 997, "exp": 1000}, {"level": 999, "exp": 3000}]`
+	for name, data := range map[string]string{"Plant.json": plants, "RoleLevel.json": levels} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hub := NewLogHub(64)
+	logs := hub.Subscribe(SystemAccountID)
+	logger := NewLogger(SystemAccountID, nil, hub)
+	logger.SetOutputs(nil, config.ConsoleLogOff)
+	gc := newGameConfig()
+	gc.logger = logger
+	gc.load(dir)
+	for i := 0; i < 2; i++ {
+		if exp, ok := gc.GetNextLevelExp(997); !ok || exp != 2000 {
+			t.Fatalf("GetNextLevelExp(997) = %d, %v; want 2000 interpolated", exp, ok)
+		}
+	}
+
+	var got []string
+	for len(logs) > 0 {
+		e := <-logs
+		if e.Tag != "配置" {
+			t.Errorf("entry %q logged under tag %q", e.Message, e.Tag)
+		}
+		got = append(got, e.Level+" "+e.Message)
+	}
+	want := []string{
+		"info 已加载植物配置 (2 种)",
+		"info 已加载等级经验表 (3 级)",
+		"warn 1 种植物的 grow_phases 无法完整解析: ids [2]",
+		"warn 等级经验表缺少 998 级, 已按 997~999 级插值估算",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("logged:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	// A plant, its shop row and its fruit price that agree with each other
+	fixture := func(t *testing.T) ([]PlantConfig, *SeedShopExport, map[int]int) {
+		t.Helper()
+		var plants []PlantConfig
+		err := json.Unmarshal([]byte(`[
+			{"id": 1, "name": "白萝卜", "seed_id": 101, "grow_phases": "种子:30;发芽:30;成熟:0;", "seasons": 1, "exp": 2,
+			 "fruit": {"id": 201, "count": 5}}
+		]`), &plants)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shop := &SeedShopExport{Rows: []SeedShopEntry{{
+			SeedID: 101, PlantID: 1, Name: "白萝卜", Price: 10, Exp: 2, GrowTimeSec: 60, FruitID: 201, FruitCount: 5,
+		}}}
+		return plants, shop, map[int]int{201: 3}
+	}
+
+	cases := []struct {
+		name    string
+		modify  func(plants []PlantConfig, shop *SeedShopExport, prices map[int]int)
+		err     string // substring of the only error, "" for none
+		warning string // substring of the only warning, "" for none
+	}{
+		{name: "consistent", modify: func([]PlantConfig, *SeedShopExport, map[int]int) {}},
+		{name: "shop seed without plant", modify: func(_ []PlantConfig, shop *SeedShopExport, _ map[int]int) {
+			shop.Rows = append(shop.Rows, SeedShopEntry{SeedID: 999, PlantID: 99, Name: "幽灵", Price: 1})
+		}, err: "shop seed 999 (幽灵): plant 99 not found in Plant.json"},
+		{name: "fruit missing from ItemInfo", modify: func(_ []PlantConfig, _ *SeedShopExport, prices map[int]int) {
+			delete(prices, 201)
+		}, err: "fruit 201 not found in ItemInfo.json"},
+		{name: "grow time mismatch", modify: func(_ []PlantConfig, shop *SeedShopExport, _ map[int]int) {
+			shop.Rows[0].GrowTimeSec = 90
+		}, err: "growTimeSec 90 differs from the 60s summed"},
+		{name: "fruit price 0", modify: func(_ []PlantConfig, _ *SeedShopExport, prices map[int]int) {
+			prices[201] = 0
+		}, warning: "fruit 201 has price 0"},
+		{name: "seed price 0", modify: func(_ []PlantConfig, shop *SeedShopExport, _ map[int]int) {
+			shop.Rows[0].Price = 0
+		}, warning: "price is 0"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plants, shop, prices := fixture(t)
+			tc.modify(plants, shop, prices)
+			r := CheckConsistency(plants, shop, prices)
+			check := func(kind string, got []string, want string) {
+				t.Helper()
+				if want == "" {
+					if len(got) != 0 {
+						t.Errorf("unexpected %s: %q", kind, got)
+					}
+					return
+				}
+				if len(got) != 1 || !strings.Contains(got[0], want) {
+					t.Errorf("%s = %q, want one containing %q", kind, got, want)
+				}
+			}
+			check("errors", r.Errors, tc.err)
+			check("warnings", r.Warnings, tc.warning)
+		})
+	}
+}
+
+func TestItemPrices(t *testing.T) {
+	const fixture = "testdata/itemprices"
+	gc := newGameConfig()
+	gc.load(fixture)
+
+	for id, want := range map[int]int{40002: 2, 40003: 3, 20002: 0, 40004: 0, 99999: 0} {
+		if got := gc.GetItemPrice(id); got != want {
+			t.Errorf("GetItemPrice(%d) = %d, want %d", id, got, want)
+		}
+	}
+	// fruit count × fruit price; 0 for an unpriced fruit or unknown plant
+	for id, want := range map[int]int{1020002: 10, 1020003: 30, 1020004: 0, 1099999: 0} {
+		if got := gc.GetFruitSellValue(id); got != want {
+			t.Errorf("GetFruitSellValue(%d) = %d, want %d", id, got, want)
+		}
+	}
+	if got := gc.GetItemName(80001); got != "化肥(1小时)" {
+		t.Errorf("GetItemName(80001) = %q", got)
+	}
+
+	prices, err := LoadItemPrices(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]int{40002: 2, 40003: 3, 20002: 0, 80001: 0}; !maps.Equal(prices, want) {
+		t.Errorf("LoadItemPrices = %v, want %v", prices, want)
+	}
+
+	// Without ItemInfo.json the plants still load, unpriced
+	small := newGameConfig()
+	small.load(writeSmallGameConfig(t))
+	if small.GetCropList() == nil || small.GetFruitSellValue(1) != 0 {
+		t.Error("config without ItemInfo.json")
+	}
+	if _, err := LoadItemPrices(t.TempDir()); !os.IsNotExist(err) {
+		t.Errorf("LoadItemPrices of a dir without ItemInfo.json: err = %v", err)
+	}
+
+	// A malformed ItemInfo.json is reported, not loaded half-way
+	broken := writeSmallGameConfig(t)
+	os.WriteFile(filepath.Join(broken, "ItemInfo.json"), []byte(`[{"id": 40002, "price": "2"}]`), 0644)
+	if _, err := LoadItemPrices(broken); err == nil || !strings.Contains(err.Error(), "parse ItemInfo.json") {
+		t.Errorf("LoadItemPrices of a malformed file: err = %v", err)
+	}
+	gc = newGameConfig()
+	gc.load(broken)
+	if gc.GetItemPrice(40002) != 0 {
+		t.Error("price loaded from a malformed ItemInfo.json")
+	}
+}
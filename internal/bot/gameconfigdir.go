@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Degraded reports whether the game config is running without plant data,
+// in which case names, recommendations and strategies are unavailable and
+// seeds are picked by level only.
+func (gc *GameConfig) Degraded() bool {
+	return gc.PlantCount() == 0
+}
+
+// ConfigDir returns the directory the config was last loaded from.
+func (gc *GameConfig) ConfigDir() string {
+	if gc == nil {
+		return ""
+	}
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	return gc.configDir
+}
+
+// DescribeGameConfigDir summarizes which of the expected game config files
+// exist in dir, for startup and readiness diagnostics.
+func DescribeGameConfigDir(dir string) string {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Sprintf("目录 %s 不存在", dir)
+	}
+	if !fi.IsDir() {
+		return fmt.Sprintf("%s 不是目录", dir)
+	}
+	var found, missing []string
+	for _, name := range gameConfigFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Sprintf("目录 %s 中没有任何配置文件", dir)
+	}
+	desc := "已找到: " + strings.Join(found, ", ")
+	if len(missing) > 0 {
+		desc += "; 缺少: " + strings.Join(missing, ", ")
+	}
+	return desc
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// gameDayZone is the timezone of the game's daily reset (China Standard Time).
+var gameDayZone = time.FixedZone("CST", 8*60*60)
+
+// gameResetHour is the hour of gameDayZone at which the game day rolls over.
+var gameResetHour atomic.Int32
+
+// SetGameResetHour sets the hour (China time, 0-23) at which the game server
+// resets its daily counters.
+func SetGameResetHour(hour int) {
+	gameResetHour.Store(int32(hour))
+}
+
+// GameDayStart returns the reset starting the game day of t.
+func GameDayStart(t time.Time) time.Time {
+	t = t.In(gameDayZone)
+	start := time.Date(t.Year(), t.Month(), t.Day(), int(gameResetHour.Load()), 0, 0, 0, gameDayZone)
+	if t.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// GameDate returns the game day of t formatted as 2006-01-02.
+func GameDate(t time.Time) string {
+	return GameDayStart(t).Format("2006-01-02")
+}
+
+// ServerNow returns the current time on the game server's clock.
+func (n *Network) ServerNow() time.Time {
+	return n.clock.Now().Add(time.Duration(n.ServerTimeDelta()) * time.Millisecond)
+}
+
+// GameDate returns the current game day on the server's clock. Daily
+// allowances tracked on our side roll over with it.
+func (n *Network) GameDate() string {
+	return GameDate(n.ServerNow())
+}
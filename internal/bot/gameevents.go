@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/proto/plantpb"
+	"qq-farm-bot/proto/shoppb"
+)
+
+// Server events the bot recognizes. The game doesn't announce them in any
+// message we can decode, so each is inferred from what the server credits.
+const (
+	GameEventDoubleExp = "double_exp" // harvest exp is a multiple of the crop's base exp
+)
+
+const (
+	// A harvest crediting at least this multiple of the expected exp starts a
+	// double-exp event; one back under expBoostEndRatio ends it.
+	expBoostStartRatio = 1.8
+	expBoostEndRatio   = 1.3
+	// gameEventTTL expires an event no harvest has confirmed for this long,
+	// so a missed end doesn't leave the bot in event mode.
+	gameEventTTL = 3 * time.Hour
+)
+
+// GameEvents tracks the server events currently believed active. Workers
+// record observations and consult the modifiers; Status reads Active.
+type GameEvents struct {
+	clock  Clock
+	mu     sync.Mutex
+	active map[string]*model.GameEvent
+}
+
+func NewGameEvents(clock Clock) *GameEvents {
+	return &GameEvents{clock: clock, active: make(map[string]*model.GameEvent)}
+}
+
+// Active returns the events currently in effect.
+func (ge *GameEvents) Active() []model.GameEvent {
+	if ge == nil {
+		return nil
+	}
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+	now := ge.clock.Now()
+	var out []model.GameEvent
+	for _, kind := range []string{GameEventDoubleExp} {
+		if e := ge.get(kind, now); e != nil {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// ExpMultiplier returns the current harvest exp multiplier (1 when no
+// double-exp event is active).
+func (ge *GameEvents) ExpMultiplier() float64 {
+	if ge == nil {
+		return 1
+	}
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+	if e := ge.get(GameEventDoubleExp, ge.clock.Now()); e != nil {
+		return e.Multiplier
+	}
+	return 1
+}
+
+// get returns the active event of kind, dropping it once expired. ge.mu
+// must be held.
+func (ge *GameEvents) get(kind string, now time.Time) *model.GameEvent {
+	e := ge.active[kind]
+	if e != nil && now.Sub(e.LastSeen) > gameEventTTL {
+		delete(ge.active, kind)
+		return nil
+	}
+	return e
+}
+
+// observe starts, refreshes or ends an event. It reports whether the event
+// started (started) or ended (ended) with this observation.
+func (ge *GameEvents) observe(kind string, on bool, multiplier float64, now time.Time) (started, ended bool) {
+	ge.mu.Lock()
+	defer ge.mu.Unlock()
+	e := ge.get(kind, now)
+	switch {
+	case on && e == nil:
+		ge.active[kind] = &model.GameEvent{Kind: kind, Multiplier: multiplier, Since: now, LastSeen: now}
+		return true, false
+	case on:
+		e.Multiplier, e.LastSeen = multiplier, now
+	case e != nil:
+		delete(ge.active, kind)
+		return false, true
+	}
+	return false, false
+}
+
+// expectedHarvestExp is the base exp the harvested lands should credit:
+// each crop's exp with the land's exp bonus applied.
+func expectedHarvestExp(gc *GameConfig, landIDs []int64, landMap map[int64]*plantpb.LandInfo) int64 {
+	var total float64
+	for _, id := range landIDs {
+		land := landMap[id]
+		if land == nil || land.Plant == nil {
+			continue
+		}
+		exp := float64(gc.GetPlantExp(int(land.Plant.Id)))
+		if buff := land.GetBuff(); buff != nil {
+			exp = exp * (10000 + float64(buff.PlantExpBonus)) / 10000
+		}
+		total += exp
+	}
+	return int64(math.Round(total))
+}
+
+// observeHarvestExp compares the exp a harvest credited with the expected
+// base exp and updates the double-exp event. Harvests without a usable
+// expectation are ignored.
+func (f *FarmWorker) observeHarvestExp(landIDs []int64, landMap map[int64]*plantpb.LandInfo, yield harvestYield) {
+	if f.events == nil || yield.Exp <= 0 {
+		return
+	}
+	expected := expectedHarvestExp(f.gc, landIDs, landMap)
+	if expected <= 0 {
+		return
+	}
+	ratio := float64(yield.Exp) / float64(expected)
+	if ratio > expBoostEndRatio && ratio < expBoostStartRatio {
+		return // ambiguous, e.g. a batch straddling the event boundary
+	}
+	on := ratio >= expBoostStartRatio
+	started, ended := f.events.observe(GameEventDoubleExp, on, math.Round(ratio), f.net.clock.Now())
+	switch {
+	case started:
+		f.logger.Infof("活动", "检测到多倍经验 (收获经验 %d, 预期 %d, ×%.0f)", yield.Exp, expected, math.Round(ratio))
+		f.sc.RecordActivity(model.ActivityGameEvent, map[string]any{"kind": GameEventDoubleExp, "active": true, "multiplier": math.Round(ratio)})
+	case ended:
+		f.logger.Infof("活动", "多倍经验已结束 (收获经验 %d, 预期 %d)", yield.Exp, expected)
+		f.sc.RecordActivity(model.ActivityGameEvent, map[string]any{"kind": GameEventDoubleExp, "active": false})
+	}
+}
+
+// maxExpRateSeed returns the available seed with the highest farm exp per
+// hour, ignoring price, or nil when no seed has yield data.
+func (gc *GameConfig) maxExpRateSeed(available []shopSeedCandidate) *shoppb.GoodsInfo {
+	if gc == nil {
+		return nil
+	}
+	rates := make(map[int]float64)
+	for _, yr := range gc.GetSeedYieldRows() {
+		rates[yr.SeedID] = yr.FarmExpPerHourNormal
+	}
+	var best *shoppb.GoodsInfo
+	bestRate := 0.0
+	for _, c := range available {
+		if r := rates[int(c.goods.ItemId)]; r > bestRate {
+			best, bestRate = c.goods, r
+		}
+	}
+	return best
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/corepb"
+	"qq-farm-bot/proto/plantpb"
+)
+
+const expItemID = 2 // exp as credited in harvest/steal rewards
+
+// harvestYield is what one Harvest reply credited to us. With IsAll the
+// server merges every land of the batch into one reply, so items may repeat
+// per land and are summed here.
+type harvestYield struct {
+	Exp    int64
+	Gold   int64
+	Fruits map[int64]int64 // fruit item id → count
+	Others map[int64]int64 // anything else, by item id
+	Lands  int             // lands echoed back in the reply
+}
+
+// goldCost sums the gold among the cost items of a purchase reply.
+func goldCost(items []*corepb.Item) int64 {
+	var n int64
+	for _, item := range items {
+		if item.Id == 1 || item.Id == 1001 {
+			n += item.Count
+		}
+	}
+	return n
+}
+
+// decodeHarvestReply parses a Harvest reply body into its yield.
+func decodeHarvestReply(body []byte, gc *GameConfig) (harvestYield, error) {
+	reply := &plantpb.HarvestReply{}
+	if err := proto.Unmarshal(body, reply); err != nil {
+		return harvestYield{}, err
+	}
+	return yieldOf(reply.Items, gc, len(reply.Land)), nil
+}
+
+func yieldOf(items []*corepb.Item, gc *GameConfig, lands int) harvestYield {
+	y := harvestYield{Fruits: map[int64]int64{}, Others: map[int64]int64{}, Lands: lands}
+	for _, item := range items {
+		if item.Count <= 0 {
+			continue
+		}
+		switch {
+		case item.Id == expItemID:
+			y.Exp += item.Count
+		case item.Id == 1 || item.Id == 1001:
+			y.Gold += item.Count
+		case gc != nil && gc.IsFruitID(int(item.Id)):
+			y.Fruits[item.Id] += item.Count
+		default:
+			y.Others[item.Id] += item.Count
+		}
+	}
+	return y
+}
+
+// cropCounts maps the yield's fruits to crop names.
+func (y harvestYield) cropCounts(gc *GameConfig) map[string]int64 {
+	crops := make(map[string]int64, len(y.Fruits))
+	for id, n := range y.Fruits {
+		crops[gc.GetFruitName(int(id))] += n
+	}
+	return crops
+}
+
+// fruitValue is what the yield's fruits sell for at the configured prices.
+func (y harvestYield) fruitValue(gc *GameConfig) int64 {
+	var v int64
+	for id, n := range y.Fruits {
+		v += n * int64(gc.GetItemPrice(int(id)))
+	}
+	return v
+}
+
+// describe formats the yield for the 收获 log line, e.g.
+// "经验+36 白萝卜×24 金币+10".
+func (y harvestYield) describe(gc *GameConfig) string {
+	var parts []string
+	if y.Exp > 0 {
+		parts = append(parts, fmt.Sprintf("经验+%d", y.Exp))
+	}
+	crops := y.cropCounts(gc)
+	names := make([]string, 0, len(crops))
+	for name := range crops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s×%d", name, crops[name]))
+	}
+	if y.Gold > 0 {
+		parts = append(parts, fmt.Sprintf("金币+%d", y.Gold))
+	}
+	ids := make([]int64, 0, len(y.Others))
+	for id := range y.Others {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%s×%d", gc.GetItemName(int(id)), y.Others[id]))
+	}
+	if len(parts) == 0 {
+		return "无"
+	}
+	return strings.Join(parts, " ")
+}
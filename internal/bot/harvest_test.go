@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"encoding/hex"
+	"maps"
+	"testing"
+)
+
+// harvestAllReply is a recorded IsAll Harvest reply for lands 3, 4 and 7:
+// two 白萝卜 lands and one 胡萝卜 land, each crediting its own exp, plus
+// gold, a fertilizer and a zero-count fruit entry.
+const harvestAllReply = "0a04080310010a04080410010a0408071001120608c2b8021005120408021001120608c2b8021005" +
+	"120408021001120608c3b802100a120408021002120508e907100c12060881f1041001120408c3b802"
+
+func TestDecodeHarvestReply(t *testing.T) {
+	gc := loadTestGameConfig(t)
+	body, err := hex.DecodeString(harvestAllReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := decodeHarvestReply(body, gc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.Exp != 4 || y.Gold != 12 || y.Lands != 3 {
+		t.Errorf("exp %d, gold %d, lands %d; want 4, 12, 3", y.Exp, y.Gold, y.Lands)
+	}
+	if want := map[int64]int64{40002: 10, 40003: 10}; !maps.Equal(y.Fruits, want) {
+		t.Errorf("fruits = %v, want %v", y.Fruits, want)
+	}
+	if want := map[int64]int64{80001: 1}; !maps.Equal(y.Others, want) {
+		t.Errorf("others = %v, want %v", y.Others, want)
+	}
+	if got, want := y.describe(gc), "经验+4 白萝卜×10 胡萝卜×10 金币+12 化肥(1小时)×1"; got != want {
+		t.Errorf("describe = %q, want %q", got, want)
+	}
+
+	if _, err := decodeHarvestReply([]byte{0x0a, 0xff}, gc); err == nil {
+		t.Error("decoded a truncated reply")
+	}
+}
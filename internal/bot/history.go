@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// dailySummaryLead is how long before the game-day reset the daily summaries are written.
+const dailySummaryLead = time.Minute
+
+// serverTimeDelta returns the instance's server clock offset (0 before login).
+func (inst *Instance) serverTimeDelta() time.Duration {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	if inst.net == nil {
+		return 0
+	}
+	return time.Duration(inst.net.ServerTimeDelta()) * time.Millisecond
+}
+
+// writeDailySummary upserts today's summary row: level/gold/exp from the
+// current user state and harvest/steal/help counts from op_stats of the game
+// day so far. Counts come from the database rather than in-memory counters,
+// so a summary written after a restart still covers the whole day. The
+// written row is returned (nil when the bot never logged in).
+func (inst *Instance) writeDailySummary() (*model.DailySummary, error) {
+	inst.mu.RLock()
+	net := inst.net
+	accountID := inst.account.ID
+	inst.mu.RUnlock()
+	if net == nil || inst.store == nil {
+		return nil, nil
+	}
+	gid, level, exp, gold, _ := net.state.Get()
+	if gid == 0 {
+		return nil, nil // never logged in
+	}
+
+	// Day bounds are computed on the server clock, then shifted back to the
+	// local clock op_stats timestamps are recorded with.
+	delta := time.Duration(net.ServerTimeDelta()) * time.Millisecond
+	serverNow := net.ServerNow()
+	start := GameDayStart(serverNow)
+	counts, err := inst.store.GetOpCounts(context.Background(), accountID,
+		start.Add(-delta).Local(), start.AddDate(0, 0, 1).Add(-delta).Local())
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &model.DailySummary{
+		AccountID:    accountID,
+		Date:         GameDate(serverNow),
+		Level:        level,
+		Gold:         gold,
+		Exp:          exp,
+		TotalHarvest: counts[model.OpHarvest],
+		TotalSteal:   counts[model.OpSteal],
+		TotalHelp:    counts[model.OpHelpWeed] + counts[model.OpHelpBug] + counts[model.OpHelpWater],
+	}
+	if err := inst.store.UpsertDailySummary(context.Background(), summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// runDailySummaries writes a summary for every running bot shortly before
+// each game midnight until the manager is stopped.
+func (m *Manager) runDailySummaries() {
+	for {
+		timer := time.NewTimer(m.untilDailySummary())
+		select {
+		case <-m.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		m.writeDailySummaries()
+	}
+}
+
+// untilDailySummary returns the wait until dailySummaryLead before the next
+// game midnight, using the server clock offset of a running bot if any.
+func (m *Manager) untilDailySummary() time.Duration {
+	var delta time.Duration
+	m.mu.RLock()
+	for _, inst := range m.instances {
+		if inst.IsRunning() {
+			delta = inst.serverTimeDelta()
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	serverNow := time.Now().Add(delta)
+	wait := GameDayStart(serverNow).AddDate(0, 0, 1).Add(-dailySummaryLead).Sub(serverNow)
+	if wait < time.Second {
+		// Already inside the lead window (just written): wait for the next day
+		wait += 24 * time.Hour
+	}
+	return wait
+}
+
+func (m *Manager) writeDailySummaries() {
+	m.mu.RLock()
+	var running []*Instance
+	for _, inst := range m.instances {
+		if inst.IsRunning() {
+			running = append(running, inst)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, inst := range running {
+		summary, err := inst.writeDailySummary()
+		if err != nil {
+			m.sysLog.Warnf("历史", "账号 #%d 写入每日汇总失败: %v", inst.account.ID, err)
+			continue
+		}
+		if summary != nil {
+			inst.logGriefSummary(summary.Date)
+			inst.notify(NotifyDailySummary, fmt.Sprintf("%s 日报: Lv%d, 收获 %d, 偷菜 %d, 帮忙 %d",
+				summary.Date, summary.Level, summary.TotalHarvest, summary.TotalSteal, summary.TotalHelp),
+				map[string]any{"summary": summary})
+		}
+	}
+}
+
+// logGriefSummary logs which friends left weeds or insects on the account's
+// lands during the game day date.
+func (inst *Instance) logGriefSummary(date string) {
+	griefs, err := inst.store.GetFriendGrief(context.Background(), inst.account.ID, date)
+	if err != nil {
+		inst.logger.Warnf("好友", "读取放草放虫统计失败: %v", err)
+		return
+	}
+	var parts []string
+	for _, g := range griefs {
+		var did []string
+		if g.Weeds > 0 {
+			did = append(did, fmt.Sprintf("放草 %d 次", g.Weeds))
+		}
+		if g.Insects > 0 {
+			did = append(did, fmt.Sprintf("放虫 %d 次", g.Insects))
+		}
+		parts = append(parts, fmt.Sprintf("@%s %s", g.FriendName, strings.Join(did, "、")))
+	}
+	if len(parts) > 0 {
+		inst.logger.Infof("好友", "本日被 %s", strings.Join(parts, ", "))
+	}
+}
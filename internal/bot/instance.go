@@ -1,9 +1,9 @@
 package bot
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sort"
 	"sync"
 	"time"
 
@@ -19,8 +19,18 @@ type BotConfig struct {
 	ClientVersion           string
 	FarmInterval            int // seconds
 	FriendInterval          int // seconds
+	FriendListRefresh       int // seconds between full friend list fetches (0 = every check)
+	WarehouseInterval       int // seconds between bag sweeps without a harvest
 	EnableSteal             bool
+	FamilyID                int64  // account family, 0 = none
+	RPCDeny                 string // extra denied "service.method" patterns
 	ForceLowest             bool
+	ForceLowestMinExp       int // 0 = no floor
+	ForceLowestMaxPrice     int // 0 = no cap
+	ReplantOnLevelUp        bool
+	ReplantMarginPct        int // percent the new seed must beat the planted crop by
+	WarmUpLevel             int // warm-up profile below this level (0 = off)
+	MatureNoticeMinutes     int // announce maturity this early (0 = off)
 	AutoUseFertilizer       bool
 	AutoBuyFertilizer       bool
 	FertilizerTargetCount   int
@@ -42,20 +52,34 @@ type BotConfig struct {
 	PlantCropID  int    // specific crop to plant (0 = auto)
 	SellCropIDs  string // comma-separated crop IDs to sell (empty = all)
 	StealCropIDs string // comma-separated crop IDs to steal (empty = all)
+	// Crops never harvested, e.g. event crops needed for a quest
+	NoHarvestCropIDs string
 	// Planting preference
 	PreferBagSeeds bool // prioritize planting seeds from bag
 	// Anti-detection
 	EnableAntiDetection bool
+	IntervalJitterPct   int // ± percent applied to worker loop sleeps
 	// Planting strategy
 	PlantingStrategy string
 	// Debug
 	EnableDebugLog bool
+	// Minimum gap between identical mutations of one land (0 = off)
+	LandActionThrottle time.Duration
+
+	// Set by the warm-up overlay (see Profile), never from account settings
+	WarmUp          bool // plant the cheapest seed only
+	FriendsPerCycle int  // friends visited per pass (0 = all)
 }
 
 const (
 	reconnectBackoffInit    = 2 * time.Second
 	reconnectBackoffMax     = 60 * time.Second
 	maxLoginTimeoutAttempts = 3
+
+	// Persisted retry delay after consecutive login failures, honoured by
+	// AutoStart across restarts
+	loginRetryInit = time.Minute
+	loginRetryMax  = time.Hour
 )
 
 // connectError wraps a connection/login failure with the disconnect reason
@@ -70,24 +94,68 @@ func (e *connectError) Unwrap() error { return e.err }
 
 // Instance represents a running bot for a single game account.
 type Instance struct {
+	// mu guards the fields below. It may be held while taking Network or
+	// UserState locks, never the other way round, and must not be held
+	// across blocking network I/O or GameConfig/LandCache work.
 	mu      sync.RWMutex
 	account *model.Account
 	config  *BotConfig
+	profile *Profile // per-cycle view of config for the workers
 	net     *Network
 	logger  *Logger
-	store   *store.Store
+	store   store.Store
 	crypto  *Crypto
 	stats   *BotStats
 	lands   *LandCache
+	tasks   *TaskCache
+	bag     *BagCache
+	friends *FriendNames
 	sc      *StatsCollector
-	running bool
-	startAt time.Time
-	err     string
+	state   *WorkerState // worker checkpoints kept across reconnects
+	gate    *spendGate   // conserve mode around the gold reserve
+	game    *GameEvents  // server events inferred while running
+
+	// Single-flight and timing of the farm, friend and fertilizer passes
+	farmCycle, friendCycle, fertCycle *cycleGuard
+
+	// harvested is fired by the farm worker after a harvest so the
+	// warehouse worker sells without waiting for its sweep
+	harvested trigger
+	bagFull   *BagFull
+	leveledUp trigger // fired on level-up so the farm re-plans right away
+	clock     Clock
+	farm      *FarmWorker // worker of the current connection
+	friend    *FriendWorker
+	events    *EventBus
+	// notifier and qr are set by the manager; nil disables notifications
+	notifier *Notifier
+	qr       *QRSessions
+	families *Families // set by the manager; nil disables families
+	// loginSem is shared across instances to limit concurrent logins
+	loginSem chan struct{}
+	running  bool
+	startAt  time.Time
+	err      string
+	// needsRelogin is set when the watchdog gave up and a new code is needed
+	needsRelogin bool
+	// reconnectFailures counts failed reconnects since the last connection
+	reconnectFailures int
+	// loginFailures counts failed logins since the last success, persisted
+	// on the account row
+	loginFailures int
+	// RPC tracing deadline (zero = off), kept across reconnects, and the
+	// timer that switches it off
+	traceUntil time.Time
+	traceTimer Timer
 
 	stopCh chan struct{} // signals watchdog to stop
+	// watching is set while the watchdog runs: a disconnected instance may
+	// still log in again with its code
+	watching bool
 }
 
-func NewInstance(account *model.Account, serverURL, clientVersion string, s *store.Store, crypto *Crypto) *Instance {
+// newBotConfig builds the bot settings of account.
+func newBotConfig(account *model.Account, serverURL, clientVersion string) *BotConfig {
 	cfg := &BotConfig{
 		Platform:                account.Platform,
 		Code:                    account.Code,
@@ -95,8 +163,18 @@ func NewInstance(account *model.Account, serverURL, clientVersion string, s *sto
 		ClientVersion:           clientVersion,
 		FarmInterval:            account.FarmInterval,
 		FriendInterval:          account.FriendInterval,
+		FriendListRefresh:       account.FriendListRefreshInterval,
+		WarehouseInterval:       account.WarehouseInterval,
 		EnableSteal:             account.EnableSteal,
+		FamilyID:                account.FamilyID,
+		RPCDeny:                 account.RPCDeny,
 		ForceLowest:             account.ForceLowest,
+		ForceLowestMinExp:       account.ForceLowestMinExp,
+		ForceLowestMaxPrice:     account.ForceLowestMaxPrice,
+		ReplantOnLevelUp:        account.ReplantOnLevelUp,
+		ReplantMarginPct:        account.ReplantMarginPct,
+		WarmUpLevel:             account.WarmUpLevel,
+		MatureNoticeMinutes:     account.NotifyBeforeMatureMinutes,
 		AutoUseFertilizer:       account.AutoUseFertilizer,
 		AutoBuyFertilizer:       account.AutoBuyFertilizer,
 		FertilizerTargetCount:   account.FertilizerTargetCount,
@@ -118,10 +196,12 @@ func NewInstance(account *model.Account, serverURL, clientVersion string, s *sto
 		PlantCropID:      account.PlantCropID,
 		SellCropIDs:      account.SellCropIDs,
 		StealCropIDs:     account.StealCropIDs,
+		NoHarvestCropIDs: account.NoHarvestCropIDs,
 		PreferBagSeeds:   account.PreferBagSeeds,
 		PlantingStrategy: account.PlantingStrategy,
 
 		EnableAntiDetection: account.EnableAntiDetection,
+		IntervalJitterPct:   account.IntervalJitterPct,
 		EnableDebugLog:      account.EnableDebugLog,
 	}
 	if cfg.FarmInterval < 1 {
@@ -130,20 +210,52 @@ func NewInstance(account *model.Account, serverURL, clientVersion string, s *sto
 	if cfg.FriendInterval < 1 {
 		cfg.FriendInterval = 10
 	}
+	return cfg
+}
 
-	logger := NewLogger(account.ID, s)
+func NewInstance(account *model.Account, serverURL, clientVersion string, s store.Store, crypto *Crypto, events *EventBus, logs *LogHub, loginSem chan struct{}) *Instance {
+	cfg := newBotConfig(account, serverURL, clientVersion)
+
+	logger := NewLogger(account.ID, s, logs)
 	logger.SetDebug(cfg.EnableDebugLog)
 
-	return &Instance{
-		account: account,
-		config:  cfg,
-		logger:  logger,
-		store:   s,
-		stats:   &BotStats{},
-		lands:   NewLandCache(),
-		crypto:  crypto,
-		sc:      NewStatsCollector(account.ID, s),
-	}
+	clock := realClock{}
+	inst := &Instance{
+		account:   account,
+		config:    cfg,
+		logger:    logger,
+		store:     s,
+		stats:     &BotStats{},
+		lands:     NewLandCache(clock),
+		tasks:     NewTaskCache(),
+		bag:       NewBagCache(),
+		friends:   NewFriendNames(),
+		harvested: newTrigger(),
+		bagFull:   NewBagFull(),
+		leveledUp: newTrigger(),
+		clock:     clock,
+		crypto:    crypto,
+		sc:        NewStatsCollector(account.ID, s),
+		state:     NewWorkerState(s, account.ID, logger),
+		gate:      newSpendGate(account.GoldReserve, logger),
+		events:    events,
+
+		farmCycle:   newCycleGuard("巡田", logger),
+		friendCycle: newCycleGuard("好友", logger),
+		fertCycle:   newCycleGuard("化肥", logger),
+
+		loginSem:      loginSem,
+		loginFailures: account.LoginFailCount,
+	}
+	inst.profile = NewProfile(cfg, logger)
+	inst.game = NewGameEvents(inst.clock)
+	inst.lands.SetOnUpdate(func() { inst.publish(EventLandsUpdated) })
+	return inst
+}
+
+// publish emits a status change event for this account.
+func (inst *Instance) publish(kind StatusEventKind) {
+	inst.events.Publish(inst.account.ID, kind)
 }
 
 func (inst *Instance) Start() error {
@@ -158,8 +270,12 @@ func (inst *Instance) Start() error {
 	if err := inst.connectAndRun(); err != nil {
 		return err
 	}
+	inst.sc.RecordActivity(model.ActivityBotStart, nil)
 
 	// Start watchdog for auto-reconnection
+	inst.mu.Lock()
+	inst.watching = true
+	inst.mu.Unlock()
 	go inst.watchdog()
 
 	return nil
@@ -167,7 +283,33 @@ func (inst *Instance) Start() error {
 
 // connectAndRun creates a new Network, connects, logs in, and starts all workers.
 func (inst *Instance) connectAndRun() error {
-	net := NewNetwork(inst.logger, inst.crypto)
+	// Wait for a login slot so mass starts/reconnects don't stampede the gate
+	if inst.loginSem != nil {
+		select {
+		case inst.loginSem <- struct{}{}:
+			defer func() { <-inst.loginSem }()
+		case <-inst.stopCh:
+			return fmt.Errorf("bot stopped")
+		}
+	}
+
+	net := NewNetwork(inst.logger, inst.crypto, inst.clock)
+	inst.mu.RLock()
+	net.SetRPCDeny(inst.config.RPCDeny)
+	net.SetTrace(inst.traceUntil)
+	inst.mu.RUnlock()
+	net.onStateChange = func() { inst.publish(EventStateChanged) }
+	net.onLevelUp = func(from, to int64) {
+		inst.notify(NotifyLevelUp, fmt.Sprintf("升级 Lv%d → Lv%d", from, to), map[string]any{"from": from, "to": to})
+		inst.sc.RecordActivity(model.ActivityLevelUp, map[string]any{"from": from, "to": to})
+		inst.leveledUp.fire()
+	}
+	net.onGoldChange = func(from, to int64) {
+		inst.gate.observe(to)
+		if isLargeGoldChange(from, to) {
+			inst.sc.RecordActivity(model.ActivityGoldChange, map[string]any{"from": from, "to": to, "delta": to - from})
+		}
+	}
 
 	// Connect
 	inst.logger.Infof("启动", "正在连接 %s 平台...", inst.config.Platform)
@@ -175,6 +317,7 @@ func (inst *Instance) connectAndRun() error {
 		inst.mu.Lock()
 		inst.err = err.Error()
 		inst.mu.Unlock()
+		inst.recordLoginFailure(net.GetDisconnectReason())
 		return fmt.Errorf("connect: %w", err)
 	}
 
@@ -184,15 +327,33 @@ func (inst *Instance) connectAndRun() error {
 		inst.mu.Lock()
 		inst.err = err.Error()
 		inst.mu.Unlock()
+		if reason == DisconnectLoginExpired {
+			inst.logger.Warnf("登录", "登录码已失效, 需要重新扫码")
+			inst.requireRelogin(reason.String())
+		}
+		inst.recordLoginFailure(reason)
 		return &connectError{reason: reason, err: fmt.Errorf("login: %w", err)}
 	}
 
 	inst.mu.Lock()
 	inst.net = net
 	inst.running = true
-	inst.startAt = time.Now()
+	inst.startAt = inst.clock.Now()
 	inst.err = ""
+	inst.needsRelogin = false
+	inst.reconnectFailures = 0
+	hadFailures := inst.loginFailures > 0
+	inst.loginFailures = 0
+	familyID := inst.config.FamilyID
 	inst.mu.Unlock()
+	inst.publish(EventStarted)
+	gid, _, _, _, _ := net.state.Get()
+	inst.families.Join(inst.account.UserID, familyID, inst.account.ID, gid)
+	if hadFailures && inst.store != nil {
+		if err := inst.store.ClearLoginFailure(context.Background(), inst.account.ID); err != nil {
+			inst.logger.Warnf("登录", "清除登录失败记录失败: %v", err)
+		}
+	}
 
 	// After login, persist account name from game server to database
 	_, _, _, _, loginName := net.state.Get()
@@ -204,7 +365,7 @@ func (inst *Instance) connectAndRun() error {
 		}
 		inst.mu.Unlock()
 		if needsUpdate {
-			inst.store.UpdateAccountName(inst.account.ID, loginName)
+			inst.store.UpdateAccountName(context.Background(), inst.account.ID, loginName)
 		}
 	}
 
@@ -212,25 +373,66 @@ func (inst *Instance) connectAndRun() error {
 	net.StartHeartbeat(inst.config.ClientVersion, 25*time.Second)
 
 	// Start workers
-	farm := NewFarmWorker(net, inst.logger, inst.config, inst.lands, inst.sc)
+	farm := NewFarmWorker(net, inst.logger, inst.profile, inst.stats, inst.lands, inst.friends, inst.game, inst.harvested, inst.leveledUp, inst.bagFull, inst.sc, inst.state, inst.gate)
+	farm.notify = inst.notify
+	farm.cycle = inst.farmCycle
+	inst.mu.Lock()
+	inst.farm = farm
+	inst.mu.Unlock()
 	go farm.RunLoop()
 
-	friend := NewFriendWorker(net, inst.logger, inst.config, inst.stats, inst.sc)
+	friend := NewFriendWorker(net, inst.logger, inst.profile, inst.stats, inst.friends, inst.bagFull, inst.sc, inst.families, inst.account.UserID, inst.state)
+	friend.cycle = inst.friendCycle
+	inst.mu.Lock()
+	inst.friend = friend
+	inst.mu.Unlock()
 	go friend.RunLoop()
 
-	task := NewTaskWorker(net, inst.logger, inst.config, inst.sc)
+	task := NewTaskWorker(net, inst.logger, inst.profile, inst.tasks, inst.sc)
 	go task.RunLoop()
 
-	warehouse := NewWarehouseWorker(net, inst.logger, inst.config, inst.sc)
+	warehouse := NewWarehouseWorker(net, inst.logger, inst.profile, inst.bag, inst.harvested, inst.bagFull, inst.sc)
 	go warehouse.RunLoop()
 
-	fertilizer := NewFertilizerWorker(net, inst.logger, inst.config, inst.sc)
+	fertilizer := NewFertilizerWorker(net, inst.logger, inst.profile, inst.bag, inst.game, inst.sc, inst.state, inst.gate)
+	fertilizer.cycle = inst.fertCycle
 	go fertilizer.RunLoop()
 
 	return nil
 }
 
+// loginRetryDelay is how long after the n-th consecutive login failure the
+// account may be auto-started again. Failures a retry can't fix wait the
+// longest.
+func loginRetryDelay(failures int, reason DisconnectReason) time.Duration {
+	if !reason.Retryable() || failures > 16 {
+		return loginRetryMax
+	}
+	return min(loginRetryInit<<max(failures-1, 0), loginRetryMax)
+}
+
+// recordLoginFailure persists a failed connect or login so a restart
+// doesn't retry the account before the backoff has passed.
+func (inst *Instance) recordLoginFailure(reason DisconnectReason) {
+	inst.mu.Lock()
+	inst.loginFailures++
+	failures := inst.loginFailures
+	inst.mu.Unlock()
+	if inst.store == nil {
+		return
+	}
+	retryAt := inst.clock.Now().Add(loginRetryDelay(failures, reason))
+	if err := inst.store.SetLoginFailure(context.Background(), inst.account.ID, reason.String(), failures, retryAt); err != nil {
+		inst.logger.Warnf("登录", "保存登录失败记录失败: %v", err)
+	}
+}
+
 func (inst *Instance) watchdog() {
+	defer func() {
+		inst.mu.Lock()
+		inst.watching = false
+		inst.mu.Unlock()
+	}()
 	backoff := reconnectBackoffInit
 	loginTimeoutCount := 0
 
@@ -253,12 +455,18 @@ func (inst *Instance) watchdog() {
 		inst.mu.Lock()
 		inst.running = false
 		inst.mu.Unlock()
+		inst.publish(EventStopped)
+		inst.sc.RecordActivity(model.ActivityDisconnect, map[string]any{"reason": reason.String(), "retryable": reason.Retryable()})
 
 		if !reason.Retryable() {
 			inst.logger.Warnf("系统", "连接断开 (reason=%s)，不再重连", reason)
 			inst.mu.Lock()
 			inst.err = fmt.Sprintf("断开: %s", reason)
 			inst.mu.Unlock()
+			if reason == DisconnectKickout {
+				inst.notify(NotifyKickout, "被踢下线", map[string]any{"reason": reason.String()})
+				inst.requireRelogin(reason.String())
+			}
 			return
 		}
 
@@ -269,6 +477,7 @@ func (inst *Instance) watchdog() {
 				inst.mu.Lock()
 				inst.err = fmt.Sprintf("登录超时达上限 (%d/%d)", loginTimeoutCount, maxLoginTimeoutAttempts)
 				inst.mu.Unlock()
+				inst.requireRelogin(DisconnectLoginTimeout.String())
 				return
 			}
 		}
@@ -278,7 +487,7 @@ func (inst *Instance) watchdog() {
 		// Reconnect loop: retry with exponential backoff until success or stop.
 		for {
 			select {
-			case <-time.After(backoff):
+			case <-inst.clock.After(backoff):
 			case <-inst.stopCh:
 				inst.logger.Info("系统", "Bot 已停止")
 				return
@@ -287,13 +496,23 @@ func (inst *Instance) watchdog() {
 			err := inst.connectAndRun()
 			if err == nil {
 				inst.logger.Infof("重连", "成功")
+				inst.sc.RecordActivity(model.ActivityReconnect, nil)
 				backoff = reconnectBackoffInit
 				loginTimeoutCount = 0
 				break
 			}
 
-			// Check if reconnection failed due to login timeout.
+			inst.mu.Lock()
+			inst.reconnectFailures++
+			inst.mu.Unlock()
+			inst.publish(EventStateChanged)
+
+			// Stop on failures retrying can't fix; count login timeouts.
 			var ce *connectError
+			if errors.As(err, &ce) && !ce.reason.Retryable() {
+				inst.logger.Warnf("重连", "失败: %v，不再重连", err)
+				return
+			}
 			if errors.As(err, &ce) && ce.reason == DisconnectLoginTimeout {
 				loginTimeoutCount++
 				if loginTimeoutCount >= maxLoginTimeoutAttempts {
@@ -301,6 +520,7 @@ func (inst *Instance) watchdog() {
 					inst.mu.Lock()
 					inst.err = fmt.Sprintf("登录超时达上限 (%d/%d)", loginTimeoutCount, maxLoginTimeoutAttempts)
 					inst.mu.Unlock()
+					inst.requireRelogin(DisconnectLoginTimeout.String())
 					return
 				}
 			}
@@ -315,426 +535,250 @@ func (inst *Instance) watchdog() {
 }
 
 func (inst *Instance) Stop() {
+	defer inst.publish(EventStopped)
+	// Persist today's summary so the history chart covers partial days
+	if inst.IsRunning() {
+		if _, err := inst.writeDailySummary(); err != nil {
+			inst.logger.Warnf("历史", "写入每日汇总失败: %v", err)
+		}
+	}
 	inst.mu.Lock()
-	defer inst.mu.Unlock()
-
 	// Signal watchdog to stop
+	stopped := false
 	if inst.stopCh != nil {
 		select {
 		case <-inst.stopCh:
 			// already closed
 		default:
 			close(inst.stopCh)
+			stopped = true
 		}
 	}
+	net, friend := inst.net, inst.friend
+	inst.running = false
+	inst.needsRelogin = false
+	inst.mu.Unlock()
+	inst.families.Leave(inst.account.ID)
 
-	if inst.net != nil {
-		inst.net.Close()
+	// Close outside the lock: the close frame write may block for writeWait.
+	// A visit in progress is ended first, while the connection is still up
+	if net != nil {
+		friend.leaveCurrentVisit()
+		net.Close()
+	}
+	if stopped {
+		inst.sc.RecordActivity(model.ActivityBotStop, nil)
 	}
-	inst.running = false
 }
 
-func (inst *Instance) Status() *model.BotStatus {
-	inst.mu.Lock()
-	defer inst.mu.Unlock()
+// isLargeGoldChange reports whether a gold update is worth an activity
+// entry: at least 1000 gold and a fifth of the previous balance.
+func isLargeGoldChange(from, to int64) bool {
+	delta := abs64(to - from)
+	return delta >= 1000 && delta >= from/5
+}
 
+// Status snapshots the instance under a read lock and computes everything
+// else (level-up estimate, lands) from the snapshot without holding inst.mu.
+func (inst *Instance) Status() *model.BotStatus {
+	inst.mu.RLock()
 	s := &model.BotStatus{
 		AccountID: inst.account.ID,
 		Running:   inst.running,
 		Platform:  inst.config.Platform,
 		Error:     inst.err,
+
+		NeedsRelogin:      inst.needsRelogin,
+		ReconnectFailures: inst.reconnectFailures,
 	}
+	net, lands, tasks, startAt := inst.net, inst.lands, inst.tasks, inst.startAt
+	plan := plantPlan{
+		cropID:      inst.config.PlantCropID,
+		strategy:    inst.config.PlantingStrategy,
+		forceLowest: inst.config.ForceLowest,
+		minExp:      inst.config.ForceLowestMinExp,
+		maxPrice:    inst.config.ForceLowestMaxPrice,
+	}
+	inst.mu.RUnlock()
+	counters := inst.stats.Snapshot()
 
 	// Read state from net even when stopped — net object is closed but not nil'd,
 	// so state persists after disconnect/stop.
-	if inst.net != nil {
-		gid, level, exp, gold, name := inst.net.state.Get()
+	if net != nil {
+		gid, level, exp, gold, name := net.state.Get()
 		s.GID = gid
 		s.Name = name
 		s.Level = level
 		s.Exp = exp
 		s.Gold = gold
+		s.Coupons = net.state.GetCoupons()
 	}
 
-	if !inst.startAt.IsZero() {
-		startAt := inst.startAt
+	if !startAt.IsZero() {
 		s.StartedAt = &startAt
 	}
 
 	// Calculate level up estimation only when running
-	if inst.running && s.Level > 0 {
+	if s.Running && s.Level > 0 {
 		gc := GetGameConfig()
-		if gc != nil {
-			if nextExp, hasNext := gc.GetNextLevelExp(int(s.Level)); hasNext {
-				s.NextLevelExp = nextExp
-				s.ExpToNextLevel = nextExp - s.Exp
-				if s.ExpToNextLevel < 0 {
-					s.ExpToNextLevel = 0
-				}
-				s.ExpRatePerHour, s.HoursToNextLevel = inst.estimateLevelUp(s.ExpToNextLevel)
+		if gc.Degraded() {
+			s.ConfigHealth = model.ConfigHealthGameConfigMissing
+		} else if !gc.HasLevelTable() {
+			s.ConfigHealth = model.ConfigHealthLevelTableMissing
+		} else if nextExp, hasNext := nextLevelExpAbove(gc, s.Level, s.Exp); hasNext {
+			s.NextLevelExp = nextExp
+			s.ExpToNextLevel = nextExp - s.Exp
+			seed, belowFloor := resolveStrategySeed(gc, plan, s.Level)
+			if belowFloor {
+				s.Warnings = append(s.Warnings, model.WarningSeedBelowFloor)
 			}
+			in := levelUpInputs{
+				NowSec:          inst.clock.Now().Unix(),
+				ExpToNextLevel:  s.ExpToNextLevel,
+				GC:              gc,
+				Seed:            seed,
+				PendingTaskExp:  tasks.PendingExp(),
+				StealExpPerHour: counters.StealExpPerHour,
+			}
+			if lands != nil {
+				in.Harvests = lands.GetHarvestInfo()
+				_, _, in.Lands = lands.Get()
+			}
+			est := estimateLevelUp(in)
+			s.ExpRatePerHour, s.HoursToNextLevel = est.ExpRatePerHour, est.HoursToNextLevel
+			s.CropExpPerHour, s.StealExpPerHour, s.PendingTaskExp = est.CropExpPerHour, est.StealExpPerHour, est.TaskExp
 		}
 	}
 
-	if inst.stats != nil {
-		s.TotalSteal = inst.stats.TotalSteal
-		s.TotalHelp = inst.stats.TotalHelp
-		s.FriendsCount = inst.stats.FriendsCount
+	s.GameEvents = inst.game.Active()
+	if since := inst.bagFull.Since(); s.Running && !since.IsZero() {
+		s.Warnings = append(s.Warnings, model.WarningBagFull)
 	}
-
-	if inst.lands != nil {
-		totalLands, unlockedLands, landStatuses := inst.lands.Get()
-		s.TotalLands = totalLands
-		s.UnlockedLands = unlockedLands
-		s.Lands = landStatuses
+	if s.Running && inst.gate.Conserving() {
+		s.Warnings = append(s.Warnings, model.WarningConserve)
 	}
-
-	return s
-}
-
-// effectiveGrowSec computes growth time after applying land time-reduction buff
-// and subtracting fertilizer skip time (longest-phase optimization).
-func effectiveGrowSec(baseSec, fertReduceSec int, timeReducePct int64) int64 {
-	base := int64(baseSec)
-	if timeReducePct > 0 {
-		base = base * (10000 - timeReducePct) / 10000
-	}
-	fert := int64(fertReduceSec)
-	if timeReducePct > 0 {
-		fert = fert * (10000 - timeReducePct) / 10000
-	}
-	eff := base - fert
-	if eff < 1 {
-		eff = 1
-	}
-	return eff
-}
-
-// resolveStrategySeed determines which seed the bot would plant based on the
-// current planting configuration (explicit crop ID, strategy rules, or defaults).
-// Uses only static GameConfig data — no network calls required.
-// Returns nil if no suitable seed can be determined.
-func (inst *Instance) resolveStrategySeed(gc *GameConfig) *SeedYieldRow {
-	if gc == nil {
-		return nil
-	}
-
-	_, level, _, _, _ := inst.net.state.Get()
-	yieldRows := gc.GetSeedYieldRows()
-	if len(yieldRows) == 0 {
-		return nil
-	}
-
-	var available []SeedYieldRow
-	for _, yr := range yieldRows {
-		if yr.RequiredLevel <= int(level) && yr.GrowTimeSec > 0 {
-			available = append(available, yr)
-		}
-	}
-	if len(available) == 0 {
-		return nil
-	}
-
-	// 1. Explicit crop ID override
-	if inst.config.PlantCropID > 0 {
-		targetSeedID := gc.GetSeedIDForCrop(inst.config.PlantCropID)
-		if targetSeedID > 0 {
-			for i, yr := range available {
-				if yr.SeedID == targetSeedID {
-					return &available[i]
-				}
+	for name, g := range map[string]*cycleGuard{"farm": inst.farmCycle, "friend": inst.friendCycle, "fertilizer": inst.fertCycle} {
+		if t, ok := g.timing(); ok {
+			if s.Cycles == nil {
+				s.Cycles = make(map[string]model.CycleTiming, 3)
 			}
+			s.Cycles[name] = t
 		}
 	}
+	s.TotalHarvest = counters.TotalHarvest
+	s.HarvestExp = counters.HarvestExp
+	s.MeasuredCropExpPerHour = counters.HarvestExpPerHour
+	s.HarvestItems = counters.HarvestItems
+	s.TotalSteal = counters.TotalSteal
+	s.TotalHelp = counters.TotalHelp
+	s.FriendsCount = counters.FriendsCount
 
-	// 2. Strategy-based selection
-	strategy := ParsePlantingStrategy(inst.config.PlantingStrategy)
-	if strategy != nil {
-		if strategy.Mode == StrategyModeFastestLevelUp {
-			// fastest_levelup does per-round optimization; approximate with best exp efficiency
-			best := &available[0]
-			for i := 1; i < len(available); i++ {
-				if available[i].FarmExpPerHourNormal > best.FarmExpPerHourNormal {
-					best = &available[i]
-				}
-			}
-			return best
-		}
-
-		var candidates []SeedCandidate
-		for _, yr := range available {
-			sc := SeedCandidate{
-				SeedID:             yr.SeedID,
-				Name:               yr.Name,
-				RequiredLevel:      yr.RequiredLevel,
-				Price:              yr.Price,
-				ExpPerHarvest:      yr.ExpHarvest,
-				Seasons:            yr.Seasons,
-				GrowTimeSec:        yr.GrowTimeSec,
-				ExpEfficiency:      yr.FarmExpPerHourNormal,
-				GrowTimeNormalFert: yr.GrowTimeNormalFert,
-			}
-			if yr.Price > 0 {
-				sc.GoldEfficiency = float64(yr.ExpHarvest*yr.Seasons) / float64(yr.Price)
-			}
-			candidates = append(candidates, sc)
-		}
-
-		result := ApplyStrategy(strategy, candidates)
-		if len(result) > 0 {
-			for i, yr := range available {
-				if yr.SeedID == result[0].SeedID {
-					return &available[i]
-				}
+	if lands != nil {
+		totalLands, unlockedLands, landStatuses := lands.Get()
+		s.TotalLands = totalLands
+		s.UnlockedLands = unlockedLands
+		s.Lands = landStatuses
+		if s.Running {
+			next, check := lands.Schedule()
+			if next.AtSec > 0 {
+				at := time.Unix(next.AtSec, 0)
+				s.NextEventAt, s.NextEventType = &at, next.Type
 			}
-		}
-	}
-
-	// 3. ForceLowest: pick lowest required level seed
-	if inst.config.ForceLowest {
-		best := &available[0]
-		for i := 1; i < len(available); i++ {
-			if available[i].RequiredLevel < best.RequiredLevel ||
-				(available[i].RequiredLevel == best.RequiredLevel && available[i].Price < best.Price) {
-				best = &available[i]
+			if !check.IsZero() {
+				s.NextFarmCheckAt = &check
 			}
 		}
-		return best
 	}
 
-	// 4. Default: best exp efficiency (matches findBestSeed fallback)
-	best := &available[0]
-	for i := 1; i < len(available); i++ {
-		if available[i].FarmExpPerHourNormal > best.FarmExpPerHourNormal {
-			best = &available[i]
-		}
-	}
-	return best
+	return s
 }
 
-// estimateLevelUp calculates expected exp rate and hours to next level using a
-// time-series simulation. It builds discrete harvest events from currently
-// growing crops, then simulates future planting cycles using the configured
-// planting strategy to produce an accurate level-up timeline.
-func (inst *Instance) estimateLevelUp(expToNextLevel int64) (expRatePerHour float64, hoursToNextLevel float64) {
-	if inst.lands == nil || expToNextLevel <= 0 {
-		return 0, 0
-	}
+func (inst *Instance) Logger() *Logger {
+	return inst.logger
+}
 
-	harvestInfos := inst.lands.GetHarvestInfo()
-	gc := GetGameConfig()
-	nowSec := time.Now().Unix()
+// LandsSnapshot returns the last raw lands reply and its classification.
+func (inst *Instance) LandsSnapshot() LandsSnapshot {
+	return inst.lands.Snapshot()
+}
 
-	// --- Phase 1: Build events from current crops + track per-land free times ---
+// Bag returns the last fetched bag contents.
+func (inst *Instance) Bag() model.BagSnapshot {
+	return inst.bag.Snapshot()
+}
 
-	type harvestEvent struct {
-		timeSec int64
-		exp     int64
-	}
-	type landState struct {
-		landID        int64
-		freeTimeSec   int64 // when this land becomes available for replanting
-		expBonusPct   int64
-		timeReducePct int64
+// LandAction runs a manual action on one land through the farm loop,
+// waiting for any cycle in progress to finish first.
+func (inst *Instance) LandAction(ctx context.Context, landID int64, action string) error {
+	inst.mu.RLock()
+	farm, running := inst.farm, inst.running
+	inst.mu.RUnlock()
+	if !running || farm == nil {
+		return ErrNotRunning
 	}
+	return farm.do(ctx, landID, action)
+}
 
-	var events []harvestEvent
-	var landStates []landState
-	var totalExpPerMin float64
-
-	for _, h := range harvestInfos {
-		adjustedExp := float64(h.CropExp) * (10000 + float64(h.ExpBonusPct)) / 10000.0
-		if adjustedExp <= 0 {
-			continue
-		}
-
-		seasons := 1
-		var season2GrowSec int64
-		if gc != nil && h.CropID > 0 {
-			seasons = gc.GetPlantSeasons(int(h.CropID))
-			if seasons >= 2 {
-				if pd := gc.GetPlantPhaseData(int(h.CropID)); pd != nil && pd.Season2GrowTime > 0 {
-					season2GrowSec = effectiveGrowSec(pd.Season2GrowTime, pd.Season2MaxPhase, h.TimeReducePct)
-				}
-			}
-		}
-
-		if h.CycleTimeSec > 0 {
-			totalCycleExp := adjustedExp * float64(seasons)
-			totalCycleSec := float64(h.CycleTimeSec)
-			if seasons >= 2 && season2GrowSec > 0 {
-				totalCycleSec += float64(season2GrowSec)
-			}
-			totalExpPerMin += totalCycleExp / (totalCycleSec / 60.0)
-		}
-
-		currentSeason := h.Season
-		if currentSeason < 1 {
-			currentSeason = 1
-		}
-
-		var lastHarvestTime int64
-
-		if h.IsMature {
-			events = append(events, harvestEvent{timeSec: nowSec, exp: int64(adjustedExp)})
-			lastHarvestTime = nowSec
-			if currentSeason <= 1 && seasons >= 2 && season2GrowSec > 0 {
-				s2Time := nowSec + season2GrowSec
-				events = append(events, harvestEvent{timeSec: s2Time, exp: int64(adjustedExp)})
-				lastHarvestTime = s2Time
-			}
-		} else if h.IsGrowing && h.MatureTimeSec > nowSec {
-			events = append(events, harvestEvent{timeSec: h.MatureTimeSec, exp: int64(adjustedExp)})
-			lastHarvestTime = h.MatureTimeSec
-			if currentSeason <= 1 && seasons >= 2 && season2GrowSec > 0 {
-				s2Time := h.MatureTimeSec + season2GrowSec
-				events = append(events, harvestEvent{timeSec: s2Time, exp: int64(adjustedExp)})
-				lastHarvestTime = s2Time
-			}
-		}
-
-		if lastHarvestTime > 0 {
-			landStates = append(landStates, landState{
-				landID:        h.LandID,
-				freeTimeSec:   lastHarvestTime,
-				expBonusPct:   h.ExpBonusPct,
-				timeReducePct: h.TimeReducePct,
-			})
-		}
+// SetTrace turns RPC tracing on for d, or off when d is 0, and returns when
+// it ends. Tracing follows the instance across reconnects.
+func (inst *Instance) SetTrace(d time.Duration) time.Time {
+	var until time.Time
+	if d > 0 {
+		until = inst.clock.Now().Add(d)
 	}
-
-	// --- Phase 2: Include empty/idle lands in the simulation ---
-	_, _, landStatuses := inst.lands.Get()
-	trackedLands := make(map[int64]bool, len(landStates))
-	for _, ls := range landStates {
-		trackedLands[ls.landID] = true
+	inst.mu.Lock()
+	if inst.traceTimer != nil {
+		inst.traceTimer.Stop()
+		inst.traceTimer = nil
 	}
-	for _, ls := range landStatuses {
-		if !ls.Unlocked || trackedLands[ls.ID] || ls.CropID > 0 {
-			continue
-		}
-		// Skip slave lands occupied by a master's crop
-		if ls.MasterLandID > 0 && ls.MasterLandID != ls.ID && trackedLands[ls.MasterLandID] {
-			continue
-		}
-		landStates = append(landStates, landState{
-			landID:        ls.ID,
-			freeTimeSec:   nowSec,
-			expBonusPct:   ls.ExpBonusPct,
-			timeReducePct: ls.TimeReducePct,
-		})
+	wasOn := !inst.traceUntil.IsZero()
+	inst.traceUntil = until
+	if d > 0 {
+		inst.traceTimer = inst.clock.AfterFunc(d, func() { inst.endTrace(until) })
 	}
-
-	if len(landStates) == 0 {
-		return 0, 0
+	if inst.net != nil {
+		inst.net.SetTrace(until)
 	}
+	inst.mu.Unlock()
 
-	expRatePerHour = totalExpPerMin * 60
-
-	// --- Phase 3: Resolve strategy seed for future planting cycles ---
-	strategySeed := inst.resolveStrategySeed(gc)
-
-	// --- Phase 4: Simulate future planting cycles using the strategy seed ---
-	if strategySeed != nil {
-		s1GrowTimeSec := strategySeed.GrowTimeSec
-		s1FertReduceSec := strategySeed.NormalFertReduceSec
-		s2GrowTimeSec := strategySeed.Season2GrowTimeSec
-		s2FertReduceSec := strategySeed.Season2FertReduceSec
-		seedSeasons := strategySeed.Seasons
-		seedExp := strategySeed.ExpHarvest
-
-		maxSimTime := nowSec + 30*24*3600 // cap simulation at 30 days
-		maxCycles := 50
-
-		// Track cumulative exp for early termination
-		var totalSimExp int64
-		for _, e := range events {
-			totalSimExp += e.exp
-		}
-
-		for cycle := 0; cycle < maxCycles && totalSimExp < expToNextLevel; cycle++ {
-			for i := range landStates {
-				ls := &landStates[i]
-				if ls.freeTimeSec >= maxSimTime {
-					continue
-				}
-
-				s1Eff := effectiveGrowSec(s1GrowTimeSec, s1FertReduceSec, ls.timeReducePct)
-				adjExp := int64(seedExp) * (10000 + ls.expBonusPct) / 10000
-				if adjExp <= 0 {
-					adjExp = int64(seedExp)
-				}
-
-				harvest1Time := ls.freeTimeSec + s1Eff
-				events = append(events, harvestEvent{timeSec: harvest1Time, exp: adjExp})
-				totalSimExp += adjExp
-
-				lastHarvest := harvest1Time
-				if seedSeasons >= 2 && s2GrowTimeSec > 0 {
-					s2Eff := effectiveGrowSec(s2GrowTimeSec, s2FertReduceSec, ls.timeReducePct)
-					harvest2Time := harvest1Time + s2Eff
-					events = append(events, harvestEvent{timeSec: harvest2Time, exp: adjExp})
-					totalSimExp += adjExp
-					lastHarvest = harvest2Time
-				}
-
-				ls.freeTimeSec = lastHarvest
-			}
-		}
-
-		var stratExpPerMin float64
-		for _, ls := range landStates {
-			adjExp := float64(seedExp) * (10000 + float64(ls.expBonusPct)) / 10000.0
-			s1Eff := effectiveGrowSec(s1GrowTimeSec, s1FertReduceSec, ls.timeReducePct)
-			totalExp := adjExp * float64(seedSeasons)
-			totalTime := float64(s1Eff)
-			if seedSeasons >= 2 && s2GrowTimeSec > 0 {
-				s2Eff := effectiveGrowSec(s2GrowTimeSec, s2FertReduceSec, ls.timeReducePct)
-				totalTime += float64(s2Eff)
-			}
-			if totalTime > 0 {
-				stratExpPerMin += totalExp / (totalTime / 60.0)
-			}
-		}
-		if stratExpPerMin > 0 {
-			expRatePerHour = stratExpPerMin * 60
-		}
+	if d > 0 {
+		inst.logger.Infof("RPC", "已开启 RPC 跟踪, %s 后自动关闭", d)
+	} else if wasOn {
+		inst.logger.Infof("RPC", "已关闭 RPC 跟踪")
 	}
+	return until
+}
 
-	// --- Phase 5: Walk sorted events to find the level-up moment ---
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].timeSec < events[j].timeSec
-	})
-
-	remaining := expToNextLevel
-	lastEventTime := nowSec
-	for _, e := range events {
-		remaining -= e.exp
-		if remaining <= 0 {
-			secsUntil := e.timeSec - nowSec
-			if secsUntil < 0 {
-				secsUntil = 0
-			}
-			hoursToNextLevel = float64(secsUntil) / 3600.0
-			return
+// endTrace switches off the tracing that was set to end at until, unless it
+// has been changed since.
+func (inst *Instance) endTrace(until time.Time) {
+	inst.mu.Lock()
+	expired := inst.traceUntil.Equal(until)
+	if expired {
+		inst.traceUntil, inst.traceTimer = time.Time{}, nil
+		if inst.net != nil {
+			inst.net.SetTrace(time.Time{})
 		}
-		lastEventTime = e.timeSec
 	}
-
-	// --- Phase 6: Fallback — use steady-state rate for any remaining exp ---
-	if expRatePerHour > 0 {
-		additionalSecs := float64(remaining) / (expRatePerHour / 3600.0)
-		totalSecs := float64(lastEventTime-nowSec) + additionalSecs
-		if totalSecs < 0 {
-			totalSecs = 0
-		}
-		hoursToNextLevel = totalSecs / 3600.0
+	inst.mu.Unlock()
+	if expired {
+		inst.logger.Infof("RPC", "RPC 跟踪已到期, 自动关闭")
 	}
-	return
 }
 
-func (inst *Instance) Logger() *Logger {
-	return inst.logger
+// loginIdentity returns the code the bot logs in with and the account name.
+func (inst *Instance) loginIdentity() (code, name string) {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	return inst.config.Code, inst.account.Name
+}
+
+// active reports whether the instance holds its login code: it is connected,
+// or its watchdog hasn't given up reconnecting.
+func (inst *Instance) active() bool {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	return inst.running || inst.watching
 }
 
 func (inst *Instance) IsRunning() bool {
@@ -744,8 +788,9 @@ func (inst *Instance) IsRunning() bool {
 }
 
 // UpdateConfig applies updated account settings to the running bot config.
-// Workers read config fields via the shared pointer each loop iteration,
+// Workers copy the config through the profile at the start of each pass,
 // so updated values take effect on the next cycle automatically.
+// Instance code reading inst.config directly holds inst.mu.
 func (inst *Instance) UpdateConfig(account *model.Account) {
 	inst.mu.Lock()
 	defer inst.mu.Unlock()
@@ -754,43 +799,67 @@ func (inst *Instance) UpdateConfig(account *model.Account) {
 		return
 	}
 
-	inst.config.FarmInterval = account.FarmInterval
-	if inst.config.FarmInterval < 1 {
-		inst.config.FarmInterval = 10
-	}
-	inst.config.FriendInterval = account.FriendInterval
-	if inst.config.FriendInterval < 1 {
-		inst.config.FriendInterval = 10
-	}
-
-	inst.config.EnableSteal = account.EnableSteal
-	inst.config.ForceLowest = account.ForceLowest
-	inst.config.AutoUseFertilizer = account.AutoUseFertilizer
-	inst.config.AutoBuyFertilizer = account.AutoBuyFertilizer
-	inst.config.FertilizerTargetCount = account.FertilizerTargetCount
-	inst.config.FertilizerBuyDailyLimit = account.FertilizerBuyDailyLimit
-
-	inst.config.EnableHarvest = account.EnableHarvest
-	inst.config.EnablePlant = account.EnablePlant
-	inst.config.EnableSell = account.EnableSell
-	inst.config.EnableWeed = account.EnableWeed
-	inst.config.EnableBug = account.EnableBug
-	inst.config.EnableWater = account.EnableWater
-	inst.config.EnableRemoveDead = account.EnableRemoveDead
-	inst.config.EnableUpgradeLand = account.EnableUpgradeLand
-	inst.config.EnableHelpFriend = account.EnableHelpFriend
-	inst.config.EnableClaimTask = account.EnableClaimTask
-
-	inst.config.PlantCropID = account.PlantCropID
-	inst.config.PlantingStrategy = account.PlantingStrategy
-	inst.config.SellCropIDs = account.SellCropIDs
-	inst.config.StealCropIDs = account.StealCropIDs
-	inst.config.PreferBagSeeds = account.PreferBagSeeds
-
-	inst.config.EnableAntiDetection = account.EnableAntiDetection
+	inst.profile.Update(func(cfg *BotConfig) {
+		cfg.FarmInterval = account.FarmInterval
+		if cfg.FarmInterval < 1 {
+			cfg.FarmInterval = 10
+		}
+		cfg.FriendInterval = account.FriendInterval
+		cfg.FriendListRefresh = account.FriendListRefreshInterval
+		cfg.WarehouseInterval = account.WarehouseInterval
+		if cfg.FriendInterval < 1 {
+			cfg.FriendInterval = 10
+		}
 
-	inst.config.EnableDebugLog = account.EnableDebugLog
-	if inst.logger != nil {
-		inst.logger.SetDebug(account.EnableDebugLog)
-	}
+		cfg.EnableSteal = account.EnableSteal
+		if cfg.FamilyID != account.FamilyID {
+			cfg.FamilyID = account.FamilyID
+			if inst.running && inst.net != nil {
+				gid, _, _, _, _ := inst.net.state.Get()
+				inst.families.Join(inst.account.UserID, account.FamilyID, inst.account.ID, gid)
+			}
+		}
+		cfg.RPCDeny = account.RPCDeny
+		inst.gate.setReserve(account.GoldReserve)
+		if inst.net != nil {
+			inst.net.SetRPCDeny(account.RPCDeny)
+		}
+		cfg.ForceLowest = account.ForceLowest
+		cfg.ForceLowestMinExp = account.ForceLowestMinExp
+		cfg.ForceLowestMaxPrice = account.ForceLowestMaxPrice
+		cfg.ReplantOnLevelUp = account.ReplantOnLevelUp
+		cfg.ReplantMarginPct = account.ReplantMarginPct
+		cfg.WarmUpLevel = account.WarmUpLevel
+		cfg.MatureNoticeMinutes = account.NotifyBeforeMatureMinutes
+		cfg.AutoUseFertilizer = account.AutoUseFertilizer
+		cfg.AutoBuyFertilizer = account.AutoBuyFertilizer
+		cfg.FertilizerTargetCount = account.FertilizerTargetCount
+		cfg.FertilizerBuyDailyLimit = account.FertilizerBuyDailyLimit
+
+		cfg.EnableHarvest = account.EnableHarvest
+		cfg.EnablePlant = account.EnablePlant
+		cfg.EnableSell = account.EnableSell
+		cfg.EnableWeed = account.EnableWeed
+		cfg.EnableBug = account.EnableBug
+		cfg.EnableWater = account.EnableWater
+		cfg.EnableRemoveDead = account.EnableRemoveDead
+		cfg.EnableUpgradeLand = account.EnableUpgradeLand
+		cfg.EnableHelpFriend = account.EnableHelpFriend
+		cfg.EnableClaimTask = account.EnableClaimTask
+
+		cfg.PlantCropID = account.PlantCropID
+		cfg.PlantingStrategy = account.PlantingStrategy
+		cfg.SellCropIDs = account.SellCropIDs
+		cfg.StealCropIDs = account.StealCropIDs
+		cfg.NoHarvestCropIDs = account.NoHarvestCropIDs
+		cfg.PreferBagSeeds = account.PreferBagSeeds
+
+		cfg.EnableAntiDetection = account.EnableAntiDetection
+		cfg.IntervalJitterPct = account.IntervalJitterPct
+
+		cfg.EnableDebugLog = account.EnableDebugLog
+		if inst.logger != nil {
+			inst.logger.SetDebug(account.EnableDebugLog)
+		}
+	})
 }
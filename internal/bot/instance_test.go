@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+// unreachableServer refuses connections at once, so Start fails fast.
+const unreachableServer = "ws://127.0.0.1:1/ws"
+
+// newTestInstance returns an instance of a fresh account on a temp SQLite
+// store. Its game server is unreachable.
+func newTestInstance(t *testing.T) *Instance {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "farm.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	u := &model.User{Username: "alice", PasswordHash: "x"}
+	if err := s.CreateUser(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	a := &model.Account{UserID: u.ID, Name: "farm", Platform: "qq", Code: "code"}
+	if err := s.CreateAccount(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	inst := NewInstance(a, unreachableServer, "1.0", s, nil, NewEventBus(), nil, nil)
+	inst.logger.SetOutputs(nil, config.ConsoleLogOff)
+	return inst
+}
+
+func TestBotStatsConcurrentWriters(t *testing.T) {
+	inst := newTestInstance(t)
+	const writers, rounds = 4, 500
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	// Status readers, as the dashboard polls
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					inst.Status()
+				}
+			}
+		}()
+	}
+
+	var writersWG sync.WaitGroup
+	now := time.Now()
+	for i := 0; i < writers; i++ {
+		writersWG.Add(1)
+		go func(i int) {
+			defer writersWG.Done()
+			for j := 0; j < rounds; j++ {
+				// The friend worker's counters
+				inst.stats.AddSteal(1)
+				inst.stats.AddHelp(2)
+				inst.stats.SetFriendsCount(j)
+				inst.stats.recordStealPass(3, now.Add(time.Duration(j)*time.Minute))
+				// and the farm worker's
+				inst.stats.recordHarvest(1, 10, map[string]int64{"白萝卜": 5}, now.Add(time.Duration(j)*time.Minute))
+			}
+		}(i)
+	}
+	writersWG.Wait()
+	close(done)
+	wg.Wait()
+
+	c := inst.stats.Snapshot()
+	const n = writers * rounds
+	if c.TotalSteal != n || c.TotalHelp != 2*n || c.StealExp != 3*n {
+		t.Fatalf("steal/help/steal exp = %d/%d/%d, want %d/%d/%d", c.TotalSteal, c.TotalHelp, c.StealExp, n, 2*n, 3*n)
+	}
+	if c.TotalHarvest != n || c.HarvestExp != 10*n || c.HarvestItems["白萝卜"] != 5*n {
+		t.Fatalf("harvest = %d lands, %d exp, %d items", c.TotalHarvest, c.HarvestExp, c.HarvestItems["白萝卜"])
+	}
+
+	// Snapshots are copies
+	c.HarvestItems["白萝卜"] = 0
+	if inst.stats.Snapshot().HarvestItems["白萝卜"] != 5*n {
+		t.Fatal("Snapshot shares its map with the stats")
+	}
+	if s := inst.Status(); s.TotalSteal != n {
+		t.Fatalf("Status TotalSteal = %d, want %d", s.TotalSteal, n)
+	}
+}
+
+// connectFake puts inst in the state a successful connectAndRun leaves it
+// in, minus the workers: a logged-in network and running = true.
+func connectFake(inst *Instance, level, exp int64) {
+	net := NewNetwork(inst.logger, nil, inst.clock)
+	net.state.SetFromLogin(1001, level, exp, 5000, "farmer")
+	inst.mu.Lock()
+	inst.net = net
+	inst.running = true
+	inst.startAt = inst.clock.Now()
+	inst.stopCh = make(chan struct{})
+	inst.mu.Unlock()
+}
+
+func TestStatusWhileStartStopCycles(t *testing.T) {
+	LoadGameConfig(testGameConfigDir, nil)
+	inst := newTestInstance(t)
+
+	// Pollers report every Status they finish; a hang shows up as silence
+	done := make(chan struct{})
+	polled := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s := inst.Status()
+				if s.Running && s.Level > 0 && s.NextLevelExp == 0 && s.ConfigHealth == "" {
+					t.Errorf("running status without a level-up estimate: %+v", s)
+				}
+				select {
+				case polled <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	account := *inst.account
+	for i := 0; i < 20; i++ {
+		if err := inst.Start(); err == nil {
+			t.Fatal("Start against an unreachable server succeeded")
+		}
+		connectFake(inst, 10, 100)
+		account.FarmInterval = 10 + i
+		inst.UpdateConfig(&account)
+		inst.SetTrace(time.Minute)
+		inst.Stop()
+
+		select {
+		case <-polled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Status blocked during start/stop cycles")
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if s := inst.Status(); s.Running {
+		t.Fatal("instance still running after Stop")
+	}
+}
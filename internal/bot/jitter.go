@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// antiDetectionJitterPct is the minimum spread used while anti-detection is
+// enabled, matching its historical ±30%.
+const antiDetectionJitterPct = 30
+
+// jitterPct returns the spread worker loops apply to their sleeps.
+func (c *BotConfig) jitterPct() int {
+	if c.EnableAntiDetection && c.IntervalJitterPct < antiDetectionJitterPct {
+		return antiDetectionJitterPct
+	}
+	return c.IntervalJitterPct
+}
+
+// jitterDuration returns base moved uniformly by up to pct percent either
+// way, so loop passes don't land on exact multiples of the interval.
+func jitterDuration(base time.Duration, pct int) time.Duration {
+	if pct <= 0 || base <= 0 {
+		return base
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	spread := float64(base) * float64(pct) / 100
+	return base + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// sleepJitter waits a jittered base on clock. It returns false as soon as
+// ctx is cancelled, so every worker loop stops the same way.
+func sleepJitter(ctx context.Context, clock Clock, base time.Duration, pct int) bool {
+	select {
+	case <-clock.After(jitterDuration(base, pct)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
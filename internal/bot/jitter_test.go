@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJitterDurationBounds(t *testing.T) {
+	cases := []struct {
+		name     string
+		base     time.Duration
+		pct      int
+		min, max time.Duration
+	}{
+		{"10%", time.Minute, 10, 54 * time.Second, 66 * time.Second},
+		{"50%", 10 * time.Second, 50, 5 * time.Second, 15 * time.Second},
+		{"100%", time.Second, 100, 0, 2 * time.Second},
+		{"above 100% clamps", time.Second, 500, 0, 2 * time.Second},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lo, hi := tc.max, tc.min
+			for i := 0; i < 2000; i++ {
+				d := jitterDuration(tc.base, tc.pct)
+				if d < tc.min || d > tc.max {
+					t.Fatalf("jitterDuration(%v, %d) = %v, outside [%v, %v]", tc.base, tc.pct, d, tc.min, tc.max)
+				}
+				lo, hi = min(lo, d), max(hi, d)
+			}
+			// Both sides of base get used
+			if lo >= tc.base || hi <= tc.base {
+				t.Fatalf("2000 draws spanned only [%v, %v] around %v", lo, hi, tc.base)
+			}
+		})
+	}
+}
+
+func TestJitterDurationPassesThrough(t *testing.T) {
+	cases := []struct {
+		base time.Duration
+		pct  int
+	}{
+		{time.Minute, 0},
+		{time.Minute, -20},
+		{0, 30},
+		{-time.Second, 30},
+	}
+	for _, tc := range cases {
+		if got := jitterDuration(tc.base, tc.pct); got != tc.base {
+			t.Errorf("jitterDuration(%v, %d) = %v, want %v", tc.base, tc.pct, got, tc.base)
+		}
+	}
+}
+
+func TestJitterPctAntiDetectionFloor(t *testing.T) {
+	cases := []struct {
+		anti bool
+		pct  int
+		want int
+	}{
+		{false, 0, 0},
+		{false, 15, 15},
+		{true, 0, antiDetectionJitterPct},
+		{true, 15, antiDetectionJitterPct},
+		{true, 60, 60},
+	}
+	for _, tc := range cases {
+		cfg := &BotConfig{EnableAntiDetection: tc.anti, IntervalJitterPct: tc.pct}
+		if got := cfg.jitterPct(); got != tc.want {
+			t.Errorf("anti-detection %v, %d%%: jitterPct = %d, want %d", tc.anti, tc.pct, got, tc.want)
+		}
+	}
+}
+
+func TestSleepJitterCancelled(t *testing.T) {
+	c := newFakeClock(testEpoch)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool, 1)
+	go func() { done <- sleepJitter(ctx, c, time.Hour, 30) }()
+
+	c.blockUntil(t, 1)
+	c.Advance(time.Minute)
+	cancel()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("sleepJitter = true after cancel, want false")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sleepJitter kept sleeping after cancel")
+	}
+
+	// A context cancelled beforehand doesn't sleep at all
+	if sleepJitter(ctx, c, time.Hour, 30) {
+		t.Fatal("sleepJitter on a cancelled context = true")
+	}
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"qq-farm-bot/internal/model"
+)
+
+// Manual land actions accepted by Instance.LandAction.
+const (
+	LandActionHarvest   = "harvest"
+	LandActionWater     = "water"
+	LandActionWeed      = "weed"
+	LandActionBug       = "bug"
+	LandActionRemove    = "remove"
+	LandActionFertilize = "fertilize"
+)
+
+// LandActions lists every valid manual land action.
+var LandActions = []string{
+	LandActionHarvest, LandActionWater, LandActionWeed,
+	LandActionBug, LandActionRemove, LandActionFertilize,
+}
+
+// ErrUnknownLand is returned when a manual action targets a land the farm
+// doesn't have.
+var ErrUnknownLand = errors.New("unknown land")
+
+// IsLandAction reports whether action is a valid manual land action.
+func IsLandAction(action string) bool {
+	return slices.Contains(LandActions, action)
+}
+
+// landCommand is a manual action queued for the farm loop.
+type landCommand struct {
+	landID int64
+	action string
+	done   chan error // buffered, so the loop never blocks on a gone caller
+}
+
+// do queues a manual action and waits for the farm loop to run it between
+// cycles, so it never interleaves with a batch in flight.
+func (f *FarmWorker) do(ctx context.Context, landID int64, action string) error {
+	cmd := landCommand{landID: landID, action: action, done: make(chan error, 1)}
+	select {
+	case f.cmds <- cmd:
+	case <-f.net.ctx.Done():
+		return ErrNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-cmd.done:
+		return err
+	case <-f.net.ctx.Done():
+		return ErrNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runLandCommand performs one manual action with the same RPC helpers the
+// farm cycle uses, then refreshes the land cache.
+func (f *FarmWorker) runLandCommand(cmd landCommand) error {
+	landsReply, err := f.net.AllLands()
+	if err != nil {
+		return err
+	}
+	landMap := buildLandMap(landsReply.Lands)
+	if _, ok := landMap[cmd.landID]; !ok {
+		return ErrUnknownLand
+	}
+
+	ids := []int64{cmd.landID}
+	desc := f.descLands(ids, landMap)
+	switch cmd.action {
+	case LandActionHarvest:
+		var yield harvestYield
+		if yield, err = f.harvest(ids); err == nil {
+			f.recordHarvest(1, yield)
+			f.observeHarvestExp(ids, landMap, yield)
+			delete(f.fertilized, cmd.landID)
+		}
+	case LandActionWater:
+		if err = f.waterLand(ids); err == nil {
+			f.sc.RecordSimple(model.OpWater, 1)
+		}
+	case LandActionWeed:
+		if err = f.weedOut(ids); err == nil {
+			f.sc.RecordSimple(model.OpWeed, 1)
+			f.creditGrief(ids, landMap, false)
+		}
+	case LandActionBug:
+		if err = f.insecticide(ids); err == nil {
+			f.sc.RecordSimple(model.OpBug, 1)
+			f.creditGrief(ids, landMap, true)
+		}
+	case LandActionRemove:
+		if _, err = f.removePlantAndCollectFreed(ids); err == nil {
+			delete(f.fertilized, cmd.landID)
+		}
+	case LandActionFertilize:
+		if err = f.fertilizeLand(cmd.landID); err == nil {
+			f.sc.RecordSimple(model.OpFertilize, 1)
+			f.fertilized[cmd.landID] = true
+		}
+	default:
+		return errors.New("unknown action: " + cmd.action)
+	}
+	if err != nil {
+		f.logger.Warnf("手动", "%s %s 失败: %v", cmd.action, desc, err)
+		return err
+	}
+	f.logger.Infof("手动", "%s %s 完成", cmd.action, desc)
+
+	if fresh, err := f.net.AllLands(); err == nil {
+		status := f.analyzeLands(fresh.Lands)
+		f.lands.SetSnapshot(fresh, status.classes())
+		f.updateLandCache(fresh.Lands)
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package bot
 
 import (
 	"sync"
+	"time"
 
 	"qq-farm-bot/internal/model"
+	"qq-farm-bot/proto/plantpb"
 )
 
 // LandHarvestInfo holds harvest timing data for level-up estimation.
@@ -21,6 +23,13 @@ type LandHarvestInfo struct {
 	YieldBonusPct int64 // land buff: plant_yield_bonus percentage
 }
 
+// LandEvent is the next thing on the farm that will need the bot.
+type LandEvent struct {
+	AtSec  int64  // unix timestamp, 0 when nothing is pending
+	Type   string // model.LandEvent* constant
+	LandID int64
+}
+
 // LandBuffInfo holds per-land buff data for the fastest-levelup simulation.
 type LandBuffInfo struct {
 	ExpBonusPct   int64
@@ -34,19 +43,88 @@ type LandCache struct {
 	unlockedLands int
 	lands         []model.LandStatus
 	harvestInfos  []LandHarvestInfo
+	nextEvent     LandEvent // earliest upcoming land event as of the last Update
+	nextCheck     time.Time // when the farm loop checks again
+	onUpdate      func()    // called after every Update (outside the lock)
+	clock         Clock
+
+	// Last analyzed AllLandsReply and the analyzer's verdict per land, kept
+	// for the debug endpoint
+	raw        *plantpb.AllLandsReply
+	rawClasses map[int64][]string
+	rawAt      time.Time
+}
+
+// LandsSnapshot is the last raw AllLandsReply with the analyzer's
+// categories for each land.
+type LandsSnapshot struct {
+	Reply   *plantpb.AllLandsReply
+	Classes map[int64][]string
+	At      time.Time
 }
 
-func NewLandCache() *LandCache {
-	return &LandCache{}
+func NewLandCache(clock Clock) *LandCache {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &LandCache{clock: clock}
 }
 
-func (lc *LandCache) Update(totalLands, unlockedLands int, lands []model.LandStatus, harvestInfos []LandHarvestInfo) {
+func (lc *LandCache) Update(totalLands, unlockedLands int, lands []model.LandStatus, harvestInfos []LandHarvestInfo, next LandEvent) {
 	lc.mu.Lock()
-	defer lc.mu.Unlock()
 	lc.totalLands = totalLands
 	lc.unlockedLands = unlockedLands
 	lc.lands = lands
 	lc.harvestInfos = harvestInfos
+	lc.nextEvent = next
+	onUpdate := lc.onUpdate
+	lc.mu.Unlock()
+
+	if onUpdate != nil {
+		onUpdate()
+	}
+}
+
+// SetOnUpdate registers a callback invoked after each Update.
+func (lc *LandCache) SetOnUpdate(fn func()) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.onUpdate = fn
+}
+
+// SetSnapshot records the reply the farm analyzer just classified.
+func (lc *LandCache) SetSnapshot(reply *plantpb.AllLandsReply, classes map[int64][]string) {
+	if lc == nil {
+		return
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.raw, lc.rawClasses, lc.rawAt = reply, classes, lc.clock.Now()
+}
+
+// Snapshot returns the last recorded snapshot; Reply is nil before the first
+// farm check.
+func (lc *LandCache) Snapshot() LandsSnapshot {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return LandsSnapshot{Reply: lc.raw, Classes: lc.rawClasses, At: lc.rawAt}
+}
+
+// SetNextCheck records when the farm loop runs next.
+func (lc *LandCache) SetNextCheck(at time.Time) {
+	if lc == nil {
+		return
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.nextCheck = at
+}
+
+// Schedule returns the earliest upcoming land event and the next farm check.
+func (lc *LandCache) Schedule() (LandEvent, time.Time) {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.nextEvent, lc.nextCheck
 }
 
 func (lc *LandCache) Get() (totalLands, unlockedLands int, lands []model.LandStatus) {
@@ -0,0 +1,417 @@
+package bot
+
+import (
+	"sort"
+
+	"qq-farm-bot/internal/model"
+)
+
+// effectiveGrowSec computes growth time after applying land time-reduction buff
+// and subtracting fertilizer skip time (longest-phase optimization).
+func effectiveGrowSec(baseSec, fertReduceSec int, timeReducePct int64) int64 {
+	base := int64(baseSec)
+	if timeReducePct > 0 {
+		base = base * (10000 - timeReducePct) / 10000
+	}
+	fert := int64(fertReduceSec)
+	if timeReducePct > 0 {
+		fert = fert * (10000 - timeReducePct) / 10000
+	}
+	eff := base - fert
+	if eff < 1 {
+		eff = 1
+	}
+	return eff
+}
+
+// plantPlan is the planting configuration the level-up estimate needs,
+// copied out of BotConfig so it can be used without holding inst.mu.
+type plantPlan struct {
+	cropID      int
+	strategy    string
+	forceLowest bool
+	minExp      int // ForceLowest floors
+	maxPrice    int
+}
+
+// resolveStrategySeed determines which seed the bot would plant at level
+// based on the planting configuration (explicit crop ID, strategy rules, or defaults).
+// Uses only static GameConfig data — no network calls required.
+// Returns nil if no suitable seed can be determined; belowFloor is true when
+// ForceLowest found no seed passing its floors.
+func resolveStrategySeed(gc *GameConfig, plan plantPlan, level int64) (seed *SeedYieldRow, belowFloor bool) {
+	if gc == nil {
+		return nil, false
+	}
+
+	yieldRows := gc.GetSeedYieldRows()
+	if len(yieldRows) == 0 {
+		return nil, false
+	}
+
+	var available []SeedYieldRow
+	for _, yr := range yieldRows {
+		if yr.RequiredLevel <= int(level) && yr.GrowTimeSec > 0 {
+			available = append(available, yr)
+		}
+	}
+	if len(available) == 0 {
+		return nil, false
+	}
+
+	// 1. Explicit crop ID override
+	if plan.cropID > 0 {
+		targetSeedID := gc.GetSeedIDForCrop(plan.cropID)
+		if targetSeedID > 0 {
+			for i, yr := range available {
+				if yr.SeedID == targetSeedID {
+					return &available[i], false
+				}
+			}
+		}
+	}
+
+	// 2. Strategy-based selection
+	strategy := ParsePlantingStrategy(plan.strategy)
+	if strategy != nil {
+		if strategy.Mode == StrategyModeFastestLevelUp {
+			// fastest_levelup does per-round optimization; approximate with best exp efficiency
+			best := &available[0]
+			for i := 1; i < len(available); i++ {
+				if available[i].FarmExpPerHourNormal > best.FarmExpPerHourNormal {
+					best = &available[i]
+				}
+			}
+			return best, false
+		}
+
+		var candidates []SeedCandidate
+		for _, yr := range available {
+			sc := SeedCandidate{
+				SeedID:             yr.SeedID,
+				Name:               yr.Name,
+				RequiredLevel:      yr.RequiredLevel,
+				Price:              yr.Price,
+				ExpPerHarvest:      yr.ExpHarvest,
+				Seasons:            yr.Seasons,
+				GrowTimeSec:        yr.GrowTimeSec,
+				ExpEfficiency:      yr.FarmExpPerHourNormal,
+				GrowTimeNormalFert: yr.GrowTimeNormalFert,
+			}
+			if yr.Price > 0 {
+				sc.GoldEfficiency = float64(yr.ExpHarvest*yr.Seasons) / float64(yr.Price)
+			}
+			candidates = append(candidates, sc)
+		}
+
+		result := ApplyStrategy(strategy, candidates)
+		if len(result) > 0 {
+			for i, yr := range available {
+				if yr.SeedID == result[0].SeedID {
+					return &available[i], false
+				}
+			}
+		}
+	}
+
+	// 3. ForceLowest: cheapest seed passing the floors. When none passes the
+	// farm worker still plants the plain lowest seed but skips the level-up
+	// replant, so the forecast keeps that seed and reports the fallback
+	if plan.forceLowest {
+		cands := make([]SeedCandidate, len(available))
+		for i, yr := range available {
+			cands[i] = SeedCandidate{RequiredLevel: yr.RequiredLevel, Price: yr.Price, ExpPerHarvest: yr.ExpHarvest}
+		}
+		i, ok := pickLowestSeed(cands, plan.minExp, plan.maxPrice)
+		return &available[i], !ok
+	}
+
+	// 4. Default: best exp efficiency (matches findBestSeed fallback)
+	best := &available[0]
+	for i := 1; i < len(available); i++ {
+		if available[i].FarmExpPerHourNormal > best.FarmExpPerHourNormal {
+			best = &available[i]
+		}
+	}
+	return best, false
+}
+
+// nextLevelExpAbove returns the first level threshold above exp. Exp events can
+// arrive before the level-up notify, so a stale level may already be passed;
+// look a few levels ahead instead of reporting a zero-hour estimate.
+func nextLevelExpAbove(gc *GameConfig, level, exp int64) (int64, bool) {
+	for l := level; l < level+5; l++ {
+		nextExp, ok := gc.GetNextLevelExp(int(l))
+		if !ok {
+			return 0, false
+		}
+		if nextExp > exp {
+			return nextExp, true
+		}
+	}
+	return 0, false
+}
+
+// stealRateWeight discounts the measured steal exp rate in the forecast:
+// steals depend on friends' crops and on the daily steal limit, so past
+// rates are a weaker predictor than our own planting.
+const stealRateWeight = 0.5
+
+// levelUpInputs is everything estimateLevelUp needs, gathered by the caller
+// so the simulation itself is a pure function.
+type levelUpInputs struct {
+	NowSec         int64
+	ExpToNextLevel int64
+	Harvests       []LandHarvestInfo
+	Lands          []model.LandStatus
+	GC             *GameConfig   // static crop data; nil skips multi-season lookups
+	Seed           *SeedYieldRow // replanted after current crops; nil models current crops only
+
+	PendingTaskExp  int64   // claimable, unclaimed task exp, counted as received now
+	StealExpPerHour float64 // measured friend-steal exp rate
+}
+
+// levelUpEstimate is the result of estimateLevelUp. ExpRatePerHour is the
+// steady-state total; the other rates break it down by source.
+type levelUpEstimate struct {
+	ExpRatePerHour   float64
+	HoursToNextLevel float64
+	CropExpPerHour   float64
+	StealExpPerHour  float64 // after stealRateWeight
+	TaskExp          int64
+}
+
+// estimateLevelUp calculates expected exp rate and hours to next level using a
+// time-series simulation. It builds discrete harvest events from currently
+// growing crops, then simulates future planting cycles with in.Seed to produce
+// an accurate level-up timeline. Pending task exp counts immediately and
+// steal exp accrues continuously on top of the harvests.
+func estimateLevelUp(in levelUpInputs) (est levelUpEstimate) {
+	if in.ExpToNextLevel <= 0 {
+		return est
+	}
+
+	harvestInfos := in.Harvests
+	gc := in.GC
+	nowSec := in.NowSec
+	if in.PendingTaskExp > 0 {
+		est.TaskExp = in.PendingTaskExp
+	}
+	if in.StealExpPerHour > 0 {
+		est.StealExpPerHour = in.StealExpPerHour * stealRateWeight
+	}
+	// Exp our own crops still have to provide
+	cropTarget := in.ExpToNextLevel - est.TaskExp
+	if cropTarget <= 0 {
+		est.ExpRatePerHour = est.StealExpPerHour
+		return est
+	}
+
+	// --- Phase 1: Build events from current crops + track per-land free times ---
+
+	type harvestEvent struct {
+		timeSec int64
+		exp     int64
+	}
+	type landState struct {
+		landID        int64
+		freeTimeSec   int64 // when this land becomes available for replanting
+		expBonusPct   int64
+		timeReducePct int64
+	}
+
+	var events []harvestEvent
+	var landStates []landState
+	var totalExpPerMin float64
+
+	for _, h := range harvestInfos {
+		adjustedExp := float64(h.CropExp) * (10000 + float64(h.ExpBonusPct)) / 10000.0
+		if adjustedExp <= 0 {
+			continue
+		}
+
+		seasons := 1
+		var season2GrowSec int64
+		if gc != nil && h.CropID > 0 {
+			seasons = gc.GetPlantSeasons(int(h.CropID))
+			if seasons >= 2 {
+				if pd := gc.GetPlantPhaseData(int(h.CropID)); pd != nil && pd.Season2GrowTime > 0 {
+					season2GrowSec = effectiveGrowSec(pd.Season2GrowTime, pd.Season2MaxPhase, h.TimeReducePct)
+				}
+			}
+		}
+
+		if h.CycleTimeSec > 0 {
+			totalCycleExp := adjustedExp * float64(seasons)
+			totalCycleSec := float64(h.CycleTimeSec)
+			if seasons >= 2 && season2GrowSec > 0 {
+				totalCycleSec += float64(season2GrowSec)
+			}
+			totalExpPerMin += totalCycleExp / (totalCycleSec / 60.0)
+		}
+
+		currentSeason := h.Season
+		if currentSeason < 1 {
+			currentSeason = 1
+		}
+
+		var lastHarvestTime int64
+
+		if h.IsMature {
+			events = append(events, harvestEvent{timeSec: nowSec, exp: int64(adjustedExp)})
+			lastHarvestTime = nowSec
+			if currentSeason <= 1 && seasons >= 2 && season2GrowSec > 0 {
+				s2Time := nowSec + season2GrowSec
+				events = append(events, harvestEvent{timeSec: s2Time, exp: int64(adjustedExp)})
+				lastHarvestTime = s2Time
+			}
+		} else if h.IsGrowing && h.MatureTimeSec > nowSec {
+			events = append(events, harvestEvent{timeSec: h.MatureTimeSec, exp: int64(adjustedExp)})
+			lastHarvestTime = h.MatureTimeSec
+			if currentSeason <= 1 && seasons >= 2 && season2GrowSec > 0 {
+				s2Time := h.MatureTimeSec + season2GrowSec
+				events = append(events, harvestEvent{timeSec: s2Time, exp: int64(adjustedExp)})
+				lastHarvestTime = s2Time
+			}
+		}
+
+		if lastHarvestTime > 0 {
+			landStates = append(landStates, landState{
+				landID:        h.LandID,
+				freeTimeSec:   lastHarvestTime,
+				expBonusPct:   h.ExpBonusPct,
+				timeReducePct: h.TimeReducePct,
+			})
+		}
+	}
+
+	// --- Phase 2: Include empty/idle lands in the simulation ---
+	trackedLands := make(map[int64]bool, len(landStates))
+	for _, ls := range landStates {
+		trackedLands[ls.landID] = true
+	}
+	for _, ls := range in.Lands {
+		if !ls.Unlocked || trackedLands[ls.ID] || ls.CropID > 0 {
+			continue
+		}
+		// Skip slave lands occupied by a master's crop
+		if ls.MasterLandID > 0 && ls.MasterLandID != ls.ID && trackedLands[ls.MasterLandID] {
+			continue
+		}
+		landStates = append(landStates, landState{
+			landID:        ls.ID,
+			freeTimeSec:   nowSec,
+			expBonusPct:   ls.ExpBonusPct,
+			timeReducePct: ls.TimeReducePct,
+		})
+	}
+
+	est.CropExpPerHour = totalExpPerMin * 60
+
+	// --- Phase 3: Simulate future planting cycles using the strategy seed ---
+	strategySeed := in.Seed
+	if strategySeed != nil && len(landStates) > 0 {
+		s1GrowTimeSec := strategySeed.GrowTimeSec
+		s1FertReduceSec := strategySeed.NormalFertReduceSec
+		s2GrowTimeSec := strategySeed.Season2GrowTimeSec
+		s2FertReduceSec := strategySeed.Season2FertReduceSec
+		seedSeasons := strategySeed.Seasons
+		seedExp := strategySeed.ExpHarvest
+
+		maxSimTime := nowSec + 30*24*3600 // cap simulation at 30 days
+		maxCycles := 50
+
+		// Track cumulative exp for early termination
+		var totalSimExp int64
+		for _, e := range events {
+			totalSimExp += e.exp
+		}
+
+		for cycle := 0; cycle < maxCycles && totalSimExp < cropTarget; cycle++ {
+			for i := range landStates {
+				ls := &landStates[i]
+				if ls.freeTimeSec >= maxSimTime {
+					continue
+				}
+
+				s1Eff := effectiveGrowSec(s1GrowTimeSec, s1FertReduceSec, ls.timeReducePct)
+				adjExp := int64(seedExp) * (10000 + ls.expBonusPct) / 10000
+				if adjExp <= 0 {
+					adjExp = int64(seedExp)
+				}
+
+				harvest1Time := ls.freeTimeSec + s1Eff
+				events = append(events, harvestEvent{timeSec: harvest1Time, exp: adjExp})
+				totalSimExp += adjExp
+
+				lastHarvest := harvest1Time
+				if seedSeasons >= 2 && s2GrowTimeSec > 0 {
+					s2Eff := effectiveGrowSec(s2GrowTimeSec, s2FertReduceSec, ls.timeReducePct)
+					harvest2Time := harvest1Time + s2Eff
+					events = append(events, harvestEvent{timeSec: harvest2Time, exp: adjExp})
+					totalSimExp += adjExp
+					lastHarvest = harvest2Time
+				}
+
+				ls.freeTimeSec = lastHarvest
+			}
+		}
+
+		var stratExpPerMin float64
+		for _, ls := range landStates {
+			adjExp := float64(seedExp) * (10000 + float64(ls.expBonusPct)) / 10000.0
+			s1Eff := effectiveGrowSec(s1GrowTimeSec, s1FertReduceSec, ls.timeReducePct)
+			totalExp := adjExp * float64(seedSeasons)
+			totalTime := float64(s1Eff)
+			if seedSeasons >= 2 && s2GrowTimeSec > 0 {
+				s2Eff := effectiveGrowSec(s2GrowTimeSec, s2FertReduceSec, ls.timeReducePct)
+				totalTime += float64(s2Eff)
+			}
+			if totalTime > 0 {
+				stratExpPerMin += totalExp / (totalTime / 60.0)
+			}
+		}
+		if stratExpPerMin > 0 {
+			est.CropExpPerHour = stratExpPerMin * 60
+		}
+	}
+	est.ExpRatePerHour = est.CropExpPerHour + est.StealExpPerHour
+
+	// --- Phase 4: Walk sorted events to find the level-up moment ---
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].timeSec < events[j].timeSec
+	})
+
+	stealPerSec := est.StealExpPerHour / 3600.0
+	remaining := float64(cropTarget)
+	lastEventTime := nowSec
+	for _, e := range events {
+		t := max(e.timeSec, nowSec)
+		// Steal exp accrued since the previous event may level up first
+		if stealPerSec > 0 {
+			accrued := float64(t-lastEventTime) * stealPerSec
+			if remaining <= accrued {
+				est.HoursToNextLevel = (float64(lastEventTime-nowSec) + remaining/stealPerSec) / 3600.0
+				return est
+			}
+			remaining -= accrued
+		}
+		remaining -= float64(e.exp)
+		if remaining <= 0 {
+			est.HoursToNextLevel = float64(t-nowSec) / 3600.0
+			return est
+		}
+		lastEventTime = t
+	}
+
+	// --- Phase 5: Fallback — use steady-state rate for any remaining exp ---
+	if est.ExpRatePerHour > 0 {
+		additionalSecs := remaining / (est.ExpRatePerHour / 3600.0)
+		totalSecs := float64(lastEventTime-nowSec) + additionalSecs
+		if totalSecs < 0 {
+			totalSecs = 0
+		}
+		est.HoursToNextLevel = totalSecs / 3600.0
+	}
+	return est
+}
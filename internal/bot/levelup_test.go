@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"qq-farm-bot/internal/model"
+)
+
+const testNow = int64(1_800_000_000)
+
+// twoSeasonGameConfig holds one two-season plant (id 1) whose second season
+// takes 3000s, 1200s with fertilizer.
+func twoSeasonGameConfig(t *testing.T) *GameConfig {
+	t.Helper()
+	dir := t.TempDir()
+	plants := `[{"id": 1, "name": "twice", "seed_id": 101, "seasons": 2, "exp": 100,
+		"grow_phases": "种子:600;发芽:600;开花:1200;结果:1800;成熟:0"}]`
+	if err := os.WriteFile(filepath.Join(dir, "Plant.json"), []byte(plants), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gc := newGameConfig()
+	gc.load(dir)
+	if gc.GetPlantSeasons(1) != 2 {
+		t.Fatal("two-season plant not loaded")
+	}
+	return gc
+}
+
+func TestEstimateLevelUp(t *testing.T) {
+	growing := func(matureIn, cycle int64, exp int, bonusPct int64) LandHarvestInfo {
+		return LandHarvestInfo{LandID: 1, IsGrowing: true, MatureTimeSec: testNow + matureIn,
+			CycleTimeSec: cycle, CropExp: exp, ExpBonusPct: bonusPct}
+	}
+	emptyLand := []model.LandStatus{{ID: 1, Unlocked: true}}
+	seed := &SeedYieldRow{ExpHarvest: 10, Seasons: 1, GrowTimeSec: 3600}
+
+	cases := []struct {
+		name  string
+		in    levelUpInputs
+		hours float64
+		rate  float64 // ExpRatePerHour
+		crop  float64 // CropExpPerHour
+		steal float64 // StealExpPerHour
+		task  int64
+	}{
+		{name: "no exp needed", in: levelUpInputs{NowSec: testNow, PendingTaskExp: 50, StealExpPerHour: 40}},
+		{name: "task exp covers the gap",
+			in:   levelUpInputs{NowSec: testNow, ExpToNextLevel: 100, PendingTaskExp: 150, StealExpPerHour: 40},
+			rate: 20, steal: 20, task: 150},
+		{name: "steal only, at half weight",
+			in:    levelUpInputs{NowSec: testNow, ExpToNextLevel: 100, StealExpPerHour: 100},
+			hours: 2, rate: 50, steal: 50},
+		{name: "mature crop harvests now",
+			in: levelUpInputs{NowSec: testNow, ExpToNextLevel: 50,
+				Harvests: []LandHarvestInfo{{LandID: 1, IsMature: true, CropExp: 100, CycleTimeSec: 3600}}},
+			rate: 100, crop: 100},
+		{name: "growing crop harvests when mature",
+			in:    levelUpInputs{NowSec: testNow, ExpToNextLevel: 100, Harvests: []LandHarvestInfo{growing(3600, 7200, 100, 0)}},
+			hours: 1, rate: 50, crop: 50},
+		{name: "task exp shrinks what crops must cover",
+			in: levelUpInputs{NowSec: testNow, ExpToNextLevel: 250, PendingTaskExp: 150,
+				Harvests: []LandHarvestInfo{growing(3600, 7200, 100, 0)}},
+			hours: 1, rate: 50, crop: 50, task: 150},
+		{name: "exp bonus raises the harvest",
+			in:    levelUpInputs{NowSec: testNow, ExpToNextLevel: 150, Harvests: []LandHarvestInfo{growing(3600, 3600, 100, 5000)}},
+			hours: 1, rate: 150, crop: 150},
+		{name: "steal exp levels up before the harvest",
+			in: levelUpInputs{NowSec: testNow, ExpToNextLevel: 30, StealExpPerHour: 40,
+				Harvests: []LandHarvestInfo{growing(7200, 7200, 80, 0)}},
+			hours: 1.5, rate: 60, crop: 40, steal: 20},
+		{name: "steal exp accrued until the harvest",
+			in: levelUpInputs{NowSec: testNow, ExpToNextLevel: 100, StealExpPerHour: 40,
+				Harvests: []LandHarvestInfo{growing(7200, 7200, 80, 0)}},
+			hours: 2, rate: 60, crop: 40, steal: 20},
+		{name: "last harvest falls short, rest at the steady rate",
+			in:    levelUpInputs{NowSec: testNow, ExpToNextLevel: 200, Harvests: []LandHarvestInfo{growing(3600, 3600, 100, 0)}},
+			hours: 2, rate: 100, crop: 100},
+		{name: "idle land replanted with the seed",
+			in:    levelUpInputs{NowSec: testNow, ExpToNextLevel: 25, Lands: emptyLand, Seed: seed},
+			hours: 3, rate: 10, crop: 10},
+		{name: "locked land not replanted",
+			in: levelUpInputs{NowSec: testNow, ExpToNextLevel: 25, StealExpPerHour: 20,
+				Lands: []model.LandStatus{{ID: 1}}, Seed: seed},
+			hours: 2.5, rate: 10, steal: 10},
+		{name: "land frees up for the seed after its crop",
+			in: levelUpInputs{NowSec: testNow, ExpToNextLevel: 110, Seed: seed,
+				Harvests: []LandHarvestInfo{growing(1800, 3600, 100, 0)}},
+			hours: 1.5, rate: 10, crop: 10},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := estimateLevelUp(tc.in)
+			want := levelUpEstimate{ExpRatePerHour: tc.rate, HoursToNextLevel: tc.hours,
+				CropExpPerHour: tc.crop, StealExpPerHour: tc.steal, TaskExp: tc.task}
+			if !approxEstimate(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestEstimateLevelUpSecondSeason(t *testing.T) {
+	gc := twoSeasonGameConfig(t)
+	mature := func(season int64) levelUpInputs {
+		return levelUpInputs{NowSec: testNow, ExpToNextLevel: 200, GC: gc, Harvests: []LandHarvestInfo{
+			{LandID: 1, CropID: 1, Season: season, IsMature: true, CropExp: 100, CycleTimeSec: 4800}}}
+	}
+	// Both seasons yield 100 exp over 4800s + 1200s
+	const rate = 200.0 / (6000.0 / 3600.0)
+
+	cases := []struct {
+		name  string
+		in    levelUpInputs
+		hours float64
+	}{
+		{"first season regrows", mature(1), 1200.0 / 3600.0},
+		{"second season is the last", mature(2), 100 / rate},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := estimateLevelUp(tc.in)
+			want := levelUpEstimate{ExpRatePerHour: rate, CropExpPerHour: rate, HoursToNextLevel: tc.hours}
+			if !approxEstimate(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// Without static data the crop counts as single-season
+	in := mature(1)
+	in.GC = nil
+	if got := estimateLevelUp(in); approx(got.HoursToNextLevel, 1200.0/3600.0) {
+		t.Errorf("second season assumed without game config: %+v", got)
+	}
+}
+
+func approxEstimate(a, b levelUpEstimate) bool {
+	return approx(a.ExpRatePerHour, b.ExpRatePerHour) && approx(a.HoursToNextLevel, b.HoursToNextLevel) &&
+		approx(a.CropExpPerHour, b.CropExpPerHour) && approx(a.StealExpPerHour, b.StealExpPerHour) &&
+		a.TaskExp == b.TaskExp
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
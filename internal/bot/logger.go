@@ -1,29 +1,66 @@
 package bot
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
+	"qq-farm-bot/internal/config"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
+// SystemAccountID is the log channel for events not tied to an account.
+const SystemAccountID int64 = 0
+
+// logWriteTimeout bounds a single log insert so a locked database drops the
+// entry instead of stalling the bot goroutine that logged it.
+const logWriteTimeout = 2 * time.Second
+
 // Logger provides structured logging for a bot instance.
-// Logs are stored in SQLite and broadcast to WebSocket subscribers.
+// Logs are stored in SQLite and published to the LogHub for WebSocket subscribers.
+// The store policy only limits what is persisted; subscribers get every entry.
 type Logger struct {
-	accountID   int64
-	store       *store.Store
-	subscribers map[chan *model.LogEntry]struct{}
-	mu          sync.RWMutex
-	enableDebug bool
+	accountID    int64
+	store        store.Store
+	hub          *LogHub
+	mu           sync.RWMutex
+	enableDebug  bool
+	storeMinRank int             // entries below this level are not stored
+	tagBlacklist map[string]bool // tags whose debug/info entries are not stored
+	sink         *FileSink       // optional shared JSON-lines file output
+	consoleRank  int             // entries below this level are not printed
+	correlation  string          // request id attached to entries while an API action runs
 }
 
-func NewLogger(accountID int64, s *store.Store) *Logger {
+func NewLogger(accountID int64, s store.Store, hub *LogHub) *Logger {
 	return &Logger{
-		accountID:   accountID,
-		store:       s,
-		subscribers: make(map[chan *model.LogEntry]struct{}),
+		accountID: accountID,
+		store:     s,
+		hub:       hub,
+	}
+}
+
+// SetOutputs configures the shared file sink (nil disables) and which
+// entries are also printed to stdout (a config.ConsoleLog* level).
+func (l *Logger) SetOutputs(sink *FileSink, console string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sink = sink
+	l.consoleRank = consoleRank(console)
+}
+
+// consoleRank maps a console level to the lowest log level rank printed.
+func consoleRank(console string) int {
+	switch console {
+	case config.ConsoleLogWarn:
+		return model.LogLevelRank("warn")
+	case config.ConsoleLogOff:
+		return math.MaxInt
+	default:
+		return 0
 	}
 }
 
@@ -57,6 +94,44 @@ func (l *Logger) Debugf(tag, format string, args ...interface{}) {
 	l.emit("debug", tag, fmt.Sprintf(format, args...))
 }
 
+// Tracef emits a debug entry even when debug logging is off, for traces the
+// user switched on explicitly such as RPC tracing.
+func (l *Logger) Tracef(tag, format string, args ...interface{}) {
+	l.emit("debug", tag, fmt.Sprintf(format, args...))
+}
+
+// SetStorePolicy sets the minimum level persisted to the database and the tags
+// whose debug/info entries are skipped. Warnings and errors are always stored.
+func (l *Logger) SetStorePolicy(level string, tagBlacklist []string) {
+	blacklist := make(map[string]bool, len(tagBlacklist))
+	for _, tag := range tagBlacklist {
+		blacklist[tag] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.storeMinRank = min(model.LogLevelRank(level), model.LogLevelRank("warn"))
+	l.tagBlacklist = blacklist
+}
+
+// shouldStore applies the store policy to an entry.
+func (l *Logger) shouldStore(level, tag string) bool {
+	rank := model.LogLevelRank(level)
+	if rank >= model.LogLevelRank("warn") {
+		return true
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return rank >= l.storeMinRank && !l.tagBlacklist[tag]
+}
+
+// SetCorrelationID tags the following entries with the request id of the API
+// action in progress; "" stops tagging.
+func (l *Logger) SetCorrelationID(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.correlation = id
+}
+
 // SetDebug enables or disables debug-level logging.
 func (l *Logger) SetDebug(enabled bool) {
 	l.mu.Lock()
@@ -65,45 +140,37 @@ func (l *Logger) SetDebug(enabled bool) {
 }
 
 func (l *Logger) emit(level, tag, msg string) {
+	l.mu.RLock()
+	correlation := l.correlation
+	l.mu.RUnlock()
 	entry := &model.LogEntry{
-		AccountID: l.accountID,
-		Tag:       tag,
-		Message:   msg,
-		Level:     level,
-		CreatedAt: time.Now(),
+		AccountID:     l.accountID,
+		Tag:           tag,
+		Message:       msg,
+		Level:         level,
+		CreatedAt:     time.Now(),
+		CorrelationID: correlation,
 	}
 
 	// Store in database (fire-and-forget)
-	if l.store != nil {
-		_ = l.store.AddLog(entry)
+	if l.store != nil && l.shouldStore(level, tag) {
+		ctx, cancel := context.WithTimeout(context.Background(), logWriteTimeout)
+		_ = l.store.AddLog(ctx, entry)
+		cancel()
 	}
 
 	// Broadcast to subscribers
+	l.hub.Publish(entry)
+
 	l.mu.RLock()
-	for ch := range l.subscribers {
-		select {
-		case ch <- entry:
-		default: // drop if channel full
+	sink, toConsole := l.sink, model.LogLevelRank(level) >= l.consoleRank
+	l.mu.RUnlock()
+	sink.Write(entry)
+	if toConsole {
+		source := fmt.Sprintf("账号#%d", l.accountID)
+		if l.accountID == SystemAccountID {
+			source = "系统"
 		}
+		fmt.Printf("[%s] [%s] [%s] %s\n", time.Now().Format("15:04:05"), source, tag, msg)
 	}
-	l.mu.RUnlock()
-
-	// Also print to stdout
-	fmt.Printf("[%s] [账号#%d] [%s] %s\n", time.Now().Format("15:04:05"), l.accountID, tag, msg)
-}
-
-// Subscribe returns a channel that receives log entries. Call Unsubscribe to stop.
-func (l *Logger) Subscribe() chan *model.LogEntry {
-	ch := make(chan *model.LogEntry, 100)
-	l.mu.Lock()
-	l.subscribers[ch] = struct{}{}
-	l.mu.Unlock()
-	return ch
-}
-
-func (l *Logger) Unsubscribe(ch chan *model.LogEntry) {
-	l.mu.Lock()
-	delete(l.subscribers, ch)
-	l.mu.Unlock()
-	close(ch)
 }
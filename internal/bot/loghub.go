@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// defaultLogSubscriberBuffer is used when no buffer size is configured.
+const defaultLogSubscriberBuffer = 500
+
+// logSub tracks entries dropped for one subscriber since the last notice.
+type logSub struct {
+	dropped atomic.Int64
+}
+
+// LogHub fans out log entries to subscribers keyed by account ID.
+// It is owned by the Manager so subscriptions survive instance restarts:
+// a new Instance for the same account publishes into the same hub.
+type LogHub struct {
+	mu           sync.RWMutex
+	subs         map[int64]map[chan *model.LogEntry]*logSub
+	bufferSize   int
+	totalDropped atomic.Int64
+}
+
+// NewLogHub creates a hub whose subscriber channels buffer bufferSize entries.
+func NewLogHub(bufferSize int) *LogHub {
+	if bufferSize <= 0 {
+		bufferSize = defaultLogSubscriberBuffer
+	}
+	return &LogHub{
+		subs:       make(map[int64]map[chan *model.LogEntry]*logSub),
+		bufferSize: bufferSize,
+	}
+}
+
+// Publish delivers the entry to all subscribers of its account without
+// blocking. When a subscriber's buffer is full the entry is counted as
+// dropped; once the buffer has room again the subscriber first receives a
+// synthetic "已丢弃 N 条日志" notice so gaps in the live view are visible.
+func (h *LogHub) Publish(entry *model.LogEntry) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch, sub := range h.subs[entry.AccountID] {
+		if n := sub.dropped.Load(); n > 0 {
+			notice := &model.LogEntry{
+				AccountID: entry.AccountID,
+				Tag:       "日志",
+				Message:   fmt.Sprintf("已丢弃 %d 条日志", n),
+				Level:     "warn",
+				CreatedAt: time.Now(),
+			}
+			select {
+			case ch <- notice:
+				sub.dropped.Add(-n)
+			default:
+				h.drop(sub)
+				continue
+			}
+		}
+		select {
+		case ch <- entry:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+func (h *LogHub) drop(sub *logSub) {
+	sub.dropped.Add(1)
+	h.totalDropped.Add(1)
+}
+
+// Subscribe returns a channel receiving log entries of the account.
+// Call Unsubscribe to stop.
+func (h *LogHub) Subscribe(accountID int64) chan *model.LogEntry {
+	ch := make(chan *model.LogEntry, h.bufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[accountID] == nil {
+		h.subs[accountID] = make(map[chan *model.LogEntry]*logSub)
+	}
+	h.subs[accountID][ch] = &logSub{}
+	return ch
+}
+
+// Unsubscribe removes and closes the channel. The close happens under the
+// write lock, so a concurrent Publish can never send on a closed channel,
+// and repeated calls are no-ops.
+func (h *LogHub) Unsubscribe(accountID int64, ch chan *model.LogEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs := h.subs[accountID]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	if len(subs) == 0 {
+		delete(h.subs, accountID)
+	}
+	close(ch)
+}
+
+// Dropped returns the total number of entries dropped for slow subscribers.
+func (h *LogHub) Dropped() int64 {
+	return h.totalDropped.Load()
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *LogHub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n := 0
+	for _, subs := range h.subs {
+		n += len(subs)
+	}
+	return n
+}
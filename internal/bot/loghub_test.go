@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+func TestLogHubStalledSubscriber(t *testing.T) {
+	hub := NewLogHub(4)
+	stalled := hub.Subscribe(1)
+	live := hub.Subscribe(1)
+
+	// The stalled subscriber never reads; publishing must not wait for it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 10; i++ {
+			hub.Publish(&model.LogEntry{AccountID: 1, Message: fmt.Sprintf("entry %d", i)})
+			if got := <-live; got.Message != fmt.Sprintf("entry %d", i) {
+				t.Errorf("live subscriber got %q, want entry %d", got.Message, i)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a stalled subscriber")
+	}
+	if hub.Dropped() != 6 {
+		t.Fatalf("Dropped() = %d, want 6", hub.Dropped())
+	}
+
+	// Once it drains, the next entry is preceded by a notice of the gap
+	for i := 1; i <= 4; i++ {
+		if got := <-stalled; got.Message != fmt.Sprintf("entry %d", i) {
+			t.Fatalf("stalled subscriber got %q, want entry %d", got.Message, i)
+		}
+	}
+	hub.Publish(&model.LogEntry{AccountID: 1, Message: "entry 11"})
+	if got := <-stalled; got.Message != "已丢弃 6 条日志" || got.Level != "warn" {
+		t.Fatalf("got %q (%s), want the drop notice", got.Message, got.Level)
+	}
+	if got := <-stalled; got.Message != "entry 11" {
+		t.Fatalf("got %q after the notice, want entry 11", got.Message)
+	}
+	if got := <-live; got.Message != "entry 11" || len(live) != 0 {
+		t.Fatalf("live subscriber got %q and %d more; it never fell behind", got.Message, len(live))
+	}
+
+	// The count restarts after the notice
+	hub.Publish(&model.LogEntry{AccountID: 1, Message: "entry 12"})
+	if got := <-stalled; got.Message != "entry 12" {
+		t.Fatalf("got %q, want entry 12 without another notice", got.Message)
+	}
+}
+
+func TestLogHubUnsubscribe(t *testing.T) {
+	hub := NewLogHub(1)
+	ch := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	if hub.Subscribers() != 2 {
+		t.Fatalf("Subscribers() = %d", hub.Subscribers())
+	}
+	hub.Unsubscribe(1, ch)
+	hub.Unsubscribe(1, ch)
+	if _, ok := <-ch; ok {
+		t.Fatal("channel not closed")
+	}
+	hub.Publish(&model.LogEntry{AccountID: 1, Message: "after"})
+	if hub.Subscribers() != 1 || len(other) != 0 {
+		t.Fatalf("Subscribers() = %d, other account got %d entries", hub.Subscribers(), len(other))
+	}
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+const (
+	logSinkBuffer   = 4096 // queued entries before emit starts dropping
+	logSinkMaxBatch = 256  // entries written per flush
+)
+
+// fileLogLine is the JSON line format written by FileSink.
+type fileLogLine struct {
+	TS        string `json:"ts"`
+	AccountID int64  `json:"account_id"`
+	Level     string `json:"level"`
+	Tag       string `json:"tag"`
+	Message   string `json:"message"`
+}
+
+// FileSink writes log entries of all accounts as JSON lines to a file with
+// size-based rotation (path, path.1 ... path.N). Writes happen on a background
+// goroutine in batches; Write never blocks and drops entries when the queue
+// is full.
+type FileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu      sync.RWMutex // guards closed against sends on the closed queue
+	closed  bool
+	queue   chan *model.LogEntry
+	done    chan struct{}
+	dropped atomic.Int64
+
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+// NewFileSink opens (or appends to) path. maxSize is the rotation threshold in
+// bytes; maxFiles is the number of rotated files kept besides the active one.
+func NewFileSink(path string, maxSize int64, maxFiles int) (*FileSink, error) {
+	if maxSize <= 0 {
+		maxSize = 10 << 20
+	}
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	fs := &FileSink{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		queue:    make(chan *model.LogEntry, logSinkBuffer),
+		done:     make(chan struct{}),
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+	go fs.run()
+	return fs, nil
+}
+
+// Write queues an entry. Safe on a nil sink.
+func (fs *FileSink) Write(entry *model.LogEntry) {
+	if fs == nil {
+		return
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	if fs.closed {
+		return
+	}
+	select {
+	case fs.queue <- entry:
+	default:
+		fs.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (fs *FileSink) Dropped() int64 {
+	if fs == nil {
+		return 0
+	}
+	return fs.dropped.Load()
+}
+
+// Close flushes queued entries and closes the file. Entries written after
+// Close are dropped.
+func (fs *FileSink) Close() {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	if !fs.closed {
+		fs.closed = true
+		close(fs.queue)
+	}
+	fs.mu.Unlock()
+	<-fs.done
+}
+
+func (fs *FileSink) run() {
+	defer close(fs.done)
+	for entry := range fs.queue {
+		fs.writeEntry(entry)
+		// Drain whatever else is queued before flushing
+	batch:
+		for n := 1; n < logSinkMaxBatch; n++ {
+			select {
+			case e, ok := <-fs.queue:
+				if !ok {
+					break batch
+				}
+				fs.writeEntry(e)
+			default:
+				break batch
+			}
+		}
+		fs.buf.Flush()
+	}
+	fs.buf.Flush()
+	fs.file.Close()
+}
+
+func (fs *FileSink) writeEntry(entry *model.LogEntry) {
+	line, err := json.Marshal(fileLogLine{
+		TS:        entry.CreatedAt.Format(time.RFC3339Nano),
+		AccountID: entry.AccountID,
+		Level:     entry.Level,
+		Tag:       entry.Tag,
+		Message:   entry.Message,
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	if fs.size > 0 && fs.size+int64(len(line)) > fs.maxSize {
+		if err := fs.rotate(); err != nil {
+			fmt.Printf("[日志] 日志文件轮转失败: %v\n", err)
+		}
+	}
+	n, _ := fs.buf.Write(line)
+	fs.size += int64(n)
+}
+
+func (fs *FileSink) open() error {
+	f, err := os.OpenFile(fs.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fs.file = f
+	fs.buf = bufio.NewWriter(f)
+	fs.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.(N-1) -> path.N ... path -> path.1 and reopens path.
+// With maxFiles == 0 the active file is simply truncated.
+func (fs *FileSink) rotate() error {
+	fs.buf.Flush()
+	fs.file.Close()
+
+	if fs.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", fs.path, fs.maxFiles))
+		for i := fs.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", fs.path, i), fmt.Sprintf("%s.%d", fs.path, i+1))
+		}
+		if err := os.Rename(fs.path, fs.path+".1"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := os.Truncate(fs.path, 0); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fs.open()
+}
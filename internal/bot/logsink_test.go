@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// sinkEntry returns entry n; all entries encode to lines of the same length.
+func sinkEntry(n int) *model.LogEntry {
+	return &model.LogEntry{
+		AccountID: 1,
+		Tag:       "测试",
+		Level:     "info",
+		Message:   fmt.Sprintf("line %d", n),
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// sinkLineLen is the size of one sinkEntry line on disk.
+func sinkLineLen(t *testing.T) int64 {
+	t.Helper()
+	e := sinkEntry(0)
+	line, err := json.Marshal(fileLogLine{
+		TS:        e.CreatedAt.Format(time.RFC3339Nano),
+		AccountID: e.AccountID,
+		Level:     e.Level,
+		Tag:       e.Tag,
+		Message:   e.Message,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(line)) + 1
+}
+
+// writeSink writes entries 1..n through a new sink and closes it.
+func writeSink(t *testing.T, path string, maxSize int64, maxFiles, n int) {
+	t.Helper()
+	fs, err := NewFileSink(path, maxSize, maxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		fs.Write(sinkEntry(i))
+	}
+	fs.Close()
+	if fs.Dropped() != 0 {
+		t.Fatalf("dropped %d entries", fs.Dropped())
+	}
+}
+
+// sinkMessages returns the messages in a log file, or nil if it doesn't exist.
+func sinkMessages(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var l fileLogLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		msgs = append(msgs, l.Message)
+	}
+	return msgs
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	lineLen := sinkLineLen(t)
+	cases := []struct {
+		name     string
+		maxSize  int64
+		maxFiles int
+		entries  int
+		want     [][]string // messages of path, path.1, ...; a trailing nil means absent
+	}{
+		{name: "exactly at the limit", maxSize: 2 * lineLen, maxFiles: 3, entries: 2,
+			want: [][]string{{"line 1", "line 2"}, nil}},
+		{name: "one byte over", maxSize: 2*lineLen - 1, maxFiles: 3, entries: 2,
+			want: [][]string{{"line 2"}, {"line 1"}, nil}},
+		{name: "oversized line is still written", maxSize: 1, maxFiles: 1, entries: 1,
+			want: [][]string{{"line 1"}, nil}},
+		{name: "max files pruned", maxSize: lineLen, maxFiles: 2, entries: 5,
+			want: [][]string{{"line 5"}, {"line 4"}, {"line 3"}, nil}},
+		{name: "no backups truncates", maxSize: lineLen, maxFiles: 0, entries: 3,
+			want: [][]string{{"line 3"}, nil}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "farm.log")
+			writeSink(t, path, tc.maxSize, tc.maxFiles, tc.entries)
+			for i, want := range tc.want {
+				name := path
+				if i > 0 {
+					name = fmt.Sprintf("%s.%d", path, i)
+				}
+				got := sinkMessages(t, name)
+				if fmt.Sprint(got) != fmt.Sprint(want) || (got == nil) != (want == nil) {
+					t.Errorf("%s = %q, want %q", filepath.Base(name), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSinkCountsExistingFile(t *testing.T) {
+	lineLen := sinkLineLen(t)
+	path := filepath.Join(t.TempDir(), "farm.log")
+	writeSink(t, path, 2*lineLen, 1, 1)
+
+	// Reopened with one line on disk, the second fills it exactly and the
+	// third rotates
+	writeSink(t, path, 2*lineLen, 1, 1)
+	if got := sinkMessages(t, path); fmt.Sprint(got) != "[line 1 line 1]" {
+		t.Fatalf("after reopen = %q", got)
+	}
+	writeSink(t, path, 2*lineLen, 1, 1)
+	if got, old := sinkMessages(t, path), sinkMessages(t, path+".1"); fmt.Sprint(got) != "[line 1]" || fmt.Sprint(old) != "[line 1 line 1]" {
+		t.Fatalf("after rotation: active %q, backup %q", got, old)
+	}
+}
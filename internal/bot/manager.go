@@ -1,86 +1,413 @@
 package bot
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
+	"time"
 
 	"qq-farm-bot/internal/config"
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
+// ErrAlreadyRunning is returned by StartBot when the bot is running,
+// reconnecting, starting or stopping.
+var ErrAlreadyRunning = errors.New("already running")
+
+// ErrDuplicateCode is returned by StartBot when another running or starting
+// bot logs in with the same code; the two sessions would kick each other.
+var ErrDuplicateCode = errors.New("login code already in use")
+
+// ErrStartCanceled is returned by StartBot when the bot was stopped while its
+// login was in flight.
+var ErrStartCanceled = errors.New("start canceled")
+
+// ErrNotRunning is returned by operations that need a connected bot.
+var ErrNotRunning = errors.New("bot not running")
+
+// BulkResult is the per-account outcome of a bulk start/stop.
+type BulkResult struct {
+	AccountID int64  `json:"account_id"`
+	Name      string `json:"name"`
+	Result    string `json:"result"` // started / already_running / skipped / stopped / not_running / error
+	Error     string `json:"error,omitempty"`
+}
+
+// pendingStart is a login in flight. A stop sent meanwhile sets canceled and
+// waits on done; StartBot then stops the instance instead of keeping it.
+type pendingStart struct {
+	account  *model.Account
+	canceled bool
+	done     chan struct{}
+}
+
 // Manager manages multiple bot instances.
 type Manager struct {
 	mu        sync.RWMutex
-	instances map[int64]*Instance // accountID -> instance
-	store     *store.Store
+	instances map[int64]*Instance     // accountID -> instance
+	starting  map[int64]*pendingStart // accounts with a login in flight
+	stopping  map[int64]*Instance     // instances removed by StopBot, Stop in flight
+	store     store.Store
 	cfg       *config.Config
 	crypto    *Crypto
+	events    *EventBus
+	logs      *LogHub
+	logSink   *FileSink     // optional JSON-lines log file shared by all loggers
+	sysLog    *Logger       // system channel (account_id 0) for events not tied to an account
+	loginSem  chan struct{} // bounds concurrent connect+login across all instances
+	stopCh    chan struct{} // stops the daily summary scheduler
+	qr        *QRSessions   // background scan login sessions
+	families  *Families     // running GIDs per account family
+	notifier  *Notifier     // webhook notifications of bot events
 }
 
-func NewManager(s *store.Store, cfg *config.Config) *Manager {
+func NewManager(s store.Store, cfg *config.Config) *Manager {
+	logs := NewLogHub(cfg.LogSubscriberBuffer)
+	sysLog := NewLogger(SystemAccountID, s, logs)
+	sysLog.SetStorePolicy(cfg.LogLevel, cfg.LogTagBlacklist)
+
+	var logSink *FileSink
+	if cfg.LogFile != "" {
+		var err error
+		logSink, err = NewFileSink(cfg.LogFile, int64(cfg.LogFileMaxSizeMB)<<20, cfg.LogFileMaxFiles)
+		if err != nil {
+			sysLog.Warnf("Manager", "打开日志文件失败: %v (仅记录到数据库)", err)
+		}
+	}
+	sysLog.SetOutputs(logSink, cfg.ConsoleLogMode())
+
+	SetWXAppID(cfg.WXAppID)
+	SetGameResetHour(cfg.GameResetHour)
+
 	crypto, err := NewCrypto()
 	if err != nil {
-		fmt.Printf("[Manager] WASM crypto 初始化失败: %v (消息体将不加密)\n", err)
+		sysLog.Warnf("Manager", "WASM crypto 初始化失败: %v (消息体将不加密)", err)
 	}
-	return &Manager{
+	maxLogins := cfg.MaxConcurrentLogins
+	if maxLogins < 1 {
+		maxLogins = 1
+	}
+	m := &Manager{
 		instances: make(map[int64]*Instance),
+		starting:  make(map[int64]*pendingStart),
+		stopping:  make(map[int64]*Instance),
 		store:     s,
 		cfg:       cfg,
 		crypto:    crypto,
+		events:    NewEventBus(),
+		logs:      logs,
+		logSink:   logSink,
+		sysLog:    sysLog,
+		loginSem:  make(chan struct{}, maxLogins),
+		stopCh:    make(chan struct{}),
+		qr:        NewQRSessions(s, sysLog),
+		families:  NewFamilies(),
+		notifier: NewNotifier(&WebhookTransport{Client: &http.Client{Timeout: notifySendTimeout}},
+			&WebhookTransport{Client: newPublicWebhookClient(notifySendTimeout)},
+			cfg.NotifyWebhookURL, cfg.NotifyEvents, sysLog),
+	}
+	m.qr.OnLogin(m.resumeAfterRelogin)
+	go m.runDailySummaries()
+	return m
+}
+
+// SystemLogger returns the logger for server events not tied to an account.
+// Its entries are stored and streamed under SystemAccountID.
+func (m *Manager) SystemLogger() *Logger {
+	return m.sysLog
+}
+
+// QRSessions returns the background scan login sessions.
+func (m *Manager) QRSessions() *QRSessions {
+	return m.qr
+}
+
+// Events returns the bus carrying status change events of all instances.
+func (m *Manager) Events() *EventBus {
+	return m.events
+}
+
+// Logs returns the per-account log hub. Subscriptions are independent of
+// instance lifetime, so they keep streaming across restarts and reconnects.
+func (m *Manager) Logs() *LogHub {
+	return m.logs
+}
+
+// LogFileDropped returns entries dropped by the log file sink (0 when disabled).
+func (m *Manager) LogFileDropped() int64 {
+	return m.logSink.Dropped()
+}
+
+// Responsive reports whether the manager lock can be acquired within timeout.
+// A false result means some operation is holding the lock (e.g. a wedged stop).
+func (m *Manager) Responsive(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if m.mu.TryRLock() {
+			m.mu.RUnlock()
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
 	}
 }
 
 // AutoStart starts all accounts with auto_start=true.
 func (m *Manager) AutoStart() {
-	accounts, err := m.store.ListAccounts()
+	accounts, err := m.store.ListAccounts(context.Background())
 	if err != nil {
-		fmt.Printf("[Manager] 加载账号失败: %v\n", err)
+		m.sysLog.Errorf("Manager", "加载账号失败: %v", err)
 		return
 	}
+	now := time.Now()
 	for _, a := range accounts {
 		if a.AutoStart && a.Code != "" {
 			acct := a
-			if err := m.StartBot(&acct); err != nil {
-				fmt.Printf("[Manager] 自动启动账号 #%d (%s) 失败: %v\n", a.ID, a.Name, err)
+			if acct.LoginRetryPending(now) {
+				m.sysLog.Warnf("Manager", "账号 #%d (%s) 已连续登录失败 %d 次 (%s), %s 后再自动启动",
+					a.ID, a.Name, a.LoginFailCount, a.LoginFailReason, a.LoginRetryAt.Sub(now).Round(time.Second))
+				go m.autoStartLater(a.ID, a.LoginRetryAt.Sub(now))
+				continue
+			}
+			if err := m.StartBot(&acct, ""); err != nil {
+				m.sysLog.Warnf("Manager", "自动启动账号 #%d (%s) 失败: %v", a.ID, a.Name, err)
 			}
 		}
 	}
 }
 
-func (m *Manager) StartBot(account *model.Account) error {
+// autoStartLater starts an auto-start account once its login retry time has
+// passed, unless it was started, disabled or changed meanwhile.
+func (m *Manager) autoStartLater(id int64, wait time.Duration) {
+	select {
+	case <-time.After(wait):
+	case <-m.stopCh:
+		return
+	}
+	a, err := m.store.GetAccount(context.Background(), id)
+	if err != nil || !a.AutoStart || a.Code == "" || a.LoginRetryPending(time.Now()) {
+		return
+	}
+	if err := m.StartBot(a, ""); err != nil && !errors.Is(err, ErrAlreadyRunning) {
+		m.sysLog.Warnf("Manager", "自动启动账号 #%d (%s) 失败: %v", a.ID, a.Name, err)
+	}
+}
+
+// StartBot connects and logs in a bot for the account. The manager lock is
+// only held to reserve the account, not while the login is in flight.
+// Log lines written during the login carry correlationID (may be empty).
+func (m *Manager) StartBot(account *model.Account, correlationID string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	select {
+	case <-m.stopCh:
+		m.mu.Unlock()
+		return fmt.Errorf("bot #%d %w", account.ID, ErrStartCanceled)
+	default:
+	}
+	if inst, ok := m.instances[account.ID]; (ok && inst.active()) || m.starting[account.ID] != nil || m.stopping[account.ID] != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("bot #%d %w", account.ID, ErrAlreadyRunning)
+	}
+	// Checked under the same lock as the reservation, so two simultaneous
+	// starts with one code can't both pass
+	if holderID, holderName, ok := m.codeHolder(account.ID, account.Code); ok {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: 账号 #%d (%s) 正在使用同一登录码", ErrDuplicateCode, holderID, holderName)
+	}
+	pending := &pendingStart{account: account, done: make(chan struct{})}
+	m.starting[account.ID] = pending
+	m.mu.Unlock()
+	defer close(pending.done)
 
-	if inst, ok := m.instances[account.ID]; ok && inst.IsRunning() {
-		return fmt.Errorf("bot #%d already running", account.ID)
+	serverURL, clientVersion := m.cfg.GameServerFor(account.Platform)
+	if account.ServerURLOverride != "" {
+		serverURL = account.ServerURLOverride
 	}
+	inst := NewInstance(account, serverURL, clientVersion, m.store, m.crypto, m.events, m.logs, m.loginSem)
+	inst.config.LandActionThrottle = m.cfg.LandActionThrottleWindow()
+	inst.logger.SetStorePolicy(m.logStorePolicy(account))
+	inst.logger.SetOutputs(m.logSink, m.cfg.ConsoleLogMode())
+	inst.notifier = m.notifier
+	inst.qr = m.qr
+	inst.families = m.families
+	inst.logger.SetCorrelationID(correlationID)
+	err := inst.Start()
+	inst.logger.SetCorrelationID("")
 
-	inst := NewInstance(account, m.cfg.GameServerURL, m.cfg.ClientVersion, m.store, m.crypto)
-	if err := inst.Start(); err != nil {
+	m.mu.Lock()
+	delete(m.starting, account.ID)
+	if err != nil {
+		m.mu.Unlock()
 		return err
 	}
+	if !pending.canceled {
+		m.instances[account.ID] = inst
+		m.mu.Unlock()
+		return nil
+	}
+	// Stopped during the login: stop it the way StopBot would have
+	m.stopping[account.ID] = inst
+	m.mu.Unlock()
+	inst.Stop()
+	m.mu.Lock()
+	delete(m.stopping, account.ID)
 	m.instances[account.ID] = inst
-	return nil
+	m.mu.Unlock()
+	return fmt.Errorf("bot #%d %w", account.ID, ErrStartCanceled)
 }
 
-func (m *Manager) StopBot(accountID int64) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// codeFingerprint identifies a login code for comparisons.
+func codeFingerprint(code string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(code))
+}
+
+// codeHolder finds another account that may log in with code: one starting,
+// running, reconnecting or still stopping. Callers hold m.mu.
+func (m *Manager) codeHolder(id int64, code string) (int64, string, bool) {
+	if code == "" {
+		return 0, "", false
+	}
+	fp := codeFingerprint(code)
+	for otherID, p := range m.starting {
+		if otherID != id && codeFingerprint(p.account.Code) == fp {
+			return otherID, p.account.Name, true
+		}
+	}
+	for otherID, inst := range m.instances {
+		if otherID == id || !inst.active() {
+			continue
+		}
+		if code, name := inst.loginIdentity(); codeFingerprint(code) == fp {
+			return otherID, name, true
+		}
+	}
+	for otherID, inst := range m.stopping {
+		if otherID == id {
+			continue
+		}
+		if code, name := inst.loginIdentity(); codeFingerprint(code) == fp {
+			return otherID, name, true
+		}
+	}
+	return 0, "", false
+}
 
+// StartBots starts the given accounts concurrently; logins are bounded by the
+// shared login semaphore. Results are returned in input order.
+func (m *Manager) StartBots(accounts []model.Account, correlationID string) []BulkResult {
+	results := make([]BulkResult, len(accounts))
+	var wg sync.WaitGroup
+	for i := range accounts {
+		a := &accounts[i]
+		results[i] = BulkResult{AccountID: a.ID, Name: a.Name}
+		if a.Code == "" {
+			results[i].Result = "skipped"
+			results[i].Error = "account has no login code"
+			continue
+		}
+		wg.Add(1)
+		go func(r *BulkResult) {
+			defer wg.Done()
+			err := m.StartBot(a, correlationID)
+			switch {
+			case err == nil:
+				r.Result = "started"
+			case errors.Is(err, ErrAlreadyRunning):
+				r.Result = "already_running"
+			default:
+				r.Result = "error"
+				r.Error = err.Error()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// StopBots stops the given accounts. Results are returned in input order.
+// A disconnected bot whose watchdog is waiting to reconnect counts as
+// running, so a bulk stop ends its reconnect attempts too.
+func (m *Manager) StopBots(accounts []model.Account, correlationID string) []BulkResult {
+	results := make([]BulkResult, len(accounts))
+	for i, a := range accounts {
+		results[i] = BulkResult{AccountID: a.ID, Name: a.Name}
+		inst := m.GetInstance(a.ID)
+		if (inst == nil || !inst.active()) && !m.isStarting(a.ID) {
+			results[i].Result = "not_running"
+			continue
+		}
+		if err := m.StopBot(a.ID, correlationID); err != nil {
+			results[i].Result = "not_running"
+			continue
+		}
+		results[i].Result = "stopped"
+	}
+	return results
+}
+
+// isStarting reports whether the account has a login in flight.
+func (m *Manager) isStarting(accountID int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.starting[accountID] != nil
+}
+
+// StopBot stops a bot; its shutdown log lines carry correlationID.
+// A bot still logging in is stopped once its login finishes; StopBot waits
+// for that, as the start would otherwise add a running instance after it.
+// The instance leaves the map under the lock but is stopped outside it, as
+// Stop sends a Leave and writes to the database; until it returns, the
+// account can't be started again and its code stays taken. The stopped
+// instance then goes back into the map so its last status stays visible.
+func (m *Manager) StopBot(accountID int64, correlationID string) error {
+	m.mu.Lock()
+	if pending := m.starting[accountID]; pending != nil {
+		pending.canceled = true
+		m.mu.Unlock()
+		<-pending.done
+		return nil
+	}
 	inst, ok := m.instances[accountID]
 	if !ok {
+		m.mu.Unlock()
 		return fmt.Errorf("bot #%d not found", accountID)
 	}
+	delete(m.instances, accountID)
+	m.stopping[accountID] = inst
+	m.mu.Unlock()
+
+	inst.logger.SetCorrelationID(correlationID)
 	inst.Stop()
+	inst.logger.SetCorrelationID("")
+
+	m.mu.Lock()
+	delete(m.stopping, accountID)
+	m.instances[accountID] = inst
+	m.mu.Unlock()
 	return nil
 }
 
+// instance returns the account's instance, including one being stopped.
+// Callers hold m.mu.
+func (m *Manager) instance(accountID int64) (*Instance, bool) {
+	if inst, ok := m.instances[accountID]; ok {
+		return inst, true
+	}
+	inst, ok := m.stopping[accountID]
+	return inst, ok
+}
+
 func (m *Manager) GetStatus(accountID int64) *model.BotStatus {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	inst, ok := m.instances[accountID]
+	inst, ok := m.instance(accountID)
 	if !ok {
 		return &model.BotStatus{AccountID: accountID, Running: false}
 	}
@@ -95,24 +422,69 @@ func (m *Manager) GetAllStatus() []*model.BotStatus {
 	for _, inst := range m.instances {
 		statuses = append(statuses, inst.Status())
 	}
+	for _, inst := range m.stopping {
+		statuses = append(statuses, inst.Status())
+	}
 	return statuses
 }
 
 func (m *Manager) GetInstance(accountID int64) *Instance {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.instances[accountID]
+	inst, _ := m.instance(accountID)
+	return inst
 }
 
 func (m *Manager) StopAll() {
+	// Scan sessions may restart bots on success, so stop them before
+	// taking the lock
+	m.qr.Stop()
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	select {
+	case <-m.stopCh:
+	default:
+		close(m.stopCh)
+	}
+	// Logins in flight stop their own instance once done; no new ones can
+	// begin after stopCh is closed
+	pending := make([]*pendingStart, 0, len(m.starting))
+	for _, p := range m.starting {
+		p.canceled = true
+		pending = append(pending, p)
+	}
+	instances := make([]*Instance, 0, len(m.instances))
 	for _, inst := range m.instances {
+		instances = append(instances, inst)
+	}
+	m.mu.Unlock()
+
+	for _, p := range pending {
+		<-p.done
+	}
+
+	// Outside the lock, like StopBot: each Stop may wait on a Leave
+	for _, inst := range instances {
 		inst.Stop()
 	}
+	m.notifier.Stop()
 	if m.crypto != nil {
 		m.crypto.Close()
 	}
+	m.logSink.Close()
+}
+
+// resumeAfterRelogin restarts a bot that stopped waiting for a new login code
+// once a scan login saved one. Bots stopped for other reasons are left alone.
+func (m *Manager) resumeAfterRelogin(account *model.Account) {
+	inst := m.GetInstance(account.ID)
+	if inst == nil || inst.IsRunning() || !inst.NeedsRelogin() {
+		return
+	}
+	if err := m.StartBot(account, ""); err != nil {
+		m.sysLog.Warnf("扫码", "账号 #%d 扫码后重新启动失败: %v", account.ID, err)
+		return
+	}
+	m.sysLog.Infof("扫码", "账号 #%d 已使用新的登录 code 重新启动", account.ID)
 }
 
 // UpdateBotConfig applies updated account settings to a running bot instance.
@@ -124,5 +496,20 @@ func (m *Manager) UpdateBotConfig(accountID int64, account *model.Account) {
 
 	if ok && inst.IsRunning() {
 		inst.UpdateConfig(account)
+		inst.logger.SetStorePolicy(m.logStorePolicy(account))
+	}
+}
+
+// logStorePolicy resolves the account's log storage settings, falling back to
+// the server defaults from config.json.
+func (m *Manager) logStorePolicy(account *model.Account) (string, []string) {
+	level := account.LogLevel
+	if level == "" {
+		level = m.cfg.LogLevel
+	}
+	blacklist := model.ParseTags(account.LogTagBlacklist)
+	if len(blacklist) == 0 {
+		blacklist = m.cfg.LogTagBlacklist
 	}
+	return level, blacklist
 }
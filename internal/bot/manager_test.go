@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/internal/config"
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/proto/userpb"
+)
+
+func TestStopBotsStopsReconnectingInstance(t *testing.T) {
+	inst := newTestInstance(t)
+	c := newFakeClock(testEpoch)
+	inst.clock = c
+	connectFake(inst, 10, 0)
+	inst.mu.Lock()
+	inst.watching = true
+	inst.mu.Unlock()
+	go inst.watchdog()
+
+	// The connection drops; the watchdog waits out its backoff
+	inst.net.disconnectWithReason(DisconnectReadError)
+	c.blockUntil(t, 1)
+	if inst.IsRunning() || !inst.active() {
+		t.Fatalf("running = %v, active = %v; want a reconnecting instance", inst.IsRunning(), inst.active())
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.ConsoleLog = config.ConsoleLogOff
+	m := NewManager(inst.store, cfg)
+	t.Cleanup(m.StopAll)
+	m.mu.Lock()
+	m.instances[inst.account.ID] = inst
+	m.mu.Unlock()
+
+	res := m.StopBots([]model.Account{*inst.account}, "")
+	if len(res) != 1 || res[0].Result != "stopped" {
+		t.Fatalf("StopBots = %+v, want stopped", res)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for inst.active() {
+		if time.Now().After(deadline) {
+			t.Fatal("watchdog still reconnecting after StopBots")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAutoStartSkipsPendingLoginRetry(t *testing.T) {
+	inst := newTestInstance(t)
+	s := inst.store
+	ctx := context.Background()
+	accounts := make(map[string]int64)
+	for _, name := range []string{"fresh", "failing", "elapsed"} {
+		a := &model.Account{UserID: inst.account.UserID, Name: name, Platform: "qq", Code: name + "-code", AutoStart: true,
+			ServerURLOverride: unreachableServer}
+		if err := s.CreateAccount(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		accounts[name] = a.ID
+	}
+	s.SetLoginFailure(ctx, accounts["failing"], "LoginFailed", 3, time.Now().Add(time.Hour))
+	s.SetLoginFailure(ctx, accounts["elapsed"], "LoginFailed", 2, time.Now().Add(-time.Minute))
+	failures := func(name string) int {
+		t.Helper()
+		a, err := s.GetAccount(ctx, accounts[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.LoginFailCount
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.ConsoleLog = config.ConsoleLogOff
+	m := NewManager(s, cfg)
+	t.Cleanup(m.StopAll)
+
+	// Each start attempt fails against the unreachable server and is counted
+	m.AutoStart()
+	for name, want := range map[string]int{"fresh": 1, "failing": 3, "elapsed": 3} {
+		if got := failures(name); got != want {
+			t.Errorf("after AutoStart %s has %d failures, want %d", name, got, want)
+		}
+	}
+
+	// A manual start doesn't wait for the retry time
+	failing, _ := s.GetAccount(ctx, accounts["failing"])
+	if err := m.StartBot(failing, ""); err == nil {
+		t.Fatal("started against an unreachable server")
+	}
+	if got := failures("failing"); got != 4 {
+		t.Errorf("after a manual start failing has %d failures, want 4", got)
+	}
+}
+
+func TestStopBotDuringLogin(t *testing.T) {
+	LoadGameConfig(testGameConfigDir, nil)
+	inst := newTestInstance(t)
+	_, srv := serveFakeGame(map[string]gameHandler{
+		"Login": func([]byte) (proto.Message, int64) {
+			return &userpb.LoginReply{Basic: &userpb.BasicInfo{Gid: 1001, Name: "farmer", Level: 10, Gold: 5000}}, 0
+		},
+	})
+	t.Cleanup(srv.Close)
+	account := *inst.account
+	account.ServerURLOverride = "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	cfg := config.DefaultConfig()
+	cfg.ConsoleLog = config.ConsoleLogOff
+	cfg.MaxConcurrentLogins = 1
+	m := NewManager(inst.store, cfg)
+	t.Cleanup(m.StopAll)
+
+	// Take the only login slot so the start blocks mid-login
+	m.loginSem <- struct{}{}
+	started := make(chan error, 1)
+	go func() { started <- m.StartBot(&account, "") }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !m.isStarting(account.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("start never reserved the account")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- m.StopBot(account.ID, "") }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("StopBot returned %v before the login finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-m.loginSem
+	if err := <-started; !errors.Is(err, ErrStartCanceled) {
+		t.Fatalf("StartBot = %v, want %v", err, ErrStartCanceled)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("StopBot = %v", err)
+	}
+	got := m.GetInstance(account.ID)
+	if got == nil || got.IsRunning() || got.active() {
+		t.Fatal("bot still running after a stop sent during its login")
+	}
+	if st := m.GetStatus(account.ID); st.Running {
+		t.Fatalf("status = %+v, want stopped", st)
+	}
+}
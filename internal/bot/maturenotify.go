@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+)
+
+// matureLand is a growing land whose crop matures within the notify window.
+type matureLand struct {
+	landID    int64
+	matureSec int64
+	crop      string
+}
+
+// notifyMatureSoon logs and sends NotifyMatureSoon for the lands in soon
+// not yet announced for their current planting. Announcements are keyed by
+// (land, mature time) and checkpointed, so neither a reconnect nor a restart
+// repeats them, while a replant (new mature time) is announced again.
+func (f *FarmWorker) notifyMatureSoon(soon []matureLand, nowSec int64) {
+	if f.matureNotified == nil {
+		f.matureNotified = make(map[int64]int64)
+		f.state.Load(stateKeyMatureNotified, stateVersionMatureNotified, &f.matureNotified)
+	}
+	before := maps.Clone(f.matureNotified)
+
+	var fresh []matureLand
+	for _, l := range soon {
+		if f.matureNotified[l.landID] == l.matureSec {
+			continue
+		}
+		f.matureNotified[l.landID] = l.matureSec
+		fresh = append(fresh, l)
+	}
+	// Forget announcements of crops that matured long ago
+	for id, sec := range f.matureNotified {
+		if sec < nowSec-24*3600 {
+			delete(f.matureNotified, id)
+		}
+	}
+	if !maps.Equal(before, f.matureNotified) {
+		f.state.Save(stateKeyMatureNotified, stateVersionMatureNotified, f.matureNotified)
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].matureSec < fresh[j].matureSec })
+	parts := make([]string, 0, len(fresh))
+	lands := make([]map[string]any, 0, len(fresh))
+	for _, l := range fresh {
+		minutes := (l.matureSec - nowSec + 59) / 60
+		parts = append(parts, fmt.Sprintf("#%d(%s) %d分钟", l.landID, l.crop, minutes))
+		lands = append(lands, map[string]any{"land_id": l.landID, "crop": l.crop, "mature_time": l.matureSec})
+	}
+	msg := fmt.Sprintf("%d 块土地即将成熟: %s", len(fresh), strings.Join(parts, " "))
+	f.logger.Infof("成熟", "%s", msg)
+	if f.notify != nil {
+		f.notify(NotifyMatureSoon, msg, map[string]any{"lands": lands})
+	}
+}
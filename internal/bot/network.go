@@ -56,6 +56,8 @@ const (
 	DisconnectLoginFailed
 	// DisconnectLoginTimeout — login request timed out (30 s).
 	DisconnectLoginTimeout
+	// DisconnectLoginExpired — the server no longer accepts the login code.
+	DisconnectLoginExpired
 	// DisconnectClosed — Close() was called explicitly (user-initiated stop).
 	DisconnectClosed
 )
@@ -74,6 +76,8 @@ func (r DisconnectReason) String() string {
 		return "login_failed"
 	case DisconnectLoginTimeout:
 		return "login_timeout"
+	case DisconnectLoginExpired:
+		return "login_expired"
 	case DisconnectClosed:
 		return "closed"
 	default:
@@ -88,6 +92,8 @@ func (r DisconnectReason) Retryable() bool {
 		return false // server kicked us; retrying is futile
 	case DisconnectClosed:
 		return false // intentional stop
+	case DisconnectLoginExpired:
+		return false // the same code will be refused again
 	default:
 		return true
 	}
@@ -141,6 +147,7 @@ type callResult struct {
 
 // Network manages the WebSocket connection to the game server.
 type Network struct {
+	clock     Clock // time source of heartbeats and worker scheduling
 	conn      *websocket.Conn
 	writeMu   sync.Mutex // protects concurrent writes to conn
 	clientSeq int64
@@ -153,6 +160,12 @@ type Network struct {
 	logger   *Logger
 	crypto   *Crypto
 	onNotify func(msgType string, body []byte)
+	// onStateChange is called after level/gold/exp change via server push
+	onStateChange func()
+	// onLevelUp is called when a server push raises the level
+	onLevelUp func(from, to int64)
+	// onGoldChange is called when a server push changes a known gold balance
+	onGoldChange func(from, to int64)
 
 	// Disconnect reason — written at most once via disconnectOnce.
 	disconnectOnce   sync.Once
@@ -165,6 +178,15 @@ type Network struct {
 	// Approximate server now = time.Now().UnixMilli() + ServerTimeDelta().
 	serverTimeDelta atomic.Int64
 
+	// Set when a push adds or removes a friend, so the cached friend list
+	// is refetched on the next friend check.
+	friendsChanged atomic.Bool
+
+	// RPC denylist checked before every request; see SetRPCDeny.
+	guard atomic.Pointer[rpcGuard]
+	// Requests are traced until this unix-millis deadline; see SetTrace.
+	traceUntil atomic.Int64
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	done   chan struct{}
@@ -177,6 +199,8 @@ type UserState struct {
 	Level int64
 	Exp   int64
 	Gold  int64
+	// Coupons (点券) as last seen in a bag fetch or ItemNotify push
+	Coupons int64
 }
 
 func (s *UserState) Get() (gid, level, exp, gold int64, name string) {
@@ -185,6 +209,18 @@ func (s *UserState) Get() (gid, level, exp, gold int64, name string) {
 	return s.GID, s.Level, s.Exp, s.Gold, s.Name
 }
 
+func (s *UserState) GetCoupons() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Coupons
+}
+
+func (s *UserState) SetCoupons(v int64) {
+	s.mu.Lock()
+	s.Coupons = v
+	s.mu.Unlock()
+}
+
 func (s *UserState) SetFromLogin(gid, level, exp, gold int64, name string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -195,9 +231,14 @@ func (s *UserState) SetFromLogin(gid, level, exp, gold int64, name string) {
 	s.Gold = gold
 }
 
-func NewNetwork(logger *Logger, crypto *Crypto) *Network {
+// NewNetwork creates an unconnected Network. A nil clock uses real time.
+func NewNetwork(logger *Logger, crypto *Crypto, clock Clock) *Network {
+	if clock == nil {
+		clock = realClock{}
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	n := &Network{
+		clock:   clock,
 		pending: make(map[int64]*pendingCall),
 		state:   &UserState{},
 		logger:  logger,
@@ -206,10 +247,50 @@ func NewNetwork(logger *Logger, crypto *Crypto) *Network {
 		cancel:  cancel,
 		done:    make(chan struct{}),
 	}
-	n.lastHeartbeatAt.Store(time.Now().UnixMilli())
+	n.lastHeartbeatAt.Store(clock.Now().UnixMilli())
+	n.guard.Store(newRPCGuard(""))
 	return n
 }
 
+// SetTrace logs the metadata of every request under the "RPC" tag until
+// the given time; the zero time stops tracing.
+func (n *Network) SetTrace(until time.Time) {
+	if until.IsZero() {
+		n.traceUntil.Store(0)
+		return
+	}
+	n.traceUntil.Store(until.UnixMilli())
+}
+
+func (n *Network) tracing() bool {
+	until := n.traceUntil.Load()
+	return until != 0 && n.clock.Now().UnixMilli() < until
+}
+
+// traceRPC logs one request's metadata. Bodies are never logged: requests
+// may carry login codes.
+func (n *Network) traceRPC(service, method string, seq int64, reqBytes, respBytes int, elapsed time.Duration, err error) {
+	var code int64
+	if se := asServerError(err); se != nil {
+		code = se.Code
+	}
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+		if code != 0 {
+			status = fmt.Sprintf("code=%d", code)
+		}
+	}
+	n.logger.Tracef("RPC", "%s.%s seq=%d %dms req=%dB resp=%dB %s",
+		service, method, seq, elapsed.Milliseconds(), reqBytes, respBytes, status)
+}
+
+// SetRPCDeny replaces the account's denied "service.method" patterns. The
+// default griefing denials always stay in force.
+func (n *Network) SetRPCDeny(patterns string) {
+	n.guard.Store(newRPCGuard(patterns))
+}
+
 // disconnectWithReason records the disconnect reason (first-writer-wins)
 // and cancels the context to signal all goroutines.
 func (n *Network) disconnectWithReason(reason DisconnectReason) {
@@ -316,8 +397,16 @@ func (n *Network) ServerTimeDelta() int64 { return n.serverTimeDelta.Load() }
 
 // sendRequestWithTimeout sends a protobuf request and waits for the response
 // with a caller-specified timeout.
-func (n *Network) sendRequestWithTimeout(service, method string, body []byte, timeout time.Duration) ([]byte, error) {
+func (n *Network) sendRequestWithTimeout(service, method string, body []byte, timeout time.Duration) (reply []byte, err error) {
+	if pattern, denied := n.guard.Load().denied(service, method); denied {
+		n.logger.Warnf("安全", "已拦截请求 %s.%s (匹配禁止规则 %s)", service, method, pattern)
+		return nil, fmt.Errorf("%w: %s.%s", ErrRPCDenied, service, method)
+	}
 	seq := atomic.AddInt64(&n.clientSeq, 1)
+	if n.tracing() {
+		start := time.Now()
+		defer func() { n.traceRPC(service, method, seq, len(body), len(reply), time.Since(start), err) }()
+	}
 	msg := &gatepb.Message{
 		Meta: &gatepb.Meta{
 			ServiceName: service,
@@ -511,16 +600,24 @@ func (n *Network) handleNotify(msg *gatepb.Message) {
 			if notify.Basic.Level > 0 {
 				n.state.Level = notify.Basic.Level
 			}
+			newLevel := n.state.Level
+			oldGold := n.state.Gold
 			if notify.Basic.Gold > 0 {
 				n.state.Gold = notify.Basic.Gold
 			}
+			newGold := n.state.Gold
 			if notify.Basic.Exp > 0 {
 				n.state.Exp = notify.Basic.Exp
 			}
 			n.state.mu.Unlock()
-			if n.state.Level != oldLevel {
-				n.logger.Infof("系统", "升级! Lv%d → Lv%d", oldLevel, n.state.Level)
+			if newLevel != oldLevel {
+				n.logger.Infof("系统", "升级! Lv%d → Lv%d", oldLevel, newLevel)
+				if oldLevel > 0 && newLevel > oldLevel && n.onLevelUp != nil {
+					n.onLevelUp(oldLevel, newLevel)
+				}
 			}
+			n.goldChanged(oldGold, newGold)
+			n.notifyStateChange()
 		}
 		return
 	}
@@ -528,6 +625,7 @@ func (n *Network) handleNotify(msg *gatepb.Message) {
 	if strings.Contains(msgType, "ItemNotify") {
 		notify := &itempb.ItemNotify{}
 		if err := proto.Unmarshal(event.Body, notify); err == nil {
+			changed := false
 			for _, chg := range notify.Items {
 				if chg.Item == nil {
 					continue
@@ -538,22 +636,51 @@ func (n *Network) handleNotify(msg *gatepb.Message) {
 					n.state.mu.Lock()
 					n.state.Exp = count
 					n.state.mu.Unlock()
+					changed = true
 				} else if id == 1 || id == 1001 {
 					n.state.mu.Lock()
+					oldGold := n.state.Gold
 					n.state.Gold = count
 					n.state.mu.Unlock()
+					n.goldChanged(oldGold, count)
+					changed = true
+				} else if id == couponItemID {
+					n.state.SetCoupons(count)
+					changed = true
 				}
 			}
+			if changed {
+				n.notifyStateChange()
+			}
 		}
 		return
 	}
 
+	if strings.Contains(msgType, "FriendAddedNotify") || strings.Contains(msgType, "DelFriendNotify") {
+		n.friendsChanged.Store(true)
+		return
+	}
+
 	// Forward other notifies to bot
 	if n.onNotify != nil {
 		n.onNotify(msgType, event.Body)
 	}
 }
 
+// goldChanged reports a pushed gold update; an unknown (zero) previous
+// balance is not a change.
+func (n *Network) goldChanged(from, to int64) {
+	if from > 0 && from != to && n.onGoldChange != nil {
+		n.onGoldChange(from, to)
+	}
+}
+
+func (n *Network) notifyStateChange() {
+	if n.onStateChange != nil {
+		n.onStateChange()
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Login
 // ---------------------------------------------------------------------------
@@ -588,7 +715,9 @@ func (n *Network) Login(clientVersion string) error {
 		if errors.As(err, &se) {
 			n.logger.Warnf("登录", "服务器拒绝: code=%d msg=%s", se.Code, se.Message)
 		}
-		if strings.Contains(err.Error(), "timeout") {
+		if asServerError(err).IsNeedRelogin() {
+			n.disconnectWithReason(DisconnectLoginExpired)
+		} else if strings.Contains(err.Error(), "timeout") {
 			n.disconnectWithReason(DisconnectLoginTimeout)
 		} else {
 			n.disconnectWithReason(DisconnectLoginFailed)
@@ -625,13 +754,13 @@ func (n *Network) Login(clientVersion string) error {
 //   - Syncs server time delta from HeartbeatReply on every success.
 func (n *Network) StartHeartbeat(clientVersion string, interval time.Duration) {
 	go func() {
-		ticker := time.NewTicker(interval)
+		ticker := n.clock.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-n.ctx.Done():
 				return
-			case <-ticker.C:
+			case <-ticker.C():
 				gid, _, _, _, _ := n.state.Get()
 				if gid == 0 {
 					continue
@@ -641,7 +770,7 @@ func (n *Network) StartHeartbeat(clientVersion string, interval time.Duration) {
 				// If no heartbeat response within the deadline, the connection
 				// is dead — disconnect immediately and let the watchdog reconnect.
 				lastMs := n.lastHeartbeatAt.Load()
-				elapsed := time.Since(time.UnixMilli(lastMs))
+				elapsed := n.clock.Now().Sub(time.UnixMilli(lastMs))
 				if elapsed > heartbeatResponseDeadline {
 					n.logger.Warnf("心跳", "超过 %ds 无心跳响应，断开连接 (pending=%d)",
 						int(elapsed.Seconds()), n.pendingCount())
@@ -672,7 +801,7 @@ func (n *Network) doHeartbeat(clientVersion string, gid int64) {
 		}
 		return
 	}
-	n.lastHeartbeatAt.Store(time.Now().UnixMilli())
+	n.lastHeartbeatAt.Store(n.clock.Now().UnixMilli())
 	n.syncServerTime(replyBody)
 }
 
@@ -709,7 +838,7 @@ func (n *Network) syncServerTime(replyBody []byte) {
 		return
 	}
 	if reply.ServerTime > 0 {
-		localNow := time.Now().UnixMilli()
+		localNow := n.clock.Now().UnixMilli()
 		n.serverTimeDelta.Store(reply.ServerTime - localNow)
 	}
 }
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/gatepb"
+)
+
+// gameHandler answers one request body with a reply, or with a non-zero
+// server error code.
+type gameHandler func(body []byte) (proto.Message, int64)
+
+type gameCall struct {
+	method string
+	body   []byte
+}
+
+// fakeGame is a game server on a real websocket. Requests are answered by
+// handlers keyed by method name; unhandled methods get an empty reply.
+type fakeGame struct {
+	mu       sync.Mutex
+	handlers map[string]gameHandler
+	calls    []gameCall
+}
+
+// connectFakeGame serves a fakeGame and connects net to it.
+func connectFakeGame(t *testing.T, net *Network, handlers map[string]gameHandler) *fakeGame {
+	t.Helper()
+	g, srv := serveFakeGame(handlers)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	net.conn = conn
+	go net.readLoop()
+	t.Cleanup(func() {
+		net.Close()
+		srv.Close()
+	})
+	return g
+}
+
+// serveFakeGame starts a websocket server answering with a fakeGame.
+func serveFakeGame(handlers map[string]gameHandler) (*fakeGame, *httptest.Server) {
+	g := &fakeGame{handlers: handlers}
+	// The bot sends the game's Origin header
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if reply := g.serve(data); reply != nil {
+				conn.WriteMessage(websocket.BinaryMessage, reply)
+			}
+		}
+	}))
+	return g, srv
+}
+
+func (g *fakeGame) serve(data []byte) []byte {
+	msg := &gatepb.Message{}
+	if proto.Unmarshal(data, msg) != nil || msg.Meta == nil || msg.Meta.MessageType != 1 {
+		return nil
+	}
+	method := msg.Meta.MethodName
+	g.mu.Lock()
+	g.calls = append(g.calls, gameCall{method, msg.Body})
+	handler := g.handlers[method]
+	g.mu.Unlock()
+
+	meta := &gatepb.Meta{ServiceName: msg.Meta.ServiceName, MethodName: method, MessageType: 2, ClientSeq: msg.Meta.ClientSeq}
+	reply := &gatepb.Message{Meta: meta}
+	if handler != nil {
+		body, code := handler(msg.Body)
+		meta.ErrorCode = code
+		if body != nil {
+			reply.Body, _ = proto.Marshal(body)
+		}
+	}
+	out, _ := proto.Marshal(reply)
+	return out
+}
+
+// requests returns the bodies of every call to method so far.
+func (g *fakeGame) requests(method string) [][]byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out [][]byte
+	for _, c := range g.calls {
+		if c.method == method {
+			out = append(out, c.body)
+		}
+	}
+	return out
+}
@@ -0,0 +1,358 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// Notification events.
+const (
+	NotifyNeedsRelogin = "needs_relogin" // bot gave up reconnecting; payload carries a QR login URL
+	NotifyKickout      = "kickout"
+	NotifyLevelUp      = "level_up"
+	NotifyDailySummary = "daily_summary"
+	NotifyMatureSoon   = "mature_soon" // crops maturing within notify_before_mature_minutes
+)
+
+const (
+	notifyQueueSize   = 20 // per webhook target
+	notifyMaxSends    = 8  // sends in flight across all targets
+	notifyIdleTimeout = time.Minute
+	notifyMaxAttempts = 4
+	notifyBackoffInit = 2 * time.Second
+	notifySendTimeout = 10 * time.Second
+)
+
+// errPrivateAddress is returned when a webhook resolves to an address the
+// server must not call on a user's behalf.
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// Notification is the JSON payload delivered by notification transports.
+type Notification struct {
+	Event       string         `json:"event"`
+	AccountID   int64          `json:"account_id"`
+	AccountName string         `json:"account_name"`
+	Platform    string         `json:"platform"`
+	Message     string         `json:"message"`
+	Time        time.Time      `json:"time"`
+	Data        map[string]any `json:"data,omitempty"`
+}
+
+// Transport delivers a notification to a target such as a webhook URL.
+type Transport interface {
+	Send(ctx context.Context, target string, n *Notification) error
+}
+
+// WebhookTransport POSTs the notification as JSON to the target URL. Any
+// non-2xx response counts as a failure and is retried by the Notifier.
+type WebhookTransport struct {
+	Client *http.Client
+}
+
+func (t *WebhookTransport) Send(ctx context.Context, target string, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "qq-farm-bot")
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newPublicWebhookClient returns a client for user-supplied webhooks. It
+// refuses loopback, private and link-local addresses when dialing, so DNS
+// answers and redirects can't reach internal hosts either, and ignores the
+// proxy environment.
+func newPublicWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicDialControl}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// publicDialControl rejects connections to non-public addresses. It runs
+// after name resolution, on the address actually dialed.
+func publicDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+	return nil
+}
+
+type notifyJob struct {
+	target    string
+	transport Transport
+	n         *Notification
+}
+
+// notifyTarget is the queue of one webhook URL. Its worker retries a failed
+// send before moving on, so a dead webhook only delays its own events.
+type notifyTarget struct {
+	queue chan notifyJob
+}
+
+// Notifier queues notifications and delivers them in the background, retrying
+// failed sends with exponential backoff. Notify never blocks the caller.
+type Notifier struct {
+	transport        Transport // the server's default URL
+	accountTransport Transport // per-account overrides, set by users
+	defaultURL       string
+	events           map[string]bool // nil sends every event
+	logger           *Logger
+	sendSem          chan struct{} // bounds sends in flight
+	mu               sync.Mutex
+	targets          map[string]*notifyTarget // URL -> queue, while its worker runs
+	stopCh           chan struct{}
+	stopOnce         sync.Once
+	wg               sync.WaitGroup
+}
+
+// NewNotifier returns a notifier sending to defaultURL unless an account
+// overrides it. Overrides go through accountTransport. events limits the
+// events sent; empty sends all of them.
+func NewNotifier(transport, accountTransport Transport, defaultURL string, events []string, logger *Logger) *Notifier {
+	nt := &Notifier{
+		transport:        transport,
+		accountTransport: accountTransport,
+		defaultURL:       defaultURL,
+		logger:           logger,
+		sendSem:          make(chan struct{}, notifyMaxSends),
+		targets:          make(map[string]*notifyTarget),
+		stopCh:           make(chan struct{}),
+	}
+	if len(events) > 0 {
+		nt.events = make(map[string]bool, len(events))
+		for _, ev := range events {
+			nt.events[ev] = true
+		}
+	}
+	return nt
+}
+
+func (nt *Notifier) target(account *model.Account) string {
+	if account.NotifyWebhookURL != "" {
+		return account.NotifyWebhookURL
+	}
+	return nt.defaultURL
+}
+
+func (nt *Notifier) transportFor(account *model.Account) Transport {
+	if account.NotifyWebhookURL != "" {
+		return nt.accountTransport
+	}
+	return nt.transport
+}
+
+// Enabled reports whether event would be delivered for the account.
+func (nt *Notifier) Enabled(account *model.Account, event string) bool {
+	return nt.target(account) != "" && (nt.events == nil || nt.events[event])
+}
+
+// Notify queues an event for the account. It is a no-op when the account has
+// no webhook or the event is filtered out; when the target's queue is full
+// the event is dropped.
+func (nt *Notifier) Notify(account *model.Account, event, message string, data map[string]any) {
+	if !nt.Enabled(account, event) {
+		return
+	}
+	job := notifyJob{
+		target:    nt.target(account),
+		transport: nt.transportFor(account),
+		n: &Notification{
+			Event:       event,
+			AccountID:   account.ID,
+			AccountName: account.Name,
+			Platform:    account.Platform,
+			Message:     message,
+			Time:        time.Now(),
+			Data:        data,
+		},
+	}
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	select {
+	case <-nt.stopCh:
+		return
+	default:
+	}
+	t := nt.targets[job.target]
+	if t == nil {
+		t = &notifyTarget{queue: make(chan notifyJob, notifyQueueSize)}
+		nt.targets[job.target] = t
+		nt.wg.Add(1)
+		go nt.run(job.target, t)
+	}
+	select {
+	case t.queue <- job:
+	default:
+		nt.logger.Warnf("通知", "通知队列已满, 丢弃账号 #%d 的 %s 事件", account.ID, event)
+	}
+}
+
+// Stop abandons queued and retrying notifications and waits for the senders.
+func (nt *Notifier) Stop() {
+	nt.mu.Lock()
+	nt.stopOnce.Do(func() { close(nt.stopCh) })
+	nt.mu.Unlock()
+	nt.wg.Wait()
+}
+
+// run delivers one target's queue in order. It exits once the queue has
+// been idle for notifyIdleTimeout; Notify starts a new worker as needed.
+func (nt *Notifier) run(url string, t *notifyTarget) {
+	defer nt.wg.Done()
+	idle := time.NewTimer(notifyIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-nt.stopCh:
+			return
+		case job := <-t.queue:
+			nt.deliver(job)
+			idle.Reset(notifyIdleTimeout)
+		case <-idle.C:
+			// Notify queues under nt.mu, so an empty queue stays empty
+			nt.mu.Lock()
+			if len(t.queue) == 0 {
+				delete(nt.targets, url)
+				nt.mu.Unlock()
+				return
+			}
+			nt.mu.Unlock()
+			idle.Reset(notifyIdleTimeout)
+		}
+	}
+}
+
+// send makes one delivery attempt, holding a slot of sendSem meanwhile.
+func (nt *Notifier) send(job notifyJob) error {
+	select {
+	case nt.sendSem <- struct{}{}:
+		defer func() { <-nt.sendSem }()
+	case <-nt.stopCh:
+		return errors.New("notifier stopped")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifySendTimeout)
+	defer cancel()
+	return job.transport.Send(ctx, job.target, job.n)
+}
+
+func (nt *Notifier) deliver(job notifyJob) {
+	backoff := notifyBackoffInit
+	for attempt := 1; ; attempt++ {
+		err := nt.send(job)
+		if err == nil {
+			return
+		}
+		if attempt >= notifyMaxAttempts {
+			nt.logger.Warnf("通知", "账号 #%d 的 %s 通知发送失败 (已重试 %d 次): %v", job.n.AccountID, job.n.Event, attempt-1, err)
+			return
+		}
+		select {
+		case <-nt.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// notify sends an event for the instance's account. The account is re-read
+// so webhook changes apply without restarting the bot.
+func (inst *Instance) notify(event, message string, data map[string]any) {
+	if inst.notifier == nil {
+		return
+	}
+	inst.notifier.Notify(inst.currentAccount(), event, message, data)
+}
+
+func (inst *Instance) currentAccount() *model.Account {
+	inst.mu.RLock()
+	account := *inst.account
+	inst.mu.RUnlock()
+	if inst.store != nil {
+		if fresh, err := inst.store.GetAccount(context.Background(), account.ID); err == nil {
+			return fresh
+		}
+	}
+	return &account
+}
+
+// requireRelogin marks the instance as waiting for a new login code and sends
+// needs_relogin. A scan login session is started for the payload so the QR
+// can be scanned straight from the notification; once it succeeds the
+// manager restarts the bot with the new code.
+func (inst *Instance) requireRelogin(reason string) {
+	inst.mu.Lock()
+	inst.needsRelogin = true
+	inst.mu.Unlock()
+	inst.sc.RecordActivity(model.ActivityNeedsRelogin, map[string]any{"reason": reason})
+
+	if inst.notifier == nil {
+		return
+	}
+	account := inst.currentAccount()
+	if !inst.notifier.Enabled(account, NotifyNeedsRelogin) {
+		return
+	}
+	data := map[string]any{"reason": reason}
+	if inst.qr != nil {
+		qs, err := inst.qr.Start(account, model.AuditEntry{UserID: account.UserID})
+		if err != nil {
+			inst.logger.Warnf("通知", "创建扫码登录会话失败: %v", err)
+			data["qr_error"] = err.Error()
+		} else {
+			data["qr_session_id"] = qs.ID
+			data["qr_code_url"] = qs.QRCodeURL
+			data["qr_expires_at"] = qs.ExpiresAt
+		}
+	}
+	name := account.Name
+	if name == "" {
+		name = fmt.Sprintf("#%d", account.ID)
+	}
+	inst.notifier.Notify(account, NotifyNeedsRelogin, fmt.Sprintf("账号 %s 需要重新登录 (%s)", name, reason), data)
+}
+
+// NeedsRelogin reports whether the bot stopped waiting for a new login code.
+func (inst *Instance) NeedsRelogin() bool {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	return inst.needsRelogin
+}
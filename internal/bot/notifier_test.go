@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// fakeTransport fails every send to a target in dead and reports the
+// others on sent.
+type fakeTransport struct {
+	mu   sync.Mutex
+	dead map[string]bool
+	sent chan *Notification
+}
+
+func (t *fakeTransport) Send(_ context.Context, target string, n *Notification) error {
+	t.mu.Lock()
+	dead := t.dead[target]
+	t.mu.Unlock()
+	if dead {
+		return errors.New("HTTP 502")
+	}
+	t.sent <- n
+	return nil
+}
+
+func TestNotifierDeadWebhookDoesNotDelayOthers(t *testing.T) {
+	tr := &fakeTransport{dead: map[string]bool{"http://dead.example/hook": true}, sent: make(chan *Notification, 10)}
+	nt := NewNotifier(tr, tr, "", nil, quietLogger())
+	t.Cleanup(nt.Stop)
+
+	dead := &model.Account{ID: 1, NotifyWebhookURL: "http://dead.example/hook"}
+	live := &model.Account{ID: 2, NotifyWebhookURL: "http://live.example/hook"}
+	// The dead webhook's events are retried with backoff meanwhile
+	for range 3 {
+		nt.Notify(dead, NotifyNeedsRelogin, "dead", nil)
+	}
+	nt.Notify(live, NotifyNeedsRelogin, "live", nil)
+
+	select {
+	case n := <-tr.sent:
+		if n.AccountID != live.ID {
+			t.Fatalf("delivered account #%d, want #%d", n.AccountID, live.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("live webhook waited behind the dead one")
+	}
+}
+
+func TestPublicDialControl(t *testing.T) {
+	for _, tc := range []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fc00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	} {
+		err := publicDialControl("tcp", tc.address, nil)
+		if (err == nil) != tc.public {
+			t.Errorf("%s: err = %v, want public %v", tc.address, err, tc.public)
+		}
+	}
+}
+
+func TestPublicWebhookClientRefusesLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	t.Cleanup(srv.Close)
+
+	tr := &WebhookTransport{Client: newPublicWebhookClient(time.Second)}
+	err := tr.Send(context.Background(), srv.URL, &Notification{Event: NotifyLevelUp})
+	if !errors.Is(err, errPrivateAddress) || called {
+		t.Fatalf("send to %s: err = %v, called %v; want refused", srv.URL, err, called)
+	}
+}
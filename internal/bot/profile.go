@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// warmUpFriendsPerCycle caps the friends visited per pass during warm-up.
+	warmUpFriendsPerCycle = 2
+	// warmUpTaskInterval replaces the task poll interval during warm-up, as
+	// growth tasks carry the tutorial progression.
+	warmUpTaskInterval = time.Minute
+)
+
+// Profile resolves the config a worker uses for one cycle. While the
+// account's level is below WarmUpLevel the warm-up overlay is applied on top
+// of the account settings, so workers only ever look at cfg fields.
+//
+// Workers never read base directly: UpdateConfig rewrites it while they
+// run, so they take a copy through Current or Resolve instead.
+type Profile struct {
+	logger *Logger
+
+	baseMu sync.RWMutex
+	base   *BotConfig // written only through Update
+
+	mu   sync.Mutex
+	warm bool
+}
+
+func NewProfile(base *BotConfig, logger *Logger) *Profile {
+	return &Profile{base: base, logger: logger}
+}
+
+// Current returns a copy of the account settings, without the warm-up
+// overlay.
+func (p *Profile) Current() *BotConfig {
+	p.baseMu.RLock()
+	cfg := *p.base
+	p.baseMu.RUnlock()
+	return &cfg
+}
+
+// Update applies fn to the account settings. Copies handed out earlier keep
+// their values; workers see the change on their next pass.
+func (p *Profile) Update(fn func(cfg *BotConfig)) {
+	p.baseMu.Lock()
+	defer p.baseMu.Unlock()
+	fn(p.base)
+}
+
+// Resolve returns the config for an account at level. Level 0 (not logged in
+// yet) never selects warm-up. Switching profile is logged once.
+func (p *Profile) Resolve(level int64) *BotConfig {
+	cfg := p.Current()
+	warm := warmUpAt(cfg, level)
+
+	p.mu.Lock()
+	changed := warm != p.warm
+	p.warm = warm
+	p.mu.Unlock()
+	if changed && warm {
+		p.logger.Infof("新手", "等级 %d 低于 %d, 启用新手模式: 只种最便宜的种子, 不升级土地, 优先领取成长任务, 每轮只访问 %d 位好友",
+			level, cfg.WarmUpLevel, warmUpFriendsPerCycle)
+	} else if changed {
+		p.logger.Infof("新手", "等级 %d, 退出新手模式, 恢复账号配置", level)
+	}
+
+	if warm {
+		applyWarmUp(cfg)
+	}
+	return cfg
+}
+
+// Peek returns the config Resolve would return at level, without recording
+// or logging a profile switch.
+func (p *Profile) Peek(level int64) *BotConfig {
+	cfg := p.Current()
+	if warmUpAt(cfg, level) {
+		applyWarmUp(cfg)
+	}
+	return cfg
+}
+
+func warmUpAt(cfg *BotConfig, level int64) bool {
+	return cfg.WarmUpLevel > 0 && level > 0 && level < int64(cfg.WarmUpLevel)
+}
+
+// applyWarmUp overlays the beginner profile on cfg.
+func applyWarmUp(cfg *BotConfig) {
+	cfg.WarmUp = true
+	cfg.PlantCropID = 0
+	cfg.PlantingStrategy = ""
+	cfg.ReplantOnLevelUp = false
+	cfg.EnableUpgradeLand = false
+	cfg.EnableClaimTask = true
+	cfg.FriendsPerCycle = warmUpFriendsPerCycle
+}
@@ -27,6 +27,19 @@ type QRLoginStatus struct {
 	Message string `json:"message,omitempty"` // error detail for frontend display
 }
 
+// qrHTTPClient is shared by the QQ and WeChat scan login providers.
+var qrHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// newQRRequest builds a login request carrying the provider's headers.
+func newQRRequest(method, rawURL string, body io.Reader, headers http.Header) (*http.Request, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+	return req, nil
+}
+
 func qqHeaders() http.Header {
 	h := http.Header{}
 	h.Set("qua", qua)
@@ -37,12 +50,35 @@ func qqHeaders() http.Header {
 	return h
 }
 
-// RequestQRCode initiates a QQ scan login and returns the QR URL.
-func RequestQRCode() (*QRLoginResult, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", "https://q.qq.com/ide/devtoolAuth/GetLoginCode", nil)
-	req.Header = qqHeaders()
-	resp, err := client.Do(req)
+// RequestQRCode initiates a scan login for the platform ("qq" or "wx") and
+// returns the QR URL plus the login code to poll with.
+func RequestQRCode(platform string) (*QRLoginResult, error) {
+	if platform == "wx" {
+		return requestWXQRCode()
+	}
+	return requestQQQRCode()
+}
+
+// PollQRStatus checks the scan status of a login started by RequestQRCode.
+func PollQRStatus(platform, loginCode string) (*QRLoginStatus, error) {
+	if platform == "wx" {
+		return pollWXQRStatus(loginCode)
+	}
+	return pollQQQRStatus(loginCode)
+}
+
+// QRCodeURL returns the URL encoded in the QR code of a scan login.
+func QRCodeURL(platform, loginCode string) string {
+	if platform == "wx" {
+		return wxQRConfirmURL + url.QueryEscape(loginCode)
+	}
+	return fmt.Sprintf("https://h5.qzone.qq.com/qqq/code/%s?_proxy=1&from=ide", url.PathEscape(loginCode))
+}
+
+// requestQQQRCode initiates a QQ scan login and returns the QR URL.
+func requestQQQRCode() (*QRLoginResult, error) {
+	req, _ := newQRRequest("GET", "https://q.qq.com/ide/devtoolAuth/GetLoginCode", nil, qqHeaders())
+	resp, err := qrHTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -63,23 +99,21 @@ func RequestQRCode() (*QRLoginResult, error) {
 
 	return &QRLoginResult{
 		LoginCode: result.Data.Code,
-		QRCodeURL: fmt.Sprintf("https://h5.qzone.qq.com/qqq/code/%s?_proxy=1&from=ide", result.Data.Code),
+		QRCodeURL: QRCodeURL("qq", result.Data.Code),
 	}, nil
 }
 
-// PollQRStatus checks the scan status.
+// pollQQQRStatus checks the QQ scan status.
 // Returns a status object with NO error for all expected QR states (wait/ok/expired),
 // so the API handler always returns HTTP 200 and the frontend can react properly.
 // Only returns a Go error for truly unexpected failures (network, JSON parse).
-func PollQRStatus(loginCode string) (*QRLoginStatus, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+func pollQQQRStatus(loginCode string) (*QRLoginStatus, error) {
 	pollURL := fmt.Sprintf(
 		"https://q.qq.com/ide/devtoolAuth/syncScanSateGetTicket?code=%s",
 		url.QueryEscape(loginCode),
 	)
-	req, _ := http.NewRequest("GET", pollURL, nil)
-	req.Header = qqHeaders()
-	resp, err := client.Do(req)
+	req, _ := newQRRequest("GET", pollURL, nil, qqHeaders())
+	resp, err := qrHTTPClient.Do(req)
 	if err != nil {
 		return &QRLoginStatus{Status: "error", Message: "网络请求失败"}, nil
 	}
@@ -122,13 +156,10 @@ func PollQRStatus(loginCode string) (*QRLoginStatus, error) {
 // Handles both string and numeric "code" in the QQ API response,
 // matching Node.js behavior which uses implicit type coercion.
 func getAuthCode(ticket string) (string, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
 	payload, _ := json.Marshal(map[string]string{"appid": farmAppID, "ticket": ticket})
 
-	req, _ := http.NewRequest("POST", "https://q.qq.com/ide/login", bytes.NewReader(payload))
-	req.Header = qqHeaders()
-
-	resp, err := client.Do(req)
+	req, _ := newQRRequest("POST", "https://q.qq.com/ide/login", bytes.NewReader(payload), qqHeaders())
+	resp, err := qrHTTPClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("请求登录接口失败: %w", err)
 	}
@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// qrReply is a canned response of a fake login server; status 0 means 200.
+type qrReply struct {
+	status int
+	body   string
+}
+
+// qrServer is a fake login provider answering by request path.
+type qrServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	replies  map[string]qrReply
+	requests []*http.Request
+}
+
+func newQRServer(t *testing.T) *qrServer {
+	t.Helper()
+	s := &qrServer{replies: map[string]qrReply{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		reply, ok := s.replies[r.URL.Path]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if reply.status != 0 {
+			w.WriteHeader(reply.status)
+		}
+		w.Write([]byte(reply.body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// reply sets the responses; a later call replaces them all.
+func (s *qrServer) reply(replies map[string]qrReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = replies
+	s.requests = nil
+}
+
+// last returns the last request made to path.
+func (s *qrServer) last(t *testing.T, path string) *http.Request {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].URL.Path == path {
+			return s.requests[i]
+		}
+	}
+	t.Fatalf("no request to %s", path)
+	return nil
+}
+
+// qrRedirect sends requests for the provider hosts to their fake servers.
+type qrRedirect map[string]*qrServer
+
+func (rt qrRedirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	s, ok := rt[req.URL.Host]
+	if !ok {
+		return nil, &url.Error{Op: req.Method, URL: req.URL.String(), Err: http.ErrNotSupported}
+	}
+	target, _ := url.Parse(s.URL)
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeQRProviders points the shared scan login client at one fake server per
+// provider for the duration of the test.
+func fakeQRProviders(t *testing.T) (qq, wx *qrServer) {
+	t.Helper()
+	qq, wx = newQRServer(t), newQRServer(t)
+	orig := qrHTTPClient
+	qrHTTPClient = &http.Client{Transport: qrRedirect{
+		"q.qq.com":                qq,
+		"open.weixin.qq.com":      wx,
+		"long.open.weixin.qq.com": wx,
+		"servicewechat.com":       wx,
+	}}
+	t.Cleanup(func() { qrHTTPClient = orig })
+	return qq, wx
+}
+
+func TestQQRequestQRCode(t *testing.T) {
+	qq, _ := fakeQRProviders(t)
+
+	qq.reply(map[string]qrReply{"/ide/devtoolAuth/GetLoginCode": {body: `{"code":0,"data":{"code":"qqcode1"}}`}})
+	res, err := RequestQRCode("qq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LoginCode != "qqcode1" || res.QRCodeURL != "https://h5.qzone.qq.com/qqq/code/qqcode1?_proxy=1&from=ide" {
+		t.Fatalf("result = %+v", res)
+	}
+	if req := qq.last(t, "/ide/devtoolAuth/GetLoginCode"); req.Header.Get("qua") != qua || req.Header.Get("user-agent") != chromeUA {
+		t.Errorf("request headers = %v", req.Header)
+	}
+
+	for _, body := range []string{`{"code":-1,"data":{}}`, `{"code":0,"data":{"code":""}}`, `<html>`} {
+		qq.reply(map[string]qrReply{"/ide/devtoolAuth/GetLoginCode": {body: body}})
+		if res, err := RequestQRCode("qq"); err == nil {
+			t.Errorf("%s: result %+v, want an error", body, res)
+		}
+	}
+}
+
+func TestQQPollQRStatus(t *testing.T) {
+	qq, _ := fakeQRProviders(t)
+	const poll, login = "/ide/devtoolAuth/syncScanSateGetTicket", "/ide/login"
+	scanned := qrReply{body: `{"code":0,"data":{"ok":1,"ticket":"tk1"}}`}
+
+	cases := []struct {
+		name    string
+		replies map[string]qrReply
+		want    QRLoginStatus
+	}{
+		{"waiting", map[string]qrReply{poll: {body: `{"code":0,"data":{"ok":0}}`}},
+			QRLoginStatus{Status: "wait"}},
+		{"expired", map[string]qrReply{poll: {body: `{"code":-10003}`}},
+			QRLoginStatus{Status: "expired"}},
+		{"error code", map[string]qrReply{poll: {body: `{"code":-5}`}},
+			QRLoginStatus{Status: "error", Message: "QQ返回错误码 -5"}},
+		{"http error", map[string]qrReply{poll: {status: http.StatusBadGateway}},
+			QRLoginStatus{Status: "error", Message: "QQ服务器返回 502"}},
+		{"malformed", map[string]qrReply{poll: {body: `not json`}},
+			QRLoginStatus{Status: "error", Message: "解析响应失败"}},
+		{"scanned, string code", map[string]qrReply{poll: scanned, login: {body: `{"code":"farm-abc"}`}},
+			QRLoginStatus{Status: "ok", Code: "farm-abc"}},
+		{"scanned, numeric code", map[string]qrReply{poll: scanned, login: {body: `{"code":12345}`}},
+			QRLoginStatus{Status: "ok", Code: "12345"}},
+		{"scanned, no code", map[string]qrReply{poll: scanned, login: {body: `{"code":0}`}},
+			QRLoginStatus{Status: "error", Message: `获取农场登录 code 失败 (响应: {"code":0})`}},
+		{"scanned, login http error", map[string]qrReply{poll: scanned, login: {status: http.StatusInternalServerError}},
+			QRLoginStatus{Status: "error", Message: "登录接口返回 HTTP 500"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			qq.reply(tc.replies)
+			got, err := PollQRStatus("qq", "qq code/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tc.want {
+				t.Fatalf("status = %+v, want %+v", *got, tc.want)
+			}
+			if q := qq.last(t, poll).URL.Query().Get("code"); q != "qq code/1" {
+				t.Errorf("polled code %q", q)
+			}
+			if tc.want.Status == "ok" {
+				req := qq.last(t, login)
+				if req.Method != http.MethodPost || !strings.Contains(req.Header.Get("content-type"), "json") {
+					t.Errorf("login request %s %v", req.Method, req.Header)
+				}
+			}
+		})
+	}
+}
+
+func TestQRCodeURLEscapesCode(t *testing.T) {
+	if got, want := QRCodeURL("qq", "a/../b?x"), "https://h5.qzone.qq.com/qqq/code/a%2F..%2Fb%3Fx?_proxy=1&from=ide"; got != want {
+		t.Errorf("qq = %s, want %s", got, want)
+	}
+	if got, want := QRCodeURL("wx", "u&redirect=x"), wxQRConfirmURL+"u%26redirect%3Dx"; got != want {
+		t.Errorf("wx = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,273 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/internal/store"
+)
+
+const (
+	qrSessionTTL       = 3 * time.Minute  // scan login codes expire server-side around this
+	qrSessionRetention = 10 * time.Minute // finished sessions stay readable for the UI
+	qrPollInterval     = 2 * time.Second
+	maxQRSessionsUser  = 3 // concurrent pending sessions per user
+)
+
+// ErrTooManyQRSessions is returned when a user has too many pending sessions.
+var ErrTooManyQRSessions = errors.New("too many pending QR login sessions")
+
+// QRSession is a scan login polled in the background until it succeeds or
+// expires. On success the auth code is saved to the account.
+type QRSession struct {
+	ID        string    `json:"id"`
+	AccountID int64     `json:"account_id"`
+	Platform  string    `json:"platform"`
+	LoginCode string    `json:"login_code"`
+	QRCodeURL string    `json:"qr_code_url"`
+	Status    string    `json:"status"` // "wait", "ok", "expired", "error"
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	audit  model.AuditEntry // recorded on success (user, IP of the requester)
+	cancel chan struct{}
+}
+
+// UserID returns the user who started the session.
+func (qs *QRSession) UserID() int64 {
+	return qs.audit.UserID
+}
+
+func (qs *QRSession) done() bool {
+	return qs.Status != "wait"
+}
+
+// QRSessions runs scan login sessions independently of any browser tab.
+type QRSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*QRSession
+	store    store.Store
+	logger   *Logger
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	onLogin  func(account *model.Account) // called after the new code is saved
+}
+
+func NewQRSessions(s store.Store, logger *Logger) *QRSessions {
+	return &QRSessions{
+		sessions: make(map[string]*QRSession),
+		store:    s,
+		logger:   logger,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// OnLogin sets a callback run after a successful scan saved the new code.
+func (q *QRSessions) OnLogin(fn func(account *model.Account)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onLogin = fn
+}
+
+// Start requests a QR code for the account and polls it in the background.
+// A pending session of the same account is cancelled. audit carries the
+// requester and is recorded with action AuditQRLogin once the scan succeeds.
+func (q *QRSessions) Start(account *model.Account, audit model.AuditEntry) (*QRSession, error) {
+	q.mu.Lock()
+	q.sweepLocked()
+	pending := 0
+	for _, qs := range q.sessions {
+		if qs.done() {
+			continue
+		}
+		if qs.AccountID == account.ID {
+			q.finishLocked(qs, "error", "已被新的扫码会话取代")
+			continue
+		}
+		if qs.audit.UserID == audit.UserID {
+			pending++
+		}
+	}
+	if pending >= maxQRSessionsUser {
+		q.mu.Unlock()
+		return nil, ErrTooManyQRSessions
+	}
+	// Reserve the slot under the same lock as the count; the code is filled
+	// in once requested
+	now := time.Now()
+	audit.Action = model.AuditQRLogin
+	audit.AccountID = account.ID
+	qs := &QRSession{
+		ID:        newQRSessionID(),
+		AccountID: account.ID,
+		Platform:  account.Platform,
+		Status:    "wait",
+		CreatedAt: now,
+		ExpiresAt: now.Add(qrSessionTTL),
+		audit:     audit,
+		cancel:    make(chan struct{}),
+	}
+	q.sessions[qs.ID] = qs
+	q.mu.Unlock()
+
+	result, err := RequestQRCode(account.Platform)
+	q.mu.Lock()
+	if err != nil {
+		delete(q.sessions, qs.ID)
+		q.mu.Unlock()
+		return nil, err
+	}
+	qs.LoginCode = result.LoginCode
+	qs.QRCodeURL = result.QRCodeURL
+	// A newer session of the account may have replaced this one meanwhile
+	superseded := qs.done()
+	q.mu.Unlock()
+
+	if !superseded {
+		q.wg.Add(1)
+		go q.poll(qs)
+	}
+	return q.Get(qs.ID), nil
+}
+
+// Get returns a snapshot of the session, or nil if unknown or swept.
+func (q *QRSessions) Get(id string) *QRSession {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qs, ok := q.sessions[id]
+	if !ok {
+		return nil
+	}
+	snapshot := *qs
+	return &snapshot
+}
+
+// Pending returns a snapshot of the account's session still waiting for a
+// scan, or nil if there is none.
+func (q *QRSessions) Pending(accountID int64) *QRSession {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, qs := range q.sessions {
+		if qs.AccountID == accountID && !qs.done() && qs.LoginCode != "" {
+			snapshot := *qs
+			return &snapshot
+		}
+	}
+	return nil
+}
+
+// Stop cancels all polling and waits for the goroutines to exit.
+func (q *QRSessions) Stop() {
+	q.mu.Lock()
+	select {
+	case <-q.stopCh:
+	default:
+		close(q.stopCh)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *QRSessions) poll(qs *QRSession) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(qrPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-qs.cancel:
+			return
+		case <-ticker.C:
+		}
+
+		if time.Now().After(qs.ExpiresAt) {
+			q.finish(qs, "expired", "")
+			return
+		}
+		status, err := PollQRStatus(qs.Platform, qs.LoginCode)
+		if err != nil {
+			status = &QRLoginStatus{Status: "error", Message: err.Error()}
+		}
+		switch status.Status {
+		case "ok":
+			q.complete(qs, status.Code)
+			return
+		case "expired":
+			q.finish(qs, "expired", "")
+			return
+		case "error":
+			// Network hiccups are retried until the session expires
+			q.mu.Lock()
+			qs.Message = status.Message
+			q.mu.Unlock()
+		}
+	}
+}
+
+// complete saves the auth code to the account and records the audit entry.
+func (q *QRSessions) complete(qs *QRSession, code string) {
+	q.mu.Lock()
+	superseded := qs.done()
+	q.mu.Unlock()
+	if superseded {
+		return
+	}
+	account, err := q.store.GetAccount(context.Background(), qs.AccountID)
+	if err == nil {
+		account.Code = code
+		err = q.store.UpdateAccount(context.Background(), account)
+	}
+	if err != nil {
+		q.logger.Warnf("扫码", "账号 #%d 保存登录 code 失败: %v", qs.AccountID, err)
+		q.finish(qs, "error", "保存登录 code 失败")
+		return
+	}
+	_ = q.store.AddAudit(context.Background(), &qs.audit)
+	q.logger.Infof("扫码", "账号 #%d 扫码登录成功, 已保存登录 code", qs.AccountID)
+	q.finish(qs, "ok", "")
+
+	q.mu.Lock()
+	onLogin := q.onLogin
+	q.mu.Unlock()
+	if onLogin != nil {
+		onLogin(account)
+	}
+}
+
+func (q *QRSessions) finish(qs *QRSession, status, message string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finishLocked(qs, status, message)
+}
+
+func (q *QRSessions) finishLocked(qs *QRSession, status, message string) {
+	if qs.done() {
+		return
+	}
+	qs.Status = status
+	qs.Message = message
+	close(qs.cancel)
+}
+
+// sweepLocked drops finished sessions past their retention.
+func (q *QRSessions) sweepLocked() {
+	cutoff := time.Now().Add(-qrSessionRetention)
+	for id, qs := range q.sessions {
+		if qs.done() && qs.ExpiresAt.Before(cutoff) {
+			delete(q.sessions, id)
+		}
+	}
+}
+
+func newQRSessionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"qq-farm-bot/internal/model"
+)
+
+func TestQRSessionsConcurrentStartsKeepCap(t *testing.T) {
+	qq, _ := fakeQRProviders(t)
+	qq.reply(map[string]qrReply{"/ide/devtoolAuth/GetLoginCode": {body: `{"code":0,"data":{"code":"qqcode1"}}`}})
+	q := NewQRSessions(nil, quietLogger())
+	t.Cleanup(q.Stop)
+
+	const starts = 10
+	errs := make([]error, starts)
+	var wg sync.WaitGroup
+	for i := range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			account := &model.Account{ID: int64(i + 1), Platform: "qq"}
+			_, errs[i] = q.Start(account, model.AuditEntry{UserID: 7})
+		}()
+	}
+	wg.Wait()
+
+	started := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			started++
+		case !errors.Is(err, ErrTooManyQRSessions):
+			t.Fatalf("Start: %v", err)
+		}
+	}
+	if started != maxQRSessionsUser {
+		t.Fatalf("%d concurrent sessions started, want %d", started, maxQRSessionsUser)
+	}
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/proto/plantpb"
+)
+
+// onLevelUp re-plans the farm for the new level. The caller runs a farm
+// cycle right after, which unlocks newly affordable lands and plants the
+// lands freed here with the new best seed.
+func (f *FarmWorker) onLevelUp() {
+	_, level, _, _, _ := f.net.state.Get()
+	landsReply, err := f.net.AllLands()
+	if err != nil {
+		return
+	}
+	unlocked := 0
+	for _, land := range landsReply.Lands {
+		if land.Unlocked {
+			unlocked++
+		}
+	}
+	available, err := f.seedShopCandidates()
+	if err != nil {
+		return
+	}
+	plan, err := f.bestSeedPlan(available, unlocked)
+	if err != nil {
+		return
+	}
+	best := plan.goods
+	f.logger.Infof("升级", "Lv%d 推荐种子: %s", level, f.gc.GetPlantNameBySeedID(int(best.ItemId)))
+	if !f.cfg.ReplantOnLevelUp || !f.cfg.EnablePlant {
+		return
+	}
+	// A seed below the ForceLowest floors is no better than what's growing
+	if plan.belowFloor {
+		f.logger.Infof("升级", "推荐种子未达到最低经验/最高价格要求, 不铲除作物改种")
+		return
+	}
+
+	ids := f.outclassedLands(landsReply.Lands, int(best.ItemId))
+	if len(ids) == 0 {
+		return
+	}
+	desc := f.descLands(ids, buildLandMap(landsReply.Lands))
+	freed, err := f.removePlantAndCollectFreed(ids)
+	if err != nil {
+		f.logger.Warnf("升级", "铲除 %s 失败: %v", desc, err)
+		return
+	}
+	for _, id := range freed {
+		delete(f.fertilized, id)
+	}
+	f.logger.Infof("升级", "铲除 %d 块刚种下的作物改种 %s: %s", len(ids), f.gc.GetPlantNameBySeedID(int(best.ItemId)), desc)
+	f.sc.RecordActivity(model.ActivityReplant, map[string]any{
+		"level": level, "seed_id": best.ItemId, "lands": ids,
+	})
+}
+
+// outclassedLands returns the lands still in their first growth phase whose
+// crop's exp/hour trails seedID's by more than ReplantMarginPct percent.
+// Kept, multi-tile and later-season crops are left alone.
+func (f *FarmWorker) outclassedLands(lands []*plantpb.LandInfo, seedID int) []int64 {
+	rates := make(map[int]float64)
+	for _, yr := range f.gc.GetSeedYieldRows() {
+		rates[yr.SeedID] = yr.FarmExpPerHourNormal
+	}
+	bestRate := rates[seedID]
+	if bestRate <= 0 {
+		return nil
+	}
+	keep := f.gc.CropFilter(f.cfg.NoHarvestCropIDs)
+	threshold := bestRate / (1 + float64(f.cfg.ReplantMarginPct)/100)
+	nowSec := f.net.clock.Now().Unix()
+
+	var ids []int64
+	for _, land := range lands {
+		p := land.Plant
+		if !land.Unlocked || p == nil || len(p.Phases) == 0 || p.GetSeason() > 1 {
+			continue
+		}
+		plantID := int(p.Id)
+		curSeed := f.gc.GetSeedIDForCrop(plantID)
+		if curSeed == 0 || curSeed == seedID || keep[plantID] || f.gc.GetPlantSize(plantID) > 1 {
+			continue
+		}
+		if !inFirstPhase(p.Phases, nowSec) {
+			continue
+		}
+		if rate, ok := rates[curSeed]; ok && rate < threshold {
+			ids = append(ids, land.Id)
+		}
+	}
+	return ids
+}
+
+// inFirstPhase reports whether the earliest phase is the current one.
+func inFirstPhase(phases []*plantpb.PlantPhaseInfo, nowSec int64) bool {
+	cur := getCurrentPhase(phases, nowSec)
+	if cur == nil {
+		return false
+	}
+	first := phases[0]
+	for _, p := range phases[1:] {
+		if toTimeSec(p.BeginTime) < toTimeSec(first.BeginTime) {
+			first = p
+		}
+	}
+	return cur == first
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"errors"
+	"path"
+
+	"qq-farm-bot/internal/model"
+)
+
+// ErrRPCDenied is returned for requests matching the account's RPC denylist.
+var ErrRPCDenied = errors.New("rpc denied by denylist")
+
+// defaultDeniedRPCs are refused for every account whatever its settings:
+// the bot must never put weeds or insects on someone else's farm.
+var defaultDeniedRPCs = []string{
+	"gamepb.plantpb.PlantService.PutWeeds",
+	"gamepb.plantpb.PlantService.PutInsects",
+}
+
+// rpcGuard is the denylist checked before every outgoing request. It also
+// serves as a kill switch for experimental features: deny their methods and
+// the code paths fail closed.
+type rpcGuard struct {
+	patterns []string
+}
+
+// newRPCGuard builds the denylist of the defaults plus the account's
+// patterns (see model.ParseRPCPatterns).
+func newRPCGuard(accountPatterns string) *rpcGuard {
+	patterns := append([]string{}, defaultDeniedRPCs...)
+	return &rpcGuard{patterns: append(patterns, model.ParseRPCPatterns(accountPatterns)...)}
+}
+
+// denied returns the pattern that blocks service.method, if any.
+func (g *rpcGuard) denied(service, method string) (string, bool) {
+	if g == nil {
+		return "", false
+	}
+	name := service + "." + method
+	for _, p := range g.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
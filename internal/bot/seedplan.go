@@ -0,0 +1,341 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"qq-farm-bot/internal/model"
+	"qq-farm-bot/proto/shoppb"
+)
+
+// Rules that can pick the seed, reported in SeedPlan.Rule.
+const (
+	SeedRuleConserve    = "conserve"
+	SeedRuleWarmUp      = "warm_up"
+	SeedRulePlantCropID = "plant_crop_id"
+	SeedRuleDoubleExp   = "double_exp"
+	SeedRuleStrategy    = "strategy"
+	SeedRuleForceLowest = "force_lowest"
+	SeedRuleEfficiency  = "efficiency"
+	SeedRuleLevel       = "level"
+)
+
+// Reasons a shop seed was left out, reported in SeedElimination.Reason.
+const (
+	SeedOutLocked      = "locked"
+	SeedOutLevel       = "level"
+	SeedOutLimit       = "limit"
+	SeedOutPlantCropID = "plant_crop_id"
+	SeedOutForceLowest = "force_lowest"
+)
+
+// Seed data sources, reported in SeedPlan.Source.
+const (
+	SeedSourceShop   = "shop"   // the bot's last seed shop reply
+	SeedSourceConfig = "config" // game config shop data; purchase limits unknown
+)
+
+// planTopCandidates is how many ranked candidates a SeedPlan lists.
+const planTopCandidates = 5
+
+// SeedPlanEntry is one buyable seed with its farm-wide yield rates.
+type SeedPlanEntry struct {
+	SeedID        int     `json:"seed_id"`
+	GoodsID       int64   `json:"goods_id,omitempty"`
+	Name          string  `json:"name"`
+	RequiredLevel int64   `json:"required_level"`
+	Price         int64   `json:"price"`
+	ExpPerHour    float64 `json:"exp_per_hour"`
+	GoldPerHour   float64 `json:"gold_per_hour"`
+}
+
+// SeedElimination is a shop seed a filter ruled out.
+type SeedElimination struct {
+	SeedID int    `json:"seed_id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// SeedPlan is the seed the farm worker buys next and why.
+type SeedPlan struct {
+	Level      int64             `json:"level"`
+	LandCount  int               `json:"land_count"`
+	Source     string            `json:"source"`
+	ShopAt     *time.Time        `json:"shop_at,omitempty"` // when the shop data was fetched
+	Degraded   bool              `json:"degraded,omitempty"`
+	Rule       string            `json:"rule,omitempty"`
+	Chosen     *SeedPlanEntry    `json:"chosen,omitempty"`
+	Candidates []SeedPlanEntry   `json:"candidates"` // best exp/hour first
+	Eliminated []SeedElimination `json:"eliminated"`
+	Notes      []string          `json:"notes,omitempty"`
+
+	goods      *shoppb.GoodsInfo // the chosen shop goods
+	belowFloor bool              // ForceLowest found no seed passing its floors
+}
+
+// seedPlanInput is everything planSeed decides from.
+type seedPlanInput struct {
+	cfg           *BotConfig
+	conserve      bool // gold below the reserve: cheapest seed only
+	level         int64
+	landsCount    int
+	available     []shopSeedCandidate
+	eliminated    []SeedElimination
+	expMultiplier float64
+	// fastestLevelUp resolves the fastest_levelup strategy from live land
+	// state; nil approximates it with the best exp rate.
+	fastestLevelUp func([]shopSeedCandidate) *shoppb.GoodsInfo
+}
+
+// planSeed picks the seed to buy from in.available, in order: conserve,
+// warm-up, level only without plant data, PlantCropID, double exp, strategy,
+// ForceLowest, efficiency recommendation, level.
+func (gc *GameConfig) planSeed(in seedPlanInput) *SeedPlan {
+	plan := &SeedPlan{
+		Level:      in.level,
+		LandCount:  in.landsCount,
+		Eliminated: append([]SeedElimination{}, in.eliminated...),
+	}
+	entries := gc.seedPlanEntries(in.available, in.landsCount)
+	plan.Candidates = entries
+	if len(plan.Candidates) > planTopCandidates {
+		plan.Candidates = plan.Candidates[:planTopCandidates]
+	}
+	if len(in.available) == 0 {
+		plan.Notes = append(plan.Notes, "没有可购买的种子")
+		return plan
+	}
+	cfg := in.cfg
+	choose := func(rule string, goods *shoppb.GoodsInfo) *SeedPlan {
+		plan.Rule, plan.goods = rule, goods
+		for i := range entries {
+			if entries[i].SeedID == int(goods.ItemId) {
+				plan.Chosen = &entries[i]
+			}
+		}
+		return plan
+	}
+	drop := func(reason string, keep func(shopSeedCandidate) bool) {
+		for _, c := range in.available {
+			if !keep(c) {
+				plan.Eliminated = append(plan.Eliminated, gc.seedOut(c.goods, reason))
+			}
+		}
+	}
+
+	if in.conserve {
+		return choose(SeedRuleConserve, cheapestSeed(in.available))
+	}
+	if cfg.WarmUp {
+		return choose(SeedRuleWarmUp, cheapestSeed(in.available))
+	}
+	// Without Plant.json no exp/price data exists for crop ID, strategy or
+	// efficiency rules
+	if gc.Degraded() {
+		plan.Degraded = true
+		return choose(SeedRuleLevel, levelBasedSeed(in.available, in.level))
+	}
+
+	if cfg.PlantCropID > 0 {
+		if targetSeedID := gc.GetSeedIDForCrop(cfg.PlantCropID); targetSeedID > 0 {
+			for _, c := range in.available {
+				if int(c.goods.ItemId) == targetSeedID {
+					drop(SeedOutPlantCropID, func(o shopSeedCandidate) bool { return o.goods == c.goods })
+					return choose(SeedRulePlantCropID, c.goods)
+				}
+			}
+			plan.Notes = append(plan.Notes, fmt.Sprintf("指定作物(ID:%d)的种子不可购买，使用自动选择", cfg.PlantCropID))
+		}
+	}
+	// During double exp the exp rate is all that matters, whatever the seed costs
+	if in.expMultiplier > 1 {
+		if best := gc.maxExpRateSeed(in.available); best != nil {
+			return choose(SeedRuleDoubleExp, best)
+		}
+	}
+	if strategy := ParsePlantingStrategy(cfg.PlantingStrategy); strategy != nil {
+		fastest := in.fastestLevelUp
+		if fastest == nil && strategy.Mode == StrategyModeFastestLevelUp {
+			plan.Notes = append(plan.Notes, "最快升级策略需要实时土地数据, 按经验效率近似")
+			fastest = gc.maxExpRateSeed
+		}
+		if result := gc.strategySeed(strategy, in.available, fastest); result != nil {
+			return choose(SeedRuleStrategy, result)
+		}
+		plan.Notes = append(plan.Notes, "策略筛选无匹配作物，回退默认选择")
+	}
+
+	if cfg.ForceLowest {
+		cands := make([]SeedCandidate, len(in.available))
+		for i, c := range in.available {
+			cands[i] = SeedCandidate{
+				RequiredLevel: int(c.requiredLevel),
+				Price:         int(c.goods.Price),
+				ExpPerHarvest: gc.GetPlantExpBySeedID(int(c.goods.ItemId)),
+			}
+		}
+		i, ok := pickLowestSeed(cands, cfg.ForceLowestMinExp, cfg.ForceLowestMaxPrice)
+		if ok {
+			drop(SeedOutForceLowest, func(c shopSeedCandidate) bool {
+				exp := gc.GetPlantExpBySeedID(int(c.goods.ItemId))
+				return (cfg.ForceLowestMinExp <= 0 || exp >= cfg.ForceLowestMinExp) &&
+					(cfg.ForceLowestMaxPrice <= 0 || int(c.goods.Price) <= cfg.ForceLowestMaxPrice)
+			})
+		} else {
+			plan.belowFloor = true
+			plan.Notes = append(plan.Notes, fmt.Sprintf("没有满足最低经验(%d)/最高价格(%d)的种子，使用最低等级种子",
+				cfg.ForceLowestMinExp, cfg.ForceLowestMaxPrice))
+		}
+		return choose(SeedRuleForceLowest, in.available[i].goods)
+	}
+
+	for _, r := range gc.GetPlantingRecommendation(int(in.level), in.landsCount, 50) {
+		for _, c := range in.available {
+			if c.goods.ItemId == int64(r.SeedID) {
+				return choose(SeedRuleEfficiency, c.goods)
+			}
+		}
+	}
+	return choose(SeedRuleLevel, levelBasedSeed(in.available, in.level))
+}
+
+// seedPlanEntries describes the available seeds, best exp rate first.
+func (gc *GameConfig) seedPlanEntries(available []shopSeedCandidate, landsCount int) []SeedPlanEntry {
+	if landsCount <= 0 {
+		landsCount = defaultYieldLands
+	}
+	yields := make(map[int]SeedYieldRow)
+	for _, yr := range gc.GetSeedYieldTable(landsCount) {
+		yields[yr.SeedID] = yr
+	}
+	entries := make([]SeedPlanEntry, 0, len(available))
+	for _, c := range available {
+		seedID := int(c.goods.ItemId)
+		yr := yields[seedID]
+		entries = append(entries, SeedPlanEntry{
+			SeedID:        seedID,
+			GoodsID:       c.goods.Id,
+			Name:          gc.GetPlantNameBySeedID(seedID),
+			RequiredLevel: c.requiredLevel,
+			Price:         c.goods.Price,
+			ExpPerHour:    yr.FarmExpPerHourNormal,
+			GoldPerHour:   yr.FarmGoldPerHourFert,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ExpPerHour > entries[j].ExpPerHour })
+	return entries
+}
+
+func (gc *GameConfig) seedOut(goods *shoppb.GoodsInfo, reason string) SeedElimination {
+	return SeedElimination{SeedID: int(goods.ItemId), Name: gc.GetPlantNameBySeedID(int(goods.ItemId)), Reason: reason}
+}
+
+// seedShopSnapshot keeps the last seed shop result of a farm worker for
+// plant-plan requests, which run outside the farm goroutine.
+type seedShopSnapshot struct {
+	mu         sync.Mutex
+	available  []shopSeedCandidate
+	eliminated []SeedElimination
+	at         time.Time
+}
+
+func (s *seedShopSnapshot) set(available []shopSeedCandidate, eliminated []SeedElimination, at time.Time) {
+	s.mu.Lock()
+	s.available, s.eliminated, s.at = available, eliminated, at
+	s.mu.Unlock()
+}
+
+func (s *seedShopSnapshot) get() ([]shopSeedCandidate, []SeedElimination, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.available, s.eliminated, s.at
+}
+
+// seedPlan explains the choice the farm worker would make now from its last
+// seed shop reply, or returns nil if it hasn't fetched the shop yet.
+func (f *FarmWorker) seedPlan() *SeedPlan {
+	available, eliminated, at := f.shop.get()
+	if at.IsZero() {
+		return nil
+	}
+	_, level, _, _, _ := f.net.state.Get()
+	_, unlocked, _ := f.lands.Get()
+	plan := f.gc.planSeed(seedPlanInput{
+		cfg:            f.profile.Peek(level),
+		conserve:       f.gate.Conserving(),
+		level:          level,
+		landsCount:     unlocked,
+		available:      available,
+		eliminated:     eliminated,
+		expMultiplier:  f.events.ExpMultiplier(),
+		fastestLevelUp: f.fastestLevelUpSeed,
+	})
+	plan.Source, plan.ShopAt = SeedSourceShop, &at
+	return plan
+}
+
+// PlantPlan explains which seed the account's bot would buy now. A bot that
+// has fetched the seed shop answers from that reply; otherwise the game
+// config shop data is used with the last known level and land count.
+func (m *Manager) PlantPlan(ctx context.Context, account *model.Account) *SeedPlan {
+	var level int64
+	var lands int
+	if inst := m.GetInstance(account.ID); inst != nil {
+		inst.mu.RLock()
+		farm, net := inst.farm, inst.net
+		inst.mu.RUnlock()
+		if farm != nil {
+			if plan := farm.seedPlan(); plan != nil {
+				return plan
+			}
+		}
+		if net != nil {
+			_, level, _, _, _ = net.state.Get()
+		}
+		_, lands, _ = inst.lands.Get()
+	}
+	if level == 0 && m.store != nil {
+		since := GameDate(time.Now().AddDate(0, 0, -30))
+		if days, err := m.store.GetDailySummaries(ctx, []int64{account.ID}, since); err == nil && len(days) > 0 {
+			level = days[len(days)-1].Level
+		}
+	}
+	return planSeedFromConfig(GetGameConfig(), account, level, lands)
+}
+
+// planSeedFromConfig plans from the game config's seed shop data, for bots
+// that have no seed shop reply. Purchase limits and locked goods are unknown.
+func planSeedFromConfig(gc *GameConfig, account *model.Account, level int64, lands int) *SeedPlan {
+	var notes []string
+	if level <= 0 {
+		notes = append(notes, "等级未知, 按 1 级计算")
+		level = 1
+	}
+	if lands <= 0 {
+		notes = append(notes, fmt.Sprintf("土地数未知, 按 %d 块计算", defaultYieldLands))
+		lands = defaultYieldLands
+	}
+	var available []shopSeedCandidate
+	var eliminated []SeedElimination
+	for _, yr := range gc.GetSeedYieldRows() {
+		goods := &shoppb.GoodsInfo{ItemId: int64(yr.SeedID), Price: int64(yr.Price), Unlocked: true}
+		if int64(yr.RequiredLevel) > level {
+			eliminated = append(eliminated, gc.seedOut(goods, SeedOutLevel))
+			continue
+		}
+		available = append(available, shopSeedCandidate{goods: goods, requiredLevel: int64(yr.RequiredLevel)})
+	}
+	plan := gc.planSeed(seedPlanInput{
+		cfg:        NewProfile(newBotConfig(account, "", ""), nil).Peek(level),
+		level:      level,
+		landsCount: lands,
+		available:  available,
+		eliminated: eliminated,
+	})
+	plan.Source = SeedSourceConfig
+	plan.Notes = append(notes, plan.Notes...)
+	return plan
+}
@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"qq-farm-bot/proto/shoppb"
+)
+
+func TestPlanSeedForceLowestFallback(t *testing.T) {
+	gc := newGameConfig()
+	gc.load(writeSmallGameConfig(t))
+	// small-a gives 5 exp per season, small-b 8
+	available := []shopSeedCandidate{
+		{goods: &shoppb.GoodsInfo{Id: 1, ItemId: 20001, Price: 10, Unlocked: true}, requiredLevel: 1},
+		{goods: &shoppb.GoodsInfo{Id: 2, ItemId: 20002, Price: 20, Unlocked: true}, requiredLevel: 2},
+	}
+	plan := func(minExp int) *SeedPlan {
+		return gc.planSeed(seedPlanInput{
+			cfg:       &BotConfig{ForceLowest: true, ForceLowestMinExp: minExp},
+			level:     10,
+			available: available,
+		})
+	}
+
+	p := plan(6)
+	if p.Rule != SeedRuleForceLowest || p.goods.ItemId != 20002 || p.belowFloor || len(p.Notes) != 0 {
+		t.Fatalf("min exp 6: rule %s, seed %d, below floor %v, notes %v; want small-b",
+			p.Rule, p.goods.ItemId, p.belowFloor, p.Notes)
+	}
+
+	p = plan(9)
+	if p.Rule != SeedRuleForceLowest || p.goods.ItemId != 20001 || !p.belowFloor {
+		t.Fatalf("min exp 9: rule %s, seed %d, below floor %v; want small-a below the floor",
+			p.Rule, p.goods.ItemId, p.belowFloor)
+	}
+	if len(p.Notes) != 1 || !strings.Contains(p.Notes[0], "最低经验(9)") {
+		t.Fatalf("min exp 9: notes = %v, want the fallback note", p.Notes)
+	}
+}
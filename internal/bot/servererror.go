@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+)
+
+// serverErrorClass groups business errors by how workers react to them.
+type serverErrorClass int
+
+const (
+	errClassOther        serverErrorClass = iota
+	errClassBagFull                       // no room in the bag for harvested or stolen fruit
+	errClassLimitReached                  // daily or purchase limit used up
+	errClassNotFriend                     // the target is no longer a friend
+	errClassProtected                     // the farm is guarded (dog, protection period)
+	errClassPriceChanged                  // the shop price differs from the one sent
+	errClassNeedRelogin                   // the login code expired; only a new code helps
+)
+
+type serverErrorRule struct {
+	class    serverErrorClass
+	codes    []int64
+	messages []string
+}
+
+// serverErrorRules maps the gate's business errors to their class, first
+// match wins. codes holds the numeric codes confirmed for a class; until a
+// code is known the message fragments catch it. Teaching every worker a new
+// error is one row (or one code) here.
+var serverErrorRules = []serverErrorRule{
+	{errClassBagFull, nil, []string{"背包已满", "仓库已满", "背包空间不足", "仓库空间不足"}},
+	{errClassNeedRelogin, nil, []string{"登录过期", "登录已过期", "登录失效", "重新登录", "code无效", "code已失效"}},
+	{errClassNotFriend, nil, []string{"不是好友", "非好友", "好友不存在"}},
+	{errClassProtected, nil, []string{"被狗", "狗咬", "保护期", "受保护"}},
+	{errClassPriceChanged, nil, []string{"价格已变", "价格变化", "价格错误", "价格不一致"}},
+	{errClassLimitReached, nil, []string{"上限", "已达限购", "次数已用完", "次数不足", "今日已"}},
+}
+
+// class returns how workers should handle the error.
+func (e *ServerError) class() serverErrorClass {
+	for _, rule := range serverErrorRules {
+		for _, code := range rule.codes {
+			if e.Code == code {
+				return rule.class
+			}
+		}
+	}
+	for _, rule := range serverErrorRules {
+		for _, m := range rule.messages {
+			if strings.Contains(e.Message, m) {
+				return rule.class
+			}
+		}
+	}
+	return errClassOther
+}
+
+// asServerError returns the ServerError in err's chain, or nil. The Is*
+// methods accept the nil result, so call sites read
+// asServerError(err).IsBagFull().
+func asServerError(err error) *ServerError {
+	var se *ServerError
+	if errors.As(err, &se) {
+		return se
+	}
+	return nil
+}
+
+func (e *ServerError) is(class serverErrorClass) bool { return e != nil && e.class() == class }
+
+// IsBagFull reports whether the bag had no room for the items.
+func (e *ServerError) IsBagFull() bool { return e.is(errClassBagFull) }
+
+// IsLimitReached reports whether a daily or purchase limit is used up.
+func (e *ServerError) IsLimitReached() bool { return e.is(errClassLimitReached) }
+
+// IsNotFriend reports whether the target player is no longer a friend.
+func (e *ServerError) IsNotFriend() bool { return e.is(errClassNotFriend) }
+
+// IsProtected reports whether the target farm is guarded.
+func (e *ServerError) IsProtected() bool { return e.is(errClassProtected) }
+
+// IsPriceChanged reports whether the shop price moved since ShopInfo.
+func (e *ServerError) IsPriceChanged() bool { return e.is(errClassPriceChanged) }
+
+// IsNeedRelogin reports whether the login code is no longer accepted.
+func (e *ServerError) IsNeedRelogin() bool { return e.is(errClassNeedRelogin) }
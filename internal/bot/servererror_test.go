@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestServerErrorClass(t *testing.T) {
+	cases := []struct {
+		code    int64
+		message string
+		want    serverErrorClass
+	}{
+		{1000001, "背包已满", errClassBagFull},
+		{1000001, "仓库空间不足,请先出售", errClassBagFull},
+		{1000002, "登录已过期,请重新登录", errClassNeedRelogin},
+		{1000003, "对方不是好友", errClassNotFriend},
+		{1000004, "被狗咬了", errClassProtected},
+		{1000004, "农场处于保护期", errClassProtected},
+		{1000005, "商品价格已变化", errClassPriceChanged},
+		{1000006, "今日购买已达上限", errClassLimitReached},
+		{1000006, "偷菜次数已用完", errClassLimitReached},
+		{1000007, "参数错误", errClassOther},
+		{1000008, "", errClassOther},
+		// First rule wins when a message matches several
+		{1000009, "背包已满, 今日已无法领取", errClassBagFull},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%d %s", tc.code, tc.message), func(t *testing.T) {
+			if got := (&ServerError{Code: tc.code, Message: tc.message}).class(); got != tc.want {
+				t.Errorf("class = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServerErrorClassByCode(t *testing.T) {
+	saved := serverErrorRules
+	t.Cleanup(func() { serverErrorRules = saved })
+	serverErrorRules = append([]serverErrorRule{{errClassLimitReached, []int64{1000020}, nil}}, saved...)
+
+	// A known code wins over the message
+	if got := (&ServerError{Code: 1000020, Message: "背包已满"}).class(); got != errClassLimitReached {
+		t.Errorf("code 1000020 class = %d, want limit reached", got)
+	}
+	if got := (&ServerError{Code: 1000021, Message: "背包已满"}).class(); got != errClassBagFull {
+		t.Errorf("unknown code class = %d, want bag full from the message", got)
+	}
+}
+
+func TestAsServerError(t *testing.T) {
+	wrapped := fmt.Errorf("收获: %w", &ServerError{Code: 1, Message: "背包已满"})
+	if !asServerError(wrapped).IsBagFull() {
+		t.Error("wrapped bag-full error not recognized")
+	}
+	// The Is* methods take the nil of a non-server error
+	for _, err := range []error{nil, errors.New("背包已满"), ErrThrottled} {
+		se := asServerError(err)
+		if se != nil || se.IsBagFull() || se.IsLimitReached() || se.IsNeedRelogin() {
+			t.Errorf("%v classified as a server error", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/shoppb"
+)
+
+// seedShopID is the ShopInfo shop id of the seed shop.
+const seedShopID = 2
+
+// Session is a one-shot game connection for command line tools: connect, log
+// in, call services, close. Unlike Instance it runs no heartbeat, watchdog or
+// workers, so it is meant for a handful of requests right after login.
+type Session struct {
+	net    *Network
+	crypto *Crypto
+}
+
+// Dial connects to the game server and logs in. A nil logger prints to stdout
+// only.
+func Dial(serverURL, platform, clientVersion, code string, logger *Logger) (*Session, error) {
+	if logger == nil {
+		logger = NewLogger(SystemAccountID, nil, nil)
+	}
+	crypto, err := NewCrypto()
+	if err != nil {
+		logger.Warnf("连接", "WASM crypto 初始化失败: %v (消息体将不加密)", err)
+	}
+	s := &Session{net: NewNetwork(logger, crypto, nil), crypto: crypto}
+	if err := s.net.Connect(serverURL, platform, clientVersion, code); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	if err := s.net.Login(clientVersion); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	return s, nil
+}
+
+// Call sends a request and decodes the reply into reply.
+func (s *Session) Call(service, method string, req, reply proto.Message) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	replyBody, err := s.net.SendRequestWithRetry(service, method, body)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(replyBody, reply)
+}
+
+// State returns the logged-in user's state.
+func (s *Session) State() *UserState {
+	return s.net.State()
+}
+
+// SeedShopGoods fetches the current seed shop listing.
+func (s *Session) SeedShopGoods() ([]LiveSeedGoods, error) {
+	reply := &shoppb.ShopInfoReply{}
+	if err := s.Call("gamepb.shoppb.ShopService", "ShopInfo", &shoppb.ShopInfoRequest{ShopId: seedShopID}, reply); err != nil {
+		return nil, err
+	}
+	return liveSeedGoods(reply.GoodsList), nil
+}
+
+// Close closes the connection.
+func (s *Session) Close() {
+	s.net.Close()
+	if s.crypto != nil {
+		s.crypto.Close()
+	}
+}
+
+// liveSeedGoods extracts the fields the seed shop export needs from a
+// ShopInfo listing.
+func liveSeedGoods(goodsList []*shoppb.GoodsInfo) []LiveSeedGoods {
+	goods := make([]LiveSeedGoods, 0, len(goodsList))
+	for _, g := range goodsList {
+		var reqLevel int64
+		for _, cond := range g.Conds {
+			if cond.Type == 1 { // MIN_LEVEL
+				reqLevel = cond.Param
+			}
+		}
+		goods = append(goods, LiveSeedGoods{
+			GoodsID:       int(g.Id),
+			SeedID:        int(g.ItemId),
+			Price:         int(g.Price),
+			RequiredLevel: int(reqLevel),
+		})
+	}
+	return goods
+}
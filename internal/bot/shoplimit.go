@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"qq-farm-bot/proto/shoppb"
+)
+
+// maxSeedTypesPerPlant bounds how many seed types one planting pass buys
+// when purchase limits stop the first choice from covering every land.
+const maxSeedTypesPerPlant = 2
+
+// seedPurchases remembers seed shop purchases made by this worker so that
+// limited goods are not over-bought before the next ShopInfo reflects them.
+// Counts are absolute (the server's BoughtNum plus our purchases since) and
+// are dropped at the game-day reset, when the server's counts reset too.
+type seedPurchases struct {
+	day    string
+	bought map[int64]int64 // goods id -> bought count
+}
+
+func (p *seedPurchases) rollover(day string) {
+	if day != p.day || p.bought == nil {
+		p.day = day
+		p.bought = make(map[int64]int64)
+	}
+}
+
+// remaining returns how many more of goods may be bought on game day day,
+// or -1 when the goods has no purchase limit.
+func (p *seedPurchases) remaining(goods *shoppb.GoodsInfo, day string) int64 {
+	if goods.LimitCount <= 0 {
+		return -1
+	}
+	p.rollover(day)
+	return max(goods.LimitCount-max(goods.BoughtNum, p.bought[goods.Id]), 0)
+}
+
+// exhaust marks a limited goods as sold out for the day after the server
+// refused a purchase for its limit.
+func (p *seedPurchases) exhaust(goods *shoppb.GoodsInfo, day string) {
+	if goods.LimitCount <= 0 {
+		return
+	}
+	p.rollover(day)
+	p.bought[goods.Id] = goods.LimitCount
+}
+
+// record notes that n more of goods were bought.
+func (p *seedPurchases) record(goods *shoppb.GoodsInfo, n int64, day string) {
+	p.rollover(day)
+	p.bought[goods.Id] = max(goods.BoughtNum, p.bought[goods.Id]) + n
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/corepb"
+	"qq-farm-bot/proto/plantpb"
+	"qq-farm-bot/proto/shoppb"
+)
+
+// seedShopHandlers serve ShopInfo with goods and hand out each goods' seed
+// on BuyGoods.
+func seedShopHandlers(goods []*shoppb.GoodsInfo) map[string]gameHandler {
+	return map[string]gameHandler{
+		"ShopInfo": func([]byte) (proto.Message, int64) {
+			return &shoppb.ShopInfoReply{GoodsList: goods}, 0
+		},
+		"BuyGoods": func(body []byte) (proto.Message, int64) {
+			req := &shoppb.BuyGoodsRequest{}
+			proto.Unmarshal(body, req)
+			for _, g := range goods {
+				if g.Id == req.GoodsId {
+					return &shoppb.BuyGoodsReply{GetItems: []*corepb.Item{{Id: g.ItemId, Count: req.Num}}}, 0
+				}
+			}
+			return nil, 1000020
+		},
+	}
+}
+
+func TestBuyAndPlantPurchaseLimit(t *testing.T) {
+	// small-a (seed 20001) is the cheapest, so warm-up mode prefers it
+	limited := func(bought int64) *shoppb.GoodsInfo {
+		return &shoppb.GoodsInfo{Id: 1, ItemId: 20001, Price: 10, LimitCount: 5, BoughtNum: bought, Unlocked: true}
+	}
+	unlimited := &shoppb.GoodsInfo{Id: 2, ItemId: 20002, Price: 20, Unlocked: true}
+	type buy struct{ goods, num int64 }
+
+	cases := []struct {
+		name    string
+		goods   []*shoppb.GoodsInfo
+		buys    []buy
+		planted map[int64]int // seed -> lands
+	}{
+		{"split at the limit", []*shoppb.GoodsInfo{limited(0), unlimited},
+			[]buy{{1, 5}, {2, 13}}, map[int64]int{20001: 5, 20002: 13}},
+		{"part bought earlier today", []*shoppb.GoodsInfo{limited(3), unlimited},
+			[]buy{{1, 2}, {2, 16}}, map[int64]int{20001: 2, 20002: 16}},
+		{"capped without another seed", []*shoppb.GoodsInfo{limited(0)},
+			[]buy{{1, 5}}, map[int64]int{20001: 5}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gc := newGameConfig()
+			gc.load(writeSmallGameConfig(t))
+			f := newTestFarm(gc, time.Unix(testNow, 0))
+			f.cfg.WarmUp = true
+			f.net.state.SetFromLogin(1001, 10, 0, 5000, "farmer")
+			game := connectFakeGame(t, f.net, seedShopHandlers(tc.goods))
+
+			lands := make([]int64, 18)
+			for i := range lands {
+				lands[i] = int64(i + 1)
+			}
+			f.buyAndPlant(lands, len(lands))
+
+			var buys []buy
+			for _, body := range game.requests("BuyGoods") {
+				req := &shoppb.BuyGoodsRequest{}
+				proto.Unmarshal(body, req)
+				buys = append(buys, buy{req.GoodsId, req.Num})
+			}
+			if !reflect.DeepEqual(buys, tc.buys) {
+				t.Errorf("purchases = %v, want %v", buys, tc.buys)
+			}
+			planted := make(map[int64]int)
+			for _, body := range game.requests("Plant") {
+				req := &plantpb.PlantRequest{}
+				proto.Unmarshal(body, req)
+				planted[req.Items[0].SeedId] += len(req.Items[0].LandIds)
+			}
+			if !reflect.DeepEqual(planted, tc.planted) {
+				t.Errorf("planted = %v, want %v", planted, tc.planted)
+			}
+			if left := f.purchases.remaining(tc.goods[0], f.net.GameDate()); left != 0 {
+				t.Errorf("%d of the limited seed left after planting", left)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+
+	"qq-farm-bot/internal/store"
+)
+
+// Worker state keys and the format version of each blob. Bump a version
+// whenever its struct changes incompatibly; older blobs are then dropped.
+const (
+	stateKeyFertilizer     = "fertilizer"
+	stateVersionFertilizer = 1
+
+	stateKeyFertilizedLands     = "fertilized_lands"
+	stateVersionFertilizedLands = 1
+
+	stateKeyMatureNotified     = "mature_notified"
+	stateVersionMatureNotified = 1
+
+	stateKeyVisit     = "visit"
+	stateVersionVisit = 1
+)
+
+// stateBlob is the stored envelope of a worker checkpoint.
+type stateBlob struct {
+	Version int             `json:"v"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// WorkerState checkpoints worker schedules and daily caps of one account, so
+// a reconnect (which recreates the workers) or a restart resumes them
+// instead of repeating or skipping actions. A nil *WorkerState or one
+// without a store loads nothing and saves nothing.
+type WorkerState struct {
+	store     store.Store
+	accountID int64
+	logger    *Logger
+}
+
+func NewWorkerState(s store.Store, accountID int64, logger *Logger) *WorkerState {
+	return &WorkerState{store: s, accountID: accountID, logger: logger}
+}
+
+// Load decodes the checkpoint saved under key into v. It reports false when
+// there is none, it can't be read, or it was written with another version.
+func (ws *WorkerState) Load(key string, version int, v any) bool {
+	if ws == nil || ws.store == nil {
+		return false
+	}
+	raw, err := ws.store.GetBotState(context.Background(), ws.accountID, key)
+	if err != nil {
+		ws.logger.Warnf("状态", "读取 %s 失败: %v", key, err)
+		return false
+	}
+	if raw == "" {
+		return false
+	}
+	var blob stateBlob
+	if err := json.Unmarshal([]byte(raw), &blob); err != nil || blob.Version != version {
+		ws.logger.Debugf("状态", "丢弃旧格式的 %s (版本 %d, 当前 %d)", key, blob.Version, version)
+		return false
+	}
+	if err := json.Unmarshal(blob.Data, v); err != nil {
+		ws.logger.Debugf("状态", "丢弃无法解析的 %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// Save replaces the checkpoint under key with v.
+func (ws *WorkerState) Save(key string, version int, v any) {
+	if ws == nil || ws.store == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	raw, _ := json.Marshal(stateBlob{Version: version, Data: data})
+	if err := ws.store.PutBotState(context.Background(), ws.accountID, key, string(raw)); err != nil {
+		ws.logger.Warnf("状态", "保存 %s 失败: %v", key, err)
+	}
+}
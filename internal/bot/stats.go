@@ -1,20 +1,136 @@
 package bot
 
 import (
+	"context"
+	"encoding/json"
+	"maps"
+	"math"
+	"sync"
+	"time"
+
 	"qq-farm-bot/internal/model"
 	"qq-farm-bot/internal/store"
 )
 
+// BotStats holds the in-memory counters of a running bot. Workers update it
+// and Status reads it from HTTP goroutines, so all access goes through the
+// methods below.
+type BotStats struct {
+	mu          sync.Mutex
+	c           BotCounters
+	stealRate   expRate
+	harvestRate expRate
+}
+
+// BotCounters is a point-in-time copy of BotStats.
+type BotCounters struct {
+	TotalSteal   int64
+	TotalHelp    int64
+	FriendsCount int
+	// StealExp is the exp stolen since start; StealExpPerHour is its
+	// exponentially weighted rate, updated after every friend pass.
+	StealExp        int64
+	StealExpPerHour float64
+	// TotalHarvest counts harvested lands; HarvestExp and HarvestItems are
+	// what the Harvest replies credited, HarvestExpPerHour its measured rate.
+	TotalHarvest      int64
+	HarvestExp        int64
+	HarvestExpPerHour float64
+	HarvestItems      map[string]int64 // crop name → fruits
+}
+
+// stealRateHalfLife is how quickly old friend passes fade from StealExpPerHour.
+const stealRateHalfLife = 6 * time.Hour
+
+// harvestRateHalfLife is the same for harvests, which come in bursts when
+// crops mature, so they are smoothed over a longer window.
+const harvestRateHalfLife = 12 * time.Hour
+
+// expRate is an exponentially weighted exp-per-hour rate, updated with the
+// exp gained since the previous sample.
+type expRate struct {
+	last    time.Time
+	seeded  bool
+	perHour float64
+}
+
+func (r *expRate) add(exp int64, now time.Time, halfLife time.Duration) {
+	last := r.last
+	r.last = now
+	dt := now.Sub(last).Hours()
+	if last.IsZero() || dt <= 0 {
+		return
+	}
+	alpha := 1 - math.Exp2(-dt/halfLife.Hours())
+	if !r.seeded {
+		// The first measured interval seeds the rate instead of decaying from 0
+		alpha, r.seeded = 1, true
+	}
+	r.perHour += alpha * (float64(exp)/dt - r.perHour)
+}
+
+// Snapshot returns a copy of the counters.
+func (s *BotStats) Snapshot() BotCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.c
+	c.HarvestItems = maps.Clone(s.c.HarvestItems)
+	return c
+}
+
+func (s *BotStats) AddSteal(n int64) {
+	s.mu.Lock()
+	s.c.TotalSteal += n
+	s.mu.Unlock()
+}
+
+func (s *BotStats) AddHelp(n int64) {
+	s.mu.Lock()
+	s.c.TotalHelp += n
+	s.mu.Unlock()
+}
+
+func (s *BotStats) SetFriendsCount(n int) {
+	s.mu.Lock()
+	s.c.FriendsCount = n
+	s.mu.Unlock()
+}
+
+// recordStealPass folds the exp stolen in one friend pass into the rate.
+func (s *BotStats) recordStealPass(exp int64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.StealExp += exp
+	s.stealRate.add(exp, now, stealRateHalfLife)
+	s.c.StealExpPerHour = s.stealRate.perHour
+}
+
+// recordHarvest adds one Harvest reply's yield to the counters and rate.
+func (s *BotStats) recordHarvest(lands int, exp int64, crops map[string]int64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c.TotalHarvest += int64(lands)
+	s.c.HarvestExp += exp
+	if s.c.HarvestItems == nil {
+		s.c.HarvestItems = make(map[string]int64)
+	}
+	for name, n := range crops {
+		s.c.HarvestItems[name] += n
+	}
+	s.harvestRate.add(exp, now, harvestRateHalfLife)
+	s.c.HarvestExpPerHour = s.harvestRate.perHour
+}
+
 // StatsCollector records operation statistics to the database.
 // It is safe for concurrent use — each call writes directly to SQLite
 // (which handles concurrency via WAL mode and busy timeout).
 type StatsCollector struct {
 	accountID int64
-	store     *store.Store
+	store     store.Store
 }
 
 // NewStatsCollector creates a new stats collector for the given account.
-func NewStatsCollector(accountID int64, s *store.Store) *StatsCollector {
+func NewStatsCollector(accountID int64, s store.Store) *StatsCollector {
 	return &StatsCollector{accountID: accountID, store: s}
 }
 
@@ -26,7 +142,7 @@ func (sc *StatsCollector) Record(opType string, count int64, goldDelta int64, ex
 	if sc == nil || sc.store == nil || count == 0 {
 		return
 	}
-	_ = sc.store.AddOpStat(&model.OpRecord{
+	_ = sc.store.AddOpStat(context.Background(), &model.OpRecord{
 		AccountID: sc.accountID,
 		OpType:    opType,
 		Count:     count,
@@ -45,7 +161,7 @@ func (sc *StatsCollector) RecordWithDetail(opType string, count int64, goldDelta
 	if sc == nil || sc.store == nil || count == 0 {
 		return
 	}
-	_ = sc.store.AddOpStat(&model.OpRecord{
+	_ = sc.store.AddOpStat(context.Background(), &model.OpRecord{
 		AccountID: sc.accountID,
 		OpType:    opType,
 		Count:     count,
@@ -54,3 +170,45 @@ func (sc *StatsCollector) RecordWithDetail(opType string, count int64, goldDelta
 		Detail:    detail,
 	})
 }
+
+// RecordSpend records a gold payment of the given category (model.Spend*).
+func (sc *StatsCollector) RecordSpend(category string, amount int64, detail string) {
+	if sc == nil || sc.store == nil || amount <= 0 {
+		return
+	}
+	_ = sc.store.AddSpend(context.Background(), &model.SpendRecord{
+		AccountID: sc.accountID,
+		Category:  category,
+		Amount:    amount,
+		Detail:    detail,
+	})
+}
+
+// RecordActivity appends a typed event to the account's activity feed.
+// payload is marshalled to JSON; nil stores an empty object.
+func (sc *StatsCollector) RecordActivity(eventType string, payload any) {
+	if sc == nil || sc.store == nil {
+		return
+	}
+	var raw []byte
+	if payload != nil {
+		var err error
+		if raw, err = json.Marshal(payload); err != nil {
+			return
+		}
+	}
+	_ = sc.store.AddActivity(context.Background(), &model.ActivityEvent{
+		AccountID: sc.accountID,
+		Type:      eventType,
+		Payload:   raw,
+	})
+}
+
+// RecordFriendGrief adds g to the account's per-friend weed/insect counters.
+func (sc *StatsCollector) RecordFriendGrief(g *model.FriendGrief) {
+	if sc == nil || sc.store == nil {
+		return
+	}
+	g.AccountID = sc.accountID
+	_ = sc.store.AddFriendGrief(context.Background(), g)
+}
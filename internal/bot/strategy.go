@@ -326,3 +326,35 @@ func formatValue(t StrategyRuleType, v float64) string {
 		return fmt.Sprintf("%.1f", v)
 	}
 }
+
+// pickLowestSeed returns the index of the ForceLowest choice in cands. Seeds
+// below minExp per-season exp or above maxPrice are dropped first (0 = no
+// limit), then the lowest required level wins with price breaking ties.
+// ok is false when no seed passes the floors; idx is then the plain lowest.
+func pickLowestSeed(cands []SeedCandidate, minExp, maxPrice int) (idx int, ok bool) {
+	lower := func(a, b SeedCandidate) bool {
+		return a.RequiredLevel < b.RequiredLevel || (a.RequiredLevel == b.RequiredLevel && a.Price < b.Price)
+	}
+	idx = -1
+	for i, c := range cands {
+		if minExp > 0 && c.ExpPerHarvest < minExp {
+			continue
+		}
+		if maxPrice > 0 && c.Price > maxPrice {
+			continue
+		}
+		if idx < 0 || lower(c, cands[idx]) {
+			idx = i
+		}
+	}
+	if idx >= 0 {
+		return idx, true
+	}
+	idx = 0
+	for i := 1; i < len(cands); i++ {
+		if lower(cands[i], cands[idx]) {
+			idx = i
+		}
+	}
+	return idx, false
+}
@@ -0,0 +1,36 @@
+package bot
+
+import "testing"
+
+func TestPickLowestSeed(t *testing.T) {
+	// Two level 1 and two level 2 seeds
+	cands := []SeedCandidate{
+		{Name: "cheap-low", RequiredLevel: 1, Price: 30, ExpPerHarvest: 2},
+		{Name: "dear-low", RequiredLevel: 1, Price: 50, ExpPerHarvest: 6},
+		{Name: "dear-mid", RequiredLevel: 2, Price: 40, ExpPerHarvest: 9},
+		{Name: "cheap-mid", RequiredLevel: 2, Price: 20, ExpPerHarvest: 8},
+	}
+	cases := []struct {
+		name             string
+		minExp, maxPrice int
+		want             string
+		ok               bool
+	}{
+		{"zero means no limit", 0, 0, "cheap-low", true},
+		{"min exp only", 5, 0, "dear-low", true},
+		{"max price only", 0, 25, "cheap-mid", true},
+		{"both floors", 5, 45, "cheap-mid", true},
+		{"level tie broken by price", 7, 0, "cheap-mid", true},
+		{"nothing passes", 10, 0, "cheap-low", false},
+		{"nothing passes both floors", 9, 30, "cheap-low", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			i, ok := pickLowestSeed(cands, tc.minExp, tc.maxPrice)
+			if cands[i].Name != tc.want || ok != tc.ok {
+				t.Errorf("pickLowestSeed(%d, %d) = %s, %v; want %s, %v",
+					tc.minExp, tc.maxPrice, cands[i].Name, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
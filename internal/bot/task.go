@@ -3,6 +3,7 @@ package bot
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -14,34 +15,66 @@ import (
 )
 
 type TaskWorker struct {
-	net    *Network
-	logger *Logger
-	cfg    *BotConfig
-	sc     *StatsCollector
+	net     *Network
+	logger  *Logger
+	profile *Profile
+	tasks   *TaskCache
+	sc      *StatsCollector
 }
 
-func NewTaskWorker(net *Network, logger *Logger, cfg *BotConfig, sc *StatsCollector) *TaskWorker {
-	return &TaskWorker{net: net, logger: logger, cfg: cfg, sc: sc}
+// taskCheckInterval is how often claimable tasks are polled.
+const taskCheckInterval = 5 * time.Minute
+
+// TaskCache holds the exp of tasks that were claimable but not claimed as of
+// the last task check, for the level-up estimate. It stays empty while task
+// claiming is disabled, since the worker doesn't poll tasks then.
+type TaskCache struct {
+	mu         sync.RWMutex
+	pendingExp int64
 }
 
-func (tw *TaskWorker) RunLoop() {
-	if !tw.cfg.EnableClaimTask {
-		return
+func NewTaskCache() *TaskCache {
+	return &TaskCache{}
+}
+
+func (c *TaskCache) setPendingExp(exp int64) {
+	c.mu.Lock()
+	c.pendingExp = exp
+	c.mu.Unlock()
+}
+
+// PendingExp returns the claimable, unclaimed task exp.
+func (c *TaskCache) PendingExp() int64 {
+	if c == nil {
+		return 0
 	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.pendingExp
+}
+
+func NewTaskWorker(net *Network, logger *Logger, profile *Profile, tasks *TaskCache, sc *StatsCollector) *TaskWorker {
+	return &TaskWorker{net: net, logger: logger, profile: profile, tasks: tasks, sc: sc}
+}
 
-	select {
-	case <-time.After(4 * time.Second):
-	case <-tw.net.ctx.Done():
+// RunLoop polls tasks while claiming is enabled, more often during warm-up.
+// The setting is re-read every pass, so toggling it needs no restart.
+func (tw *TaskWorker) RunLoop() {
+	if !sleepJitter(tw.net.ctx, tw.net.clock, 4*time.Second, tw.profile.Current().jitterPct()) {
 		return
 	}
 
-	tw.checkAndClaim()
-
 	for {
-		select {
-		case <-time.After(5 * time.Minute):
+		_, level, _, _, _ := tw.net.state.Get()
+		cfg := tw.profile.Resolve(level)
+		if cfg.EnableClaimTask {
 			tw.checkAndClaim()
-		case <-tw.net.ctx.Done():
+		}
+		interval := taskCheckInterval
+		if cfg.WarmUp {
+			interval = warmUpTaskInterval
+		}
+		if !sleepJitter(tw.net.ctx, tw.net.clock, interval, cfg.jitterPct()) {
 			return
 		}
 	}
@@ -67,11 +100,15 @@ func (tw *TaskWorker) checkAndClaim() {
 	allTasks = append(allTasks, reply.TaskInfo.Tasks...)
 
 	var claimable []*taskpb.Task
+	var pendingExp int64
 	for _, task := range allTasks {
 		if task.IsUnlocked && !task.IsClaimed && task.Progress >= task.TotalProgress && task.TotalProgress > 0 {
 			claimable = append(claimable, task)
+			pendingExp += taskRewardExp(task)
 		}
 	}
+	// Whatever fails to claim below stays pending until the next check
+	defer func() { tw.tasks.setPendingExp(pendingExp) }()
 
 	if len(claimable) == 0 {
 		return
@@ -91,6 +128,7 @@ func (tw *TaskWorker) checkAndClaim() {
 
 		claimReply := &taskpb.ClaimTaskRewardReply{}
 		proto.Unmarshal(claimReplyBody, claimReply)
+		pendingExp -= taskRewardExp(task)
 
 		rewardStr := formatRewards(claimReply.Items)
 		multiStr := ""
@@ -114,6 +152,21 @@ func (tw *TaskWorker) checkAndClaim() {
 	}
 }
 
+// taskRewardExp returns the exp a claim of task yields, including the share
+// multiplier the worker claims with.
+func taskRewardExp(task *taskpb.Task) int64 {
+	var exp int64
+	for _, item := range task.Rewards {
+		if item.Id == 2 {
+			exp += item.Count
+		}
+	}
+	if task.ShareMultiple > 1 {
+		exp *= task.ShareMultiple
+	}
+	return exp
+}
+
 func formatRewards(items []*corepb.Item) string {
 	if len(items) == 0 {
 		return "无"
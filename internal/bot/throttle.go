@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrThrottled is returned by the farm RPC helpers when the same mutation
+// was already sent to one of the lands within the throttle window.
+var ErrThrottled = errors.New("同一土地的相同操作过于频繁, 已跳过")
+
+type landActionKey struct {
+	method string
+	landID int64
+}
+
+// landThrottle refuses to repeat a mutating RPC on the same land within
+// window, so a retry bug or repeated manual clicks can't hammer one land.
+// It is used from the farm goroutine only, like the rest of FarmWorker.
+type landThrottle struct {
+	window time.Duration
+	last   map[landActionKey]time.Time
+}
+
+func newLandThrottle(window time.Duration) *landThrottle {
+	return &landThrottle{window: window, last: make(map[landActionKey]time.Time)}
+}
+
+// allow reports whether method may be sent for landIDs at now, and if so
+// records it. A request touching any land still inside the window is refused
+// as a whole. A zero window disables the throttle.
+func (t *landThrottle) allow(method string, landIDs []int64, now time.Time) bool {
+	if t.window <= 0 {
+		return true
+	}
+	for _, id := range landIDs {
+		if last, ok := t.last[landActionKey{method, id}]; ok && now.Sub(last) < t.window {
+			return false
+		}
+	}
+	for key, last := range t.last {
+		if now.Sub(last) >= t.window {
+			delete(t.last, key)
+		}
+	}
+	for _, id := range landIDs {
+		t.last[landActionKey{method, id}] = now
+	}
+	return true
+}
+
+// sendLandAction sends a PlantService mutation for landIDs unless it was
+// throttled, in which case the suppression is logged at debug level.
+func (f *FarmWorker) sendLandAction(method string, landIDs []int64, body []byte) ([]byte, error) {
+	if !f.throttle.allow(method, landIDs, f.net.clock.Now()) {
+		f.logger.Debugf("限流", "%s 地%v 在 %s 内重复, 已跳过", method, landIDs, f.throttle.window)
+		return nil, ErrThrottled
+	}
+	return f.net.SendRequest("gamepb.plantpb.PlantService", method, body)
+}
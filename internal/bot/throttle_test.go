@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLandThrottle(t *testing.T) {
+	start := time.Unix(testNow, 0)
+	type send struct {
+		after  time.Duration // since start
+		method string
+		lands  []int64
+		want   bool
+	}
+	cases := []struct {
+		name   string
+		window time.Duration
+		sends  []send
+	}{
+		{"repeat inside the window", 2 * time.Second, []send{
+			{0, "Plant", []int64{1}, true},
+			{time.Second, "Plant", []int64{1}, false},
+			{1999 * time.Millisecond, "Plant", []int64{1}, false},
+		}},
+		{"repeat after the window", 2 * time.Second, []send{
+			{0, "Plant", []int64{1}, true},
+			{2 * time.Second, "Plant", []int64{1}, true},
+			{3 * time.Second, "Plant", []int64{1}, false},
+		}},
+		{"refused sends don't extend the window", 2 * time.Second, []send{
+			{0, "Plant", []int64{1}, true},
+			{time.Second, "Plant", []int64{1}, false},
+			{2 * time.Second, "Plant", []int64{1}, true},
+		}},
+		{"other land or method", 2 * time.Second, []send{
+			{0, "Plant", []int64{1}, true},
+			{0, "Plant", []int64{2}, true},
+			{0, "WaterLand", []int64{1}, true},
+		}},
+		{"batch touching a recent land", 2 * time.Second, []send{
+			{0, "WaterLand", []int64{2}, true},
+			{time.Second, "WaterLand", []int64{1, 2, 3}, false},
+			// The refused batch recorded nothing for lands 1 and 3
+			{time.Second, "WaterLand", []int64{1, 3}, true},
+		}},
+		{"disabled", 0, []send{
+			{0, "Plant", []int64{1}, true},
+			{0, "Plant", []int64{1}, true},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			th := newLandThrottle(tc.window)
+			for i, s := range tc.sends {
+				if got := th.allow(s.method, s.lands, start.Add(s.after)); got != s.want {
+					t.Errorf("send %d: %s %v at +%s allowed = %v, want %v", i, s.method, s.lands, s.after, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSendLandActionThrottled(t *testing.T) {
+	f := newTestFarm(nil, time.Unix(testNow, 0))
+	f.throttle = newLandThrottle(2 * time.Second)
+	game := connectFakeGame(t, f.net, nil)
+
+	if _, err := f.sendLandAction("WaterLand", []int64{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.sendLandAction("WaterLand", []int64{1}, nil); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("repeat err = %v, want ErrThrottled", err)
+	}
+	f.net.clock.(*fakeClock).Advance(2 * time.Second)
+	if _, err := f.sendLandAction("WaterLand", []int64{1}, nil); err != nil {
+		t.Fatalf("after the window: %v", err)
+	}
+	if n := len(game.requests("WaterLand")); n != 2 {
+		t.Errorf("%d WaterLand requests reached the server, want 2", n)
+	}
+}
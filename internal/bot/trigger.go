@@ -0,0 +1,15 @@
+package bot
+
+// trigger wakes a worker loop ahead of its schedule. Fires coalesce: any
+// number of fire calls before the loop next receives wake it once, and fire
+// never blocks the caller.
+type trigger chan struct{}
+
+func newTrigger() trigger { return make(trigger, 1) }
+
+func (t trigger) fire() {
+	select {
+	case t <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"qq-farm-bot/proto/visitpb"
+)
+
+// leaveTimeout bounds the Leave sent while stopping, so Stop isn't held up
+// by a dead connection.
+const leaveTimeout = 3 * time.Second
+
+// enterFriend records friendGid as the visit in progress, both in memory
+// and in the worker state, before sending Enter: should the process die
+// mid-visit, the next connection still knows whom to leave.
+func (fw *FriendWorker) enterFriend(friendGid int64) (*visitpb.EnterReply, error) {
+	fw.visiting.Store(friendGid)
+	fw.state.Save(stateKeyVisit, stateVersionVisit, friendGid)
+
+	body, _ := proto.Marshal(&visitpb.EnterRequest{HostGid: friendGid, Reason: 2})
+	replyBody, err := fw.net.SendRequest("gamepb.visitpb.VisitService", "Enter", body)
+	if asServerError(err) != nil {
+		// Refused: no session was opened
+		if fw.visiting.CompareAndSwap(friendGid, 0) {
+			fw.state.Save(stateKeyVisit, stateVersionVisit, int64(0))
+		}
+		return nil, err
+	}
+	if err != nil {
+		// A timeout may hide a session the server did open; leave anyway
+		fw.leaveFriend(friendGid, defaultRequestTimeout)
+		return nil, err
+	}
+	reply := &visitpb.EnterReply{}
+	proto.Unmarshal(replyBody, reply)
+	return reply, nil
+}
+
+// leaveFriend ends the visit of friendGid unless someone else already did.
+// The persisted target is cleared only once the server confirmed the Leave.
+func (fw *FriendWorker) leaveFriend(friendGid int64, timeout time.Duration) {
+	if !fw.visiting.CompareAndSwap(friendGid, 0) {
+		return
+	}
+	if fw.sendLeave(friendGid, timeout) {
+		fw.state.Save(stateKeyVisit, stateVersionVisit, int64(0))
+	}
+}
+
+// leaveCurrentVisit ends the visit in progress, if any. The instance calls
+// it while stopping, before the connection is closed.
+func (fw *FriendWorker) leaveCurrentVisit() {
+	if fw == nil {
+		return
+	}
+	if gid := fw.visiting.Load(); gid != 0 {
+		fw.leaveFriend(gid, leaveTimeout)
+	}
+}
+
+// leaveStaleVisit sends a defensive Leave for a visit the previous
+// connection never ended, so the game doesn't keep showing us on that farm
+// and the next Enter isn't refused.
+func (fw *FriendWorker) leaveStaleVisit() {
+	var gid int64
+	if !fw.state.Load(stateKeyVisit, stateVersionVisit, &gid) || gid == 0 {
+		return
+	}
+	fw.logger.Debugf("好友", "上次拜访 %d 未正常离开, 补发离开请求", gid)
+	if fw.sendLeave(gid, defaultRequestTimeout) {
+		fw.state.Save(stateKeyVisit, stateVersionVisit, int64(0))
+	}
+}
+
+// sendLeave reports whether the session is gone: a server error means there
+// was none left to end.
+func (fw *FriendWorker) sendLeave(friendGid int64, timeout time.Duration) bool {
+	body, _ := proto.Marshal(&visitpb.LeaveRequest{HostGid: friendGid})
+	_, err := fw.net.sendRequestWithTimeout("gamepb.visitpb.VisitService", "Leave", body, timeout)
+	return err == nil || asServerError(err) != nil
+}
+
+// stopping reports whether the connection is shutting down.
+func (fw *FriendWorker) stopping() bool {
+	return fw.net.ctx.Err() != nil
+}
+
+// pause sleeps d unless the bot is stopping, in which case it returns false
+// at once so loops stop sending.
+func (fw *FriendWorker) pause(d time.Duration) bool {
+	return sleepJitter(fw.net.ctx, fw.net.clock, d, 0)
+}
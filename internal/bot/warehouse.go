@@ -13,62 +13,94 @@ import (
 	"qq-farm-bot/proto/itempb"
 )
 
+// sellAfterHarvestDelay lets the rest of a farm cycle's harvests land
+// before the bag is fetched, so one sale covers them all.
+const sellAfterHarvestDelay = 5 * time.Second
+
 type WarehouseWorker struct {
-	net    *Network
-	logger *Logger
-	cfg    *BotConfig
-	gc     *GameConfig
-	sc     *StatsCollector
+	net       *Network
+	logger    *Logger
+	profile   *Profile
+	cfg       *BotConfig // copy taken at the start of each pass
+	gc        *GameConfig
+	bag       *BagCache
+	harvested trigger
+	bagFull   *BagFull
+	sc        *StatsCollector
 }
 
-func NewWarehouseWorker(net *Network, logger *Logger, cfg *BotConfig, sc *StatsCollector) *WarehouseWorker {
-	return &WarehouseWorker{net: net, logger: logger, cfg: cfg, gc: GetGameConfig(), sc: sc}
+func NewWarehouseWorker(net *Network, logger *Logger, profile *Profile, bag *BagCache, harvested trigger, bagFull *BagFull, sc *StatsCollector) *WarehouseWorker {
+	return &WarehouseWorker{net: net, logger: logger, profile: profile, cfg: profile.Current(), gc: GetGameConfig(), bag: bag, harvested: harvested, bagFull: bagFull, sc: sc}
 }
 
+// RunLoop sells shortly after each harvest and otherwise sweeps the bag
+// every WarehouseInterval, keeping BagCache fresh for the panel. Sell
+// passes requested for a full bag run right away.
 func (ww *WarehouseWorker) RunLoop() {
-	if !ww.cfg.EnableSell {
-		return
-	}
-
-	select {
-	case <-time.After(10 * time.Second):
-	case <-ww.net.ctx.Done():
-		return
-	}
-
-	ww.sellAllFruits()
-
+	next := ww.net.clock.After(jitterDuration(10*time.Second, ww.cfg.jitterPct()))
 	for {
 		select {
-		case <-time.After(60 * time.Second):
-			ww.sellAllFruits()
+		case <-next:
+		case reply := <-ww.bagFull.sells:
+			ww.cfg = ww.profile.Current()
+			reply <- ww.sellPass()
+			continue
+		case <-ww.harvested:
+			if !sleepJitter(ww.net.ctx, ww.net.clock, sellAfterHarvestDelay, ww.cfg.jitterPct()) {
+				return
+			}
 		case <-ww.net.ctx.Done():
 			return
 		}
+		ww.cfg = ww.profile.Current()
+		ww.tick()
+		next = ww.net.clock.After(jitterDuration(ww.sweepInterval(), ww.cfg.jitterPct()))
 	}
 }
 
-func (ww *WarehouseWorker) sellAllFruits() {
-	req := &itempb.BagRequest{}
-	body, _ := proto.Marshal(req)
-	replyBody, err := ww.net.SendRequest("gamepb.itempb.ItemService", "Bag", body)
-	if err != nil {
-		return
+func (ww *WarehouseWorker) sweepInterval() time.Duration {
+	if ww.cfg.WarehouseInterval <= 0 {
+		return model.DefaultWarehouseIntervalSec * time.Second
 	}
-	reply := &itempb.BagReply{}
-	proto.Unmarshal(replyBody, reply)
+	return time.Duration(ww.cfg.WarehouseInterval) * time.Second
+}
 
-	if reply.ItemBag == nil || len(reply.ItemBag.Items) == 0 {
+func (ww *WarehouseWorker) tick() {
+	// Another worker may have just fetched the bag
+	if ww.bag.fresh() && !ww.cfg.EnableSell {
 		return
 	}
+	ww.sellPass()
+}
+
+// sellPass fetches the bag and sells fruits when enabled. It reports
+// whether anything was sold.
+func (ww *WarehouseWorker) sellPass() bool {
+	items, err := fetchBag(ww.net, ww.bag, ww.gc)
+	if err != nil || !ww.cfg.EnableSell {
+		return false
+	}
+	if !ww.sellAllFruits(items) {
+		return false
+	}
+	ww.bagFull.sold()
+	return true
+}
+
+// sellAllFruits sells the fruits the sell filter allows and reports
+// whether the sale went through.
+func (ww *WarehouseWorker) sellAllFruits(items []*corepb.Item) bool {
+	if len(items) == 0 {
+		return false
+	}
 
-	sellFilter := ParseCropIDs(ww.cfg.SellCropIDs)
+	sellFilter := ww.gc.CropFilter(ww.cfg.SellCropIDs)
 	hasSellFilter := len(sellFilter) > 0
 
 	var toSell []*corepb.Item
 	var names []string
 
-	for _, item := range reply.ItemBag.Items {
+	for _, item := range items {
 		id := int(item.Id)
 		count := item.Count
 		if ww.gc.IsFruitID(id) && count > 0 && item.Uid > 0 {
@@ -84,7 +116,7 @@ func (ww *WarehouseWorker) sellAllFruits() {
 	}
 
 	if len(toSell) == 0 {
-		return
+		return false
 	}
 
 	sellReq := &itempb.SellRequest{Items: toSell}
@@ -92,7 +124,7 @@ func (ww *WarehouseWorker) sellAllFruits() {
 	sellReplyBody, err := ww.net.SendRequest("gamepb.itempb.ItemService", "Sell", sellBody)
 	if err != nil {
 		ww.logger.Warnf("仓库", "出售失败: %v", err)
-		return
+		return false
 	}
 
 	sellReply := &itempb.SellReply{}
@@ -107,4 +139,5 @@ func (ww *WarehouseWorker) sellAllFruits() {
 
 	ww.logger.Infof("仓库", "出售 %s，获得 %d 金币", strings.Join(names, ", "), totalGold)
 	ww.sc.RecordWithDetail(model.OpSell, int64(len(toSell)), totalGold, 0, strings.Join(names, ", "))
+	return true
 }
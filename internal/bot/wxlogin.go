@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WeChat scan login mirrors the WeChat DevTools flow: the devtools app shows
+// an open-platform QR code, the scan yields an OAuth code, which is exchanged
+// for a devtools ticket and then for a mini-program login code of the farm.
+const (
+	wxDevtoolsAppID   = "wxde40e023744664cb"
+	wxQRConnectURL    = "https://open.weixin.qq.com/connect/qrconnect"
+	wxQRConfirmURL    = "https://open.weixin.qq.com/connect/confirm?uuid="
+	wxQRPollURL       = "https://long.open.weixin.qq.com/connect/l/qrconnect"
+	wxDevLoginURL     = "https://servicewechat.com/wxa-dev-logic/login"
+	wxDevJSLoginURL   = "https://servicewechat.com/wxa-dev-logic/jslogin"
+	wxDevRedirectURI  = "https://servicewechat.com/wxa-dev-logic/login"
+	wxPollWait        = 408 // not scanned yet
+	wxPollScanned     = 404 // scanned, waiting for confirmation
+	wxPollConfirmed   = 405 // confirmed, wx_code carries the OAuth code
+	wxPollCancelled   = 403
+	wxPollExpired     = 402
+	wxPollExpiredLong = 500
+)
+
+var (
+	wxUUIDPattern = regexp.MustCompile(`/connect/qrcode/([A-Za-z0-9_-]+)`)
+	wxErrPattern  = regexp.MustCompile(`wx_errcode=(\d+)`)
+	wxCodePattern = regexp.MustCompile(`wx_code='([^']*)'`)
+)
+
+var (
+	wxAppIDMu sync.RWMutex
+	wxAppID   string // farm mini-program appid on WeChat, from config
+)
+
+// SetWXAppID sets the farm mini-program appid used for WeChat scan login.
+func SetWXAppID(appID string) {
+	wxAppIDMu.Lock()
+	defer wxAppIDMu.Unlock()
+	wxAppID = appID
+}
+
+func getWXAppID() string {
+	wxAppIDMu.RLock()
+	defer wxAppIDMu.RUnlock()
+	return wxAppID
+}
+
+func wxHeaders() http.Header {
+	h := http.Header{}
+	h.Set("accept", "*/*")
+	h.Set("user-agent", chromeUA)
+	return h
+}
+
+// requestWXQRCode starts a WeChat scan login. LoginCode is the open-platform
+// uuid; QRCodeURL is the confirm page the WeChat app opens when scanning.
+func requestWXQRCode() (*QRLoginResult, error) {
+	if getWXAppID() == "" {
+		return nil, errors.New("未配置 wx_app_id, 无法使用微信扫码登录")
+	}
+	q := url.Values{}
+	q.Set("appid", wxDevtoolsAppID)
+	q.Set("redirect_uri", wxDevRedirectURI)
+	q.Set("response_type", "code")
+	q.Set("scope", "snsapi_login")
+	q.Set("state", "login")
+	req, _ := newQRRequest("GET", wxQRConnectURL+"?"+q.Encode(), nil, wxHeaders())
+	resp, err := qrHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid, err := parseWXQRUUID(body)
+	if err != nil {
+		return nil, err
+	}
+	return &QRLoginResult{LoginCode: uuid, QRCodeURL: QRCodeURL("wx", uuid)}, nil
+}
+
+// parseWXQRUUID extracts the QR uuid from the qrconnect page.
+func parseWXQRUUID(page []byte) (string, error) {
+	m := wxUUIDPattern.FindSubmatch(page)
+	if m == nil {
+		return "", errors.New("获取微信扫码登录码失败")
+	}
+	return string(m[1]), nil
+}
+
+// pollWXQRStatus checks the WeChat scan status. Like the QQ provider it only
+// reports expected states through QRLoginStatus and never returns an error.
+func pollWXQRStatus(uuid string) (*QRLoginStatus, error) {
+	pollURL := fmt.Sprintf("%s?uuid=%s&_=%d", wxQRPollURL, url.QueryEscape(uuid), time.Now().UnixMilli())
+	req, _ := newQRRequest("GET", pollURL, nil, wxHeaders())
+	resp, err := qrHTTPClient.Do(req)
+	if err != nil {
+		return &QRLoginStatus{Status: "error", Message: "网络请求失败"}, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &QRLoginStatus{Status: "error", Message: fmt.Sprintf("微信服务器返回 %d", resp.StatusCode)}, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &QRLoginStatus{Status: "error", Message: "读取响应失败"}, nil
+	}
+
+	status, wxCode := parseWXPoll(body)
+	if status.Status != "ok" {
+		return status, nil
+	}
+	authCode, err := exchangeWXCode(wxCode)
+	if err != nil {
+		return &QRLoginStatus{Status: "error", Message: err.Error()}, nil
+	}
+	return &QRLoginStatus{Status: "ok", Code: authCode}, nil
+}
+
+// parseWXPoll maps a long-poll response such as
+// "window.wx_errcode=405;window.wx_code='xxx';" to a status. On success the
+// returned status is "ok" without Code; wxCode is the OAuth code to exchange.
+func parseWXPoll(body []byte) (status *QRLoginStatus, wxCode string) {
+	m := wxErrPattern.FindSubmatch(body)
+	if m == nil {
+		return &QRLoginStatus{Status: "error", Message: "解析响应失败"}, ""
+	}
+	errCode, _ := strconv.Atoi(string(m[1]))
+	switch errCode {
+	case wxPollWait, wxPollScanned:
+		return &QRLoginStatus{Status: "wait"}, ""
+	case wxPollExpired, wxPollExpiredLong:
+		return &QRLoginStatus{Status: "expired"}, ""
+	case wxPollCancelled:
+		return &QRLoginStatus{Status: "error", Message: "已在微信中取消登录"}, ""
+	case wxPollConfirmed:
+		c := wxCodePattern.FindSubmatch(body)
+		if c == nil || len(c[1]) == 0 {
+			return &QRLoginStatus{Status: "error", Message: "微信未返回授权码"}, ""
+		}
+		return &QRLoginStatus{Status: "ok"}, string(c[1])
+	default:
+		return &QRLoginStatus{Status: "error", Message: fmt.Sprintf("微信返回错误码 %d", errCode)}, ""
+	}
+}
+
+// exchangeWXCode turns the OAuth code into a devtools ticket and then into a
+// farm mini-program login code.
+func exchangeWXCode(wxCode string) (string, error) {
+	req, _ := newQRRequest("GET", wxDevLoginURL+"?code="+url.QueryEscape(wxCode), nil, wxHeaders())
+	body, err := doWXRequest(req)
+	if err != nil {
+		return "", err
+	}
+	ticket, err := parseWXTicket(body)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("appid", getWXAppID())
+	q.Set("newticket", ticket)
+	req, _ = newQRRequest("GET", wxDevJSLoginURL+"?"+q.Encode(), nil, wxHeaders())
+	body, err = doWXRequest(req)
+	if err != nil {
+		return "", err
+	}
+	return parseWXJSLogin(body)
+}
+
+func doWXRequest(req *http.Request) ([]byte, error) {
+	resp, err := qrHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求登录接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("登录接口返回 HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	return body, nil
+}
+
+// wxBaseResponse is the status envelope of servicewechat.com responses.
+type wxBaseResponse struct {
+	Ret    int    `json:"ret"`
+	ErrMsg string `json:"errmsg"`
+}
+
+func parseWXTicket(body []byte) (string, error) {
+	var result struct {
+		BaseResponse wxBaseResponse `json:"baseresponse"`
+		NewTicket    string         `json:"newticket"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析登录响应失败: %w", err)
+	}
+	if result.BaseResponse.Ret != 0 || result.NewTicket == "" {
+		return "", fmt.Errorf("获取开发者票据失败 (ret=%d %s)", result.BaseResponse.Ret, result.BaseResponse.ErrMsg)
+	}
+	return result.NewTicket, nil
+}
+
+func parseWXJSLogin(body []byte) (string, error) {
+	var result struct {
+		BaseResponse wxBaseResponse `json:"baseresponse"`
+		Code         string         `json:"code"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析登录响应失败: %w", err)
+	}
+	if result.BaseResponse.Ret != 0 || result.Code == "" {
+		return "", fmt.Errorf("获取农场登录 code 失败 (响应: %s)", string(body))
+	}
+	return result.Code, nil
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWXRequestQRCode(t *testing.T) {
+	_, wx := fakeQRProviders(t)
+	SetWXAppID("")
+	if _, err := RequestQRCode("wx"); err == nil || !strings.Contains(err.Error(), "wx_app_id") {
+		t.Fatalf("without an appid: %v", err)
+	}
+
+	SetWXAppID("wxfarm")
+	t.Cleanup(func() { SetWXAppID("") })
+	wx.reply(map[string]qrReply{"/connect/qrconnect": {
+		body: `<div class="wrp_code"><img class="qrcode lightBorder" src="/connect/qrcode/071aBc-D_9"></div>`,
+	}})
+	res, err := RequestQRCode("wx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LoginCode != "071aBc-D_9" || res.QRCodeURL != wxQRConfirmURL+"071aBc-D_9" {
+		t.Fatalf("result = %+v", res)
+	}
+	q := wx.last(t, "/connect/qrconnect").URL.Query()
+	if q.Get("appid") != wxDevtoolsAppID || q.Get("scope") != "snsapi_login" {
+		t.Errorf("qrconnect query = %v", q)
+	}
+
+	wx.reply(map[string]qrReply{"/connect/qrconnect": {body: `<html>no code</html>`}})
+	if res, err := RequestQRCode("wx"); err == nil {
+		t.Errorf("page without a QR: result %+v, want an error", res)
+	}
+}
+
+func TestWXPollQRStatus(t *testing.T) {
+	_, wx := fakeQRProviders(t)
+	SetWXAppID("wxfarm")
+	t.Cleanup(func() { SetWXAppID("") })
+	const poll, login, jslogin = "/connect/l/qrconnect", "/wxa-dev-logic/login", "/wxa-dev-logic/jslogin"
+	confirmed := qrReply{body: `window.wx_errcode=405;window.wx_code='oauth1';`}
+	ticket := qrReply{body: `{"baseresponse":{"ret":0},"newticket":"tk1"}`}
+
+	cases := []struct {
+		name    string
+		replies map[string]qrReply
+		want    QRLoginStatus
+	}{
+		{"waiting", map[string]qrReply{poll: {body: `window.wx_errcode=408;window.wx_code='';`}},
+			QRLoginStatus{Status: "wait"}},
+		{"scanned", map[string]qrReply{poll: {body: `window.wx_errcode=404;window.wx_code='';`}},
+			QRLoginStatus{Status: "wait"}},
+		{"expired", map[string]qrReply{poll: {body: `window.wx_errcode=402;window.wx_code='';`}},
+			QRLoginStatus{Status: "expired"}},
+		{"cancelled", map[string]qrReply{poll: {body: `window.wx_errcode=403;window.wx_code='';`}},
+			QRLoginStatus{Status: "error", Message: "已在微信中取消登录"}},
+		{"unknown code", map[string]qrReply{poll: {body: `window.wx_errcode=499;`}},
+			QRLoginStatus{Status: "error", Message: "微信返回错误码 499"}},
+		{"malformed", map[string]qrReply{poll: {body: `<html>`}},
+			QRLoginStatus{Status: "error", Message: "解析响应失败"}},
+		{"http error", map[string]qrReply{poll: {status: http.StatusServiceUnavailable}},
+			QRLoginStatus{Status: "error", Message: "微信服务器返回 503"}},
+		{"confirmed without code", map[string]qrReply{poll: {body: `window.wx_errcode=405;window.wx_code='';`}},
+			QRLoginStatus{Status: "error", Message: "微信未返回授权码"}},
+		{"confirmed", map[string]qrReply{poll: confirmed, login: ticket,
+			jslogin: {body: `{"baseresponse":{"ret":0},"code":"farm-wx"}`}},
+			QRLoginStatus{Status: "ok", Code: "farm-wx"}},
+		{"ticket refused", map[string]qrReply{poll: confirmed,
+			login: {body: `{"baseresponse":{"ret":-1,"errmsg":"invalid code"}}`}},
+			QRLoginStatus{Status: "error", Message: "获取开发者票据失败 (ret=-1 invalid code)"}},
+		{"jslogin refused", map[string]qrReply{poll: confirmed, login: ticket,
+			jslogin: {body: `{"baseresponse":{"ret":40013}}`}},
+			QRLoginStatus{Status: "error", Message: `获取农场登录 code 失败 (响应: {"baseresponse":{"ret":40013}})`}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			wx.reply(tc.replies)
+			got, err := PollQRStatus("wx", "uuid-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tc.want {
+				t.Fatalf("status = %+v, want %+v", *got, tc.want)
+			}
+			if q := wx.last(t, poll).URL.Query().Get("uuid"); q != "uuid-1" {
+				t.Errorf("polled uuid %q", q)
+			}
+			if tc.want.Status == "ok" {
+				if q := wx.last(t, login).URL.Query().Get("code"); q != "oauth1" {
+					t.Errorf("exchanged OAuth code %q", q)
+				}
+				if q := wx.last(t, jslogin).URL.Query(); q.Get("appid") != "wxfarm" || q.Get("newticket") != "tk1" {
+					t.Errorf("jslogin query = %v", q)
+				}
+			}
+		})
+	}
+}
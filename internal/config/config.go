@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type Config struct {
@@ -12,55 +13,326 @@ type Config struct {
 	JWTSecret string `json:"jwt_secret"`
 	DBPath    string `json:"db_path"`
 
+	// Database backend: "sqlite" (default, file at DBPath) or "postgres"
+	// (connection string in DBDSN, needs a build with -tags postgres).
+	// Use Postgres when the data dir is on network storage, where SQLite's
+	// WAL mode is unsafe.
+	DBDriver string `json:"db_driver"`
+	DBDSN    string `json:"db_dsn"`
+
+	// Upper bound for a single database call (duration string); requests
+	// fail instead of hanging on a locked database file.
+	DBQueryTimeout string `json:"db_query_timeout"`
+
+	// HTTPS: serve TLS when both cert and key are set (relative paths are
+	// resolved against the working directory). TLSAutoSelfSigned generates a
+	// self-signed pair into the data dir when no cert/key is configured,
+	// valid for localhost plus TLSHosts. HTTPRedirectListen optionally starts
+	// a plain HTTP listener that redirects to HTTPS.
+	TLSCert            string   `json:"tls_cert"`
+	TLSKey             string   `json:"tls_key"`
+	TLSAutoSelfSigned  bool     `json:"tls_auto_self_signed"`
+	TLSHosts           []string `json:"tls_hosts"`
+	HTTPRedirectListen string   `json:"http_redirect_listen"`
+
+	// Auth token lifetimes (Go duration strings, e.g. "1h", "720h")
+	TokenTTL   string `json:"token_ttl"`
+	RefreshTTL string `json:"refresh_ttl"`
+
+	// Login throttling: lock a username/IP after LoginMaxAttempts failures
+	// within LoginWindow (duration string). 0 disables throttling.
+	LoginMaxAttempts int    `json:"login_max_attempts"`
+	LoginWindow      string `json:"login_window"`
+
+	// Request limits: bodies larger than MaxBodyBytes are rejected with 413.
+	// Each user gets a token bucket refilled at RateLimitPerMinute holding
+	// up to RateLimitBurst requests; /api/auth is limited per client IP at
+	// AuthRateLimitPerMinute. WebSocket streams skip the buckets and are
+	// capped at MaxStreamsPerUser open connections instead. 0 disables a limit.
+	MaxBodyBytes           int `json:"max_body_bytes"`
+	RateLimitPerMinute     int `json:"rate_limit_per_minute"`
+	RateLimitBurst         int `json:"rate_limit_burst"`
+	AuthRateLimitPerMinute int `json:"auth_rate_limit_per_minute"`
+	MaxStreamsPerUser      int `json:"max_streams_per_user"`
+
+	// Reverse proxies whose X-Forwarded-For header is trusted (IPs or CIDRs).
+	// Empty means the direct peer address is always used.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// Browser origins allowed for CORS and WebSocket connections
+	// (e.g. "https://farm.example.com"). "*" allows any origin.
+	AllowedOrigins []string `json:"allowed_origins"`
+
+	// Audit log retention in days (0 keeps audit rows forever)
+	AuditRetentionDays int `json:"audit_retention_days"`
+
+	// Bot log retention in days (0 keeps logs forever). Old logs, audit rows
+	// and sessions are pruned daily at MaintenanceHour (local time, 0-23,
+	// -1 disables the daily run), followed by ANALYZE and VACUUM as needed.
+	LogRetentionDays int `json:"log_retention_days"`
+	MaintenanceHour  int `json:"maintenance_hour"`
+
+	// Accounts a non-admin user may own (0 = unlimited)
+	MaxAccountsPerUser int `json:"max_accounts_per_user"`
+
+	// Days a deleted account stays in the recycle bin before it is purged
+	// with its logs (0 keeps deleted accounts forever)
+	AccountPurgeDays int `json:"account_purge_days"`
+
 	// Admin
 	AdminUser string `json:"admin_user"`
 	AdminPass string `json:"admin_pass"`
 
-	// Game defaults
-	GameServerURL string `json:"game_server_url"`
-	ClientVersion string `json:"client_version"`
+	// Default log storage policy for accounts without their own setting:
+	// minimum level written to the database (debug/info/warn) and tags whose
+	// debug/info lines are not stored. Live log streams always see everything.
+	LogLevel        string   `json:"log_level"`
+	LogTagBlacklist []string `json:"log_tag_blacklist"`
+
+	// Optional JSON-lines log file shared by all accounts, rotated when it
+	// exceeds LogFileMaxSizeMB (keeping LogFileMaxFiles old files). Relative
+	// paths are resolved against the working directory.
+	LogFile          string `json:"log_file"`
+	LogFileMaxSizeMB int    `json:"log_file_max_size_mb"`
+	LogFileMaxFiles  int    `json:"log_file_max_files"`
+	DisableStdoutLog bool   `json:"disable_stdout_log"` // legacy: same as console_log "off"
+
+	// Console (stdout) output of bot logs and of HTTP access logs: "all",
+	// "warn" (warnings/errors, for access logs 4xx/5xx responses) or "off".
+	// Run as a quiet service with both "off"; logs still reach the database.
+	ConsoleLog string `json:"console_log"`
+	AccessLog  string `json:"access_log"`
+
+	// Entries buffered per live log subscriber before drops are counted
+	LogSubscriberBuffer int `json:"log_subscriber_buffer"`
+
+	// Game defaults. GameServers overrides them per platform ("qq", "wx");
+	// its "default" entry applies to all platforms. Empty fields in an entry
+	// fall back to the next level: platform -> default -> the flat fields.
+	GameServerURL string                `json:"game_server_url"`
+	ClientVersion string                `json:"client_version"`
+	GameServers   map[string]GameServer `json:"game_servers,omitempty"`
+
+	// Hour (China time, 0-23) at which the game server resets daily limits.
+	// Daily counters, summaries and per-day stats roll over at this hour.
+	GameResetHour int `json:"game_reset_hour"`
+
+	// Appid of the farm mini-program on WeChat, required for WeChat scan login
+	WXAppID string `json:"wx_app_id"`
+
+	// Notification webhook receiving JSON events (needs_relogin, kickout,
+	// level_up, daily_summary); accounts may override the URL. NotifyEvents
+	// limits the events sent, empty sends all of them.
+	NotifyWebhookURL string   `json:"notify_webhook_url"`
+	NotifyEvents     []string `json:"notify_events"`
+
+	// Maximum number of bots connecting/logging in at the same time
+	MaxConcurrentLogins int `json:"max_concurrent_logins"`
 
 	// External API
 	APIKey string `json:"api_key"`
 
+	// Minimum gap between identical mutations of one land (duration string,
+	// default 2s, "0" disables)
+	LandActionThrottle string `json:"land_action_throttle"`
+
+	// Poll interval for game config file changes (duration string, empty disables)
+	GameConfigReloadInterval string `json:"game_config_reload_interval"`
+
+	// Enables /api/accounts/:id/debug/* endpoints, which expose raw game data
+	DebugEndpointsEnabled bool `json:"debug_endpoints_enabled"`
+
+	// Directory holding Plant.json etc., relative paths resolve against the
+	// base directory
+	GameConfigDir string `json:"game_config_dir"`
+
 	// Paths
-	DataDir       string `json:"-"`
-	GameConfigDir string `json:"-"`
+	DataDir string `json:"-"`
+
+	// JSON names of fields overridden by FARMBOT_* environment variables
+	EnvOverrides []string `json:"-"`
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		Listen:        "0.0.0.0:8080",
-		JWTSecret:     "qq-farm-bot-secret-change-me",
-		DBPath:        "data/farm.db",
-		AdminUser:     "admin",
-		AdminPass:     "admin123",
-		GameServerURL: "wss://gate-obt.nqf.qq.com/prod/ws",
-		ClientVersion: "1.7.0.5_20260306",
+		Listen:                 "0.0.0.0:8080",
+		JWTSecret:              "qq-farm-bot-secret-change-me",
+		DBPath:                 "data/farm.db",
+		DBQueryTimeout:         "5s",
+		TokenTTL:               "1h",
+		RefreshTTL:             "720h",
+		LoginMaxAttempts:       5,
+		LoginWindow:            "15m",
+		MaxBodyBytes:           1 << 20,
+		RateLimitPerMinute:     600,
+		RateLimitBurst:         100,
+		AuthRateLimitPerMinute: 30,
+		MaxStreamsPerUser:      8,
+		AllowedOrigins:         []string{"*"},
+		AuditRetentionDays:     90,
+		LogRetentionDays:       7,
+		MaintenanceHour:        4,
+		AccountPurgeDays:       30,
+		AdminUser:              "admin",
+		AdminPass:              "admin123",
+		GameServerURL:          "wss://gate-obt.nqf.qq.com/prod/ws",
+		ClientVersion:          "1.7.0.5_20260306",
+		MaxConcurrentLogins:    3,
+		LogLevel:               "debug",
+		LogFileMaxSizeMB:       10,
+		LogFileMaxFiles:        5,
+		ConsoleLog:             ConsoleLogAll,
+		AccessLog:              ConsoleLogOff,
+		LogSubscriberBuffer:    500,
 	}
 }
 
+// Load reads the config file (a missing file yields the defaults) and then
+// applies FARMBOT_* environment overrides, so env always wins over the file.
 func Load(path string) (*Config, error) {
 	cfg := DefaultConfig()
 	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
-	if err := json.Unmarshal(data, cfg); err != nil {
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.EnvOverrides, err = cfg.applyEnv(); err != nil {
 		return nil, err
 	}
 	return cfg, nil
 }
 
+// Console output levels for ConsoleLog and AccessLog.
+const (
+	ConsoleLogAll  = "all"
+	ConsoleLogWarn = "warn"
+	ConsoleLogOff  = "off"
+)
+
+// ValidConsoleLog reports whether mode is a console output level.
+func ValidConsoleLog(mode string) bool {
+	return mode == ConsoleLogAll || mode == ConsoleLogWarn || mode == ConsoleLogOff
+}
+
+// ConsoleLogMode returns the effective console level for bot logs,
+// honoring the legacy disable_stdout_log switch.
+func (c *Config) ConsoleLogMode() string {
+	if c.DisableStdoutLog {
+		return ConsoleLogOff
+	}
+	return c.ConsoleLog
+}
+
+// GameServer is the gateway and client version used for one platform.
+type GameServer struct {
+	URL           string `json:"url,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+}
+
+// GameServerFor resolves the gateway URL and client version for a platform.
+func (c *Config) GameServerFor(platform string) (url, clientVersion string) {
+	url, clientVersion = c.GameServerURL, c.ClientVersion
+	for _, key := range []string{"default", platform} {
+		gs, ok := c.GameServers[key]
+		if !ok {
+			continue
+		}
+		if gs.URL != "" {
+			url = gs.URL
+		}
+		if gs.ClientVersion != "" {
+			clientVersion = gs.ClientVersion
+		}
+	}
+	return url, clientVersion
+}
+
+// AccessTokenTTL returns the access token lifetime, defaulting to 1h when
+// token_ttl is empty or invalid.
+func (c *Config) AccessTokenTTL() time.Duration {
+	return parseDurationOr(c.TokenTTL, time.Hour)
+}
+
+// RefreshTokenTTL returns the refresh token lifetime, defaulting to 30 days
+// when refresh_ttl is empty or invalid.
+func (c *Config) RefreshTokenTTL() time.Duration {
+	return parseDurationOr(c.RefreshTTL, 30*24*time.Hour)
+}
+
+// LoginWindowDuration returns the failed-login sliding window, defaulting to 15m.
+func (c *Config) LoginWindowDuration() time.Duration {
+	return parseDurationOr(c.LoginWindow, 15*time.Minute)
+}
+
+// DBSource returns the store driver and data source: the SQLite file path or
+// the Postgres connection string.
+func (c *Config) DBSource() (driver, dsn string) {
+	if c.DBDriver == "postgres" {
+		return "postgres", c.DBDSN
+	}
+	return "sqlite", c.DBPath
+}
+
+// DBDescription describes the database for startup output without
+// revealing DSN credentials.
+func (c *Config) DBDescription() string {
+	if c.DBDriver == "postgres" {
+		return "postgres"
+	}
+	return c.DBPath
+}
+
+// DBQueryTimeoutDuration returns the per-query database timeout, defaulting to 5s.
+func (c *Config) DBQueryTimeoutDuration() time.Duration {
+	return parseDurationOr(c.DBQueryTimeout, 5*time.Second)
+}
+
+// LandActionThrottleWindow returns the per-land mutation throttle window,
+// defaulting to 2s; an explicit zero disables it.
+func (c *Config) LandActionThrottleWindow() time.Duration {
+	if d, err := time.ParseDuration(c.LandActionThrottle); err == nil && d == 0 {
+		return 0
+	}
+	return parseDurationOr(c.LandActionThrottle, 2*time.Second)
+}
+
+// GameConfigReloadEvery returns the game config poll interval; 0 disables polling.
+func (c *Config) GameConfigReloadEvery() time.Duration {
+	return parseDurationOr(c.GameConfigReloadInterval, 0)
+}
+
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
 func (c *Config) ResolvePaths(baseDir string) {
 	c.DataDir = filepath.Join(baseDir, "data")
-	c.GameConfigDir = filepath.Join(baseDir, "gameConfig")
+	if c.GameConfigDir == "" {
+		c.GameConfigDir = "gameConfig"
+	}
+	if !filepath.IsAbs(c.GameConfigDir) {
+		c.GameConfigDir = filepath.Join(baseDir, c.GameConfigDir)
+	}
 	if !filepath.IsAbs(c.DBPath) {
 		c.DBPath = filepath.Join(baseDir, c.DBPath)
 	}
+	if c.LogFile != "" && !filepath.IsAbs(c.LogFile) {
+		c.LogFile = filepath.Join(baseDir, c.LogFile)
+	}
+	if c.TLSCert != "" && !filepath.IsAbs(c.TLSCert) {
+		c.TLSCert = filepath.Join(baseDir, c.TLSCert)
+	}
+	if c.TLSKey != "" && !filepath.IsAbs(c.TLSKey) {
+		c.TLSKey = filepath.Join(baseDir, c.TLSKey)
+	}
 	os.MkdirAll(c.DataDir, 0755)
 }
 
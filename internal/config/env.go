@@ -0,0 +1,77 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is prepended to the upper-cased JSON name of each config field to
+// form its environment variable, e.g. listen -> FARMBOT_LISTEN.
+const EnvPrefix = "FARMBOT_"
+
+// applyEnv overlays FARMBOT_* environment variables onto c and returns the
+// JSON names of the fields that were overridden. Lists are comma-separated.
+func (c *Config) applyEnv() ([]string, error) {
+	var overridden []string
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		raw, ok := os.LookupEnv(EnvPrefix + strings.ToUpper(name))
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), raw); err != nil {
+			return overridden, fmt.Errorf("环境变量 %s%s: %w", EnvPrefix, strings.ToUpper(name), err)
+		}
+		overridden = append(overridden, name)
+	}
+	return overridden, nil
+}
+
+func setField(f reflect.Value, raw string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		// Maps such as game_servers are given as JSON objects
+		m := reflect.New(f.Type())
+		if err := json.Unmarshal([]byte(raw), m.Interface()); err != nil {
+			return err
+		}
+		f.Set(m.Elem())
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvOverridePrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"listen": "127.0.0.1:9000",
+		"max_body_bytes": 2048,
+		"debug_endpoints_enabled": true,
+		"allowed_origins": ["https://file.example"],
+		"admin_user": "file-admin"
+	}`)
+	t.Setenv("FARMBOT_LISTEN", "0.0.0.0:9100")
+	t.Setenv("FARMBOT_MAX_BODY_BYTES", " 4096 ")
+	t.Setenv("FARMBOT_DEBUG_ENDPOINTS_ENABLED", "false")
+	t.Setenv("FARMBOT_ALLOWED_ORIGINS", " https://a.example , ,https://b.example")
+	t.Setenv("FARMBOT_GAME_SERVERS", `{"wx": {"url": "wss://wx.example/ws"}}`)
+	t.Setenv("FARMBOT_JWT_SECRET", "from-env")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := DefaultConfig()
+
+	// env > file
+	if cfg.Listen != "0.0.0.0:9100" || cfg.MaxBodyBytes != 4096 || cfg.DebugEndpointsEnabled {
+		t.Errorf("env didn't override the file: listen %q, max body %d, debug %v", cfg.Listen, cfg.MaxBodyBytes, cfg.DebugEndpointsEnabled)
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.AllowedOrigins, want) {
+		t.Errorf("allowed_origins = %q, want %q", cfg.AllowedOrigins, want)
+	}
+	if cfg.GameServers["wx"].URL != "wss://wx.example/ws" {
+		t.Errorf("game_servers = %+v", cfg.GameServers)
+	}
+	// env > defaults
+	if cfg.JWTSecret != "from-env" {
+		t.Errorf("jwt_secret = %q", cfg.JWTSecret)
+	}
+	// file > defaults
+	if cfg.AdminUser != "file-admin" {
+		t.Errorf("admin_user = %q, want the file's", cfg.AdminUser)
+	}
+	// defaults where neither is set
+	if cfg.RateLimitPerMinute != defaults.RateLimitPerMinute || cfg.TokenTTL != defaults.TokenTTL {
+		t.Errorf("unset fields lost their defaults: %d, %q", cfg.RateLimitPerMinute, cfg.TokenTTL)
+	}
+
+	want := []string{"listen", "jwt_secret", "max_body_bytes", "allowed_origins", "game_servers", "debug_endpoints_enabled"}
+	if !reflect.DeepEqual(cfg.EnvOverrides, want) {
+		t.Errorf("EnvOverrides = %q, want %q", cfg.EnvOverrides, want)
+	}
+}
+
+func TestEnvOverrideWithoutFile(t *testing.T) {
+	t.Setenv("FARMBOT_ADMIN_USER", "env-admin")
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdminUser != "env-admin" || cfg.Listen != DefaultConfig().Listen {
+		t.Fatalf("admin_user %q, listen %q", cfg.AdminUser, cfg.Listen)
+	}
+}
+
+func TestEnvOverrideEmptyValue(t *testing.T) {
+	// A set but empty variable overrides too, e.g. to clear a list
+	path := writeConfigFile(t, `{"trusted_proxies": ["10.0.0.1"], "api_key": "file-key"}`)
+	t.Setenv("FARMBOT_TRUSTED_PROXIES", "")
+	t.Setenv("FARMBOT_API_KEY", "")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.TrustedProxies) != 0 || cfg.APIKey != "" {
+		t.Fatalf("trusted_proxies %q, api_key %q", cfg.TrustedProxies, cfg.APIKey)
+	}
+}
+
+func TestEnvOverrideMalformed(t *testing.T) {
+	for _, tc := range []struct{ name, value string }{
+		{"FARMBOT_MAX_BODY_BYTES", "1MB"},
+		{"FARMBOT_MAINTENANCE_HOUR", ""},
+		{"FARMBOT_TLS_AUTO_SELF_SIGNED", "maybe"},
+		{"FARMBOT_GAME_SERVERS", `{"wx": `},
+		{"FARMBOT_GAME_SERVERS", `["wss://a.example"]`},
+	} {
+		t.Run(tc.name+"="+tc.value, func(t *testing.T) {
+			t.Setenv(tc.name, tc.value)
+			_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+			if err == nil || !strings.Contains(err.Error(), tc.name) {
+				t.Fatalf("Load error = %v, want one naming %s", err, tc.name)
+			}
+		})
+	}
+}
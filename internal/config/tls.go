@@ -0,0 +1,112 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfSignedValidity is the lifetime of generated self-signed certificates.
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// TLSEnabled reports whether the server should serve HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// PrepareTLS validates the TLS settings. With tls_auto_self_signed and no
+// explicit cert/key it points them into the data dir and generates a
+// self-signed certificate there on first start; generated reports whether a
+// new certificate was written.
+func (c *Config) PrepareTLS() (generated bool, err error) {
+	if c.TLSAutoSelfSigned && c.TLSCert == "" && c.TLSKey == "" {
+		c.TLSCert = filepath.Join(c.DataDir, "tls-cert.pem")
+		c.TLSKey = filepath.Join(c.DataDir, "tls-key.pem")
+		if _, err := os.Stat(c.TLSCert); os.IsNotExist(err) {
+			if err := generateSelfSigned(c.TLSCert, c.TLSKey, c.tlsHosts()); err != nil {
+				return false, fmt.Errorf("生成自签名证书失败: %w", err)
+			}
+			generated = true
+		}
+	}
+
+	switch {
+	case c.TLSCert == "" && c.TLSKey == "":
+		return false, nil
+	case c.TLSCert == "":
+		return false, errors.New("tls_key 已设置但缺少 tls_cert")
+	case c.TLSKey == "":
+		return false, errors.New("tls_cert 已设置但缺少 tls_key")
+	}
+	if _, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey); err != nil {
+		return false, fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	return generated, nil
+}
+
+// tlsHosts returns the names the self-signed certificate is valid for:
+// tls_hosts plus localhost and the listen address host.
+func (c *Config) tlsHosts() []string {
+	hosts := append([]string{"localhost", "127.0.0.1", "::1"}, c.TLSHosts...)
+	if host, _, err := net.SplitHostPort(c.Listen); err == nil && host != "" && host != "0.0.0.0" && host != "::" {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func generateSelfSigned(certPath, keyPath string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"qq-farm-bot"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
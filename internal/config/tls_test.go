@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// selfSignedPair generates a certificate and key in a new dir.
+func selfSignedPair(t *testing.T) (cert, key string) {
+	t.Helper()
+	dir := t.TempDir()
+	cert, key = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := generateSelfSigned(cert, key, []string{"localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestPrepareTLSAutoSelfSigned(t *testing.T) {
+	c := DefaultConfig()
+	c.DataDir = t.TempDir()
+	c.TLSAutoSelfSigned = true
+	generated, err := c.PrepareTLS()
+	if err != nil || !generated {
+		t.Fatalf("first start: generated = %v, err = %v", generated, err)
+	}
+	if !c.TLSEnabled() || filepath.Dir(c.TLSCert) != c.DataDir {
+		t.Fatalf("cert %q, key %q not in the data dir", c.TLSCert, c.TLSKey)
+	}
+
+	// A restart reuses the certificate
+	again := DefaultConfig()
+	again.DataDir = c.DataDir
+	again.TLSAutoSelfSigned = true
+	if generated, err := again.PrepareTLS(); err != nil || generated {
+		t.Fatalf("second start: generated = %v, err = %v", generated, err)
+	}
+}
+
+func TestPrepareTLS(t *testing.T) {
+	cert, key := selfSignedPair(t)
+	otherCert, _ := selfSignedPair(t)
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("not a certificate"), 0644)
+	unreadable := filepath.Join(dir, "unreadable.pem")
+	data, _ := os.ReadFile(cert)
+	os.WriteFile(unreadable, data, 0)
+
+	cases := []struct {
+		name      string
+		cert, key string
+		auto      bool
+		err       string // "" for success
+	}{
+		{name: "disabled"},
+		{name: "valid pair", cert: cert, key: key},
+		{name: "explicit pair wins over auto", cert: cert, key: key, auto: true},
+		{name: "cert without key", cert: cert, err: "缺少 tls_key"},
+		{name: "key without cert", key: key, err: "缺少 tls_cert"},
+		{name: "cert without key, auto", cert: cert, auto: true, err: "缺少 tls_key"},
+		{name: "missing cert file", cert: filepath.Join(dir, "missing.pem"), key: key, err: "加载 TLS 证书失败"},
+		{name: "missing key file", cert: cert, key: filepath.Join(dir, "missing.pem"), err: "加载 TLS 证书失败"},
+		{name: "directory as cert", cert: dir, key: key, err: "加载 TLS 证书失败"},
+		{name: "garbage cert", cert: garbage, key: key, err: "加载 TLS 证书失败"},
+		{name: "mismatched pair", cert: otherCert, key: key, err: "加载 TLS 证书失败"},
+		{name: "unreadable cert", cert: unreadable, key: key, err: "加载 TLS 证书失败"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.cert == unreadable && os.Geteuid() == 0 {
+				t.Skip("file permissions don't apply to root")
+			}
+			c := DefaultConfig()
+			c.DataDir = t.TempDir()
+			c.TLSCert, c.TLSKey, c.TLSAutoSelfSigned = tc.cert, tc.key, tc.auto
+			generated, err := c.PrepareTLS()
+			if generated {
+				t.Error("generated a certificate")
+			}
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("PrepareTLS: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("PrepareTLS error = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultJWTSecret = "qq-farm-bot-secret-change-me"
+	defaultAdminPass = "admin123"
+)
+
+// clientVersionPattern matches versions like "1.7.0.5_20260306".
+var clientVersionPattern = regexp.MustCompile(`^\d+(\.\d+)+_\d{8}$`)
+
+// Validate checks the resolved config and returns every problem at once.
+// errs must be fixed before the server can start; warnings are reported
+// but do not block startup. Call after ResolvePaths.
+func (c *Config) Validate() (errs, warnings []string) {
+	if c.JWTSecret == "" {
+		errs = append(errs, "jwt_secret 不能为空")
+	} else if c.JWTSecret == defaultJWTSecret {
+		warnings = append(warnings, "jwt_secret 仍为默认值, 请修改为随机字符串")
+	}
+	if c.AdminPass == defaultAdminPass {
+		warnings = append(warnings, "admin_pass 仍为默认密码, 请尽快修改")
+	}
+
+	if err := checkListen(c.Listen); err != nil {
+		errs = append(errs, fmt.Sprintf("listen %q 无效: %v", c.Listen, err))
+	}
+	if c.HTTPRedirectListen != "" {
+		if err := checkListen(c.HTTPRedirectListen); err != nil {
+			errs = append(errs, fmt.Sprintf("http_redirect_listen %q 无效: %v", c.HTTPRedirectListen, err))
+		}
+	}
+
+	if err := checkWSURL(c.GameServerURL); err != nil {
+		errs = append(errs, fmt.Sprintf("game_server_url %q 无效: %v", c.GameServerURL, err))
+	}
+	if !clientVersionPattern.MatchString(c.ClientVersion) {
+		warnings = append(warnings, fmt.Sprintf("client_version %q 格式异常, 应类似 1.7.0.5_20260306", c.ClientVersion))
+	}
+	for platform, gs := range c.GameServers {
+		if gs.URL != "" {
+			if err := checkWSURL(gs.URL); err != nil {
+				errs = append(errs, fmt.Sprintf("game_servers.%s.url %q 无效: %v", platform, gs.URL, err))
+			}
+		}
+		if gs.ClientVersion != "" && !clientVersionPattern.MatchString(gs.ClientVersion) {
+			warnings = append(warnings, fmt.Sprintf("game_servers.%s.client_version %q 格式异常", platform, gs.ClientVersion))
+		}
+	}
+
+	if c.NotifyWebhookURL != "" {
+		if err := checkHTTPURL(c.NotifyWebhookURL); err != nil {
+			errs = append(errs, fmt.Sprintf("notify_webhook_url %q 无效: %v", c.NotifyWebhookURL, err))
+		}
+	}
+	for _, ev := range c.NotifyEvents {
+		switch ev {
+		case "needs_relogin", "kickout", "level_up", "daily_summary":
+		default:
+			warnings = append(warnings, fmt.Sprintf("notify_events 中的 %q 未知, 将被忽略", ev))
+		}
+	}
+
+	switch c.DBDriver {
+	case "", "sqlite":
+		if err := checkWritableDir(filepath.Dir(c.DBPath)); err != nil {
+			errs = append(errs, fmt.Sprintf("db_path 所在目录不可写: %v", err))
+		}
+	case "postgres":
+		if c.DBDSN == "" {
+			errs = append(errs, "db_driver 为 postgres 时必须设置 db_dsn")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("db_driver %q 无效, 应为 sqlite/postgres", c.DBDriver))
+	}
+	if !ValidConsoleLog(c.ConsoleLog) {
+		errs = append(errs, fmt.Sprintf("console_log %q 无效, 应为 all/warn/off", c.ConsoleLog))
+	}
+	if !ValidConsoleLog(c.AccessLog) {
+		errs = append(errs, fmt.Sprintf("access_log %q 无效, 应为 all/warn/off", c.AccessLog))
+	}
+	if c.LogFile != "" {
+		if err := checkWritableDir(filepath.Dir(c.LogFile)); err != nil {
+			errs = append(errs, fmt.Sprintf("log_file 所在目录不可写: %v", err))
+		}
+	}
+
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"max_body_bytes", c.MaxBodyBytes},
+		{"rate_limit_per_minute", c.RateLimitPerMinute},
+		{"rate_limit_burst", c.RateLimitBurst},
+		{"auth_rate_limit_per_minute", c.AuthRateLimitPerMinute},
+		{"max_streams_per_user", c.MaxStreamsPerUser},
+		{"log_retention_days", c.LogRetentionDays},
+	} {
+		if n.value < 0 {
+			errs = append(errs, fmt.Sprintf("%s 不能为负数", n.name))
+		}
+	}
+	if c.GameResetHour < 0 || c.GameResetHour > 23 {
+		errs = append(errs, fmt.Sprintf("game_reset_hour %d 无效, 应为 0-23", c.GameResetHour))
+	}
+	if c.MaintenanceHour < -1 || c.MaintenanceHour > 23 {
+		errs = append(errs, fmt.Sprintf("maintenance_hour %d 无效, 应为 0-23 (-1 关闭)", c.MaintenanceHour))
+	}
+
+	for _, d := range []struct{ name, value string }{
+		{"db_query_timeout", c.DBQueryTimeout},
+		{"token_ttl", c.TokenTTL},
+		{"refresh_ttl", c.RefreshTTL},
+		{"login_window", c.LoginWindow},
+		{"game_config_reload_interval", c.GameConfigReloadInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+			warnings = append(warnings, fmt.Sprintf("%s %q 无效, 将使用默认值", d.name, d.value))
+		}
+	}
+	if c.LandActionThrottle != "" {
+		if v, err := time.ParseDuration(c.LandActionThrottle); err != nil || v < 0 {
+			warnings = append(warnings, fmt.Sprintf("land_action_throttle %q 无效, 将使用默认值", c.LandActionThrottle))
+		}
+	}
+	switch c.LogLevel {
+	case "", "debug", "info", "warn":
+	default:
+		warnings = append(warnings, fmt.Sprintf("log_level %q 无效, 应为 debug/info/warn", c.LogLevel))
+	}
+	return errs, warnings
+}
+
+func checkListen(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("端口 %q 超出范围", port)
+	}
+	return nil
+}
+
+func checkWSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("协议应为 ws:// 或 wss://")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("缺少主机名")
+	}
+	return nil
+}
+
+func checkHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("协议应为 http:// 或 https://")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("缺少主机名")
+	}
+	return nil
+}
+
+// checkWritableDir creates dir if needed and probes it with a temp file.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
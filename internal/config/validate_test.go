@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig is a config Validate accepts without warnings.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	c := DefaultConfig()
+	c.JWTSecret = "s3cret"
+	c.AdminPass = "not-the-default"
+	c.ResolvePaths(t.TempDir())
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	// A path whose parent is a file can't be created
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		modify  func(c *Config)
+		err     string // substring of the only error, "" for none
+		warning string // substring of the only warning, "" for none
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "empty durations use defaults", modify: func(c *Config) { c.TokenTTL, c.LoginWindow = "", "" }},
+		{name: "maintenance disabled", modify: func(c *Config) { c.MaintenanceHour = -1 }},
+		{name: "land throttle disabled", modify: func(c *Config) { c.LandActionThrottle = "0" }},
+		{name: "postgres with dsn", modify: func(c *Config) { c.DBDriver, c.DBDSN = "postgres", "postgres://farm@db/farm" }},
+		{name: "zero limits disable them", modify: func(c *Config) { c.MaxBodyBytes, c.RateLimitPerMinute = 0, 0 }},
+
+		// Errors
+		{name: "empty jwt secret", modify: func(c *Config) { c.JWTSecret = "" }, err: "jwt_secret 不能为空"},
+		{name: "listen without port", modify: func(c *Config) { c.Listen = "8080" }, err: "listen"},
+		{name: "listen port out of range", modify: func(c *Config) { c.Listen = ":70000" }, err: "超出范围"},
+		{name: "redirect listen", modify: func(c *Config) { c.HTTPRedirectListen = "localhost" }, err: "http_redirect_listen"},
+		{name: "game server scheme", modify: func(c *Config) { c.GameServerURL = "https://gate.example/ws" }, err: "ws:// 或 wss://"},
+		{name: "game server host", modify: func(c *Config) { c.GameServerURL = "wss:///ws" }, err: "缺少主机名"},
+		{name: "platform game server", modify: func(c *Config) { c.GameServers = map[string]GameServer{"wx": {URL: "gate.example"}} }, err: "game_servers.wx.url"},
+		{name: "webhook url", modify: func(c *Config) { c.NotifyWebhookURL = "ftp://hook.example" }, err: "notify_webhook_url"},
+		{name: "db dir not writable", modify: func(c *Config) { c.DBPath = filepath.Join(blocked, "farm.db") }, err: "db_path 所在目录不可写"},
+		{name: "postgres without dsn", modify: func(c *Config) { c.DBDriver = "postgres" }, err: "必须设置 db_dsn"},
+		{name: "unknown db driver", modify: func(c *Config) { c.DBDriver = "mysql" }, err: `db_driver "mysql"`},
+		{name: "console log", modify: func(c *Config) { c.ConsoleLog = "loud" }, err: "console_log"},
+		{name: "access log", modify: func(c *Config) { c.AccessLog = "loud" }, err: "access_log"},
+		{name: "log file dir not writable", modify: func(c *Config) { c.LogFile = filepath.Join(blocked, "farm.log") }, err: "log_file 所在目录不可写"},
+		{name: "negative max body", modify: func(c *Config) { c.MaxBodyBytes = -1 }, err: "max_body_bytes 不能为负数"},
+		{name: "negative rate limit", modify: func(c *Config) { c.RateLimitPerMinute = -1 }, err: "rate_limit_per_minute 不能为负数"},
+		{name: "negative burst", modify: func(c *Config) { c.RateLimitBurst = -1 }, err: "rate_limit_burst 不能为负数"},
+		{name: "negative auth rate limit", modify: func(c *Config) { c.AuthRateLimitPerMinute = -1 }, err: "auth_rate_limit_per_minute 不能为负数"},
+		{name: "negative streams", modify: func(c *Config) { c.MaxStreamsPerUser = -1 }, err: "max_streams_per_user 不能为负数"},
+		{name: "negative log retention", modify: func(c *Config) { c.LogRetentionDays = -1 }, err: "log_retention_days 不能为负数"},
+		{name: "reset hour too high", modify: func(c *Config) { c.GameResetHour = 24 }, err: "game_reset_hour 24"},
+		{name: "reset hour negative", modify: func(c *Config) { c.GameResetHour = -1 }, err: "game_reset_hour -1"},
+		{name: "maintenance hour too high", modify: func(c *Config) { c.MaintenanceHour = 24 }, err: "maintenance_hour 24"},
+		{name: "maintenance hour below -1", modify: func(c *Config) { c.MaintenanceHour = -2 }, err: "maintenance_hour -2"},
+
+		// Warnings
+		{name: "default jwt secret", modify: func(c *Config) { c.JWTSecret = defaultJWTSecret }, warning: "jwt_secret 仍为默认值"},
+		{name: "default admin password", modify: func(c *Config) { c.AdminPass = defaultAdminPass }, warning: "admin_pass 仍为默认密码"},
+		{name: "client version", modify: func(c *Config) { c.ClientVersion = "1.7" }, warning: `client_version "1.7"`},
+		{name: "platform client version", modify: func(c *Config) { c.GameServers = map[string]GameServer{"qq": {ClientVersion: "latest"}} }, warning: "game_servers.qq.client_version"},
+		{name: "unknown notify event", modify: func(c *Config) { c.NotifyEvents = []string{"level_up", "harvest"} }, warning: `"harvest" 未知`},
+		{name: "db query timeout", modify: func(c *Config) { c.DBQueryTimeout = "soon" }, warning: "db_query_timeout"},
+		{name: "token ttl", modify: func(c *Config) { c.TokenTTL = "0s" }, warning: "token_ttl"},
+		{name: "refresh ttl", modify: func(c *Config) { c.RefreshTTL = "-1h" }, warning: "refresh_ttl"},
+		{name: "login window", modify: func(c *Config) { c.LoginWindow = "15" }, warning: "login_window"},
+		{name: "reload interval", modify: func(c *Config) { c.GameConfigReloadInterval = "daily" }, warning: "game_config_reload_interval"},
+		{name: "land action throttle", modify: func(c *Config) { c.LandActionThrottle = "-2s" }, warning: "land_action_throttle"},
+		{name: "log level", modify: func(c *Config) { c.LogLevel = "trace" }, warning: `log_level "trace"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validConfig(t)
+			tc.modify(c)
+			errs, warnings := c.Validate()
+			check := func(kind string, got []string, want string) {
+				t.Helper()
+				if want == "" {
+					if len(got) != 0 {
+						t.Errorf("unexpected %s: %q", kind, got)
+					}
+					return
+				}
+				if len(got) != 1 || !strings.Contains(got[0], want) {
+					t.Errorf("%s = %q, want one containing %q", kind, got, want)
+				}
+			}
+			check("errors", errs, tc.err)
+			check("warnings", warnings, tc.warning)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package i18n
+
+// tagCatalog names the log tags in English.
+var tagCatalog = []struct{ zh, en string }{
+	{"农场", "Farm"},
+	{"巡田", "Patrol"},
+	{"收获", "Harvest"},
+	{"种植", "Plant"},
+	{"浇水", "Water"},
+	{"除草", "Weed"},
+	{"除虫", "Bug"},
+	{"铲除", "Remove"},
+	{"成熟", "Mature"},
+	{"施肥", "Fertilize"},
+	{"化肥", "Fertilizer"},
+	{"商店", "Shop"},
+	{"购买", "Buy"},
+	{"升级", "Upgrade"},
+	{"解锁", "Unlock"},
+	{"策略", "Strategy"},
+	{"大种子", "Big seed"},
+	{"分析", "Analyze"},
+	{"好友", "Friends"},
+	{"申请", "Requests"},
+	{"仓库", "Warehouse"},
+	{"任务", "Tasks"},
+	{"金币", "Gold"},
+	{"活动", "Event"},
+	{"新手", "Warm-up"},
+	{"手动", "Manual"},
+	{"系统", "System"},
+	{"启动", "Start"},
+	{"连接", "Connect"},
+	{"登录", "Login"},
+	{"扫码", "QR login"},
+	{"重连", "Reconnect"},
+	{"心跳", "Heartbeat"},
+	{"推送", "Push"},
+	{"通知", "Notify"},
+	{"状态", "State"},
+	{"历史", "History"},
+	{"配置", "Config"},
+	{"限流", "Throttle"},
+	{"安全", "Security"},
+}
+
+// messageCatalog lists recurring log templates. zh is the format string
+// exactly as the bot logs it under tag; en receives the matched arguments
+// as strings, so it uses %s (or %[n]s to reorder) for every argument.
+// Add an entry here along with any new recurring log line.
+var messageCatalog = []struct{ tag, zh, en string }{
+	// Farm
+	{"巡田", "检查失败: %v", "check failed: %s"},
+	{"巡田", "重新获取土地失败: %v", "failed to refetch lands: %s"},
+	{"巡田", "本轮耗时 %v, 超过间隔 %v", "pass took %s, longer than the %s interval"},
+	{"巡田", "上一轮尚未结束, 跳过本轮", "previous pass still running, skipped"},
+	{"收获", "收获 %d 块: %s", "harvested %s lands: %s"},
+	{"收获", "成熟 %d 块: %s", "%s lands mature: %s"},
+	{"收获", "解析收获结果失败: %v", "failed to parse harvest reply: %s"},
+	{"浇水", "需浇水 %d 块: %s", "%s lands need water: %s"},
+	{"除草", "需除草 %d 块: %s", "%s lands need weeding: %s"},
+	{"除虫", "需除虫 %d 块: %s", "%s lands need bug removal: %s"},
+	{"铲除", "铲除枯萎作物 %d 块: %s", "removed %s withered crops: %s"},
+	{"铲除", "释放附属地 %d 块，共腾出 %d 块", "released %s linked lands, %s lands freed in total"},
+	{"种植", "从背包种植 %d 块", "planting %s lands from the bag"},
+	{"种植", "背包种子 %s x%d → 地%s", "bag seed %s x%s → lands %s"},
+	{"种植", "商店种子 %s x%d → 地%s", "shop seed %s x%s → lands %s"},
+	{"种植", "%s 需要至少 %d 块空地才能种植，当前仅 %d 块", "%s needs at least %s empty lands, only %s available"},
+	{"成熟", "%d 块土地即将成熟: %s", "%s lands mature soon: %s"},
+	{"商店", "最佳种子: %s 价格=%d金币", "best seed: %s price=%s gold"},
+	{"商店", "%s 已达限购, 剩余 %d 块地改种其他种子", "%s hit its purchase limit, planting other seeds on the remaining %s lands"},
+	{"商店", "金币不足", "not enough gold"},
+	{"商店", "已用实时商店数据更新种子配置 (%d 种)", "updated seed config from the live shop (%s seeds)"},
+	{"商店", "保存商店数据失败: %v", "failed to save shop data: %s"},
+	{"购买", "已购买 %s种子 x%d", "bought %s seeds x%s"},
+	{"购买", "%s 价格变为 %d金币, 重试", "%s price changed to %s gold, retrying"},
+	{"购买", "附赠物品: %s", "bonus items: %s"},
+	{"购买", "购买结果中的种子(%d)与商品种子(%d)不一致, 按 %d 种植", "bought seed (%s) differs from the listed seed (%s), planting %s"},
+	{"升级", "土地#%d Lv%d→Lv%d (花费%d金币)", "land #%s Lv%s→Lv%s (cost %s gold)"},
+	{"升级", "土地#%d Lv%d→Lv%d 失败: %v", "land #%s Lv%s→Lv%s failed: %s"},
+	{"升级", "Lv%d 推荐种子: %s", "recommended seed at Lv%s: %s"},
+	{"解锁", "土地#%d 成功 (花费%d金币)", "land #%s unlocked (cost %s gold)"},
+	{"解锁", "土地#%d 失败: %v", "land #%s failed: %s"},
+	{"策略", "最快升级模式 → %s (预计%.1f小时后升级)", "fastest level-up → %s (next level in ~%s hours)"},
+	{"活动", "检测到多倍经验 (收获经验 %d, 预期 %d, ×%.0f)", "multiplied exp detected (harvest exp %s, expected %s, ×%s)"},
+	{"活动", "多倍经验已结束 (收获经验 %d, 预期 %d)", "multiplied exp ended (harvest exp %s, expected %s)"},
+	{"活动", "多倍经验(×%.0f)期间选择经验效率最高的种子 → %s", "multiplied exp (×%s): choosing the most exp-efficient seed → %s"},
+	{"金币", "金币 %d 低于储备 %d 的 %.0f%%, 进入节约模式: 只买最便宜的种子, 暂停解锁/升级土地和商城购买",
+		"gold %s is below %[3]s%% of the reserve %[2]s, conserving: cheapest seed only, no land unlocks/upgrades or mall purchases"},
+	{"金币", "金币 %d 已高于储备 %d 的 %.0f%%, 退出节约模式", "gold %s is above %[3]s%% of the reserve %[2]s, conserve mode ended"},
+	{"金币", "已关闭金币储备, 恢复正常消费", "gold reserve cleared, spending resumed"},
+
+	// Friends
+	{"好友", "巡查 %d 人 → %s", "visited %s friends → %s"},
+	{"好友", "停止偷取 %s: %s", "stopped stealing from %s: %s"},
+	{"好友", "获取好友失败: %v", "failed to fetch friends: %s"},
+	{"好友", "本轮耗时 %v, 超过间隔 %v", "pass took %s, longer than the %s interval"},
+	{"好友", "上一轮尚未结束, 跳过本轮", "previous pass still running, skipped"},
+	{"申请", "已同意 %d 人: %s", "accepted %s requests: %s"},
+
+	// Fertilizer
+	{"施肥", "本轮共施肥 %d 块地", "fertilized %s lands this pass"},
+	{"化肥", "使用化肥: 普通容器 %d→%d小时, 有机容器 %d→%d小时", "used fertilizer: normal container %s→%s h, organic container %s→%s h"},
+	{"化肥", "使用化肥失败: %v", "failed to use fertilizer: %s"},
+	{"化肥", "开启化肥礼包 x%d", "opened fertilizer packs x%s"},
+	{"化肥", "开启礼包失败: %v", "failed to open packs: %s"},
+	{"化肥", "购买化肥礼包 x%d (今日累计:%d), 点券 %d→%d", "bought fertilizer packs x%s (today: %s), coupons %s→%s"},
+	{"化肥", "购买失败: %v", "purchase failed: %s"},
+	{"化肥", "普通化肥容器已满 (%d小时), 跳过购买", "normal fertilizer container is full (%s h), not buying"},
+	{"化肥", "点券不足 (余额:%d, 价格:%d)", "not enough coupons (balance: %s, price: %s)"},
+	{"化肥", "获取背包失败: %v", "failed to fetch the bag: %s"},
+	{"化肥", "本轮耗时 %v, 超过间隔 %v", "pass took %s, longer than the %s interval"},
+	{"化肥", "上一轮尚未结束, 跳过本轮", "previous pass still running, skipped"},
+
+	// Warehouse and tasks
+	{"仓库", "出售 %s，获得 %d 金币", "sold %s for %s gold"},
+	{"仓库", "出售失败: %v", "sale failed: %s"},
+	{"仓库", "背包已满, 出售果实后重试", "bag full, retrying after selling fruit"},
+	{"仓库", "背包已满, 暂停收获 %v", "bag full, harvesting paused for %s"},
+	{"仓库", "背包已有空间, 恢复收获", "bag has room again, harvesting resumed"},
+	{"任务", "发现 %d 个可领取任务", "%s task rewards to claim"},
+	{"任务", "领取失败 #%d: %v", "claim failed #%s: %s"},
+
+	// Connection
+	{"登录", "成功 GID=%d 昵称=%s Lv%d 金币=%d", "logged in GID=%s name=%s Lv%s gold=%s"},
+	{"登录", "服务器拒绝: code=%d msg=%s", "rejected by server: code=%s msg=%s"},
+	{"登录", "登录码已失效, 需要重新扫码", "login code expired, scan the QR code again"},
+	{"系统", "升级! Lv%d → Lv%d", "level up! Lv%s → Lv%s"},
+	{"系统", "连接断开 (reason=%s)，%v 后尝试重连...", "disconnected (reason=%s), reconnecting in %s..."},
+	{"系统", "连接断开 (reason=%s)，不再重连", "disconnected (reason=%s), not reconnecting"},
+	{"系统", "登录超时累计 %d 次，停止重连", "login timed out %s times, reconnecting stopped"},
+	{"重连", "成功", "succeeded"},
+	{"重连", "失败: %v，不再重连", "failed: %s, not retrying"},
+	{"重连", "失败: %v", "failed: %s"},
+	{"心跳", "失败: %v", "failed: %s"},
+	{"心跳", "超过 %ds 无心跳响应，断开连接 (pending=%d)", "no heartbeat reply for %ss, disconnecting (pending=%s)"},
+}
@@ -0,0 +1,104 @@
+// Package i18n translates bot log lines for users who don't read Chinese.
+//
+// Log lines are written and stored in Chinese. Instead of threading message
+// keys through every call site, the catalog lists the recurring format
+// strings exactly as passed to Logger.Infof and friends; a stored line is
+// matched against the templates of its tag and re-rendered in the user's
+// locale on the way out. Lines without a template are returned unchanged.
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Supported locales. Logs are stored in Zh, the default.
+const (
+	Zh = "zh"
+	En = "en"
+)
+
+// Locales lists the supported locales.
+var Locales = []string{Zh, En}
+
+// Valid reports whether locale is supported.
+func Valid(locale string) bool {
+	for _, l := range Locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// template is one compiled catalog entry.
+type template struct {
+	re *regexp.Regexp
+	en string
+}
+
+var (
+	tagsEn    = make(map[string]string, len(tagCatalog))
+	templates = make(map[string][]template)
+)
+
+func init() {
+	for _, t := range tagCatalog {
+		tagsEn[t.zh] = t.en
+	}
+	for _, m := range messageCatalog {
+		templates[m.tag] = append(templates[m.tag], template{re: compile(m.zh), en: m.en})
+	}
+}
+
+// verbRe matches a fmt verb with optional flags, width and precision.
+var verbRe = regexp.MustCompile(`%[-+# 0]*\d*(?:\.\d+)?[a-zA-Z%]`)
+
+// compile turns a format string into an anchored regexp capturing each
+// argument: numbers for %d and %f, anything (lazily) otherwise.
+func compile(format string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range verbRe.FindAllStringIndex(format, -1) {
+		b.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		verb := format[loc[0]:loc[1]]
+		switch verb[len(verb)-1] {
+		case '%':
+			b.WriteString("%")
+		case 'd':
+			b.WriteString(`(-?\d+)`)
+		case 'f':
+			b.WriteString(`(-?[\d.]+)`)
+		default:
+			b.WriteString(`(.*?)`)
+		}
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(format[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Translate returns tag and message in locale. Unknown tags and messages
+// without a catalog template are returned as stored.
+func Translate(locale, tag, message string) (string, string) {
+	if locale != En {
+		return tag, message
+	}
+	for _, t := range templates[tag] {
+		if m := t.re.FindStringSubmatch(message); m != nil {
+			args := make([]any, len(m)-1)
+			for i, s := range m[1:] {
+				args[i] = s
+			}
+			message = fmt.Sprintf(t.en, args...)
+			break
+		}
+	}
+	if en, ok := tagsEn[tag]; ok {
+		tag = en
+	}
+	return tag, message
+}
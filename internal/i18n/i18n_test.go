@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// argVerbRe matches a fmt verb that consumes an argument, with an optional
+// explicit index. Run it on formats with %% removed.
+var argVerbRe = regexp.MustCompile(`%(?:\[\d+\])?[-+# 0]*\d*(?:\.\d+)?[a-zA-Z]`)
+
+func argVerbs(format string) []string {
+	return argVerbRe.FindAllString(strings.ReplaceAll(format, "%%", ""), -1)
+}
+
+// sampleArgs returns one distinct argument per verb of format.
+func sampleArgs(format string) []any {
+	var args []any
+	for i, verb := range argVerbs(format) {
+		switch verb[len(verb)-1] {
+		case 'd':
+			args = append(args, 10+i)
+		case 'f':
+			args = append(args, float64(10+i)+0.5)
+		default:
+			args = append(args, fmt.Sprintf("arg%d", i))
+		}
+	}
+	return args
+}
+
+func TestMessageCatalog(t *testing.T) {
+	for _, m := range messageCatalog {
+		t.Run(m.tag+" "+m.zh, func(t *testing.T) {
+			zhVerbs, enVerbs := argVerbs(m.zh), argVerbs(m.en)
+			if len(zhVerbs) != len(enVerbs) {
+				t.Fatalf("zh has %d verbs, en %d", len(zhVerbs), len(enVerbs))
+			}
+			args := sampleArgs(m.zh)
+			// The translator hands en each argument as it appears in the line
+			shown := make([]any, len(args))
+			for i, a := range args {
+				shown[i] = fmt.Sprintf(zhVerbs[i], a)
+			}
+
+			line := fmt.Sprintf(m.zh, args...)
+			tag, got := Translate(En, m.tag, line)
+			if want := fmt.Sprintf(m.en, shown...); got != want {
+				t.Errorf("Translate(%q) = %q, want %q", line, got, want)
+			}
+			if tag != tagsEn[m.tag] || tag == "" {
+				t.Errorf("tag %s translated to %q", m.tag, tag)
+			}
+			if _, zh := Translate(Zh, m.tag, line); zh != line {
+				t.Errorf("zh line changed to %q", zh)
+			}
+		})
+	}
+}
+
+// TestMessageCatalogMatchesCallSites checks each zh template still appears
+// as a string literal in the code, so a reworded log line fails here instead
+// of silently going untranslated.
+func TestMessageCatalogMatchesCallSites(t *testing.T) {
+	literals := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, dir := range []string{"../bot", "../api", "../auth", "../../cmd/server"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					if s, err := strconv.Unquote(lit.Value); err == nil {
+						literals[s] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	for _, m := range messageCatalog {
+		if !literals[m.zh] {
+			t.Errorf("no call site logs %q under %s", m.zh, m.tag)
+		}
+	}
+}
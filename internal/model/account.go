@@ -12,10 +12,41 @@ type Account struct {
 	AutoStart bool   `json:"auto_start"` // auto start bot on server launch
 
 	// Bot config
-	FarmInterval   int  `json:"farm_interval"`   // farm check seconds
-	FriendInterval int  `json:"friend_interval"` // friend check seconds
-	EnableSteal    bool `json:"enable_steal"`
-	ForceLowest    bool `json:"force_lowest"` // force lowest level crop
+	FarmInterval   int `json:"farm_interval"`   // farm check seconds
+	FriendInterval int `json:"friend_interval"` // friend check seconds
+	// Seconds between full friend list fetches; visits in between use the
+	// cached list (0 = fetch every friend check)
+	FriendListRefreshInterval int `json:"friend_list_refresh_interval"`
+	// Seconds between bag sweeps when nothing was harvested; a harvest
+	// triggers a sale right away
+	WarehouseInterval int  `json:"warehouse_interval"`
+	EnableSteal       bool `json:"enable_steal"`
+	// Running accounts of the same user and family never steal from each
+	// other and help each other first (0 = no family)
+	FamilyID    int64 `json:"family_id"`
+	ForceLowest bool  `json:"force_lowest"` // force lowest level crop
+	// ForceLowest floors: skip seeds below this per-season exp or above this
+	// price (0 = no limit)
+	ForceLowestMinExp   int `json:"force_lowest_min_exp"`
+	ForceLowestMaxPrice int `json:"force_lowest_max_price"`
+	// On level-up, remove crops still in their first phase when the new best
+	// seed's exp/hour beats theirs by ReplantMarginPct percent
+	ReplantOnLevelUp bool `json:"replant_on_level_up"`
+	ReplantMarginPct int  `json:"replant_margin_pct"`
+	// Below this level the bot plays a cautious beginner profile (0 = off)
+	WarmUpLevel int `json:"warm_up_level"`
+	// Gold below 80% of this pauses spending beyond the cheapest seed until
+	// it is back above 120% (0 = off)
+	GoldReserve int64 `json:"gold_reserve"`
+	// Log and notify (mature_soon) this many minutes before a crop matures
+	// (0 = off)
+	NotifyBeforeMatureMinutes int `json:"notify_before_mature_minutes"`
+
+	// Consecutive login failures, persisted so a restart doesn't retry a
+	// failing account right away. Cleared on login or when the code changes.
+	LoginFailReason string     `json:"login_fail_reason,omitempty"`
+	LoginFailCount  int        `json:"login_fail_count,omitempty"`
+	LoginRetryAt    *time.Time `json:"login_retry_at,omitempty"`
 
 	// Farm automation toggles (all default true for backward compatibility)
 	EnableHarvest     bool `json:"enable_harvest"`
@@ -33,6 +64,8 @@ type Account struct {
 	PlantCropID  int    `json:"plant_crop_id"`  // specific crop to plant (0 = auto select)
 	SellCropIDs  string `json:"sell_crop_ids"`  // comma-separated crop IDs to sell (empty = all)
 	StealCropIDs string `json:"steal_crop_ids"` // comma-separated crop IDs to steal (empty = all)
+	// Crops left standing when mature (event/quest crops); still tended
+	NoHarvestCropIDs string `json:"no_harvest_crop_ids"`
 
 	// Fertilizer config
 	AutoUseFertilizer       bool `json:"auto_use_fertilizer"`
@@ -42,6 +75,8 @@ type Account struct {
 
 	// Anti-detection
 	EnableAntiDetection bool `json:"enable_anti_detection"`
+	// Worker loop sleeps vary randomly by up to this percent either way
+	IntervalJitterPct int `json:"interval_jitter_pct"`
 	// Planting preference
 	PreferBagSeeds bool `json:"prefer_bag_seeds"` // prioritize planting seeds from bag
 
@@ -51,13 +86,82 @@ type Account struct {
 	// Debug
 	EnableDebugLog bool `json:"enable_debug_log"`
 
+	// Log storage: minimum level written to the database ("" = server default)
+	// and comma-separated tags whose debug/info lines are not stored
+	LogLevel        string `json:"log_level"`
+	LogTagBlacklist string `json:"log_tag_blacklist"`
+
+	// Grouping (comma-separated tags)
+	Tags string `json:"tags"`
+
+	// Free-form notes and the position in the account list (lower first)
+	Notes     string `json:"notes"`
+	SortOrder int    `json:"sort_order"`
+
+	// Set while the account is in the recycle bin
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Extra "service.method" patterns the bot must never call (comma-separated,
+	// '*' wildcards), on top of the built-in griefing denials
+	RPCDeny string `json:"rpc_deny"`
+
+	// Game gateway URL used instead of the platform default (e.g. a staging gate)
+	ServerURLOverride string `json:"server_url_override"`
+
+	// Notification webhook used instead of the server-wide notify_webhook_url
+	NotifyWebhookURL string `json:"notify_webhook_url"`
+
 	// External API
 	APIKey    string    `json:"api_key"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// LoginRetryPending reports whether the account failed to log in and its
+// next retry is still in the future.
+func (a *Account) LoginRetryPending(now time.Time) bool {
+	return a.LoginRetryAt != nil && a.LoginRetryAt.After(now)
+}
+
+// CloneSettings returns a new, unsaved account with every setting of a.
+// The struct is copied whole so fields added later are cloned by default;
+// only identity, credentials and bookkeeping are reset here.
+func (a *Account) CloneSettings() *Account {
+	c := *a
+	c.ID = 0
+	c.Name = a.Name + " 副本"
+	c.Code = ""         // login codes are per game account
+	c.AutoStart = false // the clone has no code to start with
+	c.APIKey = ""       // API keys identify a single account
+	c.LoginFailReason, c.LoginFailCount, c.LoginRetryAt = "", 0, nil
+	c.DeletedAt = nil
+	c.CreatedAt = time.Time{}
+	c.UpdatedAt = time.Time{}
+	return &c
+}
+
+// ConfigHealth values reported in BotStatus.
+const (
+	ConfigHealthLevelTableMissing = "level_table_missing"
+	ConfigHealthGameConfigMissing = "game_config_missing"
+)
+
+// Land events reported as BotStatus.NextEventType.
+const (
+	LandEventMature  = "mature"
+	LandEventDry     = "dry"
+	LandEventWeeds   = "weeds"
+	LandEventInsects = "insects"
+)
+
 // BotStatus represents the runtime status of a bot instance.
+// BotStatus warnings.
+const (
+	WarningBagFull        = "bag_full"         // harvests fail for lack of bag room
+	WarningConserve       = "conserve"         // gold below the reserve, spending paused
+	WarningSeedBelowFloor = "seed_below_floor" // no seed passes the ForceLowest floors, lowest seed planted
+)
+
 type BotStatus struct {
 	AccountID int64      `json:"account_id"`
 	Running   bool       `json:"running"`
@@ -66,15 +170,45 @@ type BotStatus struct {
 	Level     int64      `json:"level,omitempty"`
 	Exp       int64      `json:"exp,omitempty"`
 	Gold      int64      `json:"gold,omitempty"`
+	Coupons   int64      `json:"coupons,omitempty"`
 	Platform  string     `json:"platform,omitempty"`
 	StartedAt *time.Time `json:"started_at,omitempty"`
 	Error     string     `json:"error,omitempty"`
+	// Connection health: a new login code is required, or reconnects have
+	// failed this many times in a row
+	NeedsRelogin      bool `json:"needs_relogin,omitempty"`
+	ReconnectFailures int  `json:"reconnect_failures,omitempty"`
 
 	// Exp tracking for level up estimation
 	ExpRatePerHour   float64 `json:"exp_rate_per_hour,omitempty"`
 	NextLevelExp     int64   `json:"next_level_exp,omitempty"`
 	ExpToNextLevel   int64   `json:"exp_to_next_level,omitempty"`
 	HoursToNextLevel float64 `json:"hours_to_next_level,omitempty"`
+	// Breakdown of the estimate: crop and steal exp rates (summing to
+	// ExpRatePerHour) and exp waiting in claimable tasks
+	CropExpPerHour  float64 `json:"crop_exp_per_hour,omitempty"`
+	StealExpPerHour float64 `json:"steal_exp_per_hour,omitempty"`
+	PendingTaskExp  int64   `json:"pending_task_exp,omitempty"`
+	// Exp measured from harvest replies since start and its smoothed rate,
+	// to check CropExpPerHour against
+	HarvestExp             int64            `json:"harvest_exp,omitempty"`
+	MeasuredCropExpPerHour float64          `json:"measured_crop_exp_per_hour,omitempty"`
+	HarvestItems           map[string]int64 `json:"harvest_items,omitempty"` // crop name → fruits
+
+	// Server events (double exp, ...) currently inferred as running
+	GameEvents []GameEvent `json:"game_events,omitempty"`
+	// Conditions holding the bot back, e.g. WarningBagFull
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Problems with the loaded game config affecting this bot (empty when healthy)
+	ConfigHealth string `json:"config_health,omitempty"`
+
+	// Earliest upcoming land event (LandEvent*) and the next farm check
+	NextEventAt     *time.Time `json:"next_event_at,omitempty"`
+	NextEventType   string     `json:"next_event_type,omitempty"`
+	NextFarmCheckAt *time.Time `json:"next_farm_check_at,omitempty"`
+	// Pass durations of the "farm", "friend" and "fertilizer" loops
+	Cycles map[string]CycleTiming `json:"cycles,omitempty"`
 
 	// Farm stats
 	TotalHarvest  int64        `json:"total_harvest"`
@@ -86,6 +220,16 @@ type BotStatus struct {
 	Lands         []LandStatus `json:"lands,omitempty"`
 }
 
+// CycleTiming is how long the passes of a worker loop take, for tuning its
+// interval.
+type CycleTiming struct {
+	LastMs   int64 `json:"last_ms"`
+	AvgMs    int64 `json:"avg_ms"` // moving average weighted to recent passes
+	Passes   int64 `json:"passes"`
+	Overruns int64 `json:"overruns,omitempty"` // passes longer than the interval
+	Skipped  int64 `json:"skipped,omitempty"`  // passes dropped while one was in flight
+}
+
 // LandStatus represents the status of a single farm land.
 type LandStatus struct {
 	ID       int64  `json:"id"`
@@ -117,7 +261,10 @@ type LandStatus struct {
 	DryNum        int64 `json:"dry_num,omitempty"`
 	HasWeeds      bool  `json:"has_weeds,omitempty"`
 	HasInsects    bool  `json:"has_insects,omitempty"`
+	NeedsWater    bool  `json:"needs_water,omitempty"` // dry now (dry_num or the phase's dry time passed)
+	Fertilized    bool  `json:"fertilized,omitempty"`  // fertilizer used in the current phase
 	FertTimesLeft int64 `json:"fert_times_left,omitempty"`
+	Kept          bool  `json:"kept,omitempty"` // crop is in no_harvest_crop_ids
 
 	// Debug: land meta
 	CouldUpgrade bool  `json:"could_upgrade,omitempty"`
@@ -131,8 +278,31 @@ type LogEntry struct {
 	AccountID int64     `json:"account_id"`
 	Tag       string    `json:"tag"`
 	Message   string    `json:"message"`
-	Level     string    `json:"level"` // "info", "warn", "error"
+	Level     string    `json:"level"` // "debug", "info", "warn", "error"
 	CreatedAt time.Time `json:"created_at"`
+
+	// Request id of the API action that caused the entry (empty otherwise)
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// LogLevelRank orders log levels; unknown levels rank as info.
+func LogLevelRank(level string) int {
+	switch level {
+	case "debug":
+		return 0
+	case "warn":
+		return 2
+	case "error":
+		return 3
+	default:
+		return 1
+	}
+}
+
+// ValidLogLevel reports whether level is accepted as a storage threshold.
+// Warnings and errors are always stored, so "warn" is the highest threshold.
+func ValidLogLevel(level string) bool {
+	return level == "debug" || level == "info" || level == "warn"
 }
 
 // OpRecord represents a single operation statistics record.
@@ -141,7 +311,7 @@ type OpRecord struct {
 	AccountID int64     `json:"account_id"`
 	OpType    string    `json:"op_type"`    // harvest, plant, sell, steal, weed, bug, water, fertilize, task_claim, fert_buy, fert_open, fert_use, unlock_land, upgrade_land
 	Count     int64     `json:"count"`      // number of items/lands in this operation
-	GoldDelta int64     `json:"gold_delta"` // gold change: positive=earned, negative=spent
+	GoldDelta int64     `json:"gold_delta"` // gold change: positive=earned, negative=spent; for steal, the stolen fruit's sell value
 	ExpDelta  int64     `json:"exp_delta"`  // exp earned
 	Detail    string    `json:"detail"`     // optional: crop name (sell), friend name (steal), etc.
 	CreatedAt time.Time `json:"created_at"`
@@ -169,6 +339,59 @@ const (
 	OpBuySeed     = "buy_seed"
 )
 
+// SpendRecord is one gold payment of an account, kept for cost accounting.
+type SpendRecord struct {
+	ID        int64     `json:"id"`
+	AccountID int64     `json:"account_id"`
+	Category  string    `json:"category"` // one of the Spend* constants
+	Amount    int64     `json:"amount"`   // gold paid, positive
+	Detail    string    `json:"detail"`   // e.g. "白萝卜种子×6", "土地#3 Lv1→Lv2"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Spend categories.
+const (
+	SpendSeed        = "seed"
+	SpendLandUnlock  = "land_unlock"
+	SpendLandUpgrade = "land_upgrade"
+)
+
+// DailySummary is one account's end-of-day snapshot used for history charts.
+// Date is the game day (server time, UTC+8) formatted as 2006-01-02.
+type DailySummary struct {
+	AccountID    int64     `json:"account_id"`
+	Date         string    `json:"date"`
+	Level        int64     `json:"level"`
+	Gold         int64     `json:"gold"`
+	Exp          int64     `json:"exp"`
+	TotalHarvest int64     `json:"total_harvest"` // harvests during the day
+	TotalSteal   int64     `json:"total_steal"`   // steals during the day
+	TotalHelp    int64     `json:"total_help"`    // friend help actions during the day
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// FriendGrief counts the weeds and insects one friend left on an account's
+// lands during a game day (counted when the bot cleared them).
+type FriendGrief struct {
+	AccountID  int64  `json:"account_id"`
+	Date       string `json:"date"`
+	FriendGID  int64  `json:"friend_gid"`
+	FriendName string `json:"friend_name"`
+	Weeds      int64  `json:"weeds"`
+	Insects    int64  `json:"insects"`
+}
+
+// TodayCounters is an account's activity during the current game day.
+type TodayCounters struct {
+	Harvest    int64 `json:"harvest"`
+	Steal      int64 `json:"steal"`
+	Help       int64 `json:"help"`
+	ExpGained  int64 `json:"exp_gained"`
+	GoldGained int64 `json:"gold_gained"`
+	SellGold   int64 `json:"sell_gold"`   // warehouse sale proceeds
+	StealValue int64 `json:"steal_value"` // sell value of stolen fruit
+}
+
 // AggregatedStats represents aggregated operation statistics for a time bucket.
 type AggregatedStats struct {
 	Period    string           `json:"period"`     // time bucket label, e.g. "2026-03-09 10:00"
@@ -177,3 +400,26 @@ type AggregatedStats struct {
 	GoldOut   int64            `json:"gold_out"`   // total gold spent (absolute)
 	ExpGained int64            `json:"exp_gained"` // total exp earned
 }
+
+// Bag item categories.
+const (
+	BagFruit      = "fruit"
+	BagSeed       = "seed"
+	BagFertilizer = "fertilizer"
+	BagCurrency   = "currency"
+	BagOther      = "other"
+)
+
+// BagItem is one stack in the account's bag.
+type BagItem struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+	Category string `json:"category"`
+}
+
+// BagSnapshot is the last fetched bag of an account.
+type BagSnapshot struct {
+	Items     []BagItem `json:"items"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLandStatusJSON(t *testing.T) {
+	// An empty land carries only its identity and flags the UI always reads
+	empty, err := json.Marshal(LandStatus{ID: 3, Level: 1, MaxLevel: 4, Unlocked: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(empty), `{"id":3,"level":1,"max_level":4,"unlocked":true}`; got != want {
+		t.Errorf("empty land = %s, want %s", got, want)
+	}
+
+	growing := LandStatus{
+		ID: 3, Level: 2, MaxLevel: 4, Unlocked: true,
+		CropName: "白萝卜", CropID: 1020002, Phase: "发芽", Season: 1,
+		MatureTimeSec: 1800000600, TimeReducePct: 1000,
+		DryNum: 1, NeedsWater: true, Fertilized: true, FertTimesLeft: 2, Kept: true,
+	}
+	data, err := json.Marshal(growing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":3,"level":2,"max_level":4,"unlocked":true,"crop_name":"白萝卜","crop_id":1020002,"phase":"发芽",` +
+		`"season":1,"mature_time_sec":1800000600,"time_reduce_pct":1000,` +
+		`"dry_num":1,"needs_water":true,"fertilized":true,"fert_times_left":2,"kept":true}`
+	if string(data) != want {
+		t.Errorf("growing land =\n%s\nwant\n%s", data, want)
+	}
+
+	var back LandStatus
+	if err := json.Unmarshal(data, &back); err != nil || back != growing {
+		t.Fatalf("round trip = %+v, %v", back, err)
+	}
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Activity event types written to the per-account activity feed.
+const (
+	ActivityLevelUp      = "level_up"
+	ActivityLandUnlock   = "land_unlock"
+	ActivityLandUpgrade  = "land_upgrade"
+	ActivityGoldChange   = "gold_change"
+	ActivityBotStart     = "bot_start"
+	ActivityBotStop      = "bot_stop"
+	ActivityDisconnect   = "disconnect"
+	ActivityReconnect    = "reconnect"
+	ActivityNeedsRelogin = "needs_relogin"
+	ActivityCouponSpend  = "coupon_spend"
+	ActivityGameEvent    = "game_event" // a server event started or ended
+	ActivityReplant      = "replant"    // young crops removed for a better seed after level-up
+)
+
+// ActivityEvent is one notable, typed event in an account's history.
+// Payload is a type-specific JSON object.
+type ActivityEvent struct {
+	ID        int64           `json:"id"`
+	AccountID int64           `json:"account_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ActivityTypes lists every activity event type, for filter validation.
+var ActivityTypes = []string{
+	ActivityLevelUp, ActivityLandUnlock, ActivityLandUpgrade, ActivityGoldChange,
+	ActivityBotStart, ActivityBotStop, ActivityDisconnect, ActivityReconnect, ActivityNeedsRelogin,
+	ActivityCouponSpend, ActivityGameEvent, ActivityReplant,
+}
+
+// GameEvent is a server-wide event (double exp, ...) the bot believes is
+// running, as inferred from server replies.
+type GameEvent struct {
+	Kind       string    `json:"kind"`
+	Multiplier float64   `json:"multiplier,omitempty"`
+	Since      time.Time `json:"since"`
+	LastSeen   time.Time `json:"last_seen"` // last observation confirming it
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Audit actions recorded for sensitive operations.
+const (
+	AuditAccountCreate  = "account.create"
+	AuditAccountUpdate  = "account.update"
+	AuditAccountDelete  = "account.delete"
+	AuditAccountRestore = "account.restore"
+	AuditAccountCode    = "account.code"
+	AuditBotStart       = "bot.start"
+	AuditBotStop        = "bot.stop"
+	AuditQRLogin        = "qr.login"
+	AuditPasswordChange = "user.change_password"
+	AuditPasswordReset  = "user.reset_password"
+	AuditConfigReload   = "gameconfig.reload"
+	AuditImpersonate    = "user.impersonate"
+	AuditTokenCreate    = "token.create"
+	AuditTokenRevoke    = "token.revoke"
+)
+
+// AuditEntry records who performed a sensitive action and on what.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	AccountID int64     `json:"account_id"` // 0 when the action has no target account
+	Summary   string    `json:"summary"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseCropIDs parses a comma-separated crop ID list (sell_crop_ids,
+// steal_crop_ids) into a set. Blank and invalid entries are skipped, so an
+// empty result means "no filter".
+func ParseCropIDs(s string) map[int]bool {
+	result := make(map[int]bool)
+	if s == "" {
+		return result
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if id, err := strconv.Atoi(part); err == nil && id > 0 {
+			result[id] = true
+		}
+	}
+	return result
+}
+
+// CheckCropIDs reports the first entry of a crop ID list that ParseCropIDs
+// would skip, so lists are rejected when saved instead of silently narrowed.
+func CheckCropIDs(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if id, err := strconv.Atoi(part); err != nil || id <= 0 {
+			return fmt.Errorf("invalid crop id %q", part)
+		}
+	}
+	return nil
+}
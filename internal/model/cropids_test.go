@@ -0,0 +1,49 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCropIDs(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want []int
+		err  string // CheckCropIDs error, "" for none
+	}{
+		{name: "empty", in: ""},
+		{name: "whitespace only", in: "  \t "},
+		{name: "single", in: "1020002", want: []int{1020002}},
+		{name: "list", in: "1,2,3", want: []int{1, 2, 3}},
+		{name: "whitespace around entries", in: " 1 ,\t2 , 3\n", want: []int{1, 2, 3}},
+		{name: "duplicates", in: "5,5, 5,6", want: []int{5, 6}},
+		{name: "blank entries", in: "1,,2,", want: []int{1, 2}, err: `invalid crop id ""`},
+		{name: "non-numeric", in: "1,abc,2", want: []int{1, 2}, err: `invalid crop id "abc"`},
+		{name: "trailing garbage", in: "12x", err: `invalid crop id "12x"`},
+		{name: "zero", in: "0,3", want: []int{3}, err: `invalid crop id "0"`},
+		{name: "negative", in: "-4", err: `invalid crop id "-4"`},
+		{name: "fractional", in: "1.5", err: `invalid crop id "1.5"`},
+		{name: "semicolons", in: "1;2", err: `invalid crop id "1;2"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := make(map[int]bool)
+			for _, id := range tc.want {
+				want[id] = true
+			}
+			if got := ParseCropIDs(tc.in); !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseCropIDs(%q) = %v, want %v", tc.in, got, want)
+			}
+
+			err := CheckCropIDs(tc.in)
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("CheckCropIDs(%q) = %v", tc.in, err)
+				}
+			} else if err == nil || err.Error() != tc.err {
+				t.Errorf("CheckCropIDs(%q) = %v, want %s", tc.in, err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// MaintenanceReport describes one run of the daily database maintenance.
+type MaintenanceReport struct {
+	StartedAt   time.Time `json:"started_at"`
+	DurationMS  int64     `json:"duration_ms"`
+	LogsDeleted int64     `json:"logs_deleted"`
+	Vacuumed    bool      `json:"vacuumed"`
+	SizeBefore  int64     `json:"size_before"` // bytes
+	SizeAfter   int64     `json:"size_after"`
+	Error       string    `json:"error,omitempty"`
+}
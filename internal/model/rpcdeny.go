@@ -0,0 +1,33 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ParseRPCPatterns splits a comma- or newline-separated list of
+// "service.method" patterns into trimmed, non-empty entries. '*' matches
+// any run of characters, e.g. "gamepb.mallpb.*" or "*.PutWeeds".
+func ParseRPCPatterns(s string) []string {
+	var patterns []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			patterns = append(patterns, part)
+		}
+	}
+	return patterns
+}
+
+// CheckRPCPatterns reports the first malformed entry of a pattern list.
+func CheckRPCPatterns(s string) error {
+	for _, p := range ParseRPCPatterns(s) {
+		if !strings.Contains(p, ".") {
+			return fmt.Errorf("%q is not a service.method pattern", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%q: %v", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const maxTagLen = 32
+
+// ParseTags splits a comma-separated tag string into trimmed, non-empty tags.
+func ParseTags(s string) []string {
+	var tags []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			tags = append(tags, part)
+		}
+	}
+	return tags
+}
+
+// NormalizeTags validates and de-duplicates a comma-separated tag string.
+// Tags may contain letters (including CJK), digits, '-' and '_'.
+func NormalizeTags(s string) (string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range ParseTags(s) {
+		if len([]rune(tag)) > maxTagLen {
+			return "", fmt.Errorf("tag %q too long (max %d chars)", tag, maxTagLen)
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+				return "", fmt.Errorf("tag %q contains invalid character %q", tag, r)
+			}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return strings.Join(out, ","), nil
+}
+
+// HasTag reports whether the comma-separated tag string contains tag.
+func HasTag(tags, tag string) bool {
+	for _, t := range ParseTags(tags) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -8,5 +8,32 @@ type User struct {
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"-"` // Never expose password hash in JSON
 	IsAdmin      bool      `json:"is_admin"`
+	Locale       string    `json:"locale"` // log language, see package i18n
 	CreatedAt    time.Time `json:"created_at"`
 }
+
+// Session is a refresh-token session. Only the SHA-256 hash of the refresh
+// token is stored so a leaked database cannot be used to mint access tokens.
+type Session struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Revoked   bool      `json:"revoked"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIToken is a long-lived personal access token for scripts. Like sessions,
+// only the SHA-256 hash of the secret is stored; Prefix is the start of the
+// secret so users can tell their tokens apart.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // nil never expires
+	AccountID  int64      `json:"account_id,omitempty"` // only this account; 0 all of the user's
+}
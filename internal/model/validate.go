@@ -0,0 +1,130 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bounds for account settings.
+const (
+	MinIntervalSec = 3
+	MaxIntervalSec = 3600
+	MaxNotesLen    = 2000 // characters
+
+	DefaultFriendListRefreshSec = 600
+	MaxFriendListRefreshSec     = 86400
+
+	DefaultReplantMarginPct = 20
+	MaxReplantMarginPct     = 1000
+
+	MaxWarmUpLevel = 50
+
+	MaxNotifyBeforeMatureMinutes = 24 * 60
+
+	DefaultIntervalJitterPct = 15
+	MaxIntervalJitterPct     = 50
+
+	DefaultWarehouseIntervalSec = 600
+	MinWarehouseIntervalSec     = 60
+	MaxWarehouseIntervalSec     = 86400
+)
+
+// ValidPlatform reports whether p is a supported login platform.
+func ValidPlatform(p string) bool {
+	return p == "qq" || p == "wx"
+}
+
+// FieldError is a validation failure of one request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every invalid field of a request.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationErrors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the account settings that would otherwise only fail or be
+// clamped at runtime. It returns nil when the account is valid.
+func (a *Account) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if !ValidPlatform(a.Platform) {
+		errs.add("platform", "must be qq or wx")
+	}
+	if a.FarmInterval < MinIntervalSec || a.FarmInterval > MaxIntervalSec {
+		errs.add("farm_interval", "must be between %d and %d seconds", MinIntervalSec, MaxIntervalSec)
+	}
+	if a.FriendInterval < MinIntervalSec || a.FriendInterval > MaxIntervalSec {
+		errs.add("friend_interval", "must be between %d and %d seconds", MinIntervalSec, MaxIntervalSec)
+	}
+	if a.FriendListRefreshInterval < 0 || a.FriendListRefreshInterval > MaxFriendListRefreshSec {
+		errs.add("friend_list_refresh_interval", "must be between 0 and %d seconds", MaxFriendListRefreshSec)
+	}
+	if a.WarehouseInterval < MinWarehouseIntervalSec || a.WarehouseInterval > MaxWarehouseIntervalSec {
+		errs.add("warehouse_interval", "must be between %d and %d seconds", MinWarehouseIntervalSec, MaxWarehouseIntervalSec)
+	}
+	if a.ReplantMarginPct < 0 || a.ReplantMarginPct > MaxReplantMarginPct {
+		errs.add("replant_margin_pct", "must be between 0 and %d", MaxReplantMarginPct)
+	}
+	if a.WarmUpLevel < 0 || a.WarmUpLevel > MaxWarmUpLevel {
+		errs.add("warm_up_level", "must be between 0 and %d", MaxWarmUpLevel)
+	}
+	if a.NotifyBeforeMatureMinutes < 0 || a.NotifyBeforeMatureMinutes > MaxNotifyBeforeMatureMinutes {
+		errs.add("notify_before_mature_minutes", "must be between 0 and %d", MaxNotifyBeforeMatureMinutes)
+	}
+	if a.GoldReserve < 0 {
+		errs.add("gold_reserve", "must not be negative")
+	}
+	if a.FamilyID < 0 {
+		errs.add("family_id", "must not be negative")
+	}
+	if a.IntervalJitterPct < 0 || a.IntervalJitterPct > MaxIntervalJitterPct {
+		errs.add("interval_jitter_pct", "must be between 0 and %d", MaxIntervalJitterPct)
+	}
+	if a.PlantCropID < 0 {
+		errs.add("plant_crop_id", "must not be negative")
+	}
+	if a.ForceLowestMinExp < 0 {
+		errs.add("force_lowest_min_exp", "must not be negative")
+	}
+	if a.ForceLowestMaxPrice < 0 {
+		errs.add("force_lowest_max_price", "must not be negative")
+	}
+	if a.FertilizerTargetCount < 0 {
+		errs.add("fertilizer_target_count", "must not be negative")
+	}
+	if a.FertilizerBuyDailyLimit < 0 {
+		errs.add("fertilizer_buy_daily_limit", "must not be negative")
+	}
+	if err := CheckCropIDs(a.SellCropIDs); err != nil {
+		errs.add("sell_crop_ids", "%v", err)
+	}
+	if err := CheckCropIDs(a.StealCropIDs); err != nil {
+		errs.add("steal_crop_ids", "%v", err)
+	}
+	if err := CheckCropIDs(a.NoHarvestCropIDs); err != nil {
+		errs.add("no_harvest_crop_ids", "%v", err)
+	}
+	if err := CheckRPCPatterns(a.RPCDeny); err != nil {
+		errs.add("rpc_deny", "%v", err)
+	}
+	if n := utf8.RuneCountInString(a.Notes); n > MaxNotesLen {
+		errs.add("notes", "must be at most %d characters", MaxNotesLen)
+	}
+	if a.LogLevel != "" && !ValidLogLevel(a.LogLevel) {
+		errs.add("log_level", "must be debug, info or warn")
+	}
+	return errs
+}
@@ -0,0 +1,113 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// ISO/IEC 18004 Annex I: "01234567" at 1-M
+	data := []byte{16, 32, 12, 86, 97, 128, 236, 17, 236, 17, 236, 17, 236, 17, 236, 17}
+	want := []byte{165, 36, 212, 193, 237, 54, 199, 135, 44, 85}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("1-M remainder = %v, want %v", got, want)
+	}
+	// "HELLO WORLD" at 1-Q, as worked through in the common QR tutorials
+	data = []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236}
+	want = []byte{168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16}
+	if got := rsRemainder(data, rsDivisor(13)); !bytes.Equal(got, want) {
+		t.Errorf("1-Q remainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// Level M rows of the format information table
+	want := [8]int{
+		0b101010000010010,
+		0b101000100100101,
+		0b101111001111100,
+		0b101101101001011,
+		0b100010111111001,
+		0b100000011001110,
+		0b100111110010111,
+		0b100101010100000,
+	}
+	for mask, w := range want {
+		if got := formatBits(mask); got != w {
+			t.Errorf("mask %d: format bits %015b, want %015b", mask, got, w)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	for ver, want := range map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3} {
+		if got := versionBits(ver); got != want {
+			t.Errorf("version %d: bits %018b, want %018b", ver, got, want)
+		}
+	}
+}
+
+func TestEncodeGolden(t *testing.T) {
+	// "HELLO" in byte mode at 1-M; mask 4 has the lowest penalty. Checked
+	// with an independent decoder (format, RS syndromes, payload)
+	golden := []string{
+		"#######.##.#..#######",
+		"#.....#..##.#.#.....#",
+		"#.###.#..####.#.###.#",
+		"#.###.#.#..#..#.###.#",
+		"#.###.#.#...#.#.###.#",
+		"#.....#.#.##..#.....#",
+		"#######.#.#.#.#######",
+		"........#####........",
+		"#...#.######.#####..#",
+		"...###..#.###..#.####",
+		"#.##..#.#.##..###..#.",
+		"###..#...#...##.#....",
+		"..#.###..#..###...##.",
+		"........###.###..#.##",
+		"#######.##..##...#.#.",
+		"#.....#....##..#...#.",
+		"#.###.#.#..#..###.#.#",
+		"#.###.#....##....#.##",
+		"#.###.#..###..####...",
+		"#.....#..#...##......",
+		"#######.#...#####.#.#",
+	}
+	c, err := Encode("HELLO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != len(golden) {
+		t.Fatalf("size = %d, want %d", c.Size, len(golden))
+	}
+	for y, want := range golden {
+		var row strings.Builder
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				row.WriteByte('#')
+			} else {
+				row.WriteByte('.')
+			}
+		}
+		if row.String() != want {
+			t.Errorf("row %2d = %s\n          want %s", y, row.String(), want)
+		}
+	}
+}
+
+func TestEncodeCapacity(t *testing.T) {
+	// Version 10-M holds 216 data codewords: 4 mode bits, a 16-bit count
+	// and 213 bytes
+	c, err := Encode(strings.Repeat("a", 213))
+	if err != nil {
+		t.Fatalf("213 bytes: %v", err)
+	}
+	if c.Size != 10*4+17 {
+		t.Errorf("213 bytes: size %d, want version 10", c.Size)
+	}
+	if _, err := Encode(strings.Repeat("a", 214)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("214 bytes: err = %v, want %v", err, ErrTooLong)
+	}
+}
@@ -1,27 +1,74 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"qq-farm-bot/internal/i18n"
 	"qq-farm-bot/internal/model"
 )
 
-type Store struct {
-	db *sql.DB
+// DefaultQueryTimeout bounds each Store call, so a locked database file
+// fails the request instead of hanging it.
+const DefaultQueryTimeout = 5 * time.Second
+
+// SQLStore implements Store on database/sql for SQLite and Postgres.
+type SQLStore struct {
+	db           *sql.DB
+	dialect      *dialect
+	queryTimeout time.Duration
+
+	maintMu   sync.Mutex
+	lastMaint *model.MaintenanceReport
 }
 
-func New(dbPath string) (*Store, error) {
-	os.MkdirAll(filepath.Dir(dbPath), 0755)
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+// Supported database drivers.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// New opens (or creates) the SQLite database at dbPath.
+func New(dbPath string) (*SQLStore, error) {
+	return Open(DriverSQLite, dbPath)
+}
+
+// Open connects to the database and applies pending migrations. For SQLite
+// dsn is the file path; for Postgres it is a connection string. The Postgres
+// driver is only linked into builds made with -tags postgres.
+func Open(driver, dsn string) (*SQLStore, error) {
+	var (
+		db  *sql.DB
+		d   *dialect
+		err error
+	)
+	switch driver {
+	case "", DriverSQLite:
+		os.MkdirAll(filepath.Dir(dsn), 0755)
+		db, err = sql.Open("sqlite3", dsn+"?_journal_mode=WAL&_busy_timeout=5000")
+		d = sqliteDialect
+	case DriverPostgres:
+		if !slices.Contains(sql.Drivers(), "postgres") {
+			return nil, fmt.Errorf("postgres driver not compiled in, rebuild with -tags postgres")
+		}
+		db, err = sql.Open("postgres", dsn)
+		d = postgresDialect
+	default:
+		return nil, fmt.Errorf("unknown db driver %q", driver)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
-	s := &Store{db: db}
+	s := &SQLStore{db: db, dialect: d, queryTimeout: DefaultQueryTimeout}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
@@ -29,7 +76,48 @@ func New(dbPath string) (*Store, error) {
 	return s, nil
 }
 
-func (s *Store) Close() error { return s.db.Close() }
+func (s *SQLStore) Close() error { return s.db.Close() }
+
+// SetQueryTimeout changes the per-call timeout; d <= 0 restores the default.
+func (s *SQLStore) SetQueryTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultQueryTimeout
+	}
+	s.queryTimeout = d
+}
+
+// withTimeout derives the context for one Store call. A caller deadline that
+// is earlier than the query timeout still wins.
+func (s *SQLStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+func (s *SQLStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *SQLStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *SQLStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(query), args...)
+}
+
+// insert runs an INSERT and returns the id of the new row.
+func (s *SQLStore) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	if s.dialect.returning {
+		var id int64
+		err := s.queryRow(ctx, query+` RETURNING id`, args...).Scan(&id)
+		return id, err
+	}
+	res, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	return id, nil
+}
 
 // Column list shared by all account queries
 const accountColumns = `id, user_id, name, platform, code, auto_start,
@@ -43,96 +131,37 @@ const accountColumns = `id, user_id, name, platform, code, auto_start,
 	planting_strategy,
 	enable_debug_log,
 	api_key,
-	created_at, updated_at`
-
-func (s *Store) migrate() error {
-	ddl := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL UNIQUE,
-		password_hash TEXT NOT NULL,
-		is_admin INTEGER NOT NULL DEFAULT 0,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS accounts (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL DEFAULT 1,
-		name TEXT NOT NULL DEFAULT '',
-		platform TEXT NOT NULL DEFAULT 'qq',
-		code TEXT NOT NULL DEFAULT '',
-		auto_start INTEGER NOT NULL DEFAULT 0,
-		farm_interval INTEGER NOT NULL DEFAULT 10,
-		friend_interval INTEGER NOT NULL DEFAULT 10,
-		enable_steal INTEGER NOT NULL DEFAULT 1,
-		force_lowest INTEGER NOT NULL DEFAULT 0,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS logs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		account_id INTEGER NOT NULL,
-		tag TEXT NOT NULL DEFAULT '',
-		message TEXT NOT NULL DEFAULT '',
-		level TEXT NOT NULL DEFAULT 'info',
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE INDEX IF NOT EXISTS idx_logs_account ON logs(account_id, created_at DESC);
-	`
-	_, err := s.db.Exec(ddl)
-
-	// Migration: add user_id column if not exists (for existing databases)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`UPDATE accounts SET user_id = 1 WHERE user_id = 0 OR user_id IS NULL`)
-	// Migration: add fertilizer columns
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN auto_use_fertilizer INTEGER NOT NULL DEFAULT 0`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN auto_buy_fertilizer INTEGER NOT NULL DEFAULT 0`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN fertilizer_target_count INTEGER NOT NULL DEFAULT 0`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN fertilizer_buy_daily_limit INTEGER NOT NULL DEFAULT 0`)
-
-	// Migration: add farm automation toggles (default 1 = enabled for backward compatibility)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_harvest INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_plant INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_sell INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_weed INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_bug INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_water INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_remove_dead INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_upgrade_land INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_help_friend INTEGER NOT NULL DEFAULT 1`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_claim_task INTEGER NOT NULL DEFAULT 1`)
-
-	// Migration: add crop selection & filtering columns
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN plant_crop_id INTEGER NOT NULL DEFAULT 0`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN sell_crop_ids TEXT NOT NULL DEFAULT ''`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN steal_crop_ids TEXT NOT NULL DEFAULT ''`)
-	// Migration: add anti-detection column
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_anti_detection INTEGER NOT NULL DEFAULT 0`)
-	// Migration: add per-account API key column
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN api_key TEXT NOT NULL DEFAULT ''
-`)
-	// Migration: op_stats table for operation statistics tracking
-	_, _ = s.db.Exec(`CREATE TABLE IF NOT EXISTS op_stats (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		account_id INTEGER NOT NULL,
-		op_type TEXT NOT NULL,
-		count INTEGER NOT NULL DEFAULT 0,
-		gold_delta INTEGER NOT NULL DEFAULT 0,
-		exp_delta INTEGER NOT NULL DEFAULT 0,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)`)
-	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_op_stats_account_time ON op_stats(account_id, created_at)`)
-	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_op_stats_type ON op_stats(account_id, op_type, created_at)`)
-	// Migration: add detail column to op_stats
-	_, _ = s.db.Exec(`ALTER TABLE op_stats ADD COLUMN detail TEXT NOT NULL DEFAULT ''`)
-	// Migration: add prefer_bag_seeds column
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN prefer_bag_seeds INTEGER NOT NULL DEFAULT 0`)
-	// Migration: add planting_strategy column (JSON-encoded composable rules)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN planting_strategy TEXT NOT NULL DEFAULT ''`)
-	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN enable_debug_log INTEGER NOT NULL DEFAULT 0`)
-
+	tags,
+	log_level,
+	log_tag_blacklist,
+	server_url_override,
+	notify_webhook_url,
+	notes,
+	sort_order,
+	force_lowest_min_exp,
+	force_lowest_max_price,
+	no_harvest_crop_ids,
+	friend_list_refresh_interval,
+	warehouse_interval,
+	replant_on_level_up,
+	replant_margin_pct,
+	warm_up_level,
+	login_fail_reason,
+	login_fail_count,
+	login_retry_at,
+	interval_jitter_pct,
+	family_id,
+	rpc_deny,
+	gold_reserve,
+	notify_before_mature_minutes,
+	created_at, updated_at, deleted_at`
+
+// CheckWritable verifies the database accepts writes by touching a probe row.
+func (s *SQLStore) CheckWritable(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `INSERT INTO health_probe (id, checked_at) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET checked_at = excluded.checked_at`, time.Now())
 	return err
 }
 
@@ -145,6 +174,8 @@ func scanAccount(scanner interface {
 	var enableHarvest, enablePlant, enableSell, enableWeed, enableBug, enableWater int
 	var enableRemoveDead, enableUpgradeLand, enableHelpFriend, enableClaimTask int
 	var autoUseFert, autoBuyFert, enableAntiDetection, preferBagSeeds, enableDebugLog int
+	var replantOnLevelUp int
+	var deletedAt, loginRetryAt sql.NullTime
 
 	if err := scanner.Scan(
 		&a.ID, &a.UserID, &a.Name, &a.Platform, &a.Code, &autoStart,
@@ -158,10 +189,39 @@ func scanAccount(scanner interface {
 		&a.PlantingStrategy,
 		&enableDebugLog,
 		&a.APIKey,
-		&a.CreatedAt, &a.UpdatedAt,
+		&a.Tags,
+		&a.LogLevel,
+		&a.LogTagBlacklist,
+		&a.ServerURLOverride,
+		&a.NotifyWebhookURL,
+		&a.Notes,
+		&a.SortOrder,
+		&a.ForceLowestMinExp,
+		&a.ForceLowestMaxPrice,
+		&a.NoHarvestCropIDs,
+		&a.FriendListRefreshInterval,
+		&a.WarehouseInterval,
+		&replantOnLevelUp,
+		&a.ReplantMarginPct,
+		&a.WarmUpLevel,
+		&a.LoginFailReason,
+		&a.LoginFailCount,
+		&loginRetryAt,
+		&a.IntervalJitterPct,
+		&a.FamilyID,
+		&a.RPCDeny,
+		&a.GoldReserve,
+		&a.NotifyBeforeMatureMinutes,
+		&a.CreatedAt, &a.UpdatedAt, &deletedAt,
 	); err != nil {
 		return nil, err
 	}
+	if deletedAt.Valid {
+		a.DeletedAt = &deletedAt.Time
+	}
+	if loginRetryAt.Valid {
+		a.LoginRetryAt = &loginRetryAt.Time
+	}
 
 	a.AutoStart = autoStart == 1
 	a.EnableSteal = enableSteal == 1
@@ -181,14 +241,17 @@ func scanAccount(scanner interface {
 	a.EnableAntiDetection = enableAntiDetection == 1
 	a.PreferBagSeeds = preferBagSeeds == 1
 	a.EnableDebugLog = enableDebugLog == 1
+	a.ReplantOnLevelUp = replantOnLevelUp == 1
 
 	return &a, nil
 }
 
 // ============ Account CRUD ============
 
-func (s *Store) ListAccounts() ([]model.Account, error) {
-	rows, err := s.db.Query(`SELECT ` + accountColumns + ` FROM accounts ORDER BY id`)
+func (s *SQLStore) ListAccounts(ctx context.Context) ([]model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `SELECT `+accountColumns+` FROM accounts WHERE deleted_at IS NULL ORDER BY sort_order, id`)
 	if err != nil {
 		return nil, err
 	}
@@ -205,8 +268,81 @@ func (s *Store) ListAccounts() ([]model.Account, error) {
 	return accounts, nil
 }
 
-func (s *Store) ListAccountsByUserID(userID int64) ([]model.Account, error) {
-	rows, err := s.db.Query(`SELECT `+accountColumns+` FROM accounts WHERE user_id = ? ORDER BY id`, userID)
+func (s *SQLStore) ListAccountsByUserID(ctx context.Context, userID int64) ([]model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `SELECT `+accountColumns+` FROM accounts WHERE user_id = ? AND deleted_at IS NULL ORDER BY sort_order, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []model.Account
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *a)
+	}
+	return accounts, nil
+}
+
+// AccountFilter narrows ListAccountsFiltered and CountAccounts. Zero values
+// match everything; Limit 0 returns all matching rows.
+type AccountFilter struct {
+	ID       int64  // a single account (0 = any)
+	UserID   int64  // owner (0 = all users)
+	Query    string // case-insensitive name substring
+	Platform string
+	Tag      string
+	Limit    int
+	Offset   int
+}
+
+// likeEscape escapes LIKE wildcards so s matches literally with ESCAPE '\'.
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f AccountFilter) where() (string, []interface{}) {
+	where := ` WHERE deleted_at IS NULL`
+	var args []interface{}
+	if f.ID > 0 {
+		where += ` AND id = ?`
+		args = append(args, f.ID)
+	}
+	if f.UserID > 0 {
+		where += ` AND user_id = ?`
+		args = append(args, f.UserID)
+	}
+	if f.Query != "" {
+		where += ` AND LOWER(name) LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscape(strings.ToLower(f.Query))+"%")
+	}
+	if f.Platform != "" {
+		where += ` AND platform = ?`
+		args = append(args, f.Platform)
+	}
+	if f.Tag != "" {
+		// tags are stored normalized as "a,b,c"
+		where += ` AND (',' || tags || ',') LIKE ? ESCAPE '\'`
+		args = append(args, "%,"+likeEscape(f.Tag)+",%")
+	}
+	return where, args
+}
+
+// ListAccountsFiltered returns one page of accounts matching f, in list order.
+func (s *SQLStore) ListAccountsFiltered(ctx context.Context, f AccountFilter) ([]model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	where, args := f.where()
+	q := `SELECT ` + accountColumns + ` FROM accounts` + where + ` ORDER BY sort_order, id`
+	if f.Limit > 0 {
+		q += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, max(f.Offset, 0))
+	}
+	rows, err := s.query(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -223,26 +359,65 @@ func (s *Store) ListAccountsByUserID(userID int64) ([]model.Account, error) {
 	return accounts, nil
 }
 
-func (s *Store) GetAccount(id int64) (*model.Account, error) {
-	row := s.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = ?`, id)
+// CountAccounts returns the number of accounts matching f, ignoring Limit and Offset.
+func (s *SQLStore) CountAccounts(ctx context.Context, f AccountFilter) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	where, args := f.where()
+	var n int64
+	err := s.queryRow(ctx, `SELECT COUNT(*) FROM accounts`+where, args...).Scan(&n)
+	return n, err
+}
+
+func (s *SQLStore) GetAccount(ctx context.Context, id int64) (*model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	row := s.queryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = ? AND deleted_at IS NULL`, id)
 	return scanAccount(row)
 }
 
-func (s *Store) GetAccountByName(name string) (*model.Account, error) {
-	row := s.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE name = ? LIMIT 1`, name)
+func (s *SQLStore) GetAccountByName(ctx context.Context, name string) (*model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	row := s.queryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE name = ? AND deleted_at IS NULL LIMIT 1`, name)
 	return scanAccount(row)
 }
 
-func (s *Store) GetAccountByAPIKey(apiKey string) (*model.Account, error) {
-	row := s.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE api_key = ? AND api_key != '' LIMIT 1`, apiKey)
+// ListAccountsByCode returns the live accounts logging in with code.
+func (s *SQLStore) ListAccountsByCode(ctx context.Context, code string) ([]model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `SELECT `+accountColumns+` FROM accounts WHERE code = ? AND code != '' AND deleted_at IS NULL ORDER BY id`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []model.Account
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *a)
+	}
+	return accounts, nil
+}
+
+func (s *SQLStore) GetAccountByAPIKey(ctx context.Context, apiKey string) (*model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	row := s.queryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE api_key = ? AND api_key != '' AND deleted_at IS NULL LIMIT 1`, apiKey)
 	return scanAccount(row)
 }
 
-func (s *Store) CreateAccount(a *model.Account) error {
+func (s *SQLStore) CreateAccount(ctx context.Context, a *model.Account) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	now := time.Now()
 	a.CreatedAt = now
 	a.UpdatedAt = now
-	res, err := s.db.Exec(`INSERT INTO accounts (
+	id, err := s.insert(ctx, `INSERT INTO accounts (
 		user_id, name, platform, code, auto_start,
 		farm_interval, friend_interval, enable_steal, force_lowest,
 		enable_harvest, enable_plant, enable_sell, enable_weed, enable_bug, enable_water,
@@ -254,8 +429,28 @@ func (s *Store) CreateAccount(a *model.Account) error {
 		planting_strategy,
 		enable_debug_log,
 		api_key,
+		tags,
+		log_level,
+		log_tag_blacklist,
+		server_url_override,
+		notify_webhook_url,
+		notes,
+		sort_order,
+		force_lowest_min_exp,
+		force_lowest_max_price,
+		no_harvest_crop_ids,
+		friend_list_refresh_interval,
+		warehouse_interval,
+		replant_on_level_up,
+		replant_margin_pct,
+		warm_up_level,
+		interval_jitter_pct,
+		family_id,
+		rpc_deny,
+		gold_reserve,
+		notify_before_mature_minutes,
 		created_at, updated_at
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		a.UserID, a.Name, a.Platform, a.Code, boolToInt(a.AutoStart),
 		a.FarmInterval, a.FriendInterval, boolToInt(a.EnableSteal), boolToInt(a.ForceLowest),
 		boolToInt(a.EnableHarvest), boolToInt(a.EnablePlant), boolToInt(a.EnableSell),
@@ -270,17 +465,39 @@ func (s *Store) CreateAccount(a *model.Account) error {
 		a.PlantingStrategy,
 		boolToInt(a.EnableDebugLog),
 		a.APIKey,
+		a.Tags,
+		a.LogLevel,
+		a.LogTagBlacklist,
+		a.ServerURLOverride,
+		a.NotifyWebhookURL,
+		a.Notes,
+		a.SortOrder,
+		a.ForceLowestMinExp,
+		a.ForceLowestMaxPrice,
+		a.NoHarvestCropIDs,
+		a.FriendListRefreshInterval,
+		a.WarehouseInterval,
+		boolToInt(a.ReplantOnLevelUp),
+		a.ReplantMarginPct,
+		a.WarmUpLevel,
+		a.IntervalJitterPct,
+		a.FamilyID,
+		a.RPCDeny,
+		a.GoldReserve,
+		a.NotifyBeforeMatureMinutes,
 		now, now)
 	if err != nil {
 		return err
 	}
-	a.ID, _ = res.LastInsertId()
+	a.ID = id
 	return nil
 }
 
-func (s *Store) UpdateAccount(a *model.Account) error {
+func (s *SQLStore) UpdateAccount(ctx context.Context, a *model.Account) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	a.UpdatedAt = time.Now()
-	_, err := s.db.Exec(`UPDATE accounts SET
+	_, err := s.exec(ctx, `UPDATE accounts SET
 		name=?, platform=?, code=?, auto_start=?,
 		farm_interval=?, friend_interval=?, enable_steal=?, force_lowest=?,
 		enable_harvest=?, enable_plant=?, enable_sell=?, enable_weed=?, enable_bug=?, enable_water=?,
@@ -292,6 +509,29 @@ func (s *Store) UpdateAccount(a *model.Account) error {
 		planting_strategy=?,
 		enable_debug_log=?,
 		api_key=?,
+		tags=?,
+		log_level=?,
+		log_tag_blacklist=?,
+		server_url_override=?,
+		notify_webhook_url=?,
+		notes=?,
+		sort_order=?,
+		force_lowest_min_exp=?,
+		force_lowest_max_price=?,
+		no_harvest_crop_ids=?,
+		friend_list_refresh_interval=?,
+		warehouse_interval=?,
+		replant_on_level_up=?,
+		replant_margin_pct=?,
+		warm_up_level=?,
+		login_fail_reason=CASE WHEN code=? THEN login_fail_reason ELSE '' END,
+		login_fail_count=CASE WHEN code=? THEN login_fail_count ELSE 0 END,
+		login_retry_at=CASE WHEN code=? THEN login_retry_at ELSE NULL END,
+		interval_jitter_pct=?,
+		family_id=?,
+		rpc_deny=?,
+		gold_reserve=?,
+		notify_before_mature_minutes=?,
 		updated_at=?
 	WHERE id=?`,
 		a.Name, a.Platform, a.Code, boolToInt(a.AutoStart),
@@ -308,53 +548,247 @@ func (s *Store) UpdateAccount(a *model.Account) error {
 		a.PlantingStrategy,
 		boolToInt(a.EnableDebugLog),
 		a.APIKey,
+		a.Tags,
+		a.LogLevel,
+		a.LogTagBlacklist,
+		a.ServerURLOverride,
+		a.NotifyWebhookURL,
+		a.Notes,
+		a.SortOrder,
+		a.ForceLowestMinExp,
+		a.ForceLowestMaxPrice,
+		a.NoHarvestCropIDs,
+		a.FriendListRefreshInterval,
+		a.WarehouseInterval,
+		boolToInt(a.ReplantOnLevelUp),
+		a.ReplantMarginPct,
+		a.WarmUpLevel,
+		// SET expressions see the old row: a new code clears the failure state
+		a.Code, a.Code, a.Code,
+		a.IntervalJitterPct,
+		a.FamilyID,
+		a.RPCDeny,
+		a.GoldReserve,
+		a.NotifyBeforeMatureMinutes,
 		a.UpdatedAt, a.ID)
 	return err
 }
 
+// ReorderAccounts puts the given accounts of userID first, in that order,
+// followed by the user's other accounts in their previous order. It fails
+// with ErrForeignAccount, changing nothing, if an id is not one of the
+// user's accounts.
+func (s *SQLStore) ReorderAccounts(ctx context.Context, userID int64, ids []int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind(
+		`SELECT id FROM accounts WHERE user_id = ? AND deleted_at IS NULL ORDER BY sort_order, id`), userID)
+	if err != nil {
+		return err
+	}
+	var current []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	listed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(current, id) {
+			return fmt.Errorf("%w: #%d", ErrForeignAccount, id)
+		}
+		listed[id] = true
+	}
+	order := slices.Clone(ids)
+	for _, id := range current {
+		if !listed[id] {
+			order = append(order, id)
+		}
+	}
+	update := s.dialect.rebind(`UPDATE accounts SET sort_order = ? WHERE id = ?`)
+	for i, id := range order {
+		if _, err := tx.ExecContext(ctx, update, i+1, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // UpdateAccountName updates only the display name of an account.
 // Used by the bot to persist the name obtained from the game server after login.
-func (s *Store) UpdateAccountName(id int64, name string) error {
-	_, err := s.db.Exec(`UPDATE accounts SET name=?, updated_at=? WHERE id=?`, name, time.Now(), id)
+func (s *SQLStore) UpdateAccountName(ctx context.Context, id int64, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `UPDATE accounts SET name=?, updated_at=? WHERE id=?`, name, time.Now(), id)
+	return err
+}
+
+// SetLoginFailure persists an account's consecutive login failures so a
+// restart doesn't retry it before retryAt.
+func (s *SQLStore) SetLoginFailure(ctx context.Context, id int64, reason string, count int, retryAt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `UPDATE accounts SET login_fail_reason=?, login_fail_count=?, login_retry_at=? WHERE id=?`,
+		reason, count, retryAt, id)
+	return err
+}
+
+// ClearLoginFailure forgets the login failure state after a successful login.
+func (s *SQLStore) ClearLoginFailure(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `UPDATE accounts SET login_fail_reason='', login_fail_count=0, login_retry_at=NULL WHERE id=?`, id)
 	return err
 }
 
-func (s *Store) DeleteAccount(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM accounts WHERE id = ?`, id)
+// DeleteAccount moves an account to the recycle bin. Every other account
+// query ignores it until RestoreAccount; PurgeDeletedAccounts removes it for good.
+func (s *SQLStore) DeleteAccount(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.exec(ctx, `UPDATE accounts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListDeletedAccounts returns accounts in the recycle bin, newest deletion
+// first. userID 0 lists every user's accounts.
+func (s *SQLStore) ListDeletedAccounts(ctx context.Context, userID int64) ([]model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE deleted_at IS NOT NULL`
+	var args []interface{}
+	if userID > 0 {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := s.query(ctx, query+` ORDER BY deleted_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []model.Account{}
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetDeletedAccount returns an account from the recycle bin.
+func (s *SQLStore) GetDeletedAccount(ctx context.Context, id int64) (*model.Account, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	row := s.queryRow(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return scanAccount(row)
+}
+
+// RestoreAccount takes an account out of the recycle bin.
+func (s *SQLStore) RestoreAccount(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.exec(ctx, `UPDATE accounts SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL`, time.Now(), id)
 	if err != nil {
 		return err
 	}
-	_, _ = s.db.Exec(`DELETE FROM logs WHERE account_id = ?`, id)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
+// accountDataTables hold per-account rows that go with a purged account.
+var accountDataTables = []string{"logs", "op_stats", "daily_summaries", "activity", "friend_stats", "bot_state", "spend"}
+
+// PurgeDeletedAccounts permanently removes accounts deleted before cutoff,
+// together with their rows in accountDataTables, in one transaction.
+// Returns the number purged.
+func (s *SQLStore) PurgeDeletedAccounts(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const expired = `SELECT id FROM accounts WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	for _, table := range accountDataTables {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM `+table+` WHERE account_id IN (`+expired+`)`), cutoff); err != nil {
+			return 0, fmt.Errorf("purge %s: %w", table, err)
+		}
+	}
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM accounts WHERE deleted_at IS NOT NULL AND deleted_at < ?`), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
 // ============ Log ============
 
-func (s *Store) AddLog(entry *model.LogEntry) error {
+func (s *SQLStore) AddLog(ctx context.Context, entry *model.LogEntry) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	entry.CreatedAt = time.Now()
-	res, err := s.db.Exec(`INSERT INTO logs (account_id, tag, message, level, created_at) VALUES (?, ?, ?, ?, ?)`,
-		entry.AccountID, entry.Tag, entry.Message, entry.Level, entry.CreatedAt)
+	id, err := s.insert(ctx, `INSERT INTO logs (account_id, tag, message, level, created_at, correlation_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.AccountID, entry.Tag, entry.Message, entry.Level, entry.CreatedAt, entry.CorrelationID)
 	if err != nil {
 		return err
 	}
-	entry.ID, _ = res.LastInsertId()
+	entry.ID = id
 	return nil
 }
 
-func (s *Store) GetLogs(accountID int64, limit int, beforeID int64) ([]model.LogEntry, error) {
+// GetLogs returns the newest logs of an account before beforeID (0 = from
+// the newest), optionally only those of one correlation id.
+func (s *SQLStore) GetLogs(ctx context.Context, accountID int64, limit int, beforeID int64, correlationID string) ([]model.LogEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	if limit <= 0 || limit > 500 {
 		limit = 100
 	}
-	query := `SELECT id, account_id, tag, message, level, created_at FROM logs WHERE account_id = ?`
+	query := `SELECT id, account_id, tag, message, level, created_at, correlation_id FROM logs WHERE account_id = ?`
 	args := []interface{}{accountID}
 	if beforeID > 0 {
 		query += ` AND id < ?`
 		args = append(args, beforeID)
 	}
+	if correlationID != "" {
+		query += ` AND correlation_id = ?`
+		args = append(args, correlationID)
+	}
 	query += ` ORDER BY id DESC LIMIT ?`
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -363,7 +797,7 @@ func (s *Store) GetLogs(accountID int64, limit int, beforeID int64) ([]model.Log
 	var logs []model.LogEntry
 	for rows.Next() {
 		var l model.LogEntry
-		if err := rows.Scan(&l.ID, &l.AccountID, &l.Tag, &l.Message, &l.Level, &l.CreatedAt); err != nil {
+		if err := rows.Scan(&l.ID, &l.AccountID, &l.Tag, &l.Message, &l.Level, &l.CreatedAt, &l.CorrelationID); err != nil {
 			return nil, err
 		}
 		logs = append(logs, l)
@@ -371,12 +805,182 @@ func (s *Store) GetLogs(accountID int64, limit int, beforeID int64) ([]model.Log
 	return logs, nil
 }
 
-func (s *Store) CleanOldLogs(days int) error {
+func (s *SQLStore) CleanOldLogs(ctx context.Context, days int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	cutoff := time.Now().AddDate(0, 0, -days)
-	_, err := s.db.Exec(`DELETE FROM logs WHERE created_at < ?`, cutoff)
+	_, err := s.exec(ctx, `DELETE FROM logs WHERE created_at < ?`, cutoff)
 	return err
 }
 
+// ============ Activity ============
+
+// ActivityFilter selects one page of an account's activity feed, newest
+// first. Zero values leave a condition out.
+type ActivityFilter struct {
+	AccountID int64
+	Types     []string
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64
+	Limit     int
+}
+
+func (s *SQLStore) AddActivity(ctx context.Context, e *model.ActivityEvent) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	payload := string(e.Payload)
+	if payload == "" {
+		payload = "{}"
+	}
+	id, err := s.insert(ctx, `INSERT INTO activity (account_id, event_type, payload, created_at) VALUES (?, ?, ?, ?)`,
+		e.AccountID, e.Type, payload, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (s *SQLStore) ListActivity(ctx context.Context, f ActivityFilter) ([]model.ActivityEvent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 100
+	}
+	query := `SELECT id, account_id, event_type, payload, created_at FROM activity WHERE account_id = ?`
+	args := []interface{}{f.AccountID}
+	if len(f.Types) > 0 {
+		query += ` AND event_type IN (?` + strings.Repeat(`, ?`, len(f.Types)-1) + `)`
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+	if !f.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, f.Since)
+	}
+	if !f.Until.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, f.Until)
+	}
+	if f.BeforeID > 0 {
+		query += ` AND id < ?`
+		args = append(args, f.BeforeID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, f.Limit)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.ActivityEvent{}
+	for rows.Next() {
+		var e model.ActivityEvent
+		var payload string
+		if err := rows.Scan(&e.ID, &e.AccountID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = json.RawMessage(payload)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ============ Maintenance ============
+
+// vacuumFreeRatio is the share of free pages above which Maintain rebuilds
+// the SQLite file with VACUUM.
+const vacuumFreeRatio = 0.2
+
+// Maintain deletes logs older than logRetentionDays (0 keeps them), runs
+// ANALYZE and, on SQLite, VACUUMs once free pages pass vacuumFreeRatio.
+// VACUUM rewrites the whole file, so only the prune runs under the query
+// timeout; bound ctx instead. The report is kept for LastMaintenance.
+func (s *SQLStore) Maintain(ctx context.Context, logRetentionDays int) (*model.MaintenanceReport, error) {
+	r := &model.MaintenanceReport{StartedAt: time.Now()}
+	err := s.maintain(ctx, logRetentionDays, r)
+	r.DurationMS = time.Since(r.StartedAt).Milliseconds()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	s.maintMu.Lock()
+	s.lastMaint = r
+	s.maintMu.Unlock()
+	return r, err
+}
+
+func (s *SQLStore) maintain(ctx context.Context, logRetentionDays int, r *model.MaintenanceReport) error {
+	size, free, err := s.dbSize(ctx)
+	if err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	r.SizeBefore = size
+
+	if logRetentionDays > 0 {
+		pruneCtx, cancel := s.withTimeout(ctx)
+		res, err := s.exec(pruneCtx, `DELETE FROM logs WHERE created_at < ?`, time.Now().AddDate(0, 0, -logRetentionDays))
+		cancel()
+		if err != nil {
+			return fmt.Errorf("prune logs: %w", err)
+		}
+		r.LogsDeleted, _ = res.RowsAffected()
+		// Pages freed by the prune count toward the vacuum threshold
+		if size, free, err = s.dbSize(ctx); err != nil {
+			return fmt.Errorf("size: %w", err)
+		}
+	}
+
+	if s.dialect == sqliteDialect && size > 0 && float64(free)/float64(size) > vacuumFreeRatio {
+		if _, err := s.db.ExecContext(ctx, `VACUUM`); err != nil {
+			return fmt.Errorf("vacuum: %w", err)
+		}
+		r.Vacuumed = true
+	}
+	if _, err := s.db.ExecContext(ctx, `ANALYZE`); err != nil {
+		return fmt.Errorf("analyze: %w", err)
+	}
+
+	if r.SizeAfter, _, err = s.dbSize(ctx); err != nil {
+		return fmt.Errorf("size: %w", err)
+	}
+	return nil
+}
+
+// dbSize returns the database size and, on SQLite, the bytes on free pages.
+func (s *SQLStore) dbSize(ctx context.Context) (size, free int64, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if s.dialect == postgresDialect {
+		err = s.queryRow(ctx, `SELECT pg_database_size(current_database())`).Scan(&size)
+		return size, 0, err
+	}
+	var pages, freePages, pageSize int64
+	if err = s.queryRow(ctx, `PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, 0, err
+	}
+	if err = s.queryRow(ctx, `PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return 0, 0, err
+	}
+	if err = s.queryRow(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, 0, err
+	}
+	return pages * pageSize, freePages * pageSize, nil
+}
+
+// LastMaintenance returns the report of the latest Maintain run, or nil.
+func (s *SQLStore) LastMaintenance() *model.MaintenanceReport {
+	s.maintMu.Lock()
+	defer s.maintMu.Unlock()
+	return s.lastMaint
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
@@ -386,23 +990,30 @@ func boolToInt(b bool) int {
 
 // ============ User CRUD ============
 
-func (s *Store) CreateUser(u *model.User) error {
+func (s *SQLStore) CreateUser(ctx context.Context, u *model.User) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	now := time.Now()
 	u.CreatedAt = now
-	res, err := s.db.Exec(`INSERT INTO users (username, password_hash, is_admin, created_at) VALUES (?, ?, ?, ?)`,
-		u.Username, u.PasswordHash, boolToInt(u.IsAdmin), now)
+	if u.Locale == "" {
+		u.Locale = i18n.Zh
+	}
+	id, err := s.insert(ctx, `INSERT INTO users (username, password_hash, is_admin, locale, created_at) VALUES (?, ?, ?, ?, ?)`,
+		u.Username, u.PasswordHash, boolToInt(u.IsAdmin), u.Locale, now)
 	if err != nil {
 		return err
 	}
-	u.ID, _ = res.LastInsertId()
+	u.ID = id
 	return nil
 }
 
-func (s *Store) GetUserByID(id int64) (*model.User, error) {
+func (s *SQLStore) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var u model.User
 	var isAdmin int
-	err := s.db.QueryRow(`SELECT id, username, password_hash, is_admin, created_at FROM users WHERE id = ?`, id).
-		Scan(&u.ID, &u.Username, &u.PasswordHash, &isAdmin, &u.CreatedAt)
+	err := s.queryRow(ctx, `SELECT id, username, password_hash, is_admin, locale, created_at FROM users WHERE id = ?`, id).
+		Scan(&u.ID, &u.Username, &u.PasswordHash, &isAdmin, &u.Locale, &u.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -410,11 +1021,13 @@ func (s *Store) GetUserByID(id int64) (*model.User, error) {
 	return &u, nil
 }
 
-func (s *Store) GetUserByUsername(username string) (*model.User, error) {
+func (s *SQLStore) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var u model.User
 	var isAdmin int
-	err := s.db.QueryRow(`SELECT id, username, password_hash, is_admin, created_at FROM users WHERE username = ?`, username).
-		Scan(&u.ID, &u.Username, &u.PasswordHash, &isAdmin, &u.CreatedAt)
+	err := s.queryRow(ctx, `SELECT id, username, password_hash, is_admin, locale, created_at FROM users WHERE username = ?`, username).
+		Scan(&u.ID, &u.Username, &u.PasswordHash, &isAdmin, &u.Locale, &u.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -422,30 +1035,294 @@ func (s *Store) GetUserByUsername(username string) (*model.User, error) {
 	return &u, nil
 }
 
-func (s *Store) UserExists(username string) (bool, error) {
+func (s *SQLStore) UserExists(ctx context.Context, username string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var count int
+	err := s.queryRow(ctx, `SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *SQLStore) HasAnyUser(ctx context.Context) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count)
+	err := s.queryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func (s *Store) HasAnyUser() (bool, error) {
+// HasAdminUser reports whether at least one admin user exists in the database.
+func (s *SQLStore) HasAdminUser(ctx context.Context) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+	err := s.queryRow(ctx, `SELECT COUNT(*) FROM users WHERE is_admin = 1`).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
+// UpdateUserLocale sets the language a user reads logs in.
+func (s *SQLStore) UpdateUserLocale(ctx context.Context, id int64, locale string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.exec(ctx, `UPDATE users SET locale = ? WHERE id = ?`, locale, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateUserPassword replaces the stored bcrypt hash for a user.
+func (s *SQLStore) UpdateUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.exec(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ============ Sessions ============
+
+func (s *SQLStore) CreateSession(ctx context.Context, sess *model.Session) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	sess.CreatedAt = time.Now()
+	id, err := s.insert(ctx, `INSERT INTO sessions (user_id, token_hash, expires_at, revoked, created_at) VALUES (?, ?, ?, 0, ?)`,
+		sess.UserID, sess.TokenHash, sess.ExpiresAt, sess.CreatedAt)
+	if err != nil {
+		return err
+	}
+	sess.ID = id
+	return nil
+}
+
+func (s *SQLStore) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*model.Session, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var sess model.Session
+	var revoked int
+	err := s.queryRow(ctx, `SELECT id, user_id, token_hash, expires_at, revoked, created_at FROM sessions WHERE token_hash = ?`, tokenHash).
+		Scan(&sess.ID, &sess.UserID, &sess.TokenHash, &sess.ExpiresAt, &revoked, &sess.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	sess.Revoked = revoked == 1
+	return &sess, nil
+}
+
+// RevokeSession marks a single refresh-token session as revoked. It returns
+// sql.ErrNoRows when the session was already revoked, so of two concurrent
+// refreshes with the same token only one can win.
+func (s *SQLStore) RevokeSession(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.exec(ctx, `UPDATE sessions SET revoked = 1 WHERE id = ? AND revoked = 0`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every refresh-token session of a user.
+func (s *SQLStore) RevokeUserSessions(ctx context.Context, userID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `UPDATE sessions SET revoked = 1 WHERE user_id = ?`, userID)
+	return err
+}
+
+// CleanExpiredSessions deletes sessions that are expired or revoked.
+func (s *SQLStore) CleanExpiredSessions(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `DELETE FROM sessions WHERE expires_at < ? OR revoked = 1`, time.Now())
+	return err
+}
+
+// ============ API tokens ============
+
+const apiTokenColumns = `id, user_id, name, token_hash, prefix, created_at, last_used_at, expires_at, account_id`
+
+func scanAPIToken(scanner interface{ Scan(...any) error }) (*model.APIToken, error) {
+	var t model.APIToken
+	var lastUsed, expires sql.NullTime
+	if err := scanner.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Prefix, &t.CreatedAt, &lastUsed, &expires, &t.AccountID); err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	return &t, nil
+}
+
+func (s *SQLStore) CreateAPIToken(ctx context.Context, t *model.APIToken) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	t.CreatedAt = time.Now()
+	id, err := s.insert(ctx, `INSERT INTO api_tokens (user_id, name, token_hash, prefix, created_at, expires_at, account_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.UserID, t.Name, t.TokenHash, t.Prefix, t.CreatedAt, t.ExpiresAt, t.AccountID)
+	if err != nil {
+		return err
+	}
+	t.ID = id
+	return nil
+}
+
+func (s *SQLStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return scanAPIToken(s.queryRow(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`, tokenHash))
+}
+
+// ListAPITokens returns the tokens of a user, newest first.
+func (s *SQLStore) ListAPITokens(ctx context.Context, userID int64) ([]model.APIToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []model.APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken deletes a token of userID, returning sql.ErrNoRows if the
+// user has no such token.
+func (s *SQLStore) DeleteAPIToken(ctx context.Context, userID, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.exec(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUserAPITokens deletes every personal access token of a user.
+func (s *SQLStore) DeleteUserAPITokens(ctx context.Context, userID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `DELETE FROM api_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+// TouchAPIToken records when a token was last used.
+func (s *SQLStore) TouchAPIToken(ctx context.Context, id int64, at time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// ============ Audit ============
+
+func (s *SQLStore) AddAudit(ctx context.Context, e *model.AuditEntry) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	e.CreatedAt = time.Now()
+	id, err := s.insert(ctx, `INSERT INTO audit (user_id, username, action, account_id, summary, ip, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.UserID, e.Username, e.Action, e.AccountID, e.Summary, e.IP, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// ListAudit returns audit entries newest first, optionally filtered by user
+// and action, together with the total number of matching rows.
+func (s *SQLStore) ListAudit(ctx context.Context, userID int64, action string, limit, offset int) ([]model.AuditEntry, int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	where := ` WHERE 1=1`
+	var args []interface{}
+	if userID > 0 {
+		where += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	if action != "" {
+		where += ` AND action = ?`
+		args = append(args, action)
+	}
+
+	var total int64
+	if err := s.queryRow(ctx, `SELECT COUNT(*) FROM audit`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.query(ctx, `SELECT id, user_id, username, action, account_id, summary, ip, created_at FROM audit`+
+		where+` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.Action, &e.AccountID, &e.Summary, &e.IP, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, nil
+}
+
+func (s *SQLStore) CleanOldAudit(ctx context.Context, days int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	cutoff := time.Now().AddDate(0, 0, -days)
+	_, err := s.exec(ctx, `DELETE FROM audit WHERE created_at < ?`, cutoff)
+	return err
+}
+
 // ============ Operation Stats ============
 
 // AddOpStat inserts a single operation statistics record.
-func (s *Store) AddOpStat(r *model.OpRecord) error {
+func (s *SQLStore) AddOpStat(ctx context.Context, r *model.OpRecord) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	r.CreatedAt = time.Now()
-	_, err := s.db.Exec(
+	_, err := s.exec(ctx,
 		`INSERT INTO op_stats (account_id, op_type, count, gold_delta, exp_delta, detail, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		r.AccountID, r.OpType, r.Count, r.GoldDelta, r.ExpDelta, r.Detail, r.CreatedAt)
 	return err
@@ -454,21 +1331,14 @@ func (s *Store) AddOpStat(r *model.OpRecord) error {
 // GetOpStats returns aggregated operation statistics for an account.
 // granularity: "hour", "day", "week", "all"
 // from/to: optional time range filters (zero time means no filter)
-func (s *Store) GetOpStats(accountID int64, granularity string, from, to time.Time) ([]model.AggregatedStats, error) {
-	var periodExpr string
-	switch granularity {
-	case "hour":
-		periodExpr = `strftime('%Y-%m-%d %H:00', created_at)`
-	case "day":
-		periodExpr = `strftime('%Y-%m-%d', created_at)`
-	case "week":
-		periodExpr = `strftime('%Y-W%W', created_at)`
-	default:
-		periodExpr = `'all'`
-	}
+func (s *SQLStore) GetOpStats(ctx context.Context, accountID int64, granularity string, from, to time.Time) ([]model.AggregatedStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	periodExpr := s.dialect.timeBucket(granularity, "created_at")
 
+	// Steal rows carry the stolen fruit's value, not gold received
 	query := `SELECT ` + periodExpr + ` as period, op_type, SUM(count) as total_count,
-		SUM(CASE WHEN gold_delta > 0 THEN gold_delta ELSE 0 END) as gold_in,
+		SUM(CASE WHEN gold_delta > 0 AND op_type <> 'steal' THEN gold_delta ELSE 0 END) as gold_in,
 		SUM(CASE WHEN gold_delta < 0 THEN -gold_delta ELSE 0 END) as gold_out,
 		SUM(CASE WHEN exp_delta > 0 THEN exp_delta ELSE 0 END) as exp_gained
 		FROM op_stats WHERE account_id = ?`
@@ -485,7 +1355,7 @@ func (s *Store) GetOpStats(accountID int64, granularity string, from, to time.Ti
 
 	query += ` GROUP BY period, op_type ORDER BY period ASC`
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -524,9 +1394,11 @@ func (s *Store) GetOpStats(accountID int64, granularity string, from, to time.Ti
 }
 
 // GetOpStatsSummary returns overall totals for an account (no time grouping).
-func (s *Store) GetOpStatsSummary(accountID int64) (map[string]int64, int64, int64, int64, error) {
-	rows, err := s.db.Query(
-		`SELECT op_type, SUM(count), SUM(CASE WHEN gold_delta > 0 THEN gold_delta ELSE 0 END),
+func (s *SQLStore) GetOpStatsSummary(ctx context.Context, accountID int64) (map[string]int64, int64, int64, int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx,
+		`SELECT op_type, SUM(count), SUM(CASE WHEN gold_delta > 0 AND op_type <> 'steal' THEN gold_delta ELSE 0 END),
 		SUM(CASE WHEN gold_delta < 0 THEN -gold_delta ELSE 0 END),
 		SUM(CASE WHEN exp_delta > 0 THEN exp_delta ELSE 0 END)
 		FROM op_stats WHERE account_id = ? GROUP BY op_type`, accountID)
@@ -552,12 +1424,270 @@ func (s *Store) GetOpStatsSummary(accountID int64) (map[string]int64, int64, int
 }
 
 // CleanOldOpStats removes operation stats older than the given number of days.
-func (s *Store) CleanOldOpStats(days int) error {
+func (s *SQLStore) CleanOldOpStats(ctx context.Context, days int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	cutoff := time.Now().AddDate(0, 0, -days)
-	_, err := s.db.Exec(`DELETE FROM op_stats WHERE created_at < ?`, cutoff)
+	_, err := s.exec(ctx, `DELETE FROM op_stats WHERE created_at < ?`, cutoff)
+	return err
+}
+
+// GetOpCounts returns per-op_type counts for an account within [from, to).
+func (s *SQLStore) GetOpCounts(ctx context.Context, accountID int64, from, to time.Time) (map[string]int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx,
+		`SELECT op_type, SUM(count) FROM op_stats
+		WHERE account_id = ? AND created_at >= ? AND created_at < ? GROUP BY op_type`,
+		accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var opType string
+		var count int64
+		if err := rows.Scan(&opType, &count); err != nil {
+			return nil, err
+		}
+		counts[opType] = count
+	}
+	return counts, rows.Err()
+}
+
+// ============ Spend ============
+
+// AddSpend records one gold payment.
+func (s *SQLStore) AddSpend(ctx context.Context, r *model.SpendRecord) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	r.CreatedAt = time.Now()
+	_, err := s.exec(ctx,
+		`INSERT INTO spend (account_id, category, amount, detail, created_at) VALUES (?, ?, ?, ?, ?)`,
+		r.AccountID, r.Category, r.Amount, r.Detail, r.CreatedAt)
+	return err
+}
+
+// GetSpendTotals sums the gold spent since the given time per category for
+// every account in accountIDs. Accounts without spending are absent.
+func (s *SQLStore) GetSpendTotals(ctx context.Context, accountIDs []int64, since time.Time) (map[int64]map[string]int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	result := make(map[int64]map[string]int64)
+	if len(accountIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.Repeat("?,", len(accountIDs))
+	args := make([]interface{}, 0, len(accountIDs)+1)
+	for _, id := range accountIDs {
+		args = append(args, id)
+	}
+	args = append(args, since)
+
+	rows, err := s.query(ctx,
+		`SELECT account_id, category, COALESCE(SUM(amount), 0)
+		FROM spend WHERE account_id IN (`+placeholders[:len(placeholders)-1]+`) AND created_at >= ?
+		GROUP BY account_id, category`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountID, amount int64
+		var category string
+		if err := rows.Scan(&accountID, &category, &amount); err != nil {
+			return nil, err
+		}
+		if result[accountID] == nil {
+			result[accountID] = make(map[string]int64)
+		}
+		result[accountID][category] = amount
+	}
+	return result, rows.Err()
+}
+
+// ============ Daily Summaries ============
+
+// UpsertDailySummary inserts or replaces the summary of (account_id, date).
+// Rewriting the same day is idempotent, so restarts never double count.
+func (s *SQLStore) UpsertDailySummary(ctx context.Context, d *model.DailySummary) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	d.UpdatedAt = time.Now()
+	_, err := s.exec(ctx,
+		`INSERT INTO daily_summaries (account_id, date, level, gold, exp, total_harvest, total_steal, total_help, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, date) DO UPDATE SET
+			level = excluded.level, gold = excluded.gold, exp = excluded.exp,
+			total_harvest = excluded.total_harvest, total_steal = excluded.total_steal,
+			total_help = excluded.total_help, updated_at = excluded.updated_at`,
+		d.AccountID, d.Date, d.Level, d.Gold, d.Exp, d.TotalHarvest, d.TotalSteal, d.TotalHelp, d.UpdatedAt)
+	return err
+}
+
+// AddFriendGrief adds g's weeds and insects to the friend's counters of
+// that day, keeping the latest friend name.
+func (s *SQLStore) AddFriendGrief(ctx context.Context, g *model.FriendGrief) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx,
+		`INSERT INTO friend_stats (account_id, date, friend_gid, friend_name, weeds, insects, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, date, friend_gid) DO UPDATE SET
+			friend_name = excluded.friend_name,
+			weeds = friend_stats.weeds + excluded.weeds,
+			insects = friend_stats.insects + excluded.insects,
+			updated_at = excluded.updated_at`,
+		g.AccountID, g.Date, g.FriendGID, g.FriendName, g.Weeds, g.Insects, time.Now())
 	return err
 }
 
+// GetBotState returns the blob a worker saved under key, or "" if none.
+func (s *SQLStore) GetBotState(ctx context.Context, accountID int64, key string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var data string
+	err := s.queryRow(ctx, `SELECT data FROM bot_state WHERE account_id = ? AND state_key = ?`, accountID, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return data, err
+}
+
+// PutBotState replaces the blob saved under key.
+func (s *SQLStore) PutBotState(ctx context.Context, accountID int64, key, data string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.exec(ctx,
+		`INSERT INTO bot_state (account_id, state_key, data, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(account_id, state_key) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		accountID, key, data, time.Now())
+	return err
+}
+
+// GetFriendGrief returns an account's per-friend counters from sinceDate
+// (inclusive, 2006-01-02) onwards, summed over the days, worst first.
+func (s *SQLStore) GetFriendGrief(ctx context.Context, accountID int64, sinceDate string) ([]model.FriendGrief, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx,
+		`SELECT friend_gid, MAX(friend_name), SUM(weeds), SUM(insects) FROM friend_stats
+		WHERE account_id = ? AND date >= ?
+		GROUP BY friend_gid
+		ORDER BY SUM(weeds) + SUM(insects) DESC, friend_gid`,
+		accountID, sinceDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []model.FriendGrief{}
+	for rows.Next() {
+		g := model.FriendGrief{AccountID: accountID}
+		if err := rows.Scan(&g.FriendGID, &g.FriendName, &g.Weeds, &g.Insects); err != nil {
+			return nil, err
+		}
+		result = append(result, g)
+	}
+	return result, rows.Err()
+}
+
+// GetDailySummaries returns summaries of the given accounts from sinceDate
+// (inclusive, 2006-01-02) onwards, ordered by date then account.
+func (s *SQLStore) GetDailySummaries(ctx context.Context, accountIDs []int64, sinceDate string) ([]model.DailySummary, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	result := []model.DailySummary{}
+	if len(accountIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.Repeat("?,", len(accountIDs))
+	args := make([]interface{}, 0, len(accountIDs)+1)
+	for _, id := range accountIDs {
+		args = append(args, id)
+	}
+	args = append(args, sinceDate)
+
+	rows, err := s.query(ctx,
+		`SELECT account_id, date, level, gold, exp, total_harvest, total_steal, total_help, updated_at
+		FROM daily_summaries WHERE account_id IN (`+placeholders[:len(placeholders)-1]+`) AND date >= ?
+		ORDER BY date ASC, account_id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d model.DailySummary
+		if err := rows.Scan(&d.AccountID, &d.Date, &d.Level, &d.Gold, &d.Exp,
+			&d.TotalHarvest, &d.TotalSteal, &d.TotalHelp, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+// GetTodayCounters sums op_stats since the given time for every account in
+// accountIDs with one query. Accounts without activity are absent from the map.
+func (s *SQLStore) GetTodayCounters(ctx context.Context, accountIDs []int64, since time.Time) (map[int64]*model.TodayCounters, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	result := make(map[int64]*model.TodayCounters)
+	if len(accountIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.Repeat("?,", len(accountIDs))
+	args := make([]interface{}, 0, len(accountIDs)+1)
+	for _, id := range accountIDs {
+		args = append(args, id)
+	}
+	args = append(args, since)
+
+	rows, err := s.query(ctx,
+		`SELECT account_id, op_type, COALESCE(SUM(count), 0), COALESCE(SUM(exp_delta), 0),
+			COALESCE(SUM(CASE WHEN gold_delta > 0 THEN gold_delta ELSE 0 END), 0)
+		FROM op_stats WHERE account_id IN (`+placeholders[:len(placeholders)-1]+`) AND created_at >= ?
+		GROUP BY account_id, op_type`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountID, count, exp, gold int64
+		var opType string
+		if err := rows.Scan(&accountID, &opType, &count, &exp, &gold); err != nil {
+			return nil, err
+		}
+		t := result[accountID]
+		if t == nil {
+			t = &model.TodayCounters{}
+			result[accountID] = t
+		}
+		t.ExpGained += exp
+		switch opType {
+		case model.OpHarvest:
+			t.Harvest += count
+		case model.OpSteal:
+			t.Steal += count
+			// Stolen fruit is valued, not gold received; selling it later
+			// is what earns the gold.
+			t.StealValue += gold
+			continue
+		case model.OpSell:
+			t.SellGold += gold
+		case model.OpHelpWeed, model.OpHelpBug, model.OpHelpWater:
+			t.Help += count
+		}
+		t.GoldGained += gold
+	}
+	return result, rows.Err()
+}
+
 // ============ Data Summary Queries ============
 
 // DataSummaryTotals holds the top-level summary numbers for the data summary page.
@@ -569,9 +1699,11 @@ type DataSummaryTotals struct {
 }
 
 // GetDataSummaryTotals returns aggregated harvest/steal totals for an account within a time range.
-func (s *Store) GetDataSummaryTotals(accountID int64, since time.Time) (*DataSummaryTotals, error) {
+func (s *SQLStore) GetDataSummaryTotals(ctx context.Context, accountID int64, since time.Time) (*DataSummaryTotals, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var t DataSummaryTotals
-	err := s.db.QueryRow(`
+	err := s.queryRow(ctx, `
 		SELECT
 			COALESCE(SUM(CASE WHEN op_type='harvest' THEN count ELSE 0 END), 0),
 			COALESCE(SUM(CASE WHEN op_type='sell' THEN gold_delta ELSE 0 END), 0),
@@ -595,10 +1727,12 @@ type HourlyTrendRow struct {
 }
 
 // GetHourlyTrend returns per-hour harvest/steal data for the last N hours.
-func (s *Store) GetHourlyTrend(accountID int64, since time.Time) ([]HourlyTrendRow, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) GetHourlyTrend(ctx context.Context, accountID int64, since time.Time) ([]HourlyTrendRow, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `
 		SELECT
-			strftime('%Y-%m-%d %H:00', created_at) AS hour,
+			`+s.dialect.timeBucket("hour", "created_at")+` AS hour,
 			COALESCE(SUM(CASE WHEN op_type='harvest' THEN count ELSE 0 END), 0),
 			COALESCE(SUM(CASE WHEN op_type='sell' THEN gold_delta ELSE 0 END), 0),
 			COALESCE(SUM(CASE WHEN op_type='steal' THEN count ELSE 0 END), 0),
@@ -633,8 +1767,10 @@ type CropBreakdownRow struct {
 
 // GetCropBreakdown returns crop-level sell breakdown by parsing the detail field.
 // detail format: "白萝卜x10, 核桃x5"
-func (s *Store) GetCropBreakdown(accountID int64, since time.Time) ([]CropBreakdownRow, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) GetCropBreakdown(ctx context.Context, accountID int64, since time.Time) ([]CropBreakdownRow, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `
 		SELECT detail, SUM(count) AS total_count, SUM(gold_delta) AS total_gold
 		FROM op_stats
 		WHERE account_id = ? AND created_at >= ? AND op_type = 'sell' AND detail != ''
@@ -665,8 +1801,10 @@ type StealRankingRow struct {
 }
 
 // GetStealRanking returns friends ranked by steal count.
-func (s *Store) GetStealRanking(accountID int64, since time.Time) ([]StealRankingRow, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) GetStealRanking(ctx context.Context, accountID int64, since time.Time) ([]StealRankingRow, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `
 		SELECT detail, SUM(count) AS total_count, SUM(gold_delta) AS total_gold
 		FROM op_stats
 		WHERE account_id = ? AND created_at >= ? AND op_type = 'steal' AND detail != ''
@@ -700,10 +1838,12 @@ type DailySummaryRow struct {
 }
 
 // GetDailySummary returns per-day summary for the last N days.
-func (s *Store) GetDailySummary(accountID int64, since time.Time) ([]DailySummaryRow, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) GetDailySummary(ctx context.Context, accountID int64, since time.Time) ([]DailySummaryRow, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.query(ctx, `
 		SELECT
-			strftime('%Y-%m-%d', created_at) AS day,
+			`+s.dialect.timeBucket("day", "created_at")+` AS day,
 			COALESCE(SUM(CASE WHEN op_type='harvest' THEN count ELSE 0 END), 0),
 			COALESCE(SUM(CASE WHEN op_type='sell' THEN gold_delta ELSE 0 END), 0),
 			COALESCE(SUM(CASE WHEN op_type='steal' THEN count ELSE 0 END), 0),
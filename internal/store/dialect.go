@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dialect covers the SQL differences between the supported databases.
+// Queries are written in SQLite syntax with ? placeholders and translated
+// here, so both backends share one implementation.
+//
+// Concurrency differs a lot between the two:
+//   - SQLite runs in WAL mode with a 5s busy_timeout. Readers never block,
+//     but writers serialize on the database file, and a writer that cannot
+//     get the lock within busy_timeout fails with "database is locked". WAL
+//     needs shared memory, so the file must not live on network storage.
+//   - Postgres has row-level locking and MVCC, so concurrent writers only
+//     wait on the rows they touch. There is no busy timeout; a blocked
+//     statement waits until the per-query context timeout cancels it.
+type dialect struct {
+	name string
+	// numbered placeholders ($1, $2, ...) instead of ?
+	numbered bool
+	// INSERT ... RETURNING id instead of LastInsertId
+	returning bool
+}
+
+var (
+	sqliteDialect   = &dialect{name: "sqlite"}
+	postgresDialect = &dialect{name: "postgres", numbered: true, returning: true}
+)
+
+// rebind rewrites ? placeholders outside string literals for the dialect.
+func (d *dialect) rebind(query string) string {
+	if !d.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n, quoted := 0, false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// ddl translates SQLite column types in CREATE/ALTER statements.
+func (d *dialect) ddl(stmt string) string {
+	if d != postgresDialect {
+		return stmt
+	}
+	r := strings.NewReplacer(
+		"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+		"INTEGER", "BIGINT",
+		"DATETIME", "TIMESTAMPTZ",
+	)
+	return r.Replace(stmt)
+}
+
+// timeBucket formats a timestamp column as text for grouping. layout is one
+// of "hour" (2006-01-02 15:00), "day" (2006-01-02) or "week" (2006-W01).
+// Postgres weeks are ISO weeks, SQLite weeks start on the first Monday.
+func (d *dialect) timeBucket(layout, col string) string {
+	if d == postgresDialect {
+		switch layout {
+		case "hour":
+			return fmt.Sprintf(`to_char(%s, 'YYYY-MM-DD HH24:00')`, col)
+		case "day":
+			return fmt.Sprintf(`to_char(%s, 'YYYY-MM-DD')`, col)
+		case "week":
+			return fmt.Sprintf(`to_char(%s, 'IYYY-"W"IW')`, col)
+		}
+	}
+	switch layout {
+	case "hour":
+		return fmt.Sprintf(`strftime('%%Y-%%m-%%d %%H:00', %s)`, col)
+	case "day":
+		return fmt.Sprintf(`strftime('%%Y-%%m-%%d', %s)`, col)
+	case "week":
+		return fmt.Sprintf(`strftime('%%Y-W%%W', %s)`, col)
+	}
+	return "'all'"
+}
+
+// columnsQuery lists a table's column names.
+func (d *dialect) columnsQuery(table string) string {
+	if d == postgresDialect {
+		return fmt.Sprintf(`SELECT column_name FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = '%s'`, table)
+	}
+	return fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table)
+}
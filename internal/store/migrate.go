@@ -0,0 +1,353 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migrateFunc runs one migration. Statements are written in SQLite syntax
+// and translated for the database by d.
+type migrateFunc func(tx *sql.Tx, d *dialect) error
+
+// migration is one numbered schema change. Pending migrations are applied in
+// order, each in its own transaction, and recorded in schema_migrations.
+// Released migrations must never be edited or reordered; append a new one.
+type migration struct {
+	version int
+	name    string
+	up      migrateFunc
+}
+
+// migrations brings a database to the current schema. Databases created
+// before versioning already carry some of these changes, so column additions
+// skip columns that exist and tables are created IF NOT EXISTS.
+var migrations = []migration{
+	{1, "initial schema", execSQL(`
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		is_admin INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`, `
+	CREATE TABLE IF NOT EXISTS accounts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL DEFAULT 1,
+		name TEXT NOT NULL DEFAULT '',
+		platform TEXT NOT NULL DEFAULT 'qq',
+		code TEXT NOT NULL DEFAULT '',
+		auto_start INTEGER NOT NULL DEFAULT 0,
+		farm_interval INTEGER NOT NULL DEFAULT 10,
+		friend_interval INTEGER NOT NULL DEFAULT 10,
+		enable_steal INTEGER NOT NULL DEFAULT 1,
+		force_lowest INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)`, `
+	CREATE TABLE IF NOT EXISTS logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		tag TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL DEFAULT '',
+		level TEXT NOT NULL DEFAULT 'info',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_account ON logs(account_id, created_at DESC)`,
+	)},
+	{2, "accounts.user_id", steps(
+		addColumns("accounts", "user_id INTEGER NOT NULL DEFAULT 1"),
+		execSQL(`UPDATE accounts SET user_id = 1 WHERE user_id = 0 OR user_id IS NULL`),
+	)},
+	{3, "fertilizer settings", addColumns("accounts",
+		"auto_use_fertilizer INTEGER NOT NULL DEFAULT 0",
+		"auto_buy_fertilizer INTEGER NOT NULL DEFAULT 0",
+		"fertilizer_target_count INTEGER NOT NULL DEFAULT 0",
+		"fertilizer_buy_daily_limit INTEGER NOT NULL DEFAULT 0",
+	)},
+	// Farm automation toggles default to 1 (enabled) for backward compatibility
+	{4, "farm automation toggles", addColumns("accounts",
+		"enable_harvest INTEGER NOT NULL DEFAULT 1",
+		"enable_plant INTEGER NOT NULL DEFAULT 1",
+		"enable_sell INTEGER NOT NULL DEFAULT 1",
+		"enable_weed INTEGER NOT NULL DEFAULT 1",
+		"enable_bug INTEGER NOT NULL DEFAULT 1",
+		"enable_water INTEGER NOT NULL DEFAULT 1",
+		"enable_remove_dead INTEGER NOT NULL DEFAULT 1",
+		"enable_upgrade_land INTEGER NOT NULL DEFAULT 1",
+		"enable_help_friend INTEGER NOT NULL DEFAULT 1",
+		"enable_claim_task INTEGER NOT NULL DEFAULT 1",
+	)},
+	{5, "crop selection and filtering", addColumns("accounts",
+		"plant_crop_id INTEGER NOT NULL DEFAULT 0",
+		"sell_crop_ids TEXT NOT NULL DEFAULT ''",
+		"steal_crop_ids TEXT NOT NULL DEFAULT ''",
+	)},
+	{6, "anti-detection", addColumns("accounts", "enable_anti_detection INTEGER NOT NULL DEFAULT 0")},
+	{7, "per-account API key", addColumns("accounts", "api_key TEXT NOT NULL DEFAULT ''")},
+	{8, "op_stats table", execSQL(`CREATE TABLE IF NOT EXISTS op_stats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		op_type TEXT NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		gold_delta INTEGER NOT NULL DEFAULT 0,
+		exp_delta INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_op_stats_account_time ON op_stats(account_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_op_stats_type ON op_stats(account_id, op_type, created_at)`,
+	)},
+	{9, "op_stats.detail", addColumns("op_stats", "detail TEXT NOT NULL DEFAULT ''")},
+	{10, "prefer_bag_seeds", addColumns("accounts", "prefer_bag_seeds INTEGER NOT NULL DEFAULT 0")},
+	// planting_strategy holds JSON-encoded composable rules
+	{11, "planting strategy and debug log", addColumns("accounts",
+		"planting_strategy TEXT NOT NULL DEFAULT ''",
+		"enable_debug_log INTEGER NOT NULL DEFAULT 0",
+	)},
+	{12, "sessions for revocable refresh tokens", execSQL(`CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		revoked INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id)`,
+	)},
+	{13, "audit table", execSQL(`CREATE TABLE IF NOT EXISTS audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL DEFAULT 0,
+		username TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL,
+		account_id INTEGER NOT NULL DEFAULT 0,
+		summary TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_time ON audit(created_at DESC)`,
+	)},
+	{14, "account tags", addColumns("accounts", "tags TEXT NOT NULL DEFAULT ''")},
+	{15, "per-account log storage policy", addColumns("accounts",
+		"log_level TEXT NOT NULL DEFAULT ''",
+		"log_tag_blacklist TEXT NOT NULL DEFAULT ''",
+	)},
+	// One row per account and game day, for history charts
+	{16, "daily_summaries table", execSQL(`CREATE TABLE IF NOT EXISTS daily_summaries (
+		account_id INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		level INTEGER NOT NULL DEFAULT 0,
+		gold INTEGER NOT NULL DEFAULT 0,
+		exp INTEGER NOT NULL DEFAULT 0,
+		total_harvest INTEGER NOT NULL DEFAULT 0,
+		total_steal INTEGER NOT NULL DEFAULT 0,
+		total_help INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (account_id, date)
+	)`)},
+	{17, "health_probe table", execSQL(`CREATE TABLE IF NOT EXISTS health_probe (
+		id INTEGER PRIMARY KEY,
+		checked_at DATETIME NOT NULL
+	)`)},
+	{18, "server_url_override", addColumns("accounts", "server_url_override TEXT NOT NULL DEFAULT ''")},
+	{19, "notify_webhook_url", addColumns("accounts", "notify_webhook_url TEXT NOT NULL DEFAULT ''")},
+	{20, "accounts.deleted_at", addColumns("accounts", "deleted_at DATETIME")},
+	{21, "logs.correlation_id", steps(
+		addColumns("logs", "correlation_id TEXT NOT NULL DEFAULT ''"),
+		execSQL(`CREATE INDEX IF NOT EXISTS idx_logs_correlation ON logs(correlation_id)`),
+	)},
+	{22, "accounts.notes and sort_order", addColumns("accounts",
+		"notes TEXT NOT NULL DEFAULT ''",
+		"sort_order INTEGER NOT NULL DEFAULT 0")},
+	{23, "api_tokens", execSQL(`CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		expires_at DATETIME
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id)`,
+	)},
+	{24, "activity table", execSQL(`CREATE TABLE IF NOT EXISTS activity (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_account ON activity(account_id, id)`,
+	)},
+	{25, "force_lowest floors", addColumns("accounts",
+		"force_lowest_min_exp INTEGER NOT NULL DEFAULT 0",
+		"force_lowest_max_price INTEGER NOT NULL DEFAULT 0")},
+	// Weeds/insects left on our lands, per friend and game day
+	{26, "friend_stats table", execSQL(`CREATE TABLE IF NOT EXISTS friend_stats (
+		account_id INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		friend_gid INTEGER NOT NULL,
+		friend_name TEXT NOT NULL DEFAULT '',
+		weeds INTEGER NOT NULL DEFAULT 0,
+		insects INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (account_id, date, friend_gid)
+	)`)},
+	{27, "no_harvest_crop_ids", addColumns("accounts", "no_harvest_crop_ids TEXT NOT NULL DEFAULT ''")},
+	{28, "friend_list_refresh_interval", addColumns("accounts", "friend_list_refresh_interval INTEGER NOT NULL DEFAULT 600")},
+	{29, "warehouse_interval", addColumns("accounts", "warehouse_interval INTEGER NOT NULL DEFAULT 600")},
+	{30, "level-up replant", addColumns("accounts",
+		"replant_on_level_up INTEGER NOT NULL DEFAULT 0",
+		"replant_margin_pct INTEGER NOT NULL DEFAULT 20")},
+	{31, "warm_up_level", addColumns("accounts", "warm_up_level INTEGER NOT NULL DEFAULT 0")},
+	{32, "login failure state", addColumns("accounts",
+		"login_fail_reason TEXT NOT NULL DEFAULT ''",
+		"login_fail_count INTEGER NOT NULL DEFAULT 0",
+		"login_retry_at DATETIME")},
+	{33, "interval_jitter_pct", addColumns("accounts", "interval_jitter_pct INTEGER NOT NULL DEFAULT 15")},
+	// Worker checkpoints that survive reconnects and restarts
+	{34, "bot_state table", execSQL(`CREATE TABLE IF NOT EXISTS bot_state (
+		account_id INTEGER NOT NULL,
+		state_key TEXT NOT NULL,
+		data TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (account_id, state_key)
+	)`)},
+	{35, "family_id", addColumns("accounts", "family_id INTEGER NOT NULL DEFAULT 0")},
+	{36, "rpc_deny", addColumns("accounts", "rpc_deny TEXT NOT NULL DEFAULT ''")},
+	// Gold payments by category, for cost accounting
+	{37, "spend table", execSQL(`CREATE TABLE IF NOT EXISTS spend (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		category TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		detail TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_spend_account_time ON spend(account_id, created_at)`,
+	)},
+	{38, "gold_reserve", addColumns("accounts", "gold_reserve INTEGER NOT NULL DEFAULT 0")},
+	{39, "notify_before_mature_minutes", addColumns("accounts", "notify_before_mature_minutes INTEGER NOT NULL DEFAULT 0")},
+	{40, "users.locale", addColumns("users", "locale TEXT NOT NULL DEFAULT 'zh'")},
+	{41, "api_tokens.account_id", addColumns("api_tokens", "account_id INTEGER NOT NULL DEFAULT 0")},
+}
+
+// LatestSchemaVersion is the schema version this build migrates to.
+var LatestSchemaVersion = migrations[len(migrations)-1].version
+
+func execSQL(stmts ...string) migrateFunc {
+	return func(tx *sql.Tx, d *dialect) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(d.ddl(stmt)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumns adds columns given as "name TYPE ..." definitions, skipping
+// columns the table already has.
+func addColumns(table string, defs ...string) migrateFunc {
+	return func(tx *sql.Tx, d *dialect) error {
+		existing, err := tableColumns(tx, d, table)
+		if err != nil {
+			return err
+		}
+		for _, def := range defs {
+			name := strings.Fields(def)[0]
+			if existing[name] {
+				continue
+			}
+			if _, err := tx.Exec(d.ddl(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def))); err != nil {
+				return fmt.Errorf("add column %s.%s: %w", table, name, err)
+			}
+		}
+		return nil
+	}
+}
+
+func steps(fns ...migrateFunc) migrateFunc {
+	return func(tx *sql.Tx, d *dialect) error {
+		for _, fn := range fns {
+			if err := fn(tx, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func tableColumns(tx *sql.Tx, d *dialect, table string) (map[string]bool, error) {
+	rows, err := tx.Query(d.columnsQuery(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+// migrate applies pending migrations. Any failure aborts startup: the failed
+// migration is rolled back and later ones are not attempted.
+func (s *SQLStore) migrate() error {
+	if _, err := s.db.Exec(s.dialect.ddl(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)); err != nil {
+		return err
+	}
+
+	current, err := s.SchemaVersion(context.Background())
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, LatestSchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := m.up(tx, s.dialect); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(s.dialect.rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), m.version, m.name); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the highest applied migration (0 for a new database).
+func (s *SQLStore) SchemaVersion(ctx context.Context) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var v int
+	err := s.queryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
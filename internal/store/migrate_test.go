@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// legacyDB writes a database in the pre-versioning layout of
+// testdata/legacy.sql and returns its path.
+func legacyDB(t *testing.T) string {
+	t.Helper()
+	fixture, err := os.ReadFile("testdata/legacy.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(fixture)); err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	return path
+}
+
+func openTestStore(t *testing.T, path string) *SQLStore {
+	t.Helper()
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", filepath.Base(path), err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// sqliteSchema describes every table's columns and indexes, in a form that
+// doesn't depend on how the DDL text was written.
+func sqliteSchema(t *testing.T, db *sql.DB) map[string][]string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	schema := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		schema[table] = append(pragmaRows(t, db, "table_info", table),
+			pragmaRows(t, db, "index_list", table)...)
+		for _, idx := range pragmaRows(t, db, "index_list", table) {
+			name := strings.Fields(idx)[1]
+			schema[table] = append(schema[table], name+": "+strings.Join(pragmaRows(t, db, "index_xinfo", name), ", "))
+		}
+	}
+	return schema
+}
+
+func pragmaRows(t *testing.T, db *sql.DB, pragma, arg string) []string {
+	t.Helper()
+	rows, err := db.Query(fmt.Sprintf("PRAGMA %s(%q)", pragma, arg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, _ := rows.Columns()
+	var out []string
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		line := make([]string, len(vals))
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			line[i] = fmt.Sprint(v)
+		}
+		out = append(out, strings.Join(line, " "))
+	}
+	return out
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	legacy := openTestStore(t, legacyDB(t))
+	fresh := openTestStore(t, filepath.Join(t.TempDir(), "fresh.db"))
+	ctx := context.Background()
+
+	for name, s := range map[string]*SQLStore{"legacy": legacy, "fresh": fresh} {
+		if v, err := s.SchemaVersion(ctx); err != nil || v != LatestSchemaVersion {
+			t.Fatalf("%s SchemaVersion = %d, %v; want %d", name, v, err, LatestSchemaVersion)
+		}
+	}
+
+	got, want := sqliteSchema(t, legacy.db), sqliteSchema(t, fresh.db)
+	for table := range want {
+		if !reflect.DeepEqual(got[table], want[table]) {
+			t.Errorf("table %s differs from a fresh database:\n got %q\nwant %q", table, got[table], want[table])
+		}
+	}
+	for table := range got {
+		if _, ok := want[table]; !ok {
+			t.Errorf("legacy database has extra table %s", table)
+		}
+	}
+}
+
+func TestMigrateLegacyDatabaseKeepsData(t *testing.T) {
+	s := openTestStore(t, legacyDB(t))
+	ctx := context.Background()
+
+	u, err := s.GetUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.IsAdmin || u.PasswordHash != "hash" || u.Locale != "zh" {
+		t.Errorf("user = %+v", u)
+	}
+
+	a, err := s.GetAccount(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Settings from the old columns survive
+	if a.Name != "old farm" || a.Platform != "wx" || a.FarmInterval != 30 || a.EnableHarvest || a.PlantCropID != 5 ||
+		a.APIKey != "legacy-key" || a.PlantingStrategy != `{"rules":[]}` {
+		t.Errorf("account lost settings: %+v", a)
+	}
+	// and columns added since get their defaults
+	if !a.EnablePlant || a.WarehouseInterval != 600 || a.IntervalJitterPct != 15 || a.ReplantMarginPct != 20 {
+		t.Errorf("new columns not defaulted: %+v", a)
+	}
+
+	logs, err := s.GetLogs(ctx, 1, 10, 0, "")
+	if err != nil || len(logs) != 1 || logs[0].Message != "harvested" {
+		t.Errorf("logs = %+v, %v", logs, err)
+	}
+	var detail string
+	if err := s.db.QueryRow(`SELECT detail FROM op_stats WHERE account_id = 1`).Scan(&detail); err != nil || detail != "白萝卜" {
+		t.Errorf("op_stats detail = %q, %v", detail, err)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	path := legacyDB(t)
+	openTestStore(t, path).Close()
+	s := openTestStore(t, path)
+	var applied int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Fatalf("schema_migrations has %d rows after reopening, want %d", applied, len(migrations))
+	}
+}
+
+func TestMigrationFailureAbortsStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "farm.db")
+	openTestStore(t, path).Close()
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(migrations[:len(migrations):len(migrations)], migration{LatestSchemaVersion + 1, "broken", execSQL(
+		`CREATE TABLE half_done (id INTEGER)`,
+		`ALTER TABLE no_such_table ADD COLUMN x INTEGER`,
+	)})
+
+	if _, err := New(path); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("New with a failing migration: err = %v", err)
+	}
+
+	// The failed migration was rolled back as a whole
+	migrations = saved
+	s := openTestStore(t, path)
+	if v, _ := s.SchemaVersion(context.Background()); v != LatestSchemaVersion {
+		t.Fatalf("SchemaVersion = %d, want %d", v, LatestSchemaVersion)
+	}
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n)
+	if n != 0 {
+		t.Fatal("table from the failed migration left behind")
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "farm.db")
+	s := openTestStore(t, path)
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'from the future')`, LatestSchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, err := New(path); err == nil {
+		t.Fatal("opened a database migrated by a newer build")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build postgres
+
+package store
+
+// Registers the "postgres" database/sql driver. Build with
+//
+//	go build -tags postgres ./cmd/server
+//
+// to enable db_driver "postgres".
+import _ "github.com/lib/pq"
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+// ErrForeignAccount is returned when an operation names an account that
+// does not belong to the user it is performed for.
+var ErrForeignAccount = errors.New("account does not belong to the user")
+
+// Store is the persistence API used by the server, the bots and the command
+// line tools. SQLStore implements it for SQLite (default) and Postgres.
+type Store interface {
+	Close() error
+	SetQueryTimeout(d time.Duration)
+	SchemaVersion(ctx context.Context) (int, error)
+	CheckWritable(ctx context.Context) error
+
+	// Accounts
+	ListAccounts(ctx context.Context) ([]model.Account, error)
+	ListAccountsByUserID(ctx context.Context, userID int64) ([]model.Account, error)
+	ListAccountsFiltered(ctx context.Context, f AccountFilter) ([]model.Account, error)
+	CountAccounts(ctx context.Context, f AccountFilter) (int64, error)
+	GetAccount(ctx context.Context, id int64) (*model.Account, error)
+	GetAccountByName(ctx context.Context, name string) (*model.Account, error)
+	GetAccountByAPIKey(ctx context.Context, apiKey string) (*model.Account, error)
+	ListAccountsByCode(ctx context.Context, code string) ([]model.Account, error)
+	CreateAccount(ctx context.Context, a *model.Account) error
+	UpdateAccount(ctx context.Context, a *model.Account) error
+	UpdateAccountName(ctx context.Context, id int64, name string) error
+	SetLoginFailure(ctx context.Context, id int64, reason string, count int, retryAt time.Time) error
+	ClearLoginFailure(ctx context.Context, id int64) error
+	ReorderAccounts(ctx context.Context, userID int64, ids []int64) error
+	DeleteAccount(ctx context.Context, id int64) error
+	ListDeletedAccounts(ctx context.Context, userID int64) ([]model.Account, error)
+	GetDeletedAccount(ctx context.Context, id int64) (*model.Account, error)
+	RestoreAccount(ctx context.Context, id int64) error
+	PurgeDeletedAccounts(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// Logs
+	AddLog(ctx context.Context, entry *model.LogEntry) error
+	GetLogs(ctx context.Context, accountID int64, limit int, beforeID int64, correlationID string) ([]model.LogEntry, error)
+	CleanOldLogs(ctx context.Context, days int) error
+
+	// Activity feed
+	AddActivity(ctx context.Context, e *model.ActivityEvent) error
+	ListActivity(ctx context.Context, f ActivityFilter) ([]model.ActivityEvent, error)
+
+	// Maintenance
+	Maintain(ctx context.Context, logRetentionDays int) (*model.MaintenanceReport, error)
+	LastMaintenance() *model.MaintenanceReport
+
+	// Users and sessions
+	CreateUser(ctx context.Context, u *model.User) error
+	GetUserByID(ctx context.Context, id int64) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	UserExists(ctx context.Context, username string) (bool, error)
+	HasAnyUser(ctx context.Context) (bool, error)
+	HasAdminUser(ctx context.Context) (bool, error)
+	UpdateUserPassword(ctx context.Context, id int64, passwordHash string) error
+	UpdateUserLocale(ctx context.Context, id int64, locale string) error
+	CreateSession(ctx context.Context, sess *model.Session) error
+	GetSessionByTokenHash(ctx context.Context, tokenHash string) (*model.Session, error)
+	RevokeSession(ctx context.Context, id int64) error
+	RevokeUserSessions(ctx context.Context, userID int64) error
+	CleanExpiredSessions(ctx context.Context) error
+	CreateAPIToken(ctx context.Context, t *model.APIToken) error
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error)
+	ListAPITokens(ctx context.Context, userID int64) ([]model.APIToken, error)
+	DeleteAPIToken(ctx context.Context, userID, id int64) error
+	DeleteUserAPITokens(ctx context.Context, userID int64) error
+	TouchAPIToken(ctx context.Context, id int64, at time.Time) error
+
+	// Audit
+	AddAudit(ctx context.Context, e *model.AuditEntry) error
+	ListAudit(ctx context.Context, userID int64, action string, limit, offset int) ([]model.AuditEntry, int64, error)
+	CleanOldAudit(ctx context.Context, days int) error
+
+	// Operation stats and summaries
+	AddOpStat(ctx context.Context, r *model.OpRecord) error
+	GetOpStats(ctx context.Context, accountID int64, granularity string, from, to time.Time) ([]model.AggregatedStats, error)
+	GetOpStatsSummary(ctx context.Context, accountID int64) (map[string]int64, int64, int64, int64, error)
+	CleanOldOpStats(ctx context.Context, days int) error
+	GetOpCounts(ctx context.Context, accountID int64, from, to time.Time) (map[string]int64, error)
+	UpsertDailySummary(ctx context.Context, d *model.DailySummary) error
+	GetDailySummaries(ctx context.Context, accountIDs []int64, sinceDate string) ([]model.DailySummary, error)
+	AddFriendGrief(ctx context.Context, g *model.FriendGrief) error
+	GetFriendGrief(ctx context.Context, accountID int64, sinceDate string) ([]model.FriendGrief, error)
+	GetBotState(ctx context.Context, accountID int64, key string) (string, error)
+	PutBotState(ctx context.Context, accountID int64, key, data string) error
+	GetTodayCounters(ctx context.Context, accountIDs []int64, since time.Time) (map[int64]*model.TodayCounters, error)
+	AddSpend(ctx context.Context, r *model.SpendRecord) error
+	GetSpendTotals(ctx context.Context, accountIDs []int64, since time.Time) (map[int64]map[string]int64, error)
+	GetDataSummaryTotals(ctx context.Context, accountID int64, since time.Time) (*DataSummaryTotals, error)
+	GetHourlyTrend(ctx context.Context, accountID int64, since time.Time) ([]HourlyTrendRow, error)
+	GetCropBreakdown(ctx context.Context, accountID int64, since time.Time) ([]CropBreakdownRow, error)
+	GetStealRanking(ctx context.Context, accountID int64, since time.Time) ([]StealRankingRow, error)
+	GetDailySummary(ctx context.Context, accountID int64, since time.Time) ([]DailySummaryRow, error)
+}
+
+var _ Store = (*SQLStore)(nil)
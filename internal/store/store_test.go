@@ -0,0 +1,417 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"qq-farm-bot/internal/model"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, openTestStore(t, filepath.Join(t.TempDir(), "farm.db")))
+}
+
+// testStore runs the behaviour every backend must share against a freshly
+// migrated, empty store.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if v, err := s.SchemaVersion(ctx); err != nil || v != LatestSchemaVersion {
+		t.Fatalf("SchemaVersion = %d, %v; want %d", v, err, LatestSchemaVersion)
+	}
+	if err := s.CheckWritable(ctx); err != nil {
+		t.Fatalf("CheckWritable: %v", err)
+	}
+
+	newUser := func(t *testing.T, name string, admin bool) *model.User {
+		t.Helper()
+		u := &model.User{Username: name, PasswordHash: "hash", IsAdmin: admin}
+		if err := s.CreateUser(ctx, u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		return u
+	}
+	newAccount := func(t *testing.T, owner *model.User, name string) *model.Account {
+		t.Helper()
+		a := &model.Account{UserID: owner.ID, Name: name, Platform: "qq", FarmInterval: 10, EnableHarvest: true}
+		if err := s.CreateAccount(ctx, a); err != nil {
+			t.Fatalf("CreateAccount: %v", err)
+		}
+		return a
+	}
+
+	t.Run("users", func(t *testing.T) {
+		u := newUser(t, "users-admin", true)
+		if u.ID == 0 {
+			t.Fatal("CreateUser left ID 0")
+		}
+		if err := s.CreateUser(ctx, &model.User{Username: "users-admin", PasswordHash: "x"}); err == nil {
+			t.Fatal("duplicate username accepted")
+		}
+		if err := s.UpdateUserLocale(ctx, u.ID, "en"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetUserByUsername(ctx, "users-admin")
+		if err != nil || got.ID != u.ID || !got.IsAdmin || got.Locale != "en" {
+			t.Fatalf("GetUserByUsername = %+v, %v", got, err)
+		}
+		if ok, err := s.HasAdminUser(ctx); err != nil || !ok {
+			t.Fatalf("HasAdminUser = %v, %v", ok, err)
+		}
+		if _, err := s.GetUserByID(ctx, u.ID+1000); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("missing user: err = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("accounts", func(t *testing.T) {
+		u := newUser(t, "accounts-owner", false)
+		a := newAccount(t, u, "Alpha_1")
+		b := newAccount(t, u, "beta%")
+		if a.ID == 0 || b.ID == a.ID {
+			t.Fatalf("account ids %d, %d", a.ID, b.ID)
+		}
+
+		a.FarmInterval = 42
+		a.EnableHarvest = false
+		a.Tags = "main"
+		a.GoldReserve = 5000
+		if err := s.UpdateAccount(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetAccount(ctx, a.ID)
+		if err != nil || got.FarmInterval != 42 || got.EnableHarvest || got.Tags != "main" || got.GoldReserve != 5000 {
+			t.Fatalf("GetAccount after update = %+v, %v", got, err)
+		}
+
+		// Name search is case-insensitive and takes wildcards literally
+		for q, want := range map[string]int{"alpha": 1, "%": 1, "_": 1, "": 2, "gamma": 0} {
+			list, err := s.ListAccountsFiltered(ctx, AccountFilter{UserID: u.ID, Query: q})
+			if err != nil || len(list) != want {
+				t.Errorf("filter %q = %d accounts, %v; want %d", q, len(list), err, want)
+			}
+		}
+		if n, err := s.CountAccounts(ctx, AccountFilter{UserID: u.ID, Tag: "main"}); err != nil || n != 1 {
+			t.Errorf("CountAccounts by tag = %d, %v", n, err)
+		}
+
+		if err := s.ReorderAccounts(ctx, u.ID, []int64{b.ID}); err != nil {
+			t.Fatal(err)
+		}
+		list, err := s.ListAccountsByUserID(ctx, u.ID)
+		if err != nil || len(list) != 2 || list[0].ID != b.ID || list[1].ID != a.ID {
+			t.Fatalf("order after ReorderAccounts = %+v, %v", list, err)
+		}
+		other := newUser(t, "accounts-other", false)
+		if err := s.ReorderAccounts(ctx, other.ID, []int64{a.ID}); !errors.Is(err, ErrForeignAccount) {
+			t.Fatalf("reorder another user's account: err = %v", err)
+		}
+	})
+
+	t.Run("account fields", func(t *testing.T) {
+		u := newUser(t, "fields-owner", false)
+
+		// Every setting survives CreateAccount
+		src := filledAccount(t, "src")
+		src.UserID = u.ID
+		if err := s.CreateAccount(ctx, src); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetAccount(ctx, src.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareAccountFields(t, "CreateAccount", got, src, accountBookkeeping)
+
+		// ... and UpdateAccount
+		dst := newAccount(t, u, "fields-dst")
+		upd := filledAccount(t, "upd")
+		upd.ID, upd.UserID = dst.ID, u.ID
+		if err := s.UpdateAccount(ctx, upd); err != nil {
+			t.Fatal(err)
+		}
+		if got, err = s.GetAccount(ctx, dst.ID); err != nil {
+			t.Fatal(err)
+		}
+		compareAccountFields(t, "UpdateAccount", got, upd, accountBookkeeping)
+
+		// A saved clone keeps every setting except the ones it resets
+		clone := src.CloneSettings()
+		if err := s.CreateAccount(ctx, clone); err != nil {
+			t.Fatal(err)
+		}
+		if got, err = s.GetAccount(ctx, clone.ID); err != nil {
+			t.Fatal(err)
+		}
+		compareAccountFields(t, "CloneSettings", got, src, cloneResets)
+		if got.Name != src.Name+" 副本" || got.Code != "" || got.AutoStart || got.APIKey != "" {
+			t.Errorf("clone identity not reset: name %q, code %q, auto_start %v, api_key %q", got.Name, got.Code, got.AutoStart, got.APIKey)
+		}
+	})
+
+	t.Run("login failure", func(t *testing.T) {
+		u := newUser(t, "failing-owner", false)
+		a := newAccount(t, u, "failing")
+		a.Code = "old-code"
+		if err := s.UpdateAccount(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		retryAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		if err := s.SetLoginFailure(ctx, a.ID, "LoginFailed", 3, retryAt); err != nil {
+			t.Fatal(err)
+		}
+		check := func(step string, count int, pending bool) {
+			t.Helper()
+			got, err := s.GetAccount(ctx, a.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.LoginFailCount != count || got.LoginRetryPending(time.Now()) != pending {
+				t.Fatalf("%s: fail count %d, retry at %v; want %d, pending %v", step, got.LoginFailCount, got.LoginRetryAt, count, pending)
+			}
+			if pending && (!got.LoginRetryAt.Equal(retryAt) || got.LoginFailReason != "LoginFailed") {
+				t.Fatalf("%s: retry at %v, reason %q", step, got.LoginRetryAt, got.LoginFailReason)
+			}
+			if !pending && got.LoginFailReason != "" {
+				t.Fatalf("%s: reason %q survived", step, got.LoginFailReason)
+			}
+		}
+		check("set", 3, true)
+
+		// Saving other settings keeps the failure
+		a.FarmInterval = 20
+		if err := s.UpdateAccount(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		check("same code", 3, true)
+
+		// A new code clears it
+		a.Code = "new-code"
+		if err := s.UpdateAccount(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		check("new code", 0, false)
+
+		// ... as does a successful login
+		s.SetLoginFailure(ctx, a.ID, "LoginFailed", 3, retryAt)
+		if err := s.ClearLoginFailure(ctx, a.ID); err != nil {
+			t.Fatal(err)
+		}
+		check("cleared", 0, false)
+	})
+
+	t.Run("recycle bin", func(t *testing.T) {
+		u := newUser(t, "bin-owner", false)
+		a := newAccount(t, u, "binned")
+		s.PutBotState(ctx, a.ID, "farm", "{}")
+		s.AddOpStat(ctx, &model.OpRecord{AccountID: a.ID, OpType: "harvest", Count: 1})
+
+		if err := s.DeleteAccount(ctx, a.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteAccount(ctx, a.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("second delete: err = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := s.GetAccount(ctx, a.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("GetAccount of a deleted account: err = %v", err)
+		}
+		if del, err := s.ListDeletedAccounts(ctx, u.ID); err != nil || len(del) != 1 || del[0].DeletedAt == nil {
+			t.Fatalf("ListDeletedAccounts = %+v, %v", del, err)
+		}
+
+		if err := s.RestoreAccount(ctx, a.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RestoreAccount(ctx, a.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("restoring a live account: err = %v, want sql.ErrNoRows", err)
+		}
+
+		s.DeleteAccount(ctx, a.ID)
+		if n, err := s.PurgeDeletedAccounts(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Fatalf("purge before the cutoff = %d, %v", n, err)
+		}
+		if n, err := s.PurgeDeletedAccounts(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+			t.Fatalf("purge = %d, %v; want 1", n, err)
+		}
+		if data, err := s.GetBotState(ctx, a.ID, "farm"); err != nil || data != "" {
+			t.Fatalf("bot state survived the purge: %q, %v", data, err)
+		}
+		if counts, err := s.GetOpCounts(ctx, a.ID, time.Time{}, time.Now().Add(time.Hour)); err != nil || len(counts) != 0 {
+			t.Fatalf("op stats survived the purge: %v, %v", counts, err)
+		}
+	})
+
+	t.Run("sessions and tokens", func(t *testing.T) {
+		u := newUser(t, "tokens-owner", false)
+		a := newAccount(t, u, "scoped")
+
+		sess := &model.Session{UserID: u.ID, TokenHash: "session-hash", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := s.CreateSession(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetSessionByTokenHash(ctx, "session-hash")
+		if err != nil || got.ID != sess.ID || got.Revoked {
+			t.Fatalf("GetSessionByTokenHash = %+v, %v", got, err)
+		}
+		if err := s.RevokeSession(ctx, sess.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RevokeSession(ctx, sess.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("second revoke: err = %v, want sql.ErrNoRows", err)
+		}
+
+		tok := &model.APIToken{UserID: u.ID, Name: "script", TokenHash: "token-hash", Prefix: "qfb_", AccountID: a.ID}
+		if err := s.CreateAPIToken(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Truncate(time.Second)
+		if err := s.TouchAPIToken(ctx, tok.ID, used); err != nil {
+			t.Fatal(err)
+		}
+		gotTok, err := s.GetAPITokenByHash(ctx, "token-hash")
+		if err != nil || gotTok.AccountID != a.ID || gotTok.LastUsedAt == nil || !gotTok.LastUsedAt.Equal(used) {
+			t.Fatalf("GetAPITokenByHash = %+v, %v", gotTok, err)
+		}
+		other := newUser(t, "tokens-other", false)
+		if err := s.DeleteAPIToken(ctx, other.ID, tok.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("deleting another user's token: err = %v", err)
+		}
+		if err := s.DeleteAPIToken(ctx, u.ID, tok.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		u := newUser(t, "logs-owner", false)
+		a := newAccount(t, u, "logged")
+		for _, e := range []model.LogEntry{
+			{AccountID: a.ID, Tag: "农场", Message: "first", Level: "info"},
+			{AccountID: a.ID, Tag: "农场", Message: "second", Level: "warn", CorrelationID: "req-1"},
+		} {
+			if err := s.AddLog(ctx, &e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		logs, err := s.GetLogs(ctx, a.ID, 10, 0, "")
+		if err != nil || len(logs) != 2 || logs[0].Message != "second" {
+			t.Fatalf("GetLogs = %+v, %v; want newest first", logs, err)
+		}
+		logs, err = s.GetLogs(ctx, a.ID, 10, 0, "req-1")
+		if err != nil || len(logs) != 1 || logs[0].Message != "second" {
+			t.Fatalf("GetLogs by correlation id = %+v, %v", logs, err)
+		}
+		if older, err := s.GetLogs(ctx, a.ID, 10, logs[0].ID, ""); err != nil || len(older) != 1 || older[0].Message != "first" {
+			t.Fatalf("GetLogs before %d = %+v, %v", logs[0].ID, older, err)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		u := newUser(t, "stats-owner", false)
+		a := newAccount(t, u, "counted")
+		now := time.Now()
+		s.AddOpStat(ctx, &model.OpRecord{AccountID: a.ID, OpType: "harvest", Count: 3, ExpDelta: 30})
+		s.AddOpStat(ctx, &model.OpRecord{AccountID: a.ID, OpType: "harvest", Count: 2, ExpDelta: 20})
+		s.AddOpStat(ctx, &model.OpRecord{AccountID: a.ID, OpType: "sell", Count: 1, GoldDelta: 100})
+
+		counts, err := s.GetOpCounts(ctx, a.ID, now.Add(-time.Hour), now.Add(time.Hour))
+		if err != nil || counts["harvest"] != 5 || counts["sell"] != 1 {
+			t.Fatalf("GetOpCounts = %v, %v", counts, err)
+		}
+		if counts, _ := s.GetOpCounts(ctx, a.ID, now.Add(time.Hour), now.Add(2*time.Hour)); len(counts) != 0 {
+			t.Fatalf("GetOpCounts outside the range = %v", counts)
+		}
+		byDay, err := s.GetOpStats(ctx, a.ID, "day", time.Time{}, time.Time{})
+		if err != nil || len(byDay) == 0 {
+			t.Fatalf("GetOpStats by day = %+v, %v", byDay, err)
+		}
+
+		s.AddSpend(ctx, &model.SpendRecord{AccountID: a.ID, Category: model.SpendSeed, Amount: 60})
+		s.AddSpend(ctx, &model.SpendRecord{AccountID: a.ID, Category: model.SpendSeed, Amount: 40})
+		spend, err := s.GetSpendTotals(ctx, []int64{a.ID}, now.Add(-time.Hour))
+		if err != nil || spend[a.ID][model.SpendSeed] != 100 {
+			t.Fatalf("GetSpendTotals = %v, %v", spend, err)
+		}
+
+		// A second upsert for the same day replaces the first
+		for _, gold := range []int64{100, 250} {
+			if err := s.UpsertDailySummary(ctx, &model.DailySummary{AccountID: a.ID, Date: "2026-10-16", Level: 10, Gold: gold}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		days, err := s.GetDailySummaries(ctx, []int64{a.ID}, "2026-10-01")
+		if err != nil || len(days) != 1 || days[0].Gold != 250 {
+			t.Fatalf("GetDailySummaries = %+v, %v", days, err)
+		}
+
+		s.PutBotState(ctx, a.ID, "farm", "v1")
+		s.PutBotState(ctx, a.ID, "farm", "v2")
+		if data, err := s.GetBotState(ctx, a.ID, "farm"); err != nil || data != "v2" {
+			t.Fatalf("GetBotState = %q, %v", data, err)
+		}
+		if data, err := s.GetBotState(ctx, a.ID, "missing"); err != nil || data != "" {
+			t.Fatalf("GetBotState of a missing key = %q, %v", data, err)
+		}
+	})
+}
+
+// Account fields CreateAccount and UpdateAccount don't take from the caller:
+// the ID and timestamps are the store's, login failures and deletion have
+// their own methods, and an update never changes the owner.
+var accountBookkeeping = map[string]bool{
+	"ID": true, "UserID": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true,
+	"LoginFailReason": true, "LoginFailCount": true, "LoginRetryAt": true,
+}
+
+// Account fields CloneSettings resets instead of copying.
+var cloneResets = map[string]bool{
+	"ID": true, "Name": true, "Code": true, "AutoStart": true, "APIKey": true,
+	"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true,
+	"LoginFailReason": true, "LoginFailCount": true, "LoginRetryAt": true,
+}
+
+// filledAccount sets every field of a model.Account to a distinct non-zero
+// value, so a column the store forgets shows up as a mismatch. It fails on
+// field types it doesn't know; extend it along with the store.
+func filledAccount(t *testing.T, seed string) *model.Account {
+	t.Helper()
+	a := &model.Account{}
+	v := reflect.ValueOf(a).Elem()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < v.NumField(); i++ {
+		f, name := v.Field(i), v.Type().Field(i).Name
+		switch {
+		case f.Type() == reflect.TypeOf(time.Time{}):
+			f.Set(reflect.ValueOf(at))
+		case f.Type() == reflect.TypeOf(&at):
+			f.Set(reflect.ValueOf(&at))
+		case f.Kind() == reflect.String:
+			f.SetString(seed + "-" + name)
+		case f.Kind() == reflect.Int || f.Kind() == reflect.Int64:
+			f.SetInt(int64(i + 1))
+		case f.Kind() == reflect.Bool:
+			f.SetBool(true)
+		default:
+			t.Fatalf("filledAccount: unhandled type %s of Account.%s", f.Type(), name)
+		}
+	}
+	return a
+}
+
+// compareAccountFields reports every field of got that differs from want,
+// except the skipped ones.
+func compareAccountFields(t *testing.T, what string, got, want *model.Account, skip map[string]bool) {
+	t.Helper()
+	gv, wv := reflect.ValueOf(got).Elem(), reflect.ValueOf(want).Elem()
+	for i := 0; i < gv.NumField(); i++ {
+		name := gv.Type().Field(i).Name
+		if skip[name] {
+			continue
+		}
+		if g, w := gv.Field(i).Interface(), wv.Field(i).Interface(); !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: Account.%s = %v, want %v", what, name, g, w)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+-- A database as left by builds before versioned migrations: the initial
+-- tables plus every blind ALTER TABLE they ran, and no schema_migrations.
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	is_admin INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL DEFAULT 1,
+	name TEXT NOT NULL DEFAULT '',
+	platform TEXT NOT NULL DEFAULT 'qq',
+	code TEXT NOT NULL DEFAULT '',
+	auto_start INTEGER NOT NULL DEFAULT 0,
+	farm_interval INTEGER NOT NULL DEFAULT 10,
+	friend_interval INTEGER NOT NULL DEFAULT 10,
+	enable_steal INTEGER NOT NULL DEFAULT 1,
+	force_lowest INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL,
+	tag TEXT NOT NULL DEFAULT '',
+	message TEXT NOT NULL DEFAULT '',
+	level TEXT NOT NULL DEFAULT 'info',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_logs_account ON logs(account_id, created_at DESC);
+
+ALTER TABLE accounts ADD COLUMN auto_use_fertilizer INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN auto_buy_fertilizer INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN fertilizer_target_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN fertilizer_buy_daily_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN enable_harvest INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_plant INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_sell INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_weed INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_bug INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_water INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_remove_dead INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_upgrade_land INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_help_friend INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN enable_claim_task INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE accounts ADD COLUMN plant_crop_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN sell_crop_ids TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN steal_crop_ids TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN enable_anti_detection INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN api_key TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE op_stats (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL,
+	op_type TEXT NOT NULL,
+	count INTEGER NOT NULL DEFAULT 0,
+	gold_delta INTEGER NOT NULL DEFAULT 0,
+	exp_delta INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_op_stats_account_time ON op_stats(account_id, created_at);
+CREATE INDEX idx_op_stats_type ON op_stats(account_id, op_type, created_at);
+ALTER TABLE op_stats ADD COLUMN detail TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN prefer_bag_seeds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN planting_strategy TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN enable_debug_log INTEGER NOT NULL DEFAULT 0;
+
+INSERT INTO users (id, username, password_hash, is_admin) VALUES (1, 'alice', 'hash', 1);
+INSERT INTO accounts (id, user_id, name, platform, code, farm_interval, enable_harvest, plant_crop_id,
+	steal_crop_ids, api_key, planting_strategy)
+	VALUES (1, 1, 'old farm', 'wx', 'code', 30, 0, 5, '1,2', 'legacy-key', '{"rules":[]}');
+INSERT INTO logs (account_id, tag, message) VALUES (1, '农场', 'harvested');
+INSERT INTO op_stats (account_id, op_type, count, detail, created_at) VALUES (1, 'harvest', 3, '白萝卜', '2026-10-15 12:00:00');
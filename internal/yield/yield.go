@@ -0,0 +1,198 @@
+// Package yield holds the crop growth math shared by the runtime game config
+// and the gen-crop-yield tool: grow_phases parsing, per-season phase data and
+// fertilized grow times.
+package yield
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Phase is one named phase of a grow_phases string.
+type Phase struct {
+	Name    string `json:"name"`
+	Seconds int    `json:"seconds"`
+}
+
+// ParsePhases extracts all phases (including zero-length mature) from a
+// grow_phases string. Format: "name:seconds;name:seconds;...;mature:0;"
+// Malformed segments are skipped and described in issues.
+func ParsePhases(growPhases string) (phases []Phase, issues []string) {
+	for _, phase := range strings.Split(growPhases, ";") {
+		phase = strings.TrimSpace(phase)
+		if phase == "" {
+			continue
+		}
+		parts := strings.Split(phase, ":")
+		if len(parts) != 2 {
+			issues = append(issues, fmt.Sprintf("%q: 应为 名称:秒数", phase))
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("%q: 秒数不是整数", phase))
+			continue
+		}
+		if v < 0 {
+			issues = append(issues, fmt.Sprintf("%q: 秒数为负", phase))
+			continue
+		}
+		phases = append(phases, Phase{Name: strings.TrimSpace(parts[0]), Seconds: v})
+	}
+	return phases, issues
+}
+
+// PhaseData holds parsed phase info for fertilizer optimization.
+type PhaseData struct {
+	PhaseNames           []string // names matching PhaseDurations
+	PhaseDurations       []int    // all non-zero growth phase durations
+	MaxPhaseDuration     int      // longest phase in season 1
+	MaxPhaseIndex        int      // 0-based index of longest phase
+	TotalGrowTime        int      // sum of all phase durations
+	AllPhasesEqual       bool     // true if all phases have the same duration
+	Season2PhaseNames    []string // names matching Season2Phases
+	Season2Phases        []int    // last 3 non-zero phases (for multi-season crops)
+	Season2GrowTime      int      // sum of season 2 phases
+	Season2MaxPhase      int      // longest phase in season 2
+	Season2MaxPhaseIndex int      // index of longest phase within Season2Phases
+	Season2AllEqual      bool     // true if all season 2 phases are equal
+}
+
+// BuildPhaseData parses a plant's grow_phases and computes the max-phase info
+// of each season. It returns nil when there is no growth phase.
+func BuildPhaseData(growPhases string, seasons int) (*PhaseData, []string) {
+	all, issues := ParsePhases(growPhases)
+	pd := &PhaseData{}
+	for _, ph := range all {
+		if ph.Seconds > 0 {
+			pd.PhaseNames = append(pd.PhaseNames, ph.Name)
+			pd.PhaseDurations = append(pd.PhaseDurations, ph.Seconds)
+		}
+	}
+	if len(pd.PhaseDurations) == 0 {
+		return nil, issues
+	}
+	pd.TotalGrowTime, pd.MaxPhaseDuration, pd.MaxPhaseIndex, pd.AllPhasesEqual = summarize(pd.PhaseDurations)
+
+	// For multi-season crops: season 2 uses the last 3 phases from the FULL
+	// phase list (including 成熟:0), then filters to non-zero growth durations.
+	if seasons >= 2 && len(all) >= 3 {
+		for _, ph := range all[len(all)-3:] {
+			if ph.Seconds > 0 {
+				pd.Season2Phases = append(pd.Season2Phases, ph.Seconds)
+				pd.Season2PhaseNames = append(pd.Season2PhaseNames, ph.Name)
+			}
+		}
+		if len(pd.Season2Phases) > 0 {
+			pd.Season2GrowTime, pd.Season2MaxPhase, pd.Season2MaxPhaseIndex, pd.Season2AllEqual = summarize(pd.Season2Phases)
+		}
+	}
+	return pd, issues
+}
+
+func summarize(durations []int) (total, maxDur, maxIdx int, allEqual bool) {
+	allEqual = true
+	for i, d := range durations {
+		total += d
+		if d > maxDur {
+			maxDur = d
+			maxIdx = i
+		}
+		if d != durations[0] {
+			allEqual = false
+		}
+	}
+	return total, maxDur, maxIdx, allEqual
+}
+
+// Fertilizer is the fertilizer assumed when computing grow times.
+type Fertilizer string
+
+const (
+	FertNone    Fertilizer = "none"
+	FertNormal  Fertilizer = "normal"  // skips the longest phase of each season
+	FertOrganic Fertilizer = "organic" // skips the two longest phases of each season
+)
+
+// ParseFertilizer validates a fertilizer name.
+func ParseFertilizer(s string) (Fertilizer, error) {
+	switch f := Fertilizer(s); f {
+	case FertNone, FertNormal, FertOrganic:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown fertilizer %q (none, normal, organic)", s)
+}
+
+// Reduction returns the seconds the fertilizer saves in season 1 and season 2.
+// A nil PhaseData saves nothing.
+func (pd *PhaseData) Reduction(fert Fertilizer) (season1, season2 int) {
+	if pd == nil {
+		return 0, 0
+	}
+	switch fert {
+	case FertNormal:
+		return pd.MaxPhaseDuration, pd.Season2MaxPhase
+	case FertOrganic:
+		return longest(pd.PhaseDurations, 2), longest(pd.Season2Phases, 2)
+	}
+	return 0, 0
+}
+
+// longest returns the sum of the n longest durations.
+func longest(durations []int, n int) int {
+	top := make([]int, 0, n)
+	for _, d := range durations {
+		top = append(top, d)
+		for i := len(top) - 1; i > 0 && top[i] > top[i-1]; i-- {
+			top[i], top[i-1] = top[i-1], top[i]
+		}
+		if len(top) > n {
+			top = top[:n]
+		}
+	}
+	sum := 0
+	for _, d := range top {
+		sum += d
+	}
+	return sum
+}
+
+// Cycle is one full planting of a crop, covering every season it grows.
+type Cycle struct {
+	Harvests             int // 2 for multi-season crops with known season 2 phases
+	GrowSec              int // all seasons without fertilizer
+	GrowSecFert          int // all seasons with the fertilizer
+	Season2GrowSec       int // season 2 without fertilizer (0 if single harvest)
+	FertReduceSec        int // season 1 saving
+	Season2FertReduceSec int // season 2 saving
+}
+
+// CalcCycle computes the grow times of a crop whose season 1 takes
+// growTimeSec. Season 2 is only counted when the phase data knows it.
+func CalcCycle(growTimeSec, seasons int, pd *PhaseData, fert Fertilizer) Cycle {
+	c := Cycle{Harvests: 1, GrowSec: growTimeSec}
+	s1Reduce, s2Reduce := pd.Reduction(fert)
+	c.FertReduceSec = s1Reduce
+	c.GrowSecFert = int(SeasonTime(growTimeSec, s1Reduce, 0))
+	if seasons >= 2 && pd != nil && pd.Season2GrowTime > 0 {
+		c.Harvests = 2
+		c.Season2GrowSec = pd.Season2GrowTime
+		c.Season2FertReduceSec = s2Reduce
+		c.GrowSec += pd.Season2GrowTime
+		c.GrowSecFert += int(SeasonTime(pd.Season2GrowTime, s2Reduce, 0))
+	}
+	return c
+}
+
+// SeasonTime returns the grow time of one season after a land buff shortening
+// growth by timeReducePct (basis points) and a fertilizer saving reduceSec,
+// which the buff shortens as well. It never drops below one second.
+func SeasonTime(growSec, reduceSec int, timeReducePct int64) int64 {
+	base, reduce := int64(growSec), int64(reduceSec)
+	if timeReducePct > 0 {
+		base = base * (10000 - timeReducePct) / 10000
+		reduce = reduce * (10000 - timeReducePct) / 10000
+	}
+	return max(base-reduce, 1)
+}
@@ -0,0 +1,85 @@
+package yield
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePhases(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     string
+		want   []Phase
+		issues int
+	}{
+		{"empty", "", nil, 0},
+		{"well formed", "种子:30;发芽:60;成熟:0", []Phase{{"种子", 30}, {"发芽", 60}, {"成熟", 0}}, 0},
+		{"trailing semicolon", "种子:30;发芽:60;", []Phase{{"种子", 30}, {"发芽", 60}}, 0},
+		{"doubled semicolons", "种子:30;;发芽:60", []Phase{{"种子", 30}, {"发芽", 60}}, 0},
+		{"missing colon", "种子30;发芽:60", []Phase{{"发芽", 60}}, 1},
+		{"no separator at all", "种子", nil, 1},
+		{"too many colons", "种子:30:1;发芽:60", []Phase{{"发芽", 60}}, 1},
+		{"full-width colon", "种子：30", nil, 1},
+		{"negative seconds", "种子:-30;发芽:60", []Phase{{"发芽", 60}}, 1},
+		{"non-numeric seconds", "种子:abc;发芽:1.5", nil, 2},
+		{"ascii whitespace", " 种子 : 30 ;\t发芽:60\n", []Phase{{"种子", 30}, {"发芽", 60}}, 0},
+		{"unicode whitespace", "　种子: 30　;发芽:60 ", []Phase{{"种子", 30}, {"发芽", 60}}, 0},
+		{"only separators", ";;;", nil, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, issues := ParsePhases(tc.in)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("phases = %v, want %v", got, tc.want)
+			}
+			if len(issues) != tc.issues {
+				t.Errorf("issues = %q, want %d", issues, tc.issues)
+			}
+		})
+	}
+}
+
+func TestBuildPhaseData(t *testing.T) {
+	cases := []struct {
+		name      string
+		in        string
+		seasons   int
+		total     int
+		maxPhase  int
+		maxIndex  int
+		season2   []int
+		nilResult bool
+		issues    int
+	}{
+		{name: "single season", in: "种子:30;发芽:90;成熟:0", seasons: 1, total: 120, maxPhase: 90, maxIndex: 1},
+		{name: "two seasons use the last three phases", in: "种子:10;发芽:20;开花:40;结果:30;成熟:0", seasons: 2,
+			total: 100, maxPhase: 40, maxIndex: 2, season2: []int{40, 30}},
+		{name: "malformed phase reported, rest kept", in: "种子:10;发芽;成熟:0", seasons: 1, total: 10, maxPhase: 10, issues: 1},
+		{name: "nothing parseable", in: "种子:x;发芽", seasons: 1, nilResult: true, issues: 2},
+		{name: "only zero phases", in: "成熟:0", seasons: 1, nilResult: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pd, issues := BuildPhaseData(tc.in, tc.seasons)
+			if len(issues) != tc.issues {
+				t.Errorf("issues = %q, want %d", issues, tc.issues)
+			}
+			if tc.nilResult {
+				if pd != nil {
+					t.Fatalf("got %+v, want nil", pd)
+				}
+				return
+			}
+			if pd == nil {
+				t.Fatal("got nil phase data")
+			}
+			if pd.TotalGrowTime != tc.total || pd.MaxPhaseDuration != tc.maxPhase || pd.MaxPhaseIndex != tc.maxIndex {
+				t.Errorf("total/max/index = %d/%d/%d, want %d/%d/%d",
+					pd.TotalGrowTime, pd.MaxPhaseDuration, pd.MaxPhaseIndex, tc.total, tc.maxPhase, tc.maxIndex)
+			}
+			if !reflect.DeepEqual(pd.Season2Phases, tc.season2) {
+				t.Errorf("season 2 = %v, want %v", pd.Season2Phases, tc.season2)
+			}
+		})
+	}
+}
@@ -23,10 +23,43 @@ instance.interceptors.request.use(
   }
 )
 
-// Response interceptor - handle 401 unauthorized
+// Refresh the access token once per burst of 401s
+let refreshing: Promise<string> | null = null
+
+function refreshAccessToken(): Promise<string> {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshing = (refreshToken
+      ? axios.post<LoginResponse>('/api/auth/refresh', { refresh_token: refreshToken }).then((res) => {
+          localStorage.setItem('token', res.data.token)
+          localStorage.setItem('refresh_token', res.data.refresh_token)
+          return res.data.token
+        })
+      : Promise.reject(new Error('no refresh token'))
+    ).finally(() => {
+      refreshing = null
+    })
+  }
+  return refreshing
+}
+
+// Response interceptor - refresh expired access tokens, otherwise handle 401 unauthorized
 instance.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && original && !original._retried && !original.url?.startsWith('/auth/')) {
+      original._retried = true
+      try {
+        const token = await refreshAccessToken()
+        const { useAuthStore } = await import('@/stores/auth')
+        useAuthStore().token = token
+        original.headers.Authorization = `Bearer ${token}`
+        return instance(original)
+      } catch {
+        // fall through to logout
+      }
+    }
     if (error.response?.status === 401) {
       Promise.all([
         import('@/stores/auth'),
@@ -40,9 +73,23 @@ instance.interceptors.response.use(
   }
 )
 
+// Error envelope of every API error response; branch on code, show message
+export interface ApiError {
+  code: string
+  message: string
+  details?: unknown
+}
+
+export function getErrorCode(error: unknown): string | undefined {
+  if (axios.isAxiosError(error) && typeof error.response?.data?.code === 'string') {
+    return error.response.data.code
+  }
+  return undefined
+}
+
 export function getErrorMessage(error: unknown, fallback: string): string {
-  if (axios.isAxiosError(error) && typeof error.response?.data?.error === 'string') {
-    return error.response.data.error
+  if (axios.isAxiosError(error) && typeof error.response?.data?.message === 'string') {
+    return error.response.data.message
   }
   return fallback
 }
@@ -52,10 +99,14 @@ export interface User {
   id: number
   username: string
   is_admin: boolean
+  // Log language; known log lines are translated server-side ('zh' = as stored)
+  locale?: 'zh' | 'en'
 }
 
 export interface LoginResponse {
   token: string
+  expires_in: number
+  refresh_token: string
   user: User
 }
 
@@ -67,8 +118,25 @@ export interface Account {
   auto_start: boolean
   farm_interval: number
   friend_interval: number
+  friend_list_refresh_interval: number
+  warehouse_interval: number
+  family_id?: number
   enable_steal: boolean
   force_lowest: boolean
+  // ForceLowest floors (0 = no limit)
+  force_lowest_min_exp?: number
+  force_lowest_max_price?: number
+  // Remove young crops for a clearly better seed on level-up
+  replant_on_level_up?: boolean
+  replant_margin_pct?: number
+  // Beginner profile below this level (0 = off)
+  warm_up_level?: number
+  gold_reserve?: number
+  notify_before_mature_minutes?: number
+  // Consecutive login failures; auto-start waits until login_retry_at
+  login_fail_reason?: string
+  login_fail_count?: number
+  login_retry_at?: string
   // Farm automation toggles
   enable_harvest: boolean
   enable_plant: boolean
@@ -84,6 +152,7 @@ export interface Account {
   plant_crop_id: number
   sell_crop_ids: string
   steal_crop_ids: string
+  no_harvest_crop_ids: string
   // Fertilizer
   auto_use_fertilizer: boolean
   auto_buy_fertilizer: boolean
@@ -91,19 +160,36 @@ export interface Account {
   fertilizer_buy_daily_limit: number
   // Anti-detection
   enable_anti_detection: boolean
+  interval_jitter_pct?: number
+  rpc_deny?: string
   // Planting preference
   prefer_bag_seeds: boolean
   // Planting strategy (JSON-encoded composable rules)
   planting_strategy: string
+  // Notes and list position (lower first)
+  notes: string
+  sort_order: number
   // External API
   api_key: string
+  // code is masked in listings; has_code tells whether one is saved
+  has_code: boolean
   // Runtime status
   status: 'running' | 'stopped' | 'error'
   level: number
   gold: number
   exp: number
+  // Activity during the current game day (list responses only)
+  today?: {
+    harvest: number
+    steal: number
+    help: number
+    exp_gained: number
+    gold_gained: number
+  }
   created_at: string
   updated_at: string
+  // Set while the account is in the recycle bin
+  deleted_at?: string
 }
 
 export interface CreateAccountRequest {
@@ -112,8 +198,21 @@ export interface CreateAccountRequest {
   auto_start: boolean
   farm_interval: number
   friend_interval: number
+  friend_list_refresh_interval?: number
+  warehouse_interval?: number
+  family_id?: number
   enable_steal: boolean
   force_lowest: boolean
+  // ForceLowest floors (0 = no limit)
+  force_lowest_min_exp?: number
+  force_lowest_max_price?: number
+  // Remove young crops for a clearly better seed on level-up
+  replant_on_level_up?: boolean
+  replant_margin_pct?: number
+  // Beginner profile below this level (0 = off)
+  warm_up_level?: number
+  gold_reserve?: number
+  notify_before_mature_minutes?: number
   // Farm automation toggles
   enable_harvest: boolean
   enable_plant: boolean
@@ -129,12 +228,18 @@ export interface CreateAccountRequest {
   plant_crop_id: number
   sell_crop_ids: string
   steal_crop_ids: string
+  no_harvest_crop_ids?: string
   // Anti-detection
   enable_anti_detection: boolean
+  interval_jitter_pct?: number
+  rpc_deny?: string
   // Planting preference
   prefer_bag_seeds: boolean
   // Planting strategy (JSON-encoded composable rules)
   planting_strategy: string
+  // Notes and list position
+  notes?: string
+  sort_order?: number
   // External API
   api_key?: string
 }
@@ -144,21 +249,97 @@ export interface CropInfo {
   name: string
   seed_id: number
   fruit_id: number
+  fruit_count: number
   exp: number
   seasons: number
   required_level: number
+  grow_time_sec: number
+  grow_time: string
+}
+
+// How one entry of a crop ID list is interpreted
+export interface CropIDInfo {
+  id: number
+  kind: 'plant' | 'fruit' | 'unknown'
+  plant_id?: number
+  name?: string
+}
+
+export interface CropYieldRow {
+  rank: number
+  seed_id: number
+  name: string
+  required_level: number
+  price: number
+  exp_harvest: number
+  seasons: number
+  grow_time_sec: number
+  season2_grow_time_sec: number
+  normal_fert_reduce_sec: number
+  season2_fert_reduce_sec: number
+  grow_time_normal_fert: number
+  farm_exp_per_hour_normal: number
+  fruit_id: number
+  fruit_count: number
+  fruit_price: number
+  farm_gold_per_hour_fert: number
+  grow_time: string
+  grow_time_fert: string
+}
+
+export interface CropPhaseSet {
+  phases: { name: string; seconds: number }[]
+  max_phase_index: number
+  total_grow_sec: number
+  fert_grow_sec: number
+  all_equal: boolean
+}
+
+export interface CropPhases {
+  plant_id: number
+  name: string
+  seasons: number
+  season1: CropPhaseSet
+  season2?: CropPhaseSet
 }
 
 export interface BotStatus {
   running: boolean
   level: number
   gold: number
+  coupons?: number
   exp: number
   current_exp: number
   max_exp: number
   nickname: string
   platform: string
   started_at: string | null
+  // Measured from harvest replies since start
+  total_harvest?: number
+  harvest_exp?: number
+  measured_crop_exp_per_hour?: number
+  harvest_items?: Record<string, number>
+  game_events?: GameEvent[]
+  // e.g. 'bag_full', 'conserve'
+  warnings?: string[]
+  // Pass durations of the worker loops, for tuning their intervals
+  cycles?: Partial<Record<'farm' | 'friend' | 'fertilizer', CycleTiming>>
+}
+
+export interface CycleTiming {
+  last_ms: number
+  avg_ms: number
+  passes: number
+  overruns?: number
+  skipped?: number
+}
+
+// Server event inferred from replies, e.g. double exp from harvest exp
+export interface GameEvent {
+  kind: 'double_exp'
+  multiplier?: number
+  since: string
+  last_seen: string
 }
 
 export interface LandStatus {
@@ -188,22 +369,47 @@ export interface LandStatus {
   dry_num?: number
   has_weeds?: boolean
   has_insects?: boolean
+  needs_water?: boolean
+  fertilized?: boolean
   fert_times_left?: number
+  kept?: boolean // crop is in no_harvest_crop_ids
   // Debug: land meta
   could_upgrade?: boolean
   could_unlock?: boolean
   master_land_id?: number
 }
 
+// Dashboard banner line
+export interface FleetAlert {
+  severity: 'error' | 'warning'
+  account_id: number
+  message: string
+}
+
 export interface DashboardStats {
   total_accounts: number
   running_bots: number
   total_gold: number
+  // Fleet rollup
+  exp_per_hour: number
+  needs_relogin: number
+  error_accounts: number
+  harvest_today: number
+  steal_today: number
+  next_level_up: {
+    account_id: number
+    name: string
+    level: number
+    hours: number
+    at: string
+  } | null
+  alerts: FleetAlert[]
   accounts: Array<{
     id: number
     name: string
     level: number
     gold: number
+    coupons?: number
     exp: number
     status: string
     platform: string
@@ -217,6 +423,17 @@ export interface DashboardStats {
     next_level_exp: number
     exp_to_next_level: number
     hours_to_next_level: number
+    crop_exp_per_hour?: number
+    steal_exp_per_hour?: number
+    pending_task_exp?: number
+    config_health?: string
+    // Today's gold spent vs sell proceeds plus stolen fruit value
+    spend_today?: number
+    income_today?: number
+    // When the account next needs attention
+    next_event_at?: string
+    next_event_type?: 'mature' | 'dry' | 'weeds' | 'insects'
+    next_farm_check_at?: string
     uptime_seconds: number
     started_at: string | null
   }>
@@ -229,18 +446,97 @@ export interface LogEntry {
   level: 'info' | 'warn' | 'error' | 'debug'
   tag: string
   message: string
+  // Request id of the API action that caused the entry
+  correlation_id?: string
+}
+
+export interface BagItem {
+  id: number
+  name: string
+  count: number
+  category: 'fruit' | 'seed' | 'fertilizer' | 'currency' | 'other'
+}
+
+export interface BagSnapshot {
+  items: BagItem[]
+  fetched_at: string
+}
+
+export interface SeedPlanEntry {
+  seed_id: number
+  goods_id?: number
+  name: string
+  required_level: number
+  price: number
+  exp_per_hour: number
+  gold_per_hour: number
 }
 
+export interface SeedElimination {
+  seed_id: number
+  name: string
+  reason: 'locked' | 'level' | 'limit' | 'plant_crop_id' | 'force_lowest'
+}
+
+// Seed the bot would buy now and why
+export interface SeedPlan {
+  level: number
+  land_count: number
+  // shop: the bot's last seed shop reply; config: game config data, no purchase limits
+  source: 'shop' | 'config'
+  shop_at?: string
+  degraded?: boolean
+  rule?: 'conserve' | 'warm_up' | 'plant_crop_id' | 'double_exp' | 'strategy' | 'force_lowest' | 'efficiency' | 'level'
+  chosen?: SeedPlanEntry
+  candidates: SeedPlanEntry[]
+  eliminated: SeedElimination[]
+  notes?: string[]
+}
+
+export type LandAction = 'harvest' | 'water' | 'weed' | 'bug' | 'remove' | 'fertilize'
+
+// Weeds/insects a friend left on an account's lands over the queried days
+export interface FriendGrief {
+  friend_gid: number
+  friend_name: string
+  weeds: number
+  insects: number
+}
+
+export type ActivityType =
+  | 'level_up' | 'land_unlock' | 'land_upgrade' | 'gold_change'
+  | 'bot_start' | 'bot_stop' | 'disconnect' | 'reconnect' | 'needs_relogin'
+  | 'coupon_spend'
+  | 'game_event' | 'replant'
+
+// Notable account event; payload fields depend on the type
+export interface ActivityEvent {
+  id: number
+  account_id: number
+  type: ActivityType
+  payload: Record<string, unknown>
+  created_at: string
+}
+
+export interface ActivityQuery {
+  type?: ActivityType[]
+  since?: string
+  until?: string
+  before_id?: number
+  limit?: number
+}
+
+// Scan login session polled by the server; the code is saved to the account on success
 export interface QRCodeResponse {
+  id: string
+  account_id: number
+  platform: 'qq' | 'wx'
   login_code: string
   qr_code_url: string
-  expires_at: string
-}
-
-export interface QRCodePollResponse {
   status: 'wait' | 'ok' | 'expired' | 'error'
-  code?: string
   message?: string
+  created_at: string
+  expires_at: string
 }
 
 export interface StatsResponse {
@@ -271,24 +567,77 @@ export const authApi = {
   register: (username: string, password: string): Promise<AxiosResponse<LoginResponse>> => 
     instance.post('/auth/register', { username, password }),
   
-  logout: (): Promise<AxiosResponse<void>> => 
-    instance.post('/auth/logout')
+  logout: (refreshToken: string): Promise<AxiosResponse<void>> => 
+    instance.post('/auth/logout', { refresh_token: refreshToken }),
+
+  setLocale: (locale: 'zh' | 'en'): Promise<AxiosResponse<{ locale: string }>> =>
+    instance.put('/auth/locale', { locale })
 }
 
 export const accountApi = {
   getAll: (): Promise<AxiosResponse<Account[]>> => 
     instance.get('/accounts'),
+
+  // One page of accounts; the total count is in the X-Total-Count header
+  list: (params: {
+    q?: string
+    platform?: 'qq' | 'wx'
+    tag?: string
+    status?: 'running' | 'stopped' | 'error'
+    limit?: number
+    offset?: number
+  } = {}): Promise<AxiosResponse<Account[]>> =>
+    instance.get('/accounts', { params }),
   
-  create: (data: CreateAccountRequest): Promise<AxiosResponse<Account>> => 
+  // warnings lists other accounts saved with the same login code
+  create: (data: CreateAccountRequest): Promise<AxiosResponse<Account & { warnings?: string[] }>> => 
     instance.post('/accounts', data),
   
-  update: (id: number, data: Partial<CreateAccountRequest>): Promise<AxiosResponse<Account>> => 
+  // warnings lists crop IDs that match no plant or fruit
+  update: (id: number, data: Partial<CreateAccountRequest>): Promise<AxiosResponse<Account & { warnings?: string[] }>> => 
     instance.put(`/accounts/${id}`, data),
   
   delete: (id: number): Promise<AxiosResponse<void>> => 
     instance.delete(`/accounts/${id}`),
+
+  // The login code is changed only here, never through update
+  setCode: (id: number, code: string): Promise<AxiosResponse<{ message: string; has_code: boolean; warnings?: string[] }>> =>
+    instance.post(`/accounts/${id}/code`, { code }),
+
+  clone: (id: number): Promise<AxiosResponse<Account>> =>
+    instance.post(`/accounts/${id}/clone`),
+
+  getTrash: (): Promise<AxiosResponse<Account[]>> =>
+    instance.get('/accounts/trash'),
+
+  restore: (id: number): Promise<AxiosResponse<Account>> =>
+    instance.post(`/accounts/${id}/restore`),
+
+  // Own accounts in display order; unlisted accounts keep their order after them
+  reorder: (ids: number[]): Promise<AxiosResponse<{ message: string }>> =>
+    instance.put('/accounts/reorder', { ids }),
+
+  getBag: (id: number): Promise<AxiosResponse<BagSnapshot>> =>
+    instance.get(`/accounts/${id}/bag`),
+
+  getPlantPlan: (id: number): Promise<AxiosResponse<SeedPlan>> =>
+    instance.get(`/accounts/${id}/plant-plan`),
+
+  // Runs between farm cycles; fails with BOT_NOT_RUNNING (409) when stopped
+  landAction: (id: number, landId: number, action: LandAction): Promise<AxiosResponse<{ message: string }>> =>
+    instance.post(`/accounts/${id}/lands/${landId}/action`, { action }),
+
+  // RPC trace (metadata only) under the "RPC" debug tag; switches itself off
+  // after minutes (default 10). Fails with BOT_NOT_RUNNING (409) when stopped
+  setTrace: (id: number, enabled: boolean, minutes?: number): Promise<AxiosResponse<{ enabled: boolean; until?: string }>> =>
+    instance.post(`/accounts/${id}/trace`, { enabled, minutes }),
+
+  // Raw lands reply and analyzer verdicts (server needs debug_endpoints_enabled)
+  debugLands: (id: number): Promise<AxiosResponse<{ captured_at: string; reply: unknown; classification: Record<string, string[]> }>> =>
+    instance.get(`/accounts/${id}/debug/lands`),
   
-  start: (id: number): Promise<AxiosResponse<{ message: string }>> => 
+  // warning is set when a persisted login backoff was overridden
+  start: (id: number): Promise<AxiosResponse<{ message: string; warning?: string }>> => 
     instance.post(`/accounts/${id}/start`),
   
   stop: (id: number): Promise<AxiosResponse<{ message: string }>> => 
@@ -300,16 +649,29 @@ export const accountApi = {
   getQRCode: (id: number): Promise<AxiosResponse<QRCodeResponse>> => 
     instance.post(`/accounts/${id}/qrcode`),
   
-  pollQRCode: (id: number, loginCode: string): Promise<AxiosResponse<QRCodePollResponse>> => 
-    instance.get(`/accounts/${id}/qrcode/poll`, { params: { login_code: loginCode } }),
+  getQRSession: (sessionId: string): Promise<AxiosResponse<QRCodeResponse>> =>
+    instance.get(`/qrcode/sessions/${sessionId}`),
+
+  // URL for an <img>: the PNG is rendered server-side (token in query, as for WebSockets)
+  qrCodeImageUrl: (id: number, loginCode: string): string =>
+    `/api/accounts/${id}/qrcode/image?login_code=${encodeURIComponent(loginCode)}&token=${localStorage.getItem('token')}`,
   
   getLogs: (id: number, limit: number = 100): Promise<AxiosResponse<LogEntry[]>> => 
     instance.get(`/accounts/${id}/logs`, { params: { limit } })
 }
 
 export const cropApi = {
-  getAll: (): Promise<AxiosResponse<CropInfo[]>> =>
-    instance.get('/crops')
+  getAll: (level?: number): Promise<AxiosResponse<CropInfo[]>> =>
+    instance.get('/crops', { params: level ? { level } : {} }),
+
+  getYield: (params: { lands?: number; level?: number; strategy?: 'exp' | 'time' } = {}): Promise<AxiosResponse<CropYieldRow[]>> =>
+    instance.get('/crops/yield', { params }),
+
+  getPhases: (plantId: number): Promise<AxiosResponse<CropPhases>> =>
+    instance.get(`/crops/${plantId}/phases`),
+
+  resolve: (ids: number[]): Promise<AxiosResponse<CropIDInfo[]>> =>
+    instance.get('/crops/resolve', { params: { ids: ids.join(',') } })
 }
 
 export const dashboardApi = {
@@ -319,7 +681,13 @@ export const dashboardApi = {
 
 export const logsApi = {
   getHistorical: (accountId: number, limit: number = 100): Promise<AxiosResponse<LogEntry[]>> => 
-    instance.get(`/accounts/${accountId}/logs`, { params: { limit } })
+    instance.get(`/accounts/${accountId}/logs`, { params: { limit } }),
+
+  getSystem: (limit: number = 100): Promise<AxiosResponse<LogEntry[]>> =>
+    instance.get('/system/logs', { params: { limit } }),
+
+  getActivity: (accountId: number, q: ActivityQuery = {}): Promise<AxiosResponse<ActivityEvent[]>> =>
+    instance.get(`/accounts/${accountId}/activity`, { params: { ...q, type: q.type?.join(',') || undefined } })
 }
 
 export const statsApi = {
@@ -328,7 +696,23 @@ export const statsApi = {
     if (from) params.from = from
     if (to) params.to = to
     return instance.get(`/accounts/${accountId}/stats`, { params })
-  }
+  },
+  getSpend: (accountId: number): Promise<AxiosResponse<SpendResponse>> =>
+    instance.get(`/accounts/${accountId}/spend`)
+}
+
+export interface SpendPeriod {
+  since: string
+  spend: Partial<Record<'seed' | 'land_unlock' | 'land_upgrade', number>>
+  total_spend: number
+  sell_income: number
+  steal_value: number
+  total_income: number
+}
+
+export interface SpendResponse {
+  today: SpendPeriod
+  week: SpendPeriod
 }
 
 export interface DataSummaryResponse {
@@ -370,6 +754,58 @@ export const dataSummaryApi = {
     instance.get(`/accounts/${accountId}/data-summary`, { params: { hours, days } })
 }
 
+export interface DailySummary {
+  account_id: number
+  date: string
+  level: number
+  gold: number
+  exp: number
+  total_harvest: number
+  total_steal: number
+  total_help: number
+  updated_at: string
+}
+
+export interface HistoryTotal {
+  date: string
+  accounts: number
+  gold: number
+  exp: number
+  total_harvest: number
+  total_steal: number
+  total_help: number
+}
+
+export const historyApi = {
+  getAccount: (accountId: number, days: number = 30): Promise<AxiosResponse<DailySummary[]>> =>
+    instance.get(`/accounts/${accountId}/history`, { params: { days } }),
+  getAll: (days: number = 30): Promise<AxiosResponse<{ totals: HistoryTotal[]; accounts: DailySummary[] }>> =>
+    instance.get('/history', { params: { days } }),
+  getGrief: (accountId: number, days: number = 30): Promise<AxiosResponse<FriendGrief[]>> =>
+    instance.get(`/accounts/${accountId}/grief`, { params: { days } })
+}
+
+export interface ApiToken {
+  id: number
+  user_id: number
+  name: string
+  prefix: string
+  created_at: string
+  last_used_at?: string
+  expires_at?: string
+}
+
+// Personal access tokens: send as "Authorization: Bearer pat_..." from scripts
+export const tokenApi = {
+  list: (): Promise<AxiosResponse<ApiToken[]>> =>
+    instance.get('/tokens'),
+  // The secret is only returned by this call
+  create: (name: string, expiresInDays = 0): Promise<AxiosResponse<{ token: string; info: ApiToken }>> =>
+    instance.post('/tokens', { name, expires_in_days: expiresInDays }),
+  revoke: (id: number): Promise<AxiosResponse<{ message: string }>> =>
+    instance.delete(`/tokens/${id}`)
+}
+
 export function createLogWebSocket(accountId: number): WebSocket {
   const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
   const host = window.location.host
@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import type { User } from '@/api'
+import { authApi, type User } from '@/api'
 
 export const useAuthStore = defineStore('auth', () => {
   const token = ref<string | null>(localStorage.getItem('token'))
@@ -18,21 +18,33 @@ export const useAuthStore = defineStore('auth', () => {
 
   const isAuthenticated = computed(() => !!token.value)
 
-  function setAuth(newToken: string, newUser: User) {
+  function setAuth(newToken: string, newUser: User, refreshToken: string) {
     token.value = newToken
     user.value = newUser
     localStorage.setItem('token', newToken)
+    localStorage.setItem('refresh_token', refreshToken)
     localStorage.setItem('user', JSON.stringify(newUser))
   }
 
+  function setLocale(locale: 'zh' | 'en') {
+    if (!user.value) return
+    user.value = { ...user.value, locale }
+    localStorage.setItem('user', JSON.stringify(user.value))
+  }
+
   function clearAuth() {
     token.value = null
     user.value = null
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     localStorage.removeItem('user')
   }
 
   function logout() {
+    const refreshToken = localStorage.getItem('refresh_token')
+    if (refreshToken) {
+      authApi.logout(refreshToken).catch(() => {})
+    }
     clearAuth()
   }
 
@@ -41,6 +53,7 @@ export const useAuthStore = defineStore('auth', () => {
     user,
     isAuthenticated,
     setAuth,
+    setLocale,
     clearAuth,
     logout
   }